@@ -21,25 +21,28 @@ import (
 
 // Knowledge represents knowledge information
 type Knowledge struct {
-	ID               string            `json:"id"`
-	TenantID         uint              `json:"tenant_id"`
-	KnowledgeBaseID  string            `json:"knowledge_base_id"`
-	Type             string            `json:"type"`
-	Title            string            `json:"title"`
-	Description      string            `json:"description"`
-	Source           string            `json:"source"`
-	ParseStatus      string            `json:"parse_status"`
-	EnableStatus     string            `json:"enable_status"`
-	EmbeddingModelID string            `json:"embedding_model_id"`
-	FileName         string            `json:"file_name"`
-	FileType         string            `json:"file_type"`
-	FileSize         int64             `json:"file_size"`
-	FilePath         string            `json:"file_path"`
-	Metadata         map[string]string `json:"metadata"` // Extensible metadata for storing machine information, paths, etc.
-	CreatedAt        time.Time         `json:"created_at"`
-	UpdatedAt        time.Time         `json:"updated_at"`
-	ProcessedAt      *time.Time        `json:"processed_at"`
-	ErrorMessage     string            `json:"error_message"`
+	ID                  string            `json:"id"`
+	TenantID            uint              `json:"tenant_id"`
+	KnowledgeBaseID     string            `json:"knowledge_base_id"`
+	Type                string            `json:"type"`
+	Title               string            `json:"title"`
+	Description         string            `json:"description"`
+	Source              string            `json:"source"`
+	ParseStatus         string            `json:"parse_status"`
+	EnableStatus        string            `json:"enable_status"`
+	EmbeddingModelID    string            `json:"embedding_model_id"`
+	FileName            string            `json:"file_name"`
+	FileType            string            `json:"file_type"`
+	FileSize            int64             `json:"file_size"`
+	FilePath            string            `json:"file_path"`
+	Metadata            map[string]string `json:"metadata"`             // Extensible metadata for storing machine information, paths, etc.
+	AccessLabels        []string          `json:"access_labels"`        // Only an audience holding one of the labels can retrieve the knowledge
+	DuplicateOf         string            `json:"duplicate_of"`         // Earlier knowledge whose content this knowledge nearly duplicates
+	DuplicateSimilarity float64           `json:"duplicate_similarity"` // Estimated similarity to the knowledge it nearly duplicates
+	CreatedAt           time.Time         `json:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at"`
+	ProcessedAt         *time.Time        `json:"processed_at"`
+	ErrorMessage        string            `json:"error_message"`
 }
 
 // KnowledgeResponse represents the API response containing a single knowledge entry
@@ -280,6 +283,74 @@ func (c *Client) DeleteKnowledge(ctx context.Context, knowledgeID string) error
 	return parseResponse(resp, &response)
 }
 
+// DeleteKnowledgeBatch deletes multiple knowledge entries by their IDs
+func (c *Client) DeleteKnowledgeBatch(ctx context.Context, knowledgeIDs []string) error {
+	path := "/api/v1/knowledge/batch"
+
+	queryParams := url.Values{}
+	for _, id := range knowledgeIDs {
+		queryParams.Add("ids", id)
+	}
+
+	resp, err := c.doRequest(ctx, http.MethodDelete, path, nil, queryParams)
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Success bool   `json:"success"`
+		Message string `json:"message,omitempty"`
+	}
+
+	return parseResponse(resp, &response)
+}
+
+// DuplicateCluster represents a group of knowledge whose contents nearly duplicate each other
+type DuplicateCluster struct {
+	Knowledge []DuplicateClusterMember `json:"knowledge"` // Earliest created first
+}
+
+// DuplicateClusterMember represents a knowledge entry of a duplicate cluster
+type DuplicateClusterMember struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Type        string    `json:"type"`
+	Source      string    `json:"source"`
+	FileName    string    `json:"file_name"`
+	ParseStatus string    `json:"parse_status"`
+	DuplicateOf string    `json:"duplicate_of"`
+	CreatedAt   time.Time `json:"created_at"`
+	Similarity  float64   `json:"similarity"` // Estimated similarity to the first knowledge of the cluster
+}
+
+// ListDuplicateClusters lists the clusters of near-duplicate knowledge in a knowledge base.
+// A threshold of 0 uses the dedup threshold of the knowledge base.
+func (c *Client) ListDuplicateClusters(ctx context.Context,
+	knowledgeBaseID string, threshold float64,
+) ([]DuplicateCluster, error) {
+	path := fmt.Sprintf("/api/v1/knowledge-bases/%s/knowledge/duplicates", knowledgeBaseID)
+
+	queryParams := url.Values{}
+	if threshold > 0 {
+		queryParams.Add("threshold", strconv.FormatFloat(threshold, 'f', -1, 64))
+	}
+
+	resp, err := c.doRequest(ctx, http.MethodGet, path, nil, queryParams)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Success bool               `json:"success"`
+		Data    []DuplicateCluster `json:"data"`
+	}
+	if err := parseResponse(resp, &response); err != nil {
+		return nil, err
+	}
+
+	return response.Data, nil
+}
+
 // DownloadKnowledgeFile downloads a knowledge file to the specified local path
 func (c *Client) DownloadKnowledgeFile(ctx context.Context, knowledgeID string, destPath string) error {
 	path := fmt.Sprintf("/api/v1/knowledge/%s/download", knowledgeID)
@@ -363,8 +363,9 @@ func (c *Client) ContinueStream(ctx context.Context, sessionID string, messageID
 
 // SearchKnowledgeRequest knowledge search request
 type SearchKnowledgeRequest struct {
-	Query           string `json:"query"`             // Query content
-	KnowledgeBaseID string `json:"knowledge_base_id"` // Knowledge base ID
+	Query           string   `json:"query"`              // Query content
+	KnowledgeBaseID string   `json:"knowledge_base_id"`  // Knowledge base ID
+	Audience        []string `json:"audience,omitempty"` // Access labels of the audience
 }
 
 // SearchKnowledgeResponse search results response
@@ -63,7 +63,14 @@ export function importDocsiteKnowledge(kbId: string, data: { base_url: string; m
 }
 
 // 批量导入任务 API
-export function createImportTask(kbId: string, data: { base_url: string; max_pages?: number; enable_multimodel?: boolean }) {
+export function createImportTask(kbId: string, data: {
+  base_url: string;
+  max_pages?: number;
+  enable_multimodel?: boolean;
+  schedule?: string;
+  include_patterns?: string[];
+  exclude_patterns?: string[];
+}) {
   return post(`/api/v1/knowledge-bases/${kbId}/import-tasks`, data);
 }
 
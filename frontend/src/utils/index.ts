@@ -23,7 +23,7 @@ export function formatStringDate(date: any) {
   );
 }
 export function kbFileTypeVerification(file: any) {
-  let validTypes = ["pdf", "txt", "md", "docx", "doc", "jpg", "jpeg", "png"];
+  let validTypes = ["pdf", "txt", "md", "docx", "doc", "jpg", "jpeg", "png", "html", "htm", "csv", "json", "jsonl"];
   let type = file.name.substring(file.name.lastIndexOf(".") + 1);
   if (!validTypes.includes(type)) {
     MessagePlugin.error("文件类型错误！");
@@ -36,8 +36,8 @@ export function kbFileTypeVerification(file: any) {
     MessagePlugin.error("pdf/doc文件不能超过30M！");
     return true;
   }
-  if ((type == "txt" || type == "md") && file.size > 31457280) {
-    MessagePlugin.error("txt/md文件不能超过30M！");
+  if (["txt", "md", "html", "htm", "csv", "json", "jsonl"].includes(type) && file.size > 31457280) {
+    MessagePlugin.error("文本文件不能超过30M！");
     return true;
   }
   return false
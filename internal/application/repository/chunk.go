@@ -59,7 +59,7 @@ func (r *chunkRepository) ListChunksByKnowledgeID(
 ) ([]*types.Chunk, error) {
 	var chunks []*types.Chunk
 	if err := r.db.WithContext(ctx).
-		Select("id, content, knowledge_id, knowledge_base_id, start_at, end_at, chunk_index, is_enabled, chunk_type, parent_chunk_id, image_info").
+		Select("id, content, knowledge_id, knowledge_base_id, start_at, end_at, chunk_index, is_enabled, chunk_type, parent_chunk_id, image_info, metadata").
 		Where("tenant_id = ? AND knowledge_id = ? and chunk_type = ?", tenantID, knowledgeID, "text").
 		Order("chunk_index ASC").
 		Find(&chunks).Error; err != nil {
@@ -68,6 +68,36 @@ func (r *chunkRepository) ListChunksByKnowledgeID(
 	return chunks, nil
 }
 
+// ListChunkIDsByKnowledgeBaseID lists the IDs of the enabled chunks of the given types in a knowledge base
+func (r *chunkRepository) ListChunkIDsByKnowledgeBaseID(
+	ctx context.Context, tenantID uint, knowledgeBaseID string, chunkTypes []types.ChunkType,
+) ([]string, error) {
+	var ids []string
+	if err := r.db.WithContext(ctx).Model(&types.Chunk{}).
+		Where("tenant_id = ? AND knowledge_base_id = ? AND is_enabled = ? AND chunk_type IN ?",
+			tenantID, knowledgeBaseID, true, chunkTypes).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// ListChunksByKnowledgeIDAndType lists the chunks of a knowledge ID with all their fields,
+// chunks of every type are listed if chunkTypes is empty
+func (r *chunkRepository) ListChunksByKnowledgeIDAndType(
+	ctx context.Context, tenantID uint, knowledgeID string, chunkTypes []types.ChunkType,
+) ([]*types.Chunk, error) {
+	var chunks []*types.Chunk
+	query := r.db.WithContext(ctx).Where("tenant_id = ? AND knowledge_id = ?", tenantID, knowledgeID)
+	if len(chunkTypes) > 0 {
+		query = query.Where("chunk_type IN ?", chunkTypes)
+	}
+	if err := query.Order("chunk_index ASC").Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
 // ListPagedChunksByKnowledgeID lists chunks for a knowledge ID with pagination
 func (r *chunkRepository) ListPagedChunksByKnowledgeID(
 	ctx context.Context, tenantID uint, knowledgeID string, page *types.Pagination, chunk_type []types.ChunkType,
@@ -84,7 +114,7 @@ func (r *chunkRepository) ListPagedChunksByKnowledgeID(
 
 	// Then query the paginated data
 	if err := r.db.WithContext(ctx).
-		Select("id, content, knowledge_id, knowledge_base_id, start_at, end_at, chunk_index, is_enabled, chunk_type, parent_chunk_id, image_info").
+		Select("id, content, knowledge_id, knowledge_base_id, start_at, end_at, chunk_index, is_enabled, chunk_type, parent_chunk_id, image_info, metadata").
 		Where("tenant_id = ? AND knowledge_id = ? and chunk_type in (?)", tenantID, knowledgeID, chunk_type).
 		Order("chunk_index ASC").
 		Offset(page.Offset()).
@@ -116,6 +146,11 @@ func (r *chunkRepository) DeleteChunk(ctx context.Context, tenantID uint, id str
 	return r.db.WithContext(ctx).Where("tenant_id = ? AND id = ?", tenantID, id).Delete(&types.Chunk{}).Error
 }
 
+// DeleteChunksByIDList deletes chunks by their IDs
+func (r *chunkRepository) DeleteChunksByIDList(ctx context.Context, tenantID uint, ids []string) error {
+	return r.db.WithContext(ctx).Where("tenant_id = ? AND id IN ?", tenantID, ids).Delete(&types.Chunk{}).Error
+}
+
 // DeleteChunksByKnowledgeID deletes all chunks for a knowledge ID
 func (r *chunkRepository) DeleteChunksByKnowledgeID(ctx context.Context, tenantID uint, knowledgeID string) error {
 	return r.db.WithContext(ctx).Where(
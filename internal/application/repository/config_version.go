@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"gorm.io/gorm"
+)
+
+var ErrConfigVersionNotFound = errors.New("config version not found")
+
+type configVersionRepository struct {
+	db *gorm.DB
+}
+
+func NewConfigVersionRepository(db *gorm.DB) interfaces.ConfigVersionRepository {
+	return &configVersionRepository{db: db}
+}
+
+func (r *configVersionRepository) Create(ctx context.Context, version *types.ConfigVersion) error {
+	return r.db.WithContext(ctx).Create(version).Error
+}
+
+func (r *configVersionRepository) GetByID(ctx context.Context, id uint) (*types.ConfigVersion, error) {
+	var version types.ConfigVersion
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&version).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConfigVersionNotFound
+		}
+		return nil, err
+	}
+	return &version, nil
+}
+
+// GetLatest returns the newest version, nil if there is none
+func (r *configVersionRepository) GetLatest(ctx context.Context) (*types.ConfigVersion, error) {
+	var version types.ConfigVersion
+	if err := r.db.WithContext(ctx).Order("id DESC").First(&version).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &version, nil
+}
+
+// GetLatestBySource returns the newest version created from one of the sources, nil if there is none
+func (r *configVersionRepository) GetLatestBySource(ctx context.Context,
+	sources ...types.ConfigVersionSource,
+) (*types.ConfigVersion, error) {
+	var version types.ConfigVersion
+	if err := r.db.WithContext(ctx).Where("source IN ?", sources).Order("id DESC").First(&version).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &version, nil
+}
+
+// List lists versions newest first, without their content
+func (r *configVersionRepository) List(ctx context.Context, pagination *types.Pagination) ([]*types.ConfigVersion, int64, error) {
+	var versions []*types.ConfigVersion
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&types.ConfigVersion{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Select("id", "source", "restored_from", "checksum", "created_at").
+		Order("id DESC").
+		Limit(pagination.GetPageSize()).
+		Offset(pagination.Offset()).
+		Find(&versions).Error
+	return versions, total, err
+}
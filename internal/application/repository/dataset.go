@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDatasetNotFound               = errors.New("dataset not found")
+	ErrDatasetGenerationTaskNotFound = errors.New("dataset generation task not found")
+)
+
+// datasetRepository implements the dataset repository interface
+type datasetRepository struct {
+	db *gorm.DB
+}
+
+// NewDatasetRepository creates a new dataset repository
+func NewDatasetRepository(db *gorm.DB) interfaces.DatasetRepository {
+	return &datasetRepository{db: db}
+}
+
+// CreateDataset stores a dataset with its records
+func (r *datasetRepository) CreateDataset(ctx context.Context,
+	dataset *types.Dataset, records []*types.DatasetRecord,
+) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(dataset).Error; err != nil {
+			return err
+		}
+		for _, record := range records {
+			record.TenantID = dataset.TenantID
+			record.DatasetID = dataset.ID
+		}
+		return tx.CreateInBatches(records, 500).Error
+	})
+}
+
+// GetDataset gets a dataset of a tenant
+func (r *datasetRepository) GetDataset(ctx context.Context, tenantID uint, id string) (*types.Dataset, error) {
+	var dataset types.Dataset
+	if err := r.db.WithContext(ctx).Where("tenant_id = ? AND id = ?", tenantID, id).First(&dataset).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDatasetNotFound
+		}
+		return nil, err
+	}
+	return &dataset, nil
+}
+
+// ListDatasets lists the datasets of a tenant, newest first
+func (r *datasetRepository) ListDatasets(ctx context.Context,
+	tenantID uint, pagination *types.Pagination,
+) ([]*types.Dataset, int64, error) {
+	var datasets []*types.Dataset
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&types.Dataset{}).Where("tenant_id = ?", tenantID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("created_at DESC").
+		Limit(pagination.GetPageSize()).
+		Offset(pagination.Offset()).
+		Find(&datasets).Error
+	return datasets, total, err
+}
+
+// ListRecords lists the records of a dataset by question ID
+func (r *datasetRepository) ListRecords(ctx context.Context,
+	tenantID uint, datasetID string,
+) ([]*types.DatasetRecord, error) {
+	var records []*types.DatasetRecord
+	err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND dataset_id = ?", tenantID, datasetID).
+		Order("question_id").
+		Find(&records).Error
+	return records, err
+}
+
+// DeleteDataset deletes a dataset with its records
+func (r *datasetRepository) DeleteDataset(ctx context.Context, tenantID uint, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("tenant_id = ? AND id = ?", tenantID, id).Delete(&types.Dataset{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrDatasetNotFound
+		}
+		return tx.Where("tenant_id = ? AND dataset_id = ?", tenantID, id).Delete(&types.DatasetRecord{}).Error
+	})
+}
+
+// CreateGenerationTask stores a new dataset generation task
+func (r *datasetRepository) CreateGenerationTask(ctx context.Context, task *types.DatasetGenerationTask) error {
+	return r.db.WithContext(ctx).Create(task).Error
+}
+
+// GetGenerationTask gets a dataset generation task of a tenant
+func (r *datasetRepository) GetGenerationTask(ctx context.Context,
+	tenantID uint, id string,
+) (*types.DatasetGenerationTask, error) {
+	var task types.DatasetGenerationTask
+	if err := r.db.WithContext(ctx).Where("tenant_id = ? AND id = ?", tenantID, id).First(&task).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDatasetGenerationTaskNotFound
+		}
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ListGenerationTasks lists the dataset generation tasks of a tenant, newest first
+func (r *datasetRepository) ListGenerationTasks(ctx context.Context,
+	tenantID uint, pagination *types.Pagination,
+) ([]*types.DatasetGenerationTask, int64, error) {
+	var tasks []*types.DatasetGenerationTask
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&types.DatasetGenerationTask{}).Where("tenant_id = ?", tenantID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("created_at DESC").
+		Limit(pagination.GetPageSize()).
+		Offset(pagination.Offset()).
+		Find(&tasks).Error
+	return tasks, total, err
+}
+
+// UpdateGenerationStatus moves a pending or processing generation task to the given status.
+// It reports false if the task already finished, e.g. because it was cancelled.
+func (r *datasetRepository) UpdateGenerationStatus(ctx context.Context,
+	id string, status types.DatasetGenerationStatus, errorMsg string,
+) (bool, error) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":        status,
+		"error_message": errorMsg,
+		"updated_at":    now,
+	}
+	if status != types.DatasetGenerationStatusPending && status != types.DatasetGenerationStatusProcessing {
+		updates["completed_at"] = &now
+	}
+	return r.updateRunningGenerationTask(ctx, id, updates)
+}
+
+// CompleteGenerationTask marks a processing generation task as completed with the generated dataset.
+// It reports false if the task was cancelled meanwhile.
+func (r *datasetRepository) CompleteGenerationTask(ctx context.Context, id string, datasetID string) (bool, error) {
+	now := time.Now()
+	return r.updateRunningGenerationTask(ctx, id, map[string]interface{}{
+		"status":       types.DatasetGenerationStatusCompleted,
+		"dataset_id":   datasetID,
+		"completed_at": &now,
+		"updated_at":   now,
+	})
+}
+
+// UpdateGenerationProgress updates the counts of a generation task
+func (r *datasetRepository) UpdateGenerationProgress(ctx context.Context,
+	id string, processedChunks, acceptedCount, rejectedCount int,
+) error {
+	return r.db.WithContext(ctx).
+		Model(&types.DatasetGenerationTask{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"processed_chunks": processedChunks,
+			"accepted_count":   acceptedCount,
+			"rejected_count":   rejectedCount,
+			"updated_at":       time.Now(),
+		}).Error
+}
+
+func (r *datasetRepository) updateRunningGenerationTask(ctx context.Context,
+	id string, updates map[string]interface{},
+) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&types.DatasetGenerationTask{}).
+		Where("id = ? AND status IN ?", id, []types.DatasetGenerationStatus{
+			types.DatasetGenerationStatusPending, types.DatasetGenerationStatusProcessing,
+		}).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEvaluationTaskNotFound  = errors.New("evaluation task not found")
+	ErrEvaluationSweepNotFound = errors.New("evaluation sweep not found")
+)
+
+// evaluationRepository implements the evaluation repository interface
+type evaluationRepository struct {
+	db *gorm.DB
+}
+
+// NewEvaluationRepository creates a new evaluation repository
+func NewEvaluationRepository(db *gorm.DB) interfaces.EvaluationRepository {
+	return &evaluationRepository{db: db}
+}
+
+// CreateTask stores a new evaluation task
+func (r *evaluationRepository) CreateTask(ctx context.Context, task *types.EvaluationTask) error {
+	return r.db.WithContext(ctx).Create(task).Error
+}
+
+// UpdateTask updates an evaluation task
+func (r *evaluationRepository) UpdateTask(ctx context.Context, task *types.EvaluationTask) error {
+	return r.db.WithContext(ctx).Save(task).Error
+}
+
+// GetTask gets an evaluation task with its parameters and metrics
+func (r *evaluationRepository) GetTask(ctx context.Context, tenantID uint, id string) (*types.EvaluationTask, error) {
+	var task types.EvaluationTask
+	if err := r.db.WithContext(ctx).Where("tenant_id = ? AND id = ?", tenantID, id).First(&task).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEvaluationTaskNotFound
+		}
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ListTasks lists the evaluation tasks of a tenant newest first
+func (r *evaluationRepository) ListTasks(ctx context.Context,
+	tenantID uint, pagination *types.Pagination,
+) ([]*types.EvaluationTask, int64, error) {
+	var tasks []*types.EvaluationTask
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&types.EvaluationTask{}).Where("tenant_id = ?", tenantID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("start_time DESC").
+		Limit(pagination.GetPageSize()).
+		Offset(pagination.Offset()).
+		Find(&tasks).Error
+	return tasks, total, err
+}
+
+// FailUnfinishedTasks marks all pending and running evaluation tasks as failed with the error message
+func (r *evaluationRepository) FailUnfinishedTasks(ctx context.Context, errMsg string) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&types.EvaluationTask{}).
+		Where("status IN ?", []types.EvaluationStatue{types.EvaluationStatuePending, types.EvaluationStatueRunning}).
+		Updates(map[string]interface{}{
+			"status":   types.EvaluationStatueFailed,
+			"err_msg":  errMsg,
+			"end_time": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
+
+// CreateQuestion stores the result of a question of an evaluation task
+func (r *evaluationRepository) CreateQuestion(ctx context.Context, question *types.EvaluationQuestion) error {
+	return r.db.WithContext(ctx).Create(question).Error
+}
+
+// ListQuestions lists the question results of an evaluation task by question ID
+func (r *evaluationRepository) ListQuestions(ctx context.Context,
+	tenantID uint, taskID string,
+) ([]*types.EvaluationQuestion, error) {
+	var questions []*types.EvaluationQuestion
+	err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND task_id = ?", tenantID, taskID).
+		Order("question_id").
+		Find(&questions).Error
+	return questions, err
+}
+
+// CreateSweep stores a new evaluation sweep
+func (r *evaluationRepository) CreateSweep(ctx context.Context, sweep *types.EvaluationSweep) error {
+	return r.db.WithContext(ctx).Create(sweep).Error
+}
+
+// UpdateSweep updates an evaluation sweep with its trials
+func (r *evaluationRepository) UpdateSweep(ctx context.Context, sweep *types.EvaluationSweep) error {
+	return r.db.WithContext(ctx).Save(sweep).Error
+}
+
+// FailUnfinishedSweeps marks all pending and running evaluation sweeps as failed with the error message
+func (r *evaluationRepository) FailUnfinishedSweeps(ctx context.Context, errMsg string) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&types.EvaluationSweep{}).
+		Where("status IN ?", []types.EvaluationStatue{types.EvaluationStatuePending, types.EvaluationStatueRunning}).
+		Updates(map[string]interface{}{
+			"status":   types.EvaluationStatueFailed,
+			"err_msg":  errMsg,
+			"end_time": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
+
+// GetSweep gets an evaluation sweep with its trials
+func (r *evaluationRepository) GetSweep(ctx context.Context, tenantID uint, id string) (*types.EvaluationSweep, error) {
+	var sweep types.EvaluationSweep
+	if err := r.db.WithContext(ctx).Where("tenant_id = ? AND id = ?", tenantID, id).First(&sweep).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEvaluationSweepNotFound
+		}
+		return nil, err
+	}
+	return &sweep, nil
+}
+
+// ListSweeps lists the evaluation sweeps of a tenant newest first, without their trials
+func (r *evaluationRepository) ListSweeps(ctx context.Context,
+	tenantID uint, pagination *types.Pagination,
+) ([]*types.EvaluationSweep, int64, error) {
+	var sweeps []*types.EvaluationSweep
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&types.EvaluationSweep{}).Where("tenant_id = ?", tenantID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Omit("trials").
+		Order("start_time DESC").
+		Limit(pagination.GetPageSize()).
+		Offset(pagination.Offset()).
+		Find(&sweeps).Error
+	return sweeps, total, err
+}
@@ -123,3 +123,75 @@ func (r *importTaskRepository) AddResult(ctx context.Context, taskID string, res
 		Where("id = ?", taskID).
 		Update("results", resultsJSON).Error
 }
+
+func (r *importTaskRepository) StartRun(ctx context.Context, taskID string) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).
+		Model(&types.ImportTask{}).
+		Where("id = ?", taskID).
+		Updates(map[string]interface{}{
+			"status":          types.ImportTaskStatusProcessing,
+			"total_urls":      0,
+			"processed_urls":  0,
+			"success_count":   0,
+			"failed_count":    0,
+			"duplicate_count": 0,
+			"unchanged_count": 0,
+			"removed_count":   0,
+			"current_url":     "",
+			"error_message":   "",
+			"results":         types.JSON("[]"),
+			"completed_at":    nil,
+			"last_run_at":     &now,
+			"updated_at":      now,
+		}).Error
+}
+
+func (r *importTaskRepository) UpdateRunCounts(ctx context.Context, taskID string, totalURLs, unchangedCount, removedCount int) error {
+	return r.db.WithContext(ctx).
+		Model(&types.ImportTask{}).
+		Where("id = ?", taskID).
+		Updates(map[string]interface{}{
+			"total_urls":      totalURLs,
+			"unchanged_count": unchangedCount,
+			"removed_count":   removedCount,
+			"updated_at":      time.Now(),
+		}).Error
+}
+
+func (r *importTaskRepository) ListDue(ctx context.Context, now time.Time) ([]*types.ImportTask, error) {
+	var tasks []*types.ImportTask
+	err := r.db.WithContext(ctx).
+		Where("schedule <> '' AND next_run_at <= ? AND status <> ?", now, types.ImportTaskStatusCancelled).
+		Order("next_run_at").
+		Find(&tasks).Error
+	return tasks, err
+}
+
+func (r *importTaskRepository) UpdateNextRun(ctx context.Context, taskID string, nextRunAt *time.Time, next *time.Time) (bool, error) {
+	query := r.db.WithContext(ctx).Model(&types.ImportTask{}).Where("id = ?", taskID)
+	if nextRunAt == nil {
+		query = query.Where("next_run_at IS NULL")
+	} else {
+		query = query.Where("next_run_at = ?", *nextRunAt)
+	}
+	result := query.Updates(map[string]interface{}{
+		"next_run_at": next,
+		"updated_at":  time.Now(),
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *importTaskRepository) ListPages(ctx context.Context, taskID string) ([]*types.ImportTaskPage, error) {
+	var pages []*types.ImportTaskPage
+	err := r.db.WithContext(ctx).Where("task_id = ?", taskID).Find(&pages).Error
+	return pages, err
+}
+
+func (r *importTaskRepository) SavePage(ctx context.Context, page *types.ImportTaskPage) error {
+	return r.db.WithContext(ctx).Save(page).Error
+}
+
+func (r *importTaskRepository) DeletePage(ctx context.Context, pageID string) error {
+	return r.db.WithContext(ctx).Where("id = ?", pageID).Delete(&types.ImportTaskPage{}).Error
+}
@@ -202,3 +202,43 @@ func (r *knowledgeRepository) UpdateKnowledgeColumn(ctx context.Context, id stri
 	err := r.db.WithContext(ctx).Model(&types.Knowledge{}).Where("id = ?", id).Update(column, value).Error
 	return err
 }
+
+// ListKnowledgeByParseStatus lists the knowledge of all tenants with one of the parse statuses
+func (r *knowledgeRepository) ListKnowledgeByParseStatus(
+	ctx context.Context, statuses ...string,
+) ([]*types.Knowledge, error) {
+	var knowledges []*types.Knowledge
+	if err := r.db.WithContext(ctx).Where("parse_status IN ?", statuses).
+		Order("created_at ASC").Find(&knowledges).Error; err != nil {
+		return nil, err
+	}
+	return knowledges, nil
+}
+
+// ListKnowledgeFingerprints lists the fingerprinted knowledge of a knowledge base, earliest created first.
+// Only the columns describing the knowledge and its fingerprint are loaded.
+func (r *knowledgeRepository) ListKnowledgeFingerprints(
+	ctx context.Context, tenantID uint, kbID string,
+) ([]*types.Knowledge, error) {
+	var knowledges []*types.Knowledge
+	if err := r.db.WithContext(ctx).
+		Select("id", "tenant_id", "knowledge_base_id", "type", "title", "source", "file_name",
+			"parse_status", "min_hash", "duplicate_of", "created_at").
+		Where("tenant_id = ? AND knowledge_base_id = ? AND min_hash IS NOT NULL", tenantID, kbID).
+		Order("created_at ASC").Find(&knowledges).Error; err != nil {
+		return nil, err
+	}
+	return knowledges, nil
+}
+
+// ListKnowledgeByDuplicateOf lists the knowledge found to nearly duplicate one of the knowledge entries
+func (r *knowledgeRepository) ListKnowledgeByDuplicateOf(
+	ctx context.Context, tenantID uint, ids []string,
+) ([]*types.Knowledge, error) {
+	var knowledges []*types.Knowledge
+	if err := r.db.WithContext(ctx).Where("tenant_id = ? AND duplicate_of IN ?", tenantID, ids).
+		Find(&knowledges).Error; err != nil {
+		return nil, err
+	}
+	return knowledges, nil
+}
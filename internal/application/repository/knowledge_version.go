@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"gorm.io/gorm"
+)
+
+var ErrKnowledgeVersionNotFound = errors.New("knowledge version not found")
+
+// knowledgeVersionRepository implements the knowledge version repository interface
+type knowledgeVersionRepository struct {
+	db *gorm.DB
+}
+
+// NewKnowledgeVersionRepository creates a new knowledge version repository
+func NewKnowledgeVersionRepository(db *gorm.DB) interfaces.KnowledgeVersionRepository {
+	return &knowledgeVersionRepository{db: db}
+}
+
+// CreateVersion stores an earlier content version
+func (r *knowledgeVersionRepository) CreateVersion(ctx context.Context, version *types.KnowledgeVersion) error {
+	return r.db.WithContext(ctx).Create(version).Error
+}
+
+// GetVersion gets a version with its chunks
+func (r *knowledgeVersionRepository) GetVersion(ctx context.Context,
+	tenantID uint, knowledgeID string, version int,
+) (*types.KnowledgeVersion, error) {
+	var knowledgeVersion types.KnowledgeVersion
+	if err := r.db.WithContext(ctx).Where(
+		"tenant_id = ? AND knowledge_id = ? AND version = ?", tenantID, knowledgeID, version,
+	).First(&knowledgeVersion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrKnowledgeVersionNotFound
+		}
+		return nil, err
+	}
+	return &knowledgeVersion, nil
+}
+
+// ListVersions lists the versions of a knowledge entry without their chunks, newest first
+func (r *knowledgeVersionRepository) ListVersions(ctx context.Context,
+	tenantID uint, knowledgeID string,
+) ([]*types.KnowledgeVersion, error) {
+	var versions []*types.KnowledgeVersion
+	err := r.db.WithContext(ctx).Omit("chunks").
+		Where("tenant_id = ? AND knowledge_id = ?", tenantID, knowledgeID).
+		Order("version DESC").
+		Find(&versions).Error
+	return versions, err
+}
+
+// GetMaxVersion returns the highest version number stored for a knowledge entry, 0 if there is none
+func (r *knowledgeVersionRepository) GetMaxVersion(ctx context.Context, tenantID uint, knowledgeID string) (int, error) {
+	var version int
+	err := r.db.WithContext(ctx).Model(&types.KnowledgeVersion{}).
+		Where("tenant_id = ? AND knowledge_id = ?", tenantID, knowledgeID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&version).Error
+	return version, err
+}
+
+// DeleteVersion deletes a version
+func (r *knowledgeVersionRepository) DeleteVersion(ctx context.Context,
+	tenantID uint, knowledgeID string, version int,
+) error {
+	return r.db.WithContext(ctx).Where(
+		"tenant_id = ? AND knowledge_id = ? AND version = ?", tenantID, knowledgeID, version,
+	).Delete(&types.KnowledgeVersion{}).Error
+}
+
+// DeleteVersions deletes versions of a knowledge entry by their numbers
+func (r *knowledgeVersionRepository) DeleteVersions(ctx context.Context,
+	tenantID uint, knowledgeID string, versions []int,
+) error {
+	if len(versions) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Where(
+		"tenant_id = ? AND knowledge_id = ? AND version IN ?", tenantID, knowledgeID, versions,
+	).Delete(&types.KnowledgeVersion{}).Error
+}
+
+// DeleteByKnowledgeList deletes the versions of knowledge entries
+func (r *knowledgeVersionRepository) DeleteByKnowledgeList(ctx context.Context,
+	tenantID uint, knowledgeIDs []string,
+) error {
+	return r.db.WithContext(ctx).Where(
+		"tenant_id = ? AND knowledge_id IN ?", tenantID, knowledgeIDs,
+	).Delete(&types.KnowledgeVersion{}).Error
+}
@@ -0,0 +1,24 @@
+package elasticsearch
+
+// IndexMapping maps the fields of index documents that retrieval filters on.
+// Metadata strings, including those that look like dates, become keywords matching exact values,
+// and metadata numbers become doubles. Access labels are keywords.
+// It is applied when the repository initializes the index.
+const IndexMapping = `{
+	"dynamic_templates": [
+		{"metadata_strings": {"path_match": "metadata.*", "match_mapping_type": "string", "mapping": {"type": "keyword"}}},
+		{"metadata_dates": {"path_match": "metadata.*", "match_mapping_type": "date", "mapping": {"type": "keyword"}}},
+		{"metadata_numbers": {"path_match": "metadata.*", "match_mapping_type": "long", "mapping": {"type": "double"}}}
+	],
+	"properties": {
+		"access_labels": {"type": "keyword"}
+	}
+}`
+
+// AccessLabelsField is the document field holding the access labels of the knowledge
+const AccessLabelsField = "access_labels"
+
+// MetadataField returns the document field of a metadata field
+func MetadataField(field string) string {
+	return "metadata." + field
+}
@@ -44,9 +44,49 @@ func NewElasticsearchEngineRepository(client *elasticsearch.Client,
 
 	log.Infof("[ElasticsearchV7] Using index: %s", indexName)
 	res := &elasticsearchRepository{client: client, index: indexName}
+	if err := res.ensureIndexMapping(context.Background()); err != nil {
+		log.Errorf("[ElasticsearchV7] Failed to map filtered fields: %v", err)
+	}
 	return res
 }
 
+// ensureIndexMapping maps the metadata and access label fields of the index for filtering,
+// the index is created with the mapping if it does not exist yet
+func (e *elasticsearchRepository) ensureIndexMapping(ctx context.Context) error {
+	exists, err := e.client.Indices.Exists([]string{e.index}, e.client.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer exists.Body.Close()
+
+	var response *esapi.Response
+	switch exists.StatusCode {
+	case 200:
+		response, err = e.client.Indices.PutMapping(
+			strings.NewReader(elasticsearchRetriever.IndexMapping),
+			e.client.Indices.PutMapping.WithIndex(e.index),
+			e.client.Indices.PutMapping.WithContext(ctx),
+		)
+	case 404:
+		response, err = e.client.Indices.Create(e.index,
+			e.client.Indices.Create.WithBody(strings.NewReader(
+				fmt.Sprintf(`{"mappings": %s}`, elasticsearchRetriever.IndexMapping),
+			)),
+			e.client.Indices.Create.WithContext(ctx),
+		)
+	default:
+		return fmt.Errorf("failed to check index: %s", exists.String())
+	}
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.IsError() {
+		return fmt.Errorf("failed to map filtered fields: %s", response.String())
+	}
+	return nil
+}
+
 func (e *elasticsearchRepository) EngineType() typesLocal.RetrieverEngineType {
 	return typesLocal.ElasticsearchRetrieverEngineType
 }
@@ -330,7 +370,7 @@ func (e *elasticsearchRepository) deleteByFieldList(ctx context.Context, field s
 	if resp.IsError() {
 		errMsg := fmt.Sprintf("failed to delete by query: %s", resp.String())
 		log.Errorf("[ElasticsearchV7] %s", errMsg)
-		return fmt.Errorf(errMsg)
+		return errors.New(errMsg)
 	}
 
 	// Try to extract deletion count from response
@@ -358,6 +398,9 @@ func (e *elasticsearchRepository) getBaseConds(params typesLocal.RetrieveParams)
 		ids, _ := json.Marshal(params.KnowledgeBaseIDs)
 		must = append(must, fmt.Sprintf(`{"terms": {"knowledge_base_id.keyword": %s}}`, ids))
 	}
+	for _, filter := range params.Filters {
+		must = append(must, metadataFilterCond(filter))
+	}
 
 	// Build MUST_NOT conditions (negative filters)
 	mustNot := make([]string, 0)
@@ -384,6 +427,41 @@ func (e *elasticsearchRepository) getBaseConds(params typesLocal.RetrieveParams)
 	}
 }
 
+// accessLabelCond restricts retrieval to documents without access labels or sharing a label with the audience
+func accessLabelCond(audience []string) string {
+	should := []map[string]any{{"bool": map[string]any{"must_not": map[string]any{
+		"exists": map[string]any{"field": elasticsearchRetriever.AccessLabelsField},
+	}}}}
+	if len(audience) > 0 {
+		should = append(should, map[string]any{"terms": map[string]any{
+			elasticsearchRetriever.AccessLabelsField: audience,
+		}})
+	}
+	cond, _ := json.Marshal(map[string]any{"bool": map[string]any{"should": should, "minimum_should_match": 1}})
+	return string(cond)
+}
+
+// metadataFilterCond builds the query of a metadata filter on the metadata fields
+func metadataFilterCond(filter *typesLocal.MetadataFilter) string {
+	field := elasticsearchRetriever.MetadataField(filter.Field)
+	var query map[string]any
+	switch filter.Op {
+	case typesLocal.MetadataFilterOpEq:
+		query = map[string]any{"term": map[string]any{field: filter.Value}}
+	case typesLocal.MetadataFilterOpIn:
+		query = map[string]any{"terms": map[string]any{field: filter.Values}}
+	case typesLocal.MetadataFilterOpRange:
+		query = map[string]any{"range": map[string]any{field: filter.Range.Bounds()}}
+	case typesLocal.MetadataFilterOpExists:
+		query = map[string]any{"exists": map[string]any{"field": field}}
+	default:
+		// Filters are validated before retrieval, an unknown operator matches nothing
+		return `{"match_none": {}}`
+	}
+	cond, _ := json.Marshal(query)
+	return string(cond)
+}
+
 func (e *elasticsearchRepository) Retrieve(ctx context.Context,
 	params typesLocal.RetrieveParams,
 ) ([]*typesLocal.RetrieveResult, error) {
@@ -447,10 +525,11 @@ func (e *elasticsearchRepository) buildVectorSearchQuery(ctx context.Context,
 
 	// Construct the script_score query
 	query := fmt.Sprintf(
-		`{"query":{"script_score":{"query":{"bool":{"filter":[%s]}},
+		`{"query":{"script_score":{"query":{"bool":{"filter":[%s,%s]}},
 			"script":{"source":"cosineSimilarity(params.query_vector,'embedding')",
 			"params":{"query_vector":%s}},"min_score":%f}},"size":%d}`,
 		filter,
+		accessLabelCond(params.Audience),
 		string(queryVectorJSON),
 		params.Threshold,
 		params.TopK,
@@ -528,8 +607,8 @@ func (e *elasticsearchRepository) buildKeywordSearchQuery(ctx context.Context,
 
 	filter := e.getBaseConds(params)
 	query := fmt.Sprintf(
-		`{"query": {"bool": {"must": [{"match": {"content": %s}}], "filter": [%s]}}}`,
-		string(content), filter,
+		`{"query": {"bool": {"must": [{"match": {"content": %s}}], "filter": [%s, %s]}}}`,
+		string(content), filter, accessLabelCond(params.Audience),
 	)
 
 	log.Debugf("[ElasticsearchV7] Executing keyword search with query: %s", query)
@@ -984,6 +1063,17 @@ func (e *elasticsearchRepository) processSingleHit(ctx context.Context,
 			len(embedding), targetChunkID)
 	}
 
+	// Extract metadata and access labels (if exist)
+	metadata, _ := sourceObj["metadata"].(map[string]interface{})
+	var accessLabels typesLocal.AccessLabels
+	if labels, ok := sourceObj[elasticsearchRetriever.AccessLabelsField].([]interface{}); ok {
+		for _, label := range labels {
+			if label, ok := label.(string); ok {
+				accessLabels = append(accessLabels, label)
+			}
+		}
+	}
+
 	// Create IndexInfo object
 	indexInfo := &typesLocal.IndexInfo{
 		ChunkID:         targetChunkID,
@@ -992,6 +1082,8 @@ func (e *elasticsearchRepository) processSingleHit(ctx context.Context,
 		KnowledgeBaseID: targetKnowledgeBaseID,
 		Content:         content,
 		SourceType:      typesLocal.SourceType(sourceType),
+		Metadata:        metadata,
+		AccessLabels:    accessLabels,
 	}
 
 	return indexInfo, embedding, nil
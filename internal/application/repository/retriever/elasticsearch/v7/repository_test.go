@@ -0,0 +1,150 @@
+package v7
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	typesLocal "github.com/Tencent/WeKnora/internal/types"
+	"github.com/elastic/go-elasticsearch/v7"
+)
+
+// assertJSONEqual compares two JSON documents regardless of key order and formatting
+func assertJSONEqual(t *testing.T, got, expected string) {
+	t.Helper()
+	var gotValue, expectedValue any
+	if err := json.Unmarshal([]byte(got), &gotValue); err != nil {
+		t.Fatalf("failed to decode %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(expected), &expectedValue); err != nil {
+		t.Fatalf("failed to decode expected %s: %v", expected, err)
+	}
+	if !reflect.DeepEqual(gotValue, expectedValue) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestAccessLabelCond(t *testing.T) {
+	tests := []struct {
+		name     string
+		audience []string
+		expected string
+	}{
+		{
+			name:     "no audience only matches public documents",
+			expected: `{"bool": {"minimum_should_match": 1, "should": [{"bool": {"must_not": {"exists": {"field": "access_labels"}}}}]}}`,
+		},
+		{
+			name:     "audience matches documents sharing a label",
+			audience: []string{"hr", "legal"},
+			expected: `{"bool": {"minimum_should_match": 1, "should": [` +
+				`{"bool": {"must_not": {"exists": {"field": "access_labels"}}}},` +
+				`{"terms": {"access_labels": ["hr", "legal"]}}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertJSONEqual(t, accessLabelCond(tt.audience), tt.expected)
+		})
+	}
+}
+
+func TestMetadataFilterCond(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   *typesLocal.MetadataFilter
+		expected string
+	}{
+		{
+			name:     "eq",
+			filter:   &typesLocal.MetadataFilter{Field: "category", Op: typesLocal.MetadataFilterOpEq, Value: "hr"},
+			expected: `{"term": {"metadata.category": "hr"}}`,
+		},
+		{
+			name: "in",
+			filter: &typesLocal.MetadataFilter{
+				Field: "category", Op: typesLocal.MetadataFilterOpIn, Values: []any{"hr", "legal"},
+			},
+			expected: `{"terms": {"metadata.category": ["hr", "legal"]}}`,
+		},
+		{
+			name: "range",
+			filter: &typesLocal.MetadataFilter{
+				Field: "year", Op: typesLocal.MetadataFilterOpRange,
+				Range: &typesLocal.MetadataRange{Gte: 2020, Lt: 2024},
+			},
+			expected: `{"range": {"metadata.year": {"gte": 2020, "lt": 2024}}}`,
+		},
+		{
+			name:     "exists",
+			filter:   &typesLocal.MetadataFilter{Field: "author", Op: typesLocal.MetadataFilterOpExists},
+			expected: `{"exists": {"field": "metadata.author"}}`,
+		},
+		{
+			name:     "unknown operator matches nothing",
+			filter:   &typesLocal.MetadataFilter{Field: "author", Op: "like"},
+			expected: `{"match_none": {}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertJSONEqual(t, metadataFilterCond(tt.filter), tt.expected)
+		})
+	}
+}
+
+func TestRetrieveAppliesFilters(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"took": 1, "timed_out": false, "hits": {"hits": []}}`))
+	}))
+	defer server.Close()
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{server.URL}})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	repo := &elasticsearchRepository{client: client, index: "test"}
+
+	params := typesLocal.RetrieveParams{
+		Query:            "leave policy",
+		Embedding:        []float32{0.1, 0.2},
+		KnowledgeBaseIDs: []string{"kb"},
+		TopK:             5,
+		Audience:         []string{"hr"},
+		Filters: typesLocal.MetadataFilters{
+			{Field: "category", Op: typesLocal.MetadataFilterOpEq, Value: "policy"},
+		},
+	}
+	retrievers := map[typesLocal.RetrieverType]func(context.Context, typesLocal.RetrieveParams) (
+		[]*typesLocal.RetrieveResult, error,
+	){
+		typesLocal.KeywordsRetrieverType: repo.KeywordsRetrieve,
+		typesLocal.VectorRetrieverType:   repo.VectorRetrieve,
+	}
+	for retrieverType, retrieve := range retrievers {
+		body = ""
+		if _, err := retrieve(context.Background(), params); err != nil {
+			t.Fatalf("%s retrieval failed: %v", retrieverType, err)
+		}
+		if !json.Valid([]byte(body)) {
+			t.Errorf("%s retrieval: request is not valid JSON\n%s", retrieverType, body)
+		}
+		for _, cond := range []string{accessLabelCond(params.Audience), metadataFilterCond(params.Filters[0])} {
+			if !strings.Contains(body, cond) {
+				t.Errorf("%s retrieval: expected condition %s in request\n%s", retrieverType, cond, body)
+			}
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
 	elasticsearchRetriever "github.com/Tencent/WeKnora/internal/application/repository/retriever/elasticsearch"
 	"github.com/Tencent/WeKnora/internal/config"
@@ -210,7 +211,7 @@ func (e *elasticsearchRepository) DeleteByKnowledgeIDList(ctx context.Context,
 
 // getBaseConds creates the base query conditions for retrieval operations
 // Returns a slice of Query objects with must and must_not conditions
-func (e *elasticsearchRepository) getBaseConds(params typesLocal.RetrieveParams) []types.Query {
+func (e *elasticsearchRepository) getBaseConds(params typesLocal.RetrieveParams) ([]types.Query, error) {
 	must := []types.Query{}
 	if len(params.KnowledgeBaseIDs) > 0 {
 		must = append(must, types.Query{Terms: &types.TermsQuery{
@@ -219,6 +220,13 @@ func (e *elasticsearchRepository) getBaseConds(params typesLocal.RetrieveParams)
 			},
 		}})
 	}
+	for _, filter := range params.Filters {
+		query, err := metadataFilterQuery(filter)
+		if err != nil {
+			return nil, err
+		}
+		must = append(must, query)
+	}
 	mustNot := make([]types.Query, 0)
 	if len(params.ExcludeKnowledgeIDs) > 0 {
 		mustNot = append(mustNot, types.Query{Terms: &types.TermsQuery{
@@ -230,7 +238,49 @@ func (e *elasticsearchRepository) getBaseConds(params typesLocal.RetrieveParams)
 			TermsQuery: map[string]types.TermsQueryField{"chunk_id.keyword": params.ExcludeChunkIDs},
 		}})
 	}
-	return []types.Query{{Bool: &types.BoolQuery{Must: must, MustNot: mustNot}}}
+	return []types.Query{{Bool: &types.BoolQuery{Must: must, MustNot: mustNot}}}, nil
+}
+
+// metadataFilterQuery translates a metadata filter into a query on the metadata fields
+func metadataFilterQuery(filter *typesLocal.MetadataFilter) (types.Query, error) {
+	field := elasticsearchRetriever.MetadataField(filter.Field)
+	switch filter.Op {
+	case typesLocal.MetadataFilterOpEq:
+		return types.Query{Term: map[string]types.TermQuery{field: {Value: filter.Value}}}, nil
+	case typesLocal.MetadataFilterOpIn:
+		return types.Query{Terms: &types.TermsQuery{
+			TermsQuery: map[string]types.TermsQueryField{field: filter.Values},
+		}}, nil
+	case typesLocal.MetadataFilterOpRange:
+		var query types.UntypedRangeQuery
+		bounds := map[string]*json.RawMessage{
+			"gt": &query.Gt, "gte": &query.Gte, "lt": &query.Lt, "lte": &query.Lte,
+		}
+		for name, value := range filter.Range.Bounds() {
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return types.Query{}, err
+			}
+			*bounds[name] = raw
+		}
+		return types.Query{Range: map[string]types.RangeQuery{field: query}}, nil
+	case typesLocal.MetadataFilterOpExists:
+		return types.Query{Exists: &types.ExistsQuery{Field: field}}, nil
+	}
+	return types.Query{}, fmt.Errorf("unsupported metadata filter operator: %s", filter.Op)
+}
+
+// accessLabelQuery restricts retrieval to documents without access labels or sharing a label with the audience
+func accessLabelQuery(audience []string) types.Query {
+	should := []types.Query{{Bool: &types.BoolQuery{MustNot: []types.Query{
+		{Exists: &types.ExistsQuery{Field: elasticsearchRetriever.AccessLabelsField}},
+	}}}}
+	if len(audience) > 0 {
+		should = append(should, types.Query{Terms: &types.TermsQuery{
+			TermsQuery: map[string]types.TermsQueryField{elasticsearchRetriever.AccessLabelsField: audience},
+		}})
+	}
+	return types.Query{Bool: &types.BoolQuery{Should: should, MinimumShouldMatch: 1}}
 }
 
 // createIndexIfNotExists checks if the specified index exists and creates it if not
@@ -248,18 +298,24 @@ func (e *elasticsearchRepository) createIndexIfNotExists(ctx context.Context) er
 
 	if exists {
 		log.Debugf("[Elasticsearch] Index already exists: %s", e.index)
-		return nil
+	} else {
+		// Create index if it doesn't exist
+		log.Infof("[Elasticsearch] Creating index: %s", e.index)
+		_, err = e.client.Indices.Create(e.index).Do(ctx)
+		if err != nil {
+			log.Errorf("[Elasticsearch] Failed to create index: %v", err)
+			return err
+		}
+		log.Infof("[Elasticsearch] Index created successfully: %s", e.index)
 	}
 
-	// Create index if it doesn't exist
-	log.Infof("[Elasticsearch] Creating index: %s", e.index)
-	_, err = e.client.Indices.Create(e.index).Do(ctx)
+	// Map the filtered fields, indices created before metadata and access labels were indexed get the mapping too
+	_, err = e.client.Indices.PutMapping(e.index).
+		Raw(strings.NewReader(elasticsearchRetriever.IndexMapping)).Do(ctx)
 	if err != nil {
-		log.Errorf("[Elasticsearch] Failed to create index: %v", err)
+		log.Errorf("[Elasticsearch] Failed to put index mapping: %v", err)
 		return err
 	}
-
-	log.Infof("[Elasticsearch] Index created successfully: %s", e.index)
 	return nil
 }
 
@@ -293,7 +349,12 @@ func (e *elasticsearchRepository) VectorRetrieve(ctx context.Context,
 	log.Infof("[Elasticsearch] Vector retrieval: dim=%d, topK=%d, threshold=%.4f",
 		len(params.Embedding), params.TopK, params.Threshold)
 
-	filter := e.getBaseConds(params)
+	filter, err := e.getBaseConds(params)
+	if err != nil {
+		log.Errorf("[Elasticsearch] Invalid metadata filters: %v", err)
+		return nil, err
+	}
+	filter = append(filter, accessLabelQuery(params.Audience))
 
 	// Build script scoring query with cosine similarity
 	queryVectorJSON, err := json.Marshal(params.Embedding)
@@ -364,7 +425,12 @@ func (e *elasticsearchRepository) KeywordsRetrieve(ctx context.Context,
 	log := logger.GetLogger(ctx)
 	log.Infof("[Elasticsearch] Performing keywords retrieval with query: %s, topK: %d", params.Query, params.TopK)
 
-	filter := e.getBaseConds(params)
+	filter, err := e.getBaseConds(params)
+	if err != nil {
+		log.Errorf("[Elasticsearch] Invalid metadata filters: %v", err)
+		return nil, err
+	}
+	filter = append(filter, accessLabelQuery(params.Audience))
 	// Build must conditions for content matching
 	must := []types.Query{
 		{Match: map[string]types.MatchQuery{"content": {Query: params.Query}}},
@@ -436,7 +502,10 @@ func (e *elasticsearchRepository) CopyIndices(ctx context.Context,
 	}
 
 	// Build base query conditions
-	filter := e.getBaseConds(params)
+	filter, err := e.getBaseConds(params)
+	if err != nil {
+		return err
+	}
 
 	// Set batch processing parameters
 	batchSize := 500
@@ -505,6 +574,8 @@ func (e *elasticsearchRepository) CopyIndices(ctx context.Context,
 				ChunkID:         targetChunkID,
 				KnowledgeID:     targetKnowledgeID,
 				KnowledgeBaseID: targetKnowledgeBaseID,
+				Metadata:        sourceDoc.Metadata,
+				AccessLabels:    sourceDoc.AccessLabels,
 			}
 
 			indexInfoList = append(indexInfoList, indexInfo)
@@ -0,0 +1,96 @@
+package v8
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	typesLocal "github.com/Tencent/WeKnora/internal/types"
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+func TestAccessLabelQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		audience []string
+		expected string
+	}{
+		{
+			name:     "no audience only matches public documents",
+			expected: `{"bool": {"minimum_should_match": 1, "should": [{"bool": {"must_not": [{"exists": {"field": "access_labels"}}]}}]}}`,
+		},
+		{
+			name:     "audience matches documents sharing a label",
+			audience: []string{"hr", "legal"},
+			expected: `{"bool": {"minimum_should_match": 1, "should": [` +
+				`{"bool": {"must_not": [{"exists": {"field": "access_labels"}}]}},` +
+				`{"terms": {"access_labels": ["hr", "legal"]}}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(accessLabelQuery(tt.audience))
+			if err != nil {
+				t.Fatalf("failed to marshal query: %v", err)
+			}
+			var got, expected any
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("failed to decode query: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.expected), &expected); err != nil {
+				t.Fatalf("failed to decode expected query: %v", err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected %s, got %s", tt.expected, data)
+			}
+		})
+	}
+}
+
+func TestRetrieveAppliesAccessLabels(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"took": 1, "timed_out": false, "hits": {"hits": []}}`))
+	}))
+	defer server.Close()
+
+	client, err := elasticsearch.NewTypedClient(elasticsearch.Config{Addresses: []string{server.URL}})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	repo := &elasticsearchRepository{client: client, index: "test"}
+
+	audience := []string{"hr"}
+	accessQuery, err := json.Marshal(accessLabelQuery(audience))
+	if err != nil {
+		t.Fatalf("failed to marshal query: %v", err)
+	}
+	for _, retrieverType := range []typesLocal.RetrieverType{
+		typesLocal.KeywordsRetrieverType, typesLocal.VectorRetrieverType,
+	} {
+		_, err := repo.Retrieve(context.Background(), typesLocal.RetrieveParams{
+			Query:            "leave policy",
+			Embedding:        []float32{0.1, 0.2},
+			KnowledgeBaseIDs: []string{"kb"},
+			TopK:             5,
+			Audience:         audience,
+			RetrieverType:    retrieverType,
+		})
+		if err != nil {
+			t.Fatalf("%s retrieval failed: %v", retrieverType, err)
+		}
+		if !strings.Contains(body, string(accessQuery)) {
+			t.Errorf("%s retrieval: expected access filter %s in request\n%s", retrieverType, accessQuery, body)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"gorm.io/gorm/clause"
+)
+
+// rangeOperators maps the bounds of a range filter to SQL operators, in the order they are applied
+var rangeOperators = []struct {
+	bound    string
+	operator string
+}{
+	{"gt", ">"}, {"gte", ">="}, {"lt", "<"}, {"lte", "<="},
+}
+
+// metadataFilterConds translates metadata filters into conditions on the metadata column
+func metadataFilterConds(filters types.MetadataFilters) ([]clause.Expression, error) {
+	conds := make([]clause.Expression, 0, len(filters))
+	for _, filter := range filters {
+		cond, err := metadataFilterCond(filter)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	return conds, nil
+}
+
+// metadataFilterCond translates a single metadata filter
+func metadataFilterCond(filter *types.MetadataFilter) (clause.Expression, error) {
+	switch filter.Op {
+	case types.MetadataFilterOpEq:
+		return metadataEqCond(filter.Field, filter.Value)
+	case types.MetadataFilterOpIn:
+		conds := make([]clause.Expr, 0, len(filter.Values))
+		for _, value := range filter.Values {
+			cond, err := metadataEqCond(filter.Field, value)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, cond)
+		}
+		return orExpr(conds), nil
+	case types.MetadataFilterOpRange:
+		return metadataRangeCond(filter.Field, filter.Range), nil
+	case types.MetadataFilterOpExists:
+		// jsonb_exists is the ? operator, which cannot be written next to query placeholders
+		return clause.Expr{SQL: "jsonb_exists(metadata, ?)", Vars: []interface{}{filter.Field}}, nil
+	}
+	return nil, fmt.Errorf("unsupported metadata filter operator: %s", filter.Op)
+}
+
+// metadataEqCond matches a field equal to the value or a list field containing it, both served by the GIN index
+func metadataEqCond(field string, value any) (clause.Expr, error) {
+	scalar, err := json.Marshal(map[string]any{field: value})
+	if err != nil {
+		return clause.Expr{}, err
+	}
+	list, err := json.Marshal(map[string]any{field: []any{value}})
+	if err != nil {
+		return clause.Expr{}, err
+	}
+	return clause.Expr{
+		SQL:  "(metadata @> ?::jsonb OR metadata @> ?::jsonb)",
+		Vars: []interface{}{string(scalar), string(list)},
+	}, nil
+}
+
+// metadataRangeCond compares a field with the bounds, values of another JSON type never match.
+// Strings are compared byte-wise, like Elasticsearch compares keywords.
+func metadataRangeCond(field string, bounds *types.MetadataRange) clause.Expression {
+	value := "CASE WHEN jsonb_typeof(metadata -> ?) = 'string' THEN metadata ->> ? END COLLATE \"C\""
+	if bounds.Numeric() {
+		value = "CASE WHEN jsonb_typeof(metadata -> ?) = 'number' THEN (metadata ->> ?)::numeric END"
+	}
+	set := bounds.Bounds()
+	conds := make([]clause.Expression, 0, len(set))
+	for _, op := range rangeOperators {
+		bound, ok := set[op.bound]
+		if !ok {
+			continue
+		}
+		conds = append(conds, clause.Expr{
+			SQL:  fmt.Sprintf("%s %s ?", value, op.operator),
+			Vars: []interface{}{field, field, bound},
+		})
+	}
+	return clause.And(conds...)
+}
+
+// accessLabelCond restricts retrieval to entries without access labels or sharing a label with the audience
+func accessLabelCond(audience []string) (clause.Expr, error) {
+	conds := []clause.Expr{{SQL: "access_labels IS NULL"}}
+	for _, label := range audience {
+		labels, err := json.Marshal([]string{label})
+		if err != nil {
+			return clause.Expr{}, err
+		}
+		conds = append(conds, clause.Expr{SQL: "access_labels @> ?::jsonb", Vars: []interface{}{string(labels)}})
+	}
+	return orExpr(conds), nil
+}
+
+// orExpr joins the conditions with OR into a single parenthesized expression.
+// A clause.Or with one condition would be joined to the other WHERE conditions with OR instead of AND.
+func orExpr(conds []clause.Expr) clause.Expr {
+	sqls := make([]string, 0, len(conds))
+	var vars []interface{}
+	for _, cond := range conds {
+		sqls = append(sqls, cond.SQL)
+		vars = append(vars, cond.Vars...)
+	}
+	return clause.Expr{SQL: "(" + strings.Join(sqls, " OR ") + ")", Vars: vars}
+}
@@ -159,12 +159,24 @@ func (g *pgRepository) KeywordsRetrieve(ctx context.Context,
 		SQL:  "id @@@ paradedb.match(field => 'content', value => ?, distance => 1)",
 		Vars: []interface{}{params.Query},
 	})
+	filterConds, err := metadataFilterConds(params.Filters)
+	if err != nil {
+		logger.GetLogger(ctx).Errorf("[Postgres] Invalid metadata filters: %v", err)
+		return nil, err
+	}
+	conds = append(conds, filterConds...)
+	accessCond, err := accessLabelCond(params.Audience)
+	if err != nil {
+		logger.GetLogger(ctx).Errorf("[Postgres] Invalid audience: %v", err)
+		return nil, err
+	}
+	conds = append(conds, accessCond)
 	conds = append(conds, clause.OrderBy{Columns: []clause.OrderByColumn{
 		{Column: clause.Column{Name: "score"}, Desc: true},
 	}})
 
 	var embeddingDBList []pgVectorWithScore
-	err := g.db.WithContext(ctx).Clauses(conds...).Debug().
+	err = g.db.WithContext(ctx).Clauses(conds...).Debug().
 		Select([]string{
 			"paradedb.score(id) as score",
 			"id",
@@ -227,6 +239,18 @@ func (g *pgRepository) VectorRetrieve(ctx context.Context,
 	// <#> Inner product operator
 	dimension := len(params.Embedding)
 	conds = append(conds, clause.Expr{SQL: "dimension = ?", Vars: []interface{}{dimension}})
+	filterConds, err := metadataFilterConds(params.Filters)
+	if err != nil {
+		logger.GetLogger(ctx).Errorf("[Postgres] Invalid metadata filters: %v", err)
+		return nil, err
+	}
+	conds = append(conds, filterConds...)
+	accessCond, err := accessLabelCond(params.Audience)
+	if err != nil {
+		logger.GetLogger(ctx).Errorf("[Postgres] Invalid audience: %v", err)
+		return nil, err
+	}
+	conds = append(conds, accessCond)
 	conds = append(conds, clause.Expr{
 		SQL:  fmt.Sprintf("embedding::halfvec(%d) <=> ?::halfvec < ?", dimension),
 		Vars: []interface{}{pgvector.NewHalfVector(params.Embedding), 1 - params.Threshold},
@@ -238,7 +262,7 @@ func (g *pgRepository) VectorRetrieve(ctx context.Context,
 
 	var embeddingDBList []pgVectorWithScore
 
-	err := g.db.WithContext(ctx).Clauses(conds...).
+	err = g.db.WithContext(ctx).Clauses(conds...).
 		Select(fmt.Sprintf(
 			"id, content, source_id, source_type, chunk_id, knowledge_id, knowledge_base_id, "+
 				"(1 - (embedding::halfvec(%d) <=> ?::halfvec)) as score",
@@ -355,6 +379,8 @@ func (g *pgRepository) CopyIndices(ctx context.Context,
 				KnowledgeBaseID: targetKnowledgeBaseID, // Update to target knowledge base ID
 				Dimension:       sourceVector.Dimension,
 				Embedding:       sourceVector.Embedding, // Copy the vector embedding directly, avoid recalculation
+				Metadata:        sourceVector.Metadata,
+				AccessLabels:    sourceVector.AccessLabels,
 			}
 
 			targetVectors = append(targetVectors, targetVector)
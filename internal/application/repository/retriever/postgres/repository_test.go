@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newDryRunRepository returns a repository that only builds SQL, the last query is written to sql
+func newDryRunRepository(t *testing.T, sql *string) *pgRepository {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to open dry run database: %v", err)
+	}
+	if err := db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		*sql = tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...)
+	}); err != nil {
+		t.Fatalf("failed to register callback: %v", err)
+	}
+	return &pgRepository{db: db}
+}
+
+func TestRetrieveAccessLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		audience []string
+		filters  types.MetadataFilters
+		expected string
+	}{
+		{
+			name:     "no audience only retrieves public entries",
+			expected: "AND (access_labels IS NULL)",
+		},
+		{
+			name:     "audience retrieves entries sharing a label",
+			audience: []string{"hr", "legal"},
+			expected: `AND ((access_labels IS NULL OR access_labels @> '["hr"]'::jsonb OR access_labels @> '["legal"]'::jsonb))`,
+		},
+		{
+			name:     "single value in filter stays ANDed",
+			audience: []string{"hr"},
+			filters: types.MetadataFilters{
+				{Field: "category", Op: types.MetadataFilterOpIn, Values: []any{"policy"}},
+			},
+			expected: `AND (((metadata @> '{"category":"policy"}'::jsonb OR metadata @> '{"category":["policy"]}'::jsonb)))` +
+				` AND ((access_labels IS NULL OR access_labels @> '["hr"]'::jsonb))`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, retrieverType := range []types.RetrieverType{types.KeywordsRetrieverType, types.VectorRetrieverType} {
+				var sql string
+				repo := newDryRunRepository(t, &sql)
+				_, err := repo.Retrieve(context.Background(), types.RetrieveParams{
+					Query:            "leave policy",
+					Embedding:        []float32{0.1, 0.2},
+					KnowledgeBaseIDs: []string{"kb"},
+					TopK:             5,
+					Filters:          tt.filters,
+					Audience:         tt.audience,
+					RetrieverType:    retrieverType,
+				})
+				if err != nil {
+					t.Fatalf("%s retrieval failed: %v", retrieverType, err)
+				}
+				if !strings.Contains(sql, tt.expected) {
+					t.Errorf("%s retrieval: expected %q in\n%s", retrieverType, tt.expected, sql)
+				}
+			}
+		})
+	}
+}
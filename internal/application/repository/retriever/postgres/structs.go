@@ -24,6 +24,8 @@ type pgVector struct {
 	Content         string              `json:"content" gorm:"column:content;not null"`
 	Dimension       int                 `json:"dimension" gorm:"column:dimension;not null"`
 	Embedding       pgvector.HalfVector `json:"embedding" gorm:"column:embedding;not null"`
+	Metadata        types.IndexMetadata `json:"metadata" gorm:"column:metadata;type:jsonb"`
+	AccessLabels    types.AccessLabels  `json:"access_labels" gorm:"column:access_labels;type:jsonb"`
 }
 
 // pgVectorWithScore extends pgVector with similarity score field
@@ -39,6 +41,8 @@ type pgVectorWithScore struct {
 	Content         string              `json:"content" gorm:"column:content;not null"`
 	Dimension       int                 `json:"dimension" gorm:"column:dimension;not null"`
 	Embedding       pgvector.HalfVector `json:"embedding" gorm:"column:embedding;not null"`
+	Metadata        types.IndexMetadata `json:"metadata" gorm:"column:metadata;type:jsonb"`
+	AccessLabels    types.AccessLabels  `json:"access_labels" gorm:"column:access_labels;type:jsonb"`
 	Score           float64             `json:"score" gorm:"column:score"`
 }
 
@@ -61,6 +65,8 @@ func toDBVectorEmbedding(indexInfo *types.IndexInfo, additionalParams map[string
 		KnowledgeID:     indexInfo.KnowledgeID,
 		KnowledgeBaseID: indexInfo.KnowledgeBaseID,
 		Content:         common.CleanInvalidUTF8(indexInfo.Content),
+		Metadata:        indexInfo.Metadata,
+		AccessLabels:    indexInfo.AccessLabels,
 	}
 	// Add embedding data if available in additionalParams
 	if additionalParams != nil && slices.Contains(slices.Collect(maps.Keys(additionalParams)), "embedding") {
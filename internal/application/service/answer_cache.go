@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+// answerCacheScope lists everything that changes the answer of a query besides the query itself.
+// Answers are only shared between requests with an identical scope.
+type answerCacheScope struct {
+	KnowledgeBases   types.SessionKnowledgeBases `json:"knowledge_bases"`
+	EmbeddingModelID string                      `json:"embedding_model_id"`
+	Pipeline         *types.PipelineConfig       `json:"pipeline"`
+	VectorThreshold  float64                     `json:"vector_threshold"`
+	KeywordThreshold float64                     `json:"keyword_threshold"`
+	EmbeddingTopK    int                         `json:"embedding_top_k"`
+	Fusion           *types.FusionConfig         `json:"fusion"`
+	Filters          types.MetadataFilters       `json:"filters"`
+	Audience         []string                    `json:"audience"`
+	RerankModelID    string                      `json:"rerank_model_id"`
+	RerankTopK       int                         `json:"rerank_top_k"`
+	RerankThreshold  float64                     `json:"rerank_threshold"`
+	ChatModelID      string                      `json:"chat_model_id"`
+	SummaryConfig    types.SummaryConfig         `json:"summary_config"`
+}
+
+// answerCacheKey hashes the cache scope of a request
+func answerCacheKey(chatManage *types.ChatManage,
+	pipeline *types.PipelineConfig, embeddingModelID string,
+) (string, error) {
+	data, err := json.Marshal(answerCacheScope{
+		KnowledgeBases:   chatManage.GetKnowledgeBases(),
+		EmbeddingModelID: embeddingModelID,
+		Pipeline:         pipeline,
+		VectorThreshold:  chatManage.VectorThreshold,
+		KeywordThreshold: chatManage.KeywordThreshold,
+		EmbeddingTopK:    chatManage.EmbeddingTopK,
+		Fusion:           chatManage.Fusion,
+		Filters:          chatManage.Filters,
+		Audience:         chatManage.Audience,
+		RerankModelID:    chatManage.RerankModelID,
+		RerankTopK:       chatManage.RerankTopK,
+		RerankThreshold:  chatManage.RerankThreshold,
+		ChatModelID:      chatManage.ChatModelID,
+		SummaryConfig:    chatManage.SummaryConfig,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// answerCacheLookup holds the cache scope and query embedding of a request
+type answerCacheLookup struct {
+	scope     string
+	embedding []float32
+}
+
+// getCachedAnswer embeds the query with the embedding model of the primary knowledge base
+// and looks up a cached answer for it. Cache failures are logged and treated as a miss.
+// The lookup runs before the query is rewritten with the conversation history, so follow-up
+// questions of a session with history are neither looked up nor cached.
+func (s *sessionService) getCachedAnswer(ctx context.Context,
+	chatManage *types.ChatManage, pipeline *types.PipelineConfig,
+) (*answerCacheLookup, *interfaces.CachedAnswer) {
+	if !s.answerCache.Enabled() || !containsEvent(pipeline, types.CHUNK_SEARCH) {
+		return nil, nil
+	}
+	if s.hasHistory(ctx, chatManage.SessionID) {
+		logger.Infof(ctx, "Answer cache skipped, session has history, session ID: %s", chatManage.SessionID)
+		return nil, nil
+	}
+	kb, err := s.knowledgeBaseService.GetKnowledgeBaseByID(ctx, chatManage.GetKnowledgeBases()[0].KnowledgeBaseID)
+	if err != nil {
+		logger.Warnf(ctx, "Answer cache skipped, failed to get knowledge base: %v", err)
+		return nil, nil
+	}
+	scope, err := answerCacheKey(chatManage, pipeline, kb.EmbeddingModelID)
+	if err != nil {
+		logger.Warnf(ctx, "Answer cache skipped, failed to build cache scope: %v", err)
+		return nil, nil
+	}
+	embedder, err := s.modelService.GetEmbeddingModel(ctx, kb.EmbeddingModelID)
+	if err != nil {
+		logger.Warnf(ctx, "Answer cache skipped, failed to get embedding model: %v", err)
+		return nil, nil
+	}
+	embedding, err := embedder.Embed(ctx, strings.TrimSpace(chatManage.Query))
+	if err != nil {
+		logger.Warnf(ctx, "Answer cache skipped, failed to embed query: %v", err)
+		return nil, nil
+	}
+
+	lookup := &answerCacheLookup{scope: scope, embedding: embedding}
+	cached, err := s.answerCache.Get(ctx, scope, embedding)
+	if err != nil {
+		logger.Warnf(ctx, "Failed to get cached answer: %v", err)
+		return lookup, nil
+	}
+	return lookup, cached
+}
+
+// hasHistory returns true if the session has a completed answer before the current request.
+// A failure to read the messages is treated as history to stay on the safe side.
+func (s *sessionService) hasHistory(ctx context.Context, sessionID string) bool {
+	messages, err := s.messageRepo.GetRecentMessagesBySession(ctx, sessionID, 5)
+	if err != nil {
+		logger.Warnf(ctx, "Failed to get recent messages: %v", err)
+		return true
+	}
+	for _, message := range messages {
+		if message.Role == "assistant" && message.IsCompleted {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheAnswerStream forwards the response stream and stores the complete answer in the cache
+// once the stream is finished. Fallback responses are not cached.
+func (s *sessionService) cacheAnswerStream(ctx context.Context,
+	lookup *answerCacheLookup, chatManage *types.ChatManage,
+) <-chan types.StreamResponse {
+	source := chatManage.ResponseChan
+	references := chatManage.MergeResult
+	stream := make(chan types.StreamResponse)
+	// The answer is stored after the request finishes, keep the values but drop the cancellation
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer close(stream)
+		var (
+			answer    strings.Builder
+			citations types.Citations
+		)
+		for response := range source {
+			if response.ResponseType == types.ResponseTypeAnswer {
+				answer.WriteString(response.Content)
+			}
+			if response.ResponseType == types.ResponseTypeCitation {
+				citations = append(citations, response.Citations...)
+			}
+			stream <- response
+		}
+		content := strings.TrimSpace(answer.String())
+		if content == "" || content == chatManage.FallbackResponse {
+			return
+		}
+		if err := s.answerCache.Set(ctx, lookup.scope, &interfaces.CachedAnswer{
+			Query:               chatManage.Query,
+			Embedding:           lookup.embedding,
+			Answer:              answer.String(),
+			KnowledgeReferences: references,
+			Citations:           citations,
+			CreatedAt:           time.Now(),
+		}); err != nil {
+			logger.Warnf(ctx, "Failed to cache answer: %v", err)
+		}
+	}()
+	return stream
+}
+
+// containsEvent returns true if the pipeline triggers the event
+func containsEvent(pipeline *types.PipelineConfig, event types.EventType) bool {
+	for _, step := range pipeline.Steps {
+		if step.Event == event {
+			return true
+		}
+	}
+	return false
+}
@@ -2,7 +2,9 @@ package chatpipline
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/Tencent/WeKnora/internal/config"
 	"github.com/Tencent/WeKnora/internal/types"
 )
 
@@ -26,14 +28,88 @@ type EventManager struct {
 	listeners map[types.EventType][]Plugin
 	// Map of event types to handler functions
 	handlers map[types.EventType]func(context.Context, types.EventType, *types.ChatManage) *PluginError
+	// Map of pipeline names to pipeline definitions
+	pipelines map[string]*types.PipelineConfig
 }
 
 // NewEventManager creates and initializes a new EventManager
-func NewEventManager() *EventManager {
-	return &EventManager{
+// Built-in pipelines are registered first, pipelines declared in config.yaml may override them
+func NewEventManager(cfg *config.Config) (*EventManager, error) {
+	e := &EventManager{
 		listeners: make(map[types.EventType][]Plugin),
 		handlers:  make(map[types.EventType]func(context.Context, types.EventType, *types.ChatManage) *PluginError),
+		pipelines: make(map[string]*types.PipelineConfig),
 	}
+	for name, events := range types.Pipline {
+		if err := e.RegisterPipeline(types.NewPipelineConfig(name, events)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg != nil {
+		for _, pipeline := range cfg.Pipelines {
+			if err := e.RegisterPipeline(pipeline); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return e, nil
+}
+
+// RegisterPipeline validates a pipeline definition and registers it by name
+func (e *EventManager) RegisterPipeline(pipeline *types.PipelineConfig) error {
+	if err := pipeline.Validate(); err != nil {
+		return fmt.Errorf("invalid pipeline: %w", err)
+	}
+	if e.pipelines == nil {
+		e.pipelines = make(map[string]*types.PipelineConfig)
+	}
+	e.pipelines[pipeline.Name] = pipeline
+	return nil
+}
+
+// GetPipeline returns the pipeline registered under the given name
+func (e *EventManager) GetPipeline(name string) (*types.PipelineConfig, error) {
+	pipeline, ok := e.pipelines[name]
+	if !ok {
+		return nil, fmt.Errorf("pipeline %s not found", name)
+	}
+	return pipeline, nil
+}
+
+// ResolvePipeline returns the registered pipeline for a name-only reference,
+// or validates an inline pipeline definition from a session or knowledge base
+func (e *EventManager) ResolvePipeline(pipeline *types.PipelineConfig) (*types.PipelineConfig, error) {
+	if pipeline.IsReference() {
+		return e.GetPipeline(pipeline.Name)
+	}
+	if err := pipeline.Validate(); err != nil {
+		return nil, err
+	}
+	if err := e.checkHandlers(pipeline); err != nil {
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+// CheckPipelines makes sure every registered pipeline only uses events that have plugins,
+// it should be called after all plugins are registered
+func (e *EventManager) CheckPipelines() error {
+	for _, pipeline := range e.pipelines {
+		if err := e.checkHandlers(pipeline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkHandlers checks that every event of the pipeline has a registered handler
+func (e *EventManager) checkHandlers(pipeline *types.PipelineConfig) error {
+	for _, event := range pipeline.Events() {
+		if _, ok := e.handlers[event]; !ok {
+			return fmt.Errorf("pipeline %s: no plugin registered for event %q", pipeline.Name, event)
+		}
+	}
+	return nil
 }
 
 // Register adds a plugin to the EventManager and sets up its event handlers
@@ -90,6 +166,10 @@ var (
 		Description: "No relevant content found",
 		ErrorType:   "search_nothing",
 	}
+	ErrDirectAnswer = &PluginError{
+		Description: "Answered by an FAQ entry",
+		ErrorType:   "direct_answer",
+	}
 	ErrSearch = &PluginError{
 		Description: "Failed to search knowledge base",
 		ErrorType:   "search_failed",
@@ -123,3 +123,81 @@ func TestTrigger(t *testing.T) {
 		}
 	})
 }
+
+func TestRegisterPipeline(t *testing.T) {
+	// Built-in pipelines must be valid
+	manager, err := NewEventManager(nil)
+	if err != nil {
+		t.Fatalf("Expected built-in pipelines to be valid, got %v", err)
+	}
+	for name := range types.Pipline {
+		if _, err := manager.GetPipeline(name); err != nil {
+			t.Errorf("Expected built-in pipeline %s to be registered, got %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		events  []types.EventType
+		wantErr bool
+	}{
+		{"ValidRetrievalOnly", []types.EventType{types.CHUNK_SEARCH, types.CHUNK_RERANK, types.FILTER_TOP_K}, false},
+		{"RerankBeforeSearch", []types.EventType{types.CHUNK_RERANK, types.CHUNK_SEARCH}, true},
+		{"RerankWithoutSearch", []types.EventType{types.CHUNK_RERANK}, true},
+		{"RewriteAfterSearch", []types.EventType{types.CHUNK_SEARCH, types.REWRITE_QUERY}, true},
+		{"StreamFilterWithoutStream", []types.EventType{types.CHAT_COMPLETION, types.STREAM_FILTER}, true},
+		{"BothCompletions", []types.EventType{types.CHAT_COMPLETION, types.CHAT_COMPLETION_STREAM}, true},
+		{"GroundingAfterStreamFilter", []types.EventType{
+			types.CHUNK_SEARCH, types.INTO_CHAT_MESSAGE, types.CHAT_COMPLETION_STREAM, types.STREAM_FILTER,
+			types.ANSWER_GROUNDING,
+		}, false},
+		{"GroundingBeforeCompletion", []types.EventType{
+			types.CHUNK_SEARCH, types.INTO_CHAT_MESSAGE, types.ANSWER_GROUNDING, types.CHAT_COMPLETION,
+		}, true},
+		{"GroundingWithoutCompletion", []types.EventType{
+			types.CHUNK_SEARCH, types.INTO_CHAT_MESSAGE, types.ANSWER_GROUNDING,
+		}, true},
+		{"DuplicateEvent", []types.EventType{types.CHUNK_SEARCH, types.CHUNK_SEARCH}, true},
+		{"UnknownEvent", []types.EventType{types.EventType("unknown")}, true},
+		{"Empty", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := manager.RegisterPipeline(types.NewPipelineConfig(tt.name, tt.events))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RegisterPipeline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolvePipeline(t *testing.T) {
+	manager, err := NewEventManager(nil)
+	if err != nil {
+		t.Fatalf("NewEventManager() error = %v", err)
+	}
+	manager.Register(&testPlugin{name: "search", events: []types.EventType{types.CHUNK_SEARCH}})
+
+	// Name-only reference resolves to the registered pipeline
+	pipeline, err := manager.ResolvePipeline(&types.PipelineConfig{Name: types.PipelineRAG})
+	if err != nil || len(pipeline.Steps) != len(types.Pipline[types.PipelineRAG]) {
+		t.Errorf("Expected rag pipeline, got %v, error %v", pipeline, err)
+	}
+
+	// Unknown reference fails
+	if _, err := manager.ResolvePipeline(&types.PipelineConfig{Name: "missing"}); err == nil {
+		t.Error("Expected error for unknown pipeline")
+	}
+
+	// Inline pipeline with registered plugins is accepted
+	inline := types.NewPipelineConfig("inline", []types.EventType{types.CHUNK_SEARCH})
+	if _, err := manager.ResolvePipeline(inline); err != nil {
+		t.Errorf("Expected inline pipeline to resolve, got %v", err)
+	}
+
+	// Inline pipeline using an event without plugins fails
+	inline = types.NewPipelineConfig("inline", []types.EventType{types.CHUNK_SEARCH, types.CHUNK_RERANK})
+	if _, err := manager.ResolvePipeline(inline); err == nil {
+		t.Error("Expected error for event without registered plugin")
+	}
+}
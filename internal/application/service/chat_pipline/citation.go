@@ -0,0 +1,165 @@
+package chatpipline
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// citationMarker matches the [n] markers the chat model puts behind cited text
+var citationMarker = regexp.MustCompile(`\[(\d{1,3})\]`)
+
+// partialCitationMarker matches a marker cut off at the end of a stream fragment
+var partialCitationMarker = regexp.MustCompile(`\[\d{0,3}$`)
+
+// citationLabel returns the label put in front of the n-th passage of the chat message
+func citationLabel(n int) string {
+	return fmt.Sprintf("[%d] ", n)
+}
+
+// citationParser finds the [n] markers in a streamed answer and maps them to the numbered references.
+// Markers without a matching reference, e.g. an index as in a[0], are left in the answer as text.
+// A marker cites the text between the previous marker or sentence end and itself,
+// a marker right after a sentence end cites that sentence, adjacent markers cite the same text.
+type citationParser struct {
+	references []*types.SearchResult
+	// pending is the end of the last fragment that may be the beginning of a marker
+	pending string
+	// offset is the number of characters written so far
+	offset int
+	// start is the offset where the text cited by the next marker begins
+	start int
+	// prevStart and prevEnd are the span of the last finished sentence
+	prevStart, prevEnd int
+	// blank is true if only whitespace was written since start
+	blank bool
+	// last is the last citation, reused by markers adjacent to it
+	last *types.Citation
+	// adjacent is true if only whitespace was written since the last citation
+	adjacent bool
+	// prev is the last character written
+	prev rune
+}
+
+// newCitationParser creates a parser for the answer to a chat message with the given numbered references
+func newCitationParser(references []*types.SearchResult) *citationParser {
+	return &citationParser{references: references, blank: true}
+}
+
+// feed parses the next fragment of the answer. It returns the text to emit and the citations of the markers in it. A marker cut off at the end of the fragment is held back.
+func (p *citationParser) feed(content string) (string, []*types.Citation) {
+	text := p.pending + content
+	p.pending = ""
+	if loc := partialCitationMarker.FindStringIndex(text); loc != nil {
+		p.pending = text[loc[0]:]
+		text = text[:loc[0]]
+	}
+
+	var (
+		out       strings.Builder
+		citations []*types.Citation
+		last      int
+	)
+	for _, loc := range citationMarker.FindAllStringSubmatchIndex(text, -1) {
+		p.write(&out, text[last:loc[0]])
+		last = loc[1]
+		n, _ := strconv.Atoi(text[loc[2]:loc[3]])
+		if n < 1 || n > len(p.references) {
+			p.write(&out, text[loc[0]:loc[1]])
+			continue
+		}
+		citation := &types.Citation{
+			Index:        n,
+			MarkerOffset: p.offset,
+			ChunkID:      p.references[n-1].ID,
+			KnowledgeID:  p.references[n-1].KnowledgeID,
+		}
+		switch {
+		case p.last != nil && p.adjacent:
+			citation.Start, citation.End = p.last.Start, p.last.End
+		case p.blank:
+			citation.Start, citation.End = p.prevStart, p.prevEnd
+		default:
+			citation.Start, citation.End = p.start, p.offset
+		}
+		citations = append(citations, citation)
+
+		marker := text[loc[0]:loc[1]]
+		out.WriteString(marker)
+		p.offset += len(marker)
+		p.last, p.adjacent = citation, true
+		if !p.blank {
+			p.start, p.blank = p.offset, true
+		}
+	}
+	p.write(&out, text[last:])
+	return out.String(), citations
+}
+
+// flush returns the text held back at the end of the answer
+func (p *citationParser) flush() string {
+	var out strings.Builder
+	p.write(&out, p.pending)
+	p.pending = ""
+	return out.String()
+}
+
+// write writes answer text other than citation markers and tracks the sentence boundaries
+func (p *citationParser) write(out *strings.Builder, text string) {
+	for _, r := range text {
+		out.WriteRune(r)
+		p.offset++
+		switch {
+		case unicode.IsSpace(r) && r != '\n':
+		case isSentenceEnd(r, p.prev):
+			if !p.blank {
+				p.prevStart, p.prevEnd = p.start, p.offset
+			}
+			p.start, p.blank, p.adjacent = p.offset, true, false
+		default:
+			p.blank, p.adjacent = false, false
+		}
+		p.prev = r
+	}
+}
+
+// isSentenceEnd returns true if r ends a sentence, a dot after a digit is taken as a decimal point
+func isSentenceEnd(r rune, prev rune) bool {
+	switch r {
+	case '。', '！', '？', '；', '!', '?', ';', '\n':
+		return true
+	case '.':
+		return !unicode.IsDigit(prev)
+	}
+	return false
+}
+
+// NewCitedAnswerChan creates a channel with a complete answer followed by its citations
+func NewCitedAnswerChan(ctx context.Context, answer string, citations types.Citations) <-chan types.StreamResponse {
+	if len(citations) == 0 {
+		return NewFallbackChan(ctx, answer)
+	}
+	answerChan := make(chan types.StreamResponse)
+	go func() {
+		defer close(answerChan)
+		response := NewFallback(ctx, answer)
+		response.Done = false
+		answerChan <- response
+		answerChan <- types.StreamResponse{
+			ID:           response.ID,
+			ResponseType: types.ResponseTypeCitation,
+			Citations:    citations,
+		}
+		answerChan <- types.StreamResponse{
+			ID:           response.ID,
+			ResponseType: types.ResponseTypeAnswer,
+			Done:         true,
+		}
+	}()
+	return answerChan
+}
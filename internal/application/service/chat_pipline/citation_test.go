@@ -0,0 +1,82 @@
+package chatpipline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+func TestCitationParser(t *testing.T) {
+	references := []*types.SearchResult{
+		{ID: "chunk-1", KnowledgeID: "knowledge-1"},
+		{ID: "chunk-2", KnowledgeID: "knowledge-2"},
+	}
+	tests := []struct {
+		name      string
+		fragments []string
+		expected  string
+		citations []types.Citation
+	}{
+		{
+			name:      "marker after the sentence end cites the sentence",
+			fragments: []string{"彗尾背向太阳。[1]"},
+			expected:  "彗尾背向太阳。[1]",
+			citations: []types.Citation{{Index: 1, Start: 0, End: 7, MarkerOffset: 7}},
+		},
+		{
+			name:      "marker split across fragments",
+			fragments: []string{"彗尾背向太阳[", "2", "]。其他内容"},
+			expected:  "彗尾背向太阳[2]。其他内容",
+			citations: []types.Citation{{Index: 2, Start: 0, End: 6, MarkerOffset: 6}},
+		},
+		{
+			name:      "adjacent markers cite the same text",
+			fragments: []string{"第一句。第二句[1] [2]。"},
+			expected:  "第一句。第二句[1] [2]。",
+			citations: []types.Citation{
+				{Index: 1, Start: 4, End: 7, MarkerOffset: 7},
+				{Index: 2, Start: 4, End: 7, MarkerOffset: 11},
+			},
+		},
+		{
+			name:      "markers without a reference are kept as text",
+			fragments: []string{"版本3.5发布[3]，", "数组a[0]为空[1]"},
+			expected:  "版本3.5发布[3]，数组a[0]为空[1]",
+			citations: []types.Citation{{Index: 1, Start: 0, End: 19, MarkerOffset: 19}},
+		},
+		{
+			name:      "unfinished marker is flushed as text",
+			fragments: []string{"结尾[1"},
+			expected:  "结尾[1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := newCitationParser(references)
+			var (
+				out       strings.Builder
+				citations []types.Citation
+			)
+			for _, fragment := range tt.fragments {
+				content, cited := parser.feed(fragment)
+				out.WriteString(content)
+				for _, citation := range cited {
+					citations = append(citations, *citation)
+				}
+			}
+			out.WriteString(parser.flush())
+			if out.String() != tt.expected {
+				t.Errorf("answer = %q, expected %q", out.String(), tt.expected)
+			}
+			for i := range tt.citations {
+				tt.citations[i].ChunkID = references[tt.citations[i].Index-1].ID
+				tt.citations[i].KnowledgeID = references[tt.citations[i].Index-1].KnowledgeID
+			}
+			if !reflect.DeepEqual(citations, tt.citations) {
+				t.Errorf("citations = %+v, expected %+v", citations, tt.citations)
+			}
+		})
+	}
+}
@@ -49,13 +49,8 @@ func prepareMessagesWithHistory(chatManage *types.ChatManage) []chat.Message {
 		{Role: "system", Content: chatManage.SummaryConfig.Prompt},
 	}
 
-	chatHistory := chatManage.History
-	if len(chatHistory) > 2 {
-		chatHistory = chatHistory[len(chatHistory)-2:]
-	}
-
 	// Add conversation history
-	for _, history := range chatHistory {
+	for _, history := range recentHistory(chatManage) {
 		chatMessages = append(chatMessages, chat.Message{Role: "user", Content: history.Query})
 		chatMessages = append(chatMessages, chat.Message{Role: "assistant", Content: history.Answer})
 	}
@@ -65,3 +60,80 @@ func prepareMessagesWithHistory(chatManage *types.ChatManage) []chat.Message {
 
 	return chatMessages
 }
+
+// recentHistory returns the history rounds sent to the chat model with the current message
+func recentHistory(chatManage *types.ChatManage) []*types.History {
+	chatHistory := chatManage.History
+	if len(chatHistory) > 2 {
+		chatHistory = chatHistory[len(chatHistory)-2:]
+	}
+	return chatHistory
+}
+
+// getPluginOption returns the option configured for the event by the current pipeline
+func getPluginOption(chatManage *types.ChatManage, eventType types.EventType, key string) (interface{}, bool) {
+	options, ok := chatManage.PipelineOptions[eventType]
+	if !ok {
+		return nil, false
+	}
+	value, ok := options[key]
+	return value, ok
+}
+
+// getIntOption returns an integer pipeline option, or def if it is not configured
+func getIntOption(chatManage *types.ChatManage, eventType types.EventType, key string, def int) int {
+	value, ok := getPluginOption(chatManage, eventType, key)
+	if !ok {
+		return def
+	}
+	switch v := value.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return def
+}
+
+// getFloatOption returns a float pipeline option, or def if it is not configured
+func getFloatOption(chatManage *types.ChatManage, eventType types.EventType, key string, def float64) float64 {
+	value, ok := getPluginOption(chatManage, eventType, key)
+	if !ok {
+		return def
+	}
+	switch v := value.(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return def
+}
+
+// getBoolOption returns a boolean pipeline option, or def if it is not configured
+func getBoolOption(chatManage *types.ChatManage, eventType types.EventType, key string, def bool) bool {
+	value, ok := getPluginOption(chatManage, eventType, key)
+	if !ok {
+		return def
+	}
+	if v, ok := value.(bool); ok {
+		return v
+	}
+	return def
+}
+
+// getStringOption returns a string pipeline option, or def if it is not configured
+func getStringOption(chatManage *types.ChatManage, eventType types.EventType, key string, def string) string {
+	value, ok := getPluginOption(chatManage, eventType, key)
+	if !ok {
+		return def
+	}
+	if v, ok := value.(string); ok {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,134 @@
+package chatpipline
+
+import (
+	"context"
+
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/tokenizer"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+// Overflow strategies for the passages that do not fit into the context budget
+const (
+	// OverflowTrim cuts the first passage that does not fit to the remaining tokens and drops the rest
+	OverflowTrim = "trim"
+	// OverflowSummarize compresses all passages that do not fit into one summary of the remaining tokens
+	OverflowSummarize = "summarize"
+)
+
+// contextBudgeter decides which passages of the merge results fit into the tokens
+// left for the retrieved context and records its decisions in the budget
+type contextBudgeter struct {
+	tokenizer     tokenizer.Tokenizer
+	budget        *types.ContextBudget
+	strategy      string
+	minTrimTokens int
+	// overhead is the number of tokens the context template adds around every passage
+	overhead int
+	// summarize compresses the overflowing passages into at most maxTokens tokens
+	summarize func(passages []string, maxTokens int) (string, error)
+}
+
+// newLimitBudgeter creates a budgeter which trims the retrieved context to a plain token limit,
+// a non-positive limit means unlimited
+func newLimitBudgeter(tok tokenizer.Tokenizer, limit int, minTrimTokens int) *contextBudgeter {
+	limit = max(limit, 0)
+	return &contextBudgeter{
+		tokenizer:     tok,
+		budget:        &types.ContextBudget{Tokenizer: tok.GetName(), ContextLength: limit, ContextTokens: limit},
+		strategy:      OverflowTrim,
+		minTrimTokens: minTrimTokens,
+	}
+}
+
+// chatModelParameters returns the parameters of the chat model, empty parameters if it cannot be found
+func chatModelParameters(ctx context.Context,
+	modelService interfaces.ModelService, chatModelID string,
+) types.ModelParameters {
+	model, err := modelService.GetModelByID(ctx, chatModelID)
+	if err != nil {
+		logger.Warnf(ctx, "Failed to get chat model %s, using the default context budget: %v", chatModelID, err)
+		return types.ModelParameters{}
+	}
+	return model.Parameters
+}
+
+// remaining returns the tokens of a limited budget that are not used yet
+func (b *contextBudgeter) remaining() int {
+	return b.budget.ContextTokens - b.budget.UsedTokens
+}
+
+// take charges the tokens of text to the budget, returns false without charging them if they do not fit
+func (b *contextBudgeter) take(text string) bool {
+	tokens := b.tokenizer.CountTokens(text)
+	if b.budget.Limited() && tokens > b.remaining() {
+		return false
+	}
+	b.budget.UsedTokens += tokens
+	return true
+}
+
+// fitResults fits the contents of search results like fit, the content of a trimmed result is cut
+func (b *contextBudgeter) fitResults(results []*types.SearchResult) []*types.SearchResult {
+	passages := make([]string, len(results))
+	for i, result := range results {
+		passages[i] = result.Content
+	}
+	passages = b.fit(passages)
+	for i, passage := range passages {
+		results[i].Content = passage
+	}
+	return results[:len(passages)]
+}
+
+// fit returns the passages sent to the chat model. Passages are ranked by score, so they are kept
+// in order while they fit. The first passage that does not fit is trimmed to the remaining tokens,
+// or together with all lower ranked passages summarised into them, the rest is dropped.
+// If the remaining tokens are fewer than minTrimTokens the overflowing passages are dropped directly.
+func (b *contextBudgeter) fit(passages []string) []string {
+	if !b.budget.Limited() {
+		for _, passage := range passages {
+			b.budget.UsedTokens += b.tokenizer.CountTokens(passage) + b.overhead
+		}
+		b.budget.KeptChunks = len(passages)
+		return passages
+	}
+
+	remaining := b.budget.ContextTokens
+	kept := make([]string, 0, len(passages))
+	for i, passage := range passages {
+		tokens := b.tokenizer.CountTokens(passage) + b.overhead
+		if tokens <= remaining {
+			kept = append(kept, passage)
+			remaining -= tokens
+			b.budget.UsedTokens += tokens
+			continue
+		}
+
+		overflow := passages[i:]
+		remaining -= b.overhead
+		if remaining <= 0 || remaining < b.minTrimTokens {
+			break
+		}
+		if b.strategy == OverflowSummarize && b.summarize != nil {
+			summary, err := b.summarize(overflow, remaining)
+			if err == nil && summary != "" {
+				summary = b.tokenizer.Truncate(summary, remaining)
+				b.budget.UsedTokens += b.tokenizer.CountTokens(summary) + b.overhead
+				b.budget.SummarizedChunks = len(overflow)
+				b.budget.KeptChunks = len(kept)
+				return append(kept, summary)
+			}
+		}
+		kept = append(kept, b.tokenizer.Truncate(passage, remaining))
+		b.budget.UsedTokens += b.tokenizer.CountTokens(kept[len(kept)-1]) + b.overhead
+		b.budget.TrimmedChunks = 1
+		b.budget.KeptChunks = len(kept) - 1
+		b.budget.DroppedChunks = len(overflow) - 1
+		return kept
+	}
+	b.budget.KeptChunks = len(kept)
+	b.budget.DroppedChunks = len(passages) - len(kept)
+	return kept
+}
@@ -0,0 +1,114 @@
+package chatpipline
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/models/tokenizer"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+func TestContextBudgeterFit(t *testing.T) {
+	passages := []string{"第一段内容", "第二段内容", "第三段内容"}
+	tests := []struct {
+		name          string
+		contextTokens int
+		strategy      string
+		minTrimTokens int
+		summarize     func(passages []string, maxTokens int) (string, error)
+		expected      []string
+		kept          int
+		trimmed       int
+		summarized    int
+		dropped       int
+	}{
+		{
+			name:          "all passages fit",
+			contextTokens: 20,
+			expected:      passages,
+			kept:          3,
+		},
+		{
+			name:          "lowest ranked passage is trimmed",
+			contextTokens: 14,
+			strategy:      OverflowTrim,
+			expected:      []string{"第一段内容", "第二段内容", "第"},
+			kept:          2,
+			trimmed:       1,
+		},
+		{
+			name:          "too few tokens left to trim",
+			contextTokens: 8,
+			strategy:      OverflowTrim,
+			minTrimTokens: 2,
+			expected:      []string{"第一段内容"},
+			kept:          1,
+			dropped:       2,
+		},
+		{
+			name:          "overflowing passages are summarized",
+			contextTokens: 9,
+			strategy:      OverflowSummarize,
+			summarize: func(passages []string, maxTokens int) (string, error) {
+				return "摘要内容超出预算", nil
+			},
+			expected:   []string{"第一段内容", "摘要"},
+			kept:       1,
+			summarized: 2,
+		},
+		{
+			name:          "failed summary falls back to trimming",
+			contextTokens: 9,
+			strategy:      OverflowSummarize,
+			summarize: func(passages []string, maxTokens int) (string, error) {
+				return "", errors.New("timeout")
+			},
+			expected: []string{"第一段内容", "第二"},
+			kept:     1,
+			trimmed:  1,
+			dropped:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budgeter := &contextBudgeter{
+				tokenizer:     tokenizer.NewTokenizer(tokenizer.TokenizerEstimate),
+				budget:        &types.ContextBudget{ContextLength: 100, ContextTokens: tt.contextTokens},
+				strategy:      tt.strategy,
+				minTrimTokens: tt.minTrimTokens,
+				overhead:      1,
+				summarize:     tt.summarize,
+			}
+			got := budgeter.fit(passages)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("fit() = %v, expected %v", got, tt.expected)
+			}
+			budget := budgeter.budget
+			if budget.KeptChunks != tt.kept || budget.TrimmedChunks != tt.trimmed ||
+				budget.SummarizedChunks != tt.summarized || budget.DroppedChunks != tt.dropped {
+				t.Errorf("kept/trimmed/summarized/dropped = %d/%d/%d/%d, expected %d/%d/%d/%d",
+					budget.KeptChunks, budget.TrimmedChunks, budget.SummarizedChunks, budget.DroppedChunks,
+					tt.kept, tt.trimmed, tt.summarized, tt.dropped)
+			}
+			if budget.UsedTokens > tt.contextTokens {
+				t.Errorf("used tokens %d exceed the budget %d", budget.UsedTokens, tt.contextTokens)
+			}
+		})
+	}
+}
+
+func TestContextBudgeterUnlimited(t *testing.T) {
+	budgeter := &contextBudgeter{
+		tokenizer: tokenizer.NewTokenizer(tokenizer.TokenizerEstimate),
+		budget:    &types.ContextBudget{},
+	}
+	passages := []string{"第一段内容", "第二段内容"}
+	if got := budgeter.fit(passages); !reflect.DeepEqual(got, passages) {
+		t.Errorf("fit() = %v, expected %v", got, passages)
+	}
+	if budgeter.budget.UsedTokens != 10 || budgeter.budget.KeptChunks != 2 {
+		t.Errorf("used tokens = %d, kept = %d, expected 10 and 2",
+			budgeter.budget.UsedTokens, budgeter.budget.KeptChunks)
+	}
+}
@@ -0,0 +1,208 @@
+package chatpipline
+
+import (
+	"context"
+
+	"github.com/Tencent/WeKnora/internal/config"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/tokenizer"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+// PluginContextWindow expands the retrieved chunks with their neighbouring chunks before they are
+// turned into the chat message, and keeps the whole context within a token budget counted with the
+// tokenizer of the chat model. Parent sections of child chunk hits are already returned by the search.
+type PluginContextWindow struct {
+	chunkRepo    interfaces.ChunkRepository
+	modelService interfaces.ModelService
+	config       *config.Config
+}
+
+// NewPluginContextWindow creates and registers a new PluginContextWindow instance
+// The plugin must be registered before PluginIntoChatMessage
+func NewPluginContextWindow(eventManager *EventManager,
+	chunkRepo interfaces.ChunkRepository, modelService interfaces.ModelService, config *config.Config,
+) *PluginContextWindow {
+	res := &PluginContextWindow{
+		chunkRepo:    chunkRepo,
+		modelService: modelService,
+		config:       config,
+	}
+	eventManager.Register(res)
+	return res
+}
+
+// ActivationEvents returns the event types this plugin handles
+func (p *PluginContextWindow) ActivationEvents() []types.EventType {
+	return []types.EventType{types.INTO_CHAT_MESSAGE}
+}
+
+// OnEvent fits the merge results into the token budget and stitches neighbouring chunks to them
+func (p *PluginContextWindow) OnEvent(ctx context.Context,
+	eventType types.EventType, chatManage *types.ChatManage, next func() *PluginError,
+) *PluginError {
+	window := getIntOption(chatManage, eventType, "context_window", p.config.GetConversation().ContextWindow)
+	maxTokens := getIntOption(chatManage, eventType, "max_context_tokens", p.config.GetConversation().MaxContextTokens)
+	if len(chatManage.MergeResult) == 0 || (window <= 0 && maxTokens <= 0) {
+		return next()
+	}
+
+	minTrimTokens := 0
+	if cfg := p.config.GetConversation().ContextBudget; cfg != nil {
+		minTrimTokens = cfg.MinTrimTokens
+	}
+	params := chatModelParameters(ctx, p.modelService, chatManage.ChatModelID)
+	budget := newLimitBudgeter(tokenizer.NewTokenizer(params.Tokenizer), maxTokens, minTrimTokens)
+	before := len(chatManage.MergeResult)
+	chatManage.MergeResult = budget.fitResults(chatManage.MergeResult)
+	logger.Infof(ctx, "Context fitted into token budget %d, tokenizer: %s, results: %d -> %d, used tokens: %d",
+		maxTokens, budget.budget.Tokenizer, before, len(chatManage.MergeResult), budget.budget.UsedTokens)
+
+	if window > 0 {
+		tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+		fetch := func(ids []string) ([]*types.Chunk, error) {
+			return p.chunkRepo.ListChunksByID(ctx, tenantID, ids)
+		}
+		expanded, err := expandContextWindow(ctx, chatManage.MergeResult, window, budget, fetch)
+		if err != nil {
+			// Expansion only adds context, the original results are still usable
+			logger.Warnf(ctx, "Failed to expand context window: %v", err)
+		}
+		logger.Infof(ctx, "Expanded context window %d, neighbouring chunks added: %d, used tokens: %d",
+			window, expanded, budget.budget.UsedTokens)
+	}
+	return next()
+}
+
+// contextCursor tracks how far a result has been expanded in both directions
+type contextCursor struct {
+	result       *types.SearchResult
+	prevChunkID  string
+	nextChunkID  string
+	prevFinished bool
+	nextFinished bool
+}
+
+// expandContextWindow stitches up to window neighbouring text chunks to both sides of every text result,
+// one hop at a time in score order so that higher ranked results are expanded first.
+// Overlapping text between neighbouring chunks is only added once. Returns the number of chunks added.
+func expandContextWindow(ctx context.Context, results []*types.SearchResult, window int,
+	budget *contextBudgeter, fetch func(ids []string) ([]*types.Chunk, error),
+) (int, error) {
+	used := make(map[string]bool)
+	var ids []string
+	for _, result := range results {
+		used[result.ID] = true
+		for _, subChunkID := range result.SubChunkID {
+			used[subChunkID] = true
+		}
+		if result.ChunkType == string(types.ChunkTypeText) {
+			ids = append(ids, result.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	chunks, err := fetch(ids)
+	if err != nil {
+		return 0, err
+	}
+	chunkMap := make(map[string]*types.Chunk, len(chunks))
+	for _, chunk := range chunks {
+		chunkMap[chunk.ID] = chunk
+	}
+	var cursors []*contextCursor
+	for _, result := range results {
+		if chunk, ok := chunkMap[result.ID]; ok && result.ChunkType == string(types.ChunkTypeText) {
+			cursors = append(cursors, &contextCursor{
+				result:      result,
+				prevChunkID: chunk.PreChunkID,
+				nextChunkID: chunk.NextChunkID,
+			})
+		}
+	}
+
+	added := 0
+	for hop := 0; hop < window; hop++ {
+		// Fetch the next ring of neighbours of all results at once
+		var neighbourIDs []string
+		for _, cursor := range cursors {
+			if !cursor.prevFinished && cursor.prevChunkID != "" && !used[cursor.prevChunkID] {
+				neighbourIDs = append(neighbourIDs, cursor.prevChunkID)
+			}
+			if !cursor.nextFinished && cursor.nextChunkID != "" && !used[cursor.nextChunkID] {
+				neighbourIDs = append(neighbourIDs, cursor.nextChunkID)
+			}
+		}
+		if len(neighbourIDs) == 0 {
+			break
+		}
+		neighbours, err := fetch(neighbourIDs)
+		if err != nil {
+			return added, err
+		}
+		neighbourMap := make(map[string]*types.Chunk, len(neighbours))
+		for _, chunk := range neighbours {
+			neighbourMap[chunk.ID] = chunk
+		}
+
+		for _, cursor := range cursors {
+			if !cursor.prevFinished {
+				chunk, ok := neighbourMap[cursor.prevChunkID]
+				if !ok || used[chunk.ID] || !prependChunk(ctx, cursor.result, chunk, budget) {
+					cursor.prevFinished = true
+				} else {
+					used[chunk.ID] = true
+					cursor.prevChunkID = chunk.PreChunkID
+					added++
+				}
+			}
+			if !cursor.nextFinished {
+				chunk, ok := neighbourMap[cursor.nextChunkID]
+				if !ok || used[chunk.ID] || !appendChunk(ctx, cursor.result, chunk, budget) {
+					cursor.nextFinished = true
+				} else {
+					used[chunk.ID] = true
+					cursor.nextChunkID = chunk.NextChunkID
+					added++
+				}
+			}
+		}
+	}
+	return added, nil
+}
+
+// prependChunk adds the part of the previous chunk that does not overlap the result in front of it
+func prependChunk(ctx context.Context, result *types.SearchResult, chunk *types.Chunk, budget *contextBudgeter) bool {
+	runes := []rune(chunk.Content)
+	keep := len(runes) - max(chunk.EndAt-result.StartAt, 0)
+	text := string(runes[:min(max(keep, 0), len(runes))])
+	if !budget.take(text) {
+		return false
+	}
+	result.Content = text + result.Content
+	result.StartAt = chunk.StartAt
+	result.SubChunkID = append(result.SubChunkID, chunk.ID)
+	if err := mergeImageInfo(ctx, result, &types.SearchResult{ImageInfo: chunk.ImageInfo}); err != nil {
+		logger.Warnf(ctx, "Failed to merge ImageInfo: %v", err)
+	}
+	return true
+}
+
+// appendChunk adds the part of the next chunk that does not overlap the result behind it
+func appendChunk(ctx context.Context, result *types.SearchResult, chunk *types.Chunk, budget *contextBudgeter) bool {
+	runes := []rune(chunk.Content)
+	skip := max(result.EndAt-chunk.StartAt, 0)
+	text := string(runes[min(skip, len(runes)):])
+	if !budget.take(text) {
+		return false
+	}
+	result.Content += text
+	result.EndAt = chunk.EndAt
+	result.SubChunkID = append(result.SubChunkID, chunk.ID)
+	if err := mergeImageInfo(ctx, result, &types.SearchResult{ImageInfo: chunk.ImageInfo}); err != nil {
+		logger.Warnf(ctx, "Failed to merge ImageInfo: %v", err)
+	}
+	return true
+}
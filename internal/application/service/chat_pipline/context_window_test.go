@@ -0,0 +1,149 @@
+package chatpipline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/models/tokenizer"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// testBudgeter creates a budgeter of the given token limit counting with the estimate tokenizer
+func testBudgeter(limit int) *contextBudgeter {
+	return newLimitBudgeter(tokenizer.NewTokenizer(tokenizer.TokenizerEstimate), limit, 2)
+}
+
+// testChunks builds a linked list of text chunks c0..c4 with 2 runes of overlap between neighbours
+func testChunks() map[string]*types.Chunk {
+	contents := []string{"aaaaAB", "ABbbbbCD", "CDccccEF", "EFddddGH", "GHeeee"}
+	ids := []string{"c0", "c1", "c2", "c3", "c4"}
+	chunks := make(map[string]*types.Chunk)
+	start := 0
+	for i, content := range contents {
+		chunk := &types.Chunk{
+			ID:        ids[i],
+			Content:   content,
+			ChunkType: types.ChunkTypeText,
+			StartAt:   start,
+			EndAt:     start + len(content),
+		}
+		if i > 0 {
+			chunk.PreChunkID = ids[i-1]
+		}
+		if i < len(ids)-1 {
+			chunk.NextChunkID = ids[i+1]
+		}
+		chunks[chunk.ID] = chunk
+		start += len(content) - 2
+	}
+	return chunks
+}
+
+func fetchFrom(chunks map[string]*types.Chunk) func(ids []string) ([]*types.Chunk, error) {
+	return func(ids []string) ([]*types.Chunk, error) {
+		var result []*types.Chunk
+		for _, id := range ids {
+			if chunk, ok := chunks[id]; ok {
+				result = append(result, chunk)
+			}
+		}
+		return result, nil
+	}
+}
+
+func searchResultOf(chunk *types.Chunk) *types.SearchResult {
+	return &types.SearchResult{
+		ID:        chunk.ID,
+		Content:   chunk.Content,
+		StartAt:   chunk.StartAt,
+		EndAt:     chunk.EndAt,
+		ChunkType: string(chunk.ChunkType),
+	}
+}
+
+func TestExpandContextWindow(t *testing.T) {
+	t.Run("neighbours stitched without overlap", func(t *testing.T) {
+		chunks := testChunks()
+		result := searchResultOf(chunks["c2"])
+		added, err := expandContextWindow(context.Background(), []*types.SearchResult{result},
+			1, testBudgeter(0), fetchFrom(chunks))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if added != 2 {
+			t.Errorf("expected 2 neighbours added, got %d", added)
+		}
+		if expected := "ABbbbbCDccccEFddddGH"; result.Content != expected {
+			t.Errorf("expected content %q, got %q", expected, result.Content)
+		}
+		if result.StartAt != chunks["c1"].StartAt || result.EndAt != chunks["c3"].EndAt {
+			t.Errorf("unexpected span [%d, %d]", result.StartAt, result.EndAt)
+		}
+	})
+
+	t.Run("stops at chunks used by other results", func(t *testing.T) {
+		chunks := testChunks()
+		first := searchResultOf(chunks["c1"])
+		second := searchResultOf(chunks["c2"])
+		_, err := expandContextWindow(context.Background(), []*types.SearchResult{first, second},
+			2, testBudgeter(0), fetchFrom(chunks))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := "aaaaABbbbbCD"; first.Content != expected {
+			t.Errorf("expected first content %q, got %q", expected, first.Content)
+		}
+		if expected := "CDccccEFddddGHeeee"; second.Content != expected {
+			t.Errorf("expected second content %q, got %q", expected, second.Content)
+		}
+	})
+
+	t.Run("respects token budget", func(t *testing.T) {
+		chunks := testChunks()
+		result := searchResultOf(chunks["c2"])
+		// Each non-overlapping neighbour part is 6 bytes, i.e. 2 estimated tokens
+		added, err := expandContextWindow(context.Background(), []*types.SearchResult{result},
+			2, testBudgeter(2), fetchFrom(chunks))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if added != 1 {
+			t.Errorf("expected 1 neighbour added, got %d", added)
+		}
+	})
+
+	t.Run("non text results are not expanded", func(t *testing.T) {
+		chunks := testChunks()
+		result := searchResultOf(chunks["c2"])
+		result.ChunkType = types.ChunkTypeSummary
+		added, _ := expandContextWindow(context.Background(), []*types.SearchResult{result},
+			1, testBudgeter(0), fetchFrom(chunks))
+		if added != 0 || result.Content != chunks["c2"].Content {
+			t.Errorf("expected summary result to stay unchanged, got %q", result.Content)
+		}
+	})
+}
+
+func TestLimitBudgeterFitResults(t *testing.T) {
+	results := []*types.SearchResult{
+		{ID: "r1", Content: "这是第一段内容"}, // 7 tokens
+		{ID: "r2", Content: "第二段"},     // 3 tokens
+		{ID: "r3", Content: "第三段内容"},   // 5 tokens
+	}
+	budget := testBudgeter(11)
+	fitted := budget.fitResults(results)
+	if len(fitted) != 2 || budget.remaining() != 1 {
+		t.Errorf("expected 2 results and 1 remaining token, got %d results and %d tokens",
+			len(fitted), budget.remaining())
+	}
+
+	first := []*types.SearchResult{{ID: "r1", Content: "这是第一段内容"}}
+	fitted = testBudgeter(4).fitResults(first)
+	if len(fitted) != 1 || fitted[0].Content != "这是第一" {
+		t.Errorf("expected the first result trimmed to 4 tokens, got %q", fitted[0].Content)
+	}
+
+	if fitted := testBudgeter(0).fitResults(results); len(fitted) != 3 {
+		t.Errorf("expected unlimited budget to keep all results, got %d", len(fitted))
+	}
+}
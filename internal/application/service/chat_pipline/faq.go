@@ -0,0 +1,90 @@
+package chatpipline
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/Tencent/WeKnora/internal/config"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// PluginFAQ answers with the paired answer of an FAQ hit instead of generating one,
+// if the query matches a question of the entry or the hit scores above the threshold.
+// It has to be registered after PluginSearch, so it runs once the search results are set.
+type PluginFAQ struct {
+	config *config.Config
+}
+
+// NewPluginFAQ creates and registers a new PluginFAQ instance
+func NewPluginFAQ(eventManager *EventManager, config *config.Config) *PluginFAQ {
+	res := &PluginFAQ{config: config}
+	eventManager.Register(res)
+	return res
+}
+
+// ActivationEvents returns the event types this plugin handles
+func (p *PluginFAQ) ActivationEvents() []types.EventType {
+	return []types.EventType{types.CHUNK_SEARCH}
+}
+
+// OnEvent stops the pipeline with ErrDirectAnswer on a high-confidence FAQ hit,
+// the hit becomes the only merge result and the answer is set as the response
+func (p *PluginFAQ) OnEvent(ctx context.Context,
+	eventType types.EventType, chatManage *types.ChatManage, next func() *PluginError,
+) *PluginError {
+	cfg := p.config.GetConversation().FAQ
+	if cfg == nil || !getBoolOption(chatManage, eventType, "faq_direct_answer", cfg.DirectAnswer) {
+		return next()
+	}
+	threshold := getFloatOption(chatManage, eventType, "faq_threshold", cfg.Threshold)
+	hit := matchFAQ(chatManage.SearchResult, []string{chatManage.Query, chatManage.RewriteQuery}, threshold)
+	if hit == nil {
+		return next()
+	}
+
+	logger.Infof(ctx, "FAQ direct answer, question: %s, score: %f", hit.FAQ.Question, hit.Score)
+	chatManage.MergeResult = []*types.SearchResult{hit}
+	chatManage.ChatResponse = &types.ChatResponse{Content: hit.FAQ.Answer}
+	chatManage.ResponseChan = NewFallbackChan(ctx, hit.FAQ.Answer)
+	return ErrDirectAnswer
+}
+
+// matchFAQ returns the FAQ result whose questions contain one of the queries, or else the best
+// FAQ result scoring at least the threshold. A threshold of 0 or less only allows exact matches.
+func matchFAQ(results []*types.SearchResult, queries []string, threshold float64) *types.SearchResult {
+	normalized := make(map[string]bool, len(queries))
+	for _, query := range queries {
+		if query = normalizeQuestion(query); query != "" {
+			normalized[query] = true
+		}
+	}
+	var best *types.SearchResult
+	for _, result := range results {
+		if result.FAQ == nil {
+			continue
+		}
+		for _, question := range result.FAQ.Questions() {
+			if normalized[normalizeQuestion(question)] {
+				return result
+			}
+		}
+		if threshold > 0 && result.Score >= threshold && (best == nil || result.Score > best.Score) {
+			best = result
+		}
+	}
+	return best
+}
+
+// normalizeQuestion lowercases a question and drops everything but letters and digits,
+// so questions differing only in case, spacing or punctuation match
+func normalizeQuestion(question string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(question) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
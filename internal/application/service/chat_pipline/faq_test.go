@@ -0,0 +1,62 @@
+package chatpipline
+
+import (
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+func TestNormalizeQuestion(t *testing.T) {
+	tests := []struct {
+		question string
+		want     string
+	}{
+		{question: "如何 重置密码？", want: "如何重置密码"},
+		{question: "How do I reset my Password?", want: "howdoiresetmypassword"},
+		{question: "  ", want: ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeQuestion(tt.question); got != tt.want {
+			t.Errorf("normalizeQuestion(%q) = %q, want %q", tt.question, got, tt.want)
+		}
+	}
+}
+
+func TestMatchFAQ(t *testing.T) {
+	faq := func(id string, score float64, question string, similar ...string) *types.SearchResult {
+		return &types.SearchResult{
+			ID:    id,
+			Score: score,
+			FAQ:   &types.FAQEntry{Question: question, SimilarQuestions: similar, Answer: "answer " + id},
+		}
+	}
+	results := []*types.SearchResult{
+		{ID: "text", Score: 0.99, Content: "如何重置密码"},
+		faq("reset", 0.8, "如何重置密码", "忘记密码怎么办"),
+		faq("login", 0.9, "如何登录"),
+		faq("logout", 0.97, "如何退出登录"),
+	}
+	tests := []struct {
+		name      string
+		queries   []string
+		threshold float64
+		want      string
+	}{
+		{name: "exact question", queries: []string{"如何重置密码?"}, threshold: 0.95, want: "reset"},
+		{name: "similar question", queries: []string{"", "忘记 密码怎么办"}, threshold: 0, want: "reset"},
+		{name: "best score above threshold", queries: []string{"登录不了"}, threshold: 0.85, want: "logout"},
+		{name: "below threshold", queries: []string{"登录不了"}, threshold: 0.98},
+		{name: "exact match only", queries: []string{"登录不了"}, threshold: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchFAQ(results, tt.queries, tt.threshold)
+			switch {
+			case got == nil && tt.want != "":
+				t.Errorf("matchFAQ() = nil, want %s", tt.want)
+			case got != nil && got.ID != tt.want:
+				t.Errorf("matchFAQ() = %s, want %q", got.ID, tt.want)
+			}
+		})
+	}
+}
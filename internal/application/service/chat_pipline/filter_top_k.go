@@ -28,7 +28,8 @@ func (p *PluginFilterTopK) OnEvent(ctx context.Context,
 	eventType types.EventType, chatManage *types.ChatManage, next func() *PluginError,
 ) *PluginError {
 	logger.Info(ctx, "Starting filter top-K process")
-	logger.Infof(ctx, "Filter configuration: top-K = %d", chatManage.RerankTopK)
+	topK := getIntOption(chatManage, eventType, "top_k", chatManage.RerankTopK)
+	logger.Infof(ctx, "Filter configuration: top-K = %d", topK)
 
 	filterTopK := func(searchResult []*types.SearchResult, topK int) []*types.SearchResult {
 		if topK > 0 && len(searchResult) > topK {
@@ -39,11 +40,11 @@ func (p *PluginFilterTopK) OnEvent(ctx context.Context,
 	}
 
 	if len(chatManage.MergeResult) > 0 {
-		chatManage.MergeResult = filterTopK(chatManage.MergeResult, chatManage.RerankTopK)
+		chatManage.MergeResult = filterTopK(chatManage.MergeResult, topK)
 	} else if len(chatManage.RerankResult) > 0 {
-		chatManage.RerankResult = filterTopK(chatManage.RerankResult, chatManage.RerankTopK)
+		chatManage.RerankResult = filterTopK(chatManage.RerankResult, topK)
 	} else if len(chatManage.SearchResult) > 0 {
-		chatManage.SearchResult = filterTopK(chatManage.SearchResult, chatManage.RerankTopK)
+		chatManage.SearchResult = filterTopK(chatManage.SearchResult, topK)
 	} else {
 		logger.Info(ctx, "No results to filter")
 	}
@@ -0,0 +1,374 @@
+package chatpipline
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Tencent/WeKnora/internal/config"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/models/tokenizer"
+	"github.com/Tencent/WeKnora/internal/models/utils"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+// Grounding scoring methods
+const (
+	// GroundingMethodNLI asks the chat model whether the chunks entail every sentence
+	GroundingMethodNLI = "nli"
+	// GroundingMethodEmbedding scores every sentence by its highest embedding similarity to a chunk
+	GroundingMethodEmbedding = "embedding"
+)
+
+// Actions taken when the groundedness is below the threshold
+const (
+	// GroundingActionNone only records the score
+	GroundingActionNone = "none"
+	// GroundingActionDisclaimer appends the disclaimer to the answer
+	GroundingActionDisclaimer = "disclaimer"
+	// GroundingActionFallback replaces the answer with the fallback response.
+	// Streamed answers are held back until they are scored.
+	GroundingActionFallback = "fallback"
+)
+
+// groundingScore matches the "n: score" lines of the NLI response
+var groundingScore = regexp.MustCompile(`(?m)^\s*(\d+)\s*[:：]\s*(\d+(?:\.\d+)?)`)
+
+// PluginAnswerGrounding scores how well the generated answer is supported by the merge results
+// and replaces the answer or appends a disclaimer if the score is below the threshold
+type PluginAnswerGrounding struct {
+	modelService         interfaces.ModelService         // Model service for the chat and embedding models
+	knowledgeBaseService interfaces.KnowledgeBaseService // Knowledge base service for the embedding model
+	config               *config.Config                  // System configuration
+}
+
+// NewPluginAnswerGrounding creates and registers a new PluginAnswerGrounding instance
+func NewPluginAnswerGrounding(eventManager *EventManager,
+	modelService interfaces.ModelService,
+	knowledgeBaseService interfaces.KnowledgeBaseService,
+	config *config.Config,
+) *PluginAnswerGrounding {
+	res := &PluginAnswerGrounding{
+		modelService:         modelService,
+		knowledgeBaseService: knowledgeBaseService,
+		config:               config,
+	}
+	eventManager.Register(res)
+	return res
+}
+
+// ActivationEvents returns the event types this plugin handles
+func (p *PluginAnswerGrounding) ActivationEvents() []types.EventType {
+	return []types.EventType{types.ANSWER_GROUNDING}
+}
+
+// groundingSettings are the grounding options resolved for the current pipeline
+type groundingSettings struct {
+	method     string
+	threshold  float64
+	action     string
+	disclaimer string
+}
+
+// OnEvent scores the answer of CHAT_COMPLETION directly and wraps the stream of CHAT_COMPLETION_STREAM,
+// a streamed answer is scored once it is complete and the score is sent before the end of the stream.
+// Scoring failures never break the pipeline, the answer is then left unchanged.
+func (p *PluginAnswerGrounding) OnEvent(ctx context.Context,
+	eventType types.EventType, chatManage *types.ChatManage, next func() *PluginError,
+) *PluginError {
+	chatManage.Grounding = nil
+	cfg := p.config.GetConversation().Grounding
+	if cfg == nil || !getBoolOption(chatManage, eventType, "enable", cfg.Enable) || len(chatManage.MergeResult) == 0 {
+		return next()
+	}
+	settings := &groundingSettings{
+		method:     getStringOption(chatManage, eventType, "method", cfg.Method),
+		threshold:  getFloatOption(chatManage, eventType, "threshold", cfg.Threshold),
+		action:     getStringOption(chatManage, eventType, "action", cfg.Action),
+		disclaimer: cfg.Disclaimer,
+	}
+
+	if chatManage.ResponseChan != nil {
+		chatManage.ResponseChan = p.groundStream(ctx, chatManage, settings)
+		return next()
+	}
+	if chatManage.ChatResponse == nil {
+		return next()
+	}
+	answer := chatManage.ChatResponse.Content
+	grounding := p.ground(ctx, chatManage, settings, answer)
+	if grounding == nil {
+		return next()
+	}
+	chatManage.Grounding = grounding
+	switch grounding.Action {
+	case GroundingActionFallback:
+		chatManage.ChatResponse.Content = chatManage.FallbackResponse
+	case GroundingActionDisclaimer:
+		chatManage.ChatResponse.Content += "\n\n" + settings.disclaimer
+	}
+	return next()
+}
+
+// groundStream forwards the answer stream and appends the grounding event to it.
+// The final done response is held back so that the score and the disclaimer arrive before it.
+func (p *PluginAnswerGrounding) groundStream(ctx context.Context,
+	chatManage *types.ChatManage, settings *groundingSettings,
+) <-chan types.StreamResponse {
+	source := chatManage.ResponseChan
+	stream := make(chan types.StreamResponse)
+	hold := settings.action == GroundingActionFallback
+	go func() {
+		defer close(stream)
+		var (
+			answer strings.Builder
+			held   []types.StreamResponse
+			id     string
+		)
+		for resp := range source {
+			id = resp.ID
+			if resp.ResponseType == types.ResponseTypeAnswer {
+				answer.WriteString(resp.Content)
+				if resp.Done {
+					if resp.Content == "" {
+						continue
+					}
+					resp.Done = false
+				}
+			}
+			if hold {
+				held = append(held, resp)
+			} else {
+				stream <- resp
+			}
+		}
+
+		grounding := p.ground(ctx, chatManage, settings, answer.String())
+		if grounding != nil && grounding.Action == GroundingActionFallback {
+			held = []types.StreamResponse{{
+				ID:           id,
+				ResponseType: types.ResponseTypeAnswer,
+				Content:      chatManage.FallbackResponse,
+			}}
+		}
+		for _, resp := range held {
+			stream <- resp
+		}
+		if grounding != nil {
+			stream <- types.StreamResponse{ID: id, ResponseType: types.ResponseTypeGrounding, Grounding: grounding}
+			if grounding.Action == GroundingActionDisclaimer {
+				stream <- types.StreamResponse{
+					ID:           id,
+					ResponseType: types.ResponseTypeAnswer,
+					Content:      "\n\n" + settings.disclaimer,
+				}
+			}
+		}
+		stream <- types.StreamResponse{ID: id, ResponseType: types.ResponseTypeAnswer, Done: true}
+	}()
+	return stream
+}
+
+// ground scores the answer and decides the action, it returns nil if the answer was not scored
+func (p *PluginAnswerGrounding) ground(ctx context.Context,
+	chatManage *types.ChatManage, settings *groundingSettings, answer string,
+) *types.AnswerGrounding {
+	cfg := p.config.GetConversation().Grounding
+	if strings.TrimSpace(answer) == "" || answer == chatManage.FallbackResponse {
+		return nil
+	}
+	sentences := splitAnswerSentences(answer, cfg.MinSentenceLength)
+	if len(sentences) == 0 {
+		return nil
+	}
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	var (
+		scores []float64
+		err    error
+	)
+	switch settings.method {
+	case GroundingMethodEmbedding:
+		scores, err = p.scoreByEmbedding(ctx, chatManage, sentences)
+	default:
+		settings.method = GroundingMethodNLI
+		scores, err = p.scoreByNLI(ctx, chatManage, cfg.Prompt, sentences)
+	}
+	if err != nil {
+		logger.Warnf(ctx, "Failed to check answer grounding, session_id: %s, method: %s, error: %v",
+			chatManage.SessionID, settings.method, err)
+		return nil
+	}
+
+	grounding := &types.AnswerGrounding{Method: settings.method}
+	for i, sentence := range sentences {
+		grounding.Score += scores[i]
+		grounding.Sentences = append(grounding.Sentences, &types.SentenceGrounding{
+			Start: sentence.start,
+			End:   sentence.end,
+			Score: scores[i],
+		})
+	}
+	grounding.Score /= float64(len(sentences))
+	if grounding.Score < settings.threshold {
+		switch settings.action {
+		case GroundingActionFallback:
+			if chatManage.FallbackResponse != "" {
+				grounding.Action = GroundingActionFallback
+			}
+		case GroundingActionDisclaimer:
+			if settings.disclaimer != "" {
+				grounding.Action = GroundingActionDisclaimer
+			}
+		}
+	}
+	logger.Infof(ctx, "Answer grounding checked, session_id: %s, method: %s, sentences: %d, score: %.3f, action: %s",
+		chatManage.SessionID, grounding.Method, len(sentences), grounding.Score, grounding.Action)
+	return grounding
+}
+
+// scoreByNLI asks the chat model to score every sentence against the merge results
+func (p *PluginAnswerGrounding) scoreByNLI(ctx context.Context,
+	chatManage *types.ChatManage, prompt string, sentences []answerSentence,
+) ([]float64, error) {
+	chatModel, err := p.modelService.GetChatModel(ctx, chatManage.ChatModelID)
+	if err != nil {
+		return nil, err
+	}
+
+	var contexts strings.Builder
+	for i, result := range chatManage.MergeResult {
+		contexts.WriteString(citationLabel(i+1) + result.Content + "\n")
+	}
+	references := contexts.String()
+	if budget := chatManage.ContextBudget; budget != nil && budget.Limited() {
+		references = tokenizer.NewTokenizer(budget.Tokenizer).Truncate(references, budget.ContextTokens)
+	}
+	var content strings.Builder
+	content.WriteString("## 参考资料\n" + references + "\n## 回答中的句子\n")
+	for i, sentence := range sentences {
+		content.WriteString(fmt.Sprintf("%d. %s\n", i+1, sentence.text))
+	}
+
+	thinking := false
+	response, err := chatModel.Chat(ctx, []chat.Message{
+		{Role: "system", Content: prompt},
+		{Role: "user", Content: content.String()},
+	}, &chat.ChatOptions{
+		Temperature:         0,
+		MaxCompletionTokens: 16 * len(sentences),
+		Thinking:            &thinking,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseGroundingScores(reg.ReplaceAllString(response.Content, ""), len(sentences))
+}
+
+// scoreByEmbedding scores every sentence by its highest cosine similarity to a merge result,
+// using the embedding model of the primary knowledge base
+func (p *PluginAnswerGrounding) scoreByEmbedding(ctx context.Context,
+	chatManage *types.ChatManage, sentences []answerSentence,
+) ([]float64, error) {
+	kbs := chatManage.GetKnowledgeBases()
+	if len(kbs) == 0 {
+		return nil, fmt.Errorf("no knowledge base to get the embedding model from")
+	}
+	kb, err := p.knowledgeBaseService.GetKnowledgeBaseByID(ctx, kbs[0].KnowledgeBaseID)
+	if err != nil {
+		return nil, err
+	}
+	embedder, err := p.modelService.GetEmbeddingModel(ctx, kb.EmbeddingModelID)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make([]string, 0, len(sentences)+len(chatManage.MergeResult))
+	for _, sentence := range sentences {
+		texts = append(texts, sentence.text)
+	}
+	for _, result := range chatManage.MergeResult {
+		texts = append(texts, result.Content)
+	}
+	embeddings, err := embedder.BatchEmbed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embeddings))
+	}
+
+	scores := make([]float64, len(sentences))
+	for i := range sentences {
+		for _, chunk := range embeddings[len(sentences):] {
+			scores[i] = max(scores[i], utils.CosineSimilarity(embeddings[i], chunk))
+		}
+	}
+	return scores, nil
+}
+
+// answerSentence is a sentence of the answer, offsets count characters of the answer
+type answerSentence struct {
+	start, end int
+	text       string
+}
+
+// splitAnswerSentences splits the answer into sentences with the citation markers removed,
+// sentences shorter than minLength characters are left out
+func splitAnswerSentences(answer string, minLength int) []answerSentence {
+	var (
+		sentences []answerSentence
+		current   strings.Builder
+		start     int
+		offset    int
+		prev      rune
+	)
+	flush := func() {
+		text := strings.TrimSpace(citationMarker.ReplaceAllString(current.String(), ""))
+		if text != "" && utf8.RuneCountInString(text) >= minLength {
+			sentences = append(sentences, answerSentence{start: start, end: offset, text: text})
+		}
+		current.Reset()
+		start = offset
+	}
+	for _, r := range answer {
+		current.WriteRune(r)
+		offset++
+		if isSentenceEnd(r, prev) {
+			flush()
+		}
+		prev = r
+	}
+	flush()
+	return sentences
+}
+
+// parseGroundingScores reads one score per sentence from the NLI response,
+// sentences the model did not score count as unsupported
+func parseGroundingScores(content string, count int) ([]float64, error) {
+	scores := make([]float64, count)
+	found := 0
+	for _, match := range groundingScore.FindAllStringSubmatch(content, -1) {
+		n, _ := strconv.Atoi(match[1])
+		score, err := strconv.ParseFloat(match[2], 64)
+		if err != nil || n < 1 || n > count {
+			continue
+		}
+		scores[n-1] = math.Min(score, 1)
+		found++
+	}
+	if found == 0 {
+		return nil, fmt.Errorf("no sentence score in response: %s", content)
+	}
+	return scores, nil
+}
@@ -0,0 +1,43 @@
+package chatpipline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitAnswerSentences(t *testing.T) {
+	answer := "彗尾背向太阳[1]。好的。彗星的轨道周期为3.5年到数百万年不等[2]\n"
+	expected := []answerSentence{
+		{start: 0, end: 10, text: "彗尾背向太阳。"},
+		{start: 13, end: 36, text: "彗星的轨道周期为3.5年到数百万年不等"},
+	}
+	if got := splitAnswerSentences(answer, 5); !reflect.DeepEqual(got, expected) {
+		t.Errorf("splitAnswerSentences() = %+v, expected %+v", got, expected)
+	}
+}
+
+func TestParseGroundingScores(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		count    int
+		expected []float64
+		wantErr  bool
+	}{
+		{name: "all sentences", content: "1: 1\n2：0.5\n3: 0", count: 3, expected: []float64{1, 0.5, 0}},
+		{name: "missing sentence is unsupported", content: "2: 1\n", count: 2, expected: []float64{0, 1}},
+		{name: "scores are capped", content: "1: 5\n9: 1", count: 1, expected: []float64{1}},
+		{name: "no scores", content: "无法判断", count: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGroundingScores(tt.content, tt.count)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseGroundingScores() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseGroundingScores() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
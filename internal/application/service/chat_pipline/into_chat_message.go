@@ -10,17 +10,30 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Tencent/WeKnora/internal/config"
 	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/models/tokenizer"
 	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
 	secutils "github.com/Tencent/WeKnora/internal/utils"
 )
 
 // PluginIntoChatMessage handles the transformation of search results into chat messages
-type PluginIntoChatMessage struct{}
+// The retrieved context is fitted into the context window of the chat model
+type PluginIntoChatMessage struct {
+	modelService interfaces.ModelService // Model service for resolving the chat model
+	config       *config.Config          // System configuration
+}
 
 // NewPluginIntoChatMessage creates and registers a new PluginIntoChatMessage instance
-func NewPluginIntoChatMessage(eventManager *EventManager) *PluginIntoChatMessage {
-	res := &PluginIntoChatMessage{}
+func NewPluginIntoChatMessage(eventManager *EventManager,
+	modelService interfaces.ModelService, config *config.Config,
+) *PluginIntoChatMessage {
+	res := &PluginIntoChatMessage{
+		modelService: modelService,
+		config:       config,
+	}
 	eventManager.Register(res)
 	return res
 }
@@ -49,7 +62,6 @@ func (p *PluginIntoChatMessage) OnEvent(ctx context.Context,
 
 	// Prepare weekday names for template
 	weekdayName := []string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
-	var userContent bytes.Buffer
 
 	// 验证用户查询的安全性
 	safeQuery, isValid := secutils.ValidateInput(chatManage.Query)
@@ -58,22 +70,182 @@ func (p *PluginIntoChatMessage) OnEvent(ctx context.Context,
 		return ErrTemplateExecute.WithError(fmt.Errorf("用户查询包含非法内容"))
 	}
 
-	// Execute template with context data
-	err = tmpl.Execute(&userContent, map[string]interface{}{
-		"Query":       safeQuery,                                // User's original query
-		"Contexts":    passages,                                 // Extracted passages from search results
-		"CurrentTime": time.Now().Format("2006-01-02 15:04:05"), // Formatted current time
-		"CurrentWeek": weekdayName[time.Now().Weekday()],        // Current weekday in Chinese
-	})
+	// Number the passages and ask the model to cite them with [n] markers
+	citation := p.config.GetConversation().Citation
+	enableCitation := citation != nil && len(passages) > 0 &&
+		getBoolOption(chatManage, eventType, "citation", citation.Enable)
+
+	now := time.Now()
+	render := func(contexts []string) (string, error) {
+		var userContent bytes.Buffer
+		// Execute template with context data
+		err := tmpl.Execute(&userContent, map[string]interface{}{
+			"Query":       safeQuery,                         // User's original query
+			"Contexts":    contexts,                          // Extracted passages from search results
+			"CurrentTime": now.Format("2006-01-02 15:04:05"), // Formatted current time
+			"CurrentWeek": weekdayName[now.Weekday()],        // Current weekday in Chinese
+		})
+		if enableCitation && citation.Prompt != "" {
+			userContent.WriteString("\n\n" + citation.Prompt)
+		}
+		return userContent.String(), err
+	}
+
+	// Every passage is charged for the template around it, including the citation label
+	placeholder := ""
+	if enableCitation {
+		placeholder = citationLabel(len(passages))
+	}
+	budgeter, err := p.newBudgeter(ctx, eventType, chatManage, render, placeholder)
+	if err != nil {
+		return ErrTemplateExecute.WithError(err)
+	}
+	passages = budgeter.fit(passages)
+	chatManage.ContextBudget = budgeter.budget
+	logger.Infof(ctx, "Context budget, tokenizer: %s, context length: %d, context tokens: %d, used tokens: %d, "+
+		"kept: %d, trimmed: %d, summarized: %d, dropped: %d",
+		budgeter.budget.Tokenizer, budgeter.budget.ContextLength, budgeter.budget.ContextTokens,
+		budgeter.budget.UsedTokens, budgeter.budget.KeptChunks, budgeter.budget.TrimmedChunks,
+		budgeter.budget.SummarizedChunks, budgeter.budget.DroppedChunks)
+
+	// Passages of merge results are a prefix of the fitted passages, a summary behind them is not numbered
+	chatManage.CitationReferences = nil
+	if enableCitation {
+		numbered := budgeter.budget.KeptChunks + budgeter.budget.TrimmedChunks
+		for i := 0; i < numbered; i++ {
+			passages[i] = citationLabel(i+1) + passages[i]
+		}
+		chatManage.CitationReferences = chatManage.MergeResult[:numbered]
+	}
+
+	userContent, err := render(passages)
 	if err != nil {
 		return ErrTemplateExecute.WithError(err)
 	}
 
 	// Set formatted content back to chat management
-	chatManage.UserContent = userContent.String()
+	chatManage.UserContent = userContent
 	return next()
 }
 
+// newBudgeter works out the tokens left for the retrieved context: the context length of the chat model
+// minus the completion, the history and the message without any context.
+// The placeholder stands in for a passage to measure the template overhead of every passage
+func (p *PluginIntoChatMessage) newBudgeter(ctx context.Context, eventType types.EventType,
+	chatManage *types.ChatManage, render func(contexts []string) (string, error), placeholder string,
+) (*contextBudgeter, error) {
+	cfg := p.config.GetConversation().ContextBudget
+	if cfg == nil {
+		cfg = &config.ContextBudgetConfig{}
+	}
+	params := chatModelParameters(ctx, p.modelService, chatManage.ChatModelID)
+	tok := tokenizer.NewTokenizer(params.Tokenizer)
+	budget := &types.ContextBudget{
+		Tokenizer:     tok.GetName(),
+		ContextLength: params.ContextLength,
+	}
+	if budget.ContextLength <= 0 {
+		budget.ContextLength = cfg.DefaultContextLength
+	}
+	budget.ContextLength = getIntOption(chatManage, eventType, "context_length", budget.ContextLength)
+
+	switch {
+	case chatManage.SummaryConfig.MaxCompletionTokens > 0:
+		budget.CompletionTokens = chatManage.SummaryConfig.MaxCompletionTokens
+	case chatManage.SummaryConfig.MaxTokens > 0:
+		budget.CompletionTokens = chatManage.SummaryConfig.MaxTokens
+	default:
+		budget.CompletionTokens = cfg.DefaultCompletionTokens
+	}
+	for _, history := range recentHistory(chatManage) {
+		budget.HistoryTokens += tok.CountTokens(history.Query) + tok.CountTokens(history.Answer)
+	}
+
+	empty, err := render(nil)
+	if err != nil {
+		return nil, err
+	}
+	single, err := render([]string{placeholder})
+	if err != nil {
+		return nil, err
+	}
+	budget.PromptTokens = tok.CountTokens(chatManage.SummaryConfig.Prompt) + tok.CountTokens(empty)
+	if budget.Limited() {
+		budget.ContextTokens = max(budget.ContextLength-budget.CompletionTokens-
+			budget.HistoryTokens-budget.PromptTokens, 0)
+	}
+
+	strategy := getStringOption(chatManage, eventType, "overflow_strategy", cfg.OverflowStrategy)
+	return &contextBudgeter{
+		tokenizer:     tok,
+		budget:        budget,
+		strategy:      strategy,
+		minTrimTokens: cfg.MinTrimTokens,
+		overhead:      max(tok.CountTokens(single)-tok.CountTokens(empty), 0),
+		summarize: func(passages []string, maxTokens int) (string, error) {
+			summary, err := p.summarize(ctx, chatManage, cfg, tok, budget, passages, maxTokens)
+			if err != nil {
+				logger.Warnf(ctx, "Failed to summarize %d passages, trimming instead: %v", len(passages), err)
+			}
+			return summary, err
+		},
+	}, nil
+}
+
+// summarize asks the chat model to compress the passages that do not fit into the budget
+// into at most maxTokens tokens, the passages are cut to what fits into the context window
+func (p *PluginIntoChatMessage) summarize(ctx context.Context, chatManage *types.ChatManage,
+	cfg *config.ContextBudgetConfig, tok tokenizer.Tokenizer, budget *types.ContextBudget,
+	passages []string, maxTokens int,
+) (string, error) {
+	if cfg.SummarizePrompt == "" {
+		return "", fmt.Errorf("summarize prompt is not configured")
+	}
+	tmpl, err := template.New("summarizeContext").Parse(cfg.SummarizePrompt)
+	if err != nil {
+		return "", err
+	}
+	var prompt bytes.Buffer
+	if err := tmpl.Execute(&prompt, map[string]interface{}{
+		"Query":     chatManage.Query,
+		"MaxTokens": maxTokens,
+	}); err != nil {
+		return "", err
+	}
+
+	content := strings.Join(passages, "\n\n")
+	if budget.Limited() {
+		limit := budget.ContextLength - maxTokens - tok.CountTokens(prompt.String())
+		if limit <= 0 {
+			return "", fmt.Errorf("no room for the passages in the context window")
+		}
+		content = tok.Truncate(content, limit)
+	}
+
+	chatModel, err := p.modelService.GetChatModel(ctx, chatManage.ChatModelID)
+	if err != nil {
+		return "", err
+	}
+	if cfg.SummarizeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.SummarizeTimeout)
+		defer cancel()
+	}
+	thinking := false
+	response, err := chatModel.Chat(ctx, []chat.Message{
+		{Role: "system", Content: prompt.String()},
+		{Role: "user", Content: content},
+	}, &chat.ChatOptions{
+		Temperature:         0.3,
+		MaxCompletionTokens: maxTokens,
+		Thinking:            &thinking,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(reg.ReplaceAllString(response.Content, "")), nil
+}
+
 // getEnrichedPassageForChat 合并Content和ImageInfo的文本内容，为聊天消息准备
 func getEnrichedPassageForChat(ctx context.Context, result *types.SearchResult) string {
 	// 如果没有图片信息，直接返回内容
@@ -0,0 +1,167 @@
+package chatpipline
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/Tencent/WeKnora/internal/config"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+// PluginQueryExpansion expands the rewritten query into several search queries
+// It asks the chat model for paraphrases of the query (multi-query) and/or a hypothetical answer (HyDE)
+type PluginQueryExpansion struct {
+	modelService interfaces.ModelService // Model service for calling large language models
+	config       *config.Config          // System configuration
+}
+
+// variantPrefix matches list markers the model may put in front of generated queries
+var variantPrefix = regexp.MustCompile(`^\s*(?:[-*•]|\d+[.)、．]|[（(]\d+[）)])\s*`)
+
+// NewPluginQueryExpansion creates a new query expansion plugin instance
+// The plugin must be registered after PluginRewrite so that it expands the rewritten query
+func NewPluginQueryExpansion(eventManager *EventManager,
+	modelService interfaces.ModelService, config *config.Config,
+) *PluginQueryExpansion {
+	res := &PluginQueryExpansion{
+		modelService: modelService,
+		config:       config,
+	}
+	eventManager.Register(res)
+	return res
+}
+
+// ActivationEvents returns the list of event types this plugin responds to
+func (p *PluginQueryExpansion) ActivationEvents() []types.EventType {
+	return []types.EventType{types.REWRITE_QUERY}
+}
+
+// OnEvent generates the expanded queries within the configured latency budget
+// Failures and timeouts never break the pipeline, the search then only uses the rewritten query
+func (p *PluginQueryExpansion) OnEvent(ctx context.Context,
+	eventType types.EventType, chatManage *types.ChatManage, next func() *PluginError,
+) *PluginError {
+	chatManage.ExpandedQueries = nil
+	cfg := p.config.GetConversation().QueryExpansion
+	if cfg == nil || !getBoolOption(chatManage, types.REWRITE_QUERY, "query_expansion", cfg.Enable) {
+		return next()
+	}
+	numVariants := getIntOption(chatManage, types.REWRITE_QUERY, "num_variants", cfg.NumVariants)
+	enableHyDE := getBoolOption(chatManage, types.REWRITE_QUERY, "hyde", cfg.EnableHyDE)
+	if numVariants <= 0 && !enableHyDE {
+		return next()
+	}
+
+	query := chatManage.RewriteQuery
+	if query == "" {
+		query = chatManage.Query
+	}
+	chatModel, err := p.modelService.GetChatModel(ctx, chatManage.ChatModelID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get model, session_id: %s, error: %v", chatManage.SessionID, err)
+		return next()
+	}
+
+	expandCtx := ctx
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		expandCtx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	var (
+		wg         sync.WaitGroup
+		variants   []string
+		hypothesis string
+	)
+	if numVariants > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content, err := p.generate(expandCtx, chatModel, cfg.MultiQueryPrompt, query, numVariants, 0.7, 300)
+			if err != nil {
+				logger.Errorf(ctx, "Failed to generate query variants, session_id: %s, error: %v",
+					chatManage.SessionID, err)
+				return
+			}
+			variants = parseQueryVariants(content, numVariants)
+		}()
+	}
+	if enableHyDE {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content, err := p.generate(expandCtx, chatModel, cfg.HyDEPrompt, query, numVariants, 0.3, 300)
+			if err != nil {
+				logger.Errorf(ctx, "Failed to generate hypothetical answer, session_id: %s, error: %v",
+					chatManage.SessionID, err)
+				return
+			}
+			hypothesis = strings.TrimSpace(content)
+		}()
+	}
+	wg.Wait()
+
+	chatManage.ExpandedQueries = variants
+	if hypothesis != "" {
+		chatManage.ExpandedQueries = append(chatManage.ExpandedQueries, hypothesis)
+	}
+	logger.Infof(ctx, "Expanded query, session_id: %s, variants: %d, hyde: %v",
+		chatManage.SessionID, len(variants), hypothesis != "")
+	return next()
+}
+
+// generate renders the prompt template and calls the chat model with it
+func (p *PluginQueryExpansion) generate(ctx context.Context, chatModel chat.Chat,
+	prompt string, query string, numVariants int, temperature float64, maxTokens int,
+) (string, error) {
+	tmpl, err := template.New("queryExpansion").Parse(prompt)
+	if err != nil {
+		return "", err
+	}
+	var content bytes.Buffer
+	if err := tmpl.Execute(&content, map[string]interface{}{
+		"Query":       query,
+		"NumVariants": numVariants,
+	}); err != nil {
+		return "", err
+	}
+
+	thinking := false
+	response, err := chatModel.Chat(ctx, []chat.Message{
+		{Role: "user", Content: content.String()},
+	}, &chat.ChatOptions{
+		Temperature:         temperature,
+		MaxCompletionTokens: maxTokens,
+		Thinking:            &thinking,
+	})
+	if err != nil {
+		return "", err
+	}
+	return reg.ReplaceAllString(response.Content, ""), nil
+}
+
+// parseQueryVariants splits the model output into at most limit unique queries, one per line
+func parseQueryVariants(content string, limit int) []string {
+	seen := make(map[string]bool)
+	var variants []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(variantPrefix.ReplaceAllString(line, ""))
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		variants = append(variants, line)
+		if len(variants) == limit {
+			break
+		}
+	}
+	return variants
+}
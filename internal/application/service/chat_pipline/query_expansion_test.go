@@ -0,0 +1,87 @@
+package chatpipline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+func TestParseQueryVariants(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "plain lines",
+			content:  "如何重置密码？\n怎样修改登录密码？\n",
+			limit:    3,
+			expected: []string{"如何重置密码？", "怎样修改登录密码？"},
+		},
+		{
+			name:     "list markers removed",
+			content:  "1. first query\n2) second query\n- third query\n（4）fourth query",
+			limit:    4,
+			expected: []string{"first query", "second query", "third query", "fourth query"},
+		},
+		{
+			name:     "duplicates and blank lines skipped",
+			content:  "query\n\n  \n1. query\nother",
+			limit:    3,
+			expected: []string{"query", "other"},
+		},
+		{
+			name:     "limit applied",
+			content:  "a\nb\nc",
+			limit:    2,
+			expected: []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variants := parseQueryVariants(tt.content, tt.limit)
+			if !slices.Equal(variants, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, variants)
+			}
+		})
+	}
+}
+
+// promptRecorder records the last prompt it was asked to complete
+type promptRecorder struct {
+	prompt string
+}
+
+func (r *promptRecorder) Chat(ctx context.Context, messages []chat.Message, opts *chat.ChatOptions) (*types.ChatResponse, error) {
+	r.prompt = messages[len(messages)-1].Content
+	return &types.ChatResponse{Content: "variant"}, nil
+}
+
+func (r *promptRecorder) ChatStream(ctx context.Context,
+	messages []chat.Message, opts *chat.ChatOptions,
+) (<-chan types.StreamResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func (r *promptRecorder) GetModelName() string { return "recorder" }
+
+func (r *promptRecorder) GetModelID() string { return "recorder" }
+
+func TestQueryExpansionPromptNotEscaped(t *testing.T) {
+	model := &promptRecorder{}
+	query := `What's the difference between "A & B" and <C>?`
+	_, err := (&PluginQueryExpansion{}).generate(context.Background(), model,
+		"Rewrite {{.NumVariants}} times: {{.Query}}", query, 2, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Rewrite 2 times: " + query; model.prompt != expected {
+		t.Errorf("expected prompt %q, got %q", expected, model.prompt)
+	}
+}
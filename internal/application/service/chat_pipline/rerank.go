@@ -102,7 +102,8 @@ func (p *PluginRerank) rerank(ctx context.Context,
 	}
 
 	// Log top scores for debugging
-	logger.Infof(ctx, "Reranking completed, filtering results with threshold: %f", chatManage.RerankThreshold)
+	threshold := getFloatOption(chatManage, types.CHUNK_RERANK, "threshold", chatManage.RerankThreshold)
+	logger.Infof(ctx, "Reranking completed, filtering results with threshold: %f", threshold)
 	for i := range min(3, len(rerankResp)) {
 		logger.Infof(ctx, "Top %d score of rerankResp: %f, passages: %s, index: %d",
 			i+1, rerankResp[i].RelevanceScore, rerankResp[i].Document.Text, rerankResp[i].Index,
@@ -112,7 +113,7 @@ func (p *PluginRerank) rerank(ctx context.Context,
 	// Filter results based on threshold with special handling for history matches
 	rankFilter := []rerank.RankResult{}
 	for _, result := range rerankResp {
-		th := chatManage.RerankThreshold
+		th := threshold
 		matchType := chatManage.SearchResult[result.Index].MatchType
 		if matchType == types.MatchTypeHistory {
 			th = math.Max(th-0.1, 0.5) // Lower threshold for history matches
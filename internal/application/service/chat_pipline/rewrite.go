@@ -99,20 +99,20 @@ func (p *PluginRewrite) OnEvent(ctx context.Context,
 	})
 
 	// Limit the number of historical records
-	if len(historyList) > p.config.Conversation.MaxRounds {
-		historyList = historyList[:p.config.Conversation.MaxRounds]
+	if len(historyList) > p.config.GetConversation().MaxRounds {
+		historyList = historyList[:p.config.GetConversation().MaxRounds]
 	}
 
 	// Reverse to chronological order
 	slices.Reverse(historyList)
 	chatManage.History = historyList
 
-	userTmpl, err := template.New("rewriteContent").Parse(p.config.Conversation.RewritePromptUser)
+	userTmpl, err := template.New("rewriteContent").Parse(p.config.GetConversation().RewritePromptUser)
 	if err != nil {
 		logger.Errorf(ctx, "Failed to execute template, session_id: %s, error: %v", chatManage.SessionID, err)
 		return next()
 	}
-	systemTmpl, err := template.New("rewriteContent").Parse(p.config.Conversation.RewritePromptSystem)
+	systemTmpl, err := template.New("rewriteContent").Parse(p.config.GetConversation().RewritePromptSystem)
 	if err != nil {
 		logger.GetLogger(ctx).Errorf("Failed to execute template, session_id: %s, error: %v", chatManage.SessionID, err)
 		return next()
@@ -2,28 +2,33 @@ package chatpipline
 
 import (
 	"context"
+	"slices"
 	"strings"
 
 	"github.com/Tencent/WeKnora/internal/config"
 	"github.com/Tencent/WeKnora/internal/logger"
 	"github.com/Tencent/WeKnora/internal/types"
 	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"golang.org/x/sync/errgroup"
 )
 
 // PluginSearch implements search functionality for chat pipeline
 type PluginSearch struct {
 	knowledgeBaseService interfaces.KnowledgeBaseService
+	knowledgeRepo        interfaces.KnowledgeRepository
 	modelService         interfaces.ModelService
 	config               *config.Config
 }
 
 func NewPluginSearch(eventManager *EventManager,
 	knowledgeBaseService interfaces.KnowledgeBaseService,
+	knowledgeRepo interfaces.KnowledgeRepository,
 	modelService interfaces.ModelService,
 	config *config.Config,
 ) *PluginSearch {
 	res := &PluginSearch{
 		knowledgeBaseService: knowledgeBaseService,
+		knowledgeRepo:        knowledgeRepo,
 		modelService:         modelService,
 		config:               config,
 	}
@@ -40,42 +45,60 @@ func (p *PluginSearch) ActivationEvents() []types.EventType {
 func (p *PluginSearch) OnEvent(ctx context.Context,
 	eventType types.EventType, chatManage *types.ChatManage, next func() *PluginError,
 ) *PluginError {
-	// Prepare search parameters
-	searchParams := types.SearchParams{
-		QueryText:        strings.TrimSpace(chatManage.RewriteQuery),
-		VectorThreshold:  chatManage.VectorThreshold,
-		KeywordThreshold: chatManage.KeywordThreshold,
-		MatchCount:       chatManage.EmbeddingTopK,
+	// Collect queries to search, try processed query as well if different from rewrite query
+	queries := []string{strings.TrimSpace(chatManage.RewriteQuery)}
+	if chatManage.RewriteQuery != chatManage.ProcessedQuery {
+		queries = append(queries, strings.TrimSpace(chatManage.ProcessedQuery))
+	}
+	// Add paraphrases and hypothetical answers generated by query expansion
+	for _, query := range chatManage.ExpandedQueries {
+		query = strings.TrimSpace(query)
+		if query != "" && !slices.Contains(queries, query) {
+			queries = append(queries, query)
+		}
 	}
-	logger.Infof(ctx, "Search parameters: %v", searchParams)
 
-	// Perform initial hybrid search
-	searchResults, err := p.knowledgeBaseService.HybridSearch(ctx, chatManage.KnowledgeBaseID, searchParams)
-	logger.Infof(ctx, "Search results count: %d, error: %v", len(searchResults), err)
-	if err != nil {
+	// Fan out the search to every knowledge base referenced by the session
+	knowledgeBases := chatManage.GetKnowledgeBases()
+	logger.Infof(ctx, "Search knowledge bases: %v, queries: %v", knowledgeBases.IDs(), queries)
+	kbResults := make([][]*types.SearchResult, len(knowledgeBases))
+	g, gctx := errgroup.WithContext(ctx)
+	for i, kb := range knowledgeBases {
+		g.Go(func() error {
+			results, err := p.searchKnowledgeBase(gctx, chatManage, kb, queries)
+			if err != nil {
+				return err
+			}
+			kbResults[i] = results
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
 		return ErrSearch.WithError(err)
 	}
-	chatManage.SearchResult = searchResults
+
+	// Scores from different knowledge bases are not comparable, normalise them before weighting
+	chatManage.SearchResult = nil
+	for i, results := range kbResults {
+		if len(knowledgeBases) > 1 {
+			normalizeScores(results, knowledgeBases[i].GetWeight())
+		}
+		chatManage.SearchResult = append(chatManage.SearchResult, results...)
+	}
 	logger.Infof(ctx, "Search result count: %d", len(chatManage.SearchResult))
 
 	// Add relevant results from chat history
 	historyResult := p.getSearchResultFromHistory(chatManage)
 	if historyResult != nil {
-		logger.Infof(ctx, "Add history result, result count: %d", len(historyResult))
-		chatManage.SearchResult = append(chatManage.SearchResult, historyResult...)
-	}
-
-	// Try search with processed query if different from rewrite query
-	if chatManage.RewriteQuery != chatManage.ProcessedQuery {
-		searchParams.QueryText = strings.TrimSpace(chatManage.ProcessedQuery)
-		searchResults, err = p.knowledgeBaseService.HybridSearch(ctx, chatManage.KnowledgeBaseID, searchParams)
-		logger.Infof(ctx, "Search by processed query: %s, results count: %d, error: %v",
-			searchParams.QueryText, len(searchResults), err,
-		)
+		// Access labels of the referenced knowledge may have changed since the earlier answer
+		knowledges, err := p.knowledgeRepo.GetKnowledgeBatch(ctx,
+			ctx.Value(types.TenantIDContextKey).(uint), resultKnowledgeIDs(historyResult))
 		if err != nil {
 			return ErrSearch.WithError(err)
 		}
-		chatManage.SearchResult = append(chatManage.SearchResult, searchResults...)
+		historyResult = visibleResults(historyResult, knowledges, chatManage.Audience)
+		logger.Infof(ctx, "Add history result, result count: %d", len(historyResult))
+		chatManage.SearchResult = append(chatManage.SearchResult, historyResult...)
 	}
 
 	// remove duplicate results
@@ -94,6 +117,77 @@ func (p *PluginSearch) OnEvent(ctx context.Context,
 	return ErrSearchNothing
 }
 
+// searchKnowledgeBase runs the hybrid search for every query against a single knowledge base
+func (p *PluginSearch) searchKnowledgeBase(ctx context.Context,
+	chatManage *types.ChatManage, kb types.SessionKnowledgeBase, queries []string,
+) ([]*types.SearchResult, error) {
+	searchParams := types.SearchParams{
+		VectorThreshold:  getFloatOption(chatManage, types.CHUNK_SEARCH, "vector_threshold", chatManage.VectorThreshold),
+		KeywordThreshold: getFloatOption(chatManage, types.CHUNK_SEARCH, "keyword_threshold", chatManage.KeywordThreshold),
+		MatchCount:       getIntOption(chatManage, types.CHUNK_SEARCH, "embedding_top_k", chatManage.EmbeddingTopK),
+		Fusion:           chatManage.Fusion,
+		Filters:          chatManage.Filters,
+		Audience:         chatManage.Audience,
+	}
+	if kb.VectorThreshold != nil {
+		searchParams.VectorThreshold = *kb.VectorThreshold
+	}
+	if kb.KeywordThreshold != nil {
+		searchParams.KeywordThreshold = *kb.KeywordThreshold
+	}
+	if kb.EmbeddingTopK > 0 {
+		searchParams.MatchCount = kb.EmbeddingTopK
+	}
+
+	// Search every query variant in parallel
+	queryResults := make([][]*types.SearchResult, len(queries))
+	g, gctx := errgroup.WithContext(ctx)
+	for i, query := range queries {
+		params := searchParams
+		params.QueryText = query
+		g.Go(func() error {
+			logger.Infof(gctx, "Search parameters, knowledge base ID: %s, params: %v", kb.KnowledgeBaseID, params)
+			searchResults, err := p.knowledgeBaseService.HybridSearch(gctx, kb.KnowledgeBaseID, params)
+			logger.Infof(gctx, "Search by query: %s, knowledge base ID: %s, results count: %d, error: %v",
+				query, kb.KnowledgeBaseID, len(searchResults), err,
+			)
+			if err != nil {
+				return err
+			}
+			for _, result := range searchResults {
+				if result.KnowledgeBaseID == "" {
+					result.KnowledgeBaseID = kb.KnowledgeBaseID
+				}
+			}
+			queryResults[i] = searchResults
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return mergeQueryResults(queryResults), nil
+}
+
+// mergeQueryResults dedupes the results of several queries by chunk ID, keeping the best score of each chunk
+func mergeQueryResults(queryResults [][]*types.SearchResult) []*types.SearchResult {
+	index := make(map[string]int)
+	var results []*types.SearchResult
+	for _, searchResults := range queryResults {
+		for _, result := range searchResults {
+			if i, ok := index[result.ID]; ok {
+				if result.Score > results[i].Score {
+					results[i] = result
+				}
+				continue
+			}
+			index[result.ID] = len(results)
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
 // getSearchResultFromHistory retrieves relevant knowledge references from chat history
 func (p *PluginSearch) getSearchResultFromHistory(chatManage *types.ChatManage) []*types.SearchResult {
 	if len(chatManage.History) == 0 {
@@ -112,6 +206,34 @@ func (p *PluginSearch) getSearchResultFromHistory(chatManage *types.ChatManage)
 	return nil
 }
 
+// resultKnowledgeIDs returns the distinct knowledge IDs of the results
+func resultKnowledgeIDs(results []*types.SearchResult) []string {
+	var ids []string
+	for _, result := range results {
+		if !slices.Contains(ids, result.KnowledgeID) {
+			ids = append(ids, result.KnowledgeID)
+		}
+	}
+	return ids
+}
+
+// visibleResults keeps the results of knowledge that still exists and that the audience may retrieve
+func visibleResults(results []*types.SearchResult,
+	knowledges []*types.Knowledge, audience []string,
+) []*types.SearchResult {
+	allowed := make(map[string]bool, len(knowledges))
+	for _, knowledge := range knowledges {
+		allowed[knowledge.ID] = knowledge.AccessLabels.Allows(audience)
+	}
+	var visible []*types.SearchResult
+	for _, result := range results {
+		if allowed[result.KnowledgeID] {
+			visible = append(visible, result)
+		}
+	}
+	return visible
+}
+
 func removeDuplicateResults(results []*types.SearchResult) []*types.SearchResult {
 	seen := make(map[string]bool)
 	var uniqueResults []*types.SearchResult
@@ -123,3 +245,22 @@ func removeDuplicateResults(results []*types.SearchResult) []*types.SearchResult
 	}
 	return uniqueResults
 }
+
+// normalizeScores min-max normalises the scores of one knowledge base into [0, 1] and applies its weight
+func normalizeScores(results []*types.SearchResult, weight float64) {
+	if len(results) == 0 {
+		return
+	}
+	minScore, maxScore := results[0].Score, results[0].Score
+	for _, result := range results[1:] {
+		minScore = min(minScore, result.Score)
+		maxScore = max(maxScore, result.Score)
+	}
+	for _, result := range results {
+		if maxScore == minScore {
+			result.Score = weight
+			continue
+		}
+		result.Score = (result.Score - minScore) / (maxScore - minScore) * weight
+	}
+}
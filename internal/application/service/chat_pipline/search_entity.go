@@ -75,14 +75,8 @@ func (p *PluginSearchEntity) OnEvent(ctx context.Context,
 		return next()
 	}
 
-	knowledgeMap := map[string]*types.Knowledge{}
-	for _, knowledge := range knowledges {
-		knowledgeMap[knowledge.ID] = knowledge
-	}
-	for _, chunk := range chunks {
-		searchResult := chunk2SearchResult(chunk, knowledgeMap[chunk.KnowledgeID])
-		chatManage.SearchResult = append(chatManage.SearchResult, searchResult)
-	}
+	chatManage.SearchResult = append(chatManage.SearchResult,
+		entitySearchResults(chunks, knowledges, chatManage.Audience)...)
 	// remove duplicate results
 	chatManage.SearchResult = removeDuplicateResults(chatManage.SearchResult)
 	if len(chatManage.SearchResult) == 0 {
@@ -114,11 +108,32 @@ func filterSeenChunk(ctx context.Context, graph *types.GraphData, searchResult [
 	return chunkIDs
 }
 
+// entitySearchResults converts the chunks of graph nodes into search results.
+// Graph nodes carry no access labels, so chunks of knowledge the audience may not retrieve are dropped here.
+func entitySearchResults(chunks []*types.Chunk,
+	knowledges []*types.Knowledge, audience []string,
+) []*types.SearchResult {
+	knowledgeMap := map[string]*types.Knowledge{}
+	for _, knowledge := range knowledges {
+		knowledgeMap[knowledge.ID] = knowledge
+	}
+	var results []*types.SearchResult
+	for _, chunk := range chunks {
+		knowledge, ok := knowledgeMap[chunk.KnowledgeID]
+		if !ok || !knowledge.AccessLabels.Allows(audience) {
+			continue
+		}
+		results = append(results, chunk2SearchResult(chunk, knowledge))
+	}
+	return results
+}
+
 func chunk2SearchResult(chunk *types.Chunk, knowledge *types.Knowledge) *types.SearchResult {
 	return &types.SearchResult{
 		ID:                chunk.ID,
 		Content:           chunk.Content,
 		KnowledgeID:       chunk.KnowledgeID,
+		KnowledgeBaseID:   chunk.KnowledgeBaseID,
 		ChunkIndex:        chunk.ChunkIndex,
 		KnowledgeTitle:    knowledge.Title,
 		StartAt:           chunk.StartAt,
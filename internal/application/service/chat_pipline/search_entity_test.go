@@ -0,0 +1,42 @@
+package chatpipline
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+func TestEntitySearchResults(t *testing.T) {
+	knowledges := []*types.Knowledge{
+		{ID: "public", Title: "Handbook"},
+		{ID: "hr", Title: "Salaries", AccessLabels: types.AccessLabels{"hr"}},
+	}
+	chunks := []*types.Chunk{
+		{ID: "c1", KnowledgeID: "public", ChunkType: types.ChunkTypeText},
+		{ID: "c2", KnowledgeID: "hr", ChunkType: types.ChunkTypeText},
+		{ID: "c3", KnowledgeID: "deleted", ChunkType: types.ChunkTypeText},
+	}
+
+	tests := []struct {
+		name     string
+		audience []string
+		expected []string
+	}{
+		{name: "no audience", expected: []string{"c1"}},
+		{name: "other audience", audience: []string{"legal"}, expected: []string{"c1"}},
+		{name: "matching audience", audience: []string{"hr"}, expected: []string{"c1", "c2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, result := range entitySearchResults(chunks, knowledges, tt.audience) {
+				ids = append(ids, result.ID)
+			}
+			if !slices.Equal(ids, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package chatpipline
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+func TestNormalizeScores(t *testing.T) {
+	tests := []struct {
+		name     string
+		scores   []float64
+		weight   float64
+		expected []float64
+	}{
+		{
+			name:     "min-max normalisation",
+			scores:   []float64{0.2, 0.6, 1.0},
+			weight:   1.0,
+			expected: []float64{0, 0.5, 1.0},
+		},
+		{
+			name:     "weight applied after normalisation",
+			scores:   []float64{10, 20},
+			weight:   0.5,
+			expected: []float64{0, 0.5},
+		},
+		{
+			name:     "identical scores",
+			scores:   []float64{0.3, 0.3},
+			weight:   2.0,
+			expected: []float64{2.0, 2.0},
+		},
+		{
+			name:     "empty results",
+			scores:   nil,
+			weight:   1.0,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make([]*types.SearchResult, 0, len(tt.scores))
+			for _, score := range tt.scores {
+				results = append(results, &types.SearchResult{Score: score})
+			}
+			normalizeScores(results, tt.weight)
+			for i, result := range results {
+				if math.Abs(result.Score-tt.expected[i]) > 1e-9 {
+					t.Errorf("result %d: expected score %v, got %v", i, tt.expected[i], result.Score)
+				}
+			}
+		})
+	}
+}
+
+func TestChatManageGetKnowledgeBases(t *testing.T) {
+	t.Run("LegacySingleKnowledgeBase", func(t *testing.T) {
+		chatManage := &types.ChatManage{KnowledgeBaseID: "kb-1"}
+		kbs := chatManage.GetKnowledgeBases()
+		if len(kbs) != 1 || kbs[0].KnowledgeBaseID != "kb-1" || kbs[0].GetWeight() != 1.0 {
+			t.Errorf("Expected single knowledge base kb-1 with weight 1, got %+v", kbs)
+		}
+	})
+
+	t.Run("MultipleKnowledgeBases", func(t *testing.T) {
+		chatManage := &types.ChatManage{
+			KnowledgeBaseID: "kb-1",
+			KnowledgeBases: types.SessionKnowledgeBases{
+				{KnowledgeBaseID: "kb-1", Weight: 0.8},
+				{KnowledgeBaseID: "kb-2"},
+			},
+		}
+		kbs := chatManage.GetKnowledgeBases()
+		if len(kbs) != 2 {
+			t.Fatalf("Expected 2 knowledge bases, got %d", len(kbs))
+		}
+		if kbs[1].GetWeight() != 1.0 {
+			t.Errorf("Expected default weight 1 for kb-2, got %v", kbs[1].GetWeight())
+		}
+	})
+}
+
+func TestMergeQueryResults(t *testing.T) {
+	results := mergeQueryResults([][]*types.SearchResult{
+		{{ID: "c1", Score: 0.5}, {ID: "c2", Score: 0.8}},
+		nil,
+		{{ID: "c1", Score: 0.9}, {ID: "c3", Score: 0.4}},
+	})
+	expected := map[string]float64{"c1": 0.9, "c2": 0.8, "c3": 0.4}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i, id := range []string{"c1", "c2", "c3"} {
+		if results[i].ID != id || results[i].Score != expected[id] {
+			t.Errorf("result %d: expected %s with score %v, got %s with score %v",
+				i, id, expected[id], results[i].ID, results[i].Score)
+		}
+	}
+}
+
+func TestVisibleResults(t *testing.T) {
+	knowledges := []*types.Knowledge{
+		{ID: "public"},
+		{ID: "hr", AccessLabels: types.AccessLabels{"hr"}},
+	}
+	references := []*types.SearchResult{
+		{ID: "c1", KnowledgeID: "public"},
+		{ID: "c2", KnowledgeID: "hr"},
+		{ID: "c3", KnowledgeID: "deleted"},
+	}
+
+	tests := []struct {
+		name     string
+		audience []string
+		expected []string
+	}{
+		{name: "no audience", expected: []string{"c1"}},
+		{name: "other audience", audience: []string{"legal"}, expected: []string{"c1"}},
+		{name: "matching audience", audience: []string{"hr"}, expected: []string{"c1", "c2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, result := range visibleResults(references, knowledges, tt.audience) {
+				ids = append(ids, result.ID)
+			}
+			if !slices.Equal(ids, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, ids)
+			}
+		})
+	}
+}
@@ -43,6 +43,43 @@ func (p *PluginStreamFilter) OnEvent(ctx context.Context,
 		logger.Infof(ctx, "Using no match prefix filter: %s", chatManage.SummaryConfig.NoMatchPrefix)
 	}
 
+	// Parse the citation markers of the answer if the chat message numbered its passages
+	var citations *citationParser
+	if len(chatManage.CitationReferences) > 0 {
+		logger.Infof(ctx, "Parsing citation markers, references: %d", len(chatManage.CitationReferences))
+		citations = newCitationParser(chatManage.CitationReferences)
+	}
+	emit := func(resp types.StreamResponse) {
+		if citations == nil || resp.ResponseType != types.ResponseTypeAnswer {
+			newStream <- resp
+			return
+		}
+		content, cited := citations.feed(resp.Content)
+		if resp.Done {
+			content += citations.flush()
+		}
+		if content != "" || (resp.Done && len(cited) == 0) {
+			newStream <- types.StreamResponse{
+				ID:           resp.ID,
+				ResponseType: resp.ResponseType,
+				Content:      content,
+				Done:         resp.Done && len(cited) == 0,
+			}
+		}
+		if len(cited) == 0 {
+			return
+		}
+		// Citations follow the text they refer to and precede the end of the stream
+		newStream <- types.StreamResponse{
+			ID:           resp.ID,
+			ResponseType: types.ResponseTypeCitation,
+			Citations:    cited,
+		}
+		if resp.Done {
+			newStream <- types.StreamResponse{ID: resp.ID, ResponseType: resp.ResponseType, Done: true}
+		}
+	}
+
 	// Start goroutine to filter the stream
 	go func() {
 		logger.Info(ctx, "Starting stream filter goroutine")
@@ -54,14 +91,14 @@ func (p *PluginStreamFilter) OnEvent(ctx context.Context,
 
 			// Skip filtering if no prefix matching is required
 			if !matchNoMatchBuilderPrefix {
-				newStream <- resp
+				emit(resp)
 				continue
 			}
 
 			// Check if content matches the no-match prefix
 			if !strings.HasPrefix(chatManage.SummaryConfig.NoMatchPrefix, responseBuilder.String()) {
 				resp.Content = responseBuilder.String()
-				newStream <- resp
+				emit(resp)
 				logger.Info(
 					ctx, "Content does not match no-match prefix, passing through, content: ",
 					responseBuilder.String(),
@@ -74,6 +111,11 @@ func (p *PluginStreamFilter) OnEvent(ctx context.Context,
 		if matchNoMatchBuilderPrefix {
 			logger.Info(ctx, "Content matches no-match prefix, using fallback response")
 			newStream <- NewFallback(ctx, chatManage.FallbackResponse)
+		} else if citations != nil {
+			// Emit a marker held back at the end of a stream that ended without a done response
+			if content := citations.flush(); content != "" {
+				newStream <- types.StreamResponse{ResponseType: types.ResponseTypeAnswer, Content: content}
+			}
 		}
 		logger.Info(ctx, "Stream filter completed, closing new stream")
 		close(newStream)
@@ -148,6 +148,21 @@ func (p *PluginTracing) IntoChatMessage(ctx context.Context,
 	)
 	err := next()
 	span.SetAttributes(attribute.Int("generated_content_length", len(chatManage.UserContent)))
+	if budget := chatManage.ContextBudget; budget != nil {
+		span.SetAttributes(
+			attribute.String("budget_tokenizer", budget.Tokenizer),
+			attribute.Int("budget_context_length", budget.ContextLength),
+			attribute.Int("budget_completion_tokens", budget.CompletionTokens),
+			attribute.Int("budget_history_tokens", budget.HistoryTokens),
+			attribute.Int("budget_prompt_tokens", budget.PromptTokens),
+			attribute.Int("budget_context_tokens", budget.ContextTokens),
+			attribute.Int("budget_used_tokens", budget.UsedTokens),
+			attribute.Int("budget_kept_chunks", budget.KeptChunks),
+			attribute.Int("budget_trimmed_chunks", budget.TrimmedChunks),
+			attribute.Int("budget_summarized_chunks", budget.SummarizedChunks),
+			attribute.Int("budget_dropped_chunks", budget.DroppedChunks),
+		)
+	}
 	return err
 }
 
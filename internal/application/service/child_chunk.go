@@ -0,0 +1,74 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/application/service/chunker"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/google/uuid"
+)
+
+// buildChildChunks splits the text chunks longer than the configured child chunk size into child chunks.
+// Child chunks are indexed for precise retrieval, a hit on a child chunk returns its parent text chunk.
+func buildChildChunks(config types.ChunkingConfig, textChunks []*types.Chunk) []*types.Chunk {
+	if config.ChildChunkSize <= 0 {
+		return nil
+	}
+	var childChunks []*types.Chunk
+	for _, parent := range textChunks {
+		runes := []rune(parent.Content)
+		for _, span := range splitChildSpans(runes, config.ChildChunkSize, config.ChildChunkOverlap) {
+			content := string(runes[span[0]:span[1]])
+			if strings.TrimSpace(content) == "" {
+				continue
+			}
+			childChunks = append(childChunks, &types.Chunk{
+				ID:              uuid.New().String(),
+				TenantID:        parent.TenantID,
+				KnowledgeID:     parent.KnowledgeID,
+				KnowledgeBaseID: parent.KnowledgeBaseID,
+				Content:         content,
+				ChunkIndex:      parent.ChunkIndex,
+				IsEnabled:       true,
+				CreatedAt:       time.Now(),
+				UpdatedAt:       time.Now(),
+				StartAt:         parent.StartAt + span[0],
+				EndAt:           parent.StartAt + span[1],
+				ChunkType:       types.ChunkTypeChildText,
+				ParentChunkID:   parent.ID,
+			})
+		}
+	}
+	return childChunks
+}
+
+// splitChildSpans splits text into rune spans of at most size runes which overlap by overlap runes.
+// A span ends at a sentence boundary in the second half of the window when possible.
+// Text that fits in a single span is not split.
+func splitChildSpans(runes []rune, size, overlap int) [][2]int {
+	if size <= 0 || len(runes) <= size {
+		return nil
+	}
+	// Every span is at least half a window long, so the overlap must stay below that to make progress
+	overlap = max(min(overlap, size/2-1), 0)
+
+	var spans [][2]int
+	for start := 0; start < len(runes); {
+		end := min(start+size, len(runes))
+		if end < len(runes) {
+			for i := end; i > start+size/2; i-- {
+				if chunker.IsSentenceBoundary(runes[i-1]) {
+					end = i
+					break
+				}
+			}
+		}
+		spans = append(spans, [2]int{start, end})
+		if end == len(runes) {
+			break
+		}
+		start = end - overlap
+	}
+	return spans
+}
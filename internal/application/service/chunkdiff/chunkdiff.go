@@ -0,0 +1,184 @@
+// Package chunkdiff matches the chunks of a new parse to the stored chunks of a knowledge entry,
+// so unchanged chunks keep their rows and vectors
+package chunkdiff
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"slices"
+
+	"github.com/Tencent/WeKnora/internal/common"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// Diff is the change from the stored chunks of a knowledge entry to the chunks of a new parse.
+// Chunks of the new parse that match a stored chunk take over its ID, so its row and vector are kept.
+type Diff struct {
+	// Create are new chunks without a stored match
+	Create []*types.Chunk
+	// Update are reused chunks whose row changed, e.g. their position or neighbours
+	Update []*types.Chunk
+	// Remove are the IDs of stored chunks without a match
+	Remove []string
+	// Index are the chunks that need to be embedded and indexed
+	Index []*types.Chunk
+	// Unindex are the IDs of chunks whose index entries have to be removed
+	Unindex []string
+	// Reindex are the IDs of reused chunks whose index metadata changed,
+	// their index entries are removed before the chunks are indexed again
+	Reindex []string
+	// Reused is the number of stored chunks kept
+	Reused int
+}
+
+// contentHash identifies the content of a chunk, chunks with the same hash have the same vector.
+// Content is cleaned the way it is stored, so a new chunk hashes like its stored copy.
+func contentHash(chunk *types.Chunk) string {
+	h := sha256.New()
+	h.Write([]byte(chunk.ChunkType))
+	h.Write([]byte{0})
+	h.Write([]byte(common.CleanInvalidUTF8(chunk.Content)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Indexed returns the IDs of the chunks that are indexed, parent chunks are retrieved through their child chunks
+// and FAQ chunks through their questions. Near-duplicates of earlier knowledge are not indexed, nor are their child chunks.
+func Indexed(chunks []*types.Chunk) map[string]bool {
+	parents := make(map[string]bool)
+	duplicates := make(map[string]bool)
+	for _, chunk := range chunks {
+		if chunk.ChunkType.RetrievesParent() {
+			parents[chunk.ParentChunkID] = true
+		}
+		if chunk.Metadata.GetDuplicateOf() != "" {
+			duplicates[chunk.ID] = true
+		}
+	}
+	indexed := make(map[string]bool, len(chunks))
+	for _, chunk := range chunks {
+		if parents[chunk.ID] || duplicates[chunk.ID] {
+			continue
+		}
+		if chunk.ChunkType.RetrievesParent() && duplicates[chunk.ParentChunkID] {
+			continue
+		}
+		indexed[chunk.ID] = true
+	}
+	return indexed
+}
+
+// Chunks matches the chunks of a new parse to the stored chunks of the knowledge by content hash.
+// Matched chunks get the ID of their stored chunk and all references between the new chunks are
+// rewritten, so the stored rows and vectors end up as a fresh import of chunks would leave them.
+func Chunks(stored []*types.Chunk, chunks []*types.Chunk) *Diff {
+	pool := make(map[string][]*types.Chunk, len(stored))
+	for _, chunk := range stored {
+		hash := contentHash(chunk)
+		pool[hash] = append(pool[hash], chunk)
+	}
+	ids := make(map[string]string)
+	matched := make(map[string]*types.Chunk)
+	for _, chunk := range chunks {
+		hash := contentHash(chunk)
+		candidates := pool[hash]
+		if len(candidates) == 0 {
+			continue
+		}
+		pool[hash] = candidates[1:]
+		ids[chunk.ID] = candidates[0].ID
+		matched[candidates[0].ID] = candidates[0]
+	}
+	for _, chunk := range chunks {
+		chunk.ID = remapID(ids, chunk.ID)
+		chunk.PreChunkID = remapID(ids, chunk.PreChunkID)
+		chunk.NextChunkID = remapID(ids, chunk.NextChunkID)
+		chunk.ParentChunkID = remapID(ids, chunk.ParentChunkID)
+		chunk.RelationChunks = remapIDList(ids, chunk.RelationChunks)
+		chunk.IndirectRelationChunks = remapIDList(ids, chunk.IndirectRelationChunks)
+	}
+
+	storedIndexed := Indexed(stored)
+	indexed := Indexed(chunks)
+	diff := &Diff{Reused: len(matched)}
+	for _, chunk := range chunks {
+		previous, ok := matched[chunk.ID]
+		if !ok {
+			diff.Create = append(diff.Create, chunk)
+			if indexed[chunk.ID] {
+				diff.Index = append(diff.Index, chunk)
+			}
+			continue
+		}
+		chunk.CreatedAt = previous.CreatedAt
+		if rowChanged(previous, chunk) {
+			diff.Update = append(diff.Update, chunk)
+		}
+		switch {
+		case indexed[chunk.ID] && !storedIndexed[chunk.ID]:
+			diff.Index = append(diff.Index, chunk)
+		case !indexed[chunk.ID] && storedIndexed[chunk.ID]:
+			diff.Unindex = append(diff.Unindex, chunk.ID)
+		case indexed[chunk.ID] && indexChanged(previous, chunk):
+			diff.Index = append(diff.Index, chunk)
+			diff.Reindex = append(diff.Reindex, chunk.ID)
+		}
+	}
+	for _, chunk := range stored {
+		if _, ok := matched[chunk.ID]; ok {
+			continue
+		}
+		diff.Remove = append(diff.Remove, chunk.ID)
+		if storedIndexed[chunk.ID] {
+			diff.Unindex = append(diff.Unindex, chunk.ID)
+		}
+	}
+	return diff
+}
+
+// rowChanged reports whether a reused chunk has to be saved, its content is known to be the same
+func rowChanged(previous, chunk *types.Chunk) bool {
+	return previous.ChunkIndex != chunk.ChunkIndex ||
+		previous.StartAt != chunk.StartAt ||
+		previous.EndAt != chunk.EndAt ||
+		previous.PreChunkID != chunk.PreChunkID ||
+		previous.NextChunkID != chunk.NextChunkID ||
+		previous.ParentChunkID != chunk.ParentChunkID ||
+		previous.ImageInfo != chunk.ImageInfo ||
+		previous.IsEnabled != chunk.IsEnabled ||
+		previous.SimHash != chunk.SimHash ||
+		!reflect.DeepEqual(previous.Metadata, chunk.Metadata) ||
+		!bytes.Equal(previous.RelationChunks, chunk.RelationChunks) ||
+		!bytes.Equal(previous.IndirectRelationChunks, chunk.IndirectRelationChunks)
+}
+
+// indexChanged reports whether the index entry of a reused chunk is outdated, its content is known to be the same
+func indexChanged(previous, chunk *types.Chunk) bool {
+	return !reflect.DeepEqual(previous.Metadata.IndexMetadata(), chunk.Metadata.IndexMetadata()) ||
+		!slices.Equal(previous.Metadata.GetAccessLabels(), chunk.Metadata.GetAccessLabels())
+}
+
+func remapID(ids map[string]string, id string) string {
+	if mapped, ok := ids[id]; ok {
+		return mapped
+	}
+	return id
+}
+
+// remapIDList rewrites a JSON list of chunk IDs, other values are returned unchanged
+func remapIDList(ids map[string]string, value types.JSON) types.JSON {
+	var list []string
+	if len(value) == 0 || json.Unmarshal(value, &list) != nil {
+		return value
+	}
+	for i, id := range list {
+		list[i] = remapID(ids, id)
+	}
+	remapped, err := json.Marshal(list)
+	if err != nil {
+		return value
+	}
+	return types.JSON(remapped)
+}
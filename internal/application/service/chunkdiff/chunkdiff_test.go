@@ -0,0 +1,249 @@
+package chunkdiff
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+func textChunk(id, content string) *types.Chunk {
+	return &types.Chunk{ID: id, Content: content, ChunkType: types.ChunkTypeText, IsEnabled: true}
+}
+
+func childChunk(id, content, parentID string) *types.Chunk {
+	return &types.Chunk{
+		ID: id, Content: content, ChunkType: types.ChunkTypeChildText, ParentChunkID: parentID, IsEnabled: true,
+	}
+}
+
+func labeled(chunk *types.Chunk, labels ...string) *types.Chunk {
+	chunk.Metadata = &types.ChunkMetadata{AccessLabels: labels}
+	return chunk
+}
+
+func duplicate(chunk *types.Chunk, knowledgeID string) *types.Chunk {
+	chunk.Metadata = &types.ChunkMetadata{DuplicateOf: knowledgeID}
+	return chunk
+}
+
+// link orders chunks as a parse would, by index and with links to their neighbours
+func link(chunks ...*types.Chunk) []*types.Chunk {
+	for i, chunk := range chunks {
+		chunk.ChunkIndex = i
+		if i > 0 {
+			chunk.PreChunkID = chunks[i-1].ID
+		}
+		if i < len(chunks)-1 {
+			chunk.NextChunkID = chunks[i+1].ID
+		}
+	}
+	return chunks
+}
+
+func chunkIDs(chunks []*types.Chunk) []string {
+	var ids []string
+	for _, chunk := range chunks {
+		ids = append(ids, chunk.ID)
+	}
+	return ids
+}
+
+func TestIndexed(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []*types.Chunk
+		want   []string
+	}{
+		{
+			name:   "text chunks are indexed",
+			chunks: []*types.Chunk{textChunk("a", "A"), textChunk("b", "B")},
+			want:   []string{"a", "b"},
+		},
+		{
+			name:   "parent chunks are retrieved through their child chunks",
+			chunks: []*types.Chunk{textChunk("p", "P"), childChunk("c1", "C1", "p"), childChunk("c2", "C2", "p")},
+			want:   []string{"c1", "c2"},
+		},
+		{
+			name: "FAQ chunks are retrieved through their questions",
+			chunks: []*types.Chunk{
+				{ID: "faq", Content: "Q A", ChunkType: types.ChunkTypeFAQ},
+				{ID: "q", Content: "Q", ChunkType: types.ChunkTypeFAQQuestion, ParentChunkID: "faq"},
+			},
+			want: []string{"q"},
+		},
+		{
+			name: "near-duplicates and their child chunks are not indexed",
+			chunks: []*types.Chunk{
+				duplicate(textChunk("p", "P"), "k1"), childChunk("c", "C", "p"),
+				duplicate(textChunk("d", "D"), "k1"), textChunk("a", "A"),
+			},
+			want: []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for id := range Indexed(tt.chunks) {
+				got = append(got, id)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Indexed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowChanged(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*types.Chunk)
+		want   bool
+	}{
+		{name: "unchanged", change: func(*types.Chunk) {}},
+		{name: "creation time is kept", change: func(c *types.Chunk) { c.CreatedAt = c.CreatedAt.Add(1) }},
+		{name: "moved", change: func(c *types.Chunk) { c.ChunkIndex, c.StartAt, c.EndAt = 3, 30, 40 }, want: true},
+		{name: "new neighbour", change: func(c *types.Chunk) { c.NextChunkID = "x" }, want: true},
+		{name: "new parent", change: func(c *types.Chunk) { c.ParentChunkID = "x" }, want: true},
+		{name: "new relations", change: func(c *types.Chunk) { c.RelationChunks = types.JSON(`["x"]`) }, want: true},
+		{name: "new metadata", change: func(c *types.Chunk) { labeled(c, "hr") }, want: true},
+		{name: "new image", change: func(c *types.Chunk) { c.ImageInfo = `[{"url":"x"}]` }, want: true},
+		{name: "disabled", change: func(c *types.Chunk) { c.IsEnabled = false }, want: true},
+		{name: "new SimHash", change: func(c *types.Chunk) { c.SimHash = 42 }, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := link(textChunk("a", "A"), textChunk("b", "B"))[0]
+			chunk := *previous
+			tt.change(&chunk)
+			if got := rowChanged(previous, &chunk); got != tt.want {
+				t.Errorf("rowChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunks(t *testing.T) {
+	type summary struct {
+		Create, Update, Remove, Index, Unindex, Reindex []string
+		Reused                                          int
+	}
+	tests := []struct {
+		name   string
+		stored []*types.Chunk
+		chunks []*types.Chunk
+		want   summary
+	}{
+		{
+			name:   "unchanged chunks are all reused",
+			stored: link(textChunk("s1", "A"), textChunk("s2", "B")),
+			chunks: link(textChunk("n1", "A"), textChunk("n2", "B")),
+			want:   summary{Reused: 2},
+		},
+		{
+			name:   "modified chunk replaces its stored chunk",
+			stored: link(textChunk("s1", "A"), textChunk("s2", "B")),
+			chunks: link(textChunk("n1", "A"), textChunk("n2", "B2")),
+			want: summary{
+				Create: []string{"n2"}, Update: []string{"s1"}, Remove: []string{"s2"},
+				Index: []string{"n2"}, Unindex: []string{"s2"}, Reused: 1,
+			},
+		},
+		{
+			name:   "reordered chunks are updated, not embedded",
+			stored: link(textChunk("s1", "A"), textChunk("s2", "B")),
+			chunks: link(textChunk("n1", "B"), textChunk("n2", "A")),
+			want:   summary{Update: []string{"s2", "s1"}, Reused: 2},
+		},
+		{
+			name:   "removed chunk is deleted with its index entry",
+			stored: link(textChunk("s1", "A"), textChunk("s2", "B"), textChunk("s3", "C")),
+			chunks: link(textChunk("n1", "A"), textChunk("n2", "C")),
+			want: summary{
+				Update: []string{"s1", "s3"}, Remove: []string{"s2"}, Unindex: []string{"s2"}, Reused: 2,
+			},
+		},
+		{
+			name:   "duplicate content matches stored chunks in order",
+			stored: link(textChunk("s1", "A"), textChunk("s2", "A")),
+			chunks: link(textChunk("n1", "A"), textChunk("n2", "A"), textChunk("n3", "A")),
+			want: summary{
+				Create: []string{"n3"}, Update: []string{"s2"}, Index: []string{"n3"}, Reused: 2,
+			},
+		},
+		{
+			name:   "chunk split into child chunks leaves the index",
+			stored: []*types.Chunk{textChunk("s1", "P")},
+			chunks: []*types.Chunk{textChunk("n1", "P"), childChunk("n2", "C", "n1")},
+			want: summary{
+				Create: []string{"n2"}, Index: []string{"n2"}, Unindex: []string{"s1"}, Reused: 1,
+			},
+		},
+		{
+			name:   "parent chunk without child chunks joins the index",
+			stored: []*types.Chunk{textChunk("s1", "P"), childChunk("s2", "C", "s1")},
+			chunks: []*types.Chunk{textChunk("n1", "P")},
+			want: summary{
+				Remove: []string{"s2"}, Index: []string{"s1"}, Unindex: []string{"s2"}, Reused: 1,
+			},
+		},
+		{
+			name:   "new access labels are indexed again",
+			stored: []*types.Chunk{labeled(textChunk("s1", "A"), "hr")},
+			chunks: []*types.Chunk{labeled(textChunk("n1", "A"), "legal")},
+			want: summary{
+				Update: []string{"s1"}, Index: []string{"s1"}, Reindex: []string{"s1"}, Reused: 1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := Chunks(tt.stored, tt.chunks)
+			got := summary{
+				Create:  chunkIDs(diff.Create),
+				Update:  chunkIDs(diff.Update),
+				Remove:  diff.Remove,
+				Index:   chunkIDs(diff.Index),
+				Unindex: diff.Unindex,
+				Reindex: diff.Reindex,
+				Reused:  diff.Reused,
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Chunks() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunks_RemapsIDs(t *testing.T) {
+	stored := []*types.Chunk{textChunk("s1", "P"), childChunk("s2", "C1", "s1")}
+	chunks := []*types.Chunk{
+		textChunk("n1", "P"), childChunk("n2", "C1", "n1"), childChunk("n3", "C2", "n1"), textChunk("n4", "E"),
+	}
+	chunks[1].NextChunkID = "n3"
+	chunks[2].PreChunkID = "n2"
+	chunks[3].RelationChunks = types.JSON(`["n1","n3"]`)
+	chunks[3].IndirectRelationChunks = types.JSON(`["n2"]`)
+	chunks[0].RelationChunks = types.JSON(`{"not":"a list"}`)
+	Chunks(stored, chunks)
+
+	type links struct{ ID, Pre, Next, Parent, Relation, Indirect string }
+	want := []links{
+		{ID: "s1", Relation: `{"not":"a list"}`},
+		{ID: "s2", Next: "n3", Parent: "s1"},
+		{ID: "n3", Pre: "s2", Parent: "s1"},
+		{ID: "n4", Relation: `["s1","n3"]`, Indirect: `["s2"]`},
+	}
+	for i, chunk := range chunks {
+		got := links{
+			ID: chunk.ID, Pre: chunk.PreChunkID, Next: chunk.NextChunkID, Parent: chunk.ParentChunkID,
+			Relation: string(chunk.RelationChunks), Indirect: string(chunk.IndirectRelationChunks),
+		}
+		if got != want[i] {
+			t.Errorf("chunk %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package chunker re-splits the chunks returned by docreader with the chunking strategy of a knowledge base.
+// Docreader splits recursively by the configured separators; the other strategies join its chunks back
+// into the parsed text and split that text again, so documents, passages and URLs are chunked alike.
+package chunker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/services/docreader/src/proto"
+)
+
+// defaultChunkSize is the chunk size used when the knowledge base configures none
+const defaultChunkSize = 512
+
+// defaultSeparators are the separators oversized spans are split at when the knowledge base configures none
+var defaultSeparators = []string{"\n\n", "\n", "。", "！", "？", "；", ". ", "! ", "? ", "; "}
+
+// Embedder embeds the sentences compared by the semantic strategy
+type Embedder interface {
+	BatchEmbed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Chunk is a chunk of the parsed text with the metadata its strategy records
+type Chunk struct {
+	*proto.Chunk
+	// Metadata of the chunk, nil if the strategy records none
+	Metadata *types.ChunkMetadata
+}
+
+// span is a part of the parsed text in rune offsets, with the section path of the Markdown strategy
+type span struct {
+	start       int
+	end         int
+	sectionPath []string
+}
+
+// document is the parsed text rebuilt from the docreader chunks
+type document struct {
+	text []rune
+	// base is the offset of the text in the source document
+	base int
+	// images are the images of the chunks with positions relative to the text
+	images []*proto.Image
+}
+
+// Split splits the docreader chunks with the strategy of the chunking config.
+// The recursive strategy keeps the docreader chunks as they are.
+func Split(ctx context.Context,
+	config types.ChunkingConfig, chunks []*proto.Chunk, embedder Embedder,
+) ([]*Chunk, error) {
+	strategy := config.GetStrategy()
+	if strategy == types.ChunkingStrategyRecursive || len(chunks) == 0 {
+		result := make([]*Chunk, 0, len(chunks))
+		for _, chunk := range chunks {
+			result = append(result, &Chunk{Chunk: chunk})
+		}
+		return result, nil
+	}
+
+	doc := joinChunks(chunks)
+	size := config.ChunkSize
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	separators := config.Separators
+	if len(separators) == 0 {
+		separators = defaultSeparators
+	}
+
+	var spans []span
+	switch strategy {
+	case types.ChunkingStrategyMarkdown:
+		spans = splitMarkdown(doc.text, size, config.ChunkOverlap, separators)
+	case types.ChunkingStrategySentenceWindow:
+		spans = splitSentenceWindows(doc.text, splitSentences(doc.text, 0, len(doc.text)), size, config.ChunkOverlap)
+	case types.ChunkingStrategySemantic:
+		var err error
+		spans, err = splitSemantic(ctx, embedder, doc.text, size, config.GetBreakpointPercentile())
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown chunking strategy: %s", strategy)
+	}
+	return doc.build(spans), nil
+}
+
+// SplitText splits parsed text into chunks the way docreader does, recursively by the separators
+// with the chunk size and overlap of the chunking config. Positions are rune offsets into the text.
+func SplitText(config types.ChunkingConfig, text string) []*proto.Chunk {
+	runes := []rune(text)
+	size := config.ChunkSize
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	separators := config.Separators
+	if len(separators) == 0 {
+		separators = defaultSeparators
+	}
+	var chunks []*proto.Chunk
+	for _, s := range splitRecursive(runes, 0, len(runes), size, config.ChunkOverlap, separators) {
+		content := string(runes[s[0]:s[1]])
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		chunks = append(chunks, &proto.Chunk{
+			Content: content,
+			Seq:     int32(len(chunks)),
+			Start:   int32(s[0]),
+			End:     int32(s[1]),
+		})
+	}
+	return chunks
+}
+
+// joinChunks rebuilds the parsed text from the docreader chunks. The content of a docreader chunk is
+// the text between its start and end, so the overlap with the previous chunk is dropped by position.
+// Chunks whose positions do not match their content, which docreader does not produce, are appended as they are.
+func joinChunks(chunks []*proto.Chunk) *document {
+	doc := &document{base: int(chunks[0].Start)}
+	seen := make(map[string]bool)
+	for _, chunk := range chunks {
+		runes := []rune(chunk.Content)
+		offset := len(doc.text)
+		textEnd := doc.base + len(doc.text)
+		start, end := int(chunk.Start), int(chunk.End)
+		if end-start == len(runes) && start >= doc.base && start <= textEnd {
+			offset = start - doc.base
+			if end > textEnd {
+				doc.text = append(doc.text, runes[textEnd-start:]...)
+			}
+		} else {
+			doc.text = append(doc.text, runes...)
+		}
+		for _, image := range chunk.Images {
+			imageStart, imageEnd := offset+int(image.Start), offset+int(image.End)
+			// Images in the overlap of two chunks are listed by both
+			key := fmt.Sprintf("%s@%d", image.Url, imageStart)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			doc.images = append(doc.images, copyImage(image, imageStart, imageEnd))
+		}
+	}
+	return doc
+}
+
+// build turns the spans into chunks. Each image goes to the chunks that contain all of it,
+// or to the chunk its start falls into if it was cut.
+func (d *document) build(spans []span) []*Chunk {
+	var chunks []*Chunk
+	for _, s := range spans {
+		content := string(d.text[s.start:s.end])
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		chunk := &Chunk{Chunk: &proto.Chunk{
+			Content: content,
+			Seq:     int32(len(chunks)),
+			Start:   int32(d.base + s.start),
+			End:     int32(d.base + s.end),
+		}}
+		if len(s.sectionPath) > 0 {
+			chunk.Metadata = &types.ChunkMetadata{SectionPath: s.sectionPath}
+		}
+		chunks = append(chunks, chunk)
+	}
+	for _, image := range d.images {
+		placed := false
+		for _, chunk := range chunks {
+			start := int(chunk.Start) - d.base
+			if int(image.Start) >= start && int(image.End) <= int(chunk.End)-d.base {
+				chunk.Images = append(chunk.Images, copyImage(image, int(image.Start)-start, int(image.End)-start))
+				placed = true
+			}
+		}
+		if placed {
+			continue
+		}
+		for _, chunk := range chunks {
+			start := int(chunk.Start) - d.base
+			if int(image.Start) >= start && int(image.Start) < int(chunk.End)-d.base {
+				chunk.Images = append(chunk.Images, copyImage(image, int(image.Start)-start, int(image.End)-start))
+				break
+			}
+		}
+	}
+	return chunks
+}
+
+// copyImage copies an image to new positions
+func copyImage(image *proto.Image, start, end int) *proto.Image {
+	return &proto.Image{
+		Url:         image.Url,
+		Caption:     image.Caption,
+		OcrText:     image.OcrText,
+		OriginalUrl: image.OriginalUrl,
+		Start:       int32(start),
+		End:         int32(end),
+	}
+}
+
+// splitRecursive splits text[start:end] into spans of at most size runes which overlap by overlap runes.
+// A span ends after the first separator, in order, found in the second half of its window,
+// or at the window end if there is none. Text that fits in a single span is not split.
+func splitRecursive(text []rune, start, end, size, overlap int, separators []string) [][2]int {
+	if end-start <= size {
+		return [][2]int{{start, end}}
+	}
+	// Every span is at least half a window long, so the overlap must stay below that to make progress
+	overlap = max(min(overlap, size/2-1), 0)
+
+	var spans [][2]int
+	for spanStart := start; spanStart < end; {
+		spanEnd := min(spanStart+size, end)
+		if spanEnd < end {
+			window := string(text[spanStart+size/2 : spanEnd])
+			for _, separator := range separators {
+				if i := strings.LastIndex(window, separator); i >= 0 {
+					spanEnd = spanStart + size/2 + len([]rune(window[:i+len(separator)]))
+					break
+				}
+			}
+		}
+		spans = append(spans, [2]int{spanStart, spanEnd})
+		if spanEnd == end {
+			break
+		}
+		spanStart = spanEnd - overlap
+	}
+	return spans
+}
@@ -0,0 +1,152 @@
+package chunker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/services/docreader/src/proto"
+)
+
+// docreaderChunks splits text like docreader: fixed windows with overlap, positions in runes
+func docreaderChunks(text string, size, overlap int) []*proto.Chunk {
+	runes := []rune(text)
+	var chunks []*proto.Chunk
+	for start := 0; start < len(runes); start += size - overlap {
+		end := min(start+size, len(runes))
+		chunks = append(chunks, &proto.Chunk{
+			Content: string(runes[start:end]),
+			Seq:     int32(len(chunks)),
+			Start:   int32(start),
+			End:     int32(end),
+		})
+		if end == len(runes) {
+			break
+		}
+	}
+	return chunks
+}
+
+func TestJoinChunks(t *testing.T) {
+	text := "第一段内容。Second paragraph here.\n\nThird part of the text."
+	tests := []struct {
+		name   string
+		chunks []*proto.Chunk
+		want   string
+	}{
+		{
+			name:   "overlapping chunks",
+			chunks: docreaderChunks(text, 10, 3),
+			want:   text,
+		},
+		{
+			name:   "adjacent chunks",
+			chunks: docreaderChunks(text, 10, 0),
+			want:   text,
+		},
+		{
+			name: "positions not matching content",
+			chunks: []*proto.Chunk{
+				{Content: "abc", Start: 0, End: 10},
+				{Content: "def", Start: 10, End: 20},
+			},
+			want: "abcdef",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(joinChunks(tt.chunks).text); got != tt.want {
+				t.Errorf("joinChunks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitRecursiveStrategyKeepsChunks(t *testing.T) {
+	chunks := docreaderChunks("some text that docreader split", 10, 2)
+	got, err := Split(context.Background(), types.ChunkingConfig{ChunkSize: 100}, chunks, nil)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	if len(got) != len(chunks) {
+		t.Fatalf("Split() returned %d chunks, want %d", len(got), len(chunks))
+	}
+	for i := range chunks {
+		if got[i].Chunk != chunks[i] || got[i].Metadata != nil {
+			t.Errorf("chunk %d was changed", i)
+		}
+	}
+}
+
+func TestSplitPlacesImages(t *testing.T) {
+	text := "# A\n\nIntro ![x](a.png) text.\n\n# B\n\nMore ![y](b.png) text."
+	runes := []rune(text)
+	aStart, bStart := strings.Index(text, "![x]"), strings.Index(text, "![y]")
+	// Docreader lists an image in the overlap of two chunks in both, relative to each chunk
+	chunks := []*proto.Chunk{
+		{Content: string(runes[:30]), Seq: 0, Start: 0, End: 30, Images: []*proto.Image{
+			{Url: "a.png", Start: int32(aStart), End: int32(aStart + 11)},
+		}},
+		{Content: string(runes[10:]), Seq: 1, Start: 10, End: int32(len(runes)), Images: []*proto.Image{
+			{Url: "a.png", Start: int32(aStart - 10), End: int32(aStart + 1)},
+			{Url: "b.png", Start: int32(bStart - 10), End: int32(bStart + 1)},
+		}},
+	}
+	got, err := Split(context.Background(),
+		types.ChunkingConfig{ChunkSize: 100, Strategy: types.ChunkingStrategyMarkdown}, chunks, nil)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Split() returned %d chunks, want 2", len(got))
+	}
+	for i, url := range []string{"a.png", "b.png"} {
+		markdown := map[string]string{"a.png": "![x](a.png)", "b.png": "![y](b.png)"}[url]
+		chunk := got[i]
+		if len(chunk.Images) != 1 || chunk.Images[0].Url != url {
+			t.Fatalf("chunk %d images = %v, want %s", i, chunk.Images, url)
+		}
+		image := chunk.Images[0]
+		if content := []rune(chunk.Content); string(content[image.Start:image.End]) != markdown {
+			t.Errorf("chunk %d image position %d-%d points at %q", i, image.Start, image.End,
+				string(content[image.Start:image.End]))
+		}
+	}
+}
+
+func TestSplitRecursive(t *testing.T) {
+	text := []rune("aaaa. bbbb. cccc. dddd.")
+	spans := splitRecursive(text, 0, len(text), 12, 2, []string{". "})
+	var got []string
+	for _, s := range spans {
+		got = append(got, string(text[s[0]:s[1]]))
+	}
+	want := []string{"aaaa. bbbb. ", ". cccc. ", ". dddd."}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("splitRecursive() = %q, want %q", got, want)
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	text := strings.Repeat("第一段内容。Second paragraph here.\n\n", 20)
+	config := types.ChunkingConfig{ChunkSize: 50, ChunkOverlap: 10}
+	chunks := SplitText(config, text)
+	if len(chunks) < 2 {
+		t.Fatalf("expected the text to be split, got %d chunks", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if int(chunk.Seq) != i {
+			t.Errorf("chunk %d has seq %d", i, chunk.Seq)
+		}
+		if n := len([]rune(chunk.Content)); n > config.ChunkSize || n != int(chunk.End-chunk.Start) {
+			t.Errorf("chunk %d has %d runes at [%d, %d)", i, n, chunk.Start, chunk.End)
+		}
+	}
+	if got := string(joinChunks(chunks).text); got != text {
+		t.Errorf("joined chunks do not rebuild the text: %q", got)
+	}
+	if chunks := SplitText(config, " \n "); len(chunks) != 0 {
+		t.Errorf("expected no chunks for blank text, got %d", len(chunks))
+	}
+}
@@ -0,0 +1,77 @@
+package chunker
+
+import (
+	"regexp"
+	"strings"
+)
+
+// headingPattern matches an ATX heading, the closing hashes are not part of the title
+var headingPattern = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*$`)
+
+// fencePattern matches the opening or closing line of a fenced code block
+var fencePattern = regexp.MustCompile("^ {0,3}(```|~~~)")
+
+// section is a heading with the text up to the next heading
+type section struct {
+	start int
+	// bodyStart is the offset after the heading line
+	bodyStart int
+	path      []string
+}
+
+// splitMarkdown splits the text at its Markdown headings. Each chunk keeps the headings of the sections
+// containing it as its section path. Sections longer than size are split recursively by the separators,
+// sections with nothing but their heading are left out since their heading is on the path of their subsections.
+func splitMarkdown(text []rune, size, overlap int, separators []string) []span {
+	type heading struct {
+		level int
+		title string
+	}
+	var stack []heading
+	sections := []section{{}}
+	fence := ""
+	for lineStart := 0; lineStart < len(text); {
+		lineEnd := lineStart
+		for lineEnd < len(text) && text[lineEnd] != '\n' {
+			lineEnd++
+		}
+		line := string(text[lineStart:lineEnd])
+		next := min(lineEnd+1, len(text))
+
+		if match := fencePattern.FindStringSubmatch(line); match != nil {
+			switch {
+			case fence == "":
+				fence = match[1]
+			case fence == match[1]:
+				fence = ""
+			}
+		} else if match := headingPattern.FindStringSubmatch(line); match != nil && fence == "" {
+			level := len(match[1])
+			for len(stack) > 0 && stack[len(stack)-1].level >= level {
+				stack = stack[:len(stack)-1]
+			}
+			stack = append(stack, heading{level: level, title: strings.TrimSpace(match[2])})
+			path := make([]string, 0, len(stack))
+			for _, h := range stack {
+				path = append(path, h.title)
+			}
+			sections = append(sections, section{start: lineStart, bodyStart: next, path: path})
+		}
+		lineStart = next
+	}
+
+	var spans []span
+	for i, s := range sections {
+		end := len(text)
+		if i+1 < len(sections) {
+			end = sections[i+1].start
+		}
+		if strings.TrimSpace(string(text[s.bodyStart:end])) == "" {
+			continue
+		}
+		for _, part := range splitRecursive(text, s.start, end, size, overlap, separators) {
+			spans = append(spans, span{start: part[0], end: part[1], sectionPath: s.path})
+		}
+	}
+	return spans
+}
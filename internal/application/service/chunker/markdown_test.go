@@ -0,0 +1,60 @@
+package chunker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitMarkdown(t *testing.T) {
+	type chunk struct {
+		content string
+		path    []string
+	}
+	tests := []struct {
+		name string
+		text string
+		size int
+		want []chunk
+	}{
+		{
+			name: "nested headings",
+			text: "Preface\n# Guide\n## Install\nRun it.\n## Usage ##\nUse it.\n# FAQ\nAsk.",
+			size: 100,
+			want: []chunk{
+				{content: "Preface\n"},
+				{content: "## Install\nRun it.\n", path: []string{"Guide", "Install"}},
+				{content: "## Usage ##\nUse it.\n", path: []string{"Guide", "Usage"}},
+				{content: "# FAQ\nAsk.", path: []string{"FAQ"}},
+			},
+		},
+		{
+			name: "headings in code blocks",
+			text: "# Shell\n```\n# comment\n```\ntext",
+			size: 100,
+			want: []chunk{
+				{content: "# Shell\n```\n# comment\n```\ntext", path: []string{"Shell"}},
+			},
+		},
+		{
+			name: "long section",
+			text: "# Long\nfirst line\nsecond line\n",
+			size: 20,
+			want: []chunk{
+				{content: "# Long\nfirst line\n", path: []string{"Long"}},
+				{content: "second line\n", path: []string{"Long"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := []rune(tt.text)
+			var got []chunk
+			for _, s := range splitMarkdown(text, tt.size, 0, defaultSeparators) {
+				got = append(got, chunk{content: string(text[s.start:s.end]), path: s.sectionPath})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
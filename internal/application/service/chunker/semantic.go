@@ -0,0 +1,72 @@
+package chunker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Tencent/WeKnora/internal/models/utils"
+)
+
+// embedBatchSize limits the sentences embedded in one request
+const embedBatchSize = 64
+
+// splitSemantic splits the text where the meaning changes. Every sentence is embedded together with
+// its neighbours to smooth out short sentences, and a chunk ends after a sentence whose cosine distance
+// to the next one is above the given percentile of all distances. Chunks longer than size are split
+// into sentence windows.
+func splitSemantic(ctx context.Context, embedder Embedder, text []rune, size, percentile int) ([]span, error) {
+	sentences := splitSentences(text, 0, len(text))
+	if len(sentences) < 3 {
+		return splitSentenceWindows(text, sentences, size, 0), nil
+	}
+	if embedder == nil {
+		return nil, fmt.Errorf("semantic chunking requires an embedding model")
+	}
+
+	windows := make([]string, len(sentences))
+	for i := range sentences {
+		from, to := max(i-1, 0), min(i+1, len(sentences)-1)
+		windows[i] = strings.TrimSpace(string(text[sentences[from][0]:sentences[to][1]]))
+	}
+	vectors := make([][]float32, 0, len(windows))
+	for start := 0; start < len(windows); start += embedBatchSize {
+		batch, err := embedder.BatchEmbed(ctx, windows[start:min(start+embedBatchSize, len(windows))])
+		if err != nil {
+			return nil, fmt.Errorf("embed sentences: %w", err)
+		}
+		vectors = append(vectors, batch...)
+	}
+	if len(vectors) != len(sentences) {
+		return nil, fmt.Errorf("embed sentences: got %d vectors for %d sentences", len(vectors), len(sentences))
+	}
+
+	distances := make([]float64, len(sentences)-1)
+	for i := range distances {
+		distances[i] = 1 - utils.CosineSimilarity(vectors[i], vectors[i+1])
+	}
+	threshold := percentileOf(distances, percentile)
+
+	var spans []span
+	groupStart := 0
+	for i := range sentences {
+		if i < len(distances) && distances[i] <= threshold {
+			continue
+		}
+		spans = append(spans, splitSentenceWindows(text, sentences[groupStart:i+1], size, 0)...)
+		groupStart = i + 1
+	}
+	return spans, nil
+}
+
+// percentileOf returns the p-th percentile of the values, interpolating between the closest ranks
+func percentileOf(values []float64, p int) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := float64(p) / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,55 @@
+package chunker
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// topicEmbedder embeds a text by the topics it mentions
+type topicEmbedder struct{}
+
+func (topicEmbedder) BatchEmbed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{
+			float32(strings.Count(text, "cat")),
+			float32(strings.Count(text, "car")),
+		}
+	}
+	return vectors, nil
+}
+
+func TestSplitSemantic(t *testing.T) {
+	text := []rune("A cat sleeps. The cat eats. My cat purrs. A car drives. The car stops. My car honks.")
+	spans, err := splitSemantic(context.Background(), topicEmbedder{}, text, 100, 80)
+	if err != nil {
+		t.Fatalf("splitSemantic() error = %v", err)
+	}
+	var got []string
+	for _, s := range spans {
+		got = append(got, string(text[s.start:s.end]))
+	}
+	want := []string{"A cat sleeps. The cat eats. My cat purrs. ", "A car drives. The car stops. My car honks."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitSemantic() = %q, want %q", got, want)
+	}
+}
+
+func TestPercentileOf(t *testing.T) {
+	values := []float64{4, 1, 3, 2, 5}
+	tests := []struct {
+		p    int
+		want float64
+	}{
+		{p: 0, want: 1},
+		{p: 50, want: 3},
+		{p: 95, want: 4.8},
+	}
+	for _, tt := range tests {
+		if got := percentileOf(values, tt.p); got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("percentileOf(%d) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
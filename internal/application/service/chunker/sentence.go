@@ -0,0 +1,75 @@
+package chunker
+
+import "unicode"
+
+// sentenceEnds are the runes that end a sentence wherever they appear
+var sentenceEnds = map[rune]bool{
+	'\n': true, '。': true, '！': true, '？': true, '；': true,
+}
+
+// spacedSentenceEnds are the runes that end a sentence when followed by whitespace,
+// so decimals and abbreviations like "3.14" or "v1.2" stay in one sentence
+var spacedSentenceEnds = map[rune]bool{
+	'.': true, '!': true, '?': true, ';': true,
+}
+
+// IsSentenceBoundary reports whether a span of text preferably ends after r
+func IsSentenceBoundary(r rune) bool {
+	return sentenceEnds[r] || spacedSentenceEnds[r]
+}
+
+// splitSentences splits text[start:end] into sentences. A sentence keeps its closing punctuation and
+// the whitespace after it; leading whitespace of the text belongs to the first sentence.
+func splitSentences(text []rune, start, end int) [][2]int {
+	var sentences [][2]int
+	sentenceStart := start
+	for i := start; i < end; i++ {
+		r := text[i]
+		if !sentenceEnds[r] && !(spacedSentenceEnds[r] && (i+1 == end || unicode.IsSpace(text[i+1]))) {
+			continue
+		}
+		j := i + 1
+		for j < end && (IsSentenceBoundary(text[j]) || unicode.IsSpace(text[j])) {
+			j++
+		}
+		sentences = append(sentences, [2]int{sentenceStart, j})
+		sentenceStart = j
+		i = j - 1
+	}
+	if sentenceStart < end {
+		sentences = append(sentences, [2]int{sentenceStart, end})
+	}
+	return sentences
+}
+
+// splitSentenceWindows packs consecutive sentences into spans of at most size runes. The next span
+// starts with the trailing sentences of the previous one that fit in overlap runes.
+// A sentence longer than size is split on its own.
+func splitSentenceWindows(text []rune, sentences [][2]int, size, overlap int) []span {
+	var spans []span
+	for i := 0; i < len(sentences); {
+		first := sentences[i]
+		if first[1]-first[0] > size {
+			for _, part := range splitRecursive(text, first[0], first[1], size, 0, nil) {
+				spans = append(spans, span{start: part[0], end: part[1]})
+			}
+			i++
+			continue
+		}
+		last := i
+		for last+1 < len(sentences) && sentences[last+1][1]-first[0] <= size {
+			last++
+		}
+		end := sentences[last][1]
+		spans = append(spans, span{start: first[0], end: end})
+		if last == len(sentences)-1 {
+			break
+		}
+		next := last + 1
+		for k := last; k > i && end-sentences[k][0] <= overlap; k-- {
+			next = k
+		}
+		i = next
+	}
+	return spans
+}
@@ -0,0 +1,79 @@
+package chunker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "mixed punctuation",
+			text: "第一句。第二句！Pi is 3.14. Really?! Yes",
+			want: []string{"第一句。", "第二句！", "Pi is 3.14. ", "Really?! ", "Yes"},
+		},
+		{
+			name: "line breaks",
+			text: "line one\n\nline two\n",
+			want: []string{"line one\n\n", "line two\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := []rune(tt.text)
+			var got []string
+			for _, s := range splitSentences(text, 0, len(text)) {
+				got = append(got, string(text[s[0]:s[1]]))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitSentences() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitSentenceWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		size    int
+		overlap int
+		want    []string
+	}{
+		{
+			name: "no overlap",
+			text: "One. Two. Three. Four.",
+			size: 10,
+			want: []string{"One. Two. ", "Three. ", "Four."},
+		},
+		{
+			name:    "shared sentences",
+			text:    "One. Two. Three. Four.",
+			size:    17,
+			overlap: 7,
+			want:    []string{"One. Two. Three. ", "Three. Four."},
+		},
+		{
+			name: "sentence longer than the window",
+			text: "Short. A very long sentence",
+			size: 10,
+			want: []string{"Short. ", "A very lon", "g sentence"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := []rune(tt.text)
+			var got []string
+			for _, s := range splitSentenceWindows(text, splitSentences(text, 0, len(text)), tt.size, tt.overlap) {
+				got = append(got, string(text[s.start:s.end]))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitSentenceWindows() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
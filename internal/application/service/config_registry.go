@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/config"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/fsnotify/fsnotify"
+)
+
+// ErrConfigVersionActive is returned when rolling back to the content that is already active
+var ErrConfigVersionActive = errors.New("config version is already active")
+
+// configReloadDelay is how long the registry waits for further file events before reloading,
+// editors usually write a file in several steps
+const configReloadDelay = 500 * time.Millisecond
+
+// configPollInterval is how often the registry checks for versions applied by other replicas, e.g. rollbacks
+const configPollInterval = 10 * time.Second
+
+// configRegistry swaps changed conversation configs into the running server.
+// Readers pick up a new version through config.GetConversation on their next request.
+// The newest recorded version is the active config of all replicas, each replica polls for it.
+type configRegistry struct {
+	config  *config.Config
+	repo    interfaces.ConfigVersionRepository
+	watcher *fsnotify.Watcher
+	// stop stops polling the versions
+	stop context.CancelFunc
+
+	// mu serializes applying versions
+	mu sync.Mutex
+	// checksum is the checksum of the active conversation config
+	checksum string
+}
+
+// NewConfigRegistry creates a registry for the conversation config of cfg
+func NewConfigRegistry(cfg *config.Config, repo interfaces.ConfigVersionRepository) interfaces.ConfigRegistry {
+	return &configRegistry{config: cfg, repo: repo}
+}
+
+// Start records the config loaded at startup and starts watching the config file and polling the versions.
+// If the config file has not changed since it was last recorded, the newest version is applied instead,
+// so a rollback is kept across restarts until the config file changes.
+func (r *configRegistry) Start(ctx context.Context) error {
+	restored, err := r.restore(ctx)
+	if err != nil {
+		return fmt.Errorf("restore config version: %w", err)
+	}
+	if !restored {
+		if _, err := r.apply(ctx, r.config.GetConversation(), types.ConfigVersionSourceStartup, 0); err != nil {
+			return fmt.Errorf("record startup config version: %w", err)
+		}
+	}
+
+	pollCtx, stop := context.WithCancel(ctx)
+	r.stop = stop
+	go r.poll(pollCtx)
+	if r.config.File() == "" {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create config watcher: %w", err)
+	}
+	// Watch the directory, editors and mounted config maps replace the file instead of writing it
+	if err := watcher.Add(filepath.Dir(r.config.File())); err != nil {
+		watcher.Close()
+		return fmt.Errorf("watch config file: %w", err)
+	}
+	r.watcher = watcher
+	go r.watch(ctx, watcher)
+	logger.Infof(ctx, "Watching config file for conversation config changes: %s", r.config.File())
+	return nil
+}
+
+// Stop stops watching the config file and polling the versions
+func (r *configRegistry) Stop() error {
+	if r.stop != nil {
+		r.stop()
+	}
+	if r.watcher == nil {
+		return nil
+	}
+	return r.watcher.Close()
+}
+
+// watch reloads the config file once its directory has been quiet for configReloadDelay
+func (r *configRegistry) watch(ctx context.Context, watcher *fsnotify.Watcher) {
+	var timer *time.Timer
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(configReloadDelay, func() { r.reload(ctx) })
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warnf(ctx, "Config watcher error: %v", err)
+		}
+	}
+}
+
+// reload applies the conversation config of the config file if it is valid and has changed
+func (r *configRegistry) reload(ctx context.Context) {
+	cfg, err := config.ParseConfigFile(r.config.File())
+	if err != nil {
+		logger.Warnf(ctx, "Config file change ignored, failed to parse: %v", err)
+		return
+	}
+	if err := config.ValidateConversation(cfg.Conversation); err != nil {
+		logger.Warnf(ctx, "Config file change ignored, invalid conversation config: %v", err)
+		return
+	}
+	version, err := r.apply(ctx, cfg.Conversation, types.ConfigVersionSourceFile, 0)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to apply conversation config from file: %v", err)
+		return
+	}
+	if version != nil {
+		logger.Infof(ctx, "Conversation config reloaded from file, version: %d", version.ID)
+	}
+}
+
+// restore applies the newest version if it was applied after the version recorded for the current content of the
+// config file, e.g. a rollback. It returns false if the config file changed since, or there is no such version.
+func (r *configRegistry) restore(ctx context.Context) (bool, error) {
+	_, checksum, err := conversationChecksum(r.config.GetConversation())
+	if err != nil {
+		return false, err
+	}
+	fileVersion, err := r.repo.GetLatestBySource(ctx, types.ConfigVersionSourceStartup, types.ConfigVersionSourceFile)
+	if err != nil || fileVersion == nil || fileVersion.Checksum != checksum {
+		return false, err
+	}
+	latest, err := r.repo.GetLatest(ctx)
+	if err != nil || latest.ID == fileVersion.ID {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.activate(latest); err != nil {
+		logger.Warnf(ctx, "Newest config version %d ignored, using the config file: %v", latest.ID, err)
+		return false, nil
+	}
+	logger.Infof(ctx, "Config file unchanged since version %d, restored newest version %d", fileVersion.ID, latest.ID)
+	return true, nil
+}
+
+// poll applies the versions recorded by other replicas every configPollInterval until ctx is canceled
+func (r *configRegistry) poll(ctx context.Context) {
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.sync(ctx)
+		}
+	}
+}
+
+// sync applies the newest version if it is not active, failures are only logged
+func (r *configRegistry) sync(ctx context.Context) {
+	latest, err := r.repo.GetLatest(ctx)
+	if err != nil {
+		logger.Warnf(ctx, "Failed to poll config versions: %v", err)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if latest == nil || latest.Checksum == r.checksum {
+		return
+	}
+	if err := r.activate(latest); err != nil {
+		logger.Errorf(ctx, "Failed to apply config version %d: %v", latest.ID, err)
+		return
+	}
+	logger.Infof(ctx, "Conversation config updated to version %d applied by another replica", latest.ID)
+}
+
+// activate makes a recorded version active, the caller holds mu
+func (r *configRegistry) activate(version *types.ConfigVersion) error {
+	var conversation config.ConversationConfig
+	if err := json.Unmarshal(version.Content, &conversation); err != nil {
+		return fmt.Errorf("decode config version %d: %w", version.ID, err)
+	}
+	if err := config.ValidateConversation(&conversation); err != nil {
+		return err
+	}
+	r.config.SetConversation(&conversation)
+	r.checksum = version.Checksum
+	return nil
+}
+
+// conversationChecksum encodes a conversation config as it is recorded and returns the content with its checksum
+func conversationChecksum(conversation *config.ConversationConfig) ([]byte, string, error) {
+	content, err := json.Marshal(conversation)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(content)
+	return content, hex.EncodeToString(sum[:]), nil
+}
+
+// apply records conversation as a new version and makes it active.
+// It returns nil if conversation is already active, a version is only
+// recorded if it differs from the latest one, so restarts don't add versions.
+func (r *configRegistry) apply(ctx context.Context,
+	conversation *config.ConversationConfig, source types.ConfigVersionSource, restoredFrom uint,
+) (*types.ConfigVersion, error) {
+	content, checksum, err := conversationChecksum(conversation)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if checksum == r.checksum {
+		return nil, nil
+	}
+	version, err := r.repo.GetLatest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version == nil || version.Checksum != checksum {
+		version = &types.ConfigVersion{
+			Source:       source,
+			RestoredFrom: restoredFrom,
+			Checksum:     checksum,
+			Content:      types.JSON(content),
+		}
+		if err := r.repo.Create(ctx, version); err != nil {
+			return nil, err
+		}
+	}
+	r.config.SetConversation(conversation)
+	r.checksum = checksum
+	return version, nil
+}
+
+// ListVersions lists the applied versions without their content, newest first
+func (r *configRegistry) ListVersions(ctx context.Context, pagination *types.Pagination) (*types.PageResult, error) {
+	versions, total, err := r.repo.List(ctx, pagination)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewPageResult(total, pagination, versions), nil
+}
+
+// GetVersion gets an applied version with its content
+func (r *configRegistry) GetVersion(ctx context.Context, id uint) (*types.ConfigVersion, error) {
+	return r.repo.GetByID(ctx, id)
+}
+
+// DiffVersions returns the fields that differ between two versions, sorted by path
+func (r *configRegistry) DiffVersions(ctx context.Context, from, to uint) ([]*types.ConfigFieldDiff, error) {
+	fromConversation, err := r.versionConversation(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	toConversation, err := r.versionConversation(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	return config.DiffConversation(fromConversation, toConversation), nil
+}
+
+// Rollback applies the content of an earlier version as a new version. The other replicas apply it
+// within configPollInterval and it is kept across restarts. The config file is not changed,
+// the next change of the file replaces the rolled back version.
+func (r *configRegistry) Rollback(ctx context.Context, id uint) (*types.ConfigVersion, error) {
+	conversation, err := r.versionConversation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.ValidateConversation(conversation); err != nil {
+		return nil, err
+	}
+	version, err := r.apply(ctx, conversation, types.ConfigVersionSourceRollback, id)
+	if err != nil {
+		return nil, err
+	}
+	if version == nil {
+		return nil, ErrConfigVersionActive
+	}
+	logger.Infof(ctx, "Conversation config rolled back to version %d, new version: %d", id, version.ID)
+	return version, nil
+}
+
+// versionConversation decodes the conversation config of a version
+func (r *configRegistry) versionConversation(ctx context.Context, id uint) (*config.ConversationConfig, error) {
+	version, err := r.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var conversation config.ConversationConfig
+	if err := json.Unmarshal(version.Content, &conversation); err != nil {
+		return nil, fmt.Errorf("decode config version %d: %w", id, err)
+	}
+	return &conversation, nil
+}
@@ -0,0 +1,370 @@
+// Package crawler discovers the pages of a website through its sitemaps or by following links,
+// respecting robots.txt and the include and exclude patterns of a crawl
+package crawler
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+	"github.com/gocolly/colly/v2"
+	"github.com/temoto/robotstxt"
+)
+
+// crawlerUserAgent is the user agent of the crawler, robots.txt rules are matched against it
+const crawlerUserAgent = "WeKnora-Crawler"
+
+// maxSitemapFiles limits the sitemap files read through sitemap indexes
+const maxSitemapFiles = 50
+
+type crawlerService struct {
+	maxDepth int
+	maxPages int
+	client   *http.Client
+}
+
+func NewCrawlerService() (interfaces.CrawlerService, error) {
+	return &crawlerService{
+		maxDepth: 5,
+		maxPages: 500,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// crawlFilter decides which URLs of a site a crawl visits and returns
+type crawlFilter struct {
+	host    string
+	robots  *robotstxt.RobotsData
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// visitable reports whether the crawl may fetch the URL
+func (f *crawlFilter) visitable(u *url.URL) bool {
+	if u.Host != f.host || shouldSkipURL(u.String()) || !secutils.IsValidURL(u.String()) {
+		return false
+	}
+	if !f.robots.TestAgent(u.RequestURI(), crawlerUserAgent) {
+		return false
+	}
+	return !matchAny(f.exclude, u.String())
+}
+
+// accepts reports whether the crawl returns the page
+func (f *crawlFilter) accepts(u *url.URL) bool {
+	return f.visitable(u) && (len(f.include) == 0 || matchAny(f.include, u.String()))
+}
+
+func matchAny(patterns []*regexp.Regexp, s string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *crawlerService) CrawlWebsite(ctx context.Context,
+	baseURL string, options *interfaces.CrawlOptions,
+) (*interfaces.CrawlResult, error) {
+	if options == nil {
+		options = &interfaces.CrawlOptions{}
+	}
+	maxPages := options.MaxPages
+	if maxPages <= 0 {
+		maxPages = s.maxPages
+	}
+	logger.Infof(ctx, "Starting website crawl: %s, maxPages: %d", baseURL, maxPages)
+
+	parsedBase, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+
+	robots := s.fetchRobots(ctx, parsedBase)
+	filter := &crawlFilter{
+		host:    parsedBase.Host,
+		robots:  robots,
+		include: options.Include,
+		exclude: options.Exclude,
+	}
+
+	result, err := s.crawlSitemaps(ctx, parsedBase, robots.Sitemaps, filter, maxPages)
+	if err != nil {
+		logger.Warnf(ctx, "Failed to read sitemap, following links instead: %v", err)
+	}
+	if result == nil || len(result.Pages) == 0 {
+		result, err = s.crawlLinks(ctx, parsedBase, filter, maxPages)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Infof(ctx, "Crawl completed: %d pages found, %d failed, sitemap: %v, truncated: %v",
+		len(result.Pages), len(result.Failed), result.FromSitemap, result.Truncated)
+	return result, nil
+}
+
+// fetchRobots reads the robots.txt of the site, a missing or unreadable robots.txt allows everything
+func (s *crawlerService) fetchRobots(ctx context.Context, base *url.URL) *robotstxt.RobotsData {
+	robotsURL := &url.URL{Scheme: base.Scheme, Host: base.Host, Path: "/robots.txt"}
+	resp, err := s.get(ctx, robotsURL.String())
+	if err == nil {
+		defer resp.Body.Close()
+		var robots *robotstxt.RobotsData
+		if robots, err = robotstxt.FromResponse(resp); err == nil {
+			return robots
+		}
+	}
+	logger.Warnf(ctx, "Failed to read robots.txt of %s, crawling without restrictions: %v", base.Host, err)
+	robots, _ := robotstxt.FromStatusAndBytes(http.StatusNotFound, nil)
+	return robots
+}
+
+// sitemapDocument is a sitemap or a sitemap index
+type sitemapDocument struct {
+	URLs []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// crawlSitemaps discovers the pages of the site through the sitemaps listed in robots.txt,
+// or /sitemap.xml if there are none. Sitemap indexes are followed.
+func (s *crawlerService) crawlSitemaps(ctx context.Context,
+	base *url.URL, sitemaps []string, filter *crawlFilter, maxPages int,
+) (*interfaces.CrawlResult, error) {
+	if len(sitemaps) == 0 {
+		sitemaps = []string{(&url.URL{Scheme: base.Scheme, Host: base.Host, Path: "/sitemap.xml"}).String()}
+	}
+	result := &interfaces.CrawlResult{
+		Pages:       make([]interfaces.CrawledPage, 0),
+		Failed:      make([]string, 0),
+		FromSitemap: true,
+		StartedAt:   time.Now(),
+	}
+	seen := make(map[string]bool)
+	read := make(map[string]bool)
+	var lastErr error
+	for len(sitemaps) > 0 && len(read) < maxSitemapFiles {
+		sitemapURL := sitemaps[0]
+		sitemaps = sitemaps[1:]
+		if read[sitemapURL] {
+			continue
+		}
+		read[sitemapURL] = true
+
+		doc, err := s.fetchSitemap(ctx, sitemapURL)
+		if err != nil {
+			logger.Warnf(ctx, "Failed to read sitemap %s: %v", sitemapURL, err)
+			result.Failed = append(result.Failed, sitemapURL)
+			lastErr = err
+			continue
+		}
+		for _, sitemap := range doc.Sitemaps {
+			sitemaps = append(sitemaps, strings.TrimSpace(sitemap.Loc))
+		}
+		for _, entry := range doc.URLs {
+			pageURL, err := url.Parse(strings.TrimSpace(entry.Loc))
+			if err != nil || !filter.accepts(pageURL) {
+				continue
+			}
+			pageURL.Fragment = ""
+			if seen[pageURL.String()] {
+				continue
+			}
+			seen[pageURL.String()] = true
+			if len(result.Pages) >= maxPages {
+				result.Truncated = true
+				continue
+			}
+			result.Pages = append(result.Pages, interfaces.CrawledPage{
+				URL:     pageURL.String(),
+				LastMod: strings.TrimSpace(entry.LastMod),
+			})
+		}
+	}
+	if len(result.Pages) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	if len(sitemaps) > 0 {
+		result.Truncated = true
+	}
+	result.Visited = len(result.Pages)
+	return result, nil
+}
+
+func (s *crawlerService) fetchSitemap(ctx context.Context, sitemapURL string) (*sitemapDocument, error) {
+	if !secutils.IsValidURL(sitemapURL) {
+		return nil, fmt.Errorf("invalid sitemap URL")
+	}
+	resp, err := s.get(ctx, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	var body io.Reader = resp.Body
+	if strings.HasSuffix(strings.ToLower(resp.Request.URL.Path), ".gz") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+	var doc sitemapDocument
+	if err := xml.NewDecoder(body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid sitemap: %w", err)
+	}
+	return &doc, nil
+}
+
+func (s *crawlerService) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", crawlerUserAgent)
+	return s.client.Do(req)
+}
+
+// crawlLinks discovers the pages of the site by following links from the base URL
+func (s *crawlerService) crawlLinks(ctx context.Context,
+	base *url.URL, filter *crawlFilter, maxPages int,
+) (*interfaces.CrawlResult, error) {
+	result := &interfaces.CrawlResult{
+		Pages:     make([]interfaces.CrawledPage, 0),
+		Failed:    make([]string, 0),
+		StartedAt: time.Now(),
+	}
+
+	c := colly.NewCollector(
+		colly.AllowedDomains(base.Hostname()),
+		colly.MaxDepth(s.maxDepth),
+		colly.Async(true),
+		colly.UserAgent(crawlerUserAgent),
+		colly.StdlibContext(ctx),
+	)
+
+	c.Limit(&colly.LimitRule{
+		DomainGlob:  "*",
+		Parallelism: 5,
+		Delay:       300 * time.Millisecond,
+	})
+
+	var visited sync.Map
+	urlsMutex := sync.Mutex{}
+
+	c.OnHTML("a[href]", func(e *colly.HTMLElement) {
+		absoluteURL := e.Request.AbsoluteURL(e.Attr("href"))
+		if absoluteURL == "" {
+			return
+		}
+
+		parsedURL, err := url.Parse(absoluteURL)
+		if err != nil {
+			return
+		}
+		parsedURL.Fragment = ""
+		if !filter.visitable(parsedURL) {
+			return
+		}
+		cleanURL := parsedURL.String()
+
+		// 使用 sync.Map 的 LoadOrStore 实现原子操作
+		// 只有第一个成功存储的 goroutine 会返回 false，其他都返回 true
+		if _, alreadyVisited := visited.LoadOrStore(cleanURL, true); alreadyVisited {
+			return
+		}
+
+		// 检查是否已达到最大页面数
+		urlsMutex.Lock()
+		full := len(result.Pages) >= maxPages
+		if full {
+			result.Truncated = true
+		}
+		urlsMutex.Unlock()
+		if full {
+			return
+		}
+
+		e.Request.Visit(cleanURL)
+	})
+
+	c.OnRequest(func(r *colly.Request) {
+		logger.Debugf(ctx, "Visiting: %s", r.URL.String())
+	})
+
+	c.OnResponse(func(r *colly.Response) {
+		if r.StatusCode < 200 || r.StatusCode >= 300 {
+			return
+		}
+		if !strings.Contains(r.Headers.Get("Content-Type"), "text/html") || !filter.accepts(r.Request.URL) {
+			return
+		}
+		urlsMutex.Lock()
+		defer urlsMutex.Unlock()
+		if len(result.Pages) >= maxPages {
+			result.Truncated = true
+			return
+		}
+		result.Pages = append(result.Pages, interfaces.CrawledPage{
+			URL:     r.Request.URL.String(),
+			LastMod: r.Headers.Get("Last-Modified"),
+		})
+		logger.Infof(ctx, "Added URL [%d/%d]: %s", len(result.Pages), maxPages, r.Request.URL.String())
+	})
+
+	c.OnError(func(r *colly.Response, err error) {
+		if strings.Contains(err.Error(), "already visited") {
+			return
+		}
+		logger.Warnf(ctx, "Failed to crawl %s: %v", r.Request.URL.String(), err)
+		urlsMutex.Lock()
+		result.Failed = append(result.Failed, r.Request.URL.String())
+		urlsMutex.Unlock()
+	})
+
+	if err := c.Visit(base.String()); err != nil {
+		return nil, fmt.Errorf("failed to start crawling: %w", err)
+	}
+
+	c.Wait()
+
+	result.Visited = len(result.Pages)
+	return result, nil
+}
+
+func shouldSkipURL(urlStr string) bool {
+	skipPatterns := []string{
+		".pdf", ".zip", ".tar", ".gz", ".jpg", ".jpeg", ".png", ".gif",
+		".mp4", ".mp3", ".avi", ".mov", ".css", ".js", ".woff", ".ttf",
+		"/api/", "/download/", "/file/", "/asset/", "/static/",
+	}
+
+	lowerURL := strings.ToLower(urlStr)
+	for _, pattern := range skipPatterns {
+		if strings.Contains(lowerURL, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
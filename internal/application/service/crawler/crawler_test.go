@@ -0,0 +1,193 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+// site serves the files of a test website, {base} in a file is replaced with the URL of the server
+type site map[string]string
+
+func (s site) serve(t *testing.T) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := s[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		content = strings.ReplaceAll(content, "{base}", server.URL)
+		switch {
+		case strings.HasSuffix(r.URL.Path, ".gz"):
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			gz.Write([]byte(content))
+			gz.Close()
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Write(buf.Bytes())
+		case strings.HasSuffix(r.URL.Path, ".xml") || strings.HasSuffix(r.URL.Path, ".txt"):
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(content))
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(content))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func urlset(locs ...string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	for _, loc := range locs {
+		b.WriteString("<url><loc>{base}" + loc + "</loc><lastmod>2025-08-01</lastmod></url>")
+	}
+	b.WriteString("</urlset>")
+	return b.String()
+}
+
+func sitemapIndex(locs ...string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	for _, loc := range locs {
+		b.WriteString("<sitemap><loc>{base}" + loc + "</loc></sitemap>")
+	}
+	b.WriteString("</sitemapindex>")
+	return b.String()
+}
+
+// paths returns the paths of the crawled pages relative to the server, sorted
+func paths(server *httptest.Server, pages []interfaces.CrawledPage) []string {
+	var got []string
+	for _, page := range pages {
+		got = append(got, strings.TrimPrefix(page.URL, server.URL))
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestCrawlWebsite_Sitemaps(t *testing.T) {
+	tests := []struct {
+		name          string
+		site          site
+		options       *interfaces.CrawlOptions
+		want          []string
+		wantTruncated bool
+	}{
+		{
+			name: "sitemap index with a gzip sitemap from robots.txt",
+			site: site{
+				"/robots.txt":      "User-agent: *\nAllow: /\nSitemap: {base}/index.xml\n",
+				"/index.xml":       sitemapIndex("/docs.xml", "/blog.xml.gz"),
+				"/docs.xml":        urlset("/docs/1", "/docs/2"),
+				"/blog.xml.gz":     urlset("/blog/1", "/docs/1"),
+				"/sitemap.xml":     urlset("/not-listed-in-robots"),
+				"/not-in-sitemaps": "<html></html>",
+			},
+			want: []string{"/blog/1", "/docs/1", "/docs/2"},
+		},
+		{
+			name: "default sitemap without robots.txt",
+			site: site{"/sitemap.xml": urlset("/docs/1")},
+			want: []string{"/docs/1"},
+		},
+		{
+			name: "robots.txt disallows pages",
+			site: site{
+				"/robots.txt":  "User-agent: *\nDisallow: /private\n",
+				"/sitemap.xml": urlset("/docs/1", "/private/salaries", "/private"),
+			},
+			want: []string{"/docs/1"},
+		},
+		{
+			name: "robots.txt rules of the crawler user agent",
+			site: site{
+				"/robots.txt":  "User-agent: WeKnora-Crawler\nDisallow: /docs/2\n\nUser-agent: *\nDisallow: /\n",
+				"/sitemap.xml": urlset("/docs/1", "/docs/2"),
+			},
+			want: []string{"/docs/1"},
+		},
+		{
+			name: "include and exclude patterns",
+			site: site{"/sitemap.xml": urlset("/docs/1", "/docs/draft-2", "/blog/3", "/docs/4#section")},
+			options: &interfaces.CrawlOptions{
+				Include: []*regexp.Regexp{regexp.MustCompile(`/docs/`)},
+				Exclude: []*regexp.Regexp{regexp.MustCompile(`draft`)},
+			},
+			want: []string{"/docs/1", "/docs/4"},
+		},
+		{
+			name:          "pages beyond max pages truncate the crawl",
+			site:          site{"/sitemap.xml": urlset("/docs/1", "/docs/2", "/docs/3")},
+			options:       &interfaces.CrawlOptions{MaxPages: 2},
+			want:          []string{"/docs/1", "/docs/2"},
+			wantTruncated: true,
+		},
+		{
+			name: "skipped file types",
+			site: site{"/sitemap.xml": urlset("/docs/1", "/docs/manual.pdf", "/static/app.html")},
+			want: []string{"/docs/1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := tt.site.serve(t)
+			s, _ := NewCrawlerService()
+			result, err := s.CrawlWebsite(context.Background(), server.URL+"/", tt.options)
+			if err != nil {
+				t.Fatalf("CrawlWebsite() error = %v", err)
+			}
+			if !result.FromSitemap {
+				t.Errorf("FromSitemap = false, want true")
+			}
+			if got := paths(server, result.Pages); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pages = %v, want %v", got, tt.want)
+			}
+			if result.Truncated != tt.wantTruncated {
+				t.Errorf("Truncated = %v, want %v", result.Truncated, tt.wantTruncated)
+			}
+			for _, page := range result.Pages {
+				if page.LastMod != "2025-08-01" {
+					t.Errorf("LastMod of %s = %q, want the lastmod of the sitemap", page.URL, page.LastMod)
+				}
+			}
+		})
+	}
+}
+
+func TestCrawlWebsite_Links(t *testing.T) {
+	server := site{
+		"/robots.txt": "User-agent: *\nDisallow: /private\n",
+		"/": `<html><body><a href="/docs/1">1</a> <a href="/private/salaries">salaries</a>
+			<a href="/docs/manual.pdf">manual</a> <a href="https://example.com/docs/2">elsewhere</a></body></html>`,
+		"/docs/1":           `<html><body><a href="/docs/draft">draft</a> <a href="/">home</a></body></html>`,
+		"/docs/draft":       `<html><body>draft</body></html>`,
+		"/private/salaries": `<html><body>secret</body></html>`,
+	}.serve(t)
+
+	s, _ := NewCrawlerService()
+	result, err := s.CrawlWebsite(context.Background(), server.URL+"/", &interfaces.CrawlOptions{
+		Exclude: []*regexp.Regexp{regexp.MustCompile(`draft`)},
+	})
+	if err != nil {
+		t.Fatalf("CrawlWebsite() error = %v", err)
+	}
+	if result.FromSitemap {
+		t.Errorf("FromSitemap = true, want links to be followed without a sitemap")
+	}
+	if got, want := paths(server, result.Pages), []string{"/", "/docs/1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+}
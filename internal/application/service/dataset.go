@@ -4,19 +4,65 @@ import (
 	"context"
 	"errors"
 	"fmt"
-
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+
+	"github.com/Tencent/WeKnora/internal/application/service/datasetfile"
+	"github.com/Tencent/WeKnora/internal/config"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
 	"github.com/Tencent/WeKnora/internal/logger"
 	"github.com/Tencent/WeKnora/internal/types"
 	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/hibiken/asynq"
 	"github.com/parquet-go/parquet-go"
 )
 
+const (
+	// maxDatasetFileSize is the largest dataset file that can be uploaded
+	maxDatasetFileSize = 50 << 20
+	// maxDatasetRecords is the largest number of records of a dataset
+	maxDatasetRecords = 10000
+)
+
 // DatasetService provides operations for working with datasets
-type DatasetService struct{}
+type DatasetService struct {
+	config               *config.Config                  // Dataset generation settings
+	datasetRepo          interfaces.DatasetRepository    // Storage of datasets and generation tasks
+	chunkRepo            interfaces.ChunkRepository      // Chunks sampled for generated datasets
+	knowledgeBaseService interfaces.KnowledgeBaseService // Knowledge bases datasets are generated from
+	modelService         interfaces.ModelService         // Chat models writing generated questions
+	tenantRepo           interfaces.TenantRepository     // Tenants of the generation tasks
+	task                 *asynq.Client                   // Client enqueuing generation tasks
+	// queue is the asynq queue of the generation tasks, the queue of the document tasks
+	queue string
+}
 
 // NewDatasetService creates a new DatasetService instance
-func NewDatasetService() interfaces.DatasetService {
-	return &DatasetService{}
+func NewDatasetService(
+	config *config.Config,
+	datasetRepo interfaces.DatasetRepository,
+	chunkRepo interfaces.ChunkRepository,
+	knowledgeBaseService interfaces.KnowledgeBaseService,
+	modelService interfaces.ModelService,
+	tenantRepo interfaces.TenantRepository,
+	task *asynq.Client,
+) interfaces.DatasetService {
+	ingestion := config.Ingestion
+	if ingestion == nil {
+		ingestion = defaultIngestionConfig
+	}
+	return &DatasetService{
+		config:               config,
+		datasetRepo:          datasetRepo,
+		chunkRepo:            chunkRepo,
+		knowledgeBaseService: knowledgeBaseService,
+		modelService:         modelService,
+		tenantRepo:           tenantRepo,
+		task:                 task,
+		queue:                ingestion.Queue,
+	}
 }
 
 // TextInfo represents text data with ID in parquet format
@@ -37,41 +83,164 @@ type QaInfo struct {
 	AID int64 `parquet:"aid"` // Answer ID
 }
 
-// GetDatasetByID retrieves QA pairs from dataset by ID
+// GetDatasetByID retrieves QA pairs from dataset by ID, the default dataset is the built-in sample dataset
 func (d *DatasetService) GetDatasetByID(ctx context.Context, datasetID string) ([]*types.QAPair, error) {
 	logger.Info(ctx, "Start getting dataset by ID")
 	logger.Infof(ctx, "Getting dataset with ID: %s", datasetID)
 
-	dataset := DefaultDataset()
-	dataset.PrintStats(ctx)
-	qaPairs := dataset.Iterate()
+	if datasetID == "" || datasetID == types.DefaultDatasetID {
+		dataset, err := DefaultDataset()
+		if err != nil {
+			logger.Errorf(ctx, "Failed to load default dataset: %v", err)
+			return nil, err
+		}
+		dataset.PrintStats(ctx)
+		qaPairs := dataset.Iterate()
+
+		logger.Infof(ctx, "Retrieved %d QA pairs from dataset", len(qaPairs))
+		return qaPairs, nil
+	}
+
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	if _, err := d.datasetRepo.GetDataset(ctx, tenantID, datasetID); err != nil {
+		return nil, err
+	}
+	records, err := d.datasetRepo.ListRecords(ctx, tenantID, datasetID)
+	if err != nil {
+		return nil, err
+	}
+	qaPairs := qaPairsFromRecords(records)
 
 	logger.Infof(ctx, "Retrieved %d QA pairs from dataset", len(qaPairs))
 	return qaPairs, nil
 }
 
+// qaPairsFromRecords converts dataset records to QA pairs. Passages get IDs in order of appearance,
+// a passage shared by records has the same ID.
+func qaPairsFromRecords(records []*types.DatasetRecord) []*types.QAPair {
+	pids := make(map[string]int)
+	qaPairs := make([]*types.QAPair, 0, len(records))
+	for _, record := range records {
+		qaPair := &types.QAPair{
+			QID:          record.QuestionID,
+			Question:     record.Question,
+			AID:          record.QuestionID,
+			Answer:       record.Answer,
+			ChunkIDs:     record.ChunkIDs,
+			KnowledgeIDs: record.KnowledgeIDs,
+		}
+		for _, passage := range record.Passages {
+			pid, ok := pids[passage]
+			if !ok {
+				pid = len(pids)
+				pids[passage] = pid
+			}
+			qaPair.PIDs = append(qaPair.PIDs, pid)
+			qaPair.Passages = append(qaPair.Passages, passage)
+		}
+		qaPairs = append(qaPairs, qaPair)
+	}
+	return qaPairs
+}
+
+// CreateDataset creates a dataset from an uploaded parquet, JSONL or CSV file
+func (d *DatasetService) CreateDataset(ctx context.Context,
+	name string, description string, file *multipart.FileHeader,
+) (*types.Dataset, error) {
+	logger.Infof(ctx, "Creating dataset %s from file %s", name, file.Filename)
+	format := datasetfile.Format(file.Filename)
+	if format == "" {
+		return nil, werrors.NewValidationError("数据集文件只支持 parquet、jsonl 和 csv 格式")
+	}
+	if file.Size > maxDatasetFileSize {
+		return nil, werrors.NewValidationError(fmt.Sprintf("数据集文件不能超过 %dMB", maxDatasetFileSize>>20))
+	}
+	if name = strings.TrimSpace(name); name == "" {
+		name = strings.TrimSuffix(file.Filename, filepath.Ext(file.Filename))
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	records, err := datasetfile.Parse(format, content)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to parse dataset file: %v", err)
+		return nil, werrors.NewValidationError("数据集文件解析失败: " + err.Error())
+	}
+	if len(records) > maxDatasetRecords {
+		return nil, werrors.NewValidationError(fmt.Sprintf("数据集不能超过 %d 条记录", maxDatasetRecords))
+	}
+
+	dataset := &types.Dataset{
+		TenantID:    ctx.Value(types.TenantIDContextKey).(uint),
+		Name:        name,
+		Description: description,
+		Format:      format,
+		FileName:    file.Filename,
+		RecordCount: len(records),
+	}
+	if err := d.datasetRepo.CreateDataset(ctx, dataset, records); err != nil {
+		logger.Errorf(ctx, "Failed to store dataset: %v", err)
+		return nil, err
+	}
+	logger.Infof(ctx, "Dataset created successfully, ID: %s, records: %d", dataset.ID, dataset.RecordCount)
+	return dataset, nil
+}
+
+// GetDataset gets a dataset without its records
+func (d *DatasetService) GetDataset(ctx context.Context, datasetID string) (*types.Dataset, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	return d.datasetRepo.GetDataset(ctx, tenantID, datasetID)
+}
+
+// ListDatasets lists the datasets of the tenant, newest first
+func (d *DatasetService) ListDatasets(ctx context.Context, pagination *types.Pagination) (*types.PageResult, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	datasets, total, err := d.datasetRepo.ListDatasets(ctx, tenantID, pagination)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewPageResult(total, pagination, datasets), nil
+}
+
+// DeleteDataset deletes a dataset, evaluation tasks run on it keep their results
+func (d *DatasetService) DeleteDataset(ctx context.Context, datasetID string) error {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	if err := d.datasetRepo.DeleteDataset(ctx, tenantID, datasetID); err != nil {
+		return err
+	}
+	logger.Infof(ctx, "Dataset deleted successfully, ID: %s", datasetID)
+	return nil
+}
+
 // DefaultDataset loads and initializes the default dataset from parquet files
-func DefaultDataset() dataset {
+func DefaultDataset() (dataset, error) {
 	datasetDir := "./dataset/samples"
 	queries, err := loadParquet[TextInfo](fmt.Sprintf("%s/queries.parquet", datasetDir))
 	if err != nil {
-		panic(err)
+		return dataset{}, err
 	}
 	corpus, err := loadParquet[TextInfo](fmt.Sprintf("%s/corpus.parquet", datasetDir))
 	if err != nil {
-		panic(err)
+		return dataset{}, err
 	}
 	answers, err := loadParquet[TextInfo](fmt.Sprintf("%s/answers.parquet", datasetDir))
 	if err != nil {
-		panic(err)
+		return dataset{}, err
 	}
 	qrels, err := loadParquet[RelsInfo](fmt.Sprintf("%s/qrels.parquet", datasetDir))
 	if err != nil {
-		panic(err)
+		return dataset{}, err
 	}
 	qas, err := loadParquet[QaInfo](fmt.Sprintf("%s/qas.parquet", datasetDir))
 	if err != nil {
-		panic(err)
+		return dataset{}, err
 	}
 
 	res := dataset{
@@ -96,7 +265,7 @@ func DefaultDataset() dataset {
 	for _, qi := range qas {
 		res.qas[qi.QID] = qi.AID
 	}
-	return res
+	return res, nil
 }
 
 // dataset represents the in-memory dataset structure
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	"github.com/Tencent/WeKnora/internal/application/service/datasetgen"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	// datasetGenerationTimeout is the longest a dataset generation task may take
+	datasetGenerationTimeout = 2 * time.Hour
+	// defaultGeneratedQuestions is the number of questions generated if the request does not tell
+	defaultGeneratedQuestions = 50
+	// defaultMaxGeneratedQuestions caps the questions of a task if dataset_generation.max_questions is not set
+	defaultMaxGeneratedQuestions = 500
+	// generationChunksPerQuestion bounds the chunks tried per requested question,
+	// so that a knowledge base whose chunks keep failing the quality checks does not run through all its chunks
+	generationChunksPerQuestion = 3
+	// maxGenerationFailures is the number of consecutive chat model failures that fail the task
+	maxGenerationFailures = 3
+	// generationBatchSize is the number of sampled chunks loaded at once
+	generationBatchSize = 50
+)
+
+// GenerateDataset validates a dataset generation task, stores it and enqueues it
+func (d *DatasetService) GenerateDataset(ctx context.Context, task *types.DatasetGenerationTask) error {
+	cfg := d.config.DatasetGeneration
+	if cfg == nil || cfg.Prompt == "" {
+		return werrors.NewValidationError("未配置评估数据集生成")
+	}
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	if task.KnowledgeBaseID == "" || task.ChatModelID == "" {
+		return werrors.NewValidationError("生成数据集时必须指定知识库和对话模型")
+	}
+	maxQuestions := cfg.MaxQuestions
+	if maxQuestions <= 0 {
+		maxQuestions = defaultMaxGeneratedQuestions
+	}
+	if task.QuestionCount == 0 {
+		task.QuestionCount = defaultGeneratedQuestions
+	}
+	if task.QuestionCount < 0 || task.QuestionCount > maxQuestions {
+		return werrors.NewValidationError(fmt.Sprintf("生成的问题数必须在 1 到 %d 之间", maxQuestions))
+	}
+
+	kb, err := d.knowledgeBaseService.GetKnowledgeBaseByID(ctx, task.KnowledgeBaseID)
+	if err != nil || kb.TenantID != tenantID {
+		logger.Errorf(ctx, "Failed to get knowledge base %s: %v", task.KnowledgeBaseID, err)
+		return werrors.NewNotFoundError("知识库不存在")
+	}
+	model, err := d.modelService.GetModelByID(ctx, task.ChatModelID)
+	if err != nil || model.Type != types.ModelTypeKnowledgeQA {
+		logger.Errorf(ctx, "Failed to get chat model %s: %v", task.ChatModelID, err)
+		return werrors.NewValidationError("对话模型不存在或不可用")
+	}
+	if task.Name = strings.TrimSpace(task.Name); task.Name == "" {
+		task.Name = kb.Name
+	}
+
+	task.TenantID = tenantID
+	task.Status = types.DatasetGenerationStatusPending
+	if err := d.datasetRepo.CreateGenerationTask(ctx, task); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(&types.DatasetGenerationPayload{TenantID: tenantID, TaskID: task.ID})
+	if err != nil {
+		return err
+	}
+	info, err := d.task.EnqueueContext(ctx, asynq.NewTask(types.TypeDatasetGenerate, data,
+		asynq.Queue(d.queue),
+		asynq.MaxRetry(0),
+		asynq.Timeout(datasetGenerationTimeout),
+	))
+	if err != nil {
+		logger.Errorf(ctx, "Failed to enqueue dataset generation task %s: %v", task.ID, err)
+		d.failGeneration(ctx, task, fmt.Sprintf("Enqueue failed: %v", err))
+		return fmt.Errorf("failed to enqueue dataset generation task: %w", err)
+	}
+	logger.Infof(ctx, "Enqueued dataset generation task: id=%s queue=%s", info.ID, info.Queue)
+	return nil
+}
+
+// GetGenerationTask gets a dataset generation task with its progress
+func (d *DatasetService) GetGenerationTask(ctx context.Context, taskID string) (*types.DatasetGenerationTask, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	return d.datasetRepo.GetGenerationTask(ctx, tenantID, taskID)
+}
+
+// ListGenerationTasks lists the dataset generation tasks of the tenant, newest first
+func (d *DatasetService) ListGenerationTasks(ctx context.Context,
+	pagination *types.Pagination,
+) (*types.PageResult, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	tasks, total, err := d.datasetRepo.ListGenerationTasks(ctx, tenantID, pagination)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewPageResult(total, pagination, tasks), nil
+}
+
+// CancelGenerationTask cancels a pending or processing dataset generation task,
+// the running task stops before its next chunk and no dataset is created
+func (d *DatasetService) CancelGenerationTask(ctx context.Context, taskID string) error {
+	task, err := d.GetGenerationTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	cancelled, err := d.datasetRepo.UpdateGenerationStatus(ctx,
+		task.ID, types.DatasetGenerationStatusCancelled, "Task cancelled by user")
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return werrors.NewBadRequestError(fmt.Sprintf("cannot cancel task with status: %s", task.Status))
+	}
+	logger.Infof(ctx, "Dataset generation task cancelled, ID: %s", task.ID)
+	return nil
+}
+
+// RunGenerationTask handles a TypeDatasetGenerate task
+func (d *DatasetService) RunGenerationTask(ctx context.Context, t *asynq.Task) error {
+	var payload types.DatasetGenerationPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Errorf(ctx, "Failed to unmarshal dataset generation payload: %v", err)
+		return skipRetry(err)
+	}
+	ctx = context.WithValue(ctx, types.TenantIDContextKey, payload.TenantID)
+	ctx = logger.WithField(ctx, "dataset_generation_task_id", payload.TaskID)
+
+	tenant, err := d.tenantRepo.GetTenantByID(ctx, payload.TenantID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get tenant: %v", err)
+		return err
+	}
+	ctx = context.WithValue(ctx, types.TenantInfoContextKey, tenant)
+
+	task, err := d.datasetRepo.GetGenerationTask(ctx, payload.TenantID, payload.TaskID)
+	if err != nil {
+		if errors.Is(err, repository.ErrDatasetGenerationTaskNotFound) {
+			logger.Warn(ctx, "Dataset generation task was deleted, skip run")
+			return nil
+		}
+		return err
+	}
+	started, err := d.datasetRepo.UpdateGenerationStatus(ctx, task.ID, types.DatasetGenerationStatusProcessing, "")
+	if err != nil {
+		return err
+	}
+	if !started {
+		logger.Infof(ctx, "Dataset generation task is %s, skip run", task.Status)
+		return nil
+	}
+	d.generate(ctx, task)
+	return nil
+}
+
+// generate samples chunks of the knowledge base in random order and asks the chat model for a question and
+// a reference answer grounded in each of them, until enough questions pass the quality checks.
+// The accepted questions are stored as a dataset whose gold chunk of every question is its chunk.
+func (d *DatasetService) generate(ctx context.Context, task *types.DatasetGenerationTask) {
+	cfg := d.config.DatasetGeneration
+	if cfg == nil || cfg.Prompt == "" {
+		d.failGeneration(ctx, task, "dataset generation is not configured")
+		return
+	}
+	chatModel, err := d.modelService.GetChatModel(ctx, task.ChatModelID)
+	if err != nil {
+		d.failGeneration(ctx, task, fmt.Sprintf("Failed to get chat model: %v", err))
+		return
+	}
+	chunkIDs, err := d.chunkRepo.ListChunkIDsByKnowledgeBaseID(ctx,
+		task.TenantID, task.KnowledgeBaseID, []types.ChunkType{types.ChunkTypeText})
+	if err != nil {
+		d.failGeneration(ctx, task, fmt.Sprintf("Failed to list chunks: %v", err))
+		return
+	}
+	if len(chunkIDs) == 0 {
+		d.failGeneration(ctx, task, "The knowledge base has no chunks")
+		return
+	}
+	rand.Shuffle(len(chunkIDs), func(i, j int) { chunkIDs[i], chunkIDs[j] = chunkIDs[j], chunkIDs[i] })
+	if maxChunks := task.QuestionCount * generationChunksPerQuestion; len(chunkIDs) > maxChunks {
+		chunkIDs = chunkIDs[:maxChunks]
+	}
+	logger.Infof(ctx, "Generating %d questions from up to %d chunks of knowledge base %s",
+		task.QuestionCount, len(chunkIDs), task.KnowledgeBaseID)
+
+	checker := datasetgen.NewChecker(cfg.MinAnswerOverlap)
+	records := make([]*types.DatasetRecord, 0, task.QuestionCount)
+	processed, rejected, failures := 0, 0, 0
+	for start := 0; start < len(chunkIDs) && len(records) < task.QuestionCount; start += generationBatchSize {
+		end := min(start+generationBatchSize, len(chunkIDs))
+		chunks, err := d.chunkRepo.ListChunksByID(ctx, task.TenantID, chunkIDs[start:end])
+		if err != nil {
+			d.failGeneration(ctx, task, fmt.Sprintf("Failed to get chunks: %v", err))
+			return
+		}
+		for _, chunk := range chunks {
+			if len(records) >= task.QuestionCount {
+				break
+			}
+			if utf8.RuneCountInString(strings.TrimSpace(chunk.Content)) < cfg.MinChunkLength {
+				continue
+			}
+			if d.isGenerationCancelled(ctx, task) {
+				return
+			}
+
+			question, answer, reason, err := d.generateQuestion(ctx, chatModel, checker, chunk.Content)
+			processed++
+			switch {
+			case err != nil:
+				rejected++
+				failures++
+				logger.Warnf(ctx, "Failed to generate question for chunk %s: %v", chunk.ID, err)
+				if failures >= maxGenerationFailures {
+					d.failGeneration(ctx, task, fmt.Sprintf("Chat model failed: %v", err))
+					return
+				}
+			case reason != "":
+				rejected++
+				failures = 0
+				logger.Debugf(ctx, "Rejected question for chunk %s: %s", chunk.ID, reason)
+			default:
+				failures = 0
+				records = append(records, &types.DatasetRecord{
+					QuestionID: len(records) + 1,
+					Question:   question,
+					Answer:     answer,
+					Passages:   types.StringArray{chunk.Content},
+					ChunkIDs:   types.StringArray{chunk.ID},
+				})
+			}
+			if err := d.datasetRepo.UpdateGenerationProgress(ctx,
+				task.ID, processed, len(records), rejected); err != nil {
+				logger.Errorf(ctx, "Failed to update dataset generation progress: %v", err)
+			}
+		}
+	}
+	if len(records) == 0 {
+		d.failGeneration(ctx, task, "No generated question passed the quality checks")
+		return
+	}
+	if d.isGenerationCancelled(ctx, task) {
+		return
+	}
+
+	dataset := &types.Dataset{
+		TenantID:    task.TenantID,
+		Name:        task.Name,
+		Description: task.Description,
+		Format:      types.DatasetFormatGenerated,
+		RecordCount: len(records),
+	}
+	if err := d.datasetRepo.CreateDataset(ctx, dataset, records); err != nil {
+		d.failGeneration(ctx, task, fmt.Sprintf("Failed to store dataset: %v", err))
+		return
+	}
+	completed, err := d.datasetRepo.CompleteGenerationTask(ctx, task.ID, dataset.ID)
+	if err != nil || !completed {
+		// The task was cancelled while the dataset was stored, or could not be completed
+		logger.Infof(ctx, "Dataset generation task %s was not completed, deleting dataset %s", task.ID, dataset.ID)
+		if err := d.datasetRepo.DeleteDataset(ctx, task.TenantID, dataset.ID); err != nil {
+			logger.Errorf(ctx, "Failed to delete dataset %s: %v", dataset.ID, err)
+		}
+		if err != nil {
+			d.failGeneration(ctx, task, fmt.Sprintf("Failed to complete task: %v", err))
+		}
+		return
+	}
+	logger.Infof(ctx, "Dataset generation task %s completed: dataset=%s, chunks=%d, accepted=%d, rejected=%d",
+		task.ID, dataset.ID, processed, len(records), rejected)
+}
+
+// generateQuestion asks the chat model for a question and a reference answer grounded in the chunk,
+// reason is the rejection reason of the quality checks, empty if the pair is accepted
+func (d *DatasetService) generateQuestion(ctx context.Context,
+	chatModel chat.Chat, checker *datasetgen.Checker, content string,
+) (question string, answer string, reason string, err error) {
+	cfg := d.config.DatasetGeneration
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+	thinking := false
+	resp, err := chatModel.Chat(ctx, []chat.Message{
+		{Role: "system", Content: cfg.Prompt},
+		{Role: "user", Content: content},
+	}, &chat.ChatOptions{
+		Temperature: 0.7,
+		MaxTokens:   512,
+		Thinking:    &thinking,
+	})
+	if err != nil {
+		return "", "", "", err
+	}
+	question, answer, skipped := datasetgen.ParseResponse(resp.Content)
+	if skipped {
+		return "", "", datasetgen.ReasonSkipped, nil
+	}
+	return question, answer, checker.Check(content, question, answer), nil
+}
+
+// isGenerationCancelled reports whether the task was cancelled while it runs
+func (d *DatasetService) isGenerationCancelled(ctx context.Context, task *types.DatasetGenerationTask) bool {
+	current, err := d.datasetRepo.GetGenerationTask(ctx, task.TenantID, task.ID)
+	if err == nil && current.Status == types.DatasetGenerationStatusCancelled {
+		logger.Infof(ctx, "Dataset generation task %s was cancelled, stopping generation", task.ID)
+		return true
+	}
+	return false
+}
+
+// failGeneration marks a dataset generation task as failed, failures to do so are only logged
+func (d *DatasetService) failGeneration(ctx context.Context, task *types.DatasetGenerationTask, errorMsg string) {
+	logger.Errorf(ctx, "Dataset generation task %s failed: %s", task.ID, errorMsg)
+	if _, err := d.datasetRepo.UpdateGenerationStatus(ctx,
+		task.ID, types.DatasetGenerationStatusFailed, errorMsg); err != nil {
+		logger.Errorf(ctx, "Failed to update dataset generation task status: %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+// Package datasetfile parses the files of evaluation datasets. A dataset is a list of records,
+// each a question with its reference answer and its gold passages, chunk IDs or knowledge IDs.
+// Parquet, JSON Lines and CSV files use the same field names:
+// id, question (or query), answer, passages, chunk_ids and knowledge_ids.
+package datasetfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/parquet-go/parquet-go"
+)
+
+// Supported dataset file formats
+const (
+	FormatParquet = "parquet"
+	FormatJSONL   = "jsonl"
+	FormatCSV     = "csv"
+)
+
+// record is a record as it is written in a dataset file
+type record struct {
+	ID           *int64   `json:"id" parquet:"id,optional"`
+	Question     string   `json:"question" parquet:"question,optional"`
+	Query        string   `json:"query" parquet:"query,optional"`
+	Answer       string   `json:"answer" parquet:"answer,optional"`
+	Passages     []string `json:"passages" parquet:"passages,list,optional"`
+	ChunkIDs     []string `json:"chunk_ids" parquet:"chunk_ids,list,optional"`
+	KnowledgeIDs []string `json:"knowledge_ids" parquet:"knowledge_ids,list,optional"`
+}
+
+// Format returns the format of a dataset file by its extension, empty if it is not supported
+func Format(fileName string) string {
+	switch strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), ".")) {
+	case "parquet":
+		return FormatParquet
+	case "jsonl", "ndjson":
+		return FormatJSONL
+	case "csv":
+		return FormatCSV
+	}
+	return ""
+}
+
+// Parse parses the records of a dataset file. A record without id gets its position, starting at 1.
+// Every record needs a question and a reference answer or gold passages, chunk IDs or knowledge IDs.
+func Parse(format string, content []byte) ([]*types.DatasetRecord, error) {
+	var records []record
+	var err error
+	switch format {
+	case FormatParquet:
+		records, err = parquet.Read[record](bytes.NewReader(content), int64(len(content)))
+	case FormatJSONL:
+		records, err = parseJSONL(content)
+	case FormatCSV:
+		records, err = parseCSV(content)
+	default:
+		return nil, fmt.Errorf("unsupported dataset format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("dataset has no records")
+	}
+
+	result := make([]*types.DatasetRecord, 0, len(records))
+	seen := make(map[int]bool, len(records))
+	for i, r := range records {
+		questionID := i + 1
+		if r.ID != nil {
+			questionID = int(*r.ID)
+		}
+		if seen[questionID] {
+			return nil, fmt.Errorf("record %d: duplicate id %d", i+1, questionID)
+		}
+		seen[questionID] = true
+
+		question := strings.TrimSpace(r.Question)
+		if question == "" {
+			question = strings.TrimSpace(r.Query)
+		}
+		if question == "" {
+			return nil, fmt.Errorf("record %d: question is required", i+1)
+		}
+		datasetRecord := &types.DatasetRecord{
+			QuestionID:   questionID,
+			Question:     question,
+			Answer:       strings.TrimSpace(r.Answer),
+			Passages:     nonEmpty(r.Passages),
+			ChunkIDs:     nonEmpty(r.ChunkIDs),
+			KnowledgeIDs: nonEmpty(r.KnowledgeIDs),
+		}
+		if datasetRecord.Answer == "" && len(datasetRecord.Passages) == 0 &&
+			len(datasetRecord.ChunkIDs) == 0 && len(datasetRecord.KnowledgeIDs) == 0 {
+			return nil, fmt.Errorf("record %d: an answer, passages, chunk_ids or knowledge_ids are required", i+1)
+		}
+		result = append(result, datasetRecord)
+	}
+	return result, nil
+}
+
+// parseJSONL parses a JSON object per line, blank lines are skipped
+func parseJSONL(content []byte) ([]record, error) {
+	var records []record
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var r record
+		if err := json.Unmarshal(text, &r); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// parseCSV parses a header row naming the fields and a record per row. The cells of the list fields
+// hold a JSON array of strings or a single value.
+func parseCSV(content []byte) ([]record, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\uFEFF"))))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["question"]; !ok {
+		if _, ok := columns["query"]; !ok {
+			return nil, fmt.Errorf("csv header has no question column")
+		}
+	}
+
+	var records []record
+	for row := 2; ; row++ {
+		cells, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(cells) {
+				return strings.TrimSpace(cells[i])
+			}
+			return ""
+		}
+		r := record{Question: cell("question"), Query: cell("query"), Answer: cell("answer")}
+		if id := cell("id"); id != "" {
+			value, err := strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: invalid id %q", row, id)
+			}
+			r.ID = &value
+		}
+		for name, field := range map[string]*[]string{
+			"passages": &r.Passages, "chunk_ids": &r.ChunkIDs, "knowledge_ids": &r.KnowledgeIDs,
+		} {
+			values, err := listCell(cell(name))
+			if err != nil {
+				return nil, fmt.Errorf("row %d: invalid %s: %w", row, name, err)
+			}
+			*field = values
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// listCell parses a CSV cell holding a JSON array of strings or a single value
+func listCell(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(value, "[") {
+		return []string{value}, nil
+	}
+	var values []string
+	if err := json.Unmarshal([]byte(value), &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// nonEmpty returns the trimmed values that are not blank
+func nonEmpty(values []string) types.StringArray {
+	var result types.StringArray
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package datasetfile
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParse(t *testing.T) {
+	type parquetRecord struct {
+		Query    string   `parquet:"query"`
+		Answer   string   `parquet:"answer"`
+		Passages []string `parquet:"passages,list"`
+	}
+	var parquetFile bytes.Buffer
+	if err := parquet.Write(&parquetFile, []parquetRecord{
+		{Query: "How do I reset my password?", Answer: "Use the reset link.", Passages: []string{"Reset link", " "}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		content  []byte
+		expected []*types.DatasetRecord
+		wantErr  bool
+	}{
+		{
+			name:    "parquet with a subset of the fields",
+			format:  FormatParquet,
+			content: parquetFile.Bytes(),
+			expected: []*types.DatasetRecord{{
+				QuestionID: 1,
+				Question:   "How do I reset my password?",
+				Answer:     "Use the reset link.",
+				Passages:   types.StringArray{"Reset link"},
+			}},
+		},
+		{
+			name:   "jsonl with ids and gold chunks",
+			format: FormatJSONL,
+			content: []byte(`{"id": 7, "question": "Refund period?", "chunk_ids": ["c1", "c2"]}` + "\n\n" +
+				`{"query": "Shipping cost?", "answer": "Free", "knowledge_ids": ["k1"]}` + "\n"),
+			expected: []*types.DatasetRecord{
+				{QuestionID: 7, Question: "Refund period?", ChunkIDs: types.StringArray{"c1", "c2"}},
+				{QuestionID: 2, Question: "Shipping cost?", Answer: "Free", KnowledgeIDs: types.StringArray{"k1"}},
+			},
+		},
+		{
+			name:   "csv with list cells",
+			format: FormatCSV,
+			content: []byte("\uFEFFid,Question,answer,passages,chunk_ids\n" +
+				"1,Opening hours?,9 to 5,\"[\"\"Open 9-5\"\", \"\"Closed Sunday\"\"]\",c9\n"),
+			expected: []*types.DatasetRecord{{
+				QuestionID: 1,
+				Question:   "Opening hours?",
+				Answer:     "9 to 5",
+				Passages:   types.StringArray{"Open 9-5", "Closed Sunday"},
+				ChunkIDs:   types.StringArray{"c9"},
+			}},
+		},
+		{
+			name:    "question is required",
+			format:  FormatJSONL,
+			content: []byte(`{"answer": "Free"}`),
+			wantErr: true,
+		},
+		{
+			name:    "a reference is required",
+			format:  FormatJSONL,
+			content: []byte(`{"question": "Shipping cost?"}`),
+			wantErr: true,
+		},
+		{
+			name:    "duplicate ids",
+			format:  FormatJSONL,
+			content: []byte(`{"id": 2, "question": "a", "answer": "b"}` + "\n" + `{"question": "c", "answer": "d"}`),
+			wantErr: true,
+		},
+		{
+			name:    "csv without question column",
+			format:  FormatCSV,
+			content: []byte("answer\nFree\n"),
+			wantErr: true,
+		},
+		{
+			name:    "empty file",
+			format:  FormatJSONL,
+			content: []byte("\n"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.format, tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for fileName, expected := range map[string]string{
+		"faq.parquet": FormatParquet,
+		"faq.JSONL":   FormatJSONL,
+		"faq.csv":     FormatCSV,
+		"faq.json":    "",
+	} {
+		if got := Format(fileName); got != expected {
+			t.Errorf("%s: expected %q, got %q", fileName, expected, got)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Package datasetgen parses and checks the question-answer pairs a chat model writes for knowledge base chunks
+// when an evaluation dataset is generated
+package datasetgen
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Reasons a generated pair is rejected
+const (
+	// ReasonSkipped means the model found nothing in the chunk worth asking about
+	ReasonSkipped = "skipped"
+	// ReasonMalformed means the response has no question or no answer
+	ReasonMalformed = "malformed"
+	// ReasonLength means the question or the answer is too short or too long
+	ReasonLength = "length"
+	// ReasonContextual means the question refers to the chunk instead of standing on its own,
+	// a user who never saw the chunk could not ask it
+	ReasonContextual = "contextual"
+	// ReasonUngrounded means too little of the answer comes from the chunk
+	ReasonUngrounded = "ungrounded"
+	// ReasonDuplicate means the question was already generated for another chunk
+	ReasonDuplicate = "duplicate"
+)
+
+const (
+	minQuestionLength = 5
+	maxQuestionLength = 200
+	minAnswerLength   = 2
+	maxAnswerLength   = 1000
+)
+
+// skipResponses are the responses of the model when a chunk is not worth a question
+var skipResponses = map[string]bool{"无": true, "none": true, "skip": true}
+
+// labelLine matches the "问题:" and "答案:" lines of a response
+var labelLine = regexp.MustCompile(`(?i)^\s*(?:[-*#]+\s*)?(问题|question|q|答案|参考答案|answer|a)\s*[:：]\s*(.*)$`)
+
+// contextualQuestion matches questions that point at the chunk they were written for
+var contextualQuestion = regexp.MustCompile(`(?i)(本文|上文|原文|文中|文章中|该段|这段|本段|以上|上述|这篇|该文档|本文档|材料中|资料中|` +
+	`\b(the|this|above|given)\s+(passage|text|document|context|article|excerpt|paragraph|section)\b)`)
+
+// ParseResponse extracts the question and the answer from a response formatted as
+// "问题: ..." and "答案: ..." lines, the answer may span the following lines.
+// skipped is true if the model answered that the chunk is not worth a question.
+func ParseResponse(content string) (question string, answer string, skipped bool) {
+	content = strings.TrimSpace(content)
+	if skipResponses[strings.ToLower(strings.Trim(content, "。.!！ "))] {
+		return "", "", true
+	}
+	var answerLines []string
+	inAnswer := false
+	for _, line := range strings.Split(content, "\n") {
+		if m := labelLine.FindStringSubmatch(line); m != nil {
+			switch strings.ToLower(m[1]) {
+			case "问题", "question", "q":
+				if question == "" {
+					question = strings.TrimSpace(m[2])
+				}
+				inAnswer = false
+			default:
+				if answerLines == nil {
+					answerLines = []string{m[2]}
+					inAnswer = true
+				} else {
+					inAnswer = false
+				}
+			}
+			continue
+		}
+		if inAnswer {
+			answerLines = append(answerLines, line)
+		}
+	}
+	return question, strings.TrimSpace(strings.Join(answerLines, "\n")), false
+}
+
+// Checker checks generated pairs, it remembers the accepted questions to reject duplicates
+type Checker struct {
+	// minAnswerOverlap is the least share of the answer's character bigrams that must appear in the chunk
+	minAnswerOverlap float64
+	seen             map[string]bool
+}
+
+// NewChecker creates a checker, answers sharing less than minAnswerOverlap of their character bigrams
+// with the chunk are rejected
+func NewChecker(minAnswerOverlap float64) *Checker {
+	return &Checker{minAnswerOverlap: minAnswerOverlap, seen: make(map[string]bool)}
+}
+
+// Check returns the reason the pair is rejected, or an empty string if it is accepted
+func (c *Checker) Check(chunk string, question string, answer string) string {
+	if question == "" || answer == "" {
+		return ReasonMalformed
+	}
+	if n := utf8.RuneCountInString(question); n < minQuestionLength || n > maxQuestionLength {
+		return ReasonLength
+	}
+	if n := utf8.RuneCountInString(answer); n < minAnswerLength || n > maxAnswerLength {
+		return ReasonLength
+	}
+	if contextualQuestion.MatchString(question) {
+		return ReasonContextual
+	}
+	if Overlap(answer, chunk) < c.minAnswerOverlap {
+		return ReasonUngrounded
+	}
+	key := normalize(question)
+	if c.seen[key] {
+		return ReasonDuplicate
+	}
+	c.seen[key] = true
+	return ""
+}
+
+// Overlap returns the share of the character bigrams of text that appear in source,
+// whitespace, punctuation and case are ignored
+func Overlap(text string, source string) float64 {
+	grams := bigrams(normalize(text))
+	if len(grams) == 0 {
+		return 0
+	}
+	sourceGrams := make(map[string]bool)
+	for _, gram := range bigrams(normalize(source)) {
+		sourceGrams[gram] = true
+	}
+	found := 0
+	for _, gram := range grams {
+		if sourceGrams[gram] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(grams))
+}
+
+// normalize lowercases the text and drops whitespace and punctuation
+func normalize(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func bigrams(text string) []string {
+	runes := []rune(text)
+	if len(runes) == 1 {
+		return []string{text}
+	}
+	grams := make([]string, 0, len(runes))
+	for i := 0; i+1 < len(runes); i++ {
+		grams = append(grams, string(runes[i:i+2]))
+	}
+	return grams
+}
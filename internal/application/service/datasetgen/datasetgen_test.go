@@ -0,0 +1,72 @@
+package datasetgen
+
+import "testing"
+
+func TestParseResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		question string
+		answer   string
+		skipped  bool
+	}{
+		{
+			name:     "chinese labels",
+			content:  "问题：年假有多少天？\n答案：入职满一年后每年 10 天。",
+			question: "年假有多少天？",
+			answer:   "入职满一年后每年 10 天。",
+		},
+		{
+			name:     "english labels with a multi-line answer",
+			content:  "Question: How are refunds paid?\nAnswer:\nTo the original card,\nwithin 7 days.",
+			question: "How are refunds paid?",
+			answer:   "To the original card,\nwithin 7 days.",
+		},
+		{
+			name:    "chunk not worth a question",
+			content: " 无。\n",
+			skipped: true,
+		},
+		{
+			name:     "missing answer",
+			content:  "问题: 加班如何调休？",
+			question: "加班如何调休？",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			question, answer, skipped := ParseResponse(tt.content)
+			if question != tt.question || answer != tt.answer || skipped != tt.skipped {
+				t.Errorf("ParseResponse() = %q, %q, %v, want %q, %q, %v",
+					question, answer, skipped, tt.question, tt.answer, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	chunk := "员工入职满一年后每年享有 10 天带薪年假，加班时长可在三个月内申请调休。"
+	checker := NewChecker(0.5)
+	tests := []struct {
+		name     string
+		question string
+		answer   string
+		reason   string
+	}{
+		{name: "accepted", question: "员工每年有多少天带薪年假？", answer: "入职满一年后每年 10 天。"},
+		{name: "duplicate", question: "员工每年有多少天带薪年假?", answer: "每年 10 天", reason: ReasonDuplicate},
+		{name: "missing answer", question: "加班如何调休？", reason: ReasonMalformed},
+		{name: "question too short", question: "年假？", answer: "10 天", reason: ReasonLength},
+		{name: "refers to the chunk", question: "根据上文，加班如何调休？", answer: "三个月内申请调休", reason: ReasonContextual},
+		{name: "refers to the passage", question: "What does the passage say about leave?", answer: "10 天",
+			reason: ReasonContextual},
+		{name: "answer not from the chunk", question: "加班费如何计算？", answer: "按基本工资的 1.5 倍支付", reason: ReasonUngrounded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reason := checker.Check(chunk, tt.question, tt.answer); reason != tt.reason {
+				t.Errorf("Check() = %q, want %q", reason, tt.reason)
+			}
+		})
+	}
+}
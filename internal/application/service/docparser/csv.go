@@ -0,0 +1,60 @@
+package docparser
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// parseCSV turns each row of a CSV document into a paragraph of "column: value" lines,
+// named by the header row. Empty cells are left out.
+func parseCSV(content []byte) (string, error) {
+	text, err := decode(content)
+	if err != nil {
+		return "", err
+	}
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var rows []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		lines := make([]string, 0, len(record))
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", columnName(header, i), value))
+		}
+		if len(lines) > 0 {
+			rows = append(rows, strings.Join(lines, "\n"))
+		}
+	}
+	return strings.Join(rows, "\n\n"), nil
+}
+
+// columnName returns the header of a column, or its position if it has none
+func columnName(header []string, i int) string {
+	if i < len(header) {
+		if name := strings.TrimSpace(header[i]); name != "" {
+			return name
+		}
+	}
+	return fmt.Sprintf("column %d", i+1)
+}
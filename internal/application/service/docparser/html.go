@@ -0,0 +1,13 @@
+package docparser
+
+import "github.com/Tencent/WeKnora/pkg/htmltext"
+
+// parseHTML extracts the text of an HTML document, leaving out scripts and styles and keeping block
+// elements in paragraphs of their own, so chunks can be split at them
+func parseHTML(content []byte) (string, error) {
+	text, err := decode(content)
+	if err != nil {
+		return "", err
+	}
+	return htmltext.Text(text), nil
+}
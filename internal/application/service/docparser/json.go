@@ -0,0 +1,130 @@
+package docparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// parseJSON turns JSON and JSON Lines documents into paragraphs of "path: value" lines. Each top-level value
+// is a record, so is each element of a top-level array; keys keep their order in the document.
+func parseJSON(content []byte) (string, error) {
+	text, err := decode(content)
+	if err != nil {
+		return "", err
+	}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+
+	var records []string
+	appendRecord := func(lines []string) {
+		if len(lines) > 0 {
+			records = append(records, strings.Join(lines, "\n"))
+		}
+	}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if token != json.Delim('[') {
+			var lines []string
+			if err := flattenJSON(decoder, token, "", &lines); err != nil {
+				return "", err
+			}
+			appendRecord(lines)
+			continue
+		}
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return "", err
+			}
+			var lines []string
+			if err := flattenJSON(decoder, token, "", &lines); err != nil {
+				return "", err
+			}
+			appendRecord(lines)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(records, "\n\n"), nil
+}
+
+// flattenJSON writes the scalar values of the JSON value starting with token as lines prefixed by their path
+func flattenJSON(decoder *json.Decoder, token json.Token, path string, lines *[]string) error {
+	switch token {
+	case json.Delim('{'):
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			value, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			if err := flattenJSON(decoder, value, joinJSONPath(path, fmt.Sprint(key)), lines); err != nil {
+				return err
+			}
+		}
+		_, err := decoder.Token()
+		return err
+	case json.Delim('['):
+		for i := 0; decoder.More(); i++ {
+			value, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			if err := flattenJSON(decoder, value, fmt.Sprintf("%s[%d]", path, i), lines); err != nil {
+				return err
+			}
+		}
+		_, err := decoder.Token()
+		return err
+	}
+
+	value := jsonScalar(token)
+	if value == "" {
+		return nil
+	}
+	if path == "" {
+		*lines = append(*lines, value)
+	} else {
+		*lines = append(*lines, path+": "+value)
+	}
+	return nil
+}
+
+// jsonScalar formats a JSON scalar, null and blank strings are empty
+func jsonScalar(token json.Token) string {
+	switch v := token.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// joinJSONPath appends a key to a path
+func joinJSONPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	var b bytes.Buffer
+	b.WriteString(path)
+	b.WriteByte('.')
+	b.WriteString(key)
+	return b.String()
+}
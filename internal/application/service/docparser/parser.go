@@ -0,0 +1,91 @@
+// Package docparser parses plain-text document formats in process, without the docreader service.
+// A parser extracts the text of a document, which is split into chunks the way docreader splits it,
+// so the chunks have the shape of docreader chunks and go through the same chunking strategies.
+// Native parsers do not extract images.
+package docparser
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Tencent/WeKnora/internal/application/service/chunker"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/services/docreader/src/proto"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// Parser extracts the text of a document
+type Parser interface {
+	// Parse returns the text of the document content
+	Parse(content []byte) (string, error)
+}
+
+// ParserFunc adapts a function to a Parser
+type ParserFunc func(content []byte) (string, error)
+
+// Parse calls f(content)
+func (f ParserFunc) Parse(content []byte) (string, error) {
+	return f(content)
+}
+
+// parsers are the registered parsers by file type
+var parsers = map[string]Parser{
+	"txt":      ParserFunc(parseText),
+	"md":       ParserFunc(parseText),
+	"markdown": ParserFunc(parseText),
+	"html":     ParserFunc(parseHTML),
+	"htm":      ParserFunc(parseHTML),
+	"csv":      ParserFunc(parseCSV),
+	"json":     ParserFunc(parseJSON),
+	"jsonl":    ParserFunc(parseJSON),
+}
+
+// Register registers the parser of a file type, replacing the parser registered before.
+// It is not safe to call concurrently with parsing and is meant for package initialization.
+func Register(fileType string, parser Parser) {
+	parsers[strings.ToLower(fileType)] = parser
+}
+
+// Supports reports whether a parser is registered for the file type
+func Supports(fileType string) bool {
+	_, ok := parsers[strings.ToLower(fileType)]
+	return ok
+}
+
+// Read parses a document with the parser of its file type and splits its text into chunks
+// with the chunking config, as docreader would
+func Read(fileType string, content []byte, config types.ChunkingConfig) ([]*proto.Chunk, error) {
+	parser, ok := parsers[strings.ToLower(fileType)]
+	if !ok {
+		return nil, fmt.Errorf("no native parser for file type: %s", fileType)
+	}
+	text, err := parser.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s document: %w", fileType, err)
+	}
+	return chunker.SplitText(config, text), nil
+}
+
+// decode decodes the content as UTF-8 without byte order mark, content that is not valid UTF-8
+// is decoded as GB18030, which covers GBK and GB2312
+func decode(content []byte) (string, error) {
+	text := strings.TrimPrefix(string(content), "\uFEFF")
+	if utf8.ValidString(text) {
+		return text, nil
+	}
+	decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(content)
+	if err != nil {
+		return "", fmt.Errorf("content is neither UTF-8 nor GB18030: %w", err)
+	}
+	return string(decoded), nil
+}
+
+// parseText returns plain text and Markdown as they are, the Markdown strategy of the chunker uses its headings
+func parseText(content []byte) (string, error) {
+	text, err := decode(content)
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(text, "\r\n", "\n"), nil
+}
@@ -0,0 +1,94 @@
+package docparser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestParse(t *testing.T) {
+	gbk, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte("你好，世界"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		fileType string
+		content  []byte
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "text with BOM and CRLF",
+			fileType: "txt",
+			content:  []byte("\uFEFFfirst line\r\nsecond line"),
+			expected: "first line\nsecond line",
+		},
+		{
+			name:     "GBK text",
+			fileType: "TXT",
+			content:  gbk,
+			expected: "你好，世界",
+		},
+		{
+			name:     "HTML drops scripts and keeps blocks",
+			fileType: "html",
+			content: []byte("<html><head><title>t</title></head><body><h1>Title</h1><script>x()</script>" +
+				"<p>Hello   <b>world</b></p><table><tr><td>a</td><td>b</td></tr></table></body></html>"),
+			expected: "Title\n\nHello world\n\na b",
+		},
+		{
+			name:     "CSV rows become records",
+			fileType: "csv",
+			content:  []byte("name,age,\nAlice,30,x\nBob,,\n"),
+			expected: "name: Alice\nage: 30\ncolumn 3: x\n\nname: Bob",
+		},
+		{
+			name:     "JSON array elements become records",
+			fileType: "json",
+			content:  []byte(`[{"b": 1, "a": {"c": "x", "d": [true, null]}}, {"e": ""}, "plain"]`),
+			expected: "b: 1\na.c: x\na.d[0]: true\n\nplain",
+		},
+		{
+			name:     "JSON lines",
+			fileType: "jsonl",
+			content:  []byte("{\"q\": \"one\"}\n{\"q\": \"two\"}\n"),
+			expected: "q: one\n\nq: two",
+		},
+		{
+			name:     "invalid JSON",
+			fileType: "json",
+			content:  []byte(`{"a": `),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := parsers[strings.ToLower(tt.fileType)]
+			got, err := parser.Parse(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	if _, err := Read("pdf", []byte("%PDF"), types.ChunkingConfig{}); err == nil {
+		t.Error("expected an error for a file type without native parser")
+	}
+	chunks, err := Read("md", []byte("# Title\n\nSome text."), types.ChunkingConfig{ChunkSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 1 || chunks[0].Content != "# Title\n\nSome text." {
+		t.Errorf("expected a single chunk with the document, got %v", chunks)
+	}
+}
@@ -0,0 +1,182 @@
+// Package evalcompare compares two evaluation tasks parameter by parameter, metric by metric and question by question
+package evalcompare
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// regressionTolerance is the decrease of a metric below which a question does not count as regressed
+const regressionTolerance = 1e-9
+
+// Metric is a metric compared between two results
+type Metric struct {
+	Name  string                             // Metric name, the JSON name of its field
+	Judge bool                               // Whether the metric is scored by a judge model
+	Field func(*types.MetricResult) *float64 // Field accessor for result
+}
+
+// Params returns the parameters that differ between two tasks, sorted by path.
+// The knowledge base is left out unless the task evaluates an existing one, otherwise each task
+// evaluates a fresh knowledge base of its own.
+func Params(base, target *types.EvaluationTask) []*types.ConfigFieldDiff {
+	baseParams, targetParams := evaluationParams(base), evaluationParams(target)
+	paths := make([]string, 0, len(baseParams)+len(targetParams))
+	for path := range baseParams {
+		paths = append(paths, path)
+	}
+	for path := range targetParams {
+		if _, ok := baseParams[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var diffs []*types.ConfigFieldDiff
+	for _, path := range paths {
+		if baseParams[path] != targetParams[path] {
+			diffs = append(diffs, &types.ConfigFieldDiff{Path: path, Old: baseParams[path], New: targetParams[path]})
+		}
+	}
+	return diffs
+}
+
+// evaluationParams flattens the parameters of a task to their values by JSON path
+func evaluationParams(task *types.EvaluationTask) map[string]string {
+	params := map[string]string{
+		"dataset_id":         task.DatasetID,
+		"embedding_model_id": task.EmbeddingModelID,
+		"use_existing_kb":    strconv.FormatBool(task.UseExistingKB),
+		"judge_model_id":     task.JudgeModelID,
+	}
+	if task.Params == nil {
+		return params
+	}
+	b, err := json.Marshal(task.Params)
+	if err != nil {
+		return params
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return params
+	}
+	if !task.UseExistingKB {
+		delete(values, "knowledge_base_id")
+	}
+	delete(values, "knowledge_bases")
+	flattenParams("", values, params)
+	return params
+}
+
+// flattenParams writes the leaf values of a JSON object to params, prefixed by their path
+func flattenParams(prefix string, values map[string]interface{}, params map[string]string) {
+	for key, value := range values {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flattenParams(path, v, params)
+		case string:
+			params[path] = v
+		case nil:
+			params[path] = ""
+		case []interface{}:
+			b, _ := json.Marshal(v)
+			params[path] = string(b)
+		default:
+			params[path] = fmt.Sprint(v)
+		}
+	}
+}
+
+// Metrics compares the metrics of a base and a target result, a missing result scores 0.
+// The judge metrics are compared only if both results were judged and their judgements did not fail.
+func Metrics(metrics []Metric, base, target *types.MetricResult, judged bool) []*types.MetricDiff {
+	if base == nil {
+		base = &types.MetricResult{}
+	}
+	if target == nil {
+		target = &types.MetricResult{}
+	}
+	diffs := make([]*types.MetricDiff, 0, len(metrics))
+	for _, m := range metrics {
+		if m.Judge && (!judged || base.GenerationMetrics.JudgeFailures[m.Name] > 0 ||
+			target.GenerationMetrics.JudgeFailures[m.Name] > 0) {
+			continue
+		}
+		baseScore, targetScore := *m.Field(base), *m.Field(target)
+		diffs = append(diffs, &types.MetricDiff{
+			Name:   m.Name,
+			Base:   baseScore,
+			Target: targetScore,
+			Delta:  targetScore - baseScore,
+		})
+	}
+	return diffs
+}
+
+// Questions pairs the question results of two tasks by question ID,
+// regressed questions come first and questions are ordered by ID otherwise
+func Questions(metrics []Metric,
+	base, target []*types.EvaluationQuestion, judged bool,
+) []*types.EvaluationQuestionDiff {
+	diffs := make(map[int]*types.EvaluationQuestionDiff, len(base))
+	for _, question := range base {
+		diffs[question.QuestionID] = &types.EvaluationQuestionDiff{
+			QuestionID: question.QuestionID,
+			Question:   question.Question,
+			Base:       question,
+		}
+	}
+	for _, question := range target {
+		diff, ok := diffs[question.QuestionID]
+		if !ok {
+			diff = &types.EvaluationQuestionDiff{QuestionID: question.QuestionID, Question: question.Question}
+			diffs[question.QuestionID] = diff
+		}
+		diff.Target = question
+	}
+
+	result := make([]*types.EvaluationQuestionDiff, 0, len(diffs))
+	for _, diff := range diffs {
+		if diff.Base != nil && diff.Target != nil {
+			diff.Metrics = Metrics(metrics, diff.Base.Metric, diff.Target.Metric, judged)
+			for _, metric := range diff.Metrics {
+				if metric.Delta < -regressionTolerance {
+					diff.Regressed = true
+				}
+			}
+			diff.AddedChunkIDs = missingChunkIDs(diff.Target.RetrievedChunkIDs, diff.Base.RetrievedChunkIDs)
+			diff.RemovedChunkIDs = missingChunkIDs(diff.Base.RetrievedChunkIDs, diff.Target.RetrievedChunkIDs)
+		}
+		result = append(result, diff)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Regressed != result[j].Regressed {
+			return result[i].Regressed
+		}
+		return result[i].QuestionID < result[j].QuestionID
+	})
+	return result
+}
+
+// missingChunkIDs returns the chunk IDs of ids that are not in others, in their order in ids
+func missingChunkIDs(ids, others []string) []string {
+	seen := make(map[string]bool, len(others))
+	for _, id := range others {
+		seen[id] = true
+	}
+	missing := []string{}
+	for _, id := range ids {
+		if !seen[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,186 @@
+package evalcompare
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+var testMetrics = []Metric{
+	{Name: "recall", Field: func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.Recall }},
+	{Name: "mrr", Field: func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.MRR }},
+	{Name: "faithfulness", Judge: true, Field: func(r *types.MetricResult) *float64 {
+		return &r.GenerationMetrics.Faithfulness
+	}},
+}
+
+func result(recall, mrr, faithfulness float64) *types.MetricResult {
+	return &types.MetricResult{
+		RetrievalMetrics:  types.RetrievalMetrics{Recall: recall, MRR: mrr},
+		GenerationMetrics: types.GenerationMetrics{Faithfulness: faithfulness},
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	tests := []struct {
+		name   string
+		base   *types.MetricResult
+		target *types.MetricResult
+		judged bool
+		want   []*types.MetricDiff
+	}{
+		{
+			name:   "judged tasks compare every metric",
+			base:   result(0.5, 0.5, 0.8),
+			target: result(0.75, 0.25, 0.8),
+			judged: true,
+			want: []*types.MetricDiff{
+				{Name: "recall", Base: 0.5, Target: 0.75, Delta: 0.25},
+				{Name: "mrr", Base: 0.5, Target: 0.25, Delta: -0.25},
+				{Name: "faithfulness", Base: 0.8, Target: 0.8, Delta: 0},
+			},
+		},
+		{
+			name:   "judge metrics are skipped unless both tasks were judged",
+			base:   result(0.5, 0.5, 0),
+			target: result(0.5, 0.5, 0.9),
+			want: []*types.MetricDiff{
+				{Name: "recall", Base: 0.5, Target: 0.5, Delta: 0},
+				{Name: "mrr", Base: 0.5, Target: 0.5, Delta: 0},
+			},
+		},
+		{
+			name: "failed judgements are not compared",
+			base: result(0.5, 0.5, 0.8),
+			target: &types.MetricResult{
+				RetrievalMetrics:  types.RetrievalMetrics{Recall: 0.5, MRR: 0.5},
+				GenerationMetrics: types.GenerationMetrics{JudgeFailures: map[string]int{"faithfulness": 1}},
+			},
+			judged: true,
+			want: []*types.MetricDiff{
+				{Name: "recall", Base: 0.5, Target: 0.5, Delta: 0},
+				{Name: "mrr", Base: 0.5, Target: 0.5, Delta: 0},
+			},
+		},
+		{
+			name:   "missing result scores 0",
+			target: result(1, 0.5, 0),
+			want: []*types.MetricDiff{
+				{Name: "recall", Base: 0, Target: 1, Delta: 1},
+				{Name: "mrr", Base: 0, Target: 0.5, Delta: 0.5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Metrics(testMetrics, tt.base, tt.target, tt.judged)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Metrics() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuestions(t *testing.T) {
+	question := func(id int, metric *types.MetricResult, chunkIDs ...string) *types.EvaluationQuestion {
+		return &types.EvaluationQuestion{QuestionID: id, Question: "q", Metric: metric, RetrievedChunkIDs: chunkIDs}
+	}
+	base := []*types.EvaluationQuestion{
+		question(1, result(1, 1, 0), "a", "b"),
+		question(2, result(0.5, 0.5, 0), "c", "d"),
+		question(3, result(1, 1, 0.9), "e"),
+		question(4, result(1, 1, 0), "f"),
+	}
+	target := []*types.EvaluationQuestion{
+		question(1, result(1, 1, 0), "b", "a"),      // reordered, unchanged metrics
+		question(2, result(0.5, 0.25, 0), "c", "x"), // MRR dropped
+		question(3, result(1, 1, 0.1), "e"),         // only a judge metric dropped
+		question(5, result(1, 1, 0), "y"),           // only in the target
+	}
+
+	got := Questions(testMetrics, base, target, false)
+	var order []int
+	for _, diff := range got {
+		order = append(order, diff.QuestionID)
+	}
+	if want := []int{2, 1, 3, 4, 5}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("question order = %v, want %v", order, want)
+	}
+
+	regressed := got[0]
+	if !regressed.Regressed || len(regressed.Metrics) != 2 || regressed.Metrics[1].Delta != -0.25 {
+		t.Errorf("question 2 = %+v, want a regression of mrr by 0.25", regressed)
+	}
+	if !reflect.DeepEqual(regressed.AddedChunkIDs, []string{"x"}) ||
+		!reflect.DeepEqual(regressed.RemovedChunkIDs, []string{"d"}) {
+		t.Errorf("question 2 chunks added %v, removed %v, want [x] and [d]",
+			regressed.AddedChunkIDs, regressed.RemovedChunkIDs)
+	}
+	if reordered := got[1]; reordered.Regressed ||
+		len(reordered.AddedChunkIDs) != 0 || len(reordered.RemovedChunkIDs) != 0 {
+		t.Errorf("question 1 = %+v, want no regression and no chunk changes", reordered)
+	}
+	if got[2].Regressed {
+		t.Errorf("question 3 regressed on a judge metric of unjudged tasks")
+	}
+	if onlyBase := got[3]; onlyBase.Target != nil || onlyBase.Metrics != nil {
+		t.Errorf("question 4 = %+v, want no target and no metrics", onlyBase)
+	}
+	if onlyTarget := got[4]; onlyTarget.Base != nil || onlyTarget.Target == nil || onlyTarget.Metrics != nil {
+		t.Errorf("question 5 = %+v, want only a target", onlyTarget)
+	}
+
+	judged := Questions(testMetrics, base, target, true)
+	if judged[1].QuestionID != 3 || !judged[1].Regressed {
+		t.Errorf("question 3 should regress on faithfulness when both tasks were judged, got %+v", judged[1])
+	}
+}
+
+func TestParams(t *testing.T) {
+	task := func(kbID string, existing bool, topK int) *types.EvaluationTask {
+		return &types.EvaluationTask{
+			DatasetID:     "default",
+			UseExistingKB: existing,
+			Params: &types.ChatManage{
+				KnowledgeBaseID: kbID,
+				EmbeddingTopK:   topK,
+			},
+		}
+	}
+	tests := []struct {
+		name   string
+		base   *types.EvaluationTask
+		target *types.EvaluationTask
+		want   []string
+	}{
+		{
+			name:   "fresh knowledge bases are not compared",
+			base:   task("kb1", false, 10),
+			target: task("kb2", false, 10),
+		},
+		{
+			name:   "existing knowledge bases are compared",
+			base:   task("kb1", true, 10),
+			target: task("kb2", true, 10),
+			want:   []string{"knowledge_base_id"},
+		},
+		{
+			name:   "changed parameters sorted by path",
+			base:   task("kb1", false, 10),
+			target: task("kb1", true, 20),
+			want:   []string{"embedding_top_k", "knowledge_base_id", "use_existing_kb"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			for _, diff := range Params(tt.base, tt.target) {
+				paths = append(paths, diff.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("Params() paths = %v, want %v", paths, tt.want)
+			}
+		})
+	}
+}
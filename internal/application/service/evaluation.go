@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/Tencent/WeKnora/internal/config"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
 	"github.com/Tencent/WeKnora/internal/logger"
 	"github.com/Tencent/WeKnora/internal/types"
 	"github.com/Tencent/WeKnora/internal/types/interfaces"
@@ -33,7 +34,7 @@ type EvaluationService struct {
 	sessionService       interfaces.SessionService       // Service for chat sessions
 	modelService         interfaces.ModelService         // Service for model operations
 
-	evaluationMemoryStorage *evaluationMemoryStorage // In-memory storage for evaluation tasks
+	evaluationRepo interfaces.EvaluationRepository // Storage of evaluation tasks and their results
 }
 
 func NewEvaluationService(
@@ -43,186 +44,129 @@ func NewEvaluationService(
 	knowledgeService interfaces.KnowledgeService,
 	sessionService interfaces.SessionService,
 	modelService interfaces.ModelService,
+	evaluationRepo interfaces.EvaluationRepository,
 ) interfaces.EvaluationService {
-	evaluationMemoryStorage := newEvaluationMemoryStorage()
 	return &EvaluationService{
-		config:                  config,
-		dataset:                 dataset,
-		knowledgeBaseService:    knowledgeBaseService,
-		knowledgeService:        knowledgeService,
-		sessionService:          sessionService,
-		modelService:            modelService,
-		evaluationMemoryStorage: evaluationMemoryStorage,
+		config:               config,
+		dataset:              dataset,
+		knowledgeBaseService: knowledgeBaseService,
+		knowledgeService:     knowledgeService,
+		sessionService:       sessionService,
+		modelService:         modelService,
+		evaluationRepo:       evaluationRepo,
 	}
 }
 
-// evaluationMemoryStorage stores evaluation tasks in memory with thread-safe access
-type evaluationMemoryStorage struct {
-	store map[string]*types.EvaluationDetail // Map of taskID to evaluation details
-	mu    *sync.RWMutex                      // Read-write lock for concurrent access
-}
-
-func newEvaluationMemoryStorage() *evaluationMemoryStorage {
-	res := &evaluationMemoryStorage{
-		store: make(map[string]*types.EvaluationDetail),
-		mu:    &sync.RWMutex{},
+// judgeConfig returns the prompts of the judge metrics, nil if they are not configured
+func (e *EvaluationService) judgeConfig() *config.JudgeConfig {
+	if e.config.Evaluation == nil || e.config.Evaluation.Judge == nil {
+		return nil
 	}
-	return res
-}
-
-func (e *evaluationMemoryStorage) register(params *types.EvaluationDetail) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-	logger.Infof(context.Background(), "Registering evaluation task: %s", params.Task.ID)
-	e.store[params.Task.ID] = params
-}
-
-func (e *evaluationMemoryStorage) get(taskID string) (*types.EvaluationDetail, error) {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-	logger.Infof(context.Background(), "Getting evaluation task: %s", taskID)
-	res, ok := e.store[taskID]
-	if !ok {
-		return nil, errors.New("task not found")
+	judge := e.config.Evaluation.Judge
+	if judge.FaithfulnessPrompt == "" || judge.AnswerRelevancePrompt == "" ||
+		judge.ContextPrecisionPrompt == "" || judge.ContextRecallPrompt == "" {
+		return nil
 	}
-	return res, nil
+	return judge
 }
 
-func (e *evaluationMemoryStorage) update(taskID string, fn func(params *types.EvaluationDetail)) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-	params, ok := e.store[taskID]
-	if !ok {
-		return errors.New("task not found")
+// saveTask stores the progress of an evaluation task, failures are only logged
+func (e *EvaluationService) saveTask(ctx context.Context, task *types.EvaluationTask) {
+	if err := e.evaluationRepo.UpdateTask(ctx, task); err != nil {
+		logger.Errorf(ctx, "Failed to update evaluation task: %v, task ID: %s", err, task.ID)
 	}
-	fn(params)
-	return nil
 }
 
 func (e *EvaluationService) EvaluationResult(ctx context.Context, taskID string) (*types.EvaluationDetail, error) {
 	logger.Info(ctx, "Start getting evaluation result")
 	logger.Infof(ctx, "Task ID: %s", taskID)
 
-	detail, err := e.evaluationMemoryStorage.get(taskID)
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	task, err := e.evaluationRepo.GetTask(ctx, tenantID, taskID)
 	if err != nil {
 		logger.Errorf(ctx, "Failed to get evaluation task: %v", err)
 		return nil, err
 	}
-
-	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
-	logger.Infof(
-		ctx,
-		"Checking tenant ID match, task tenant ID: %d, current tenant ID: %d",
-		detail.Task.TenantID, tenantID,
-	)
-
-	if tenantID != detail.Task.TenantID {
-		logger.Error(ctx, "Tenant ID mismatch")
-		return nil, errors.New("tenant ID does not match")
+	questions, err := e.evaluationRepo.ListQuestions(ctx, tenantID, taskID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list evaluation questions: %v", err)
+		return nil, err
 	}
+	detail := &types.EvaluationDetail{Task: task, Params: task.Params, Metric: task.Metric, Questions: questions}
 
 	logger.Info(ctx, "Evaluation result retrieved successfully")
 	return detail, nil
 }
 
+// ListEvaluations lists the evaluation tasks of the tenant with their parameters and metrics, newest first
+func (e *EvaluationService) ListEvaluations(ctx context.Context,
+	pagination *types.Pagination,
+) (*types.PageResult, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	tasks, total, err := e.evaluationRepo.ListTasks(ctx, tenantID, pagination)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list evaluation tasks: %v", err)
+		return nil, err
+	}
+	details := make([]*types.EvaluationDetail, 0, len(tasks))
+	for _, task := range tasks {
+		details = append(details, &types.EvaluationDetail{Task: task, Params: task.Params, Metric: task.Metric})
+	}
+	return types.NewPageResult(total, pagination, details), nil
+}
+
 // Evaluation starts a new evaluation task with given parameters
 // datasetID: ID of the dataset to evaluate against
 // knowledgeBaseID: ID of the knowledge base to use (empty to create new)
 // chatModelID: ID of the chat model to evaluate
 // rerankModelID: ID of the rerank model to evaluate
+// useExistingKB: evaluate against the knowledge base as it is instead of ingesting the dataset passages
+// into a fresh knowledge base with its models
+// judgeModelID: ID of the chat model scoring the judge metrics (empty to skip them)
 func (e *EvaluationService) Evaluation(ctx context.Context,
-	datasetID string, knowledgeBaseID string, chatModelID string, rerankModelID string,
+	datasetID string, knowledgeBaseID string, chatModelID string, rerankModelID string, useExistingKB bool,
+	judgeModelID string,
 ) (*types.EvaluationDetail, error) {
 	logger.Info(ctx, "Start evaluation")
-	logger.Infof(ctx, "Dataset ID: %s, Knowledge Base ID: %s, Chat Model ID: %s, Rerank Model ID: %s",
-		datasetID, knowledgeBaseID, chatModelID, rerankModelID)
+	logger.Infof(ctx, "Dataset ID: %s, Knowledge Base ID: %s, Chat Model ID: %s, Rerank Model ID: %s, "+
+		"existing KB: %v, Judge Model ID: %s",
+		datasetID, knowledgeBaseID, chatModelID, rerankModelID, useExistingKB, judgeModelID)
 
 	// Get tenant ID from context for multi-tenancy support
 	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
 	logger.Infof(ctx, "Tenant ID: %d", tenantID)
 
-	// Handle knowledge base creation if not provided
-	if knowledgeBaseID == "" {
-		logger.Info(ctx, "No knowledge base ID provided, creating new knowledge base")
-		// Create new knowledge base with default evaluation settings
-		// 获取默认的嵌入模型和LLM模型
-		models, err := e.modelService.ListModels(ctx)
-		if err != nil {
-			logger.Errorf(ctx, "Failed to list models: %v", err)
+	// Set default values for optional parameters
+	if datasetID == "" {
+		datasetID = types.DefaultDatasetID
+		logger.Info(ctx, "Using default dataset")
+	} else if datasetID != types.DefaultDatasetID {
+		if _, err := e.dataset.GetDataset(ctx, datasetID); err != nil {
+			logger.Errorf(ctx, "Failed to get dataset: %v", err)
 			return nil, err
 		}
+	}
 
-		var embeddingModelID, llmModelID string
-		for _, model := range models {
-			if model.Type == types.ModelTypeEmbedding {
-				embeddingModelID = model.ID
-			}
-			if model.Type == types.ModelTypeKnowledgeQA {
-				llmModelID = model.ID
-			}
-		}
-
-		if embeddingModelID == "" || llmModelID == "" {
-			return nil, fmt.Errorf("no default models found for evaluation")
-		}
-
-		kb, err := e.knowledgeBaseService.CreateKnowledgeBase(ctx, &types.KnowledgeBase{
-			Name:             "evaluation",
-			Description:      "evaluation",
-			EmbeddingModelID: embeddingModelID,
-			SummaryModelID:   llmModelID,
-		})
-		if err != nil {
-			logger.Errorf(ctx, "Failed to create knowledge base: %v", err)
-			return nil, err
-		}
-		knowledgeBaseID = kb.ID
-		logger.Infof(ctx, "Created new knowledge base with ID: %s", knowledgeBaseID)
-	} else {
-		logger.Infof(ctx, "Using existing knowledge base ID: %s", knowledgeBaseID)
-		// Create evaluation-specific knowledge base based on existing one
-		kb, err := e.knowledgeBaseService.GetKnowledgeBaseByID(ctx, knowledgeBaseID)
-		if err != nil {
-			logger.Errorf(ctx, "Failed to get knowledge base: %v", err)
-			return nil, err
+	// The judge metrics need their prompts and a chat model
+	if judgeModelID != "" {
+		if e.judgeConfig() == nil {
+			return nil, werrors.NewValidationError("未配置裁判指标提示词")
 		}
-
-		kb, err = e.knowledgeBaseService.CreateKnowledgeBase(ctx, &types.KnowledgeBase{
-			Name:             "evaluation",
-			Description:      "evaluation",
-			EmbeddingModelID: kb.EmbeddingModelID,
-			SummaryModelID:   kb.SummaryModelID,
-		})
-		if err != nil {
-			logger.Errorf(ctx, "Failed to create knowledge base: %v", err)
-			return nil, err
+		model, err := e.modelService.GetModelByID(ctx, judgeModelID)
+		if err != nil || model.Type != types.ModelTypeKnowledgeQA {
+			logger.Errorf(ctx, "Failed to get judge model %s: %v", judgeModelID, err)
+			return nil, werrors.NewValidationError("裁判模型不存在或不可用")
 		}
-		knowledgeBaseID = kb.ID
-		logger.Infof(ctx, "Created new knowledge base with ID: %s based on existing one", knowledgeBaseID)
 	}
 
-	// Set default values for optional parameters
-	if datasetID == "" {
-		datasetID = "default"
-		logger.Info(ctx, "Using default dataset")
+	// Handle knowledge base creation if not provided
+	knowledgeBaseID, embeddingModelID, err := e.prepareKnowledgeBase(ctx, knowledgeBaseID, useExistingKB)
+	if err != nil {
+		return nil, err
 	}
 
 	if rerankModelID == "" {
-		// 获取默认的重排模型
-		models, err := e.modelService.ListModels(ctx)
-		if err == nil {
-			for _, model := range models {
-				if model.Type == types.ModelTypeRerank {
-					rerankModelID = model.ID
-					break
-				}
-			}
-		}
-		if rerankModelID == "" {
-			logger.Warnf(ctx, "No rerank model found, skipping rerank")
-		} else {
-			logger.Infof(ctx, "Using default rerank model: %s", rerankModelID)
-		}
+		rerankModelID = e.defaultRerankModel(ctx)
 	}
 
 	if chatModelID == "" {
@@ -248,44 +192,49 @@ func (e *EvaluationService) Evaluation(ctx context.Context,
 	logger.Infof(ctx, "Generated task ID: %s", taskID)
 
 	// Prepare evaluation detail with all parameters
-	detail := &types.EvaluationDetail{
-		Task: &types.EvaluationTask{
-			ID:        taskID,
-			TenantID:  tenantID,
-			DatasetID: datasetID,
-			Status:    types.EvaluationStatuePending,
-			StartTime: time.Now(),
-		},
+	task := &types.EvaluationTask{
+		ID:               taskID,
+		TenantID:         tenantID,
+		DatasetID:        datasetID,
+		EmbeddingModelID: embeddingModelID,
+		UseExistingKB:    useExistingKB,
+		JudgeModelID:     judgeModelID,
+		Status:           types.EvaluationStatuePending,
+		StartTime:        time.Now(),
 		Params: &types.ChatManage{
 			KnowledgeBaseID:  knowledgeBaseID,
-			VectorThreshold:  e.config.Conversation.VectorThreshold,
-			KeywordThreshold: e.config.Conversation.KeywordThreshold,
-			EmbeddingTopK:    e.config.Conversation.EmbeddingTopK,
+			VectorThreshold:  e.config.GetConversation().VectorThreshold,
+			KeywordThreshold: e.config.GetConversation().KeywordThreshold,
+			EmbeddingTopK:    e.config.GetConversation().EmbeddingTopK,
 			RerankModelID:    rerankModelID,
-			RerankTopK:       e.config.Conversation.RerankTopK,
-			RerankThreshold:  e.config.Conversation.RerankThreshold,
+			RerankTopK:       e.config.GetConversation().RerankTopK,
+			RerankThreshold:  e.config.GetConversation().RerankThreshold,
 			ChatModelID:      chatModelID,
 			SummaryConfig: types.SummaryConfig{
-				MaxTokens:           e.config.Conversation.Summary.MaxTokens,
-				RepeatPenalty:       e.config.Conversation.Summary.RepeatPenalty,
-				TopK:                e.config.Conversation.Summary.TopK,
-				TopP:                e.config.Conversation.Summary.TopP,
-				Prompt:              e.config.Conversation.Summary.Prompt,
-				ContextTemplate:     e.config.Conversation.Summary.ContextTemplate,
-				FrequencyPenalty:    e.config.Conversation.Summary.FrequencyPenalty,
-				PresencePenalty:     e.config.Conversation.Summary.PresencePenalty,
-				NoMatchPrefix:       e.config.Conversation.Summary.NoMatchPrefix,
-				Temperature:         e.config.Conversation.Summary.Temperature,
-				Seed:                e.config.Conversation.Summary.Seed,
-				MaxCompletionTokens: e.config.Conversation.Summary.MaxCompletionTokens,
+				MaxTokens:           e.config.GetConversation().Summary.MaxTokens,
+				RepeatPenalty:       e.config.GetConversation().Summary.RepeatPenalty,
+				TopK:                e.config.GetConversation().Summary.TopK,
+				TopP:                e.config.GetConversation().Summary.TopP,
+				Prompt:              e.config.GetConversation().Summary.Prompt,
+				ContextTemplate:     e.config.GetConversation().Summary.ContextTemplate,
+				FrequencyPenalty:    e.config.GetConversation().Summary.FrequencyPenalty,
+				PresencePenalty:     e.config.GetConversation().Summary.PresencePenalty,
+				NoMatchPrefix:       e.config.GetConversation().Summary.NoMatchPrefix,
+				Temperature:         e.config.GetConversation().Summary.Temperature,
+				Seed:                e.config.GetConversation().Summary.Seed,
+				MaxCompletionTokens: e.config.GetConversation().Summary.MaxCompletionTokens,
 			},
-			FallbackResponse: e.config.Conversation.FallbackResponse,
+			FallbackResponse: e.config.GetConversation().FallbackResponse,
 		},
 	}
+	detail := &types.EvaluationDetail{Task: task, Params: task.Params}
 
-	// Store evaluation task in memory storage
-	logger.Info(ctx, "Registering evaluation task")
-	e.evaluationMemoryStorage.register(detail)
+	// Store evaluation task
+	logger.Info(ctx, "Storing evaluation task")
+	if err := e.evaluationRepo.CreateTask(ctx, task); err != nil {
+		logger.Errorf(ctx, "Failed to store evaluation task: %v", err)
+		return nil, err
+	}
 
 	// Start evaluation in background goroutine
 	logger.Info(ctx, "Starting evaluation in background")
@@ -295,26 +244,50 @@ func (e *EvaluationService) Evaluation(ctx context.Context,
 		logger.Infof(newCtx, "Background evaluation started for task ID: %s", taskID)
 
 		// Update task status to running
-		detail.Task.Status = types.EvaluationStatueRunning
+		task.Status = types.EvaluationStatueRunning
+		e.saveTask(newCtx, task)
 		logger.Info(newCtx, "Evaluation task status set to running")
 
 		// Execute actual evaluation
-		if err := e.EvalDataset(newCtx, detail); err != nil {
-			detail.Task.Status = types.EvaluationStatueFailed
-			detail.Task.ErrMsg = err.Error()
+		err := e.EvalDataset(newCtx, detail)
+		endTime := time.Now()
+		task.EndTime = &endTime
+		if err != nil {
+			task.Status = types.EvaluationStatueFailed
+			task.ErrMsg = err.Error()
+			e.saveTask(newCtx, task)
 			logger.Errorf(newCtx, "Evaluation task failed: %v, task ID: %s", err, taskID)
 			return
 		}
 
 		// Mark task as completed successfully
 		logger.Infof(newCtx, "Evaluation task completed successfully, task ID: %s", taskID)
-		detail.Task.Status = types.EvaluationStatueSuccess
+		task.Status = types.EvaluationStatueSuccess
+		e.saveTask(newCtx, task)
 	}()
 
 	logger.Infof(ctx, "Evaluation task created successfully, task ID: %s", taskID)
 	return detail, nil
 }
 
+// evaluationInterrupted is the error message of the evaluations a restart stopped
+const evaluationInterrupted = "evaluation interrupted by a server restart"
+
+// RecoverOrphanedEvaluations fails the evaluation tasks and sweeps left pending or running by a restart.
+// Evaluations run in the server process, so none of them is running when the server starts.
+func (e *EvaluationService) RecoverOrphanedEvaluations(ctx context.Context) error {
+	tasks, err := e.evaluationRepo.FailUnfinishedTasks(ctx, evaluationInterrupted)
+	if err != nil {
+		return err
+	}
+	sweeps, err := e.evaluationRepo.FailUnfinishedSweeps(ctx, evaluationInterrupted)
+	if err != nil {
+		return err
+	}
+	logger.Infof(ctx, "Recovered orphaned evaluations, failed tasks: %d, failed sweeps: %d", tasks, sweeps)
+	return nil
+}
+
 // EvalDataset performs the actual evaluation of a dataset
 // Processes each QA pair in parallel and records metrics
 func (e *EvaluationService) EvalDataset(ctx context.Context, detail *types.EvaluationDetail) error {
@@ -330,45 +303,38 @@ func (e *EvaluationService) EvalDataset(ctx context.Context, detail *types.Evalu
 	logger.Infof(ctx, "Dataset retrieved successfully with %d QA pairs", len(dataset))
 
 	// Update total QA pairs count in task details
-	e.evaluationMemoryStorage.update(detail.Task.ID, func(params *types.EvaluationDetail) {
-		params.Task.Total = len(dataset)
-		logger.Infof(ctx, "Updated task total to %d QA pairs", params.Task.Total)
-	})
-
-	// Extract and organize passages from dataset
-	passages := getPassageList(dataset)
-	logger.Infof(ctx, "Creating knowledge from %d passages", len(passages))
-
-	// Create knowledge base from passages
-	knowledge, err := e.knowledgeService.CreateKnowledgeFromPassage(ctx, detail.Params.KnowledgeBaseID, passages)
-	if err != nil {
-		logger.Errorf(ctx, "Failed to create knowledge from passages: %v", err)
-		return err
-	}
-	logger.Infof(ctx, "Knowledge created successfully, ID: %s", knowledge.ID)
-
-	// Setup cleanup of temporary resources
-	defer func() {
-		logger.Infof(ctx, "Cleaning up resources - deleting knowledge: %s", knowledge.ID)
-		if err := e.knowledgeService.DeleteKnowledge(ctx, knowledge.ID); err != nil {
-			logger.Errorf(ctx, "Failed to delete knowledge: %v, knowledge ID: %s", err, knowledge.ID)
-		}
-
-		logger.Infof(ctx, "Cleaning up resources - deleting knowledge base: %s", detail.Params.KnowledgeBaseID)
-		if err := e.knowledgeBaseService.DeleteKnowledgeBase(ctx, detail.Params.KnowledgeBaseID); err != nil {
-			logger.Errorf(
-				ctx,
-				"Failed to delete knowledge base: %v, knowledge base ID: %s",
-				err, detail.Params.KnowledgeBaseID,
-			)
+	detail.Task.Total = len(dataset)
+	e.saveTask(ctx, detail.Task)
+	logger.Infof(ctx, "Updated task total to %d QA pairs", detail.Task.Total)
+
+	// Ingest the passages into the fresh knowledge base of the task, an existing knowledge base is used as it is
+	if !detail.Task.UseExistingKB {
+		cleanup, err := e.ingestDataset(ctx, detail.Params.KnowledgeBaseID, dataset)
+		defer cleanup()
+		if err != nil {
+			return err
 		}
-	}()
+	}
 
-	// Initialize parallel evaluation metrics
+	// Initialize parallel evaluation metrics, a failed question cancels the questions and judgements in flight
 	var finished int
 	var mu sync.Mutex
-	var g errgroup.Group
+	g, groupCtx := errgroup.WithContext(ctx)
 	metricHook := NewHookMetric(len(dataset))
+	metricHook.existingKB = detail.Task.UseExistingKB
+	if detail.Task.JudgeModelID != "" {
+		judgeConfig := e.judgeConfig()
+		if judgeConfig == nil {
+			return errors.New("judge metrics are not configured")
+		}
+		judgeModel, err := e.modelService.GetChatModel(ctx, detail.Task.JudgeModelID)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to get judge model: %v", err)
+			return err
+		}
+		metricHook.metricResults.judges = newJudgeMetrics(judgeModel, judgeConfig)
+		logger.Infof(ctx, "Judge metrics are scored by model %s", detail.Task.JudgeModelID)
+	}
 
 	// Set worker limit based on available CPUs
 	g.SetLimit(max(runtime.GOMAXPROCS(0)-1, 1))
@@ -387,7 +353,7 @@ func (e *EvaluationService) EvalDataset(ctx context.Context, detail *types.Evalu
 
 			// Execute knowledge QA pipeline
 			logger.Infof(ctx, "Running knowledge QA for question: %s", qaPair.Question)
-			err = e.sessionService.KnowledgeQAByEvent(ctx, chatManage, types.Pipline["rag"])
+			err := e.sessionService.KnowledgeQAByEvent(groupCtx, chatManage, types.PipelineRAG)
 			if err != nil {
 				logger.Errorf(ctx, "Failed to process question %d: %v", i, err)
 				return err
@@ -400,18 +366,25 @@ func (e *EvaluationService) EvalDataset(ctx context.Context, detail *types.Evalu
 			metricHook.recordSearchResult(i, chatManage.SearchResult)
 			metricHook.recordRerankResult(i, chatManage.RerankResult)
 			metricHook.recordChatResponse(i, chatManage.ChatResponse)
-			metricHook.recordFinish(i)
+			question := metricHook.recordFinish(groupCtx, i)
+
+			// Store the result of the question
+			question.TenantID = detail.Task.TenantID
+			question.TaskID = detail.Task.ID
+			if err := e.evaluationRepo.CreateQuestion(ctx, question); err != nil {
+				logger.Errorf(ctx, "Failed to store result of question %d: %v", i, err)
+				return err
+			}
 
 			// Update progress metrics
 			mu.Lock()
+			defer mu.Unlock()
 			finished += 1
-			metricResult := metricHook.MetricResult()
-			mu.Unlock()
-			e.evaluationMemoryStorage.update(detail.Task.ID, func(params *types.EvaluationDetail) {
-				params.Metric = metricResult
-				params.Task.Finished = finished
-				logger.Infof(ctx, "Updated task progress: %d/%d completed", finished, params.Task.Total)
-			})
+			detail.Task.Finished = finished
+			detail.Task.Metric = metricHook.MetricResult()
+			detail.Metric = detail.Task.Metric
+			e.saveTask(ctx, detail.Task)
+			logger.Infof(ctx, "Updated task progress: %d/%d completed", finished, detail.Task.Total)
 			return nil
 		})
 	}
@@ -423,16 +396,157 @@ func (e *EvaluationService) EvalDataset(ctx context.Context, detail *types.Evalu
 		return err
 	}
 
-	// Final update of evaluation metrics
-	e.evaluationMemoryStorage.update(detail.Task.ID, func(params *types.EvaluationDetail) {
-		params.Metric = metricHook.MetricResult()
-		params.Task.Finished = finished
-	})
+	// Final update of evaluation metrics, stored with the task status
+	detail.Task.Metric = metricHook.MetricResult()
+	detail.Metric = detail.Task.Metric
+	detail.Task.Finished = finished
 
 	logger.Infof(ctx, "Dataset evaluation completed successfully, task ID: %s", detail.Task.ID)
 	return nil
 }
 
+// prepareKnowledgeBase returns the knowledge base a task searches and its embedding model. An existing knowledge base
+// is searched as it is, otherwise a fresh knowledge base is created with the models of the given one, or with the
+// default models if none is given, to ingest the passages of the dataset into.
+func (e *EvaluationService) prepareKnowledgeBase(ctx context.Context,
+	knowledgeBaseID string, useExistingKB bool,
+) (string, string, error) {
+	var embeddingModelID string
+	if useExistingKB {
+		if knowledgeBaseID == "" {
+			return "", "", werrors.NewValidationError("评估已有知识库时必须指定知识库")
+		}
+		kb, err := e.knowledgeBaseService.GetKnowledgeBaseByID(ctx, knowledgeBaseID)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to get knowledge base: %v", err)
+			return "", "", err
+		}
+		embeddingModelID = kb.EmbeddingModelID
+		logger.Infof(ctx, "Evaluating existing knowledge base ID: %s", knowledgeBaseID)
+	} else if knowledgeBaseID == "" {
+		logger.Info(ctx, "No knowledge base ID provided, creating new knowledge base")
+		// Create new knowledge base with default evaluation settings
+		// 获取默认的嵌入模型和LLM模型
+		models, err := e.modelService.ListModels(ctx)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to list models: %v", err)
+			return "", "", err
+		}
+
+		var llmModelID string
+		for _, model := range models {
+			if model.Type == types.ModelTypeEmbedding {
+				embeddingModelID = model.ID
+			}
+			if model.Type == types.ModelTypeKnowledgeQA {
+				llmModelID = model.ID
+			}
+		}
+
+		if embeddingModelID == "" || llmModelID == "" {
+			return "", "", fmt.Errorf("no default models found for evaluation")
+		}
+
+		kb, err := e.knowledgeBaseService.CreateKnowledgeBase(ctx, &types.KnowledgeBase{
+			Name:             "evaluation",
+			Description:      "evaluation",
+			EmbeddingModelID: embeddingModelID,
+			SummaryModelID:   llmModelID,
+		})
+		if err != nil {
+			logger.Errorf(ctx, "Failed to create knowledge base: %v", err)
+			return "", "", err
+		}
+		knowledgeBaseID = kb.ID
+		logger.Infof(ctx, "Created new knowledge base with ID: %s", knowledgeBaseID)
+	} else {
+		logger.Infof(ctx, "Using existing knowledge base ID: %s", knowledgeBaseID)
+		// Create evaluation-specific knowledge base based on existing one
+		kb, err := e.knowledgeBaseService.GetKnowledgeBaseByID(ctx, knowledgeBaseID)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to get knowledge base: %v", err)
+			return "", "", err
+		}
+
+		kb, err = e.knowledgeBaseService.CreateKnowledgeBase(ctx, &types.KnowledgeBase{
+			Name:             "evaluation",
+			Description:      "evaluation",
+			EmbeddingModelID: kb.EmbeddingModelID,
+			SummaryModelID:   kb.SummaryModelID,
+		})
+		if err != nil {
+			logger.Errorf(ctx, "Failed to create knowledge base: %v", err)
+			return "", "", err
+		}
+		knowledgeBaseID = kb.ID
+		embeddingModelID = kb.EmbeddingModelID
+		logger.Infof(ctx, "Created new knowledge base with ID: %s based on existing one", knowledgeBaseID)
+	}
+	return knowledgeBaseID, embeddingModelID, nil
+}
+
+// defaultRerankModel returns the first rerank model of the tenant, empty to skip reranking
+func (e *EvaluationService) defaultRerankModel(ctx context.Context) string {
+	// 获取默认的重排模型
+	var rerankModelID string
+	models, err := e.modelService.ListModels(ctx)
+	if err == nil {
+		for _, model := range models {
+			if model.Type == types.ModelTypeRerank {
+				rerankModelID = model.ID
+				break
+			}
+		}
+	}
+	if rerankModelID == "" {
+		logger.Warnf(ctx, "No rerank model found, skipping rerank")
+	} else {
+		logger.Infof(ctx, "Using default rerank model: %s", rerankModelID)
+	}
+	return rerankModelID
+}
+
+// ingestDataset ingests the passages of a dataset into the fresh knowledge base of a task. The returned cleanup
+// deletes the ingested knowledge and the knowledge base, it has to be called even if the ingestion failed.
+func (e *EvaluationService) ingestDataset(ctx context.Context,
+	knowledgeBaseID string, dataset []*types.QAPair,
+) (func(), error) {
+	var knowledge *types.Knowledge
+	cleanup := func() {
+		if knowledge != nil {
+			logger.Infof(ctx, "Cleaning up resources - deleting knowledge: %s", knowledge.ID)
+			if err := e.knowledgeService.DeleteKnowledge(ctx, knowledge.ID); err != nil {
+				logger.Errorf(ctx, "Failed to delete knowledge: %v, knowledge ID: %s", err, knowledge.ID)
+			}
+		}
+		logger.Infof(ctx, "Cleaning up resources - deleting knowledge base: %s", knowledgeBaseID)
+		if err := e.knowledgeBaseService.DeleteKnowledgeBase(ctx, knowledgeBaseID); err != nil {
+			logger.Errorf(ctx, "Failed to delete knowledge base: %v, knowledge base ID: %s", err, knowledgeBaseID)
+		}
+	}
+
+	for _, qaPair := range dataset {
+		if len(qaPair.Passages) == 0 {
+			return cleanup, fmt.Errorf("question %d has no passages to ingest, evaluate the dataset against "+
+				"an existing knowledge base", qaPair.QID)
+		}
+	}
+
+	// Extract and organize passages from dataset
+	passages := getPassageList(dataset)
+	logger.Infof(ctx, "Creating knowledge from %d passages", len(passages))
+
+	// Create knowledge base from passages
+	created, err := e.knowledgeService.CreateKnowledgeFromPassage(ctx, knowledgeBaseID, passages, nil)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to create knowledge from passages: %v", err)
+		return cleanup, err
+	}
+	knowledge = created
+	logger.Infof(ctx, "Knowledge created successfully, ID: %s", knowledge.ID)
+	return cleanup, nil
+}
+
 // getPassageList extracts and organizes passages from QA pairs
 // Returns a slice of passages indexed by their passage IDs
 func getPassageList(dataset []*types.QAPair) []string {
@@ -444,8 +558,8 @@ func getPassageList(dataset []*types.QAPair) []string {
 			maxPID = max(maxPID, qaPair.PIDs[i])
 		}
 	}
-	passages := make([]string, maxPID)
-	for i := 0; i < maxPID; i++ {
+	passages := make([]string, maxPID+1)
+	for i := 0; i <= maxPID; i++ {
 		if _, ok := pIDMap[i]; ok {
 			passages[i] = pIDMap[i]
 		}
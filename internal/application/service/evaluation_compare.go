@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Tencent/WeKnora/internal/application/service/evalcompare"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// comparedMetrics are the metrics compared between evaluation tasks, in the order of metricCalculators
+var comparedMetrics = func() []evalcompare.Metric {
+	metrics := make([]evalcompare.Metric, 0, len(metricCalculators))
+	for _, c := range metricCalculators {
+		metrics = append(metrics, evalcompare.Metric{Name: c.name, Judge: c.calc == nil, Field: c.getField})
+	}
+	return metrics
+}()
+
+// CompareEvaluations compares a target evaluation task with a base task: the parameters that differ,
+// the average metrics and the metrics and retrieved chunks of each question
+func (e *EvaluationService) CompareEvaluations(ctx context.Context,
+	baseID string, targetID string,
+) (*types.EvaluationComparison, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	base, err := e.evaluationRepo.GetTask(ctx, tenantID, baseID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := e.evaluationRepo.GetTask(ctx, tenantID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	baseQuestions, err := e.evaluationRepo.ListQuestions(ctx, tenantID, baseID)
+	if err != nil {
+		return nil, err
+	}
+	targetQuestions, err := e.evaluationRepo.ListQuestions(ctx, tenantID, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Judge metrics of a task without a judge model score 0, they are only compared if both tasks were judged
+	judged := base.JudgeModelID != "" && target.JudgeModelID != ""
+	comparison := &types.EvaluationComparison{
+		Base:      base,
+		Target:    target,
+		Params:    evalcompare.Params(base, target),
+		Metrics:   evalcompare.Metrics(comparedMetrics, base.Metric, target.Metric, judged),
+		Questions: evalcompare.Questions(comparedMetrics, baseQuestions, targetQuestions, judged),
+	}
+	regressed := 0
+	for _, question := range comparison.Questions {
+		if question.Regressed {
+			regressed++
+		}
+	}
+	logger.Infof(ctx, "Compared evaluation task %s with %s, changed params: %d, regressed questions: %d",
+		targetID, baseID, len(comparison.Params), regressed)
+	return comparison, nil
+}
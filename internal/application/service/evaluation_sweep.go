@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/application/service/sweep"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/embedding"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+)
+
+// StartSweep validates a retrieval-only parameter sweep, creates the knowledge base it searches if needed and
+// runs its trials in the background. Parameters without values in the space are fixed to the conversation config.
+func (e *EvaluationService) StartSweep(ctx context.Context,
+	sweepTask *types.EvaluationSweep,
+) (*types.EvaluationSweep, error) {
+	logger.Infof(ctx, "Start evaluation sweep, dataset ID: %s, knowledge base ID: %s, mode: %s, existing KB: %v",
+		sweepTask.DatasetID, sweepTask.KnowledgeBaseID, sweepTask.Mode, sweepTask.UseExistingKB)
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+
+	if sweepTask.DatasetID == "" {
+		sweepTask.DatasetID = types.DefaultDatasetID
+	} else if sweepTask.DatasetID != types.DefaultDatasetID {
+		if _, err := e.dataset.GetDataset(ctx, sweepTask.DatasetID); err != nil {
+			logger.Errorf(ctx, "Failed to get dataset: %v", err)
+			return nil, err
+		}
+	}
+
+	conversation := e.config.GetConversation()
+	space := &sweepTask.Space
+	if len(space.EmbeddingTopK) == 0 {
+		space.EmbeddingTopK = []int{conversation.EmbeddingTopK}
+	}
+	if len(space.VectorThreshold) == 0 {
+		space.VectorThreshold = []float64{conversation.VectorThreshold}
+	}
+	if len(space.KeywordThreshold) == 0 {
+		space.KeywordThreshold = []float64{conversation.KeywordThreshold}
+	}
+	if len(space.RerankTopK) == 0 {
+		space.RerankTopK = []int{conversation.RerankTopK}
+	}
+	if len(space.RerankThreshold) == 0 {
+		space.RerankThreshold = []float64{conversation.RerankThreshold}
+	}
+	if sweepTask.Mode == "" {
+		sweepTask.Mode = types.SweepModeGrid
+	}
+	settings, err := sweep.Settings(*space, sweepTask.Mode, sweepTask.Samples, sweepTask.Seed)
+	if err != nil {
+		return nil, werrors.NewValidationError("参数搜索空间无效").WithDetails(err.Error())
+	}
+
+	if sweepTask.RerankModelID == "" {
+		sweepTask.RerankModelID = e.defaultRerankModel(ctx)
+	}
+	knowledgeBaseID, embeddingModelID, err := e.prepareKnowledgeBase(ctx,
+		sweepTask.KnowledgeBaseID, sweepTask.UseExistingKB)
+	if err != nil {
+		return nil, err
+	}
+
+	sweepTask.ID = uuid.New().String()
+	sweepTask.TenantID = tenantID
+	sweepTask.KnowledgeBaseID = knowledgeBaseID
+	sweepTask.EmbeddingModelID = embeddingModelID
+	sweepTask.Status = types.EvaluationStatuePending
+	sweepTask.StartTime = time.Now()
+	sweepTask.Total = len(settings)
+	if err := e.evaluationRepo.CreateSweep(ctx, sweepTask); err != nil {
+		logger.Errorf(ctx, "Failed to store evaluation sweep: %v", err)
+		return nil, err
+	}
+	created := *sweepTask
+
+	go func() {
+		newCtx := logger.CloneContext(ctx)
+		logger.Infof(newCtx, "Background evaluation sweep started, sweep ID: %s, trials: %d",
+			sweepTask.ID, len(settings))
+
+		sweepTask.Status = types.EvaluationStatueRunning
+		e.saveSweep(newCtx, sweepTask)
+
+		err := e.runSweep(newCtx, sweepTask, settings)
+		endTime := time.Now()
+		sweepTask.EndTime = &endTime
+		if err != nil {
+			sweepTask.Status = types.EvaluationStatueFailed
+			sweepTask.ErrMsg = err.Error()
+			e.saveSweep(newCtx, sweepTask)
+			logger.Errorf(newCtx, "Evaluation sweep failed: %v, sweep ID: %s", err, sweepTask.ID)
+			return
+		}
+		sweepTask.Status = types.EvaluationStatueSuccess
+		e.saveSweep(newCtx, sweepTask)
+		logger.Infof(newCtx, "Evaluation sweep completed successfully, sweep ID: %s", sweepTask.ID)
+	}()
+
+	return &created, nil
+}
+
+// GetSweep gets an evaluation sweep with its trials, a finished sweep with its recommended settings
+func (e *EvaluationService) GetSweep(ctx context.Context, sweepID string) (*types.EvaluationSweep, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	sweepTask, err := e.evaluationRepo.GetSweep(ctx, tenantID, sweepID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get evaluation sweep: %v", err)
+		return nil, err
+	}
+	if sweepTask.Status == types.EvaluationStatueSuccess {
+		sweepTask.Recommended = sweep.Recommend(sweepTask.Trials)
+	}
+	return sweepTask, nil
+}
+
+// ListSweeps lists the evaluation sweeps of the tenant without their trials, newest first
+func (e *EvaluationService) ListSweeps(ctx context.Context, pagination *types.Pagination) (*types.PageResult, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	sweeps, total, err := e.evaluationRepo.ListSweeps(ctx, tenantID, pagination)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list evaluation sweeps: %v", err)
+		return nil, err
+	}
+	return types.NewPageResult(total, pagination, sweeps), nil
+}
+
+// saveSweep stores the progress of an evaluation sweep, failures are only logged
+func (e *EvaluationService) saveSweep(ctx context.Context, sweepTask *types.EvaluationSweep) {
+	if err := e.evaluationRepo.UpdateSweep(ctx, sweepTask); err != nil {
+		logger.Errorf(ctx, "Failed to update evaluation sweep: %v, sweep ID: %s", err, sweepTask.ID)
+	}
+}
+
+// runSweep runs the trials of a sweep one after the other and marks the Pareto-optimal ones.
+// The questions are embedded once up front, every trial searches with the cached embeddings.
+func (e *EvaluationService) runSweep(ctx context.Context,
+	sweepTask *types.EvaluationSweep, settings []types.SweepSettings,
+) error {
+	dataset, err := e.dataset.GetDatasetByID(ctx, sweepTask.DatasetID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get dataset: %v", err)
+		return err
+	}
+	logger.Infof(ctx, "Dataset retrieved successfully with %d QA pairs", len(dataset))
+
+	if !sweepTask.UseExistingKB {
+		cleanup, err := e.ingestDataset(ctx, sweepTask.KnowledgeBaseID, dataset)
+		defer cleanup()
+		if err != nil {
+			return err
+		}
+	}
+
+	cache := embedding.NewQueryCache()
+	ctx = context.WithValue(ctx, types.QueryEmbeddingCacheContextKey, cache)
+	if sweepTask.EmbeddingModelID != "" {
+		model, err := e.modelService.GetEmbeddingModel(ctx, sweepTask.EmbeddingModelID)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to get embedding model: %v", err)
+			return err
+		}
+		queries := make([]string, len(dataset))
+		for i, qaPair := range dataset {
+			queries[i] = strings.TrimSpace(qaPair.Question)
+		}
+		vectors, err := model.BatchEmbedWithPool(ctx, model, queries)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to embed questions: %v", err)
+			return err
+		}
+		for i, vector := range vectors {
+			cache.Put(model.GetModelID(), queries[i], vector)
+		}
+		logger.Infof(ctx, "Embedded %d questions for the sweep trials", len(queries))
+	}
+
+	for _, setting := range settings {
+		trial, err := e.runTrial(ctx, sweepTask, dataset, setting)
+		if err != nil {
+			return err
+		}
+		sweepTask.Trials = append(sweepTask.Trials, trial)
+		sweepTask.Finished = len(sweepTask.Trials)
+		e.saveSweep(ctx, sweepTask)
+		logger.Infof(ctx, "Sweep trial %d/%d finished, settings: %+v, recall: %f, average latency: %.1fms",
+			sweepTask.Finished, sweepTask.Total, setting, trial.Metrics.Recall, trial.AvgLatencyMs)
+	}
+	sweep.MarkPareto(sweepTask.Trials)
+	return nil
+}
+
+// runTrial searches, reranks and merges the chunks of every question with the settings of a trial. The metrics
+// are computed on the reranked chunks cut to the rerank top K, the search results if there is no rerank model.
+func (e *EvaluationService) runTrial(ctx context.Context,
+	sweepTask *types.EvaluationSweep, dataset []*types.QAPair, setting types.SweepSettings,
+) (*types.SweepTrial, error) {
+	metricHook := NewHookMetric(len(dataset))
+	metricHook.existingKB = sweepTask.UseExistingKB
+	metricHook.metricResults.retrievalOnly = true
+	latencies := make([]time.Duration, len(dataset))
+
+	g, groupCtx := errgroup.WithContext(ctx)
+	g.SetLimit(max(runtime.GOMAXPROCS(0)-1, 1))
+	for i, qaPair := range dataset {
+		g.Go(func() error {
+			query := strings.TrimSpace(qaPair.Question)
+			chatManage := &types.ChatManage{
+				Query:            query,
+				ProcessedQuery:   query,
+				RewriteQuery:     query,
+				KnowledgeBaseID:  sweepTask.KnowledgeBaseID,
+				VectorThreshold:  setting.VectorThreshold,
+				KeywordThreshold: setting.KeywordThreshold,
+				EmbeddingTopK:    setting.EmbeddingTopK,
+				RerankModelID:    sweepTask.RerankModelID,
+				RerankTopK:       setting.RerankTopK,
+				RerankThreshold:  setting.RerankThreshold,
+			}
+			start := time.Now()
+			if err := e.sessionService.KnowledgeQAByEvent(groupCtx, chatManage, types.PipelineRetrieval); err != nil {
+				logger.Errorf(ctx, "Failed to search question %d: %v", i, err)
+				return fmt.Errorf("question %d: %w", qaPair.QID, err)
+			}
+			latencies[i] = time.Since(start)
+
+			results := chatManage.RerankResult
+			if chatManage.RerankModelID == "" {
+				results = chatManage.SearchResult
+			}
+			if len(results) > setting.RerankTopK {
+				results = results[:setting.RerankTopK]
+			}
+			metricHook.recordInit(i)
+			metricHook.recordQaPair(i, qaPair)
+			metricHook.recordRerankResult(i, results)
+			metricHook.recordFinish(groupCtx, i)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	avgLatency, p95Latency := sweep.Latency(latencies)
+	return &types.SweepTrial{
+		Settings:     setting,
+		Metrics:      metricHook.MetricResult().RetrievalMetrics,
+		AvgLatencyMs: avgLatency,
+		P95LatencyMs: p95Latency,
+	}, nil
+}
@@ -0,0 +1,229 @@
+// Package fingerprint computes content-defined fingerprints that find near-duplicate text.
+// MinHash signatures estimate the Jaccard similarity of the word shingles of whole documents,
+// SimHash values compare short texts such as chunks by the Hamming distance of their hashes.
+// Words of Latin scripts are tokens, each CJK character is a token of its own.
+package fingerprint
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+
+	"github.com/Tencent/WeKnora/internal/models/tokenizer"
+)
+
+const (
+	// SignatureSize is the number of hash functions of a MinHash signature
+	SignatureSize = 64
+	// bands is the number of bands a signature is split into to find candidate pairs,
+	// a pair shares a band with probability 1-(1-s^4)^16 for similarity s, 0.99 at s=0.7
+	bands = 16
+	// rows is the number of signature values in a band
+	rows = SignatureSize / bands
+	// shingleSize is the number of consecutive tokens in a shingle
+	shingleSize = 3
+)
+
+// NearDuplicateDistance is the largest Hamming distance between the SimHash values of near-duplicate chunks
+const NearDuplicateDistance = 3
+
+// seeds are the seeds of the MinHash hash functions
+var seeds = func() [SignatureSize]uint64 {
+	var s [SignatureSize]uint64
+	state := uint64(0x5eed)
+	for i := range s {
+		state += 0x9e3779b97f4a7c15
+		s[i] = mix(state)
+	}
+	return s
+}()
+
+// mix is the finalizer of splitmix64, it spreads the bits of a hash
+func mix(x uint64) uint64 {
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// tokens splits the text into lowercase words and CJK characters, punctuation and spacing are dropped
+func tokens(text string) []string {
+	var result []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			result = append(result, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case tokenizer.IsCJK(r):
+			flush()
+			result = append(result, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+	return result
+}
+
+// shingles returns the distinct hashes of the shingles of the text,
+// a text shorter than a shingle is a single shingle
+func shingles(text string) []uint64 {
+	words := tokens(text)
+	if len(words) == 0 {
+		return nil
+	}
+	n := max(len(words)-shingleSize+1, 1)
+	seen := make(map[uint64]bool, n)
+	result := make([]uint64, 0, n)
+	for i := 0; i < n; i++ {
+		h := fnv.New64a()
+		for _, word := range words[i:min(i+shingleSize, len(words))] {
+			h.Write([]byte(word))
+			h.Write([]byte{0})
+		}
+		sum := h.Sum64()
+		if !seen[sum] {
+			seen[sum] = true
+			result = append(result, sum)
+		}
+	}
+	return result
+}
+
+// MinHash returns the MinHash signature of the text, nil for text without words
+func MinHash(text string) []uint32 {
+	hashes := shingles(text)
+	if len(hashes) == 0 {
+		return nil
+	}
+	signature := make([]uint32, SignatureSize)
+	for i := range signature {
+		signature[i] = ^uint32(0)
+	}
+	for _, h := range hashes {
+		for i, seed := range seeds {
+			if v := uint32(mix(h^seed) >> 32); v < signature[i] {
+				signature[i] = v
+			}
+		}
+	}
+	return signature
+}
+
+// Similarity estimates the Jaccard similarity of the texts of two MinHash signatures,
+// 0 if either is missing
+func Similarity(a, b []uint32) float64 {
+	if len(a) != SignatureSize || len(b) != SignatureSize {
+		return 0
+	}
+	equal := 0
+	for i := range a {
+		if a[i] == b[i] {
+			equal++
+		}
+	}
+	return float64(equal) / SignatureSize
+}
+
+// bandKeys returns a key for each band of a signature, signatures sharing a key are candidate duplicates
+func bandKeys(signature []uint32) []uint64 {
+	keys := make([]uint64, bands)
+	buf := make([]byte, 4*rows+1)
+	for band := range keys {
+		buf[0] = byte(band)
+		for row := 0; row < rows; row++ {
+			binary.LittleEndian.PutUint32(buf[1+4*row:], signature[band*rows+row])
+		}
+		h := fnv.New64a()
+		h.Write(buf)
+		keys[band] = h.Sum64()
+	}
+	return keys
+}
+
+// SimHash returns the SimHash of the text, 0 for text without words
+func SimHash(text string) uint64 {
+	hashes := shingles(text)
+	if len(hashes) == 0 {
+		return 0
+	}
+	var weights [64]int
+	for _, h := range hashes {
+		h = mix(h)
+		for bit := range weights {
+			if h&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	var result uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			result |= 1 << bit
+		}
+	}
+	return result
+}
+
+// Distance returns the Hamming distance between two SimHash values
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Clusters groups the signatures whose similarity reaches the threshold, transitively.
+// It returns the indices of the signatures of each group with more than one member in ascending order,
+// groups are ordered by their first index. Only pairs sharing a band are compared.
+func Clusters(signatures [][]uint32, threshold float64) [][]int {
+	parent := make([]int, len(signatures))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	buckets := make(map[uint64][]int)
+	for i, signature := range signatures {
+		if len(signature) != SignatureSize {
+			continue
+		}
+		for _, key := range bandKeys(signature) {
+			for _, j := range buckets[key] {
+				if find(i) != find(j) && Similarity(signature, signatures[j]) >= threshold {
+					parent[find(i)] = find(j)
+				}
+			}
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	groups := make(map[int][]int)
+	var roots []int
+	for i := range signatures {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], i)
+	}
+	var result [][]int
+	for _, root := range roots {
+		if len(groups[root]) > 1 {
+			result = append(result, groups[root])
+		}
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+package fingerprint
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const ticket = "Customer reports that the mobile app crashes when uploading a photo larger than ten megabytes. " +
+	"The crash happens on Android 14 after the upload progress reaches ninety percent. " +
+	"Workaround: compress the photo before uploading or use the web client until the fix is released."
+
+func TestTokens(t *testing.T) {
+	got := tokens("Hello, World! 你好 v2.0")
+	expected := []string{"hello", "world", "你", "好", "v2", "0"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestMinHashSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		min  float64
+		max  float64
+	}{
+		{
+			name: "identical text",
+			a:    ticket,
+			b:    ticket,
+			min:  1,
+			max:  1,
+		},
+		{
+			name: "case and punctuation are ignored",
+			a:    ticket,
+			b:    strings.ToUpper(strings.ReplaceAll(ticket, ".", ";")),
+			min:  1,
+			max:  1,
+		},
+		{
+			name: "one changed word is a near-duplicate",
+			a:    ticket,
+			b:    strings.Replace(ticket, "Android 14", "Android 15", 1),
+			min:  0.8,
+			max:  1,
+		},
+		{
+			name: "unrelated text",
+			a:    ticket,
+			b:    "The quarterly report lists revenue by region and compares it with the budget of the previous year.",
+			min:  0,
+			max:  0.1,
+		},
+		{
+			name: "empty text has no signature",
+			a:    ticket,
+			b:    " ... ",
+			min:  0,
+			max:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Similarity(MinHash(tt.a), MinHash(tt.b))
+			if got < tt.min || got > tt.max {
+				t.Errorf("expected similarity in [%v, %v], got %v", tt.min, tt.max, got)
+			}
+		})
+	}
+}
+
+func TestSimHashDistance(t *testing.T) {
+	edited := strings.Replace(ticket, "ninety percent", "ninety-five percent", 1)
+	if d := Distance(SimHash(ticket), SimHash(edited)); d > NearDuplicateDistance*2 {
+		t.Errorf("expected a small distance for an edited chunk, got %d", d)
+	}
+	other := "Invoices are sent on the first working day of the month and are due within thirty days."
+	if d := Distance(SimHash(ticket), SimHash(other)); d <= NearDuplicateDistance {
+		t.Errorf("expected a large distance for unrelated chunks, got %d", d)
+	}
+	if SimHash("") != 0 {
+		t.Error("expected 0 for empty text")
+	}
+}
+
+func TestClusters(t *testing.T) {
+	other := "Invoices are sent on the first working day of the month and are due within thirty days of receipt."
+	signatures := [][]uint32{
+		MinHash(ticket),
+		MinHash(other),
+		nil,
+		MinHash(strings.Replace(ticket, "Android 14", "Android 15", 1)),
+		MinHash(other + " Contact billing for questions."),
+		MinHash(ticket),
+	}
+	got := Clusters(signatures, 0.8)
+	expected := [][]int{{0, 3, 5}, {1, 4}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected clusters %v, got %v", expected, got)
+	}
+	if got := Clusters(signatures, 1); !reflect.DeepEqual(got, [][]int{{0, 5}}) {
+		t.Errorf("expected only identical signatures at threshold 1, got %v", got)
+	}
+}
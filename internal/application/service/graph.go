@@ -101,7 +101,7 @@ func (b *graphBuilder) extractEntities(ctx context.Context, chunk *types.Chunk)
 	messages := []chat.Message{
 		{
 			Role:    "system",
-			Content: b.config.Conversation.ExtractEntitiesPrompt,
+			Content: b.config.GetConversation().ExtractEntitiesPrompt,
 		},
 		{
 			Role:    "user",
@@ -201,7 +201,7 @@ func (b *graphBuilder) extractRelationships(ctx context.Context,
 	messages := []chat.Message{
 		{
 			Role:    "system",
-			Content: b.config.Conversation.ExtractRelationshipsPrompt,
+			Content: b.config.GetConversation().ExtractRelationshipsPrompt,
 		},
 		{
 			Role:    "user",
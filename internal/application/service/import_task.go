@@ -3,38 +3,90 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/url"
-	"strings"
-	"sync"
 	"time"
 
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	"github.com/Tencent/WeKnora/internal/application/service/importsync"
+	"github.com/Tencent/WeKnora/internal/config"
 	"github.com/Tencent/WeKnora/internal/logger"
 	"github.com/Tencent/WeKnora/internal/types"
 	"github.com/Tencent/WeKnora/internal/types/interfaces"
-	"github.com/gocolly/colly/v2"
+	"github.com/hibiken/asynq"
 )
 
+// importTaskTimeout is the longest a run of an import task may take,
+// a task that stays processing for longer is considered lost and scheduled again
+const importTaskTimeout = 2 * time.Hour
+
 type importTaskService struct {
 	repo           interfaces.ImportTaskRepository
 	kgService      interfaces.KnowledgeService
 	crawlerService interfaces.CrawlerService
+	tenantRepo     interfaces.TenantRepository
+	task           *asynq.Client
+	// queue is the asynq queue of the import runs, the queue of the document tasks
+	queue string
 }
 
 func NewImportTaskService(
+	config *config.Config,
 	repo interfaces.ImportTaskRepository,
 	kgService interfaces.KnowledgeService,
 	crawlerService interfaces.CrawlerService,
+	tenantRepo interfaces.TenantRepository,
+	task *asynq.Client,
 ) (interfaces.ImportTaskService, error) {
+	ingestion := config.Ingestion
+	if ingestion == nil {
+		ingestion = defaultIngestionConfig
+	}
 	return &importTaskService{
 		repo:           repo,
 		kgService:      kgService,
 		crawlerService: crawlerService,
+		tenantRepo:     tenantRepo,
+		task:           task,
+		queue:          ingestion.Queue,
 	}, nil
 }
 
+// CreateTask creates an import task and enqueues its first run.
+// A recurring task is run again at every occurrence of its schedule.
 func (s *importTaskService) CreateTask(ctx context.Context, task *types.ImportTask) error {
-	return s.repo.Create(ctx, task)
+	nextRunAt, err := task.NextRun(time.Now())
+	if err != nil {
+		return err
+	}
+	task.NextRunAt = nextRunAt
+	if err := s.repo.Create(ctx, task); err != nil {
+		return err
+	}
+	return s.enqueueRun(ctx, task)
+}
+
+// enqueueRun enqueues a run of an import task, the task is marked as failed if the run cannot be enqueued
+func (s *importTaskService) enqueueRun(ctx context.Context, task *types.ImportTask) error {
+	data, err := json.Marshal(&types.ImportTaskPayload{TenantID: task.TenantID, TaskID: task.ID})
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdateStatus(ctx, task.ID, types.ImportTaskStatusPending, ""); err != nil {
+		return err
+	}
+	info, err := s.task.EnqueueContext(ctx, asynq.NewTask(types.TypeImportTaskRun, data,
+		asynq.Queue(s.queue),
+		asynq.MaxRetry(0),
+		asynq.Timeout(importTaskTimeout),
+	))
+	if err != nil {
+		logger.Errorf(ctx, "Failed to enqueue import task %s: %v", task.ID, err)
+		s.UpdateTaskStatus(ctx, task.ID, types.ImportTaskStatusFailed, fmt.Sprintf("Enqueue failed: %v", err))
+		return fmt.Errorf("failed to enqueue import task: %w", err)
+	}
+	logger.Infof(ctx, "Enqueued import task run: id=%s queue=%s", info.ID, info.Queue)
+	return nil
 }
 
 func (s *importTaskService) GetTaskByID(ctx context.Context, taskID string) (*types.ImportTask, error) {
@@ -73,10 +125,17 @@ func (s *importTaskService) CancelTask(ctx context.Context, taskID string) error
 		return err
 	}
 
-	if task.Status != types.ImportTaskStatusPending && task.Status != types.ImportTaskStatusProcessing {
+	// A recurring task can be cancelled between runs, which stops its schedule
+	if task.Status != types.ImportTaskStatusPending && task.Status != types.ImportTaskStatusProcessing &&
+		(task.Schedule == "" || task.Status == types.ImportTaskStatusCancelled) {
 		return fmt.Errorf("cannot cancel task with status: %s", task.Status)
 	}
 
+	if task.NextRunAt != nil {
+		if _, err := s.repo.UpdateNextRun(ctx, taskID, task.NextRunAt, nil); err != nil {
+			return err
+		}
+	}
 	return s.repo.UpdateStatus(ctx, taskID, types.ImportTaskStatusCancelled, "Task cancelled by user")
 }
 
@@ -92,12 +151,83 @@ func (s *importTaskService) AddTaskResult(ctx context.Context, taskID string, re
 	return s.repo.AddResult(ctx, taskID, result)
 }
 
-// ProcessTask implements serial crawling and importing
-// Each URL is crawled and immediately imported, single failures don't block subsequent tasks
+// RunTask handles a TypeImportTaskRun task
+func (s *importTaskService) RunTask(ctx context.Context, t *asynq.Task) error {
+	var payload types.ImportTaskPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Errorf(ctx, "Failed to unmarshal import task payload: %v", err)
+		return skipRetry(err)
+	}
+	ctx = context.WithValue(ctx, types.TenantIDContextKey, payload.TenantID)
+	ctx = logger.WithField(ctx, "import_task_id", payload.TaskID)
+
+	tenant, err := s.tenantRepo.GetTenantByID(ctx, payload.TenantID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get tenant: %v", err)
+		return err
+	}
+	ctx = context.WithValue(ctx, types.TenantInfoContextKey, tenant)
+
+	task, err := s.repo.GetByID(ctx, payload.TenantID, payload.TaskID)
+	if err != nil {
+		if err == repository.ErrImportTaskNotFound {
+			logger.Warn(ctx, "Import task was deleted, skip run")
+			return nil
+		}
+		return err
+	}
+	if task.Status == types.ImportTaskStatusCancelled {
+		logger.Info(ctx, "Import task was cancelled, skip run")
+		return nil
+	}
+	s.ProcessTask(ctx, task)
+	return nil
+}
+
+// ScheduleTasks handles a TypeImportTaskSchedule task, it enqueues a run of the recurring import tasks that are due.
+// A task whose previous run is still going skips the occurrence.
+func (s *importTaskService) ScheduleTasks(ctx context.Context, t *asynq.Task) error {
+	now := time.Now()
+	tasks, err := s.repo.ListDue(ctx, now)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list due import tasks: %v", err)
+		return err
+	}
+	for _, task := range tasks {
+		next, err := task.NextRun(now)
+		if err != nil {
+			logger.Warnf(ctx, "Import task %s has an invalid schedule: %v", task.ID, err)
+			continue
+		}
+		// Claim the occurrence, another instance may have scheduled the task already
+		claimed, err := s.repo.UpdateNextRun(ctx, task.ID, task.NextRunAt, next)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to update next run of import task %s: %v", task.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		running := task.Status == types.ImportTaskStatusPending || task.Status == types.ImportTaskStatusProcessing
+		if running && now.Sub(task.UpdatedAt) < importTaskTimeout {
+			logger.Infof(ctx, "Import task %s is still running, skip scheduled run", task.ID)
+			continue
+		}
+		if err := s.enqueueRun(ctx, task); err != nil {
+			logger.Errorf(ctx, "Failed to schedule import task %s: %v", task.ID, err)
+		}
+	}
+	return nil
+}
+
+// ProcessTask runs one crawl of an import task.
+// The pages of the site are discovered through its sitemap.xml or by following links, pages new to the task
+// are imported and pages modified since the previous run are refreshed. Single failures don't block
+// subsequent pages. The knowledge of pages that disappeared from the site is removed.
 func (s *importTaskService) ProcessTask(ctx context.Context, task *types.ImportTask) {
-	logger.Infof(ctx, "Starting to process import task (serial mode): %s", task.ID)
+	logger.Infof(ctx, "Starting to process import task: %s", task.ID)
 
-	if err := s.StartProcessing(ctx, task.ID); err != nil {
+	if err := s.repo.StartRun(ctx, task.ID); err != nil {
 		logger.Errorf(ctx, "Failed to update task status to processing: %v", err)
 		s.UpdateTaskStatus(ctx, task.ID, types.ImportTaskStatusFailed, err.Error())
 		return
@@ -111,6 +241,16 @@ func (s *importTaskService) ProcessTask(ctx context.Context, task *types.ImportT
 			return
 		}
 	}
+	include, err := config.Include()
+	if err != nil {
+		s.UpdateTaskStatus(ctx, task.ID, types.ImportTaskStatusFailed, fmt.Sprintf("Invalid config: %v", err))
+		return
+	}
+	exclude, err := config.Exclude()
+	if err != nil {
+		s.UpdateTaskStatus(ctx, task.ID, types.ImportTaskStatusFailed, fmt.Sprintf("Invalid config: %v", err))
+		return
+	}
 
 	maxPages := config.MaxPages
 	if maxPages <= 0 {
@@ -120,208 +260,107 @@ func (s *importTaskService) ProcessTask(ctx context.Context, task *types.ImportT
 		maxPages = 500
 	}
 
-	logger.Infof(ctx, "Starting serial crawl and import for: %s (maxPages: %d)", task.BaseURL, maxPages)
-
-	parsedBase, err := url.Parse(task.BaseURL)
+	logger.Infof(ctx, "Starting crawl for: %s (maxPages: %d)", task.BaseURL, maxPages)
+	crawl, err := s.crawlerService.CrawlWebsite(ctx, task.BaseURL, &interfaces.CrawlOptions{
+		MaxPages: maxPages,
+		Include:  include,
+		Exclude:  exclude,
+	})
 	if err != nil {
-		logger.Errorf(ctx, "Invalid base URL: %v", err)
-		s.UpdateTaskStatus(ctx, task.ID, types.ImportTaskStatusFailed, fmt.Sprintf("Invalid base URL: %v", err))
+		logger.Errorf(ctx, "Failed to crawl: %v", err)
+		s.UpdateTaskStatus(ctx, task.ID, types.ImportTaskStatusFailed, fmt.Sprintf("Crawl failed: %v", err))
 		return
 	}
 
-	// Track visited URLs to avoid duplicates
-	visited := &sync.Map{}
-	visitedCount := 0
-	successCount := 0
-	failedCount := 0
-	duplicateCount := 0
-	totalURLs := 0
-
-	// Mutex for counters
-	counterMutex := &sync.Mutex{}
-
-	// Create collector for serial processing
-	c := colly.NewCollector(
-		colly.AllowedDomains(parsedBase.Host),
-		colly.MaxDepth(5),
-		colly.Async(false), // Synchronous mode for serial processing
-	)
-
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*",
-		Parallelism: 1, // Serial processing: one at a time
-		Delay:       300 * time.Millisecond,
-	})
-
-	// OnHTML: Find links and queue them
-	c.OnHTML("a[href]", func(e *colly.HTMLElement) {
-		// Check if task was cancelled
-		currentTask, err := s.GetTaskByID(ctx, task.ID)
-		if err == nil && currentTask.Status == types.ImportTaskStatusCancelled {
-			logger.Infof(ctx, "Task %s was cancelled, stopping crawl", task.ID)
-			return
-		}
-
-		// Check if we've reached the max pages limit
-		counterMutex.Lock()
-		currentVisited := visitedCount
-		counterMutex.Unlock()
-
-		if currentVisited >= maxPages {
-			return
-		}
-
-		link := e.Attr("href")
-		absoluteURL := e.Request.AbsoluteURL(link)
-
-		if absoluteURL == "" {
-			return
-		}
-
-		parsedURL, err := url.Parse(absoluteURL)
-		if err != nil {
-			return
-		}
-
-		// Only process URLs from the same domain
-		if parsedURL.Host != parsedBase.Host {
-			return
-		}
-
-		// Remove fragment
-		parsedURL.Fragment = ""
-		cleanURL := parsedURL.String()
-
-		// Skip non-HTML resources
-		if shouldSkipURL(cleanURL) {
-			return
-		}
-
-		// Check if URL was already visited (atomic operation)
-		_, alreadyVisited := visited.LoadOrStore(cleanURL, true)
-		if alreadyVisited {
-			return
-		}
-
-		// Visit the URL (will trigger OnResponse)
-		e.Request.Visit(cleanURL)
-	})
-
-	// OnResponse: Import each successful page immediately
-	c.OnResponse(func(r *colly.Response) {
-		// Check if task was cancelled
-		currentTask, err := s.GetTaskByID(ctx, task.ID)
-		if err == nil && currentTask.Status == types.ImportTaskStatusCancelled {
-			logger.Infof(ctx, "Task %s was cancelled, stopping import", task.ID)
-			return
-		}
+	pages, err := s.repo.ListPages(ctx, task.ID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list imported pages: %v", err)
+		s.UpdateTaskStatus(ctx, task.ID, types.ImportTaskStatusFailed, err.Error())
+		return
+	}
 
-		// Check if we've reached the max pages limit
-		counterMutex.Lock()
-		if visitedCount >= maxPages {
-			counterMutex.Unlock()
-			return
-		}
-		counterMutex.Unlock()
-
-		// Only process HTML pages
-		if r.StatusCode >= 200 && r.StatusCode < 300 {
-			contentType := r.Headers.Get("Content-Type")
-			if !strings.Contains(contentType, "text/html") {
-				return
-			}
-
-			urlStr := r.Request.URL.String()
-
-			counterMutex.Lock()
-			visitedCount++
-			currentVisited := visitedCount
-			totalURLs = visitedCount
-			counterMutex.Unlock()
-
-			logger.Infof(ctx, "[%d/%d] Processing URL: %s", currentVisited, maxPages, urlStr)
-
-			// Update progress
-			s.UpdateTaskProgress(ctx, task.ID, currentVisited, successCount, failedCount, duplicateCount, urlStr)
-
-			// Immediately import this URL
-			knowledge, err := s.kgService.CreateKnowledgeFromURL(ctx, task.KnowledgeBaseID, urlStr, config.EnableMultimodel)
-
-			result := &types.ImportTaskResult{
-				URL: urlStr,
-			}
-
-			if err != nil {
-				if _, ok := err.(*types.DuplicateKnowledgeError); ok {
-					counterMutex.Lock()
-					duplicateCount++
-					counterMutex.Unlock()
-					result.Status = "duplicate"
-					logger.Infof(ctx, "Duplicate URL skipped: %s", urlStr)
-				} else {
-					counterMutex.Lock()
-					failedCount++
-					counterMutex.Unlock()
-					result.Status = "failed"
-					result.Error = err.Error()
-					logger.Warnf(ctx, "Failed to import URL %s: %v", urlStr, err)
-				}
-			} else {
-				counterMutex.Lock()
-				successCount++
-				counterMutex.Unlock()
-				result.Status = "success"
-				result.KnowledgeID = knowledge.ID
-				logger.Infof(ctx, "Successfully imported URL: %s", urlStr)
-			}
-
-			// Record result
+	totalURLs := len(crawl.Pages)
+	s.repo.UpdateRunCounts(ctx, task.ID, totalURLs, 0, 0)
+
+	pageSync := &importsync.Sync{
+		Task: task,
+		Knowledge: &importKnowledge{
+			kgService:        s.kgService,
+			knowledgeBaseID:  task.KnowledgeBaseID,
+			enableMultimodel: config.EnableMultimodel,
+		},
+		Pages: s.repo,
+		// Small delay to avoid overwhelming the system
+		Delay: 100 * time.Millisecond,
+		Cancelled: func(ctx context.Context) bool {
+			return s.isCancelled(ctx, task.ID)
+		},
+		Progress: func(ctx context.Context, i int, url string, counts importsync.Counts) {
+			s.UpdateTaskProgress(ctx, task.ID, i, counts.Success, counts.Failed, counts.Duplicate, url)
+		},
+		Report: func(ctx context.Context, result *types.ImportTaskResult) {
 			s.AddTaskResult(ctx, task.ID, result)
+		},
+	}
+	counts, completed := pageSync.Run(ctx, crawl, pages)
+	if !completed {
+		return
+	}
 
-			// Small delay to avoid overwhelming the system
-			time.Sleep(100 * time.Millisecond)
-		}
-	})
-
-	c.OnRequest(func(r *colly.Request) {
-		logger.Debugf(ctx, "Visiting: %s", r.URL.String())
-	})
+	// Final progress update
+	s.UpdateTaskProgress(ctx, task.ID, totalURLs, counts.Success, counts.Failed, counts.Duplicate, "")
+	s.repo.UpdateRunCounts(ctx, task.ID, totalURLs, counts.Unchanged, counts.Removed)
 
-	c.OnError(func(r *colly.Response, err error) {
-		if strings.Contains(err.Error(), "already visited") {
-			return
-		}
-		logger.Warnf(ctx, "Failed to crawl %s: %v", r.Request.URL.String(), err)
-	})
+	// Complete the task
+	s.CompleteTask(ctx, task.ID)
 
-	// Update total URLs to unknown initially
-	s.repo.Update(ctx, &types.ImportTask{
-		ID:        task.ID,
-		TotalURLs: maxPages,
-	})
+	logger.Infof(ctx, "Task %s completed: total=%d, success=%d, unchanged=%d, duplicate=%d, removed=%d, failed=%d",
+		task.ID, totalURLs, counts.Success, counts.Unchanged, counts.Duplicate, counts.Removed, counts.Failed)
+}
 
-	// Start crawling from base URL
-	if err := c.Visit(task.BaseURL); err != nil {
-		logger.Errorf(ctx, "Failed to start crawling: %v", err)
-		s.UpdateTaskStatus(ctx, task.ID, types.ImportTaskStatusFailed, fmt.Sprintf("Crawl failed: %v", err))
-		return
+// isCancelled reports whether the task was cancelled while it runs
+func (s *importTaskService) isCancelled(ctx context.Context, taskID string) bool {
+	currentTask, err := s.GetTaskByID(ctx, taskID)
+	if err == nil && currentTask.Status == types.ImportTaskStatusCancelled {
+		logger.Infof(ctx, "Task %s was cancelled, stopping import", taskID)
+		return true
 	}
+	return false
+}
 
-	// Wait for all requests to complete
-	c.Wait()
+// importKnowledge imports the pages of an import task into its knowledge base
+type importKnowledge struct {
+	kgService        interfaces.KnowledgeService
+	knowledgeBaseID  string
+	enableMultimodel *bool
+}
 
-	// Final progress update
-	s.UpdateTaskProgress(ctx, task.ID, totalURLs, successCount, failedCount, duplicateCount, "")
+func (k *importKnowledge) Create(ctx context.Context, url string) (string, error) {
+	knowledge, err := k.kgService.CreateKnowledgeFromURL(ctx, k.knowledgeBaseID, url, k.enableMultimodel, nil)
+	if err != nil {
+		return "", err
+	}
+	return knowledge.ID, nil
+}
 
-	// Update total URLs to actual count
-	s.repo.Update(ctx, &types.ImportTask{
-		ID:        task.ID,
-		TotalURLs: totalURLs,
-	})
+func (k *importKnowledge) Refresh(ctx context.Context, knowledgeID string) error {
+	_, err := k.kgService.RefreshKnowledge(ctx, knowledgeID)
+	return importKnowledgeError(err)
+}
 
-	// Complete the task
-	s.CompleteTask(ctx, task.ID)
+func (k *importKnowledge) Delete(ctx context.Context, knowledgeID string) error {
+	return importKnowledgeError(k.kgService.DeleteKnowledge(ctx, knowledgeID))
+}
 
-	logger.Infof(ctx, "Task %s completed: total=%d, success=%d, duplicate=%d, failed=%d",
-		task.ID, totalURLs, successCount, duplicateCount, failedCount)
+// importKnowledgeError maps the errors of the knowledge service to the errors of an import sync
+func importKnowledgeError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrKnowledgeProcessing) || errors.Is(err, ErrKnowledgeTaskExists):
+		return fmt.Errorf("%w: %v", importsync.ErrBusy, err)
+	case errors.Is(err, repository.ErrKnowledgeNotFound):
+		return importsync.ErrDeleted
+	}
+	return err
 }
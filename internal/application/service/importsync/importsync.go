@@ -0,0 +1,212 @@
+// Package importsync brings the knowledge of an import task in line with a crawl of its site: pages new to
+// the task are imported, modified pages are refreshed and the knowledge of pages that disappeared is removed
+package importsync
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+var (
+	// ErrBusy is returned by Knowledge.Refresh while the knowledge is still being processed
+	ErrBusy = errors.New("knowledge is being processed")
+	// ErrDeleted is returned by Knowledge.Refresh and Knowledge.Delete when the knowledge no longer exists
+	ErrDeleted = errors.New("knowledge was deleted")
+)
+
+// Knowledge is the knowledge of the pages of an import task
+type Knowledge interface {
+	// Create imports a page and returns the ID of its knowledge, a *types.DuplicateKnowledgeError
+	// if the page is already in the knowledge base
+	Create(ctx context.Context, url string) (string, error)
+	// Refresh schedules the knowledge to be read again from its page
+	Refresh(ctx context.Context, knowledgeID string) error
+	// Delete deletes the knowledge of a page
+	Delete(ctx context.Context, knowledgeID string) error
+}
+
+// Pages stores the pages imported by an import task
+type Pages interface {
+	SavePage(ctx context.Context, page *types.ImportTaskPage) error
+	DeletePage(ctx context.Context, pageID string) error
+}
+
+// Counts are the outcomes of the pages of a sync
+type Counts struct {
+	Success   int
+	Failed    int
+	Duplicate int
+	Unchanged int
+	Removed   int
+}
+
+// Sync syncs the pages of an import task
+type Sync struct {
+	Task      *types.ImportTask
+	Knowledge Knowledge
+	Pages     Pages
+	// Delay is waited after every submitted page to avoid overwhelming the system
+	Delay time.Duration
+	// Cancelled is checked before every page, the sync stops once it returns true
+	Cancelled func(ctx context.Context) bool
+	// Progress is called before the i-th crawled page is submitted
+	Progress func(ctx context.Context, i int, url string, counts Counts)
+	// Report is called with the result of every page that was submitted or removed
+	Report func(ctx context.Context, result *types.ImportTaskResult)
+}
+
+// Run syncs the pages of a crawl with the pages imported by earlier runs. Pages missing from an incomplete
+// crawl may still exist, only a complete crawl removes knowledge. It returns false if the sync was cancelled.
+func (s *Sync) Run(ctx context.Context,
+	crawl *interfaces.CrawlResult, pages []*types.ImportTaskPage,
+) (Counts, bool) {
+	imported := make(map[string]*types.ImportTaskPage, len(pages))
+	for _, page := range pages {
+		imported[page.URL] = page
+	}
+
+	var counts Counts
+	for i, page := range crawl.Pages {
+		if s.cancelled(ctx) {
+			return counts, false
+		}
+		logger.Infof(ctx, "[%d/%d] Processing URL: %s", i+1, len(crawl.Pages), page.URL)
+		if s.Progress != nil {
+			s.Progress(ctx, i, page.URL, counts)
+		}
+
+		previous := imported[page.URL]
+		delete(imported, page.URL)
+		result := s.importPage(ctx, page, previous)
+		if result == nil {
+			counts.Unchanged++
+			continue
+		}
+		switch result.Status {
+		case "success", "updated":
+			counts.Success++
+		case "duplicate":
+			counts.Duplicate++
+		default:
+			counts.Failed++
+		}
+		s.report(ctx, result)
+		time.Sleep(s.Delay)
+	}
+
+	if crawl.Truncated || len(crawl.Failed) > 0 {
+		if len(imported) > 0 {
+			logger.Infof(ctx, "Crawl was incomplete, keep %d pages not found by this run", len(imported))
+		}
+		return counts, true
+	}
+	for _, page := range imported {
+		if s.cancelled(ctx) {
+			return counts, false
+		}
+		result := s.removePage(ctx, page)
+		if result.Status == "removed" {
+			counts.Removed++
+		} else {
+			counts.Failed++
+		}
+		s.report(ctx, result)
+	}
+	return counts, true
+}
+
+func (s *Sync) cancelled(ctx context.Context) bool {
+	return s.Cancelled != nil && s.Cancelled(ctx)
+}
+
+func (s *Sync) report(ctx context.Context, result *types.ImportTaskResult) {
+	if s.Report != nil {
+		s.Report(ctx, result)
+	}
+}
+
+// importPage submits a crawled page. A page new to the task is imported, a page imported by an earlier run
+// is refreshed unless its lastmod is unchanged. It returns nil if nothing was submitted.
+func (s *Sync) importPage(ctx context.Context,
+	page interfaces.CrawledPage, previous *types.ImportTaskPage,
+) *types.ImportTaskResult {
+	result := &types.ImportTaskResult{URL: page.URL}
+	if previous != nil {
+		if page.LastMod != "" && page.LastMod == previous.LastMod {
+			return nil
+		}
+		err := s.Knowledge.Refresh(ctx, previous.KnowledgeID)
+		switch {
+		case err == nil:
+			previous.LastMod = page.LastMod
+			if err := s.Pages.SavePage(ctx, previous); err != nil {
+				logger.Errorf(ctx, "Failed to save imported page %s: %v", page.URL, err)
+			}
+			result.Status = "updated"
+			result.KnowledgeID = previous.KnowledgeID
+			logger.Infof(ctx, "Refreshing modified URL: %s", page.URL)
+			return result
+		case errors.Is(err, ErrBusy):
+			// The page is still being processed, its lastmod is kept so the next run refreshes it
+			return nil
+		case !errors.Is(err, ErrDeleted):
+			result.Status = "failed"
+			result.Error = err.Error()
+			logger.Warnf(ctx, "Failed to refresh URL %s: %v", page.URL, err)
+			return result
+		}
+		// The knowledge was deleted, import the page again
+		if err := s.Pages.DeletePage(ctx, previous.ID); err != nil {
+			logger.Errorf(ctx, "Failed to delete imported page %s: %v", page.URL, err)
+		}
+	}
+
+	knowledgeID, err := s.Knowledge.Create(ctx, page.URL)
+	if err != nil {
+		var duplicate *types.DuplicateKnowledgeError
+		if errors.As(err, &duplicate) {
+			result.Status = "duplicate"
+			logger.Infof(ctx, "Duplicate URL skipped: %s", page.URL)
+		} else {
+			result.Status = "failed"
+			result.Error = err.Error()
+			logger.Warnf(ctx, "Failed to import URL %s: %v", page.URL, err)
+		}
+		return result
+	}
+	if err := s.Pages.SavePage(ctx, &types.ImportTaskPage{
+		TenantID:    s.Task.TenantID,
+		TaskID:      s.Task.ID,
+		URL:         page.URL,
+		LastMod:     page.LastMod,
+		KnowledgeID: knowledgeID,
+	}); err != nil {
+		logger.Errorf(ctx, "Failed to save imported page %s: %v", page.URL, err)
+	}
+	result.Status = "success"
+	result.KnowledgeID = knowledgeID
+	logger.Infof(ctx, "Successfully imported URL: %s", page.URL)
+	return result
+}
+
+// removePage deletes the knowledge of a page that disappeared from the site
+func (s *Sync) removePage(ctx context.Context, page *types.ImportTaskPage) *types.ImportTaskResult {
+	result := &types.ImportTaskResult{URL: page.URL, KnowledgeID: page.KnowledgeID}
+	if err := s.Knowledge.Delete(ctx, page.KnowledgeID); err != nil && !errors.Is(err, ErrDeleted) {
+		result.Status = "failed"
+		result.Error = err.Error()
+		logger.Warnf(ctx, "Failed to remove knowledge of URL %s: %v", page.URL, err)
+		return result
+	}
+	if err := s.Pages.DeletePage(ctx, page.ID); err != nil {
+		logger.Errorf(ctx, "Failed to delete imported page %s: %v", page.URL, err)
+	}
+	result.Status = "removed"
+	logger.Infof(ctx, "Removed knowledge of disappeared URL: %s", page.URL)
+	return result
+}
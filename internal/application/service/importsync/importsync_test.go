@@ -0,0 +1,292 @@
+package importsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+// fakeKnowledge keeps the knowledge of a knowledge base by ID
+type fakeKnowledge struct {
+	urls       map[string]string
+	refreshErr map[string]error
+	deleteErr  map[string]error
+	refreshed  []string
+	created    int
+}
+
+func (k *fakeKnowledge) Create(ctx context.Context, url string) (string, error) {
+	for _, existing := range k.urls {
+		if existing == url {
+			return "", &types.DuplicateKnowledgeError{Message: "duplicate"}
+		}
+	}
+	k.created++
+	id := fmt.Sprintf("new-%d", k.created)
+	k.urls[id] = url
+	return id, nil
+}
+
+func (k *fakeKnowledge) Refresh(ctx context.Context, knowledgeID string) error {
+	if err := k.refreshErr[knowledgeID]; err != nil {
+		return err
+	}
+	if _, ok := k.urls[knowledgeID]; !ok {
+		return ErrDeleted
+	}
+	k.refreshed = append(k.refreshed, knowledgeID)
+	return nil
+}
+
+func (k *fakeKnowledge) Delete(ctx context.Context, knowledgeID string) error {
+	if err := k.deleteErr[knowledgeID]; err != nil {
+		return err
+	}
+	if _, ok := k.urls[knowledgeID]; !ok {
+		return ErrDeleted
+	}
+	delete(k.urls, knowledgeID)
+	return nil
+}
+
+// fakePages keeps the imported pages of a task by URL
+type fakePages map[string]types.ImportTaskPage
+
+func (p fakePages) SavePage(ctx context.Context, page *types.ImportTaskPage) error {
+	if page.ID == "" {
+		page.ID = "page-" + page.URL
+	}
+	p[page.URL] = *page
+	return nil
+}
+
+func (p fakePages) DeletePage(ctx context.Context, pageID string) error {
+	for url, page := range p {
+		if page.ID == pageID {
+			delete(p, url)
+		}
+	}
+	return nil
+}
+
+func crawled(pages ...interfaces.CrawledPage) *interfaces.CrawlResult {
+	return &interfaces.CrawlResult{Pages: pages}
+}
+
+func page(url, lastMod string) interfaces.CrawledPage {
+	return interfaces.CrawledPage{URL: url, LastMod: lastMod}
+}
+
+func imported(url, lastMod, knowledgeID string) types.ImportTaskPage {
+	return types.ImportTaskPage{ID: "page-" + url, TaskID: "t1", URL: url, LastMod: lastMod, KnowledgeID: knowledgeID}
+}
+
+func TestSyncRun(t *testing.T) {
+	failure := errors.New("docreader unavailable")
+	tests := []struct {
+		name       string
+		knowledge  map[string]string
+		refreshErr map[string]error
+		deleteErr  map[string]error
+		pages      []types.ImportTaskPage
+		crawl      *interfaces.CrawlResult
+		wantCounts Counts
+		// wantResults are the statuses of the reported results by URL
+		wantResults   map[string]string
+		wantPages     map[string]string
+		wantRefreshed []string
+	}{
+		{
+			name:        "new page is imported",
+			knowledge:   map[string]string{},
+			crawl:       crawled(page("/a", "2025-08-01")),
+			wantCounts:  Counts{Success: 1},
+			wantResults: map[string]string{"/a": "success"},
+			wantPages:   map[string]string{"/a": "new-1 2025-08-01"},
+		},
+		{
+			name:       "unchanged lastmod is skipped",
+			knowledge:  map[string]string{"k1": "/a"},
+			pages:      []types.ImportTaskPage{imported("/a", "2025-08-01", "k1")},
+			crawl:      crawled(page("/a", "2025-08-01")),
+			wantCounts: Counts{Unchanged: 1},
+			wantPages:  map[string]string{"/a": "k1 2025-08-01"},
+		},
+		{
+			name:          "modified page is refreshed",
+			knowledge:     map[string]string{"k1": "/a"},
+			pages:         []types.ImportTaskPage{imported("/a", "2025-08-01", "k1")},
+			crawl:         crawled(page("/a", "2025-08-02")),
+			wantCounts:    Counts{Success: 1},
+			wantResults:   map[string]string{"/a": "updated"},
+			wantPages:     map[string]string{"/a": "k1 2025-08-02"},
+			wantRefreshed: []string{"k1"},
+		},
+		{
+			name:          "page without lastmod is refreshed",
+			knowledge:     map[string]string{"k1": "/a"},
+			pages:         []types.ImportTaskPage{imported("/a", "", "k1")},
+			crawl:         crawled(page("/a", "")),
+			wantCounts:    Counts{Success: 1},
+			wantResults:   map[string]string{"/a": "updated"},
+			wantPages:     map[string]string{"/a": "k1 "},
+			wantRefreshed: []string{"k1"},
+		},
+		{
+			name:        "page whose knowledge was deleted is imported again",
+			knowledge:   map[string]string{},
+			pages:       []types.ImportTaskPage{imported("/a", "2025-08-01", "k1")},
+			crawl:       crawled(page("/a", "2025-08-02")),
+			wantCounts:  Counts{Success: 1},
+			wantResults: map[string]string{"/a": "success"},
+			wantPages:   map[string]string{"/a": "new-1 2025-08-02"},
+		},
+		{
+			name:       "busy knowledge keeps its lastmod for the next run",
+			knowledge:  map[string]string{"k1": "/a"},
+			refreshErr: map[string]error{"k1": fmt.Errorf("%w: processing", ErrBusy)},
+			pages:      []types.ImportTaskPage{imported("/a", "2025-08-01", "k1")},
+			crawl:      crawled(page("/a", "2025-08-02")),
+			wantCounts: Counts{Unchanged: 1},
+			wantPages:  map[string]string{"/a": "k1 2025-08-01"},
+		},
+		{
+			name:        "failed refresh",
+			knowledge:   map[string]string{"k1": "/a"},
+			refreshErr:  map[string]error{"k1": failure},
+			pages:       []types.ImportTaskPage{imported("/a", "2025-08-01", "k1")},
+			crawl:       crawled(page("/a", "2025-08-02")),
+			wantCounts:  Counts{Failed: 1},
+			wantResults: map[string]string{"/a": "failed"},
+			wantPages:   map[string]string{"/a": "k1 2025-08-01"},
+		},
+		{
+			name:        "page already in the knowledge base",
+			knowledge:   map[string]string{"k9": "/a"},
+			crawl:       crawled(page("/a", "")),
+			wantCounts:  Counts{Duplicate: 1},
+			wantResults: map[string]string{"/a": "duplicate"},
+			wantPages:   map[string]string{},
+		},
+		{
+			name:      "disappeared pages are removed after a complete crawl",
+			knowledge: map[string]string{"k1": "/a", "k2": "/b"},
+			pages: []types.ImportTaskPage{
+				imported("/a", "2025-08-01", "k1"), imported("/b", "2025-08-01", "k2"),
+				imported("/c", "2025-08-01", "k3"),
+			},
+			crawl:       crawled(page("/a", "2025-08-01")),
+			wantCounts:  Counts{Unchanged: 1, Removed: 2},
+			wantResults: map[string]string{"/b": "removed", "/c": "removed"},
+			wantPages:   map[string]string{"/a": "k1 2025-08-01"},
+		},
+		{
+			name:        "failed removal keeps the page",
+			knowledge:   map[string]string{"k1": "/a"},
+			deleteErr:   map[string]error{"k1": failure},
+			pages:       []types.ImportTaskPage{imported("/a", "2025-08-01", "k1")},
+			crawl:       crawled(),
+			wantCounts:  Counts{Failed: 1},
+			wantResults: map[string]string{"/a": "failed"},
+			wantPages:   map[string]string{"/a": "k1 2025-08-01"},
+		},
+		{
+			name:      "truncated crawl removes nothing",
+			knowledge: map[string]string{"k1": "/a", "k2": "/b"},
+			pages:     []types.ImportTaskPage{imported("/a", "2025-08-01", "k1"), imported("/b", "2025-08-01", "k2")},
+			crawl: &interfaces.CrawlResult{
+				Pages: []interfaces.CrawledPage{page("/a", "2025-08-01")}, Truncated: true,
+			},
+			wantCounts: Counts{Unchanged: 1},
+			wantPages:  map[string]string{"/a": "k1 2025-08-01", "/b": "k2 2025-08-01"},
+		},
+		{
+			name:      "crawl with failed pages removes nothing",
+			knowledge: map[string]string{"k1": "/a", "k2": "/b"},
+			pages:     []types.ImportTaskPage{imported("/a", "2025-08-01", "k1"), imported("/b", "2025-08-01", "k2")},
+			crawl: &interfaces.CrawlResult{
+				Pages: []interfaces.CrawledPage{page("/a", "2025-08-01")}, Failed: []string{"/b"},
+			},
+			wantCounts: Counts{Unchanged: 1},
+			wantPages:  map[string]string{"/a": "k1 2025-08-01", "/b": "k2 2025-08-01"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			knowledge := &fakeKnowledge{urls: tt.knowledge, refreshErr: tt.refreshErr, deleteErr: tt.deleteErr}
+			pages := fakePages{}
+			var previous []*types.ImportTaskPage
+			for _, p := range tt.pages {
+				pages[p.URL] = p
+				previous = append(previous, &p)
+			}
+			results := map[string]string{}
+			s := &Sync{
+				Task:      &types.ImportTask{ID: "t1", TenantID: 1},
+				Knowledge: knowledge,
+				Pages:     pages,
+				Report: func(ctx context.Context, result *types.ImportTaskResult) {
+					results[result.URL] = result.Status
+				},
+			}
+
+			counts, completed := s.Run(context.Background(), tt.crawl, previous)
+			if !completed {
+				t.Fatal("Run() was cancelled")
+			}
+			if counts != tt.wantCounts {
+				t.Errorf("counts = %+v, want %+v", counts, tt.wantCounts)
+			}
+			if tt.wantResults == nil {
+				tt.wantResults = map[string]string{}
+			}
+			if !reflect.DeepEqual(results, tt.wantResults) {
+				t.Errorf("results = %v, want %v", results, tt.wantResults)
+			}
+			gotPages := map[string]string{}
+			for url, p := range pages {
+				gotPages[url] = p.KnowledgeID + " " + p.LastMod
+			}
+			if !reflect.DeepEqual(gotPages, tt.wantPages) {
+				t.Errorf("pages = %v, want %v", gotPages, tt.wantPages)
+			}
+			sort.Strings(knowledge.refreshed)
+			if !reflect.DeepEqual(knowledge.refreshed, tt.wantRefreshed) {
+				t.Errorf("refreshed = %v, want %v", knowledge.refreshed, tt.wantRefreshed)
+			}
+		})
+	}
+}
+
+func TestSyncRun_Cancelled(t *testing.T) {
+	knowledge := &fakeKnowledge{urls: map[string]string{"k1": "/a"}}
+	pages := fakePages{"/a": imported("/a", "2025-08-01", "k1")}
+	previous := pages["/a"]
+	checks := 0
+	s := &Sync{
+		Task:      &types.ImportTask{ID: "t1"},
+		Knowledge: knowledge,
+		Pages:     pages,
+		Cancelled: func(ctx context.Context) bool {
+			checks++
+			return checks > 1
+		},
+	}
+
+	counts, completed := s.Run(context.Background(), crawled(page("/b", ""), page("/c", "")), []*types.ImportTaskPage{&previous})
+	if completed {
+		t.Error("Run() completed, want it cancelled")
+	}
+	if want := (Counts{Success: 1}); counts != want {
+		t.Errorf("counts = %+v, want %+v", counts, want)
+	}
+	if _, ok := knowledge.urls["k1"]; !ok {
+		t.Error("knowledge of /a was removed by a cancelled sync")
+	}
+}
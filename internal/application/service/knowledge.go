@@ -16,11 +16,17 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Tencent/WeKnora/internal/application/service/chunkdiff"
+	"github.com/Tencent/WeKnora/internal/application/service/chunker"
+	"github.com/Tencent/WeKnora/internal/application/service/docparser"
+	"github.com/Tencent/WeKnora/internal/application/service/fingerprint"
 	"github.com/Tencent/WeKnora/internal/application/service/retriever"
+	"github.com/Tencent/WeKnora/internal/application/service/versioning"
 	"github.com/Tencent/WeKnora/internal/config"
 	werrors "github.com/Tencent/WeKnora/internal/errors"
 	"github.com/Tencent/WeKnora/internal/logger"
 	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/models/embedding"
 	"github.com/Tencent/WeKnora/internal/models/utils"
 	"github.com/Tencent/WeKnora/internal/tracing"
 	"github.com/Tencent/WeKnora/internal/types"
@@ -55,7 +61,9 @@ var (
 type knowledgeService struct {
 	config          *config.Config
 	repo            interfaces.KnowledgeRepository
+	versionRepo     interfaces.KnowledgeVersionRepository
 	kbService       interfaces.KnowledgeBaseService
+	kbRepo          interfaces.KnowledgeBaseRepository
 	tenantRepo      interfaces.TenantRepository
 	docReaderClient *client.Client
 	chunkService    interfaces.ChunkService
@@ -63,47 +71,72 @@ type knowledgeService struct {
 	fileSvc         interfaces.FileService
 	modelService    interfaces.ModelService
 	task            *asynq.Client
+	inspector       *asynq.Inspector
 	graphEngine     interfaces.RetrieveGraphRepository
+	answerCache     interfaces.AnswerCache
+	// ingestion is the config of the document tasks
+	ingestion *config.IngestionConfig
+	// ingestionLimiter limits the document tasks each tenant runs at the same time
+	ingestionLimiter *tenantLimiter
 }
 
 // NewKnowledgeService creates a new knowledge service instance
 func NewKnowledgeService(
 	config *config.Config,
 	repo interfaces.KnowledgeRepository,
+	versionRepo interfaces.KnowledgeVersionRepository,
 	docReaderClient *client.Client,
 	kbService interfaces.KnowledgeBaseService,
+	kbRepo interfaces.KnowledgeBaseRepository,
 	tenantRepo interfaces.TenantRepository,
 	chunkService interfaces.ChunkService,
 	chunkRepo interfaces.ChunkRepository,
 	fileSvc interfaces.FileService,
 	modelService interfaces.ModelService,
 	task *asynq.Client,
+	inspector *asynq.Inspector,
 	graphEngine interfaces.RetrieveGraphRepository,
+	answerCache interfaces.AnswerCache,
 ) (interfaces.KnowledgeService, error) {
+	ingestion := config.Ingestion
+	if ingestion == nil {
+		ingestion = defaultIngestionConfig
+	}
 	return &knowledgeService{
-		config:          config,
-		repo:            repo,
-		kbService:       kbService,
-		tenantRepo:      tenantRepo,
-		docReaderClient: docReaderClient,
-		chunkService:    chunkService,
-		chunkRepo:       chunkRepo,
-		fileSvc:         fileSvc,
-		modelService:    modelService,
-		task:            task,
-		graphEngine:     graphEngine,
+		config:           config,
+		repo:             repo,
+		versionRepo:      versionRepo,
+		kbService:        kbService,
+		kbRepo:           kbRepo,
+		tenantRepo:       tenantRepo,
+		docReaderClient:  docReaderClient,
+		chunkService:     chunkService,
+		chunkRepo:        chunkRepo,
+		fileSvc:          fileSvc,
+		modelService:     modelService,
+		task:             task,
+		inspector:        inspector,
+		graphEngine:      graphEngine,
+		answerCache:      answerCache,
+		ingestion:        ingestion,
+		ingestionLimiter: newTenantLimiter(ingestion.TenantConcurrency),
 	}, nil
 }
 
 // CreateKnowledgeFromFile creates a knowledge entry from an uploaded file
 func (s *knowledgeService) CreateKnowledgeFromFile(ctx context.Context,
 	kbID string, file *multipart.FileHeader, metadata map[string]string, enableMultimodel *bool,
+	accessLabels types.AccessLabels,
 ) (*types.Knowledge, error) {
 	logger.Info(ctx, "Start creating knowledge from file")
 	logger.Infof(ctx, "Knowledge base ID: %s, file: %s", kbID, file.Filename)
 	if metadata != nil {
 		logger.Infof(ctx, "Received metadata: %v", metadata)
 	}
+	accessLabels, err := normalizeAccessLabels(accessLabels)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get knowledge base configuration
 	logger.Info(ctx, "Getting knowledge base configuration")
@@ -230,6 +263,7 @@ func (s *knowledgeService) CreateKnowledgeFromFile(ctx context.Context,
 		UpdatedAt:        time.Now(),
 		EmbeddingModelID: kb.EmbeddingModelID,
 		Metadata:         metadataJSON,
+		AccessLabels:     accessLabels,
 	}
 	// Save knowledge record to database
 	logger.Info(ctx, "Saving knowledge record to database")
@@ -254,12 +288,15 @@ func (s *knowledgeService) CreateKnowledgeFromFile(ctx context.Context,
 	}
 
 	// Process document asynchronously
-	logger.Info(ctx, "Starting asynchronous document processing")
-	newCtx := logger.CloneContext(ctx)
+	logger.Info(ctx, "Enqueuing document processing task")
 	if enableMultimodel == nil {
 		enableMultimodel = &kb.ChunkingConfig.EnableMultimodal
 	}
-	go s.processDocument(newCtx, kb, knowledge, file, *enableMultimodel)
+	if err := s.startDocumentProcess(ctx, knowledge, &types.DocumentProcessPayload{
+		EnableMultimodel: *enableMultimodel,
+	}); err != nil {
+		return nil, err
+	}
 
 	logger.Infof(ctx, "Knowledge from file created successfully, ID: %s", knowledge.ID)
 	return knowledge, nil
@@ -267,10 +304,14 @@ func (s *knowledgeService) CreateKnowledgeFromFile(ctx context.Context,
 
 // CreateKnowledgeFromURL creates a knowledge entry from a URL source
 func (s *knowledgeService) CreateKnowledgeFromURL(ctx context.Context,
-	kbID string, url string, enableMultimodel *bool,
+	kbID string, url string, enableMultimodel *bool, accessLabels types.AccessLabels,
 ) (*types.Knowledge, error) {
 	logger.Info(ctx, "Start creating knowledge from URL")
 	logger.Infof(ctx, "Knowledge base ID: %s, URL: %s", kbID, url)
+	accessLabels, err := normalizeAccessLabels(accessLabels)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get knowledge base configuration
 	logger.Info(ctx, "Getting knowledge base configuration")
@@ -347,6 +388,7 @@ func (s *knowledgeService) CreateKnowledgeFromURL(ctx context.Context,
 		CreatedAt:        time.Now(),
 		UpdatedAt:        time.Now(),
 		EmbeddingModelID: kb.EmbeddingModelID,
+		AccessLabels:     accessLabels,
 	}
 
 	// Save knowledge record
@@ -357,12 +399,15 @@ func (s *knowledgeService) CreateKnowledgeFromURL(ctx context.Context,
 	}
 
 	// Process URL asynchronously
-	logger.Info(ctx, "Starting asynchronous URL processing")
+	logger.Info(ctx, "Enqueuing URL processing task")
 	if enableMultimodel == nil {
 		enableMultimodel = &kb.ChunkingConfig.EnableMultimodal
 	}
-	newCtx := logger.CloneContext(ctx)
-	go s.processDocumentFromURL(newCtx, kb, knowledge, url, *enableMultimodel)
+	if err := s.startDocumentProcess(ctx, knowledge, &types.DocumentProcessPayload{
+		EnableMultimodel: *enableMultimodel,
+	}); err != nil {
+		return nil, err
+	}
 
 	logger.Infof(ctx, "Knowledge from URL created successfully, ID: %s", knowledge.ID)
 	return knowledge, nil
@@ -370,10 +415,14 @@ func (s *knowledgeService) CreateKnowledgeFromURL(ctx context.Context,
 
 // CreateKnowledgeFromPassage creates a knowledge entry from text passages
 func (s *knowledgeService) CreateKnowledgeFromPassage(ctx context.Context,
-	kbID string, passage []string,
+	kbID string, passage []string, accessLabels types.AccessLabels,
 ) (*types.Knowledge, error) {
 	logger.Info(ctx, "Start creating knowledge from passage")
 	logger.Infof(ctx, "Knowledge base ID: %s, passage count: %d", kbID, len(passage))
+	accessLabels, err := normalizeAccessLabels(accessLabels)
+	if err != nil {
+		return nil, err
+	}
 
 	// 验证段落内容安全性
 	safePassages := make([]string, 0, len(passage))
@@ -406,6 +455,7 @@ func (s *knowledgeService) CreateKnowledgeFromPassage(ctx context.Context,
 		CreatedAt:        time.Now(),
 		UpdatedAt:        time.Now(),
 		EmbeddingModelID: kb.EmbeddingModelID,
+		AccessLabels:     accessLabels,
 	}
 
 	// Save knowledge record
@@ -416,9 +466,12 @@ func (s *knowledgeService) CreateKnowledgeFromPassage(ctx context.Context,
 	}
 
 	// Process passages asynchronously
-	logger.Info(ctx, "Starting asynchronous passage processing")
-	newCtx := logger.CloneContext(ctx)
-	go s.processDocumentFromPassage(newCtx, kb, knowledge, safePassages)
+	logger.Info(ctx, "Enqueuing passage processing task")
+	if err := s.startDocumentProcess(ctx, knowledge, &types.DocumentProcessPayload{
+		Passages: safePassages,
+	}); err != nil {
+		return nil, err
+	}
 
 	logger.Infof(ctx, "Knowledge from passage created successfully, ID: %s", knowledge.ID)
 	return knowledge, nil
@@ -531,7 +584,15 @@ func (s *knowledgeService) DeleteKnowledge(ctx context.Context, id string) error
 		return err
 	}
 	// Delete the knowledge entry itself from the database
-	return s.repo.DeleteKnowledge(ctx, ctx.Value(types.TenantIDContextKey).(uint), id)
+	if err := s.repo.DeleteKnowledge(ctx, ctx.Value(types.TenantIDContextKey).(uint), id); err != nil {
+		return err
+	}
+	if err := s.versionRepo.DeleteByKnowledgeList(ctx, knowledge.TenantID, []string{id}); err != nil {
+		logger.GetLogger(ctx).WithField("error", err).Errorf("DeleteKnowledge delete knowledge versions failed")
+	}
+	s.releaseDuplicates(ctx, knowledge.TenantID, []string{id})
+	s.invalidateAnswerCache(ctx, id)
+	return nil
 }
 
 // DeleteKnowledge deletes a knowledge entry and all related resources
@@ -617,7 +678,15 @@ func (s *knowledgeService) DeleteKnowledgeList(ctx context.Context, ids []string
 		return err
 	}
 	// 5. Delete the knowledge entry itself from the database
-	return s.repo.DeleteKnowledgeList(ctx, tenantInfo.ID, ids)
+	if err := s.repo.DeleteKnowledgeList(ctx, tenantInfo.ID, ids); err != nil {
+		return err
+	}
+	if err := s.versionRepo.DeleteByKnowledgeList(ctx, tenantInfo.ID, ids); err != nil {
+		logger.GetLogger(ctx).WithField("error", err).Errorf("DeleteKnowledge delete knowledge versions failed")
+	}
+	s.releaseDuplicates(ctx, tenantInfo.ID, ids)
+	s.invalidateAnswerCache(ctx, ids...)
+	return nil
 }
 
 func (s *knowledgeService) cloneKnowledge(ctx context.Context, src *types.Knowledge, targetKB *types.KnowledgeBase) (err error) {
@@ -644,6 +713,8 @@ func (s *knowledgeService) cloneKnowledge(ctx context.Context, src *types.Knowle
 		FilePath:         src.FilePath,
 		StorageSize:      src.StorageSize,
 		Metadata:         src.Metadata,
+		AccessLabels:     src.AccessLabels,
+		MinHash:          src.MinHash,
 	}
 	defer func() {
 		if err != nil {
@@ -676,10 +747,10 @@ func (s *knowledgeService) cloneKnowledge(ctx context.Context, src *types.Knowle
 	return
 }
 
-// processDocument handles asynchronous processing of document files
+// processDocument processes the stored file of file knowledge
 func (s *knowledgeService) processDocument(ctx context.Context,
-	kb *types.KnowledgeBase, knowledge *types.Knowledge, file *multipart.FileHeader, enableMultimodel bool,
-) {
+	kb *types.KnowledgeBase, knowledge *types.Knowledge, enableMultimodel bool,
+) error {
 	logger.GetLogger(ctx).Infof("processDocument enableMultimodel: %v", enableMultimodel)
 
 	ctx, span := tracing.ContextWithSpan(ctx, "knowledgeService.processDocument")
@@ -698,51 +769,72 @@ func (s *knowledgeService) processDocument(ctx context.Context,
 	)
 	logger.GetLogger(ctx).Infof("processDocument trace id: %s", span.SpanContext().TraceID().String())
 
-	if !enableMultimodel && IsImageType(knowledge.FileType) {
-		logger.GetLogger(ctx).WithField("knowledge_id", knowledge.ID).
-			WithField("error", ErrImageNotParse).Errorf("processDocument image without enable multimodel")
-		knowledge.ParseStatus = "failed"
-		knowledge.ErrorMessage = ErrImageNotParse.Error()
-		s.repo.UpdateKnowledge(ctx, knowledge)
-		span.RecordError(ErrImageNotParse)
-		return
+	span.AddEvent("start read file")
+	chunks, err := s.readKnowledgeFile(ctx, kb, knowledge, enableMultimodel)
+	if err != nil {
+		span.RecordError(err)
+		return err
 	}
+	knowledge.ContentHash = versioning.ContentHash(chunks)
 
-	// Update status to processing
-	knowledge.ParseStatus = "processing"
-	knowledge.UpdatedAt = time.Now()
-	if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
-		span.RecordError(err)
-		return
+	// Process and store chunks
+	span.AddEvent("start process chunks")
+	return s.processChunks(ctx, kb, knowledge, chunks)
+}
+
+// readKnowledgeFile reads the stored file of file knowledge and splits it into chunks
+func (s *knowledgeService) readKnowledgeFile(ctx context.Context,
+	kb *types.KnowledgeBase, knowledge *types.Knowledge, enableMultimodel bool,
+) ([]*proto.Chunk, error) {
+	if !enableMultimodel && IsImageType(knowledge.FileType) {
+		logger.GetLogger(ctx).WithField("knowledge_id", knowledge.ID).
+			WithField("error", ErrImageNotParse).Errorf("readKnowledgeFile image without enable multimodel")
+		return nil, skipRetry(ErrImageNotParse)
 	}
 
-	// Read and chunk the document
-	f, err := file.Open()
+	f, err := s.fileSvc.GetFile(ctx, knowledge.FilePath)
 	if err != nil {
 		logger.GetLogger(ctx).WithField("knowledge_id", knowledge.ID).
-			WithField("error", err).Errorf("processDocument open file failed")
-		knowledge.ParseStatus = "failed"
-		knowledge.ErrorMessage = err.Error()
-		knowledge.UpdatedAt = time.Now()
-		s.repo.UpdateKnowledge(ctx, knowledge)
-		span.RecordError(err)
-		return
+			WithField("error", err).Errorf("readKnowledgeFile open file failed")
+		return nil, err
 	}
 	defer f.Close()
-
-	span.AddEvent("start read file")
 	contentBytes, err := io.ReadAll(f)
 	if err != nil {
-		knowledge.ParseStatus = "failed"
-		knowledge.ErrorMessage = err.Error()
-		knowledge.UpdatedAt = time.Now()
-		s.repo.UpdateKnowledge(ctx, knowledge)
-		span.RecordError(err)
-		return
+		return nil, err
+	}
+
+	// Split file into chunks using the native parser or the document reader service
+	chunks, err := s.readFile(ctx, kb, knowledge, contentBytes, enableMultimodel)
+	if err != nil {
+		logger.GetLogger(ctx).WithField("knowledge_id", knowledge.ID).
+			WithField("error", err).Errorf("readKnowledgeFile read file failed")
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// readFile splits the content of a file into chunks with the parser the docreader config routes its file type to.
+// File types without a route use the native parser if docreader cannot read them, docreader otherwise.
+func (s *knowledgeService) readFile(ctx context.Context,
+	kb *types.KnowledgeBase, knowledge *types.Knowledge, contentBytes []byte, enableMultimodel bool,
+) ([]*proto.Chunk, error) {
+	mode := s.config.DocReader.ParserMode(knowledge.FileType)
+	if mode == "" {
+		mode = config.ParserModeDocReader
+		if docparser.Supports(knowledge.FileType) && !isDocReaderFileType(knowledge.FileType) {
+			mode = config.ParserModeNative
+		}
+	}
+	if mode == config.ParserModeNative || mode == config.ParserModeNativeFallback {
+		chunks, err := docparser.Read(knowledge.FileType, contentBytes, kb.ChunkingConfig)
+		if err == nil || mode == config.ParserModeNative {
+			return chunks, err
+		}
+		logger.GetLogger(ctx).WithField("knowledge_id", knowledge.ID).
+			WithField("error", err).Warnf("readFile native parser failed, falling back to docreader")
 	}
 
-	// Split file into chunks using document reader service
-	span.AddEvent("start split file")
 	resp, err := s.docReaderClient.ReadFromFile(ctx, &proto.ReadFromFileRequest{
 		FileContent: contentBytes,
 		FileName:    knowledge.FileName,
@@ -771,35 +863,33 @@ func (s *knowledgeService) processDocument(ctx context.Context,
 		RequestId: ctx.Value(types.RequestIDContextKey).(string),
 	})
 	if err != nil {
-		logger.GetLogger(ctx).WithField("knowledge_id", knowledge.ID).
-			WithField("error", err).Errorf("processDocument read file failed")
-		knowledge.ParseStatus = "failed"
-		knowledge.ErrorMessage = err.Error()
-		knowledge.UpdatedAt = time.Now()
-		s.repo.UpdateKnowledge(ctx, knowledge)
-		span.RecordError(err)
-		return
+		return nil, err
 	}
-
-	// Process and store chunks
-	span.AddEvent("start process chunks")
-	s.processChunks(ctx, kb, knowledge, resp.Chunks)
+	return resp.Chunks, nil
 }
 
-// processDocumentFromURL handles asynchronous processing of URL content
+// processDocumentFromURL processes the content of URL knowledge
 func (s *knowledgeService) processDocumentFromURL(ctx context.Context,
 	kb *types.KnowledgeBase, knowledge *types.Knowledge, url string, enableMultimodel bool,
-) {
-	// Update status to processing
-	knowledge.ParseStatus = "processing"
-	knowledge.UpdatedAt = time.Now()
-	if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
-		return
-	}
+) error {
 	logger.GetLogger(ctx).Infof("processDocumentFromURL enableMultimodel: %v", enableMultimodel)
 
 	// Fetch and chunk content from URL
-	resp, err := s.docReaderClient.ReadFromURL(ctx, &proto.ReadFromURLRequest{
+	resp, err := s.readURL(ctx, kb, knowledge, url, enableMultimodel)
+	if err != nil {
+		return err
+	}
+	knowledge.ContentHash = versioning.ContentHash(resp.Chunks)
+
+	// Process and store chunks
+	return s.processChunks(ctx, kb, knowledge, resp.Chunks)
+}
+
+// readURL fetches the content of a URL and splits it into chunks with the chunking config of the knowledge base
+func (s *knowledgeService) readURL(ctx context.Context,
+	kb *types.KnowledgeBase, knowledge *types.Knowledge, url string, enableMultimodel bool,
+) (*proto.ReadResponse, error) {
+	return s.docReaderClient.ReadFromURL(ctx, &proto.ReadFromURLRequest{
 		Url:   url,
 		Title: knowledge.Title,
 		ReadConfig: &proto.ReadConfig{
@@ -825,29 +915,12 @@ func (s *knowledgeService) processDocumentFromURL(ctx context.Context,
 		},
 		RequestId: ctx.Value(types.RequestIDContextKey).(string),
 	})
-	if err != nil {
-		knowledge.ParseStatus = "failed"
-		knowledge.ErrorMessage = err.Error()
-		knowledge.UpdatedAt = time.Now()
-		s.repo.UpdateKnowledge(ctx, knowledge)
-		return
-	}
-
-	// Process and store chunks
-	s.processChunks(ctx, kb, knowledge, resp.Chunks)
 }
 
-// processDocumentFromPassage handles asynchronous processing of text passages
+// processDocumentFromPassage processes the text passages of passage knowledge
 func (s *knowledgeService) processDocumentFromPassage(ctx context.Context,
 	kb *types.KnowledgeBase, knowledge *types.Knowledge, passage []string,
-) {
-	// Update status to processing
-	knowledge.ParseStatus = "processing"
-	knowledge.UpdatedAt = time.Now()
-	if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
-		return
-	}
-
+) error {
 	// Convert passages to chunks
 	chunks := make([]*proto.Chunk, 0, len(passage))
 	start, end := 0, 0
@@ -866,13 +939,13 @@ func (s *knowledgeService) processDocumentFromPassage(ctx context.Context,
 		chunks = append(chunks, chunk)
 	}
 	// Process and store chunks
-	s.processChunks(ctx, kb, knowledge, chunks)
+	return s.processChunks(ctx, kb, knowledge, chunks)
 }
 
 // processChunks processes chunks and creates embeddings for knowledge content
 func (s *knowledgeService) processChunks(ctx context.Context,
-	kb *types.KnowledgeBase, knowledge *types.Knowledge, chunks []*proto.Chunk,
-) {
+	kb *types.KnowledgeBase, knowledge *types.Knowledge, protoChunks []*proto.Chunk,
+) error {
 	ctx, span := tracing.ContextWithSpan(ctx, "knowledgeService.processChunks")
 	defer span.End()
 	span.SetAttributes(
@@ -880,7 +953,7 @@ func (s *knowledgeService) processChunks(ctx context.Context,
 		attribute.String("knowledge_base_id", knowledge.KnowledgeBaseID),
 		attribute.String("knowledge_id", knowledge.ID),
 		attribute.String("embedding_model_id", kb.EmbeddingModelID),
-		attribute.Int("chunk_count", len(chunks)),
+		attribute.Int("chunk_count", len(protoChunks)),
 	)
 
 	// Get embedding model for vectorization
@@ -888,15 +961,27 @@ func (s *knowledgeService) processChunks(ctx context.Context,
 	if err != nil {
 		logger.GetLogger(ctx).WithField("error", err).Errorf("processChunks get embedding model failed")
 		span.RecordError(err)
-		return
+		return err
 	}
 
+	// Re-split the docreader chunks with the chunking strategy of the knowledge base
+	chunks, err := chunker.Split(ctx, kb.ChunkingConfig, protoChunks, embeddingModel)
+	if err != nil {
+		logger.GetLogger(ctx).WithField("error", err).Errorf("processChunks split chunks failed")
+		span.RecordError(err)
+		return err
+	}
+	span.SetAttributes(
+		attribute.String("chunking_strategy", string(kb.ChunkingConfig.GetStrategy())),
+		attribute.Int("split_chunk_count", len(chunks)),
+	)
+
 	// Generate document summary - 只使用文本类型的 Chunk
 	chatModel, err := s.modelService.GetChatModel(ctx, kb.SummaryModelID)
 	if err != nil {
 		logger.GetLogger(ctx).WithField("error", err).Errorf("processChunks get summary model failed")
 		span.RecordError(err)
-		return
+		return err
 	}
 
 	enableGraphRAG := os.Getenv("ENABLE_GRAPH_RAG") == "true"
@@ -938,6 +1023,7 @@ func (s *knowledgeService) processChunks(ctx context.Context,
 			StartAt:         int(chunkData.Start),
 			EndAt:           int(chunkData.End),
 			ChunkType:       types.ChunkTypeText,
+			Metadata:        chunkData.Metadata,
 		}
 		var chunkImages []types.ImageInfo
 		insertChunks = append(insertChunks, textChunk)
@@ -1041,7 +1127,26 @@ func (s *knowledgeService) processChunks(ctx context.Context,
 			textChunks[i+1].PreChunkID = chunk.ID
 		}
 	}
-	if enableGraphRAG {
+
+	// Fingerprint the content and apply the dedup policy of the knowledge base
+	skipDuplicate, err := s.dedupKnowledge(ctx, kb, knowledge, textChunks)
+	if err != nil {
+		logger.GetLogger(ctx).WithField("error", err).Errorf("processChunks dedup knowledge failed")
+		span.RecordError(err)
+		return err
+	}
+
+	// 父子分块：长文本Chunk切分为子Chunk建立索引，父Chunk只保存不索引，检索命中子Chunk时返回父Chunk
+	childChunks := buildChildChunks(kb.ChunkingConfig, textChunks)
+	parentChunkIDs := make(map[string]bool)
+	for _, chunk := range childChunks {
+		parentChunkIDs[chunk.ParentChunkID] = true
+	}
+	insertChunks = append(insertChunks, childChunks...)
+	if len(childChunks) > 0 {
+		logger.GetLogger(ctx).Infof("Created %d child chunks for %d parent chunks", len(childChunks), len(parentChunkIDs))
+	}
+	if enableGraphRAG && !skipDuplicate {
 		relationChunkSize := 5
 		indirectRelationChunkSize := 5
 		graphBuilder := NewGraphBuilder(s.config, chatModel)
@@ -1092,8 +1197,12 @@ func (s *knowledgeService) processChunks(ctx context.Context,
 	}
 
 	span.AddEvent("extract summary")
-	summary, err := s.getSummary(ctx, chatModel, knowledge, textChunks)
-	if err != nil {
+	if skipDuplicate {
+		// Skipped near-duplicates are not indexed, they are described by their first chunk instead of a summary
+		if len(textChunks) > 0 {
+			knowledge.Description = textChunks[0].Content
+		}
+	} else if summary, err := s.getSummary(ctx, chatModel, knowledge, textChunks); err != nil {
 		logger.GetLogger(ctx).WithField("knowledge_id", knowledge.ID).
 			WithField("error", err).Errorf("processChunks get summary failed, use first chunk as description")
 		if len(textChunks) > 0 {
@@ -1126,8 +1235,76 @@ func (s *knowledgeService) processChunks(ctx context.Context,
 		insertChunks = append(insertChunks, sChunk)
 	}
 
-	// Create index information for each chunk
-	indexInfoList := utils.MapSlice(insertChunks, func(chunk *types.Chunk) *types.IndexInfo {
+	if skipDuplicate {
+		for _, chunk := range insertChunks {
+			markDuplicateChunk(chunk, knowledge.DuplicateOf)
+		}
+	}
+	if err := s.storeChunks(ctx, knowledge, embeddingModel, insertChunks); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	logger.Infof(ctx, "processChunks create relationship rag task")
+	for _, chunk := range textChunks {
+		if chunk.Metadata.GetDuplicateOf() != "" {
+			continue
+		}
+		err := NewChunkExtractTask(ctx, s.task, chunk.TenantID, chunk.ID, kb.SummaryModelID)
+		if err != nil {
+			logger.GetLogger(ctx).WithField("error", err).Errorf("processChunks create chunk extract task failed")
+			span.RecordError(err)
+		}
+	}
+
+	logger.GetLogger(ctx).Infof("processChunks successfully")
+	return nil
+}
+
+// storeChunks saves the chunks of a knowledge entry in place of its stored chunks, indexes them
+// and marks the knowledge as completed
+func (s *knowledgeService) storeChunks(ctx context.Context,
+	knowledge *types.Knowledge, embeddingModel embedding.Embedder, insertChunks []*types.Chunk,
+) error {
+	ctx, span := tracing.ContextWithSpan(ctx, "knowledgeService.storeChunks")
+	defer span.End()
+
+	// Reuse the stored chunks and vectors of unchanged content, only new and modified chunks are embedded.
+	// Chunks are only saved once they are indexed, so a retry diffs against what a failed attempt stored.
+	storedChunks, err := s.chunkRepo.ListChunksByKnowledgeIDAndType(ctx, knowledge.TenantID, knowledge.ID, nil)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	// Chunks carry the filterable metadata and the access labels of their knowledge into the index
+	attributes := knowledgeIndexMetadata(knowledge)
+	accessLabels := knowledge.AccessLabels
+	if len(accessLabels) == 0 {
+		accessLabels = nil
+	}
+	for _, chunk := range insertChunks {
+		if chunk.SimHash == 0 {
+			chunk.SimHash = int64(fingerprint.SimHash(chunk.Content))
+		}
+		if chunk.Metadata == nil && attributes == nil && accessLabels == nil {
+			continue
+		}
+		if chunk.Metadata == nil {
+			chunk.Metadata = &types.ChunkMetadata{}
+		}
+		chunk.Metadata.Attributes = attributes
+		chunk.Metadata.AccessLabels = accessLabels
+	}
+	diff := chunkdiff.Chunks(storedChunks, insertChunks)
+	logger.GetLogger(ctx).Infof("storeChunks reuse %d stored chunks, embed %d chunks, remove %d chunks",
+		diff.Reused, len(diff.Index), len(diff.Remove))
+
+	// Create index information for each chunk, parent chunks are retrieved through their child chunks
+	indexed := chunkdiff.Indexed(insertChunks)
+	indexChunks := slices.DeleteFunc(slices.Clone(insertChunks), func(chunk *types.Chunk) bool {
+		return !indexed[chunk.ID]
+	})
+	toIndexInfo := func(chunk *types.Chunk) *types.IndexInfo {
 		return &types.IndexInfo{
 			Content:         chunk.Content,
 			SourceID:        chunk.ID,
@@ -1135,87 +1312,89 @@ func (s *knowledgeService) processChunks(ctx context.Context,
 			ChunkID:         chunk.ID,
 			KnowledgeID:     knowledge.ID,
 			KnowledgeBaseID: knowledge.KnowledgeBaseID,
+			Metadata:        chunk.Metadata.IndexMetadata(),
+			AccessLabels:    chunk.Metadata.GetAccessLabels(),
 		}
-	})
+	}
+	indexInfoList := utils.MapSlice(indexChunks, toIndexInfo)
 
 	// Initialize retrieval engine
 	tenantInfo := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
 	retrieveEngine, err := retriever.NewCompositeRetrieveEngine(tenantInfo.RetrieverEngines.Engines)
 	if err != nil {
-		knowledge.ParseStatus = "failed"
-		knowledge.ErrorMessage = err.Error()
-		knowledge.UpdatedAt = time.Now()
-		s.repo.UpdateKnowledge(ctx, knowledge)
 		span.RecordError(err)
-		return
+		return err
 	}
 
-	// Calculate storage size required for embeddings
+	// Calculate storage size required for embeddings, the stored index is already counted
 	span.AddEvent("estimate storage size")
 	totalStorageSize := retrieveEngine.EstimateStorageSize(ctx, embeddingModel, indexInfoList)
-	if tenantInfo.StorageQuota > 0 {
+	storageDelta := totalStorageSize - knowledge.StorageSize
+	if tenantInfo.StorageQuota > 0 && storageDelta > 0 {
 		// Re-fetch tenant storage information
 		tenantInfo, err = s.tenantRepo.GetTenantByID(ctx, tenantInfo.ID)
 		if err != nil {
-			knowledge.ParseStatus = "failed"
-			knowledge.ErrorMessage = err.Error()
-			knowledge.UpdatedAt = time.Now()
-			s.repo.UpdateKnowledge(ctx, knowledge)
 			span.RecordError(err)
-			return
+			return err
 		}
 		// Check if there's enough storage quota available
-		if tenantInfo.StorageUsed+totalStorageSize > tenantInfo.StorageQuota {
-			knowledge.ParseStatus = "failed"
-			knowledge.ErrorMessage = "存储空间不足"
-			knowledge.UpdatedAt = time.Now()
-			s.repo.UpdateKnowledge(ctx, knowledge)
-			span.RecordError(errors.New("storage quota exceeded"))
-			return
+		if tenantInfo.StorageUsed+storageDelta > tenantInfo.StorageQuota {
+			err := errors.New("存储空间不足")
+			span.RecordError(err)
+			return skipRetry(err)
 		}
 	}
 
+	span.AddEvent("batch index")
+	if len(diff.Reindex) > 0 {
+		if err := retrieveEngine.DeleteByChunkIDList(ctx, diff.Reindex, embeddingModel.GetDimensions()); err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+	// unindex removes the index entries added before the chunks could be saved, the retry indexes them again
+	unindex := func() {
+		ids := utils.MapSlice(diff.Index, func(chunk *types.Chunk) string { return chunk.ID })
+		if err := retrieveEngine.DeleteByChunkIDList(ctx, ids, embeddingModel.GetDimensions()); err != nil {
+			logger.Errorf(ctx, "Delete index failed: %v", err)
+		}
+	}
+	if len(diff.Index) > 0 {
+		if err := retrieveEngine.BatchIndex(ctx, embeddingModel, utils.MapSlice(diff.Index, toIndexInfo)); err != nil {
+			unindex()
+			span.RecordError(err)
+			return err
+		}
+	}
+	logger.GetLogger(ctx).Infof("storeChunks batch index successfully, with %d index", len(diff.Index))
+
 	// Save chunks to database
 	span.AddEvent("create chunks")
-	if err := s.chunkService.CreateChunks(ctx, insertChunks); err != nil {
-		knowledge.ParseStatus = "failed"
-		knowledge.ErrorMessage = err.Error()
-		knowledge.UpdatedAt = time.Now()
-		s.repo.UpdateKnowledge(ctx, knowledge)
-		span.RecordError(err)
-		return
+	if len(diff.Create) > 0 {
+		if err := s.chunkService.CreateChunks(ctx, diff.Create); err != nil {
+			unindex()
+			span.RecordError(err)
+			return err
+		}
 	}
-
-	span.AddEvent("batch index")
-	err = retrieveEngine.BatchIndex(ctx, embeddingModel, indexInfoList)
-	if err != nil {
-		knowledge.ParseStatus = "failed"
-		knowledge.ErrorMessage = err.Error()
-		knowledge.UpdatedAt = time.Now()
-		s.repo.UpdateKnowledge(ctx, knowledge)
-
-		// delete failed chunks
-		if err := s.chunkService.DeleteChunksByKnowledgeID(ctx, knowledge.ID); err != nil {
-			logger.Errorf(ctx, "Delete chunks failed: %v", err)
+	for _, chunk := range diff.Update {
+		if err := s.chunkRepo.UpdateChunk(ctx, chunk); err != nil {
+			span.RecordError(err)
+			return err
 		}
+	}
 
-		// delete index
-		if err := retrieveEngine.DeleteByKnowledgeIDList(
-			ctx, []string{knowledge.ID}, embeddingModel.GetDimensions(),
-		); err != nil {
-			logger.Errorf(ctx, "Delete index failed: %v", err)
+	// Remove what the new content no longer has
+	if len(diff.Unindex) > 0 {
+		if err := retrieveEngine.DeleteByChunkIDList(ctx, diff.Unindex, embeddingModel.GetDimensions()); err != nil {
+			span.RecordError(err)
+			return err
 		}
-		span.RecordError(err)
-		return
 	}
-	logger.GetLogger(ctx).Infof("processChunks batch index successfully, with %d index", len(indexInfoList))
-
-	logger.Infof(ctx, "processChunks create relationship rag task")
-	for _, chunk := range textChunks {
-		err := NewChunkExtractTask(ctx, s.task, chunk.TenantID, chunk.ID, kb.SummaryModelID)
-		if err != nil {
-			logger.GetLogger(ctx).WithField("error", err).Errorf("processChunks create chunk extract task failed")
+	if len(diff.Remove) > 0 {
+		if err := s.chunkRepo.DeleteChunksByIDList(ctx, knowledge.TenantID, diff.Remove); err != nil {
 			span.RecordError(err)
+			return err
 		}
 	}
 
@@ -1227,15 +1406,33 @@ func (s *knowledgeService) processChunks(ctx context.Context,
 	knowledge.ProcessedAt = &now
 	knowledge.UpdatedAt = now
 	if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
-		logger.GetLogger(ctx).WithField("error", err).Errorf("processChunks update knowledge failed")
+		logger.GetLogger(ctx).WithField("error", err).Errorf("storeChunks update knowledge failed")
 	}
 
 	// Update tenant's storage usage
-	tenantInfo.StorageUsed += totalStorageSize
-	if err := s.tenantRepo.AdjustStorageUsed(ctx, tenantInfo.ID, totalStorageSize); err != nil {
-		logger.GetLogger(ctx).WithField("error", err).Errorf("processChunks update tenant storage used failed")
+	tenantInfo.StorageUsed += storageDelta
+	if err := s.tenantRepo.AdjustStorageUsed(ctx, tenantInfo.ID, storageDelta); err != nil {
+		logger.GetLogger(ctx).WithField("error", err).Errorf("storeChunks update tenant storage used failed")
 	}
-	logger.GetLogger(ctx).Infof("processChunks successfully")
+	logger.GetLogger(ctx).Infof("storeChunks successfully")
+	return nil
+}
+
+// knowledgeIndexMetadata returns the metadata of the knowledge that its chunks can be filtered by.
+// FAQ knowledge keeps its question-answer pair in the metadata, only the category is filterable.
+func knowledgeIndexMetadata(knowledge *types.Knowledge) types.IndexMetadata {
+	if knowledge.Type == knowledgeTypeFAQ {
+		entry, err := knowledge.GetFAQEntry()
+		if err != nil || entry.Category == "" {
+			return nil
+		}
+		return types.IndexMetadata{"category": entry.Category}
+	}
+	metadata, err := knowledge.Metadata.Map()
+	if err != nil {
+		return nil
+	}
+	return types.NewIndexMetadata(metadata)
 }
 
 // GetSummary generates a summary for knowledge content using an AI model
@@ -1316,7 +1513,7 @@ func (s *knowledgeService) getSummary(ctx context.Context,
 	summary, err := summaryModel.Chat(ctx, []chat.Message{
 		{
 			Role:    "system",
-			Content: s.config.Conversation.GenerateSummaryPrompt,
+			Content: s.config.GetConversation().GenerateSummaryPrompt,
 		},
 		{
 			Role:    "user",
@@ -1362,19 +1559,52 @@ func (s *knowledgeService) UpdateKnowledge(ctx context.Context, knowledge *types
 	if knowledge.Title != "" {
 		record.Title = knowledge.Title
 	}
+	if knowledge.AccessLabels != nil {
+		labels, err := normalizeAccessLabels(knowledge.AccessLabels)
+		if err != nil {
+			return err
+		}
+		if !slices.Equal(labels, record.AccessLabels.Normalize()) {
+			// Chunks being stored would keep the old labels
+			if record.ParseStatus == "pending" || record.ParseStatus == "processing" {
+				return ErrKnowledgeProcessing
+			}
+			record.AccessLabels = labels
+			if err := s.relabelChunks(ctx, record); err != nil {
+				logger.Errorf(ctx, "Failed to relabel chunks of knowledge %s: %v", record.ID, err)
+				return err
+			}
+		}
+	}
 
 	// Update knowledge record in the repository
 	if err := s.repo.UpdateKnowledge(ctx, record); err != nil {
 		logger.Errorf(ctx, "Failed to update knowledge: %v", err)
 		return err
 	}
+	s.invalidateAnswerCache(ctx, knowledge.ID)
 	logger.Infof(ctx, "Knowledge updated successfully, ID: %s", knowledge.ID)
 	return nil
 }
 
-// isValidFileType checks if a file type is supported
+// invalidateAnswerCache removes the cached answers referencing the knowledge, failures are only logged
+func (s *knowledgeService) invalidateAnswerCache(ctx context.Context, knowledgeIDs ...string) {
+	if err := s.answerCache.InvalidateKnowledge(ctx, knowledgeIDs...); err != nil {
+		logger.ErrorWithFields(ctx, err, map[string]interface{}{
+			"knowledge_ids": knowledgeIDs,
+		})
+	}
+}
+
+// isValidFileType checks if a file type is supported by docreader or a native parser
 func isValidFileType(filename string) bool {
-	switch strings.ToLower(getFileType(filename)) {
+	fileType := getFileType(filename)
+	return isDocReaderFileType(fileType) || docparser.Supports(fileType)
+}
+
+// isDocReaderFileType checks if the docreader service can read a file type
+func isDocReaderFileType(fileType string) bool {
+	switch strings.ToLower(fileType) {
 	case "pdf", "txt", "docx", "doc", "md", "markdown", "png", "jpg", "jpeg", "gif":
 		return true
 	default:
@@ -1530,6 +1760,8 @@ func (s *knowledgeService) updateChunkVector(ctx context.Context, kbID string, c
 			ChunkID:         chunk.ID,
 			KnowledgeID:     chunk.KnowledgeID,
 			KnowledgeBaseID: chunk.KnowledgeBaseID,
+			Metadata:        chunk.Metadata.IndexMetadata(),
+			AccessLabels:    chunk.Metadata.GetAccessLabels(),
 		})
 		ids = append(ids, chunk.ID)
 	}
@@ -1591,8 +1823,14 @@ func (s *knowledgeService) UpdateImageInfo(ctx context.Context, knowledgeID stri
 	// Track whether we've found OCR and caption child chunks for this image
 	hasOCRChunk := false
 	hasCaptionChunk := false
+	// Parent chunks of child text chunks are only retrieved through their child chunks
+	isParentChunk := false
 
 	for i, child := range chunkChildren {
+		if child.ChunkType == types.ChunkTypeChildText {
+			isParentChunk = true
+			continue
+		}
 		// Skip chunks that are not image types
 		var cImageInfo []*types.ImageInfo
 		err = json.Unmarshal([]byte(child.ImageInfo), &cImageInfo)
@@ -1685,7 +1923,11 @@ func (s *knowledgeService) UpdateImageInfo(ctx context.Context, knowledgeID stri
 	}
 
 	// Update the chunk vector
-	err = s.updateChunkVector(ctx, chunk.KnowledgeBaseID, append(updateChunk, addChunk...))
+	vectorChunks := append(updateChunk, addChunk...)
+	if isParentChunk {
+		vectorChunks = vectorChunks[1:]
+	}
+	err = s.updateChunkVector(ctx, chunk.KnowledgeBaseID, vectorChunks)
 	if err != nil {
 		logger.ErrorWithFields(ctx, err, map[string]interface{}{
 			"chunk_id":     chunk.ID,
@@ -1706,6 +1948,7 @@ func (s *knowledgeService) UpdateImageInfo(ctx context.Context, knowledgeID stri
 	if err != nil {
 		logger.Warnf(ctx, "Failed to update knowledge file hash: %v", err)
 	}
+	s.invalidateAnswerCache(ctx, knowledgeID)
 
 	logger.Infof(ctx, "Updated chunk successfully, chunk ID: %s, knowledge ID: %s", chunk.ID, chunk.KnowledgeID)
 	return nil
@@ -1734,6 +1977,7 @@ func (s *knowledgeService) CloneChunk(ctx context.Context, src, dst *types.Knowl
 	chunkType := []types.ChunkType{
 		types.ChunkTypeText, types.ChunkTypeSummary,
 		types.ChunkTypeImageCaption, types.ChunkTypeImageOCR,
+		types.ChunkTypeChildText, types.ChunkTypeFAQ, types.ChunkTypeFAQQuestion,
 	}
 	for {
 		sourceChunks, _, err := s.chunkRepo.ListPagedChunksByKnowledgeID(ctx,
@@ -1768,6 +2012,8 @@ func (s *knowledgeService) CloneChunk(ctx context.Context, src, dst *types.Knowl
 				ChunkType:       sourceChunk.ChunkType,
 				ParentChunkID:   sourceChunk.ParentChunkID,
 				ImageInfo:       sourceChunk.ImageInfo,
+				Metadata:        sourceChunk.Metadata,
+				SimHash:         sourceChunk.SimHash,
 			}
 			targetChunks = append(targetChunks, targetChunk)
 			srcTodst[sourceChunk.ID] = targetChunk.ID
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"slices"
+
+	"github.com/Tencent/WeKnora/internal/application/service/chunkdiff"
+	"github.com/Tencent/WeKnora/internal/application/service/retriever"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// normalizeAccessLabels cleans up the access labels of knowledge and checks them
+func normalizeAccessLabels(labels types.AccessLabels) (types.AccessLabels, error) {
+	labels = labels.Normalize()
+	if err := labels.Validate(); err != nil {
+		return nil, werrors.NewValidationError(err.Error())
+	}
+	return labels, nil
+}
+
+// relabelChunks gives the chunks of the knowledge its access labels and replaces their index entries,
+// so the retrieval engines enforce the new labels. The chunks are embedded again.
+func (s *knowledgeService) relabelChunks(ctx context.Context, knowledge *types.Knowledge) error {
+	chunks, err := s.chunkRepo.ListChunksByKnowledgeIDAndType(ctx, knowledge.TenantID, knowledge.ID, nil)
+	if err != nil {
+		return err
+	}
+	logger.Infof(ctx, "Relabel %d chunks of knowledge %s, access labels: %v",
+		len(chunks), knowledge.ID, knowledge.AccessLabels)
+
+	labels := knowledge.AccessLabels
+	if len(labels) == 0 {
+		labels = nil
+	}
+	for _, chunk := range chunks {
+		if slices.Equal(chunk.Metadata.GetAccessLabels(), labels) {
+			continue
+		}
+		if chunk.Metadata == nil {
+			chunk.Metadata = &types.ChunkMetadata{}
+		}
+		chunk.Metadata.AccessLabels = labels
+		if err := s.chunkRepo.UpdateChunk(ctx, chunk); err != nil {
+			return err
+		}
+	}
+
+	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, knowledge.KnowledgeBaseID)
+	if err != nil {
+		return err
+	}
+	embeddingModel, err := s.modelService.GetEmbeddingModel(ctx, kb.EmbeddingModelID)
+	if err != nil {
+		return err
+	}
+	tenantInfo := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
+	retrieveEngine, err := retriever.NewCompositeRetrieveEngine(tenantInfo.RetrieverEngines.Engines)
+	if err != nil {
+		return err
+	}
+
+	// Parent chunks are retrieved through their child chunks and FAQ chunks through their questions
+	indexed := chunkdiff.Indexed(chunks)
+	indexInfoList := make([]*types.IndexInfo, 0, len(chunks))
+	for _, chunk := range chunks {
+		if !indexed[chunk.ID] {
+			continue
+		}
+		indexInfoList = append(indexInfoList, &types.IndexInfo{
+			Content:         chunk.Content,
+			SourceID:        chunk.ID,
+			SourceType:      types.ChunkSourceType,
+			ChunkID:         chunk.ID,
+			KnowledgeID:     knowledge.ID,
+			KnowledgeBaseID: knowledge.KnowledgeBaseID,
+			Metadata:        chunk.Metadata.IndexMetadata(),
+			AccessLabels:    chunk.Metadata.GetAccessLabels(),
+		})
+	}
+	if err := retrieveEngine.DeleteByKnowledgeIDList(
+		ctx, []string{knowledge.ID}, embeddingModel.GetDimensions(),
+	); err != nil {
+		return err
+	}
+	if len(indexInfoList) == 0 {
+		return nil
+	}
+	return retrieveEngine.BatchIndex(ctx, embeddingModel, indexInfoList)
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/application/service/chunkdiff"
+	"github.com/Tencent/WeKnora/internal/application/service/fingerprint"
+	"github.com/Tencent/WeKnora/internal/application/service/retriever"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// dedupKnowledge fingerprints the content of the knowledge and its text chunks, and applies the dedup policy
+// of the knowledge base if the content nearly duplicates earlier knowledge. It returns true if the policy skips
+// the knowledge, its chunks are then stored without being indexed.
+func (s *knowledgeService) dedupKnowledge(ctx context.Context,
+	kb *types.KnowledgeBase, knowledge *types.Knowledge, textChunks []*types.Chunk,
+) (bool, error) {
+	contents := make([]string, 0, len(textChunks))
+	for _, chunk := range textChunks {
+		chunk.SimHash = int64(fingerprint.SimHash(chunk.Content))
+		contents = append(contents, chunk.Content)
+	}
+	knowledge.MinHash = fingerprint.MinHash(strings.Join(contents, "\n"))
+	knowledge.DuplicateOf = ""
+	knowledge.DuplicateSimilarity = 0
+	if !kb.DedupConfig.Enabled() || knowledge.MinHash == nil {
+		return false, nil
+	}
+
+	duplicate, similarity, err := s.findNearDuplicate(ctx, knowledge, kb.DedupConfig.GetThreshold())
+	if err != nil || duplicate == nil {
+		return false, err
+	}
+	logger.Infof(ctx, "Knowledge nearly duplicates knowledge %s, similarity: %.2f, policy: %s",
+		duplicate.ID, similarity, kb.DedupConfig.Policy)
+	knowledge.DuplicateOf = duplicate.ID
+	knowledge.DuplicateSimilarity = similarity
+
+	switch kb.DedupConfig.Policy {
+	case types.DedupPolicySkip:
+		return true, nil
+	case types.DedupPolicyMerge:
+		return false, s.mergeDuplicateChunks(ctx, duplicate, textChunks)
+	}
+	return false, nil
+}
+
+// findNearDuplicate returns the earlier knowledge of the knowledge base whose content is most similar
+// to the knowledge, nil if none reaches the threshold. Knowledge that is itself a near-duplicate is not
+// a match, so near-duplicates always point to the first knowledge of their content.
+func (s *knowledgeService) findNearDuplicate(ctx context.Context,
+	knowledge *types.Knowledge, threshold float64,
+) (*types.Knowledge, float64, error) {
+	candidates, err := s.repo.ListKnowledgeFingerprints(ctx, knowledge.TenantID, knowledge.KnowledgeBaseID)
+	if err != nil {
+		return nil, 0, err
+	}
+	var duplicate *types.Knowledge
+	var best float64
+	for _, candidate := range candidates {
+		if candidate.ID == knowledge.ID || candidate.DuplicateOf != "" || candidate.ParseStatus != "completed" ||
+			!candidate.CreatedAt.Before(knowledge.CreatedAt) {
+			continue
+		}
+		similarity := fingerprint.Similarity(knowledge.MinHash, candidate.MinHash)
+		if similarity >= threshold && similarity > best {
+			duplicate, best = candidate, similarity
+		}
+	}
+	return duplicate, best, nil
+}
+
+// mergeDuplicateChunks marks the text chunks that nearly duplicate an indexed text chunk of the duplicated
+// knowledge, they are stored but not indexed and retrieval finds their content through the earlier chunk
+func (s *knowledgeService) mergeDuplicateChunks(ctx context.Context,
+	duplicate *types.Knowledge, textChunks []*types.Chunk,
+) error {
+	stored, err := s.chunkRepo.ListChunksByKnowledgeIDAndType(
+		ctx, duplicate.TenantID, duplicate.ID, []types.ChunkType{types.ChunkTypeText},
+	)
+	if err != nil {
+		return err
+	}
+	hashes := make([]uint64, 0, len(stored))
+	for _, chunk := range stored {
+		if chunk.Metadata.GetDuplicateOf() != "" {
+			continue
+		}
+		hash := uint64(chunk.SimHash)
+		if hash == 0 {
+			hash = fingerprint.SimHash(chunk.Content)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	merged := 0
+	for _, chunk := range textChunks {
+		if chunk.SimHash == 0 {
+			continue
+		}
+		for _, hash := range hashes {
+			if fingerprint.Distance(uint64(chunk.SimHash), hash) <= fingerprint.NearDuplicateDistance {
+				markDuplicateChunk(chunk, duplicate.ID)
+				merged++
+				break
+			}
+		}
+	}
+	logger.Infof(ctx, "Merged %d of %d text chunks into knowledge %s", merged, len(textChunks), duplicate.ID)
+	return nil
+}
+
+// markDuplicateChunk records the knowledge a chunk nearly duplicates
+func markDuplicateChunk(chunk *types.Chunk, knowledgeID string) {
+	if chunk.Metadata == nil {
+		chunk.Metadata = &types.ChunkMetadata{}
+	}
+	chunk.Metadata.DuplicateOf = knowledgeID
+}
+
+// releaseDuplicates detaches the knowledge found to nearly duplicate deleted knowledge. The chunks
+// that were not indexed as near-duplicates are indexed, so their content stays retrievable.
+// Failures are only logged, the knowledge is deleted already.
+func (s *knowledgeService) releaseDuplicates(ctx context.Context, tenantID uint, ids []string) {
+	knowledgeList, err := s.repo.ListKnowledgeByDuplicateOf(ctx, tenantID, ids)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list near-duplicates of deleted knowledge: %v", err)
+		return
+	}
+	deleted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		deleted[id] = true
+	}
+	for _, knowledge := range knowledgeList {
+		if err := s.releaseDuplicate(ctx, knowledge, deleted); err != nil {
+			logger.Errorf(ctx, "Failed to release near-duplicate knowledge %s: %v", knowledge.ID, err)
+		}
+	}
+}
+
+// releaseDuplicate indexes the chunks of the knowledge marked as near-duplicates of deleted knowledge
+func (s *knowledgeService) releaseDuplicate(ctx context.Context,
+	knowledge *types.Knowledge, deleted map[string]bool,
+) error {
+	chunks, err := s.chunkRepo.ListChunksByKnowledgeIDAndType(ctx, knowledge.TenantID, knowledge.ID, nil)
+	if err != nil {
+		return err
+	}
+	wasIndexed := chunkdiff.Indexed(chunks)
+	for _, chunk := range chunks {
+		if !deleted[chunk.Metadata.GetDuplicateOf()] {
+			continue
+		}
+		chunk.Metadata.DuplicateOf = ""
+		if err := s.chunkRepo.UpdateChunk(ctx, chunk); err != nil {
+			return err
+		}
+	}
+
+	indexed := chunkdiff.Indexed(chunks)
+	indexInfoList := make([]*types.IndexInfo, 0, len(chunks))
+	for _, chunk := range chunks {
+		if !indexed[chunk.ID] || wasIndexed[chunk.ID] {
+			continue
+		}
+		indexInfoList = append(indexInfoList, &types.IndexInfo{
+			Content:         chunk.Content,
+			SourceID:        chunk.ID,
+			SourceType:      types.ChunkSourceType,
+			ChunkID:         chunk.ID,
+			KnowledgeID:     knowledge.ID,
+			KnowledgeBaseID: knowledge.KnowledgeBaseID,
+			Metadata:        chunk.Metadata.IndexMetadata(),
+			AccessLabels:    chunk.Metadata.GetAccessLabels(),
+		})
+	}
+	if len(indexInfoList) > 0 {
+		embeddingModel, err := s.modelService.GetEmbeddingModel(ctx, knowledge.EmbeddingModelID)
+		if err != nil {
+			return err
+		}
+		tenantInfo := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
+		retrieveEngine, err := retriever.NewCompositeRetrieveEngine(tenantInfo.RetrieverEngines.Engines)
+		if err != nil {
+			return err
+		}
+		storageSize := retrieveEngine.EstimateStorageSize(ctx, embeddingModel, indexInfoList)
+		if err := retrieveEngine.BatchIndex(ctx, embeddingModel, indexInfoList); err != nil {
+			return err
+		}
+		knowledge.StorageSize += storageSize
+		if err := s.tenantRepo.AdjustStorageUsed(ctx, knowledge.TenantID, storageSize); err != nil {
+			logger.Errorf(ctx, "Failed to update tenant storage used: %v", err)
+		}
+	}
+	logger.Infof(ctx, "Released near-duplicate knowledge %s of deleted knowledge %s, indexed %d chunks",
+		knowledge.ID, knowledge.DuplicateOf, len(indexInfoList))
+
+	knowledge.DuplicateOf = ""
+	knowledge.DuplicateSimilarity = 0
+	knowledge.UpdatedAt = time.Now()
+	return s.repo.UpdateKnowledge(ctx, knowledge)
+}
+
+// ListDuplicateClusters groups the fingerprinted knowledge of a knowledge base whose contents nearly duplicate
+// each other, largest clusters first. A threshold of 0 uses the threshold of the knowledge base.
+func (s *knowledgeService) ListDuplicateClusters(ctx context.Context,
+	kbID string, threshold float64,
+) ([]*types.DuplicateCluster, error) {
+	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, kbID)
+	if err != nil {
+		return nil, err
+	}
+	if threshold == 0 {
+		threshold = kb.DedupConfig.GetThreshold()
+	}
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	knowledgeList, err := s.repo.ListKnowledgeFingerprints(ctx, tenantID, kbID)
+	if err != nil {
+		return nil, err
+	}
+	signatures := make([][]uint32, len(knowledgeList))
+	for i, knowledge := range knowledgeList {
+		signatures[i] = knowledge.MinHash
+	}
+
+	groups := fingerprint.Clusters(signatures, threshold)
+	clusters := make([]*types.DuplicateCluster, 0, len(groups))
+	for _, group := range groups {
+		first := knowledgeList[group[0]]
+		cluster := &types.DuplicateCluster{Knowledge: make([]*types.DuplicateClusterMember, 0, len(group))}
+		for _, i := range group {
+			knowledge := knowledgeList[i]
+			cluster.Knowledge = append(cluster.Knowledge, &types.DuplicateClusterMember{
+				ID:          knowledge.ID,
+				Title:       knowledge.Title,
+				Type:        knowledge.Type,
+				Source:      knowledge.Source,
+				FileName:    knowledge.FileName,
+				ParseStatus: knowledge.ParseStatus,
+				DuplicateOf: knowledge.DuplicateOf,
+				CreatedAt:   knowledge.CreatedAt,
+				Similarity:  fingerprint.Similarity(first.MinHash, knowledge.MinHash),
+			})
+		}
+		clusters = append(clusters, cluster)
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return len(clusters[i].Knowledge) > len(clusters[j].Knowledge)
+	})
+	logger.Infof(ctx, "Found %d duplicate clusters in knowledge base %s, threshold: %.2f",
+		len(clusters), kbID, threshold)
+	return clusters, nil
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+	"github.com/google/uuid"
+)
+
+// knowledgeTypeFAQ is the type of knowledge holding one question-answer pair
+const knowledgeTypeFAQ = "faq"
+
+// ImportFAQ imports question-answer pairs into a knowledge base, each pair becomes one FAQ knowledge entry.
+// An entry replaces the FAQ knowledge with the same question; only changed entries are processed again,
+// and their unchanged questions keep their vectors.
+func (s *knowledgeService) ImportFAQ(ctx context.Context,
+	kbID string, entries []*types.FAQEntry,
+) (*types.FAQImportResult, error) {
+	logger.Infof(ctx, "Start importing FAQ entries, knowledge base ID: %s, entry count: %d", kbID, len(entries))
+
+	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, kbID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get knowledge base: %v", err)
+		return nil, err
+	}
+	tenantInfo, ok := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
+	if !ok {
+		return nil, fmt.Errorf("tenant info not found in context")
+	}
+	if tenantInfo.StorageQuota > 0 && tenantInfo.StorageUsed >= tenantInfo.StorageQuota {
+		logger.Error(ctx, "Storage quota exceeded")
+		return nil, types.NewStorageQuotaExceededError()
+	}
+
+	existing, err := s.listFAQKnowledge(ctx, kb.TenantID, kbID)
+	if err != nil {
+		return nil, err
+	}
+	byQuestion := make(map[string]*types.Knowledge, len(existing))
+	for _, knowledge := range existing {
+		byQuestion[knowledge.Title] = knowledge
+	}
+
+	result := &types.FAQImportResult{Failed: make([]types.FAQImportError, 0)}
+	fail := func(index int, entry *types.FAQEntry, err error) {
+		result.Failed = append(result.Failed, types.FAQImportError{
+			Index: index, Question: entry.Question, Error: err.Error(),
+		})
+	}
+	imported := make(map[string]bool, len(entries))
+	var updatedIDs []string
+	for i, entry := range entries {
+		if entry == nil {
+			entry = &types.FAQEntry{}
+		}
+		entry.Normalize()
+		if err := validateFAQEntry(entry); err != nil {
+			fail(i, entry, err)
+			continue
+		}
+		if imported[entry.Question] {
+			fail(i, entry, errors.New("duplicate question in request"))
+			continue
+		}
+		imported[entry.Question] = true
+
+		metadata, err := json.Marshal(entry)
+		if err != nil {
+			fail(i, entry, err)
+			continue
+		}
+
+		knowledge, ok := byQuestion[entry.Question]
+		if !ok {
+			knowledge = &types.Knowledge{
+				ID:               uuid.New().String(),
+				TenantID:         kb.TenantID,
+				KnowledgeBaseID:  kbID,
+				Type:             knowledgeTypeFAQ,
+				Title:            entry.Question,
+				Description:      entry.Answer,
+				FileHash:         calculateStr(entry.Question),
+				Metadata:         metadata,
+				ParseStatus:      "pending",
+				EnableStatus:     "disabled",
+				CreatedAt:        time.Now(),
+				UpdatedAt:        time.Now(),
+				EmbeddingModelID: kb.EmbeddingModelID,
+			}
+			if err := s.repo.CreateKnowledge(ctx, knowledge); err != nil {
+				logger.Errorf(ctx, "Failed to create FAQ knowledge: %v", err)
+				fail(i, entry, err)
+				continue
+			}
+			if err := s.startDocumentProcess(ctx, knowledge, &types.DocumentProcessPayload{}); err != nil {
+				fail(i, entry, err)
+				continue
+			}
+			result.Created++
+			continue
+		}
+
+		if knowledge.ParseStatus == "pending" || knowledge.ParseStatus == "processing" {
+			fail(i, entry, ErrKnowledgeProcessing)
+			continue
+		}
+		if previous, err := knowledge.GetFAQEntry(); err == nil &&
+			reflect.DeepEqual(previous, entry) && knowledge.ParseStatus == "completed" {
+			result.Unchanged++
+			continue
+		}
+		// The stored chunks are kept, the document task only embeds the questions that changed
+		knowledge.Description = entry.Answer
+		knowledge.Metadata = metadata
+		knowledge.ParseStatus = "pending"
+		knowledge.ErrorMessage = ""
+		knowledge.UpdatedAt = time.Now()
+		if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
+			logger.Errorf(ctx, "Failed to update FAQ knowledge %s: %v", knowledge.ID, err)
+			fail(i, entry, err)
+			continue
+		}
+		if err := s.startDocumentProcess(ctx, knowledge, &types.DocumentProcessPayload{}); err != nil {
+			fail(i, entry, err)
+			continue
+		}
+		updatedIDs = append(updatedIDs, knowledge.ID)
+		result.Updated++
+	}
+	if len(updatedIDs) > 0 {
+		s.invalidateAnswerCache(ctx, updatedIDs...)
+	}
+
+	logger.Infof(ctx, "FAQ entries imported, created: %d, updated: %d, unchanged: %d, failed: %d",
+		result.Created, result.Updated, result.Unchanged, len(result.Failed))
+	return result, nil
+}
+
+// ExportFAQ returns the question-answer pairs of the FAQ knowledge in a knowledge base, oldest first
+func (s *knowledgeService) ExportFAQ(ctx context.Context, kbID string) ([]*types.FAQEntry, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	knowledgeList, err := s.listFAQKnowledge(ctx, tenantID, kbID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(knowledgeList, func(i, j int) bool {
+		return knowledgeList[i].CreatedAt.Before(knowledgeList[j].CreatedAt)
+	})
+	entries := make([]*types.FAQEntry, 0, len(knowledgeList))
+	for _, knowledge := range knowledgeList {
+		entry, err := knowledge.GetFAQEntry()
+		if err != nil {
+			logger.Warnf(ctx, "Skip FAQ knowledge that cannot be exported: %v", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	logger.Infof(ctx, "FAQ entries exported, knowledge base ID: %s, entry count: %d", kbID, len(entries))
+	return entries, nil
+}
+
+// listFAQKnowledge lists the FAQ knowledge of a knowledge base
+func (s *knowledgeService) listFAQKnowledge(ctx context.Context, tenantID uint, kbID string) ([]*types.Knowledge, error) {
+	knowledgeList, err := s.repo.ListKnowledgeByKnowledgeBaseID(ctx, tenantID, kbID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list knowledge of knowledge base %s: %v", kbID, err)
+		return nil, err
+	}
+	faqList := make([]*types.Knowledge, 0, len(knowledgeList))
+	for _, knowledge := range knowledgeList {
+		if knowledge.Type == knowledgeTypeFAQ {
+			faqList = append(faqList, knowledge)
+		}
+	}
+	return faqList, nil
+}
+
+// validateFAQEntry checks a normalized entry, including the safety of its content
+func validateFAQEntry(entry *types.FAQEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	for _, text := range append(entry.Questions(), entry.Answer, entry.Category) {
+		if _, ok := secutils.ValidateInput(text); !ok {
+			return errors.New("entry contains invalid content")
+		}
+	}
+	return nil
+}
+
+// processFAQ stores the chunks of FAQ knowledge: one FAQ chunk with the question and answer, which is
+// only retrieved through one question chunk per question and similar question
+func (s *knowledgeService) processFAQ(ctx context.Context,
+	kb *types.KnowledgeBase, knowledge *types.Knowledge,
+) error {
+	entry, err := knowledge.GetFAQEntry()
+	if err != nil {
+		return skipRetry(err)
+	}
+	embeddingModel, err := s.modelService.GetEmbeddingModel(ctx, kb.EmbeddingModelID)
+	if err != nil {
+		logger.GetLogger(ctx).WithField("error", err).Errorf("processFAQ get embedding model failed")
+		return err
+	}
+
+	now := time.Now()
+	faqChunk := &types.Chunk{
+		ID:              uuid.New().String(),
+		TenantID:        knowledge.TenantID,
+		KnowledgeID:     knowledge.ID,
+		KnowledgeBaseID: knowledge.KnowledgeBaseID,
+		Content:         fmt.Sprintf("问题：%s\n答案：%s", entry.Question, entry.Answer),
+		ChunkIndex:      0,
+		IsEnabled:       true,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		ChunkType:       types.ChunkTypeFAQ,
+		Metadata:        &types.ChunkMetadata{FAQ: entry},
+	}
+	chunks := []*types.Chunk{faqChunk}
+	for i, question := range entry.Questions() {
+		chunks = append(chunks, &types.Chunk{
+			ID:              uuid.New().String(),
+			TenantID:        knowledge.TenantID,
+			KnowledgeID:     knowledge.ID,
+			KnowledgeBaseID: knowledge.KnowledgeBaseID,
+			Content:         question,
+			ChunkIndex:      i + 1,
+			IsEnabled:       true,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+			ChunkType:       types.ChunkTypeFAQQuestion,
+			ParentChunkID:   faqChunk.ID,
+		})
+	}
+	logger.GetLogger(ctx).Infof("processFAQ created FAQ chunk with %d question chunks", len(chunks)-1)
+	return s.storeChunks(ctx, knowledge, embeddingModel, chunks)
+}
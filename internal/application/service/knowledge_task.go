@@ -0,0 +1,393 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	"github.com/Tencent/WeKnora/internal/config"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/hibiken/asynq"
+)
+
+// ErrKnowledgeTaskLost is recorded on knowledge whose document task is gone and cannot be re-created
+var ErrKnowledgeTaskLost = errors.New("导入任务已丢失，请重新导入")
+
+// ErrDeadLetterTaskNotFound is returned when a dead letter task does not exist or belongs to another tenant
+var ErrDeadLetterTaskNotFound = errors.New("dead letter task not found")
+
+// defaultIngestionConfig is used when the ingestion section is missing from the config file
+var defaultIngestionConfig = &config.IngestionConfig{
+	Queue:             "default",
+	MaxRetry:          5,
+	RetryBaseDelay:    10 * time.Second,
+	RetryMaxDelay:     10 * time.Minute,
+	Timeout:           30 * time.Minute,
+	KnowledgeVersions: 5,
+}
+
+// permanentError is a task error that is not retried, the task is archived as a dead letter right away
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() []error {
+	return []error{e.err, asynq.SkipRetry}
+}
+
+// skipRetry marks err as permanent
+func skipRetry(err error) error {
+	return &permanentError{err: err}
+}
+
+// tenantLimiter limits the number of document tasks a tenant runs at the same time in this process
+type tenantLimiter struct {
+	limit   int
+	mu      sync.Mutex
+	cond    *sync.Cond
+	running map[uint]int
+}
+
+func newTenantLimiter(limit int) *tenantLimiter {
+	l := &tenantLimiter{limit: limit, running: make(map[uint]int)}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// tryAcquire takes a slot for the tenant, it returns false if all slots are taken
+func (l *tenantLimiter) tryAcquire(tenantID uint) bool {
+	if l.limit <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.running[tenantID] >= l.limit {
+		return false
+	}
+	l.running[tenantID]++
+	return true
+}
+
+// acquire waits for a slot of the tenant
+func (l *tenantLimiter) acquire(tenantID uint) {
+	if l.limit <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.running[tenantID] >= l.limit {
+		l.cond.Wait()
+	}
+	l.running[tenantID]++
+}
+
+// release frees a slot taken by tryAcquire or acquire
+func (l *tenantLimiter) release(tenantID uint) {
+	if l.limit <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.running[tenantID]--; l.running[tenantID] <= 0 {
+		delete(l.running, tenantID)
+	}
+	l.cond.Broadcast()
+}
+
+// enqueueDocumentProcess enqueues the document task of a knowledge entry, the task ID is the knowledge ID
+func (s *knowledgeService) enqueueDocumentProcess(ctx context.Context, payload *types.DocumentProcessPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	task := asynq.NewTask(types.TypeDocumentProcess, data,
+		asynq.TaskID(payload.KnowledgeID),
+		asynq.Queue(s.ingestion.Queue),
+		asynq.MaxRetry(s.ingestion.MaxRetry),
+		asynq.Timeout(s.ingestion.Timeout),
+	)
+	info, err := s.task.EnqueueContext(ctx, task)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to enqueue document task, knowledge ID: %s, error: %v", payload.KnowledgeID, err)
+		return fmt.Errorf("failed to enqueue document task: %w", err)
+	}
+	logger.Infof(ctx, "Enqueued document task: id=%s queue=%s", info.ID, info.Queue)
+	return nil
+}
+
+// startDocumentProcess enqueues the document task of a newly created knowledge entry,
+// the knowledge is marked as failed if the task cannot be enqueued
+func (s *knowledgeService) startDocumentProcess(ctx context.Context,
+	knowledge *types.Knowledge, payload *types.DocumentProcessPayload,
+) error {
+	payload.TenantID = knowledge.TenantID
+	payload.KnowledgeID = knowledge.ID
+	if requestID, ok := ctx.Value(types.RequestIDContextKey).(string); ok {
+		payload.RequestID = requestID
+	}
+	if err := s.enqueueDocumentProcess(ctx, payload); err != nil {
+		s.failKnowledge(ctx, knowledge, err)
+		return err
+	}
+	return nil
+}
+
+// ProcessDocument handles a TypeDocumentProcess task: it parses, chunks, embeds and indexes a knowledge entry.
+// Failed attempts are retried with backoff, the knowledge is marked as failed once the task
+// fails permanently or runs out of retries.
+func (s *knowledgeService) ProcessDocument(ctx context.Context, t *asynq.Task) error {
+	var payload types.DocumentProcessPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Errorf(ctx, "Failed to unmarshal document task payload: %v", err)
+		return skipRetry(err)
+	}
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+
+	// Leave the slot to other tasks while the tenant is busy, the last attempt waits so it cannot be archived
+	if retried < maxRetry {
+		if !s.ingestionLimiter.tryAcquire(payload.TenantID) {
+			return types.ErrTenantIngestionBusy
+		}
+	} else {
+		s.ingestionLimiter.acquire(payload.TenantID)
+	}
+	defer s.ingestionLimiter.release(payload.TenantID)
+
+	ctx = context.WithValue(ctx, types.RequestIDContextKey, payload.RequestID)
+	ctx = context.WithValue(ctx, types.TenantIDContextKey, payload.TenantID)
+	ctx = logger.WithRequestID(ctx, payload.RequestID)
+	ctx = logger.WithField(ctx, "knowledge_id", payload.KnowledgeID)
+	logger.Infof(ctx, "Start document task, attempt: %d/%d", retried+1, maxRetry+1)
+
+	tenant, err := s.tenantRepo.GetTenantByID(ctx, payload.TenantID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get tenant: %v", err)
+		return err
+	}
+	ctx = context.WithValue(ctx, types.TenantInfoContextKey, tenant)
+
+	knowledge, err := s.repo.GetKnowledgeByID(ctx, payload.TenantID, payload.KnowledgeID)
+	if err != nil {
+		if err == repository.ErrKnowledgeNotFound {
+			logger.Warn(ctx, "Knowledge was deleted, skip document task")
+			return nil
+		}
+		return err
+	}
+	if payload.Rollback > 0 {
+		err = s.rollbackKnowledge(ctx, knowledge, payload.Rollback)
+	} else if payload.Refresh {
+		err = s.refreshKnowledge(ctx, knowledge, &payload)
+	} else if knowledge.ParseStatus == "completed" {
+		logger.Info(ctx, "Knowledge is already processed, skip document task")
+		return nil
+	} else {
+		err = s.processKnowledge(ctx, knowledge, &payload)
+	}
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, asynq.SkipRetry) || retried >= maxRetry {
+		logger.Errorf(ctx, "Document task failed permanently: %v", err)
+		if (payload.Refresh || payload.Rollback > 0) && knowledge.ParseStatus == "completed" {
+			// The refresh or rollback failed before reindexing, the indexed content stays in use.
+			// An uploaded file is kept for the dead letter task, retrying it reads the file again.
+			s.failRefresh(ctx, knowledge, err)
+		} else {
+			s.failKnowledge(ctx, knowledge, err)
+		}
+	} else {
+		logger.Warnf(ctx, "Document task failed, will retry: %v", err)
+	}
+	return err
+}
+
+// processKnowledge runs one attempt of a document task. A previous attempt may have stopped halfway,
+// the chunks it stored are diffed against like those of any earlier processing.
+func (s *knowledgeService) processKnowledge(ctx context.Context,
+	knowledge *types.Knowledge, payload *types.DocumentProcessPayload,
+) error {
+	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, knowledge.KnowledgeBaseID)
+	if err != nil {
+		return err
+	}
+
+	// Update status to processing
+	knowledge.ParseStatus = "processing"
+	knowledge.ErrorMessage = ""
+	knowledge.UpdatedAt = time.Now()
+	if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
+		return err
+	}
+
+	switch knowledge.Type {
+	case "file":
+		return s.processDocument(ctx, kb, knowledge, payload.EnableMultimodel)
+	case "url":
+		return s.processDocumentFromURL(ctx, kb, knowledge, knowledge.Source, payload.EnableMultimodel)
+	case "passage":
+		return s.processDocumentFromPassage(ctx, kb, knowledge, payload.Passages)
+	case knowledgeTypeFAQ:
+		return s.processFAQ(ctx, kb, knowledge)
+	}
+	return skipRetry(fmt.Errorf("unsupported knowledge type: %s", knowledge.Type))
+}
+
+// failKnowledge marks a knowledge entry as failed with the error of its document task
+func (s *knowledgeService) failKnowledge(ctx context.Context, knowledge *types.Knowledge, err error) {
+	knowledge.ParseStatus = "failed"
+	knowledge.ErrorMessage = err.Error()
+	knowledge.UpdatedAt = time.Now()
+	if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
+		logger.Errorf(ctx, "Failed to mark knowledge as failed, ID: %s, error: %v", knowledge.ID, err)
+	}
+}
+
+// failRefresh records the error of a refresh or rollback that failed before reindexing. The knowledge stays completed,
+// only the error is stored because the attempt may have changed other fields of knowledge in memory.
+func (s *knowledgeService) failRefresh(ctx context.Context, knowledge *types.Knowledge, err error) {
+	if err := s.repo.UpdateKnowledgeColumn(ctx, knowledge.ID, "sync_error", err.Error()); err != nil {
+		logger.Errorf(ctx, "Failed to record refresh error, ID: %s, error: %v", knowledge.ID, err)
+	}
+}
+
+// RecoverOrphanedKnowledge finds knowledge left pending or processing without a document task,
+// e.g. by a restart before the knowledge was enqueued. File, URL and FAQ knowledge is enqueued again,
+// passage knowledge is marked as failed because its passages are only kept in the task.
+func (s *knowledgeService) RecoverOrphanedKnowledge(ctx context.Context) error {
+	knowledgeList, err := s.repo.ListKnowledgeByParseStatus(ctx, "pending", "processing")
+	if err != nil {
+		return err
+	}
+	var requeued, failed int
+	for _, knowledge := range knowledgeList {
+		info, err := s.inspector.GetTaskInfo(s.ingestion.Queue, knowledge.ID)
+		switch {
+		case err == nil && info.State == asynq.TaskStateArchived:
+			// The task gave up but the knowledge was not updated
+			s.failKnowledge(ctx, knowledge, errors.New(info.LastErr))
+			failed++
+			continue
+		case err == nil:
+			continue
+		case !errors.Is(err, asynq.ErrTaskNotFound) && !errors.Is(err, asynq.ErrQueueNotFound):
+			return err
+		}
+
+		payload := &types.DocumentProcessPayload{TenantID: knowledge.TenantID, KnowledgeID: knowledge.ID}
+		if knowledge.Type == "passage" || (knowledge.Type == "file" && knowledge.FilePath == "") {
+			s.failKnowledge(ctx, knowledge, ErrKnowledgeTaskLost)
+			failed++
+			continue
+		}
+		ctx := context.WithValue(ctx, types.TenantIDContextKey, knowledge.TenantID)
+		kb, err := s.kbService.GetKnowledgeBaseByID(ctx, knowledge.KnowledgeBaseID)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to get knowledge base of orphaned knowledge %s: %v", knowledge.ID, err)
+			continue
+		}
+		payload.EnableMultimodel = kb.ChunkingConfig.EnableMultimodal
+		if err := s.enqueueDocumentProcess(ctx, payload); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+			return err
+		}
+		requeued++
+	}
+	logger.Infof(ctx, "Recovered orphaned knowledge, requeued: %d, failed: %d", requeued, failed)
+	return nil
+}
+
+// ListDeadLetterTasks lists the archived document tasks of the current tenant, most recently failed first
+func (s *knowledgeService) ListDeadLetterTasks(ctx context.Context,
+	pagination *types.Pagination,
+) (*types.PageResult, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	tasks := make([]*types.DeadLetterTask, 0)
+	for page := 1; ; page++ {
+		infos, err := s.inspector.ListArchivedTasks(s.ingestion.Queue, asynq.Page(page), asynq.PageSize(100))
+		if err != nil {
+			if errors.Is(err, asynq.ErrQueueNotFound) {
+				break
+			}
+			return nil, err
+		}
+		for _, info := range infos {
+			if payload, ok := documentTaskPayload(info); ok && payload.TenantID == tenantID {
+				tasks = append(tasks, &types.DeadLetterTask{
+					ID:           info.ID,
+					KnowledgeID:  payload.KnowledgeID,
+					Queue:        info.Queue,
+					Retried:      info.Retried,
+					MaxRetry:     info.MaxRetry,
+					LastError:    info.LastErr,
+					LastFailedAt: info.LastFailedAt,
+				})
+			}
+		}
+		if len(infos) < 100 {
+			break
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].LastFailedAt.After(tasks[j].LastFailedAt)
+	})
+
+	total := len(tasks)
+	start := min(pagination.Offset(), total)
+	end := min(start+pagination.GetPageSize(), total)
+	return types.NewPageResult(int64(total), pagination, tasks[start:end]), nil
+}
+
+// RetryDeadLetterTask runs an archived document task of the current tenant again
+func (s *knowledgeService) RetryDeadLetterTask(ctx context.Context, taskID string) error {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	info, err := s.inspector.GetTaskInfo(s.ingestion.Queue, taskID)
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+			return ErrDeadLetterTaskNotFound
+		}
+		return err
+	}
+	payload, ok := documentTaskPayload(info)
+	if !ok || payload.TenantID != tenantID || info.State != asynq.TaskStateArchived {
+		return ErrDeadLetterTaskNotFound
+	}
+
+	knowledge, err := s.repo.GetKnowledgeByID(ctx, tenantID, payload.KnowledgeID)
+	if err != nil {
+		return err
+	}
+	knowledge.ParseStatus = "pending"
+	knowledge.ErrorMessage = ""
+	knowledge.UpdatedAt = time.Now()
+	if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
+		return err
+	}
+	if err := s.inspector.RunTask(info.Queue, info.ID); err != nil {
+		return err
+	}
+	logger.Infof(ctx, "Dead letter document task scheduled again, knowledge ID: %s", knowledge.ID)
+	return nil
+}
+
+// documentTaskPayload decodes the payload of a document task
+func documentTaskPayload(info *asynq.TaskInfo) (*types.DocumentProcessPayload, bool) {
+	if info.Type != types.TypeDocumentProcess {
+		return nil, false
+	}
+	var payload types.DocumentProcessPayload
+	if err := json.Unmarshal(info.Payload, &payload); err != nil {
+		return nil, false
+	}
+	return &payload, true
+}
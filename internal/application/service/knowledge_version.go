@@ -0,0 +1,413 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"mime/multipart"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	"github.com/Tencent/WeKnora/internal/application/service/versioning"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+	"github.com/Tencent/WeKnora/services/docreader/src/proto"
+	"github.com/hibiken/asynq"
+)
+
+var (
+	// ErrKnowledgeNotRefreshable is returned when refreshing knowledge that was not imported from a URL
+	ErrKnowledgeNotRefreshable = errors.New("only URL knowledge can be refreshed")
+	// ErrKnowledgeFileNotReplaceable is returned when replacing the file of knowledge that was not uploaded as a file
+	ErrKnowledgeFileNotReplaceable = errors.New("only file knowledge can have its file replaced")
+	// ErrKnowledgeProcessing is returned when knowledge is changed while its document task is running
+	ErrKnowledgeProcessing = errors.New("knowledge is being processed")
+	// ErrKnowledgeTaskExists is returned when knowledge already has a queued or dead letter document task
+	ErrKnowledgeTaskExists = errors.New("knowledge already has a queued or dead letter task")
+	// ErrKnowledgeVersionActive is returned when rolling back to the version that is already active
+	ErrKnowledgeVersionActive = errors.New("knowledge version is already active")
+)
+
+// versionedChunkTypes are the chunk types kept in a knowledge version, graph chunks are extracted again
+var versionedChunkTypes = []types.ChunkType{
+	types.ChunkTypeText, types.ChunkTypeSummary,
+	types.ChunkTypeImageCaption, types.ChunkTypeImageOCR,
+	types.ChunkTypeChildText, types.ChunkTypeFAQ, types.ChunkTypeFAQQuestion,
+}
+
+// RefreshKnowledge enqueues a refresh of URL knowledge. The document task fetches the URL again
+// and only reindexes the knowledge if the content hash changed, keeping the replaced version.
+func (s *knowledgeService) RefreshKnowledge(ctx context.Context, id string) (*types.Knowledge, error) {
+	knowledge, err := s.repo.GetKnowledgeByID(ctx, ctx.Value(types.TenantIDContextKey).(uint), id)
+	if err != nil {
+		return nil, err
+	}
+	if knowledge.Type != "url" {
+		return nil, ErrKnowledgeNotRefreshable
+	}
+	if knowledge.ParseStatus == "pending" || knowledge.ParseStatus == "processing" {
+		return nil, ErrKnowledgeProcessing
+	}
+	if err := s.enqueueRefresh(ctx, knowledge, &types.DocumentProcessPayload{}); err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			return nil, ErrKnowledgeTaskExists
+		}
+		return nil, err
+	}
+	return knowledge, nil
+}
+
+// ReplaceKnowledgeFile stores a file uploaded in place of the file of file knowledge and enqueues its refresh.
+// The document task parses the new file and only embeds the chunks that changed, keeping the replaced version.
+func (s *knowledgeService) ReplaceKnowledgeFile(ctx context.Context,
+	id string, file *multipart.FileHeader, enableMultimodel *bool,
+) (*types.Knowledge, error) {
+	knowledge, err := s.repo.GetKnowledgeByID(ctx, ctx.Value(types.TenantIDContextKey).(uint), id)
+	if err != nil {
+		return nil, err
+	}
+	if knowledge.Type != "file" {
+		return nil, ErrKnowledgeFileNotReplaceable
+	}
+	if knowledge.ParseStatus == "pending" || knowledge.ParseStatus == "processing" {
+		return nil, ErrKnowledgeProcessing
+	}
+	if !isValidFileType(file.Filename) {
+		return nil, ErrInvalidFileType
+	}
+	safeFilename, isValid := secutils.ValidateInput(file.Filename)
+	if !isValid {
+		return nil, werrors.NewValidationError("文件名包含非法字符")
+	}
+	hash, err := calculateFileHash(file)
+	if err != nil {
+		return nil, err
+	}
+	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, knowledge.KnowledgeBaseID)
+	if err != nil {
+		return nil, err
+	}
+	if enableMultimodel == nil {
+		enableMultimodel = &kb.ChunkingConfig.EnableMultimodal
+	}
+
+	filePath, err := s.fileSvc.SaveFile(ctx, file, knowledge.TenantID, knowledge.ID)
+	if err != nil {
+		return nil, err
+	}
+	payload := &types.DocumentProcessPayload{
+		EnableMultimodel: *enableMultimodel,
+		File: &types.KnowledgeFile{
+			FileName: safeFilename,
+			FileType: getFileType(safeFilename),
+			FileSize: file.Size,
+			FileHash: hash,
+			FilePath: filePath,
+		},
+	}
+	if err := s.enqueueRefresh(ctx, knowledge, payload); err != nil {
+		if err := s.fileSvc.DeleteFile(ctx, filePath); err != nil {
+			logger.Warnf(ctx, "Failed to delete uploaded file %s: %v", filePath, err)
+		}
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			return nil, ErrKnowledgeTaskExists
+		}
+		return nil, err
+	}
+	return knowledge, nil
+}
+
+// enqueueRefresh enqueues the refresh of knowledge as its document task
+func (s *knowledgeService) enqueueRefresh(ctx context.Context,
+	knowledge *types.Knowledge, payload *types.DocumentProcessPayload,
+) error {
+	payload.TenantID = knowledge.TenantID
+	payload.KnowledgeID = knowledge.ID
+	payload.Refresh = true
+	if requestID, ok := ctx.Value(types.RequestIDContextKey).(string); ok {
+		payload.RequestID = requestID
+	}
+	return s.enqueueDocumentProcess(ctx, payload)
+}
+
+// refreshKnowledge runs one attempt of a refresh task: URL knowledge is fetched again and file knowledge
+// reads the file uploaded in its place. Knowledge whose last processing did not complete is always reindexed,
+// the chunks stored by earlier attempts are diffed against like those of the indexed version.
+func (s *knowledgeService) refreshKnowledge(ctx context.Context,
+	knowledge *types.Knowledge, payload *types.DocumentProcessPayload,
+) error {
+	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, knowledge.KnowledgeBaseID)
+	if err != nil {
+		return err
+	}
+	// The file is only replaced in the knowledge entry once the refresh is saved
+	replacedFile := knowledge.FilePath
+	var chunks []*proto.Chunk
+	switch {
+	case knowledge.Type == "url":
+		resp, err := s.readURL(ctx, kb, knowledge, knowledge.Source, kb.ChunkingConfig.EnableMultimodal)
+		if err != nil {
+			return err
+		}
+		chunks = resp.Chunks
+		now := time.Now()
+		knowledge.LastSyncedAt = &now
+		knowledge.SyncError = ""
+	case knowledge.Type == "file" && payload.File != nil:
+		knowledge.FileName = payload.File.FileName
+		knowledge.FileType = payload.File.FileType
+		knowledge.FileSize = payload.File.FileSize
+		knowledge.FileHash = payload.File.FileHash
+		knowledge.FilePath = payload.File.FilePath
+		chunks, err = s.readKnowledgeFile(ctx, kb, knowledge, payload.EnableMultimodel)
+		if err != nil {
+			return err
+		}
+	default:
+		return skipRetry(ErrKnowledgeNotRefreshable)
+	}
+	contentHash := versioning.ContentHash(chunks)
+
+	if versioning.Unchanged(knowledge, contentHash) {
+		logger.Info(ctx, "Knowledge content is unchanged, keep the indexed version")
+		if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
+			return err
+		}
+		s.deleteReplacedFile(ctx, knowledge, replacedFile)
+		return nil
+	}
+	if knowledge.ParseStatus == "completed" {
+		// Keep the indexed content as a version that can be rolled back to
+		maxVersion, err := s.versionRepo.GetMaxVersion(ctx, knowledge.TenantID, knowledge.ID)
+		if err != nil {
+			return err
+		}
+		if err := s.archiveKnowledgeVersion(ctx, knowledge); err != nil {
+			return err
+		}
+		knowledge.Version = versioning.Next(knowledge, maxVersion)
+		logger.Infof(ctx, "Knowledge content changed, reindexing as version %d", knowledge.Version)
+	}
+
+	// Unchanged chunks keep their rows and vectors, processChunks only embeds what changed
+	knowledge.ContentHash = contentHash
+	if err := s.beginReindex(ctx, knowledge); err != nil {
+		return err
+	}
+	s.deleteReplacedFile(ctx, knowledge, replacedFile)
+	if err := s.processChunks(ctx, kb, knowledge, chunks); err != nil {
+		return err
+	}
+	s.invalidateAnswerCache(ctx, knowledge.ID)
+	s.pruneKnowledgeVersions(ctx, knowledge)
+	return nil
+}
+
+// deleteReplacedFile deletes the previous file of file knowledge once the knowledge entry refers to a new one
+func (s *knowledgeService) deleteReplacedFile(ctx context.Context, knowledge *types.Knowledge, filePath string) {
+	if filePath == "" || filePath == knowledge.FilePath {
+		return
+	}
+	if err := s.fileSvc.DeleteFile(ctx, filePath); err != nil {
+		logger.Warnf(ctx, "Failed to delete replaced file %s: %v", filePath, err)
+	}
+}
+
+// SyncKnowledge handles a TypeKnowledgeSync task: it enqueues a refresh of the completed URL knowledge
+// in knowledge bases with re-sync enabled whose last fetch is older than the re-sync interval
+func (s *knowledgeService) SyncKnowledge(ctx context.Context, t *asynq.Task) error {
+	kbs, err := s.kbRepo.ListKnowledgeBases(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var enqueued int
+	for _, kb := range kbs {
+		if kb.URLSyncConfig == nil || !kb.URLSyncConfig.Enabled {
+			continue
+		}
+		knowledgeList, err := s.repo.ListKnowledgeByKnowledgeBaseID(ctx, kb.TenantID, kb.ID)
+		if err != nil {
+			return err
+		}
+		for _, knowledge := range knowledgeList {
+			if knowledge.Type != "url" || knowledge.ParseStatus != "completed" {
+				continue
+			}
+			lastSynced := knowledge.CreatedAt
+			if knowledge.LastSyncedAt != nil {
+				lastSynced = *knowledge.LastSyncedAt
+			}
+			if !kb.URLSyncConfig.Due(lastSynced, now) {
+				continue
+			}
+			// A refresh that is still queued or failed permanently is not enqueued again
+			if err := s.enqueueRefresh(ctx, knowledge, &types.DocumentProcessPayload{}); err != nil {
+				if errors.Is(err, asynq.ErrTaskIDConflict) {
+					continue
+				}
+				return err
+			}
+			enqueued++
+		}
+	}
+	logger.Infof(ctx, "URL knowledge re-sync scan finished, refreshes enqueued: %d", enqueued)
+	return nil
+}
+
+// ListKnowledgeVersions lists the earlier content versions of knowledge, newest first
+func (s *knowledgeService) ListKnowledgeVersions(ctx context.Context, id string) ([]*types.KnowledgeVersion, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	knowledge, err := s.repo.GetKnowledgeByID(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.versionRepo.ListVersions(ctx, tenantID, knowledge.ID)
+}
+
+// RollbackKnowledgeVersion enqueues the restore of an earlier version of knowledge as its document task.
+// The active version is kept as a version itself, so a rollback can be undone by another rollback.
+func (s *knowledgeService) RollbackKnowledgeVersion(ctx context.Context,
+	id string, version int,
+) (*types.Knowledge, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint)
+	knowledge, err := s.repo.GetKnowledgeByID(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if knowledge.ParseStatus == "pending" || knowledge.ParseStatus == "processing" {
+		return nil, ErrKnowledgeProcessing
+	}
+	if knowledge.Version == version && knowledge.ParseStatus == "completed" {
+		return nil, ErrKnowledgeVersionActive
+	}
+	if _, err := s.versionRepo.GetVersion(ctx, tenantID, id, version); err != nil {
+		return nil, err
+	}
+	payload := &types.DocumentProcessPayload{
+		TenantID:    knowledge.TenantID,
+		KnowledgeID: knowledge.ID,
+		Rollback:    version,
+	}
+	if requestID, ok := ctx.Value(types.RequestIDContextKey).(string); ok {
+		payload.RequestID = requestID
+	}
+	if err := s.enqueueDocumentProcess(ctx, payload); err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			return nil, ErrKnowledgeTaskExists
+		}
+		return nil, err
+	}
+	return knowledge, nil
+}
+
+// rollbackKnowledge runs one attempt of a rollback task: the chunks of the version are stored in place
+// of the active chunks, unchanged chunks keep their rows and vectors and only the others are embedded.
+func (s *knowledgeService) rollbackKnowledge(ctx context.Context, knowledge *types.Knowledge, version int) error {
+	if knowledge.Version == version && knowledge.ParseStatus == "completed" {
+		logger.Infof(ctx, "Knowledge version %d is already active, skip rollback", version)
+		return nil
+	}
+	target, err := s.versionRepo.GetVersion(ctx, knowledge.TenantID, knowledge.ID, version)
+	if err != nil {
+		if errors.Is(err, repository.ErrKnowledgeVersionNotFound) {
+			return skipRetry(err)
+		}
+		return err
+	}
+	chunks, err := versioning.Restore(target)
+	if err != nil {
+		return skipRetry(err)
+	}
+	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, knowledge.KnowledgeBaseID)
+	if err != nil {
+		return err
+	}
+	embeddingModel, err := s.modelService.GetEmbeddingModel(ctx, kb.EmbeddingModelID)
+	if err != nil {
+		return err
+	}
+
+	// A retry after the reindex began finds the knowledge processing, its version was kept by the first attempt
+	if knowledge.ParseStatus == "completed" {
+		if err := s.archiveKnowledgeVersion(ctx, knowledge); err != nil {
+			return err
+		}
+	}
+	knowledge.Version = target.Version
+	knowledge.ContentHash = target.ContentHash
+	knowledge.Description = target.Description
+	if err := s.beginReindex(ctx, knowledge); err != nil {
+		return err
+	}
+	if err := s.storeChunks(ctx, knowledge, embeddingModel, chunks); err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		if chunk.ChunkType != types.ChunkTypeText || chunk.Metadata.GetDuplicateOf() != "" {
+			continue
+		}
+		if err := NewChunkExtractTask(ctx, s.task, chunk.TenantID, chunk.ID, kb.SummaryModelID); err != nil {
+			logger.Errorf(ctx, "Failed to create chunk extract task: %v", err)
+		}
+	}
+
+	// The restored version is active now, it is kept again once it is replaced
+	if err := s.versionRepo.DeleteVersion(ctx, knowledge.TenantID, knowledge.ID, version); err != nil {
+		logger.Errorf(ctx, "Failed to delete restored knowledge version %d: %v", version, err)
+	}
+	s.invalidateAnswerCache(ctx, knowledge.ID)
+	s.pruneKnowledgeVersions(ctx, knowledge)
+	logger.Infof(ctx, "Knowledge rolled back to version %d, chunks: %d", version, len(chunks))
+	return nil
+}
+
+// archiveKnowledgeVersion keeps the chunks of the active version of knowledge
+func (s *knowledgeService) archiveKnowledgeVersion(ctx context.Context, knowledge *types.Knowledge) error {
+	chunks, err := s.chunkRepo.ListChunksByKnowledgeIDAndType(
+		ctx, knowledge.TenantID, knowledge.ID, versionedChunkTypes,
+	)
+	if err != nil {
+		return err
+	}
+	version, err := versioning.Snapshot(knowledge, chunks)
+	if err != nil {
+		return err
+	}
+	// An earlier attempt may have kept the version before failing
+	if err := s.versionRepo.DeleteVersion(ctx, knowledge.TenantID, knowledge.ID, knowledge.Version); err != nil {
+		return err
+	}
+	return s.versionRepo.CreateVersion(ctx, version)
+}
+
+// beginReindex marks knowledge as processing before its content is processed again.
+// The knowledge graph is removed, it is extracted again from the new chunks.
+func (s *knowledgeService) beginReindex(ctx context.Context, knowledge *types.Knowledge) error {
+	knowledge.ParseStatus = "processing"
+	knowledge.ErrorMessage = ""
+	knowledge.UpdatedAt = time.Now()
+	if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
+		return err
+	}
+	namespace := types.NameSpace{KnowledgeBase: knowledge.KnowledgeBaseID, Knowledge: knowledge.ID}
+	return s.graphEngine.DelGraph(ctx, []types.NameSpace{namespace})
+}
+
+// pruneKnowledgeVersions drops the versions of knowledge beyond the configured number
+func (s *knowledgeService) pruneKnowledgeVersions(ctx context.Context, knowledge *types.Knowledge) {
+	if s.ingestion.KnowledgeVersions <= 0 {
+		return
+	}
+	versions, err := s.versionRepo.ListVersions(ctx, knowledge.TenantID, knowledge.ID)
+	if err != nil {
+		logger.Warnf(ctx, "Failed to list knowledge versions: %v", err)
+		return
+	}
+	if err := s.versionRepo.DeleteVersions(
+		ctx, knowledge.TenantID, knowledge.ID, versioning.Expired(versions, s.ingestion.KnowledgeVersions),
+	); err != nil {
+		logger.Warnf(ctx, "Failed to prune knowledge versions: %v", err)
+	}
+}
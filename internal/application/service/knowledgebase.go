@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"github.com/Tencent/WeKnora/internal/application/service/retriever"
-	"github.com/Tencent/WeKnora/internal/common"
 	"github.com/Tencent/WeKnora/internal/logger"
 	"github.com/Tencent/WeKnora/internal/models/embedding"
 	"github.com/Tencent/WeKnora/internal/types"
@@ -55,6 +54,14 @@ func (s *knowledgeBaseService) CreateKnowledgeBase(ctx context.Context,
 
 	logger.Infof(ctx, "Creating knowledge base, ID: %s, tenant ID: %d, name: %s", kb.ID, kb.TenantID, kb.Name)
 
+	// Inline pipeline definitions are validated here, references are resolved when chatting
+	if kb.Pipeline != nil && !kb.Pipeline.IsReference() {
+		if err := kb.Pipeline.Validate(); err != nil {
+			logger.Errorf(ctx, "Invalid knowledge base pipeline: %v", err)
+			return nil, err
+		}
+	}
+
 	if err := s.repo.CreateKnowledgeBase(ctx, kb); err != nil {
 		logger.ErrorWithFields(ctx, err, map[string]interface{}{
 			"knowledge_base_id": kb.ID,
@@ -124,6 +131,13 @@ func (s *knowledgeBaseService) UpdateKnowledgeBase(ctx context.Context,
 
 	logger.Infof(ctx, "Updating knowledge base, ID: %s, name: %s", id, name)
 
+	if config.Pipeline != nil && !config.Pipeline.IsReference() {
+		if err := config.Pipeline.Validate(); err != nil {
+			logger.Errorf(ctx, "Invalid knowledge base pipeline: %v", err)
+			return nil, err
+		}
+	}
+
 	// Get existing knowledge base
 	kb, err := s.repo.GetKnowledgeBaseByID(ctx, id)
 	if err != nil {
@@ -138,6 +152,9 @@ func (s *knowledgeBaseService) UpdateKnowledgeBase(ctx context.Context,
 	kb.Description = description
 	kb.ChunkingConfig = config.ChunkingConfig
 	kb.ImageProcessingConfig = config.ImageProcessingConfig
+	kb.Pipeline = config.Pipeline
+	kb.URLSyncConfig = config.URLSyncConfig
+	kb.DedupConfig = config.DedupConfig
 	kb.UpdatedAt = time.Now()
 
 	logger.Info(ctx, "Saving knowledge base update")
@@ -264,6 +281,19 @@ func (s *knowledgeBaseService) HybridSearch(ctx context.Context,
 ) ([]*types.SearchResult, error) {
 	logger.Infof(ctx, "Hybrid search parameters, knowledge base ID: %s, query text: %s", id, params.QueryText)
 
+	if err := params.Fusion.Validate(); err != nil {
+		logger.Errorf(ctx, "Invalid fusion config: %v", err)
+		return nil, err
+	}
+	if err := params.Filters.Validate(); err != nil {
+		logger.Errorf(ctx, "Invalid metadata filters: %v", err)
+		return nil, err
+	}
+	if err := types.AccessLabels(params.Audience).Validate(); err != nil {
+		logger.Errorf(ctx, "Invalid audience: %v", err)
+		return nil, err
+	}
+
 	tenantInfo := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
 	logger.Infof(ctx, "Creating composite retrieval engine, tenant ID: %d", tenantInfo.ID)
 
@@ -300,7 +330,7 @@ func (s *knowledgeBaseService) HybridSearch(ctx context.Context,
 
 		// Generate embedding vector for the query text
 		logger.Info(ctx, "Starting to generate query embedding")
-		queryEmbedding, err := embeddingModel.Embed(ctx, params.QueryText)
+		queryEmbedding, err := embedding.EmbedQuery(ctx, embeddingModel, params.QueryText)
 		if err != nil {
 			logger.Errorf(ctx, "Failed to embed query text, query text: %s, error: %v", params.QueryText, err)
 			return nil, err
@@ -313,6 +343,8 @@ func (s *knowledgeBaseService) HybridSearch(ctx context.Context,
 			KnowledgeBaseIDs: []string{id},
 			TopK:             params.MatchCount,
 			Threshold:        params.VectorThreshold,
+			Filters:          params.Filters,
+			Audience:         params.Audience,
 			RetrieverType:    types.VectorRetrieverType,
 		})
 		logger.Info(ctx, "Vector retrieval parameters setup completed")
@@ -326,6 +358,8 @@ func (s *knowledgeBaseService) HybridSearch(ctx context.Context,
 			KnowledgeBaseIDs: []string{id},
 			TopK:             params.MatchCount,
 			Threshold:        params.KeywordThreshold,
+			Filters:          params.Filters,
+			Audience:         params.Audience,
 			RetrieverType:    types.KeywordsRetrieverType,
 		})
 		logger.Info(ctx, "Keyword retrieval parameters setup completed")
@@ -347,35 +381,36 @@ func (s *knowledgeBaseService) HybridSearch(ctx context.Context,
 		return nil, err
 	}
 
-	// Collect all results from different retrievers and deduplicate by chunk ID
+	// Log results from different retrievers
 	logger.Infof(ctx, "Processing retrieval results")
-	matchResults := []*types.IndexWithScore{}
+	resultCount := 0
 	for _, retrieveResult := range retrieveResults {
 		logger.Infof(ctx, "Retrieval results, engine: %v, retriever: %v, count: %v",
 			retrieveResult.RetrieverEngineType,
 			retrieveResult.RetrieverType,
 			len(retrieveResult.Results),
 		)
-		matchResults = append(matchResults, retrieveResult.Results...)
+		resultCount += len(retrieveResult.Results)
 	}
 
 	// Early return if no results
-	if len(matchResults) == 0 {
+	if resultCount == 0 {
 		logger.Info(ctx, "No search results found")
 		return nil, nil
 	}
 
-	// Deduplicate results by chunk ID
-	logger.Infof(ctx, "Result count before deduplication: %d", len(matchResults))
-	deduplicatedChunks := common.Deduplicate(func(r *types.IndexWithScore) string { return r.ChunkID }, matchResults...)
-	logger.Infof(ctx, "Result count after deduplication: %d", len(deduplicatedChunks))
+	// Fuse results of different retrievers and deduplicate by chunk ID
+	logger.Infof(ctx, "Result count before fusion: %d, fusion method: %s", resultCount, params.Fusion.GetMethod())
+	deduplicatedChunks := retriever.FuseRetrieveResults(retrieveResults, params.Fusion)
+	logger.Infof(ctx, "Result count after fusion: %d", len(deduplicatedChunks))
 
-	return s.processSearchResults(ctx, deduplicatedChunks)
+	return s.processSearchResults(ctx, deduplicatedChunks, params.Audience)
 }
 
-// processSearchResults handles the processing of search results, optimizing database queries
+// processSearchResults handles the processing of search results, optimizing database queries.
+// Chunks of knowledge the audience may not retrieve are dropped, even if an engine returned them.
 func (s *knowledgeBaseService) processSearchResults(ctx context.Context,
-	chunks []*types.IndexWithScore,
+	chunks []*types.IndexWithScore, audience []string,
 ) ([]*types.SearchResult, error) {
 	if len(chunks) == 0 {
 		return nil, nil
@@ -408,6 +443,12 @@ func (s *knowledgeBaseService) processSearchResults(ctx context.Context,
 	if err != nil {
 		return nil, err
 	}
+	for knowledgeID, knowledge := range knowledgeMap {
+		if !knowledge.AccessLabels.Allows(audience) {
+			logger.Warnf(ctx, "Dropping results of knowledge %s restricted from the audience", knowledgeID)
+			delete(knowledgeMap, knowledgeID)
+		}
+	}
 
 	// Batch fetch all chunks in one go
 	logger.Infof(ctx, "Fetching chunk data for %d IDs", len(chunkIDs))
@@ -434,11 +475,12 @@ func (s *knowledgeBaseService) processSearchResults(ctx context.Context,
 		if chunk.ParentChunkID != "" && !processedChunkIDs[chunk.ParentChunkID] {
 			additionalChunkIDs = append(additionalChunkIDs, chunk.ParentChunkID)
 			processedChunkIDs[chunk.ParentChunkID] = true
-
-			// Pass score to parent
-			chunkScores[chunk.ParentChunkID] = chunkScores[chunk.ID]
 			chunkMatchTypes[chunk.ParentChunkID] = types.MatchTypeParentChunk
 		}
+		// Pass score to parent, a parent hit by several child chunks keeps the best score
+		if chunk.ParentChunkID != "" && chunkScores[chunk.ID] > chunkScores[chunk.ParentChunkID] {
+			chunkScores[chunk.ParentChunkID] = chunkScores[chunk.ID]
+		}
 
 		// Collect related chunks
 		relationChunkIDs := s.collectRelatedChunkIDs(chunk, processedChunkIDs)
@@ -532,6 +574,7 @@ func (s *knowledgeBaseService) buildSearchResult(chunk *types.Chunk,
 		ID:                chunk.ID,
 		Content:           chunk.Content,
 		KnowledgeID:       chunk.KnowledgeID,
+		KnowledgeBaseID:   chunk.KnowledgeBaseID,
 		ChunkIndex:        chunk.ChunkIndex,
 		KnowledgeTitle:    knowledge.Title,
 		StartAt:           chunk.StartAt,
@@ -543,6 +586,8 @@ func (s *knowledgeBaseService) buildSearchResult(chunk *types.Chunk,
 		ChunkType:         string(chunk.ChunkType),
 		ParentChunkID:     chunk.ParentChunkID,
 		ImageInfo:         chunk.ImageInfo,
+		SectionPath:       chunk.Metadata.GetSectionPath(),
+		FAQ:               chunk.Metadata.GetFAQ(),
 		KnowledgeFilename: knowledge.FileName,
 		KnowledgeSource:   knowledge.Source,
 	}
@@ -551,7 +596,7 @@ func (s *knowledgeBaseService) buildSearchResult(chunk *types.Chunk,
 // isValidTextChunk checks if a chunk is a valid text chunk
 func (s *knowledgeBaseService) isValidTextChunk(chunk *types.Chunk) bool {
 	return slices.Contains([]types.ChunkType{
-		types.ChunkTypeText, types.ChunkTypeSummary,
+		types.ChunkTypeText, types.ChunkTypeSummary, types.ChunkTypeFAQ,
 	}, chunk.ChunkType)
 }
 
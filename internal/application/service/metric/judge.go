@@ -0,0 +1,203 @@
+package metric
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// Judge metrics, scored by a chat model. Their names are the JSON names of the generation metrics.
+const (
+	// JudgeFaithfulness is the share of the statements of the answer that the retrieved chunks support
+	JudgeFaithfulness = "faithfulness"
+	// JudgeAnswerRelevance is how directly the answer addresses the question, whether it is right or not
+	JudgeAnswerRelevance = "answer_relevance"
+	// JudgeContextPrecision is the average precision of the retrieved chunks the judge finds useful
+	JudgeContextPrecision = "context_precision"
+	// JudgeContextRecall is the share of the statements of the reference answer that the retrieved chunks support
+	JudgeContextRecall = "context_recall"
+)
+
+var (
+	// statementScore matches the "statement | score" lines of the faithfulness and context recall responses
+	statementScore = regexp.MustCompile(`(?m)[|｜]\s*(\d+(?:\.\d+)?)\s*$`)
+	// contextScore matches the "n: score" lines of the context precision responses
+	contextScore = regexp.MustCompile(`(?m)^\s*\[?(\d+)\]?\s*[:：]\s*(\d+(?:\.\d+)?)\s*$`)
+	// relevanceScore matches the "分数: score" line of the answer relevance responses
+	relevanceScore = regexp.MustCompile(`(?i)(?:分数|score)\s*[:：]\s*(\d+(?:\.\d+)?)`)
+)
+
+// JudgeMetric asks a chat model to score a question, every computation is recorded
+// in the judgements of the metric input with its prompt and the raw response
+type JudgeMetric struct {
+	name    string
+	model   chat.Chat
+	prompt  string
+	timeout time.Duration
+}
+
+// NewJudgeMetric creates a judge metric, prompt is the system prompt telling the model how to score and
+// timeout bounds a single scoring, 0 for no limit
+func NewJudgeMetric(name string, model chat.Chat, prompt string, timeout time.Duration) *JudgeMetric {
+	return &JudgeMetric{name: name, model: model, prompt: prompt, timeout: timeout}
+}
+
+// Compute scores the input with the chat model like ComputeContext, in a context without cancellation
+func (j *JudgeMetric) Compute(metricInput *types.MetricInput) float64 {
+	return j.ComputeContext(context.Background(), metricInput)
+}
+
+// ComputeContext scores the input with the chat model, the scoring stops when ctx is canceled.
+// It scores 0 if the input lacks what the metric needs or the model fails, the reason is then the error
+// of the judgement and the score is left out of the averages.
+func (j *JudgeMetric) ComputeContext(ctx context.Context, metricInput *types.MetricInput) float64 {
+	judgement := &types.Judgement{Metric: j.name, Prompt: j.prompt}
+	metricInput.Judgements = append(metricInput.Judgements, judgement)
+
+	input, err := judgeInput(j.name, metricInput)
+	if err != nil {
+		judgement.Error = err.Error()
+		return 0
+	}
+	judgement.Input = input
+
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+	thinking := false
+	resp, err := j.model.Chat(ctx, []chat.Message{
+		{Role: "system", Content: j.prompt},
+		{Role: "user", Content: input},
+	}, &chat.ChatOptions{
+		Temperature: 0,
+		MaxTokens:   1024,
+		Thinking:    &thinking,
+	})
+	if err != nil {
+		judgement.Error = err.Error()
+		return 0
+	}
+	judgement.Response = resp.Content
+
+	score, err := parseJudgement(j.name, resp.Content, len(metricInput.Contexts))
+	if err != nil {
+		judgement.Error = err.Error()
+		return 0
+	}
+	judgement.Score = score
+	return score
+}
+
+// judgeInput builds the user message of a judge metric from the parts of the input it needs
+func judgeInput(name string, metricInput *types.MetricInput) (string, error) {
+	answer := strings.TrimSpace(metricInput.GeneratedTexts)
+	reference := strings.TrimSpace(metricInput.GeneratedGT)
+	var b strings.Builder
+	fmt.Fprintf(&b, "问题：%s\n\n", strings.TrimSpace(metricInput.Question))
+	switch name {
+	case JudgeFaithfulness:
+		if answer == "" {
+			return "", errors.New("no generated answer")
+		}
+		if len(metricInput.Contexts) == 0 {
+			return "", errors.New("no retrieved chunks")
+		}
+		writeContexts(&b, metricInput.Contexts)
+		fmt.Fprintf(&b, "回答：%s", answer)
+	case JudgeAnswerRelevance:
+		if answer == "" {
+			return "", errors.New("no generated answer")
+		}
+		fmt.Fprintf(&b, "回答：%s", answer)
+	case JudgeContextPrecision, JudgeContextRecall:
+		if reference == "" {
+			return "", errors.New("no reference answer")
+		}
+		if len(metricInput.Contexts) == 0 {
+			return "", errors.New("no retrieved chunks")
+		}
+		fmt.Fprintf(&b, "参考答案：%s\n\n", reference)
+		writeContexts(&b, metricInput.Contexts)
+	default:
+		return "", fmt.Errorf("unknown judge metric %s", name)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// writeContexts writes the retrieved chunks numbered from 1 in rank order
+func writeContexts(b *strings.Builder, contexts []string) {
+	b.WriteString("检索结果：\n")
+	for i, content := range contexts {
+		fmt.Fprintf(b, "[%d] %s\n", i+1, strings.TrimSpace(content))
+	}
+	b.WriteString("\n")
+}
+
+// parseJudgement parses the score of a judge metric from the response of the model, scores are clamped to [0, 1].
+// Faithfulness and context recall average the scores of the statements, context precision is the average
+// precision at the ranks of the useful chunks, chunks without a score count as not useful.
+func parseJudgement(name string, response string, contexts int) (float64, error) {
+	switch name {
+	case JudgeFaithfulness, JudgeContextRecall:
+		matches := statementScore.FindAllStringSubmatch(response, -1)
+		if len(matches) == 0 {
+			return 0, errors.New("no statement scores in response")
+		}
+		total := 0.0
+		for _, m := range matches {
+			total += parseScore(m[1])
+		}
+		return total / float64(len(matches)), nil
+	case JudgeAnswerRelevance:
+		m := relevanceScore.FindStringSubmatch(response)
+		if m == nil {
+			return 0, errors.New("no score in response")
+		}
+		return parseScore(m[1]), nil
+	case JudgeContextPrecision:
+		useful := make([]float64, contexts)
+		scored := false
+		for _, m := range contextScore.FindAllStringSubmatch(response, -1) {
+			n, err := strconv.Atoi(m[1])
+			if err != nil || n < 1 || n > contexts {
+				continue
+			}
+			useful[n-1] = parseScore(m[2])
+			scored = true
+		}
+		if !scored {
+			return 0, errors.New("no chunk scores in response")
+		}
+		hits, precision, relevant := 0.0, 0.0, 0.0
+		for i, v := range useful {
+			hits += v
+			precision += v * hits / float64(i+1)
+			relevant += v
+		}
+		if relevant == 0 {
+			return 0, nil
+		}
+		return precision / relevant, nil
+	}
+	return 0, fmt.Errorf("unknown judge metric %s", name)
+}
+
+func parseScore(s string) float64 {
+	score, err := strconv.ParseFloat(s, 64)
+	if err != nil || score < 0 {
+		return 0
+	}
+	if score > 1 {
+		return 1
+	}
+	return score
+}
@@ -0,0 +1,158 @@
+package metric
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// fakeJudge answers every scoring with a fixed response
+type fakeJudge struct {
+	response string
+	err      error
+	input    string
+}
+
+func (f *fakeJudge) Chat(ctx context.Context, messages []chat.Message, opts *chat.ChatOptions) (*types.ChatResponse, error) {
+	f.input = messages[len(messages)-1].Content
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &types.ChatResponse{Content: f.response}, nil
+}
+
+func (f *fakeJudge) ChatStream(ctx context.Context,
+	messages []chat.Message, opts *chat.ChatOptions,
+) (<-chan types.StreamResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeJudge) GetModelName() string { return "judge" }
+
+func (f *fakeJudge) GetModelID() string { return "judge" }
+
+func TestJudgeMetric_Compute(t *testing.T) {
+	input := func() *types.MetricInput {
+		return &types.MetricInput{
+			Question:       "年假有多少天？",
+			GeneratedTexts: "入职满一年后每年有 10 天年假，可以跨年使用。",
+			GeneratedGT:    "入职满一年后每年 10 天。",
+			Contexts:       []string{"报销流程说明", "员工入职满一年后每年享有 10 天带薪年假", "年假不可跨年"},
+		}
+	}
+	tests := []struct {
+		name      string
+		metric    string
+		input     *types.MetricInput
+		response  string
+		err       error
+		canceled  bool
+		expected  float64
+		wantError bool
+		wantInput []string
+	}{
+		{
+			name:      "faithfulness averages the statements",
+			metric:    JudgeFaithfulness,
+			input:     input(),
+			response:  "入职满一年后每年有 10 天年假 | 1\n年假可以跨年使用 | 0",
+			expected:  0.5,
+			wantInput: []string{"[2] 员工入职满一年后每年享有 10 天带薪年假", "回答：入职满一年"},
+		},
+		{
+			name:      "answer relevance",
+			metric:    JudgeAnswerRelevance,
+			input:     input(),
+			response:  "分数: 0.8",
+			expected:  0.8,
+			wantInput: []string{"问题：年假有多少天？"},
+		},
+		{
+			name:     "context precision is the average precision of the useful chunks",
+			metric:   JudgeContextPrecision,
+			input:    input(),
+			response: "1: 0\n2: 1\n3: 1",
+			// (1/2 + 2/3) / 2
+			expected:  0.5833333333333333,
+			wantInput: []string{"参考答案：入职满一年后每年 10 天。", "[1] 报销流程说明"},
+		},
+		{
+			name:     "context recall",
+			metric:   JudgeContextRecall,
+			input:    input(),
+			response: "入职满一年后每年 10 天 | 1",
+			expected: 1,
+		},
+		{
+			name:     "scores above 1 are clamped",
+			metric:   JudgeAnswerRelevance,
+			input:    input(),
+			response: "score: 5",
+			expected: 1,
+		},
+		{
+			name:      "unparsable response",
+			metric:    JudgeFaithfulness,
+			input:     input(),
+			response:  "回答完全正确",
+			wantError: true,
+		},
+		{
+			name:      "model failure",
+			metric:    JudgeAnswerRelevance,
+			input:     input(),
+			err:       errors.New("timeout"),
+			wantError: true,
+		},
+		{
+			name:      "canceled task",
+			metric:    JudgeAnswerRelevance,
+			input:     input(),
+			canceled:  true,
+			wantError: true,
+		},
+		{
+			name:      "no retrieved chunks",
+			metric:    JudgeContextRecall,
+			input:     &types.MetricInput{Question: "年假有多少天？", GeneratedGT: "10 天"},
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			judge := &fakeJudge{response: tt.response, err: tt.err}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.canceled {
+				cancel()
+			}
+			score := NewJudgeMetric(tt.metric, judge, "prompt", 0).ComputeContext(ctx, tt.input)
+			if math.Abs(score-tt.expected) > 1e-9 {
+				t.Errorf("Compute() = %v, want %v", score, tt.expected)
+			}
+			if len(tt.input.Judgements) != 1 {
+				t.Fatalf("got %d judgements, want 1", len(tt.input.Judgements))
+			}
+			judgement := tt.input.Judgements[0]
+			if judgement.Metric != tt.metric || judgement.Prompt != "prompt" || judgement.Response != tt.response {
+				t.Errorf("judgement = %+v", judgement)
+			}
+			if (judgement.Error != "") != tt.wantError {
+				t.Errorf("judgement error = %q, want error %v", judgement.Error, tt.wantError)
+			}
+			for _, part := range tt.wantInput {
+				if !strings.Contains(judge.input, part) || !strings.Contains(judgement.Input, part) {
+					t.Errorf("judge input %q does not contain %q", judge.input, part)
+				}
+			}
+		})
+	}
+}
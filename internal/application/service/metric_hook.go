@@ -2,10 +2,14 @@ package service
 
 import (
 	"context"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/Tencent/WeKnora/internal/application/service/metric"
+	"github.com/Tencent/WeKnora/internal/config"
 	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/chat"
 	"github.com/Tencent/WeKnora/internal/types"
 	"github.com/Tencent/WeKnora/internal/types/interfaces"
 )
@@ -13,70 +17,146 @@ import (
 // MetricList stores and aggregates metric results
 type MetricList struct {
 	results []*types.MetricResult
+	// judges are the judge metric calculators by name, judge metrics without one are not computed
+	judges map[string]*metric.JudgeMetric
+	// retrievalOnly skips the generation metrics, for results without generated answers
+	retrievalOnly bool
 }
 
-// metricCalculators defines all metrics to be calculated
-var metricCalculators = []struct {
-	calc     interfaces.Metrics                 // Metric calculator implementation
+// metricCalculator computes a metric into its field of the result
+type metricCalculator struct {
+	name     string                             // Metric name, the JSON name of its field
+	calc     interfaces.Metrics                 // Metric calculator implementation, nil for a judge metric
 	getField func(*types.MetricResult) *float64 // Field accessor for result
-}{
-	// Retrieval Metrics
-	{metric.NewPrecisionMetric(), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.Precision }},
-	{metric.NewRecallMetric(), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.Recall }},
-	{metric.NewNDCGMetric(3), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.NDCG3 }},
-	{metric.NewNDCGMetric(10), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.NDCG10 }},
-	{metric.NewMRRMetric(), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.MRR }},
-	{metric.NewMAPMetric(), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.MAP }},
-
-	// Generation Metrics
-	{metric.NewBLEUMetric(true, metric.BLEU1Gram), func(r *types.MetricResult) *float64 {
+}
+
+// retrievalMetricCalculators defines the metrics of the retrieved chunks
+var retrievalMetricCalculators = []metricCalculator{
+	{"precision", metric.NewPrecisionMetric(), func(r *types.MetricResult) *float64 {
+		return &r.RetrievalMetrics.Precision
+	}},
+	{"recall", metric.NewRecallMetric(), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.Recall }},
+	{"ndcg3", metric.NewNDCGMetric(3), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.NDCG3 }},
+	{"ndcg10", metric.NewNDCGMetric(10), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.NDCG10 }},
+	{"mrr", metric.NewMRRMetric(), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.MRR }},
+	{"map", metric.NewMAPMetric(), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.MAP }},
+}
+
+// generationMetricCalculators defines the metrics of the generated answer
+var generationMetricCalculators = []metricCalculator{
+	{"bleu1", metric.NewBLEUMetric(true, metric.BLEU1Gram), func(r *types.MetricResult) *float64 {
 		return &r.GenerationMetrics.BLEU1
 	}},
-	{metric.NewBLEUMetric(true, metric.BLEU2Gram), func(r *types.MetricResult) *float64 {
+	{"bleu2", metric.NewBLEUMetric(true, metric.BLEU2Gram), func(r *types.MetricResult) *float64 {
 		return &r.GenerationMetrics.BLEU2
 	}},
-	{metric.NewBLEUMetric(true, metric.BLEU4Gram), func(r *types.MetricResult) *float64 {
+	{"bleu4", metric.NewBLEUMetric(true, metric.BLEU4Gram), func(r *types.MetricResult) *float64 {
 		return &r.GenerationMetrics.BLEU4
 	}},
-	{metric.NewRougeMetric(true, "rouge-1", "f"), func(r *types.MetricResult) *float64 {
+	{"rouge1", metric.NewRougeMetric(true, "rouge-1", "f"), func(r *types.MetricResult) *float64 {
 		return &r.GenerationMetrics.ROUGE1
 	}},
-	{metric.NewRougeMetric(true, "rouge-2", "f"), func(r *types.MetricResult) *float64 {
+	{"rouge2", metric.NewRougeMetric(true, "rouge-2", "f"), func(r *types.MetricResult) *float64 {
 		return &r.GenerationMetrics.ROUGE2
 	}},
-	{metric.NewRougeMetric(true, "rouge-l", "f"), func(r *types.MetricResult) *float64 {
+	{"rougel", metric.NewRougeMetric(true, "rouge-l", "f"), func(r *types.MetricResult) *float64 {
 		return &r.GenerationMetrics.ROUGEL
 	}},
+
+	// Judge Metrics, computed by the judge model of the task
+	{metric.JudgeFaithfulness, nil, func(r *types.MetricResult) *float64 {
+		return &r.GenerationMetrics.Faithfulness
+	}},
+	{metric.JudgeAnswerRelevance, nil, func(r *types.MetricResult) *float64 {
+		return &r.GenerationMetrics.AnswerRelevance
+	}},
+	{metric.JudgeContextPrecision, nil, func(r *types.MetricResult) *float64 {
+		return &r.GenerationMetrics.ContextPrecision
+	}},
+	{metric.JudgeContextRecall, nil, func(r *types.MetricResult) *float64 {
+		return &r.GenerationMetrics.ContextRecall
+	}},
 }
 
-// Append calculates and stores metrics for given input
-func (m *MetricList) Append(metricInput *types.MetricInput) {
+// metricCalculators defines all metrics to be calculated
+var metricCalculators = slices.Concat(retrievalMetricCalculators, generationMetricCalculators)
+
+// newJudgeMetrics creates the judge metric calculators scored by the given chat model
+func newJudgeMetrics(model chat.Chat, cfg *config.JudgeConfig) map[string]*metric.JudgeMetric {
+	return map[string]*metric.JudgeMetric{
+		metric.JudgeFaithfulness: metric.NewJudgeMetric(
+			metric.JudgeFaithfulness, model, cfg.FaithfulnessPrompt, cfg.Timeout),
+		metric.JudgeAnswerRelevance: metric.NewJudgeMetric(
+			metric.JudgeAnswerRelevance, model, cfg.AnswerRelevancePrompt, cfg.Timeout),
+		metric.JudgeContextPrecision: metric.NewJudgeMetric(
+			metric.JudgeContextPrecision, model, cfg.ContextPrecisionPrompt, cfg.Timeout),
+		metric.JudgeContextRecall: metric.NewJudgeMetric(
+			metric.JudgeContextRecall, model, cfg.ContextRecallPrompt, cfg.Timeout),
+	}
+}
+
+// Compute calculates the metrics of the given input, the judge metrics only if the list has judges
+// and only the retrieval metrics if the list is retrieval only. The judges stop scoring when ctx is canceled.
+// It does not store the result, judge metrics call a chat model and are computed concurrently.
+func (m *MetricList) Compute(ctx context.Context, metricInput *types.MetricInput) *types.MetricResult {
 	result := &types.MetricResult{}
+	calculators := metricCalculators
+	if m.retrievalOnly {
+		calculators = retrievalMetricCalculators
+	}
 	// Calculate all configured metrics
-	for _, c := range metricCalculators {
-		score := c.calc.Compute(metricInput)
-		*c.getField(result) = score
+	for _, c := range calculators {
+		if c.calc != nil {
+			*c.getField(result) = c.calc.Compute(metricInput)
+			continue
+		}
+		judge := m.judges[c.name]
+		if judge == nil {
+			continue
+		}
+		*c.getField(result) = judge.ComputeContext(ctx, metricInput)
+		if judgement := metricInput.Judgements[len(metricInput.Judgements)-1]; judgement.Error != "" {
+			if result.GenerationMetrics.JudgeFailures == nil {
+				result.GenerationMetrics.JudgeFailures = make(map[string]int)
+			}
+			result.GenerationMetrics.JudgeFailures[c.name]++
+		}
 	}
-	logger.Infof(context.Background(), "metric: %v", result)
+	logger.Infof(ctx, "metric: %v", result)
+	return result
+}
+
+// Append stores the metrics of an input
+func (m *MetricList) Append(result *types.MetricResult) {
 	m.results = append(m.results, result)
 }
 
-// Avg calculates average of all stored metric results
+// Avg calculates average of all stored metric results. The failed judgements are left out of the averages
+// of their judge metrics and counted in the judge failures of the average.
 func (m *MetricList) Avg() *types.MetricResult {
-	if len(m.results) == 0 {
-		return &types.MetricResult{}
-	}
-
 	avgResult := &types.MetricResult{}
-	count := float64(len(m.results))
+	for _, r := range m.results {
+		for name, failures := range r.GenerationMetrics.JudgeFailures {
+			if avgResult.GenerationMetrics.JudgeFailures == nil {
+				avgResult.GenerationMetrics.JudgeFailures = make(map[string]int)
+			}
+			avgResult.GenerationMetrics.JudgeFailures[name] += failures
+		}
+	}
 
 	// Calculate average for each metric
 	for _, config := range metricCalculators {
-		sum := 0.0
+		sum, count := 0.0, 0
 		for _, r := range m.results {
+			if r.GenerationMetrics.JudgeFailures[config.name] > 0 {
+				continue
+			}
 			sum += *config.getField(r)
+			count++
+		}
+		if count > 0 {
+			*config.getField(avgResult) = sum / float64(count)
 		}
-		*config.getField(avgResult) = sum / count
 	}
 	return avgResult
 }
@@ -87,6 +167,9 @@ type HookMetric struct {
 	qaPairMetricList []*qaPairMetric // Per-QA pair metrics
 	metricResults    *MetricList     // Aggregated results
 	mu               *sync.RWMutex   // Thread safety
+	// Whether the questions are evaluated against an existing knowledge base, their gold chunks, knowledge
+	// or passage contents are matched instead of the indices of the ingested passages
+	existingKB bool
 }
 
 // qaPairMetric stores metrics for a single QA pair
@@ -131,12 +214,21 @@ func (h *HookMetric) recordChatResponse(index int, chatResponse *types.ChatRespo
 	h.qaPairMetricList[index].chatResponse = chatResponse
 }
 
-// recordFinish finalizes metrics for a QA pair
-func (h *HookMetric) recordFinish(index int) {
+// recordFinish finalizes metrics for a QA pair and returns its result, the judges stop scoring when ctx is canceled
+func (h *HookMetric) recordFinish(ctx context.Context, index int) *types.EvaluationQuestion {
 	// Prepare retrieval IDs from rerank results
-	retrievalIDs := make([]int, len(h.qaPairMetricList[index].rerankResult))
-	for i, r := range h.qaPairMetricList[index].rerankResult {
+	qaPair := h.qaPairMetricList[index].qaPair
+	rerankResult := h.qaPairMetricList[index].rerankResult
+	retrievalGT, retrievalIDs := qaPair.PIDs, make([]int, len(rerankResult))
+	chunkIDs := make(types.StringArray, len(rerankResult))
+	contexts := make([]string, len(rerankResult))
+	for i, r := range rerankResult {
 		retrievalIDs[i] = r.ChunkIndex
+		chunkIDs[i] = r.ID
+		contexts[i] = r.Content
+	}
+	if h.existingKB {
+		retrievalGT, retrievalIDs = matchGold(qaPair, rerankResult)
 	}
 
 	// Get generated text if available
@@ -147,16 +239,90 @@ func (h *HookMetric) recordFinish(index int) {
 
 	// Prepare metric input data
 	metricInput := &types.MetricInput{
-		RetrievalGT:    [][]int{h.qaPairMetricList[index].qaPair.PIDs},
+		RetrievalGT:    [][]int{retrievalGT},
 		RetrievalIDs:   retrievalIDs,
 		GeneratedTexts: generatedTexts,
-		GeneratedGT:    h.qaPairMetricList[index].qaPair.Answer,
+		GeneratedGT:    qaPair.Answer,
+		Question:       qaPair.Question,
+		Contexts:       contexts,
 	}
 
-	// Thread-safe append of metrics
+	// Thread-safe append of metrics, they are computed outside the lock as the judges call a chat model
+	result := h.metricResults.Compute(ctx, metricInput)
 	h.mu.Lock()
-	defer h.mu.Unlock()
-	h.metricResults.Append(metricInput)
+	h.metricResults.Append(result)
+	h.mu.Unlock()
+
+	return &types.EvaluationQuestion{
+		QuestionID:        qaPair.QID,
+		Question:          qaPair.Question,
+		ExpectedAnswer:    qaPair.Answer,
+		Answer:            generatedTexts,
+		RetrievedChunkIDs: chunkIDs,
+		Metric:            result,
+		Judgements:        metricInput.Judgements,
+	}
+}
+
+// matchGold numbers the gold references of a question and the retrieved results for the retrieval metrics of an
+// existing knowledge base. Gold chunk IDs match the retrieved chunks, gold knowledge IDs the knowledge of the retrieved
+// chunks, each knowledge counted once, and gold passages the retrieved chunks containing them or contained in them.
+// Results matching no gold reference get numbers of their own.
+func matchGold(qaPair *types.QAPair, results []*types.SearchResult) ([]int, []int) {
+	var gold []string
+	key := func(r *types.SearchResult) string { return r.ID }
+	switch {
+	case len(qaPair.ChunkIDs) > 0:
+		gold = qaPair.ChunkIDs
+	case len(qaPair.KnowledgeIDs) > 0:
+		gold = qaPair.KnowledgeIDs
+		key = func(r *types.SearchResult) string { return r.KnowledgeID }
+	default:
+		for _, passage := range qaPair.Passages {
+			if passage = normalizeContent(passage); passage != "" {
+				gold = append(gold, passage)
+			}
+		}
+		key = func(r *types.SearchResult) string {
+			content := normalizeContent(r.Content)
+			for _, passage := range gold {
+				if content != "" && (strings.Contains(content, passage) || strings.Contains(passage, content)) {
+					return passage
+				}
+			}
+			return "result:" + r.ID
+		}
+	}
+
+	numbers := make(map[string]int, len(gold)+len(results))
+	number := func(k string) int {
+		n, ok := numbers[k]
+		if !ok {
+			n = len(numbers)
+			numbers[k] = n
+		}
+		return n
+	}
+	goldIDs := make([]int, 0, len(gold))
+	for _, g := range gold {
+		goldIDs = append(goldIDs, number(g))
+	}
+	retrievedIDs := make([]int, 0, len(results))
+	seen := make(map[int]bool, len(results))
+	for _, r := range results {
+		n := number(key(r))
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		retrievedIDs = append(retrievedIDs, n)
+	}
+	return goldIDs, retrievedIDs
+}
+
+// normalizeContent collapses the whitespace of a text to compare passages with chunks
+func normalizeContent(text string) string {
+	return strings.Join(strings.Fields(text), " ")
 }
 
 // MetricResult returns the averaged metric results
@@ -0,0 +1,80 @@
+package retriever
+
+import (
+	"sort"
+
+	"github.com/Tencent/WeKnora/internal/common"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// FuseRetrieveResults combines the result lists of several retrievers into one list of unique chunks.
+// Scores of different retrievers are on different scales (BM25 vs cosine similarity),
+// so RRF and weighted fusion only rely on ranks or per-list normalised scores.
+func FuseRetrieveResults(results []*types.RetrieveResult, fusion *types.FusionConfig) []*types.IndexWithScore {
+	switch fusion.GetMethod() {
+	case types.FusionMethodRRF:
+		k := float64(fusion.GetRRFK())
+		return fuseResults(results, func(list []*types.IndexWithScore, rank int, weight float64) float64 {
+			return weight / (k + float64(rank+1))
+		}, fusion)
+	case types.FusionMethodWeighted:
+		return fuseResults(results, func(list []*types.IndexWithScore, rank int, weight float64) float64 {
+			minScore, maxScore := list[len(list)-1].Score, list[0].Score
+			if maxScore == minScore {
+				return weight
+			}
+			return weight * (list[rank].Score - minScore) / (maxScore - minScore)
+		}, fusion)
+	default:
+		var matchResults []*types.IndexWithScore
+		for _, result := range results {
+			matchResults = append(matchResults, result.Results...)
+		}
+		return common.Deduplicate(func(r *types.IndexWithScore) string { return r.ChunkID }, matchResults...)
+	}
+}
+
+// fuseResults sums the per-list contribution of every chunk and sorts chunks by the fused score.
+// Each list is sorted by score in descending order before computing contributions.
+func fuseResults(results []*types.RetrieveResult,
+	contribution func(list []*types.IndexWithScore, rank int, weight float64) float64,
+	fusion *types.FusionConfig,
+) []*types.IndexWithScore {
+	fused := make(map[string]*types.IndexWithScore)
+	var order []string
+	for _, result := range results {
+		if len(result.Results) == 0 {
+			continue
+		}
+		list := make([]*types.IndexWithScore, len(result.Results))
+		copy(list, result.Results)
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Score > list[j].Score })
+
+		weight := fusion.GetWeight(result.RetrieverType)
+		seen := make(map[string]bool, len(list))
+		for rank, item := range list {
+			// A chunk only contributes once per list, with its best rank
+			if seen[item.ChunkID] {
+				continue
+			}
+			seen[item.ChunkID] = true
+
+			score := contribution(list, rank, weight)
+			if existing, ok := fused[item.ChunkID]; ok {
+				existing.Score += score
+				continue
+			}
+			copied := *item
+			copied.Score = score
+			fused[item.ChunkID] = &copied
+			order = append(order, item.ChunkID)
+		}
+	}
+
+	fusedResults := make([]*types.IndexWithScore, 0, len(order))
+	for _, chunkID := range order {
+		fusedResults = append(fusedResults, fused[chunkID])
+	}
+	sort.SliceStable(fusedResults, func(i, j int) bool { return fusedResults[i].Score > fusedResults[j].Score })
+	return fusedResults
+}
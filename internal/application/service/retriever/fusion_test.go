@@ -0,0 +1,69 @@
+package retriever
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+func newRetrieveResult(retrieverType types.RetrieverType, scores map[string]float64) *types.RetrieveResult {
+	result := &types.RetrieveResult{RetrieverType: retrieverType}
+	for chunkID, score := range scores {
+		result.Results = append(result.Results, &types.IndexWithScore{ChunkID: chunkID, Score: score})
+	}
+	return result
+}
+
+func TestFuseRetrieveResults(t *testing.T) {
+	results := []*types.RetrieveResult{
+		// Vector ranking: a, b, c
+		newRetrieveResult(types.VectorRetrieverType, map[string]float64{"a": 0.9, "b": 0.8, "c": 0.5}),
+		// Keyword ranking: c, a
+		newRetrieveResult(types.KeywordsRetrieverType, map[string]float64{"c": 12.0, "a": 3.0}),
+	}
+
+	t.Run("None", func(t *testing.T) {
+		fused := FuseRetrieveResults(results, nil)
+		if len(fused) != 3 {
+			t.Errorf("Expected 3 unique chunks, got %d", len(fused))
+		}
+	})
+
+	t.Run("RRF", func(t *testing.T) {
+		fused := FuseRetrieveResults(results, &types.FusionConfig{Method: types.FusionMethodRRF, RRFK: 60})
+		expected := map[string]float64{
+			"a": 1.0/61 + 1.0/62,
+			"b": 1.0 / 62,
+			"c": 1.0/63 + 1.0/61,
+		}
+		checkFused(t, fused, expected, []string{"a", "c", "b"})
+	})
+
+	t.Run("Weighted", func(t *testing.T) {
+		fused := FuseRetrieveResults(results, &types.FusionConfig{
+			Method: types.FusionMethodWeighted, VectorWeight: 0.7, KeywordWeight: 0.3,
+		})
+		expected := map[string]float64{
+			"a": 0.7 * 1.0,
+			"b": 0.7 * 0.75,
+			"c": 0.3 * 1.0,
+		}
+		checkFused(t, fused, expected, []string{"a", "b", "c"})
+	})
+}
+
+func checkFused(t *testing.T, fused []*types.IndexWithScore, expected map[string]float64, order []string) {
+	t.Helper()
+	if len(fused) != len(order) {
+		t.Fatalf("Expected %d chunks, got %d", len(order), len(fused))
+	}
+	for i, item := range fused {
+		if item.ChunkID != order[i] {
+			t.Errorf("Position %d: expected chunk %s, got %s", i, order[i], item.ChunkID)
+		}
+		if math.Abs(item.Score-expected[item.ChunkID]) > 1e-9 {
+			t.Errorf("Chunk %s: expected score %v, got %v", item.ChunkID, expected[item.ChunkID], item.Score)
+		}
+	}
+}
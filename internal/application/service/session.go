@@ -24,6 +24,7 @@ type sessionService struct {
 	knowledgeBaseService interfaces.KnowledgeBaseService // Service for knowledge base operations
 	modelService         interfaces.ModelService         // Service for model operations
 	eventManager         *chatpipline.EventManager       // Event manager for chat pipeline
+	answerCache          interfaces.AnswerCache          // Semantic cache of generated answers
 }
 
 // NewSessionService creates a new session service instance with all required dependencies
@@ -33,6 +34,7 @@ func NewSessionService(cfg *config.Config,
 	knowledgeBaseService interfaces.KnowledgeBaseService,
 	modelService interfaces.ModelService,
 	eventManager *chatpipline.EventManager,
+	answerCache interfaces.AnswerCache,
 ) interfaces.SessionService {
 	return &sessionService{
 		cfg:                  cfg,
@@ -41,6 +43,7 @@ func NewSessionService(cfg *config.Config,
 		knowledgeBaseService: knowledgeBaseService,
 		modelService:         modelService,
 		eventManager:         eventManager,
+		answerCache:          answerCache,
 	}
 }
 
@@ -57,6 +60,27 @@ func (s *sessionService) CreateSession(ctx context.Context, session *types.Sessi
 	logger.Infof(ctx, "Creating session, tenant ID: %d, model ID: %s, knowledge base ID: %s",
 		session.TenantID, session.SummaryModelID, session.KnowledgeBaseID)
 
+	// Validate the session pipeline if configured
+	if session.Pipeline != nil {
+		if _, err := s.eventManager.ResolvePipeline(session.Pipeline); err != nil {
+			logger.Errorf(ctx, "Failed to create session: invalid pipeline: %v", err)
+			return nil, err
+		}
+	}
+	if err := session.Fusion.Validate(); err != nil {
+		logger.Errorf(ctx, "Failed to create session: invalid fusion config: %v", err)
+		return nil, err
+	}
+	if err := session.Filters.Validate(); err != nil {
+		logger.Errorf(ctx, "Failed to create session: invalid metadata filters: %v", err)
+		return nil, err
+	}
+	session.Audience = session.Audience.Normalize()
+	if err := session.Audience.Validate(); err != nil {
+		logger.Errorf(ctx, "Failed to create session: invalid audience: %v", err)
+		return nil, err
+	}
+
 	// Create session in repository
 	createdSession, err := s.sessionRepo.Create(ctx, session)
 	if err != nil {
@@ -156,6 +180,27 @@ func (s *sessionService) UpdateSession(ctx context.Context, session *types.Sessi
 
 	logger.Infof(ctx, "Updating session, ID: %s, tenant ID: %d", session.ID, session.TenantID)
 
+	// Validate the session pipeline if configured
+	if session.Pipeline != nil {
+		if _, err := s.eventManager.ResolvePipeline(session.Pipeline); err != nil {
+			logger.Errorf(ctx, "Failed to update session: invalid pipeline: %v", err)
+			return err
+		}
+	}
+	if err := session.Fusion.Validate(); err != nil {
+		logger.Errorf(ctx, "Failed to update session: invalid fusion config: %v", err)
+		return err
+	}
+	if err := session.Filters.Validate(); err != nil {
+		logger.Errorf(ctx, "Failed to update session: invalid metadata filters: %v", err)
+		return err
+	}
+	session.Audience = session.Audience.Normalize()
+	if err := session.Audience.Validate(); err != nil {
+		logger.Errorf(ctx, "Failed to update session: invalid audience: %v", err)
+		return err
+	}
+
 	// Update session in repository
 	err := s.sessionRepo.Update(ctx, session)
 	if err != nil {
@@ -271,7 +316,7 @@ func (s *sessionService) GenerateTitle(ctx context.Context,
 	logger.Info(ctx, "Preparing to generate session title")
 	var chatMessages []chat.Message
 	chatMessages = append(chatMessages,
-		chat.Message{Role: "system", Content: s.cfg.Conversation.GenerateSessionTitlePrompt},
+		chat.Message{Role: "system", Content: s.cfg.GetConversation().GenerateSessionTitlePrompt},
 	)
 	chatMessages = append(chatMessages,
 		chat.Message{Role: "user", Content: message.Content + " /no_think"},
@@ -324,21 +369,25 @@ func (s *sessionService) KnowledgeQA(ctx context.Context, sessionID, query strin
 	}
 
 	// Validate knowledge base association
-	if session.KnowledgeBaseID == "" {
+	if len(session.GetKnowledgeBases()) == 0 {
 		logger.Warnf(ctx, "Session has no associated knowledge base, session ID: %s", sessionID)
 		return nil, nil, errors.New("session has no knowledge base")
 	}
 
 	// Create chat management object with session settings
-	logger.Infof(ctx, "Creating chat manage object, knowledge base ID: %s", session.KnowledgeBaseID)
+	logger.Infof(ctx, "Creating chat manage object, knowledge base IDs: %v", session.GetKnowledgeBases().IDs())
 	chatManage := &types.ChatManage{
 		Query:            query,
 		RewriteQuery:     query,
 		SessionID:        sessionID,
 		KnowledgeBaseID:  session.KnowledgeBaseID,
+		KnowledgeBases:   session.KnowledgeBases,
 		VectorThreshold:  session.VectorThreshold,
 		KeywordThreshold: session.KeywordThreshold,
 		EmbeddingTopK:    session.EmbeddingTopK,
+		Fusion:           session.Fusion,
+		Filters:          session.Filters,
+		Audience:         session.Audience,
 		RerankModelID:    session.RerankModelID,
 		RerankTopK:       session.RerankTopK,
 		RerankThreshold:  session.RerankThreshold,
@@ -360,31 +409,88 @@ func (s *sessionService) KnowledgeQA(ctx context.Context, sessionID, query strin
 		FallbackResponse: session.FallbackResponse,
 	}
 
+	// Resolve the pipeline configured for the session or its knowledge base
+	pipeline, err := s.resolvePipeline(ctx, session)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to resolve pipeline, session ID: %s, error: %v", sessionID, err)
+		return nil, nil, err
+	}
+	chatManage.Pipeline = pipeline.Name
+	chatManage.PipelineOptions = pipeline.StepOptions()
+
+	// Answer repeated questions from the semantic answer cache
+	cacheLookup, cached := s.getCachedAnswer(ctx, chatManage, pipeline)
+	if cached != nil {
+		logger.Infof(ctx, "Answer cache hit, session ID: %s, cached query: %s", sessionID, cached.Query)
+		return cached.KnowledgeReferences, chatpipline.NewCitedAnswerChan(ctx, cached.Answer, cached.Citations), nil
+	}
+
 	// Start knowledge QA event processing
-	logger.Info(ctx, "Triggering knowledge base question answering event")
-	err = s.KnowledgeQAByEvent(ctx, chatManage, types.Pipline["rag_stream"])
+	logger.Infof(ctx, "Triggering knowledge base question answering event, pipeline: %s", pipeline.Name)
+	err = s.knowledgeQAByPipeline(ctx, chatManage, pipeline)
 	if err != nil {
 		logger.ErrorWithFields(ctx, err, map[string]interface{}{
-			"session_id":        sessionID,
-			"knowledge_base_id": session.KnowledgeBaseID,
+			"session_id":         sessionID,
+			"knowledge_base_ids": session.GetKnowledgeBases().IDs(),
 		})
 		return nil, nil, err
 	}
 
+	// Cache the answer once it is fully generated, only answers based on search results are cached
+	if cacheLookup != nil && chatManage.ResponseChan != nil && len(chatManage.MergeResult) > 0 {
+		chatManage.ResponseChan = s.cacheAnswerStream(ctx, cacheLookup, chatManage)
+	}
+
 	logger.Info(ctx, "Knowledge base question answering completed")
 	return chatManage.MergeResult, chatManage.ResponseChan, nil
 }
 
-// KnowledgeQAByEvent processes knowledge QA through a series of events in the pipeline
+// resolvePipeline picks the pipeline of the session, then the one of its primary knowledge base,
+// and falls back to the default streaming RAG pipeline
+func (s *sessionService) resolvePipeline(ctx context.Context, session *types.Session) (*types.PipelineConfig, error) {
+	if session.Pipeline != nil {
+		return s.eventManager.ResolvePipeline(session.Pipeline)
+	}
+	kbs := session.GetKnowledgeBases()
+	if len(kbs) > 0 {
+		kb, err := s.knowledgeBaseService.GetKnowledgeBaseByID(ctx, kbs[0].KnowledgeBaseID)
+		if err != nil {
+			return nil, err
+		}
+		if kb.Pipeline != nil {
+			return s.eventManager.ResolvePipeline(kb.Pipeline)
+		}
+	}
+	return s.eventManager.GetPipeline(types.PipelineRAGStream)
+}
+
+// KnowledgeQAByEvent processes knowledge QA through the events of the named pipeline
 func (s *sessionService) KnowledgeQAByEvent(ctx context.Context,
-	chatManage *types.ChatManage, eventList []types.EventType,
+	chatManage *types.ChatManage, pipelineName string,
+) error {
+	pipeline, err := s.eventManager.GetPipeline(pipelineName)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get pipeline: %v", err)
+		return err
+	}
+	chatManage.Pipeline = pipeline.Name
+	if chatManage.PipelineOptions == nil {
+		chatManage.PipelineOptions = pipeline.StepOptions()
+	}
+	return s.knowledgeQAByPipeline(ctx, chatManage, pipeline)
+}
+
+// knowledgeQAByPipeline processes knowledge QA through a series of events in the pipeline
+func (s *sessionService) knowledgeQAByPipeline(ctx context.Context,
+	chatManage *types.ChatManage, pipeline *types.PipelineConfig,
 ) error {
 	ctx, span := tracing.ContextWithSpan(ctx, "SessionService.KnowledgeQAByEvent")
 	defer span.End()
+	eventList := pipeline.Events()
 
 	logger.Info(ctx, "Start processing knowledge base question answering through events")
-	logger.Infof(ctx, "Knowledge base question answering parameters, session ID: %s, knowledge base ID: %s, query: %s",
-		chatManage.SessionID, chatManage.KnowledgeBaseID, chatManage.Query)
+	logger.Infof(ctx, "Knowledge base question answering parameters, session ID: %s, knowledge base IDs: %v, query: %s",
+		chatManage.SessionID, chatManage.GetKnowledgeBases().IDs(), chatManage.Query)
 
 	// Prepare method list for logging and tracing
 	methods := []string{}
@@ -398,6 +504,7 @@ func (s *sessionService) KnowledgeQAByEvent(ctx context.Context,
 		attribute.String("request_id", ctx.Value(types.RequestIDContextKey).(string)),
 		attribute.String("query", chatManage.Query),
 		attribute.String("method", strings.Join(methods, ",")),
+		attribute.String("pipeline", pipeline.Name),
 	)
 
 	// Process each event in sequence
@@ -413,6 +520,12 @@ func (s *sessionService) KnowledgeQAByEvent(ctx context.Context,
 			return nil
 		}
 
+		// Handle case where an FAQ entry answers the query, the answer is already set
+		if err == chatpipline.ErrDirectAnswer {
+			logger.Infof(ctx, "Event %v triggered, answered by FAQ entry", event)
+			return nil
+		}
+
 		// Handle other errors
 		if err != nil {
 			logger.Errorf(ctx, "Event triggering failed, event: %v, error type: %s, description: %s, error: %v",
@@ -431,21 +544,26 @@ func (s *sessionService) KnowledgeQAByEvent(ctx context.Context,
 
 // SearchKnowledge performs knowledge base search without LLM summarization
 func (s *sessionService) SearchKnowledge(ctx context.Context,
-	knowledgeBaseID, query string,
+	knowledgeBaseID, query string, audience []string,
 ) ([]*types.SearchResult, error) {
 	logger.Info(ctx, "Start knowledge base search without LLM summary")
 	logger.Infof(ctx, "Knowledge base search parameters, knowledge base ID: %s, query: %s", knowledgeBaseID, query)
+	if err := types.AccessLabels(audience).Validate(); err != nil {
+		logger.Errorf(ctx, "Invalid audience: %v", err)
+		return nil, err
+	}
 
 	// Create default retrieval parameters
 	chatManage := &types.ChatManage{
 		Query:            query,
 		RewriteQuery:     query,
 		KnowledgeBaseID:  knowledgeBaseID,
-		VectorThreshold:  s.cfg.Conversation.VectorThreshold,  // Use default configuration
-		KeywordThreshold: s.cfg.Conversation.KeywordThreshold, // Use default configuration
-		EmbeddingTopK:    s.cfg.Conversation.EmbeddingTopK,    // Use default configuration
-		RerankTopK:       s.cfg.Conversation.RerankTopK,       // Use default configuration
-		RerankThreshold:  s.cfg.Conversation.RerankThreshold,  // Use default configuration
+		Audience:         audience,
+		VectorThreshold:  s.cfg.GetConversation().VectorThreshold,  // Use default configuration
+		KeywordThreshold: s.cfg.GetConversation().KeywordThreshold, // Use default configuration
+		EmbeddingTopK:    s.cfg.GetConversation().EmbeddingTopK,    // Use default configuration
+		RerankTopK:       s.cfg.GetConversation().RerankTopK,       // Use default configuration
+		RerankThreshold:  s.cfg.GetConversation().RerankThreshold,  // Use default configuration
 	}
 
 	// Get default models
@@ -463,14 +581,15 @@ func (s *sessionService) SearchKnowledge(ctx context.Context,
 		}
 	}
 
-	// Use specific event list, only including retrieval-related events, not LLM summarization
-	searchEvents := []types.EventType{
-		types.PREPROCESS_QUERY, // Preprocess query
-		types.CHUNK_SEARCH,     // Vector search
-		types.CHUNK_RERANK,     // Rerank search results
-		types.CHUNK_MERGE,      // Merge search results
-		types.FILTER_TOP_K,     // Filter top K results
+	// Use the search pipeline, only including retrieval-related events, not LLM summarization
+	pipeline, err := s.eventManager.GetPipeline(types.PipelineSearch)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get search pipeline: %v", err)
+		return nil, err
 	}
+	chatManage.Pipeline = pipeline.Name
+	chatManage.PipelineOptions = pipeline.StepOptions()
+	searchEvents := pipeline.Events()
 
 	ctx, span := tracing.ContextWithSpan(ctx, "SessionService.SearchKnowledge")
 	defer span.End()
@@ -500,6 +619,12 @@ func (s *sessionService) SearchKnowledge(ctx context.Context,
 			return []*types.SearchResult{}, nil
 		}
 
+		// Handle case where an FAQ entry answers the query, the FAQ hit is the only result
+		if err == chatpipline.ErrDirectAnswer {
+			logger.Infof(ctx, "Event %v triggered, answered by FAQ entry", event)
+			return chatManage.MergeResult, nil
+		}
+
 		// Handle other errors
 		if err != nil {
 			logger.Errorf(ctx, "Event triggering failed, event: %v, error type: %s, description: %s, error: %v",
@@ -0,0 +1,188 @@
+// Package sweep picks the retrieval settings of an evaluation sweep and recommends the best of its trials
+package sweep
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// MaxTrials is the most settings a sweep tries
+const MaxTrials = 200
+
+// Settings returns the settings a sweep tries, in grid mode every combination of the values of the space and in
+// random mode a sample of samples combinations, all of them if there are fewer. Duplicate values are tried once.
+func Settings(space types.SweepSpace,
+	mode types.SweepMode, samples int, seed int64,
+) ([]types.SweepSettings, error) {
+	embeddingTopK, vectorThreshold := dedupe(space.EmbeddingTopK), dedupe(space.VectorThreshold)
+	keywordThreshold, rerankTopK := dedupe(space.KeywordThreshold), dedupe(space.RerankTopK)
+	rerankThreshold := dedupe(space.RerankThreshold)
+	if err := checkValues("embedding_top_k", embeddingTopK, 1); err != nil {
+		return nil, err
+	}
+	if err := checkValues("vector_threshold", vectorThreshold, 0); err != nil {
+		return nil, err
+	}
+	if err := checkValues("keyword_threshold", keywordThreshold, 0); err != nil {
+		return nil, err
+	}
+	if err := checkValues("rerank_top_k", rerankTopK, 1); err != nil {
+		return nil, err
+	}
+	if err := checkValues("rerank_threshold", rerankThreshold, 0); err != nil {
+		return nil, err
+	}
+
+	// Combinations are numbered in mixed radix, the last parameter varies fastest
+	sizes := []int{len(embeddingTopK), len(vectorThreshold), len(keywordThreshold), len(rerankTopK), len(rerankThreshold)}
+	total := 1
+	for _, size := range sizes {
+		if total > math.MaxInt32/size {
+			total = math.MaxInt32
+			break
+		}
+		total *= size
+	}
+	setting := func(n int) types.SweepSettings {
+		index := make([]int, len(sizes))
+		for i := len(sizes) - 1; i >= 0; i-- {
+			index[i] = n % sizes[i]
+			n /= sizes[i]
+		}
+		return types.SweepSettings{
+			EmbeddingTopK:    embeddingTopK[index[0]],
+			VectorThreshold:  vectorThreshold[index[1]],
+			KeywordThreshold: keywordThreshold[index[2]],
+			RerankTopK:       rerankTopK[index[3]],
+			RerankThreshold:  rerankThreshold[index[4]],
+		}
+	}
+
+	var numbers []int
+	switch mode {
+	case types.SweepModeGrid:
+		if total > MaxTrials {
+			return nil, fmt.Errorf("the grid has %d settings, at most %d are allowed", total, MaxTrials)
+		}
+		for n := 0; n < total; n++ {
+			numbers = append(numbers, n)
+		}
+	case types.SweepModeRandom:
+		if samples <= 0 || samples > MaxTrials {
+			return nil, fmt.Errorf("samples must be between 1 and %d", MaxTrials)
+		}
+		if samples >= total {
+			for n := 0; n < total; n++ {
+				numbers = append(numbers, n)
+			}
+			break
+		}
+		r := rand.New(rand.NewSource(seed))
+		picked := make(map[int]bool, samples)
+		for len(numbers) < samples {
+			if n := r.Intn(total); !picked[n] {
+				picked[n] = true
+				numbers = append(numbers, n)
+			}
+		}
+		sort.Ints(numbers)
+	default:
+		return nil, fmt.Errorf("unknown sweep mode %s", mode)
+	}
+
+	settings := make([]types.SweepSettings, 0, len(numbers))
+	for _, n := range numbers {
+		settings = append(settings, setting(n))
+	}
+	return settings, nil
+}
+
+// dedupe drops the repeated values of a list, keeping the first of each
+func dedupe[T int | float64](values []T) []T {
+	var unique []T
+	for _, v := range values {
+		if !slices.Contains(unique, v) {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// checkValues makes sure a parameter has values and none of them is below the minimum
+func checkValues[T int | float64](name string, values []T, minimum T) error {
+	if len(values) == 0 {
+		return fmt.Errorf("%s has no values", name)
+	}
+	for _, v := range values {
+		if v < minimum {
+			return fmt.Errorf("%s must not be below %v", name, minimum)
+		}
+	}
+	return nil
+}
+
+// Latency returns the average and the 95th percentile of the durations in milliseconds
+func Latency(durations []time.Duration) (avg float64, p95 float64) {
+	if len(durations) == 0 {
+		return 0, 0
+	}
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	// Nearest rank percentile
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	return milliseconds(total) / float64(len(sorted)), milliseconds(sorted[rank])
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// MarkPareto marks the trials no other trial dominates. A trial dominates another if its recall, MRR and
+// NDCG@10 are not lower and its average latency is not higher, with at least one of them strictly better.
+func MarkPareto(trials []*types.SweepTrial) {
+	for _, trial := range trials {
+		trial.Pareto = !slices.ContainsFunc(trials, func(other *types.SweepTrial) bool {
+			return dominates(other, trial)
+		})
+	}
+}
+
+func dominates(a, b *types.SweepTrial) bool {
+	if a.Metrics.Recall < b.Metrics.Recall || a.Metrics.MRR < b.Metrics.MRR ||
+		a.Metrics.NDCG10 < b.Metrics.NDCG10 || a.AvgLatencyMs > b.AvgLatencyMs {
+		return false
+	}
+	return a.Metrics.Recall > b.Metrics.Recall || a.Metrics.MRR > b.Metrics.MRR ||
+		a.Metrics.NDCG10 > b.Metrics.NDCG10 || a.AvgLatencyMs < b.AvgLatencyMs
+}
+
+// Recommend returns the Pareto-optimal trials by descending recall, then NDCG@10, then ascending latency
+func Recommend(trials []*types.SweepTrial) []*types.SweepTrial {
+	var recommended []*types.SweepTrial
+	for _, trial := range trials {
+		if trial.Pareto {
+			recommended = append(recommended, trial)
+		}
+	}
+	sort.SliceStable(recommended, func(i, j int) bool {
+		a, b := recommended[i], recommended[j]
+		if a.Metrics.Recall != b.Metrics.Recall {
+			return a.Metrics.Recall > b.Metrics.Recall
+		}
+		if a.Metrics.NDCG10 != b.Metrics.NDCG10 {
+			return a.Metrics.NDCG10 > b.Metrics.NDCG10
+		}
+		return a.AvgLatencyMs < b.AvgLatencyMs
+	})
+	return recommended
+}
@@ -0,0 +1,164 @@
+package sweep
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+func TestSettings(t *testing.T) {
+	space := types.SweepSpace{
+		EmbeddingTopK:    []int{10, 30, 10},
+		VectorThreshold:  []float64{0.5},
+		KeywordThreshold: []float64{0.3},
+		RerankTopK:       []int{5},
+		RerankThreshold:  []float64{0.5, 0.7},
+	}
+	tests := []struct {
+		name    string
+		space   types.SweepSpace
+		mode    types.SweepMode
+		samples int
+		want    []types.SweepSettings
+		wantErr bool
+	}{
+		{
+			name:  "grid tries every combination once",
+			space: space,
+			mode:  types.SweepModeGrid,
+			want: []types.SweepSettings{
+				{EmbeddingTopK: 10, VectorThreshold: 0.5, KeywordThreshold: 0.3, RerankTopK: 5, RerankThreshold: 0.5},
+				{EmbeddingTopK: 10, VectorThreshold: 0.5, KeywordThreshold: 0.3, RerankTopK: 5, RerankThreshold: 0.7},
+				{EmbeddingTopK: 30, VectorThreshold: 0.5, KeywordThreshold: 0.3, RerankTopK: 5, RerankThreshold: 0.5},
+				{EmbeddingTopK: 30, VectorThreshold: 0.5, KeywordThreshold: 0.3, RerankTopK: 5, RerankThreshold: 0.7},
+			},
+		},
+		{
+			name:    "random with more samples than combinations tries them all",
+			space:   space,
+			mode:    types.SweepModeRandom,
+			samples: 10,
+			want: []types.SweepSettings{
+				{EmbeddingTopK: 10, VectorThreshold: 0.5, KeywordThreshold: 0.3, RerankTopK: 5, RerankThreshold: 0.5},
+				{EmbeddingTopK: 10, VectorThreshold: 0.5, KeywordThreshold: 0.3, RerankTopK: 5, RerankThreshold: 0.7},
+				{EmbeddingTopK: 30, VectorThreshold: 0.5, KeywordThreshold: 0.3, RerankTopK: 5, RerankThreshold: 0.5},
+				{EmbeddingTopK: 30, VectorThreshold: 0.5, KeywordThreshold: 0.3, RerankTopK: 5, RerankThreshold: 0.7},
+			},
+		},
+		{
+			name:    "random without samples",
+			space:   space,
+			mode:    types.SweepModeRandom,
+			wantErr: true,
+		},
+		{
+			name: "grid too large",
+			space: types.SweepSpace{
+				EmbeddingTopK:    []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+				VectorThreshold:  []float64{0.1, 0.2, 0.3, 0.4, 0.5},
+				KeywordThreshold: []float64{0.1, 0.2, 0.3, 0.4, 0.5},
+				RerankTopK:       []int{5},
+				RerankThreshold:  []float64{0.5},
+			},
+			mode:    types.SweepModeGrid,
+			wantErr: true,
+		},
+		{
+			name:    "missing values",
+			space:   types.SweepSpace{EmbeddingTopK: []int{10}},
+			mode:    types.SweepModeGrid,
+			wantErr: true,
+		},
+		{
+			name: "negative threshold",
+			space: types.SweepSpace{
+				EmbeddingTopK:    []int{10},
+				VectorThreshold:  []float64{-0.1},
+				KeywordThreshold: []float64{0.3},
+				RerankTopK:       []int{5},
+				RerankThreshold:  []float64{0.5},
+			},
+			mode:    types.SweepModeGrid,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Settings(tt.space, tt.mode, tt.samples, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Settings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Settings() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettings_RandomSample(t *testing.T) {
+	space := types.SweepSpace{
+		EmbeddingTopK:    []int{10, 20, 30, 40, 50},
+		VectorThreshold:  []float64{0.1, 0.2, 0.3, 0.4, 0.5},
+		KeywordThreshold: []float64{0.1, 0.2, 0.3, 0.4, 0.5},
+		RerankTopK:       []int{3, 5, 10},
+		RerankThreshold:  []float64{0.3, 0.5, 0.7},
+	}
+	first, err := Settings(space, types.SweepModeRandom, 20, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := Settings(space, types.SweepModeRandom, 20, 42)
+	if len(first) != 20 || !reflect.DeepEqual(first, second) {
+		t.Errorf("expected the same 20 settings for the same seed, got %d and %d", len(first), len(second))
+	}
+	seen := make(map[types.SweepSettings]bool)
+	for _, s := range first {
+		if seen[s] {
+			t.Errorf("setting %v sampled twice", s)
+		}
+		seen[s] = true
+	}
+}
+
+func TestLatency(t *testing.T) {
+	var durations []time.Duration
+	for i := 20; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	avg, p95 := Latency(durations)
+	if math.Abs(avg-10.5) > 1e-9 || p95 != 19 {
+		t.Errorf("Latency() = %v, %v, want 10.5, 19", avg, p95)
+	}
+}
+
+func TestMarkPareto(t *testing.T) {
+	trial := func(recall, mrr, ndcg, latency float64) *types.SweepTrial {
+		return &types.SweepTrial{
+			Metrics:      types.RetrievalMetrics{Recall: recall, MRR: mrr, NDCG10: ndcg},
+			AvgLatencyMs: latency,
+		}
+	}
+	trials := []*types.SweepTrial{
+		trial(0.9, 0.8, 0.8, 300), // best quality
+		trial(0.7, 0.6, 0.6, 100), // fastest
+		trial(0.7, 0.6, 0.6, 200), // dominated by the fastest
+		trial(0.8, 0.5, 0.7, 400), // dominated by the best quality
+		trial(0.8, 0.7, 0.7, 150), // trade-off
+	}
+	MarkPareto(trials)
+	var pareto []bool
+	for _, trial := range trials {
+		pareto = append(pareto, trial.Pareto)
+	}
+	if want := []bool{true, true, false, false, true}; !reflect.DeepEqual(pareto, want) {
+		t.Errorf("Pareto = %v, want %v", pareto, want)
+	}
+
+	recommended := Recommend(trials)
+	if len(recommended) != 3 || recommended[0] != trials[0] || recommended[1] != trials[4] || recommended[2] != trials[1] {
+		t.Errorf("Recommend() returned %d trials in the wrong order", len(recommended))
+	}
+}
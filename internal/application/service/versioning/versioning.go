@@ -0,0 +1,106 @@
+// Package versioning keeps the content versions of knowledge: it detects changed content by its hash,
+// snapshots the active chunks of knowledge, restores the chunks of a version and picks the versions to prune
+package versioning
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/services/docreader/src/proto"
+	"github.com/google/uuid"
+)
+
+// ContentHash hashes the parsed content of a document. Image URLs are left out
+// because images are uploaded again on every read, their source URLs are hashed instead.
+func ContentHash(chunks []*proto.Chunk) string {
+	h := md5.New()
+	for _, chunk := range chunks {
+		h.Write([]byte(chunk.Content))
+		h.Write([]byte{0})
+		for _, image := range chunk.Images {
+			h.Write([]byte(image.OriginalUrl))
+			h.Write([]byte{0})
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Unchanged reports whether content with the hash is already indexed for the knowledge.
+// Knowledge whose last processing did not complete is always reindexed.
+func Unchanged(knowledge *types.Knowledge, contentHash string) bool {
+	return knowledge.ParseStatus == "completed" && knowledge.ContentHash == contentHash
+}
+
+// Next returns the number of the version replacing the active version of knowledge. It is higher than
+// every kept version, the active version may have a lower number after a rollback.
+func Next(knowledge *types.Knowledge, maxVersion int) int {
+	return max(knowledge.Version, maxVersion) + 1
+}
+
+// Snapshot keeps the chunks of the active version of knowledge as a version
+func Snapshot(knowledge *types.Knowledge, chunks []*types.Chunk) (*types.KnowledgeVersion, error) {
+	content, err := json.Marshal(chunks)
+	if err != nil {
+		return nil, err
+	}
+	return &types.KnowledgeVersion{
+		TenantID:    knowledge.TenantID,
+		KnowledgeID: knowledge.ID,
+		Version:     knowledge.Version,
+		ContentHash: knowledge.ContentHash,
+		Description: knowledge.Description,
+		StorageSize: knowledge.StorageSize,
+		ChunkCount:  len(chunks),
+		Chunks:      types.JSON(content),
+		ProcessedAt: knowledge.ProcessedAt,
+	}, nil
+}
+
+// Restore returns the chunks of a version to be stored again. Chunks get new IDs because the replaced
+// chunks are soft deleted and keep theirs, links between the chunks follow them. Relations are left out,
+// they are extracted again from the restored chunks.
+func Restore(version *types.KnowledgeVersion) ([]*types.Chunk, error) {
+	var chunks []*types.Chunk
+	if err := json.Unmarshal(version.Chunks, &chunks); err != nil {
+		return nil, fmt.Errorf("decode chunks of knowledge version %d: %w", version.Version, err)
+	}
+	ids := make(map[string]string, len(chunks))
+	for _, chunk := range chunks {
+		ids[chunk.ID] = uuid.New().String()
+	}
+	now := time.Now()
+	for _, chunk := range chunks {
+		chunk.ID = ids[chunk.ID]
+		chunk.PreChunkID = ids[chunk.PreChunkID]
+		chunk.NextChunkID = ids[chunk.NextChunkID]
+		chunk.ParentChunkID = ids[chunk.ParentChunkID]
+		chunk.RelationChunks = nil
+		chunk.IndirectRelationChunks = nil
+		chunk.CreatedAt = now
+		chunk.UpdatedAt = now
+	}
+	return chunks, nil
+}
+
+// Expired returns the numbers of the versions beyond the keep most recently replaced ones.
+// Nothing expires if keep is not positive.
+func Expired(versions []*types.KnowledgeVersion, keep int) []int {
+	if keep <= 0 || len(versions) <= keep {
+		return nil
+	}
+	sorted := make([]*types.KnowledgeVersion, len(versions))
+	copy(sorted, versions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+	var expired []int
+	for _, version := range sorted[keep:] {
+		expired = append(expired, version.Version)
+	}
+	return expired
+}
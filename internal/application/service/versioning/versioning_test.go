@@ -0,0 +1,216 @@
+package versioning
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/services/docreader/src/proto"
+)
+
+func parsed(contents ...string) []*proto.Chunk {
+	chunks := make([]*proto.Chunk, 0, len(contents))
+	for _, content := range contents {
+		chunks = append(chunks, &proto.Chunk{Content: content})
+	}
+	return chunks
+}
+
+func withImage(chunks []*proto.Chunk, originalURL, url string) []*proto.Chunk {
+	chunks[0].Images = append(chunks[0].Images, &proto.Image{OriginalUrl: originalURL, Url: url})
+	return chunks
+}
+
+func version(number int, replacedAt time.Time) *types.KnowledgeVersion {
+	return &types.KnowledgeVersion{Version: number, CreatedAt: replacedAt}
+}
+
+func TestContentHash(t *testing.T) {
+	base := ContentHash(withImage(parsed("A", "B"), "https://example.com/a.png", "https://cos/1.png"))
+	tests := []struct {
+		name   string
+		chunks []*proto.Chunk
+		same   bool
+	}{
+		{
+			name:   "same content",
+			chunks: withImage(parsed("A", "B"), "https://example.com/a.png", "https://cos/1.png"),
+			same:   true,
+		},
+		{
+			name:   "images uploaded again",
+			chunks: withImage(parsed("A", "B"), "https://example.com/a.png", "https://cos/2.png"),
+			same:   true,
+		},
+		{name: "image source changed", chunks: withImage(parsed("A", "B"), "https://example.com/b.png", "")},
+		{name: "image removed", chunks: parsed("A", "B")},
+		{name: "content changed", chunks: withImage(parsed("A", "B2"), "https://example.com/a.png", "")},
+		{name: "chunks reordered", chunks: withImage(parsed("B", "A"), "https://example.com/a.png", "")},
+		{name: "chunk boundary moved", chunks: withImage(parsed("AB", ""), "https://example.com/a.png", "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContentHash(tt.chunks) == base; got != tt.same {
+				t.Errorf("ContentHash() equal = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestUnchanged(t *testing.T) {
+	tests := []struct {
+		name        string
+		parseStatus string
+		contentHash string
+		want        bool
+	}{
+		{name: "completed with the same hash", parseStatus: "completed", contentHash: "h1", want: true},
+		{name: "completed with a new hash", parseStatus: "completed", contentHash: "h2"},
+		{name: "failed with the same hash", parseStatus: "failed", contentHash: "h1"},
+		{name: "processing with the same hash", parseStatus: "processing", contentHash: "h1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			knowledge := &types.Knowledge{ParseStatus: tt.parseStatus, ContentHash: "h1"}
+			if got := Unchanged(knowledge, tt.contentHash); got != tt.want {
+				t.Errorf("Unchanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name       string
+		active     int
+		maxVersion int
+		want       int
+	}{
+		{name: "first replacement", active: 1, maxVersion: 0, want: 2},
+		{name: "active is the newest", active: 3, maxVersion: 2, want: 4},
+		{name: "active was rolled back to", active: 1, maxVersion: 3, want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Next(&types.Knowledge{Version: tt.active}, tt.maxVersion); got != tt.want {
+				t.Errorf("Next() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	processedAt := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	knowledge := &types.Knowledge{
+		ID: "k1", TenantID: 7, Version: 2, ContentHash: "h2", Description: "summary",
+		StorageSize: 2048, ProcessedAt: &processedAt,
+	}
+	chunks := []*types.Chunk{
+		{ID: "p", Content: "P", ChunkType: types.ChunkTypeText, NextChunkID: "e", SimHash: 42},
+		{ID: "c", Content: "C", ChunkType: types.ChunkTypeChildText, ParentChunkID: "p"},
+		{
+			ID: "e", Content: "E", ChunkType: types.ChunkTypeText, PreChunkID: "p",
+			RelationChunks: types.JSON(`["p"]`), IndirectRelationChunks: types.JSON(`["c"]`),
+		},
+	}
+	snapshot, err := Snapshot(knowledge, chunks)
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	kept := types.KnowledgeVersion{
+		TenantID: snapshot.TenantID, KnowledgeID: snapshot.KnowledgeID, Version: snapshot.Version,
+		ContentHash: snapshot.ContentHash, Description: snapshot.Description, StorageSize: snapshot.StorageSize,
+		ChunkCount: snapshot.ChunkCount, ProcessedAt: snapshot.ProcessedAt,
+	}
+	want := types.KnowledgeVersion{
+		TenantID: 7, KnowledgeID: "k1", Version: 2, ContentHash: "h2", Description: "summary",
+		StorageSize: 2048, ChunkCount: 3, ProcessedAt: &processedAt,
+	}
+	if !reflect.DeepEqual(kept, want) {
+		t.Errorf("Snapshot() = %+v, want %+v", kept, want)
+	}
+
+	restored, err := Restore(snapshot)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if len(restored) != len(chunks) {
+		t.Fatalf("Restore() returned %d chunks, want %d", len(restored), len(chunks))
+	}
+	type links struct{ Content, Pre, Next, Parent, Relation, Indirect string }
+	ids := map[string]string{"": ""}
+	for i, chunk := range restored {
+		if chunk.ID == "" || chunk.ID == chunks[i].ID {
+			t.Errorf("chunk %d kept ID %q, want a new ID", i, chunk.ID)
+		}
+		ids[chunk.ID] = chunks[i].ID
+	}
+	wantLinks := []links{
+		{Content: "P", Next: "e"},
+		{Content: "C", Parent: "p"},
+		{Content: "E", Pre: "p"},
+	}
+	for i, chunk := range restored {
+		got := links{
+			Content: chunk.Content, Pre: ids[chunk.PreChunkID], Next: ids[chunk.NextChunkID],
+			Parent: ids[chunk.ParentChunkID], Relation: string(chunk.RelationChunks),
+			Indirect: string(chunk.IndirectRelationChunks),
+		}
+		if got != wantLinks[i] {
+			t.Errorf("chunk %d = %+v, want %+v", i, got, wantLinks[i])
+		}
+	}
+	// Versions are stored without the SimHash of their chunks, it is computed again when they are stored
+	if restored[0].SimHash != 0 {
+		t.Errorf("SimHash = %d, want 0", restored[0].SimHash)
+	}
+}
+
+func TestRestore_InvalidChunks(t *testing.T) {
+	if _, err := Restore(&types.KnowledgeVersion{Version: 1, Chunks: types.JSON(`{"not":"a list"}`)}); err == nil {
+		t.Error("Restore() error = nil, want a decode error")
+	}
+}
+
+func TestExpired(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 8, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name     string
+		versions []*types.KnowledgeVersion
+		keep     int
+		want     []int
+	}{
+		{
+			name:     "pruning disabled",
+			versions: []*types.KnowledgeVersion{version(1, day(1)), version(2, day(2))},
+			keep:     0,
+		},
+		{
+			name:     "within the limit",
+			versions: []*types.KnowledgeVersion{version(2, day(2)), version(1, day(1))},
+			keep:     2,
+		},
+		{
+			name:     "oldest replaced versions expire",
+			versions: []*types.KnowledgeVersion{version(3, day(3)), version(2, day(2)), version(1, day(1))},
+			keep:     1,
+			want:     []int{2, 1},
+		},
+		{
+			name: "a rolled back version replaced again is kept over higher numbers",
+			versions: []*types.KnowledgeVersion{
+				version(4, day(4)), version(3, day(3)), version(2, day(2)), version(1, day(5)),
+			},
+			keep: 2,
+			want: []int{3, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Expired(tt.versions, tt.keep); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
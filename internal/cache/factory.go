@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"github.com/Tencent/WeKnora/internal/config"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+// NewAnswerCache 创建语义答案缓存，启用时使用流管理器和asynq共用的Redis
+func NewAnswerCache(cfg *config.Config) (interfaces.AnswerCache, error) {
+	// 缓存在启动时创建，热更新配置不会改变答案缓存的设置
+	conversation := cfg.GetConversation()
+	if conversation == nil || conversation.AnswerCache == nil || !conversation.AnswerCache.Enable {
+		return &disabledAnswerCache{}, nil
+	}
+	db, err := strconv.Atoi(os.Getenv("REDIS_DB"))
+	if err != nil {
+		db = 0
+	}
+	return NewRedisAnswerCache(
+		os.Getenv("REDIS_ADDR"),
+		os.Getenv("REDIS_PASSWORD"),
+		db,
+		conversation.AnswerCache,
+	)
+}
+
+// disabledAnswerCache 未启用时使用的空缓存
+type disabledAnswerCache struct{}
+
+// Get 始终未命中
+func (d *disabledAnswerCache) Get(ctx context.Context,
+	scope string, embedding []float32,
+) (*interfaces.CachedAnswer, error) {
+	return nil, nil
+}
+
+// Set 不做任何处理
+func (d *disabledAnswerCache) Set(ctx context.Context, scope string, answer *interfaces.CachedAnswer) error {
+	return nil
+}
+
+// InvalidateKnowledge 不做任何处理
+func (d *disabledAnswerCache) InvalidateKnowledge(ctx context.Context, knowledgeIDs ...string) error {
+	return nil
+}
+
+// Enabled 返回false
+func (d *disabledAnswerCache) Enabled() bool {
+	return false
+}
@@ -0,0 +1,262 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/config"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/utils"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisAnswerCache 基于Redis的语义答案缓存
+// 每个缓存范围对应一个Hash，保存该范围内的所有答案，以及一个按写入时间排序的ZSet索引，
+// 查询时只读取并比较最近的若干条答案；
+// 每个知识对应一个Set，记录引用了该知识的答案，用于知识更新或删除时失效缓存
+type RedisAnswerCache struct {
+	client              *redis.Client
+	prefix              string        // Redis键前缀
+	ttl                 time.Duration // 缓存过期时间
+	maxEntries          int           // 每个缓存范围内的最大条目数
+	maxCandidates       int           // 每次查询比较的最近答案数
+	similarityThreshold float64       // 命中缓存的最小余弦相似度
+}
+
+// NewRedisAnswerCache 创建一个新的Redis语义答案缓存
+func NewRedisAnswerCache(redisAddr, redisPassword string,
+	redisDB int, cfg *config.AnswerCacheConfig,
+) (*RedisAnswerCache, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     redisAddr,
+		Password: redisPassword,
+		DB:       redisDB,
+	})
+
+	// 验证连接
+	_, err := client.Ping(context.Background()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("连接Redis失败: %w", err)
+	}
+
+	cache := &RedisAnswerCache{
+		client:              client,
+		prefix:              cfg.Prefix,
+		ttl:                 cfg.TTL,
+		maxEntries:          cfg.MaxEntries,
+		maxCandidates:       cfg.MaxCandidates,
+		similarityThreshold: cfg.SimilarityThreshold,
+	}
+	if cache.prefix == "" {
+		cache.prefix = "answer_cache" // 默认前缀
+	}
+	if cache.ttl == 0 {
+		cache.ttl = 24 * time.Hour // 默认TTL为24小时
+	}
+	if cache.maxEntries <= 0 {
+		cache.maxEntries = 1000
+	}
+	if cache.maxCandidates <= 0 {
+		cache.maxCandidates = 100
+	}
+	if cache.similarityThreshold <= 0 {
+		cache.similarityThreshold = 0.95
+	}
+	return cache, nil
+}
+
+// 构建缓存范围的Redis键
+func (r *RedisAnswerCache) scopeKey(scope string) string {
+	return fmt.Sprintf("%s:scope:%s", r.prefix, scope)
+}
+
+// 构建缓存范围按写入时间排序的索引的Redis键
+func (r *RedisAnswerCache) indexKey(scopeKey string) string {
+	return scopeKey + ":index"
+}
+
+// 构建知识反向索引的Redis键
+func (r *RedisAnswerCache) knowledgeKey(knowledgeID string) string {
+	return fmt.Sprintf("%s:knowledge:%s", r.prefix, knowledgeID)
+}
+
+// Get 返回缓存范围内最近的maxCandidates条答案中与查询向量最相似的答案，相似度不足时返回nil
+func (r *RedisAnswerCache) Get(ctx context.Context,
+	scope string, embedding []float32,
+) (*interfaces.CachedAnswer, error) {
+	candidates, err := r.loadRecent(ctx, r.scopeKey(scope))
+	if err != nil {
+		return nil, err
+	}
+	return mostSimilarAnswer(candidates, embedding, r.similarityThreshold), nil
+}
+
+// Set 将答案保存到缓存范围中，并为其引用的知识建立反向索引
+func (r *RedisAnswerCache) Set(ctx context.Context, scope string, answer *interfaces.CachedAnswer) error {
+	if answer.CreatedAt.IsZero() {
+		answer.CreatedAt = time.Now()
+	}
+	data, err := json.Marshal(answer)
+	if err != nil {
+		return fmt.Errorf("序列化缓存答案失败: %w", err)
+	}
+
+	scopeKey := r.scopeKey(scope)
+	if err := r.evict(ctx, scopeKey); err != nil {
+		return err
+	}
+
+	entryID := uuid.New().String()
+	indexKey := r.indexKey(scopeKey)
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, scopeKey, entryID, data)
+	pipe.ZAdd(ctx, indexKey, redis.Z{Score: float64(answer.CreatedAt.UnixNano()), Member: entryID})
+	pipe.Expire(ctx, scopeKey, r.ttl)
+	pipe.Expire(ctx, indexKey, r.ttl)
+	for _, knowledgeID := range referencedKnowledgeIDs(answer) {
+		key := r.knowledgeKey(knowledgeID)
+		pipe.SAdd(ctx, key, scopeKey+"|"+entryID)
+		pipe.Expire(ctx, key, r.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("保存缓存答案失败: %w", err)
+	}
+	return nil
+}
+
+// InvalidateKnowledge 删除所有引用了指定知识的缓存答案
+func (r *RedisAnswerCache) InvalidateKnowledge(ctx context.Context, knowledgeIDs ...string) error {
+	for _, knowledgeID := range knowledgeIDs {
+		key := r.knowledgeKey(knowledgeID)
+		members, err := r.client.SMembers(ctx, key).Result()
+		if err != nil {
+			return fmt.Errorf("获取知识缓存索引失败: %w", err)
+		}
+		pipe := r.client.TxPipeline()
+		for _, member := range members {
+			scopeKey, entryID, ok := strings.Cut(member, "|")
+			if !ok {
+				continue
+			}
+			pipe.HDel(ctx, scopeKey, entryID)
+			pipe.ZRem(ctx, r.indexKey(scopeKey), entryID)
+		}
+		pipe.Del(ctx, key)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("删除缓存答案失败: %w", err)
+		}
+		logger.Infof(ctx, "Answer cache invalidated, knowledge ID: %s, entries: %d", knowledgeID, len(members))
+	}
+	return nil
+}
+
+// Enabled 返回true
+func (r *RedisAnswerCache) Enabled() bool {
+	return true
+}
+
+// loadRecent 按写入时间从新到旧读取缓存范围内最近的maxCandidates条未过期答案，并顺带清理过期或损坏的条目
+func (r *RedisAnswerCache) loadRecent(ctx context.Context, scopeKey string) ([]*interfaces.CachedAnswer, error) {
+	indexKey := r.indexKey(scopeKey)
+	entryIDs, err := r.client.ZRevRange(ctx, indexKey, 0, int64(r.maxCandidates-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("获取缓存索引失败: %w", err)
+	}
+	if len(entryIDs) == 0 {
+		return nil, nil
+	}
+	values, err := r.client.HMGet(ctx, scopeKey, entryIDs...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("获取缓存答案失败: %w", err)
+	}
+
+	candidates := make([]*interfaces.CachedAnswer, 0, len(entryIDs))
+	var stale []string
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			// 条目已被失效，只剩索引
+			stale = append(stale, entryIDs[i])
+			continue
+		}
+		var answer interfaces.CachedAnswer
+		if err := json.Unmarshal([]byte(data), &answer); err != nil || time.Since(answer.CreatedAt) > r.ttl {
+			stale = append(stale, entryIDs[i])
+			continue
+		}
+		candidates = append(candidates, &answer)
+	}
+	if len(stale) > 0 {
+		if err := r.remove(ctx, scopeKey, stale...); err != nil {
+			logger.Warnf(ctx, "Failed to delete stale cached answers: %v", err)
+		}
+	}
+	return candidates, nil
+}
+
+// evict 缓存范围达到最大条目数时按索引淘汰最早的答案
+func (r *RedisAnswerCache) evict(ctx context.Context, scopeKey string) error {
+	indexKey := r.indexKey(scopeKey)
+	count, err := r.client.ZCard(ctx, indexKey).Result()
+	if err != nil {
+		return fmt.Errorf("获取缓存条目数失败: %w", err)
+	}
+	if int(count) < r.maxEntries {
+		return nil
+	}
+	oldest, err := r.client.ZRange(ctx, indexKey, 0, count-int64(r.maxEntries)).Result()
+	if err != nil {
+		return fmt.Errorf("获取缓存索引失败: %w", err)
+	}
+	if err := r.remove(ctx, scopeKey, oldest...); err != nil {
+		return fmt.Errorf("淘汰缓存答案失败: %w", err)
+	}
+	return nil
+}
+
+// remove 从缓存范围及其索引中删除答案
+func (r *RedisAnswerCache) remove(ctx context.Context, scopeKey string, entryIDs ...string) error {
+	members := make([]interface{}, len(entryIDs))
+	for i, entryID := range entryIDs {
+		members[i] = entryID
+	}
+	pipe := r.client.TxPipeline()
+	pipe.HDel(ctx, scopeKey, entryIDs...)
+	pipe.ZRem(ctx, r.indexKey(scopeKey), members...)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// mostSimilarAnswer 返回与查询向量相似度最高且不低于阈值的答案
+func mostSimilarAnswer(candidates []*interfaces.CachedAnswer,
+	embedding []float32, threshold float64,
+) *interfaces.CachedAnswer {
+	var best *interfaces.CachedAnswer
+	bestScore := threshold
+	for _, candidate := range candidates {
+		score := utils.CosineSimilarity(embedding, candidate.Embedding)
+		if score >= bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return best
+}
+
+// referencedKnowledgeIDs 返回答案引用的所有知识ID
+func referencedKnowledgeIDs(answer *interfaces.CachedAnswer) []string {
+	seen := make(map[string]bool)
+	var knowledgeIDs []string
+	for _, reference := range answer.KnowledgeReferences {
+		if reference.KnowledgeID == "" || seen[reference.KnowledgeID] {
+			continue
+		}
+		seen[reference.KnowledgeID] = true
+		knowledgeIDs = append(knowledgeIDs, reference.KnowledgeID)
+	}
+	return knowledgeIDs
+}
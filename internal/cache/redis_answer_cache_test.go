@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+func TestMostSimilarAnswer(t *testing.T) {
+	candidates := []*interfaces.CachedAnswer{
+		{Answer: "far", Embedding: []float32{0, 1}},
+		{Answer: "close", Embedding: []float32{1, 0.1}},
+		{Answer: "closest", Embedding: []float32{1, 0.01}},
+	}
+
+	if best := mostSimilarAnswer(candidates, []float32{1, 0}, 0.95); best == nil || best.Answer != "closest" {
+		t.Errorf("expected closest answer, got %+v", best)
+	}
+	if best := mostSimilarAnswer(candidates, []float32{-1, 0}, 0.95); best != nil {
+		t.Errorf("expected no answer above threshold, got %+v", best)
+	}
+	if best := mostSimilarAnswer(nil, []float32{1, 0}, 0.95); best != nil {
+		t.Errorf("expected no answer for empty cache, got %+v", best)
+	}
+}
+
+func TestReferencedKnowledgeIDs(t *testing.T) {
+	answer := &interfaces.CachedAnswer{
+		KnowledgeReferences: types.References{
+			{ID: "c1", KnowledgeID: "k1"},
+			{ID: "c2", KnowledgeID: "k2"},
+			{ID: "c3", KnowledgeID: "k1"},
+			{ID: "c4"},
+		},
+	}
+	ids := referencedKnowledgeIDs(answer)
+	if len(ids) != 2 || ids[0] != "k1" || ids[1] != "k2" {
+		t.Errorf("expected [k1 k2], got %v", ids)
+	}
+}
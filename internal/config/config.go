@@ -1,10 +1,13 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/Tencent/WeKnora/internal/types"
@@ -14,19 +17,67 @@ import (
 
 // Config 应用程序总配置
 type Config struct {
-	Conversation   *ConversationConfig   `yaml:"conversation" json:"conversation"`
-	Server         *ServerConfig         `yaml:"server" json:"server"`
-	KnowledgeBase  *KnowledgeBaseConfig  `yaml:"knowledge_base" json:"knowledge_base"`
-	Tenant         *TenantConfig         `yaml:"tenant" json:"tenant"`
-	Models         []ModelConfig         `yaml:"models" json:"models"`
-	VectorDatabase *VectorDatabaseConfig `yaml:"vector_database" json:"vector_database"`
-	DocReader      *DocReaderConfig      `yaml:"docreader" json:"docreader"`
-	StreamManager  *StreamManagerConfig  `yaml:"stream_manager" json:"stream_manager"`
-	ExtractManager *ExtractManagerConfig `yaml:"extract" json:"extract"`
+	Conversation   *ConversationConfig     `yaml:"conversation" json:"conversation"`
+	Server         *ServerConfig           `yaml:"server" json:"server"`
+	KnowledgeBase  *KnowledgeBaseConfig    `yaml:"knowledge_base" json:"knowledge_base"`
+	Ingestion      *IngestionConfig        `yaml:"ingestion" json:"ingestion"`
+	Tenant         *TenantConfig           `yaml:"tenant" json:"tenant"`
+	Models         []ModelConfig           `yaml:"models" json:"models"`
+	VectorDatabase *VectorDatabaseConfig   `yaml:"vector_database" json:"vector_database"`
+	DocReader      *DocReaderConfig        `yaml:"docreader" json:"docreader"`
+	StreamManager  *StreamManagerConfig    `yaml:"stream_manager" json:"stream_manager"`
+	ExtractManager *ExtractManagerConfig   `yaml:"extract" json:"extract"`
+	Pipelines      []*types.PipelineConfig `yaml:"pipelines" json:"pipelines"`
+
+	DatasetGeneration *DatasetGenerationConfig `yaml:"dataset_generation" json:"dataset_generation"`
+	Evaluation        *EvaluationConfig        `yaml:"evaluation" json:"evaluation"`
+
+	// conversation 热更新后的对话配置，未设置时使用Conversation
+	conversation atomic.Pointer[ConversationConfig]
+	// file 加载配置时使用的配置文件路径
+	file string
 }
 
 type DocReaderConfig struct {
-	Addr string `yaml:"addr" json:"addr"`
+	Addr    string            `yaml:"addr" json:"addr"`
+	Parsers map[string]string `yaml:"parsers" json:"parsers"` // 按文件类型选择解析方式：native、docreader或native_fallback，未配置的类型见ParserMode
+}
+
+// 文件的解析方式
+const (
+	ParserModeNative         = "native"          // 使用Go内置解析器
+	ParserModeDocReader      = "docreader"       // 使用docreader服务
+	ParserModeNativeFallback = "native_fallback" // 优先使用Go内置解析器，失败时使用docreader服务
+)
+
+// ParserMode 返回文件类型配置的解析方式，未配置时返回空字符串
+func (c *DocReaderConfig) ParserMode(fileType string) string {
+	if c == nil {
+		return ""
+	}
+	return c.Parsers[strings.ToLower(fileType)]
+}
+
+// Validate 检查各文件类型配置的解析方式，拒绝未知的解析方式
+func (c *DocReaderConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	fileTypes := make([]string, 0, len(c.Parsers))
+	for fileType := range c.Parsers {
+		fileTypes = append(fileTypes, fileType)
+	}
+	sort.Strings(fileTypes)
+	var errs []error
+	for _, fileType := range fileTypes {
+		switch mode := c.Parsers[fileType]; mode {
+		case ParserModeNative, ParserModeDocReader, ParserModeNativeFallback:
+		default:
+			errs = append(errs, fmt.Errorf("docreader.parsers.%s: unknown parser mode %q, expected %s, %s or %s",
+				fileType, mode, ParserModeNative, ParserModeDocReader, ParserModeNativeFallback))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 type VectorDatabaseConfig struct {
@@ -35,26 +86,88 @@ type VectorDatabaseConfig struct {
 
 // ConversationConfig 对话服务配置
 type ConversationConfig struct {
-	MaxRounds                  int            `yaml:"max_rounds" json:"max_rounds"`
-	KeywordThreshold           float64        `yaml:"keyword_threshold" json:"keyword_threshold"`
-	EmbeddingTopK              int            `yaml:"embedding_top_k" json:"embedding_top_k"`
-	VectorThreshold            float64        `yaml:"vector_threshold" json:"vector_threshold"`
-	RerankTopK                 int            `yaml:"rerank_top_k" json:"rerank_top_k"`
-	RerankThreshold            float64        `yaml:"rerank_threshold" json:"rerank_threshold"`
-	FallbackStrategy           string         `yaml:"fallback_strategy" json:"fallback_strategy"`
-	FallbackResponse           string         `yaml:"fallback_response" json:"fallback_response"`
-	FallbackPrompt             string         `yaml:"fallback_prompt" json:"fallback_prompt"`
-	EnableRewrite              bool           `yaml:"enable_rewrite" json:"enable_rewrite"`
-	EnableRerank               bool           `yaml:"enable_rerank" json:"enable_rerank"`
-	Summary                    *SummaryConfig `yaml:"summary" json:"summary"`
-	GenerateSessionTitlePrompt string         `yaml:"generate_session_title_prompt" json:"generate_session_title_prompt"`
-	GenerateSummaryPrompt      string         `yaml:"generate_summary_prompt" json:"generate_summary_prompt"`
-	RewritePromptSystem        string         `yaml:"rewrite_prompt_system" json:"rewrite_prompt_system"`
-	RewritePromptUser          string         `yaml:"rewrite_prompt_user" json:"rewrite_prompt_user"`
-	SimplifyQueryPrompt        string         `yaml:"simplify_query_prompt" json:"simplify_query_prompt"`
-	SimplifyQueryPromptUser    string         `yaml:"simplify_query_prompt_user" json:"simplify_query_prompt_user"`
-	ExtractEntitiesPrompt      string         `yaml:"extract_entities_prompt" json:"extract_entities_prompt"`
-	ExtractRelationshipsPrompt string         `yaml:"extract_relationships_prompt" json:"extract_relationships_prompt"`
+	MaxRounds                  int                   `yaml:"max_rounds" json:"max_rounds"`
+	KeywordThreshold           float64               `yaml:"keyword_threshold" json:"keyword_threshold"`
+	EmbeddingTopK              int                   `yaml:"embedding_top_k" json:"embedding_top_k"`
+	VectorThreshold            float64               `yaml:"vector_threshold" json:"vector_threshold"`
+	RerankTopK                 int                   `yaml:"rerank_top_k" json:"rerank_top_k"`
+	RerankThreshold            float64               `yaml:"rerank_threshold" json:"rerank_threshold"`
+	FallbackStrategy           string                `yaml:"fallback_strategy" json:"fallback_strategy"`
+	FallbackResponse           string                `yaml:"fallback_response" json:"fallback_response"`
+	FallbackPrompt             string                `yaml:"fallback_prompt" json:"fallback_prompt"`
+	EnableRewrite              bool                  `yaml:"enable_rewrite" json:"enable_rewrite"`
+	EnableRerank               bool                  `yaml:"enable_rerank" json:"enable_rerank"`
+	Summary                    *SummaryConfig        `yaml:"summary" json:"summary"`
+	GenerateSessionTitlePrompt string                `yaml:"generate_session_title_prompt" json:"generate_session_title_prompt"`
+	GenerateSummaryPrompt      string                `yaml:"generate_summary_prompt" json:"generate_summary_prompt"`
+	RewritePromptSystem        string                `yaml:"rewrite_prompt_system" json:"rewrite_prompt_system"`
+	RewritePromptUser          string                `yaml:"rewrite_prompt_user" json:"rewrite_prompt_user"`
+	SimplifyQueryPrompt        string                `yaml:"simplify_query_prompt" json:"simplify_query_prompt"`
+	SimplifyQueryPromptUser    string                `yaml:"simplify_query_prompt_user" json:"simplify_query_prompt_user"`
+	ExtractEntitiesPrompt      string                `yaml:"extract_entities_prompt" json:"extract_entities_prompt"`
+	ExtractRelationshipsPrompt string                `yaml:"extract_relationships_prompt" json:"extract_relationships_prompt"`
+	ContextWindow              int                   `yaml:"context_window" json:"context_window"`         // 送入模型的检索结果两侧各扩展的相邻Chunk数量
+	MaxContextTokens           int                   `yaml:"max_context_tokens" json:"max_context_tokens"` // 检索上下文的最大token数，0表示不限制
+	QueryExpansion             *QueryExpansionConfig `yaml:"query_expansion" json:"query_expansion"`
+	AnswerCache                *AnswerCacheConfig    `yaml:"answer_cache" json:"answer_cache"`
+	ContextBudget              *ContextBudgetConfig  `yaml:"context_budget" json:"context_budget"`
+	Citation                   *CitationConfig       `yaml:"citation" json:"citation"`
+	Grounding                  *GroundingConfig      `yaml:"grounding" json:"grounding"`
+	FAQ                        *FAQConfig            `yaml:"faq" json:"faq"`
+}
+
+// FAQConfig 问答对直接回答配置，检索命中问答对时直接返回其答案而不调用模型生成
+type FAQConfig struct {
+	DirectAnswer bool    `yaml:"direct_answer" json:"direct_answer"` // 是否启用问答对直接回答，查询与问题或相似问题一致时直接回答
+	Threshold    float64 `yaml:"threshold" json:"threshold"`         // 问答对检索分数不低于该值时也直接回答，0表示仅在问题一致时直接回答
+}
+
+// GroundingConfig 回答依据检查配置，生成回答后评估每个句子能否由检索到的分块支撑
+type GroundingConfig struct {
+	Enable            bool          `yaml:"enable" json:"enable"`                           // 是否启用回答依据检查
+	Method            string        `yaml:"method" json:"method"`                           // 评分方式：nli（由对话模型判断）或embedding（句子与分块的向量相似度）
+	Threshold         float64       `yaml:"threshold" json:"threshold"`                     // 依据分数低于该值时执行action
+	Action            string        `yaml:"action" json:"action"`                           // 低于阈值时的处理：none（仅记录）、disclaimer（追加免责声明）、fallback（替换为兜底回复）
+	Disclaimer        string        `yaml:"disclaimer" json:"disclaimer"`                   // 追加在回答后的免责声明
+	MinSentenceLength int           `yaml:"min_sentence_length" json:"min_sentence_length"` // 少于该字符数的句子不参与评分
+	Prompt            string        `yaml:"prompt" json:"prompt"`                           // nli评分提示词
+	Timeout           time.Duration `yaml:"timeout" json:"timeout"`                         // 评分超时时间，超时后不评分
+}
+
+// CitationConfig 回答内联引用配置，检索分块按[n]编号，模型在回答中用[n]标注引用来源
+type CitationConfig struct {
+	Enable bool   `yaml:"enable" json:"enable"` // 是否启用内联引用
+	Prompt string `yaml:"prompt" json:"prompt"` // 追加在用户消息后的引用标注要求
+}
+
+// ContextBudgetConfig 检索上下文的token预算配置，预算由对话模型的上下文长度减去系统提示词、历史对话和回复预留得到
+type ContextBudgetConfig struct {
+	DefaultContextLength    int           `yaml:"default_context_length" json:"default_context_length"`       // 模型未配置上下文长度时使用的默认值，0表示不限制
+	DefaultCompletionTokens int           `yaml:"default_completion_tokens" json:"default_completion_tokens"` // 未配置最大回复token数时为回复预留的token数
+	OverflowStrategy        string        `yaml:"overflow_strategy" json:"overflow_strategy"`                 // 超出预算的低排名分块的处理方式：trim（截断）或summarize（摘要）
+	MinTrimTokens           int           `yaml:"min_trim_tokens" json:"min_trim_tokens"`                     // 剩余预算不足该值时直接丢弃分块而不截断
+	SummarizePrompt         string        `yaml:"summarize_prompt" json:"summarize_prompt"`                   // 压缩超出预算分块的提示词
+	SummarizeTimeout        time.Duration `yaml:"summarize_timeout" json:"summarize_timeout"`                 // 压缩分块的超时时间，超时后改为截断
+}
+
+// AnswerCacheConfig 语义答案缓存配置，缓存存储在流管理器使用的Redis中
+type AnswerCacheConfig struct {
+	Enable              bool          `yaml:"enable" json:"enable"`                             // 是否启用答案缓存
+	SimilarityThreshold float64       `yaml:"similarity_threshold" json:"similarity_threshold"` // 命中缓存的最小查询向量余弦相似度
+	TTL                 time.Duration `yaml:"ttl" json:"ttl"`                                   // 缓存过期时间
+	MaxEntries          int           `yaml:"max_entries" json:"max_entries"`                   // 每个缓存范围内的最大条目数
+	MaxCandidates       int           `yaml:"max_candidates" json:"max_candidates"`             // 每次查询比较的最近条目数
+	Prefix              string        `yaml:"prefix" json:"prefix"`                             // Redis键前缀
+}
+
+// QueryExpansionConfig 查询扩展配置（多查询改写与HyDE）
+type QueryExpansionConfig struct {
+	Enable           bool          `yaml:"enable" json:"enable"`                         // 是否启用查询扩展
+	NumVariants      int           `yaml:"num_variants" json:"num_variants"`             // 生成的改写问题数量
+	EnableHyDE       bool          `yaml:"enable_hyde" json:"enable_hyde"`               // 是否生成假设答案用于检索
+	MultiQueryPrompt string        `yaml:"multi_query_prompt" json:"multi_query_prompt"` // 多查询改写提示词
+	HyDEPrompt       string        `yaml:"hyde_prompt" json:"hyde_prompt"`               // 假设答案生成提示词
+	Timeout          time.Duration `yaml:"timeout" json:"timeout"`                       // 查询扩展的延迟预算，超时后仅使用原查询
 }
 
 // SummaryConfig 摘要配置
@@ -79,6 +192,8 @@ type ServerConfig struct {
 	Host            string        `yaml:"host" json:"host"`
 	LogPath         string        `yaml:"log_path" json:"log_path"`
 	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" json:"shutdown_timeout" default:"30s"`
+	// OperatorToken 运维令牌，影响所有租户的操作（如回滚对话配置）须在X-Operator-Token请求头中携带，留空则禁用这些操作
+	OperatorToken string `yaml:"operator_token" json:"-"`
 }
 
 // KnowledgeBaseConfig 知识库配置
@@ -90,6 +205,42 @@ type KnowledgeBaseConfig struct {
 	ImageProcessing *ImageProcessingConfig `yaml:"image_processing" json:"image_processing"`
 }
 
+// IngestionConfig 文档导入任务配置，解析、分块、向量化和索引在asynq队列中执行
+type IngestionConfig struct {
+	Queue             string        `yaml:"queue" json:"queue"`                           // 任务所在的队列
+	MaxRetry          int           `yaml:"max_retry" json:"max_retry"`                   // 最大重试次数，用尽后任务进入死信队列
+	RetryBaseDelay    time.Duration `yaml:"retry_base_delay" json:"retry_base_delay"`     // 首次重试的延迟，之后每次翻倍
+	RetryMaxDelay     time.Duration `yaml:"retry_max_delay" json:"retry_max_delay"`       // 重试延迟上限
+	Timeout           time.Duration `yaml:"timeout" json:"timeout"`                       // 单次执行的超时时间
+	TenantConcurrency int           `yaml:"tenant_concurrency" json:"tenant_concurrency"` // 每个服务实例中单个租户同时执行的任务数，0表示不限制
+	TenantBusyDelay   time.Duration `yaml:"tenant_busy_delay" json:"tenant_busy_delay"`   // 租户并发已满时任务重新调度的延迟，不计入重试次数
+	SyncScanInterval  time.Duration `yaml:"sync_scan_interval" json:"sync_scan_interval"` // 检查URL知识是否到期重新同步的间隔，0表示关闭定时同步
+	KnowledgeVersions int           `yaml:"knowledge_versions" json:"knowledge_versions"` // 每条URL知识保留的历史版本数，0表示不限制
+}
+
+// DatasetGenerationConfig 评估数据集生成配置，从知识库抽样分块，由对话模型为每个分块生成问题和参考答案
+type DatasetGenerationConfig struct {
+	Prompt           string        `yaml:"prompt" json:"prompt"`                         // 生成问题和参考答案的提示词，分块内容作为用户消息
+	MaxQuestions     int           `yaml:"max_questions" json:"max_questions"`           // 单个任务最多生成的问题数
+	MinChunkLength   int           `yaml:"min_chunk_length" json:"min_chunk_length"`     // 少于该字符数的分块不用于生成问题
+	MinAnswerOverlap float64       `yaml:"min_answer_overlap" json:"min_answer_overlap"` // 参考答案的字符二元组出现在分块中的比例低于该值时丢弃该问题
+	Timeout          time.Duration `yaml:"timeout" json:"timeout"`                       // 单个分块生成问题的超时时间
+}
+
+// EvaluationConfig 评估配置
+type EvaluationConfig struct {
+	Judge *JudgeConfig `yaml:"judge" json:"judge"` // 裁判指标配置
+}
+
+// JudgeConfig 裁判指标配置，评估时指定裁判模型后，由裁判模型对每个问题的回答和检索结果评分
+type JudgeConfig struct {
+	FaithfulnessPrompt     string        `yaml:"faithfulness_prompt" json:"faithfulness_prompt"`           // 忠实度：回答中的陈述能否由检索结果推出
+	AnswerRelevancePrompt  string        `yaml:"answer_relevance_prompt" json:"answer_relevance_prompt"`   // 答案相关性：回答是否切中问题
+	ContextPrecisionPrompt string        `yaml:"context_precision_prompt" json:"context_precision_prompt"` // 上下文精确率：每个检索结果对回答问题是否有用
+	ContextRecallPrompt    string        `yaml:"context_recall_prompt" json:"context_recall_prompt"`       // 上下文召回率：参考答案中的陈述能否由检索结果推出
+	Timeout                time.Duration `yaml:"timeout" json:"timeout"`                                   // 单次评分的超时时间，超时的指标记为0分
+}
+
 // ImageProcessingConfig 图像处理配置
 type ImageProcessingConfig struct {
 	EnableMultimodal bool `yaml:"enable_multimodal" json:"enable_multimodal"`
@@ -157,8 +308,18 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("error reading config file: %w", err)
 	}
 
+	cfg, err := ParseConfigFile(viper.ConfigFileUsed())
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Using configuration file: %s\n", viper.ConfigFileUsed())
+	return cfg, nil
+}
+
+// ParseConfigFile 解析指定的配置文件，替换其中的${ENV_VAR}环境变量引用
+func ParseConfigFile(file string) (*Config, error) {
 	// 替换配置中的环境变量引用
-	configFileContent, err := os.ReadFile(viper.ConfigFileUsed())
+	configFileContent, err := os.ReadFile(file)
 	if err != nil {
 		return nil, fmt.Errorf("error reading config file content: %w", err)
 	}
@@ -175,16 +336,24 @@ func LoadConfig() (*Config, error) {
 		return match
 	})
 
-	// 使用处理后的配置内容
-	viper.ReadConfig(strings.NewReader(result))
+	// 使用处理后的配置内容，每次解析使用独立的viper实例，避免热更新时修改全局配置
+	v := viper.New()
+	v.SetConfigType("yaml")
+	v.AutomaticEnv()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	if err := v.ReadConfig(strings.NewReader(result)); err != nil {
+		return nil, fmt.Errorf("error parsing config file: %w", err)
+	}
 
 	// 解析配置到结构体
-	var cfg Config
-	if err := viper.Unmarshal(&cfg, func(dc *mapstructure.DecoderConfig) {
+	cfg := &Config{file: file}
+	if err := v.Unmarshal(cfg, func(dc *mapstructure.DecoderConfig) {
 		dc.TagName = "yaml"
 	}); err != nil {
 		return nil, fmt.Errorf("unable to decode config into struct: %w", err)
 	}
-	fmt.Printf("Using configuration file: %s\n", viper.ConfigFileUsed())
-	return &cfg, nil
+	if err := cfg.DocReader.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
 }
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDocReaderConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *DocReaderConfig
+		wantErr []string
+	}{
+		{name: "missing config"},
+		{
+			name: "known parser modes",
+			config: &DocReaderConfig{Parsers: map[string]string{
+				"txt": ParserModeNative, "pdf": ParserModeDocReader, "md": ParserModeNativeFallback,
+			}},
+		},
+		{
+			name:    "unknown parser modes",
+			config:  &DocReaderConfig{Parsers: map[string]string{"txt": "nativ", "md": "", "pdf": ParserModeDocReader}},
+			wantErr: []string{`docreader.parsers.md: unknown parser mode ""`, `docreader.parsers.txt: unknown parser mode "nativ"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() error = nil, expected %v", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %v, expected it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
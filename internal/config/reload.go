@@ -0,0 +1,111 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// File 返回加载配置时使用的配置文件路径
+func (c *Config) File() string {
+	return c.file
+}
+
+// GetConversation 返回当前生效的对话配置，配置热更新后返回最新版本
+// 调用方应在每次请求时读取，不要长期持有返回值
+func (c *Config) GetConversation() *ConversationConfig {
+	if conversation := c.conversation.Load(); conversation != nil {
+		return conversation
+	}
+	return c.Conversation
+}
+
+// SetConversation 原子替换当前生效的对话配置
+func (c *Config) SetConversation(conversation *ConversationConfig) {
+	c.conversation.Store(conversation)
+}
+
+// ValidateConversation 检查对话配置能否生效，所有字符串字段都必须是合法的text/template模板
+func ValidateConversation(conversation *ConversationConfig) error {
+	if conversation == nil {
+		return errors.New("conversation config is missing")
+	}
+	if conversation.Summary == nil {
+		return errors.New("conversation.summary is missing")
+	}
+	var errs []error
+	walkConversation(conversation, func(path string, value reflect.Value) {
+		if value.Kind() != reflect.String {
+			return
+		}
+		if _, err := template.New(path).Parse(value.String()); err != nil {
+			errs = append(errs, fmt.Errorf("invalid template %s: %w", path, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// DiffConversation 返回两个对话配置之间取值不同的字段，按yaml路径排序
+func DiffConversation(from, to *ConversationConfig) []*types.ConfigFieldDiff {
+	fromFields := flattenConversation(from)
+	toFields := flattenConversation(to)
+	diffs := make([]*types.ConfigFieldDiff, 0)
+	for path, old := range fromFields {
+		if value, ok := toFields[path]; !ok || value != old {
+			diffs = append(diffs, &types.ConfigFieldDiff{Path: path, Old: old, New: value})
+		}
+	}
+	for path, value := range toFields {
+		if _, ok := fromFields[path]; !ok {
+			diffs = append(diffs, &types.ConfigFieldDiff{Path: path, New: value})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Path < diffs[j].Path
+	})
+	return diffs
+}
+
+// flattenConversation 将对话配置展开为yaml路径到值的映射
+func flattenConversation(conversation *ConversationConfig) map[string]string {
+	fields := make(map[string]string)
+	walkConversation(conversation, func(path string, value reflect.Value) {
+		fields[path] = fmt.Sprint(value.Interface())
+	})
+	return fields
+}
+
+// walkConversation 遍历对话配置的叶子字段，path为以conversation开头的yaml路径，跳过未配置的子配置
+func walkConversation(conversation *ConversationConfig, visit func(path string, value reflect.Value)) {
+	if conversation == nil {
+		return
+	}
+	walkStruct("conversation", reflect.ValueOf(conversation).Elem(), visit)
+}
+
+func walkStruct(prefix string, value reflect.Value, visit func(path string, value reflect.Value)) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		path := prefix + "." + name
+		fieldValue := value.Field(i)
+		if fieldValue.Kind() == reflect.Pointer && fieldValue.Type().Elem().Kind() == reflect.Struct {
+			if !fieldValue.IsNil() {
+				walkStruct(path, fieldValue.Elem(), visit)
+			}
+			continue
+		}
+		visit(path, fieldValue)
+	}
+}
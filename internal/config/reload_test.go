@@ -0,0 +1,86 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+func TestValidateConversation(t *testing.T) {
+	tests := []struct {
+		name         string
+		conversation *ConversationConfig
+		wantErr      string
+	}{
+		{
+			name: "valid templates",
+			conversation: &ConversationConfig{
+				RewritePromptUser: "{{.Query}}",
+				Summary:           &SummaryConfig{ContextTemplate: "{{range .Contexts}}{{.}}{{end}}"},
+			},
+		},
+		{
+			name:         "missing summary",
+			conversation: &ConversationConfig{},
+			wantErr:      "conversation.summary is missing",
+		},
+		{
+			name: "invalid template in nested config",
+			conversation: &ConversationConfig{
+				Summary: &SummaryConfig{Prompt: "{{.Query"},
+			},
+			wantErr: "conversation.summary.prompt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConversation(tt.conversation)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateConversation() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateConversation() error = %v, expected it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConversationSwap(t *testing.T) {
+	cfg := &Config{Conversation: &ConversationConfig{MaxRounds: 5}}
+	if got := cfg.GetConversation().MaxRounds; got != 5 {
+		t.Fatalf("GetConversation().MaxRounds = %d, expected 5", got)
+	}
+	cfg.SetConversation(&ConversationConfig{MaxRounds: 3})
+	if got := cfg.GetConversation().MaxRounds; got != 3 {
+		t.Errorf("GetConversation().MaxRounds = %d after swap, expected 3", got)
+	}
+}
+
+func TestDiffConversation(t *testing.T) {
+	from := &ConversationConfig{
+		MaxRounds: 5,
+		Summary:   &SummaryConfig{Prompt: "旧提示词"},
+	}
+	to := &ConversationConfig{
+		MaxRounds: 5,
+		Summary:   &SummaryConfig{Prompt: "新提示词"},
+		Citation:  &CitationConfig{Enable: true},
+	}
+	expected := []*types.ConfigFieldDiff{
+		{Path: "conversation.citation.enable", New: "true"},
+		{Path: "conversation.citation.prompt", New: ""},
+		{Path: "conversation.summary.prompt", Old: "旧提示词", New: "新提示词"},
+	}
+	got := DiffConversation(from, to)
+	if !reflect.DeepEqual(got, expected) {
+		for _, diff := range got {
+			t.Logf("diff: %+v", *diff)
+		}
+		t.Errorf("DiffConversation() returned %d diffs, expected %d", len(got), len(expected))
+	}
+}
@@ -27,8 +27,10 @@ import (
 	postgresRepo "github.com/Tencent/WeKnora/internal/application/repository/retriever/postgres"
 	"github.com/Tencent/WeKnora/internal/application/service"
 	chatpipline "github.com/Tencent/WeKnora/internal/application/service/chat_pipline"
+	"github.com/Tencent/WeKnora/internal/application/service/crawler"
 	"github.com/Tencent/WeKnora/internal/application/service/file"
 	"github.com/Tencent/WeKnora/internal/application/service/retriever"
+	"github.com/Tencent/WeKnora/internal/cache"
 	"github.com/Tencent/WeKnora/internal/config"
 	"github.com/Tencent/WeKnora/internal/handler"
 	"github.com/Tencent/WeKnora/internal/logger"
@@ -72,11 +74,13 @@ func BuildContainer(container *dig.Container) *dig.Container {
 	must(container.Provide(initOllamaService))
 	must(container.Provide(initNeo4jClient))
 	must(container.Provide(stream.NewStreamManager))
+	must(container.Provide(cache.NewAnswerCache))
 
 	// Data repositories layer
 	must(container.Provide(repository.NewTenantRepository))
 	must(container.Provide(repository.NewKnowledgeBaseRepository))
 	must(container.Provide(repository.NewKnowledgeRepository))
+	must(container.Provide(repository.NewKnowledgeVersionRepository))
 	must(container.Provide(repository.NewChunkRepository))
 	must(container.Provide(repository.NewSessionRepository))
 	must(container.Provide(repository.NewMessageRepository))
@@ -84,6 +88,9 @@ func BuildContainer(container *dig.Container) *dig.Container {
 	must(container.Provide(repository.NewUserRepository))
 	must(container.Provide(repository.NewAuthTokenRepository))
 	must(container.Provide(repository.NewImportTaskRepository))
+	must(container.Provide(repository.NewConfigVersionRepository))
+	must(container.Provide(repository.NewEvaluationRepository))
+	must(container.Provide(repository.NewDatasetRepository))
 	must(container.Provide(neo4jRepo.NewNeo4jRepository))
 
 	// Business service layer
@@ -99,15 +106,22 @@ func BuildContainer(container *dig.Container) *dig.Container {
 	must(container.Provide(service.NewEvaluationService))
 	must(container.Provide(service.NewUserService))
 	must(container.Provide(service.NewChunkExtractService))
-	must(container.Provide(service.NewCrawlerService))
+	must(container.Provide(crawler.NewCrawlerService))
 	must(container.Provide(service.NewImportTaskService))
+	must(container.Provide(service.NewConfigRegistry))
+
+	// Start hot reloading of prompts and conversation defaults
+	must(container.Invoke(startConfigRegistry))
 
 	// Chat pipeline components for processing chat requests
 	must(container.Provide(chatpipline.NewEventManager))
 	must(container.Invoke(chatpipline.NewPluginTracing))
 	must(container.Invoke(chatpipline.NewPluginSearch))
+	// PluginFAQ reads the search results, it must be registered after PluginSearch
+	must(container.Invoke(chatpipline.NewPluginFAQ))
 	must(container.Invoke(chatpipline.NewPluginRerank))
 	must(container.Invoke(chatpipline.NewPluginMerge))
+	must(container.Invoke(chatpipline.NewPluginContextWindow))
 	must(container.Invoke(chatpipline.NewPluginIntoChatMessage))
 	must(container.Invoke(chatpipline.NewPluginChatCompletion))
 	must(container.Invoke(chatpipline.NewPluginChatCompletionStream))
@@ -115,8 +129,12 @@ func BuildContainer(container *dig.Container) *dig.Container {
 	must(container.Invoke(chatpipline.NewPluginFilterTopK))
 	must(container.Invoke(chatpipline.NewPluginPreprocess))
 	must(container.Invoke(chatpipline.NewPluginRewrite))
+	must(container.Invoke(chatpipline.NewPluginQueryExpansion))
 	must(container.Invoke(chatpipline.NewPluginExtractEntity))
 	must(container.Invoke(chatpipline.NewPluginSearchEntity))
+	must(container.Invoke(chatpipline.NewPluginAnswerGrounding))
+	// Validate that every configured pipeline can be served by the registered plugins
+	must(container.Invoke((*chatpipline.EventManager).CheckPipelines))
 
 	// HTTP handlers layer
 	must(container.Provide(handler.NewTenantHandler))
@@ -127,15 +145,24 @@ func BuildContainer(container *dig.Container) *dig.Container {
 	must(container.Provide(handler.NewMessageHandler))
 	must(container.Provide(handler.NewModelHandler))
 	must(container.Provide(handler.NewEvaluationHandler))
+	must(container.Provide(handler.NewDatasetHandler))
 	must(container.Provide(handler.NewInitializationHandler))
 	must(container.Provide(handler.NewAuthHandler))
 	must(container.Provide(handler.NewSystemHandler))
+	must(container.Provide(handler.NewConfigHandler))
 
 	// Router configuration
 	must(container.Provide(router.NewRouter))
 	must(container.Provide(router.NewAsyncqClient))
+	must(container.Provide(router.NewAsynqInspector))
 	must(container.Provide(router.NewAsynqServer))
 	must(container.Invoke(router.RunAsynqServer))
+	must(container.Invoke(router.RunAsynqScheduler))
+
+	// Requeue knowledge left without a document task by an earlier crash or restart
+	must(container.Invoke(recoverOrphanedKnowledge))
+	// Fail evaluations stopped by an earlier crash or restart
+	must(container.Invoke(recoverOrphanedEvaluations))
 
 	return container
 }
@@ -199,6 +226,15 @@ func initDatabase(cfg *config.Config) (*gorm.DB, error) {
 		&types.AuthToken{},
 		&types.KnowledgeBase{},
 		&types.ImportTask{},
+		&types.ImportTaskPage{},
+		&types.ConfigVersion{},
+		&types.KnowledgeVersion{},
+		&types.EvaluationTask{},
+		&types.EvaluationQuestion{},
+		&types.EvaluationSweep{},
+		&types.Dataset{},
+		&types.DatasetRecord{},
+		&types.DatasetGenerationTask{},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to auto-migrate database tables: %v", err)
@@ -371,6 +407,43 @@ func registerPoolCleanup(pool *ants.Pool, cleaner interfaces.ResourceCleaner) {
 	})
 }
 
+// startConfigRegistry records the startup config version and starts watching the config file
+// Parameters:
+//   - registry: Conversation config registry
+//   - cleaner: Resource cleaner used to stop watching on shutdown
+//
+// Returns:
+//   - Error if the config version cannot be recorded or the file cannot be watched
+func startConfigRegistry(registry interfaces.ConfigRegistry, cleaner interfaces.ResourceCleaner) error {
+	if err := registry.Start(context.Background()); err != nil {
+		return err
+	}
+	cleaner.RegisterWithName("ConfigRegistry", registry.Stop)
+	return nil
+}
+
+// recoverOrphanedKnowledge requeues or fails knowledge left pending or processing without a document task
+// Failures are logged and do not stop the server, the next start tries again
+// Parameters:
+//   - knowledgeService: Knowledge service that owns the document tasks
+func recoverOrphanedKnowledge(knowledgeService interfaces.KnowledgeService) {
+	ctx := context.Background()
+	if err := knowledgeService.RecoverOrphanedKnowledge(ctx); err != nil {
+		logger.Errorf(ctx, "Failed to recover orphaned knowledge: %v", err)
+	}
+}
+
+// recoverOrphanedEvaluations fails the evaluations left unfinished by a restart
+// Failures are logged and do not stop the server, the next start tries again
+// Parameters:
+//   - evaluationService: Evaluation service that runs the evaluations
+func recoverOrphanedEvaluations(evaluationService interfaces.EvaluationService) {
+	ctx := context.Background()
+	if err := evaluationService.RecoverOrphanedEvaluations(ctx); err != nil {
+		logger.Errorf(ctx, "Failed to recover orphaned evaluations: %v", err)
+	}
+}
+
 // initDocReaderClient initializes the document reader client
 // Creates a client for interacting with the document reader service
 // Parameters:
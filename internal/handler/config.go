@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	"github.com/Tencent/WeKnora/internal/application/service"
+	"github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigHandler handles requests for the version history of the hot reloadable conversation config
+type ConfigHandler struct {
+	registry interfaces.ConfigRegistry
+}
+
+// NewConfigHandler creates a new config handler
+func NewConfigHandler(registry interfaces.ConfigRegistry) *ConfigHandler {
+	return &ConfigHandler{registry: registry}
+}
+
+// ListConfigVersions lists the applied conversation config versions, newest first
+func (h *ConfigHandler) ListConfigVersions(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start retrieving config versions list")
+
+	var pagination types.Pagination
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		logger.Error(ctx, "Failed to parse pagination parameters", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+
+	result, err := h.registry.ListVersions(ctx, &pagination)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(errors.NewInternalServerError("Failed to retrieve config versions").WithDetails(err.Error()))
+		return
+	}
+
+	logger.Infof(ctx, "Config versions list retrieved successfully, total: %d", result.Total)
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"data":      result.Data,
+		"total":     result.Total,
+		"page":      result.Page,
+		"page_size": result.PageSize,
+	})
+}
+
+// GetConfigVersion gets a conversation config version with its content
+func (h *ConfigHandler) GetConfigVersion(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start retrieving config version")
+
+	id, ok := parseConfigVersionID(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	version, err := h.registry.GetVersion(ctx, id)
+	if err != nil {
+		c.Error(configVersionError(ctx, "Failed to retrieve config version", err))
+		return
+	}
+
+	logger.Infof(ctx, "Config version retrieved successfully, version: %d", version.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    version,
+	})
+}
+
+// DiffConfigVersions returns the fields that differ between the versions given by the from and to query parameters
+func (h *ConfigHandler) DiffConfigVersions(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start diffing config versions")
+
+	from, ok := parseConfigVersionID(c, c.Query("from"))
+	if !ok {
+		return
+	}
+	to, ok := parseConfigVersionID(c, c.Query("to"))
+	if !ok {
+		return
+	}
+
+	diffs, err := h.registry.DiffVersions(ctx, from, to)
+	if err != nil {
+		c.Error(configVersionError(ctx, "Failed to diff config versions", err))
+		return
+	}
+
+	logger.Infof(ctx, "Config versions diffed successfully, from: %d, to: %d, changed fields: %d",
+		from, to, len(diffs))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    diffs,
+	})
+}
+
+// RollbackConfigVersion applies the content of an earlier version as a new version
+func (h *ConfigHandler) RollbackConfigVersion(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start rolling back config version")
+
+	id, ok := parseConfigVersionID(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	version, err := h.registry.Rollback(ctx, id)
+	if err != nil {
+		if err == service.ErrConfigVersionActive {
+			c.Error(errors.NewConflictError(err.Error()))
+			return
+		}
+		c.Error(configVersionError(ctx, "Failed to roll back config version", err))
+		return
+	}
+
+	logger.Infof(ctx, "Config version rolled back successfully, restored: %d, version: %d", id, version.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    version,
+	})
+}
+
+// parseConfigVersionID parses a version ID, it writes a bad request error and returns false if it is invalid
+func parseConfigVersionID(c *gin.Context, value string) (uint, bool) {
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || id == 0 {
+		logger.Errorf(c.Request.Context(), "Invalid config version ID: %s", value)
+		c.Error(errors.NewBadRequestError("Invalid config version ID"))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// configVersionError maps an error of the config registry to an application error
+func configVersionError(ctx context.Context, message string, err error) *errors.AppError {
+	if err == repository.ErrConfigVersionNotFound {
+		return errors.NewNotFoundError(err.Error())
+	}
+	logger.ErrorWithFields(ctx, err, nil)
+	return errors.NewInternalServerError(message).WithDetails(err.Error())
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	"github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/gin-gonic/gin"
+)
+
+// DatasetHandler handles requests for the evaluation datasets of a tenant
+type DatasetHandler struct {
+	datasetService interfaces.DatasetService
+}
+
+// NewDatasetHandler creates a new dataset handler
+func NewDatasetHandler(datasetService interfaces.DatasetService) *DatasetHandler {
+	return &DatasetHandler{datasetService: datasetService}
+}
+
+// CreateDataset creates a dataset from an uploaded parquet, JSONL or CSV file
+func (h *DatasetHandler) CreateDataset(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start creating dataset")
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		logger.Error(ctx, "File upload failed", err)
+		c.Error(errors.NewBadRequestError("File upload failed").WithDetails(err.Error()))
+		return
+	}
+	logger.Infof(ctx, "File upload successful, filename: %s, size: %.2f KB", file.Filename, float64(file.Size)/1024)
+
+	dataset, err := h.datasetService.CreateDataset(ctx, c.PostForm("name"), c.PostForm("description"), file)
+	if err != nil {
+		c.Error(datasetError(ctx, err))
+		return
+	}
+
+	logger.Infof(ctx, "Dataset created successfully, ID: %s", dataset.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    dataset,
+	})
+}
+
+// ListDatasets lists the datasets of the tenant, newest first
+func (h *DatasetHandler) ListDatasets(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start retrieving datasets list")
+
+	var pagination types.Pagination
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		logger.Error(ctx, "Failed to parse pagination parameters", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+
+	result, err := h.datasetService.ListDatasets(ctx, &pagination)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(errors.NewInternalServerError("Failed to retrieve datasets").WithDetails(err.Error()))
+		return
+	}
+
+	logger.Infof(ctx, "Datasets list retrieved successfully, total: %d", result.Total)
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"data":      result.Data,
+		"total":     result.Total,
+		"page":      result.Page,
+		"page_size": result.PageSize,
+	})
+}
+
+// GetDataset gets a dataset without its records
+func (h *DatasetHandler) GetDataset(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start retrieving dataset")
+
+	dataset, err := h.datasetService.GetDataset(ctx, c.Param("id"))
+	if err != nil {
+		c.Error(datasetError(ctx, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    dataset,
+	})
+}
+
+// DeleteDataset deletes a dataset
+func (h *DatasetHandler) DeleteDataset(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start deleting dataset")
+
+	id := c.Param("id")
+	if err := h.datasetService.DeleteDataset(ctx, id); err != nil {
+		c.Error(datasetError(ctx, err))
+		return
+	}
+
+	logger.Infof(ctx, "Dataset deleted successfully, ID: %s", id)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Dataset deleted successfully",
+	})
+}
+
+// GenerateDatasetRequest is the request to generate a dataset from a knowledge base
+type GenerateDatasetRequest struct {
+	KnowledgeBaseID string `json:"knowledge_base_id" binding:"required"`
+	ChatID          string `json:"chat_id" binding:"required"`
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	QuestionCount   int    `json:"question_count"`
+}
+
+// GenerateDataset creates a task generating a dataset from the chunks of a knowledge base
+func (h *DatasetHandler) GenerateDataset(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start creating dataset generation task")
+
+	var req GenerateDatasetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(ctx, "Failed to parse dataset generation request", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+
+	task := &types.DatasetGenerationTask{
+		KnowledgeBaseID: req.KnowledgeBaseID,
+		ChatModelID:     req.ChatID,
+		Name:            req.Name,
+		Description:     req.Description,
+		QuestionCount:   req.QuestionCount,
+	}
+	// The questions are generated in an asynq task, the task reports its progress until the dataset is stored
+	if err := h.datasetService.GenerateDataset(ctx, task); err != nil {
+		c.Error(datasetError(ctx, err))
+		return
+	}
+
+	logger.Infof(ctx, "Dataset generation task created successfully, task ID: %s", task.ID)
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Dataset generation task created successfully",
+		"data":    task,
+	})
+}
+
+// ListGenerationTasks lists the dataset generation tasks of the tenant, newest first
+func (h *DatasetHandler) ListGenerationTasks(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start retrieving dataset generation tasks list")
+
+	var pagination types.Pagination
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		logger.Error(ctx, "Failed to parse pagination parameters", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+
+	result, err := h.datasetService.ListGenerationTasks(ctx, &pagination)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(errors.NewInternalServerError("Failed to retrieve dataset generation tasks").WithDetails(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"data":      result.Data,
+		"total":     result.Total,
+		"page":      result.Page,
+		"page_size": result.PageSize,
+	})
+}
+
+// GetGenerationTask gets a dataset generation task with its progress
+func (h *DatasetHandler) GetGenerationTask(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start retrieving dataset generation task")
+
+	task, err := h.datasetService.GetGenerationTask(ctx, c.Param("task_id"))
+	if err != nil {
+		c.Error(datasetError(ctx, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    task,
+	})
+}
+
+// CancelGenerationTask cancels a pending or processing dataset generation task
+func (h *DatasetHandler) CancelGenerationTask(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start cancelling dataset generation task")
+
+	taskID := c.Param("task_id")
+	if err := h.datasetService.CancelGenerationTask(ctx, taskID); err != nil {
+		c.Error(datasetError(ctx, err))
+		return
+	}
+
+	logger.Infof(ctx, "Dataset generation task cancelled successfully, task ID: %s", taskID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Dataset generation task cancelled successfully",
+	})
+}
+
+// datasetError maps an error of the dataset service to an application error
+func datasetError(ctx context.Context, err error) *errors.AppError {
+	if appErr, ok := errors.IsAppError(err); ok {
+		return appErr
+	}
+	if err == repository.ErrDatasetNotFound || err == repository.ErrDatasetGenerationTaskNotFound {
+		return errors.NewNotFoundError(err.Error())
+	}
+	logger.ErrorWithFields(ctx, err, nil)
+	return errors.NewInternalServerError(err.Error())
+}
@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"context"
 	"net/http"
 
+	"github.com/Tencent/WeKnora/internal/application/repository"
 	"github.com/Tencent/WeKnora/internal/errors"
 	"github.com/Tencent/WeKnora/internal/logger"
 	"github.com/Tencent/WeKnora/internal/types"
@@ -26,6 +28,8 @@ type EvaluationRequest struct {
 	KnowledgeBaseID string `json:"knowledge_base_id"` // ID of knowledge base to use
 	ChatModelID     string `json:"chat_id"`           // ID of chat model to use
 	RerankModelID   string `json:"rerank_id"`         // ID of rerank model to use
+	UseExistingKB   bool   `json:"use_existing_kb"`   // Evaluate the knowledge base as it is instead of a fresh copy
+	JudgeModelID    string `json:"judge_id"`          // ID of chat model scoring the judge metrics, empty to skip them
 }
 
 // Evaluation handles evaluation request
@@ -48,18 +52,22 @@ func (e *EvaluationHandler) Evaluation(c *gin.Context) {
 		return
 	}
 
-	logger.Infof(ctx, "Executing evaluation, tenant: %v, dataset: %s, knowledge_base: %s, chat: %s, rerank: %s",
-		tenantID, request.DatasetID, request.KnowledgeBaseID, request.ChatModelID, request.RerankModelID)
+	logger.Infof(ctx,
+		"Executing evaluation, tenant: %v, dataset: %s, knowledge_base: %s, chat: %s, rerank: %s, existing KB: %v, "+
+			"judge: %s",
+		tenantID, request.DatasetID, request.KnowledgeBaseID, request.ChatModelID, request.RerankModelID,
+		request.UseExistingKB, request.JudgeModelID)
 
 	task, err := e.evaluationService.Evaluation(ctx,
 		request.DatasetID,
 		request.KnowledgeBaseID,
 		request.ChatModelID,
 		request.RerankModelID,
+		request.UseExistingKB,
+		request.JudgeModelID,
 	)
 	if err != nil {
-		logger.ErrorWithFields(ctx, err, nil)
-		c.Error(errors.NewInternalServerError(err.Error()))
+		c.Error(evaluationError(ctx, err))
 		return
 	}
 
@@ -92,8 +100,7 @@ func (e *EvaluationHandler) GetEvaluationResult(c *gin.Context) {
 
 	result, err := e.evaluationService.EvaluationResult(ctx, request.TaskID)
 	if err != nil {
-		logger.ErrorWithFields(ctx, err, nil)
-		c.Error(errors.NewInternalServerError(err.Error()))
+		c.Error(evaluationError(ctx, err))
 		return
 	}
 
@@ -103,3 +110,169 @@ func (e *EvaluationHandler) GetEvaluationResult(c *gin.Context) {
 		"data":    result,
 	})
 }
+
+// ListEvaluations lists the evaluation tasks of the tenant with their parameters and metrics, newest first
+func (e *EvaluationHandler) ListEvaluations(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start retrieving evaluation tasks list")
+
+	var pagination types.Pagination
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		logger.Error(ctx, "Failed to parse pagination parameters", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+
+	result, err := e.evaluationService.ListEvaluations(ctx, &pagination)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(errors.NewInternalServerError("Failed to retrieve evaluation tasks").WithDetails(err.Error()))
+		return
+	}
+
+	logger.Infof(ctx, "Evaluation tasks list retrieved successfully, total: %d", result.Total)
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"data":      result.Data,
+		"total":     result.Total,
+		"page":      result.Page,
+		"page_size": result.PageSize,
+	})
+}
+
+// CompareEvaluationsRequest contains the tasks to compare
+type CompareEvaluationsRequest struct {
+	Base   string `form:"base" binding:"required"`   // ID of the base evaluation task
+	Target string `form:"target" binding:"required"` // ID of the evaluation task compared with the base
+}
+
+// CompareEvaluations compares two evaluation tasks metric by metric and question by question
+func (e *EvaluationHandler) CompareEvaluations(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start comparing evaluation tasks")
+
+	var request CompareEvaluationsRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		logger.Error(ctx, "Failed to parse request parameters", err)
+		c.Error(errors.NewBadRequestError("Invalid request parameters").WithDetails(err.Error()))
+		return
+	}
+
+	comparison, err := e.evaluationService.CompareEvaluations(ctx, request.Base, request.Target)
+	if err != nil {
+		c.Error(evaluationError(ctx, err))
+		return
+	}
+
+	logger.Infof(ctx, "Evaluation tasks compared successfully, base: %s, target: %s", request.Base, request.Target)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    comparison,
+	})
+}
+
+// SweepRequest contains the parameters of a retrieval-only parameter sweep
+type SweepRequest struct {
+	DatasetID       string           `json:"dataset_id"`        // ID of dataset to evaluate
+	KnowledgeBaseID string           `json:"knowledge_base_id"` // ID of knowledge base to use
+	RerankModelID   string           `json:"rerank_id"`         // ID of rerank model to use
+	UseExistingKB   bool             `json:"use_existing_kb"`   // Search the knowledge base as it is instead of a fresh copy
+	Mode            types.SweepMode  `json:"mode"`              // grid or random, grid by default
+	Samples         int              `json:"samples"`           // Number of settings tried in random mode
+	Seed            int64            `json:"seed"`              // Seed of the random sample
+	Space           types.SweepSpace `json:"space"`             // Values tried for each retrieval parameter
+}
+
+// StartSweep starts a retrieval-only parameter sweep
+func (e *EvaluationHandler) StartSweep(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start processing evaluation sweep request")
+
+	var request SweepRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Error(ctx, "Failed to parse request parameters", err)
+		c.Error(errors.NewBadRequestError("Invalid request parameters").WithDetails(err.Error()))
+		return
+	}
+
+	sweep, err := e.evaluationService.StartSweep(ctx, &types.EvaluationSweep{
+		DatasetID:       request.DatasetID,
+		KnowledgeBaseID: request.KnowledgeBaseID,
+		RerankModelID:   request.RerankModelID,
+		UseExistingKB:   request.UseExistingKB,
+		Mode:            request.Mode,
+		Samples:         request.Samples,
+		Seed:            request.Seed,
+		Space:           request.Space,
+	})
+	if err != nil {
+		c.Error(evaluationError(ctx, err))
+		return
+	}
+
+	logger.Infof(ctx, "Evaluation sweep created successfully, sweep ID: %s, trials: %d", sweep.ID, sweep.Total)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    sweep,
+	})
+}
+
+// GetSweep gets an evaluation sweep with its trials and recommended settings
+func (e *EvaluationHandler) GetSweep(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	logger.Infof(ctx, "Retrieving evaluation sweep, sweep ID: %s", id)
+
+	sweep, err := e.evaluationService.GetSweep(ctx, id)
+	if err != nil {
+		c.Error(evaluationError(ctx, err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    sweep,
+	})
+}
+
+// ListSweeps lists the evaluation sweeps of the tenant, newest first
+func (e *EvaluationHandler) ListSweeps(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start retrieving evaluation sweeps list")
+
+	var pagination types.Pagination
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		logger.Error(ctx, "Failed to parse pagination parameters", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+
+	result, err := e.evaluationService.ListSweeps(ctx, &pagination)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(errors.NewInternalServerError("Failed to retrieve evaluation sweeps").WithDetails(err.Error()))
+		return
+	}
+
+	logger.Infof(ctx, "Evaluation sweeps list retrieved successfully, total: %d", result.Total)
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"data":      result.Data,
+		"total":     result.Total,
+		"page":      result.Page,
+		"page_size": result.PageSize,
+	})
+}
+
+// evaluationError maps an error of the evaluation service to an application error
+func evaluationError(ctx context.Context, err error) *errors.AppError {
+	if appErr, ok := errors.IsAppError(err); ok {
+		return appErr
+	}
+	if err == repository.ErrEvaluationTaskNotFound || err == repository.ErrEvaluationSweepNotFound ||
+		err == repository.ErrDatasetNotFound {
+		return errors.NewNotFoundError(err.Error())
+	}
+	logger.ErrorWithFields(ctx, err, nil)
+	return errors.NewInternalServerError(err.Error())
+}
@@ -133,6 +133,8 @@ type InitializationRequest struct {
 		ChunkSize    int      `json:"chunkSize" binding:"required,min=100,max=10000"`
 		ChunkOverlap int      `json:"chunkOverlap" binding:"min=0"`
 		Separators   []string `json:"separators" binding:"required,min=1"`
+		// 分块策略，为空时使用 recursive
+		Strategy types.ChunkingStrategy `json:"strategy"`
 	} `json:"documentSplitting" binding:"required"`
 
 	NodeExtract struct {
@@ -223,6 +225,14 @@ func (h *InitializationHandler) InitializeByKB(c *gin.Context) {
 		}
 	}
 
+	// 验证分块策略
+	splitting := types.ChunkingConfig{Strategy: req.DocumentSplitting.Strategy}
+	if err := splitting.Validate(); err != nil {
+		logger.Error(ctx, "Invalid chunking strategy", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+
 	// 验证Node Extractor配置（如果启用）
 	if strings.ToLower(os.Getenv("NEO4J_ENABLE")) != "true" && req.NodeExtract.Enabled {
 		logger.Error(ctx, "Node Extractor configuration incomplete")
@@ -399,6 +409,7 @@ func (h *InitializationHandler) InitializeByKB(c *gin.Context) {
 		ChunkSize:    req.DocumentSplitting.ChunkSize,
 		ChunkOverlap: req.DocumentSplitting.ChunkOverlap,
 		Separators:   req.DocumentSplitting.Separators,
+		Strategy:     req.DocumentSplitting.Strategy,
 	}
 
 	// 更新多模态配置
@@ -997,6 +1008,7 @@ func buildConfigResponse(models []*types.Model,
 			"chunkSize":    kb.ChunkingConfig.ChunkSize,
 			"chunkOverlap": kb.ChunkingConfig.ChunkOverlap,
 			"separators":   kb.ChunkingConfig.Separators,
+			"strategy":     kb.ChunkingConfig.GetStrategy(),
 		}
 
 		// 添加多模态的COS配置信息
@@ -7,7 +7,11 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	"github.com/Tencent/WeKnora/internal/application/service"
 	"github.com/Tencent/WeKnora/internal/errors"
 	"github.com/Tencent/WeKnora/internal/logger"
 	"github.com/Tencent/WeKnora/internal/types"
@@ -126,6 +130,16 @@ func (h *KnowledgeHandler) CreateKnowledgeFromFile(c *gin.Context) {
 		logger.Infof(ctx, "Received file metadata: %v", metadata)
 	}
 
+	// Parse access labels if provided
+	var accessLabels types.AccessLabels
+	if accessLabelsStr := c.PostForm("access_labels"); accessLabelsStr != "" {
+		if err := json.Unmarshal([]byte(accessLabelsStr), &accessLabels); err != nil {
+			logger.Error(ctx, "Failed to parse access labels", err)
+			c.Error(errors.NewBadRequestError("Invalid access_labels format").WithDetails(err.Error()))
+			return
+		}
+	}
+
 	enableMultimodelForm := c.PostForm("enable_multimodel")
 	var enableMultimodel *bool
 	if enableMultimodelForm != "" {
@@ -139,7 +153,7 @@ func (h *KnowledgeHandler) CreateKnowledgeFromFile(c *gin.Context) {
 	}
 
 	// Create knowledge entry from the file
-	knowledge, err := h.kgService.CreateKnowledgeFromFile(ctx, kbID, file, metadata, enableMultimodel)
+	knowledge, err := h.kgService.CreateKnowledgeFromFile(ctx, kbID, file, metadata, enableMultimodel, accessLabels)
 	// Check for duplicate knowledge error
 	if err != nil {
 		if h.handleDuplicateKnowledgeError(c, err, knowledge, "file") {
@@ -175,8 +189,9 @@ func (h *KnowledgeHandler) CreateKnowledgeFromURL(c *gin.Context) {
 
 	// Parse URL from request body
 	var req struct {
-		URL              string `json:"url" binding:"required"`
-		EnableMultimodel *bool  `json:"enable_multimodel"`
+		URL              string             `json:"url" binding:"required"`
+		EnableMultimodel *bool              `json:"enable_multimodel"`
+		AccessLabels     types.AccessLabels `json:"access_labels"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Error(ctx, "Failed to parse URL request", err)
@@ -188,7 +203,7 @@ func (h *KnowledgeHandler) CreateKnowledgeFromURL(c *gin.Context) {
 	logger.Infof(ctx, "Creating knowledge from URL, knowledge base ID: %s, URL: %s", kbID, req.URL)
 
 	// Create knowledge entry from the URL
-	knowledge, err := h.kgService.CreateKnowledgeFromURL(ctx, kbID, req.URL, req.EnableMultimodel)
+	knowledge, err := h.kgService.CreateKnowledgeFromURL(ctx, kbID, req.URL, req.EnableMultimodel, req.AccessLabels)
 	// Check for duplicate knowledge error
 	if err != nil {
 		if h.handleDuplicateKnowledgeError(c, err, knowledge, "url") {
@@ -408,6 +423,39 @@ func (h *KnowledgeHandler) GetKnowledgeBatch(c *gin.Context) {
 	})
 }
 
+// DeleteKnowledgeBatchRequest defines parameters for batch knowledge deletion
+type DeleteKnowledgeBatchRequest struct {
+	IDs []string `form:"ids" binding:"required"` // List of knowledge IDs
+}
+
+// DeleteKnowledgeBatch handles requests to delete multiple knowledge entries, e.g. near-duplicates
+func (h *KnowledgeHandler) DeleteKnowledgeBatch(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	logger.Info(ctx, "Start batch deleting knowledge")
+
+	// Parse request parameters from query string
+	var req DeleteKnowledgeBatchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		logger.Error(ctx, "Failed to parse request parameters", err)
+		c.Error(errors.NewBadRequestError("Invalid request parameters").WithDetails(err.Error()))
+		return
+	}
+
+	logger.Infof(ctx, "Batch deleting knowledge, number of knowledge IDs: %d", len(req.IDs))
+	if err := h.kgService.DeleteKnowledgeList(ctx, req.IDs); err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(errors.NewInternalServerError("Failed to delete knowledge list").WithDetails(err.Error()))
+		return
+	}
+
+	logger.Infof(ctx, "Batch knowledge deletion successful, count: %d", len(req.IDs))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Deleted successfully",
+	})
+}
+
 func (h *KnowledgeHandler) UpdateKnowledge(c *gin.Context) {
 	ctx := c.Request.Context()
 	logger.Info(ctx, "Start Update knowledge")
@@ -426,8 +474,17 @@ func (h *KnowledgeHandler) UpdateKnowledge(c *gin.Context) {
 		c.Error(errors.NewBadRequestError(err.Error()))
 		return
 	}
+	knowledge.ID = id
 
 	if err := h.kgService.UpdateKnowledge(ctx, &knowledge); err != nil {
+		if err == service.ErrKnowledgeProcessing {
+			c.Error(errors.NewConflictError("Access labels cannot change while the knowledge is being processed"))
+			return
+		}
+		if appErr, ok := errors.IsAppError(err); ok {
+			c.Error(appErr)
+			return
+		}
 		logger.ErrorWithFields(ctx, err, nil)
 		c.Error(errors.NewInternalServerError(err.Error()))
 		return
@@ -499,9 +556,12 @@ func (h *KnowledgeHandler) CreateImportTask(c *gin.Context) {
 
 	// Parse request body
 	var req struct {
-		BaseURL          string `json:"base_url" binding:"required"`
-		MaxPages         int    `json:"max_pages"`
-		EnableMultimodel *bool  `json:"enable_multimodel"`
+		BaseURL          string   `json:"base_url" binding:"required"`
+		MaxPages         int      `json:"max_pages"`
+		EnableMultimodel *bool    `json:"enable_multimodel"`
+		Schedule         string   `json:"schedule"`
+		IncludePatterns  []string `json:"include_patterns"`
+		ExcludePatterns  []string `json:"exclude_patterns"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Error(ctx, "Failed to parse import task request", err)
@@ -524,6 +584,13 @@ func (h *KnowledgeHandler) CreateImportTask(c *gin.Context) {
 	config := types.ImportTaskConfig{
 		MaxPages:         req.MaxPages,
 		EnableMultimodel: req.EnableMultimodel,
+		IncludePatterns:  req.IncludePatterns,
+		ExcludePatterns:  req.ExcludePatterns,
+	}
+	if err := config.Validate(); err != nil {
+		logger.Error(ctx, "Invalid import task config", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
 	}
 	configJSON, _ := json.Marshal(config)
 
@@ -534,28 +601,21 @@ func (h *KnowledgeHandler) CreateImportTask(c *gin.Context) {
 		Status:          types.ImportTaskStatusPending,
 		Config:          configJSON,
 		Results:         []byte("[]"),
+		Schedule:        strings.TrimSpace(req.Schedule),
+	}
+	if _, err := task.NextRun(time.Now()); err != nil {
+		logger.Error(ctx, "Invalid import task schedule", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
 	}
 
+	// The crawl runs as an asynq task, recurring tasks are run again at every occurrence of the schedule
 	if err := h.importTaskService.CreateTask(ctx, task); err != nil {
 		logger.ErrorWithFields(ctx, err, nil)
 		c.Error(errors.NewInternalServerError("Failed to create import task").WithDetails(err.Error()))
 		return
 	}
 
-	// Create a context with tenant information for the background task
-	tenantInfo, exists := c.Get(types.TenantInfoContextKey.String())
-	if !exists {
-		logger.Error(ctx, "Tenant info not found in context")
-		c.Error(errors.NewInternalServerError("Failed to get tenant info"))
-		return
-	}
-	taskCtx := context.WithValue(
-		context.WithValue(context.Background(), types.TenantIDContextKey, tenantID),
-		types.TenantInfoContextKey, tenantInfo,
-	)
-	
-	go h.importTaskService.ProcessTask(taskCtx, task)
-
 	logger.Infof(ctx, "Import task created successfully, task ID: %s", task.ID)
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
@@ -615,6 +675,178 @@ func (h *KnowledgeHandler) CancelImportTask(c *gin.Context) {
 	})
 }
 
+// ListDeadLetterTasks lists the document tasks of the tenant that failed permanently or ran out of retries
+func (h *KnowledgeHandler) ListDeadLetterTasks(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start retrieving dead letter document tasks")
+
+	var pagination types.Pagination
+	if err := c.ShouldBindQuery(&pagination); err != nil {
+		logger.Error(ctx, "Failed to parse pagination parameters", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+
+	result, err := h.kgService.ListDeadLetterTasks(ctx, &pagination)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(errors.NewInternalServerError("Failed to retrieve dead letter tasks").WithDetails(err.Error()))
+		return
+	}
+
+	logger.Infof(ctx, "Dead letter document tasks retrieved successfully, total: %d", result.Total)
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"data":      result.Data,
+		"total":     result.Total,
+		"page":      result.Page,
+		"page_size": result.PageSize,
+	})
+}
+
+// RetryDeadLetterTask runs a dead letter document task again
+func (h *KnowledgeHandler) RetryDeadLetterTask(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start retrying dead letter document task")
+
+	taskID := c.Param("task_id")
+	if err := h.kgService.RetryDeadLetterTask(ctx, taskID); err != nil {
+		if err == service.ErrDeadLetterTaskNotFound {
+			c.Error(errors.NewNotFoundError(err.Error()))
+			return
+		}
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(errors.NewInternalServerError("Failed to retry dead letter task").WithDetails(err.Error()))
+		return
+	}
+
+	logger.Infof(ctx, "Dead letter document task retried successfully, task ID: %s", taskID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Dead letter task scheduled successfully",
+	})
+}
+
+// RefreshKnowledge re-fetches URL knowledge, it is reindexed in the background if its content changed
+func (h *KnowledgeHandler) RefreshKnowledge(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start refreshing knowledge")
+
+	id := c.Param("id")
+	knowledge, err := h.kgService.RefreshKnowledge(ctx, id)
+	if err != nil {
+		c.Error(knowledgeVersionError(ctx, "Failed to refresh knowledge", err))
+		return
+	}
+
+	logger.Infof(ctx, "Knowledge refresh scheduled successfully, ID: %s", knowledge.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    knowledge,
+	})
+}
+
+// ReplaceKnowledgeFile uploads a new file for file knowledge, it is parsed again in the background
+// and only the chunks that changed are embedded
+func (h *KnowledgeHandler) ReplaceKnowledgeFile(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start replacing knowledge file")
+
+	id := c.Param("id")
+	file, err := c.FormFile("file")
+	if err != nil {
+		logger.Error(ctx, "File upload failed", err)
+		c.Error(errors.NewBadRequestError("File upload failed").WithDetails(err.Error()))
+		return
+	}
+
+	var enableMultimodel *bool
+	if enableMultimodelForm := c.PostForm("enable_multimodel"); enableMultimodelForm != "" {
+		parseBool, err := strconv.ParseBool(enableMultimodelForm)
+		if err != nil {
+			logger.Error(ctx, "Failed to parse enable_multimodel", err)
+			c.Error(errors.NewBadRequestError("Invalid enable_multimodel format").WithDetails(err.Error()))
+			return
+		}
+		enableMultimodel = &parseBool
+	}
+
+	knowledge, err := h.kgService.ReplaceKnowledgeFile(ctx, id, file, enableMultimodel)
+	if err != nil {
+		c.Error(knowledgeVersionError(ctx, "Failed to replace knowledge file", err))
+		return
+	}
+
+	logger.Infof(ctx, "Knowledge file replacement scheduled successfully, ID: %s, filename: %s",
+		knowledge.ID, file.Filename)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    knowledge,
+	})
+}
+
+// ListKnowledgeVersions lists the earlier content versions of URL and file knowledge
+func (h *KnowledgeHandler) ListKnowledgeVersions(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start retrieving knowledge versions")
+
+	id := c.Param("id")
+	versions, err := h.kgService.ListKnowledgeVersions(ctx, id)
+	if err != nil {
+		c.Error(knowledgeVersionError(ctx, "Failed to retrieve knowledge versions", err))
+		return
+	}
+
+	logger.Infof(ctx, "Knowledge versions retrieved successfully, ID: %s, count: %d", id, len(versions))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    versions,
+	})
+}
+
+// RollbackKnowledgeVersion restores an earlier content version of URL and file knowledge in the background
+func (h *KnowledgeHandler) RollbackKnowledgeVersion(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start rolling back knowledge version")
+
+	id := c.Param("id")
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		logger.Errorf(ctx, "Invalid knowledge version: %s", c.Param("version"))
+		c.Error(errors.NewBadRequestError("Invalid knowledge version"))
+		return
+	}
+
+	knowledge, err := h.kgService.RollbackKnowledgeVersion(ctx, id, version)
+	if err != nil {
+		c.Error(knowledgeVersionError(ctx, "Failed to roll back knowledge version", err))
+		return
+	}
+
+	logger.Infof(ctx, "Knowledge rollback scheduled successfully, ID: %s, version: %d", knowledge.ID, version)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    knowledge,
+	})
+}
+
+// knowledgeVersionError maps an error of refreshing, replacing or rolling back knowledge to an application error
+func knowledgeVersionError(ctx context.Context, message string, err error) *errors.AppError {
+	if appErr, ok := errors.IsAppError(err); ok {
+		return appErr
+	}
+	switch err {
+	case repository.ErrKnowledgeNotFound, repository.ErrKnowledgeVersionNotFound:
+		return errors.NewNotFoundError(err.Error())
+	case service.ErrKnowledgeNotRefreshable, service.ErrKnowledgeFileNotReplaceable, service.ErrInvalidFileType:
+		return errors.NewBadRequestError(err.Error())
+	case service.ErrKnowledgeProcessing, service.ErrKnowledgeTaskExists, service.ErrKnowledgeVersionActive:
+		return errors.NewConflictError(err.Error())
+	}
+	logger.ErrorWithFields(ctx, err, nil)
+	return errors.NewInternalServerError(message).WithDetails(err.Error())
+}
+
 // ListImportTasks retrieves a list of import tasks
 func (h *KnowledgeHandler) ListImportTasks(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -661,10 +893,11 @@ func (h *KnowledgeHandler) CreateKnowledgeFromPassage(c *gin.Context) {
 
 	// Parse request body
 	var req struct {
-		Passages    []string               `json:"passages" binding:"required"`
-		Title       string                 `json:"title"`
-		Description string                 `json:"description"`
-		Metadata    map[string]interface{} `json:"metadata"`
+		Passages     []string               `json:"passages" binding:"required"`
+		Title        string                 `json:"title"`
+		Description  string                 `json:"description"`
+		Metadata     map[string]interface{} `json:"metadata"`
+		AccessLabels types.AccessLabels     `json:"access_labels"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Error(ctx, "Failed to parse passage request", err)
@@ -682,8 +915,12 @@ func (h *KnowledgeHandler) CreateKnowledgeFromPassage(c *gin.Context) {
 		kbID, len(req.Passages))
 
 	// Create knowledge entry from passages
-	knowledge, err := h.kgService.CreateKnowledgeFromPassage(ctx, kbID, req.Passages)
+	knowledge, err := h.kgService.CreateKnowledgeFromPassage(ctx, kbID, req.Passages, req.AccessLabels)
 	if err != nil {
+		if appErr, ok := errors.IsAppError(err); ok {
+			c.Error(appErr)
+			return
+		}
 		logger.ErrorWithFields(ctx, err, nil)
 		c.Error(errors.NewInternalServerError(err.Error()))
 		return
@@ -716,3 +953,112 @@ func (h *KnowledgeHandler) CreateKnowledgeFromPassage(c *gin.Context) {
 		"message":    "Knowledge passage created successfully",
 	})
 }
+
+// ImportFAQ handles requests to import question-answer pairs into a knowledge base
+func (h *KnowledgeHandler) ImportFAQ(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start importing FAQ entries")
+
+	// Validate access to the knowledge base
+	_, kbID, err := h.validateKnowledgeBaseAccess(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var req struct {
+		Entries []*types.FAQEntry `json:"entries" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(ctx, "Failed to parse FAQ import request", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+	if len(req.Entries) == 0 {
+		logger.Error(ctx, "FAQ entries array is empty")
+		c.Error(errors.NewBadRequestError("Entries cannot be empty"))
+		return
+	}
+
+	result, err := h.kgService.ImportFAQ(ctx, kbID, req.Entries)
+	if err != nil {
+		if appErr, ok := errors.IsAppError(err); ok {
+			c.Error(appErr)
+			return
+		}
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(errors.NewInternalServerError(err.Error()))
+		return
+	}
+
+	logger.Infof(ctx, "FAQ entries imported successfully, knowledge base ID: %s", kbID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// ExportFAQ handles requests to export the question-answer pairs of a knowledge base
+func (h *KnowledgeHandler) ExportFAQ(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start exporting FAQ entries")
+
+	// Validate access to the knowledge base
+	_, kbID, err := h.validateKnowledgeBaseAccess(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	entries, err := h.kgService.ExportFAQ(ctx, kbID)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(errors.NewInternalServerError(err.Error()))
+		return
+	}
+
+	logger.Infof(ctx, "FAQ entries exported successfully, knowledge base ID: %s, count: %d", kbID, len(entries))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    entries,
+	})
+}
+
+// ListDuplicateClusters handles requests to list the clusters of near-duplicate knowledge in a knowledge base
+func (h *KnowledgeHandler) ListDuplicateClusters(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start listing duplicate clusters")
+
+	// Validate access to the knowledge base
+	_, kbID, err := h.validateKnowledgeBaseAccess(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var threshold float64
+	if value := c.Query("threshold"); value != "" {
+		threshold, err = strconv.ParseFloat(value, 64)
+		if err == nil {
+			err = types.ValidateDuplicateThreshold(threshold)
+		}
+		if err != nil {
+			logger.Error(ctx, "Invalid duplicate threshold", err)
+			c.Error(errors.NewBadRequestError("Invalid threshold").WithDetails(err.Error()))
+			return
+		}
+	}
+
+	clusters, err := h.kgService.ListDuplicateClusters(ctx, kbID, threshold)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(errors.NewInternalServerError(err.Error()))
+		return
+	}
+
+	logger.Infof(ctx, "Duplicate clusters listed successfully, knowledge base ID: %s, count: %d", kbID, len(clusters))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    clusters,
+	})
+}
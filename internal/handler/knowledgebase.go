@@ -47,7 +47,25 @@ func (h *KnowledgeBaseHandler) HybridSearch(c *gin.Context) {
 		return
 	}
 
-	logger.Infof(ctx, "Executing hybrid search, knowledge base ID: %s, query: %s", id, req.QueryText)
+	// Validate fusion configuration
+	if err := req.Fusion.Validate(); err != nil {
+		logger.Error(ctx, "Invalid fusion configuration", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+	if err := req.Filters.Validate(); err != nil {
+		logger.Error(ctx, "Invalid metadata filters", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+	if err := types.AccessLabels(req.Audience).Validate(); err != nil {
+		logger.Error(ctx, "Invalid audience", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+
+	logger.Infof(ctx, "Executing hybrid search, knowledge base ID: %s, query: %s, fusion method: %s",
+		id, req.QueryText, req.Fusion.GetMethod())
 
 	// Execute hybrid search with default search parameters
 	results, err := h.service.HybridSearch(ctx, id, req)
@@ -78,6 +96,22 @@ func (h *KnowledgeBaseHandler) CreateKnowledgeBase(c *gin.Context) {
 		return
 	}
 
+	if err := req.URLSyncConfig.Validate(); err != nil {
+		logger.Error(ctx, "Invalid URL sync configuration", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+	if err := req.DedupConfig.Validate(); err != nil {
+		logger.Error(ctx, "Invalid dedup configuration", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+	if err := req.ChunkingConfig.Validate(); err != nil {
+		logger.Error(ctx, "Invalid chunking configuration", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+
 	logger.Infof(ctx, "Creating knowledge base, name: %s", req.Name)
 	// Create knowledge base using the service
 	kb, err := h.service.CreateKnowledgeBase(ctx, &req)
@@ -217,6 +251,22 @@ func (h *KnowledgeBaseHandler) UpdateKnowledgeBase(c *gin.Context) {
 		return
 	}
 
+	if err := req.Config.URLSyncConfig.Validate(); err != nil {
+		logger.Error(ctx, "Invalid URL sync configuration", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+	if err := req.Config.DedupConfig.Validate(); err != nil {
+		logger.Error(ctx, "Invalid dedup configuration", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+	if err := req.Config.ChunkingConfig.Validate(); err != nil {
+		logger.Error(ctx, "Invalid chunking configuration", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+
 	logger.Infof(ctx, "Updating knowledge base, ID: %s, name: %s", id, req.Name)
 
 	// Update the knowledge base
@@ -69,21 +69,56 @@ type SessionStrategy struct {
 	SummaryParameters *types.SummaryConfig `json:"summary_parameters" gorm:"type:json"`
 	// Prefix for responses when no match is found
 	NoMatchPrefix string `json:"no_match_prefix"`
+	// Chat pipeline, either the name of a configured pipeline or an inline definition
+	Pipeline *types.PipelineConfig `json:"pipeline"`
+	// Fusion of vector and keyword results, e.g. rrf or weighted
+	Fusion *types.FusionConfig `json:"fusion"`
+	// Default metadata filters of retrieval, e.g. category in a list of values
+	Filters types.MetadataFilters `json:"filters"`
+	// Access labels of the audience, knowledge with labels is only retrieved for a matching audience
+	Audience types.AccessLabels `json:"audience"`
 }
 
 // CreateSessionRequest represents a request to create a new session
 type CreateSessionRequest struct {
 	// ID of the associated knowledge base
-	KnowledgeBaseID string `json:"knowledge_base_id" binding:"required"`
+	KnowledgeBaseID string `json:"knowledge_base_id"`
+	// Knowledge bases to search, each with an optional weight and thresholds
+	// Takes precedence over KnowledgeBaseID when provided
+	KnowledgeBases types.SessionKnowledgeBases `json:"knowledge_bases"`
 	// Session strategy configuration
 	SessionStrategy *SessionStrategy `json:"session_strategy"`
 }
 
+// checkKnowledgeBases makes sure every knowledge base of a session exists and belongs to the tenant
+// of the session, it returns the primary knowledge base
+func (h *SessionHandler) checkKnowledgeBases(ctx context.Context,
+	session *types.Session,
+) (*types.KnowledgeBase, error) {
+	var primary *types.KnowledgeBase
+	for _, id := range session.GetKnowledgeBases().IDs() {
+		kb, err := h.knowledgebaseService.GetKnowledgeBaseByID(ctx, id)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to get knowledge base, ID: %s, error: %v", id, err)
+			return nil, errors.NewBadRequestError("Knowledge base not found").WithDetails(id)
+		}
+		if kb.TenantID != session.TenantID {
+			logger.Warnf(ctx, "Permission denied to access knowledge base %s, tenant ID mismatch, "+
+				"requested tenant ID: %d, knowledge base tenant ID: %d", id, session.TenantID, kb.TenantID)
+			return nil, errors.NewForbiddenError("Permission denied to access this knowledge base").WithDetails(id)
+		}
+		if id == session.KnowledgeBaseID {
+			primary = kb
+		}
+	}
+	return primary, nil
+}
+
 // CreateSession handles the creation of a new conversation session
 func (h *SessionHandler) CreateSession(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	// logger.Infof(ctx, "Start creating session, config: %+v", h.config.Conversation)
+	// logger.Infof(ctx, "Start creating session, config: %+v", h.config.GetConversation())
 
 	// Parse and validate the request body
 	var request CreateSessionRequest
@@ -101,7 +136,15 @@ func (h *SessionHandler) CreateSession(c *gin.Context) {
 		return
 	}
 
-	// Validate knowledge base ID
+	// Validate knowledge base IDs
+	if err := request.KnowledgeBases.Validate(request.KnowledgeBaseID); err != nil {
+		logger.Errorf(ctx, "Invalid knowledge bases: %v", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+	if request.KnowledgeBaseID == "" && len(request.KnowledgeBases) > 0 {
+		request.KnowledgeBaseID = request.KnowledgeBases[0].KnowledgeBaseID
+	}
 	if request.KnowledgeBaseID == "" {
 		logger.Error(ctx, "Knowledge base ID is empty")
 		c.Error(errors.NewBadRequestError("Knowledge base cannot be empty"))
@@ -110,15 +153,17 @@ func (h *SessionHandler) CreateSession(c *gin.Context) {
 
 	logger.Infof(
 		ctx,
-		"Processing session creation request, tenant ID: %d, knowledge base ID: %s",
+		"Processing session creation request, tenant ID: %d, knowledge base ID: %s, knowledge bases: %v",
 		tenantID.(uint),
 		request.KnowledgeBaseID,
+		request.KnowledgeBases.IDs(),
 	)
 
 	// Create session object with base properties
 	createdSession := &types.Session{
 		TenantID:        tenantID.(uint),
 		KnowledgeBaseID: request.KnowledgeBaseID,
+		KnowledgeBases:  request.KnowledgeBases,
 	}
 
 	// If summary model parameters are empty, set defaults
@@ -134,66 +179,69 @@ func (h *SessionHandler) CreateSession(c *gin.Context) {
 		createdSession.VectorThreshold = request.SessionStrategy.VectorThreshold
 		createdSession.RerankTopK = request.SessionStrategy.RerankTopK
 		createdSession.RerankThreshold = request.SessionStrategy.RerankThreshold
+		createdSession.Pipeline = request.SessionStrategy.Pipeline
+		createdSession.Fusion = request.SessionStrategy.Fusion
+		createdSession.Filters = request.SessionStrategy.Filters
+		createdSession.Audience = request.SessionStrategy.Audience
 		if request.SessionStrategy.SummaryParameters != nil {
 			createdSession.SummaryParameters = request.SessionStrategy.SummaryParameters
 		} else {
 			createdSession.SummaryParameters = &types.SummaryConfig{
-				MaxTokens:           h.config.Conversation.Summary.MaxTokens,
-				TopP:                h.config.Conversation.Summary.TopP,
-				TopK:                h.config.Conversation.Summary.TopK,
-				FrequencyPenalty:    h.config.Conversation.Summary.FrequencyPenalty,
-				PresencePenalty:     h.config.Conversation.Summary.PresencePenalty,
-				RepeatPenalty:       h.config.Conversation.Summary.RepeatPenalty,
-				NoMatchPrefix:       h.config.Conversation.Summary.NoMatchPrefix,
-				Temperature:         h.config.Conversation.Summary.Temperature,
-				Seed:                h.config.Conversation.Summary.Seed,
-				MaxCompletionTokens: h.config.Conversation.Summary.MaxCompletionTokens,
+				MaxTokens:           h.config.GetConversation().Summary.MaxTokens,
+				TopP:                h.config.GetConversation().Summary.TopP,
+				TopK:                h.config.GetConversation().Summary.TopK,
+				FrequencyPenalty:    h.config.GetConversation().Summary.FrequencyPenalty,
+				PresencePenalty:     h.config.GetConversation().Summary.PresencePenalty,
+				RepeatPenalty:       h.config.GetConversation().Summary.RepeatPenalty,
+				NoMatchPrefix:       h.config.GetConversation().Summary.NoMatchPrefix,
+				Temperature:         h.config.GetConversation().Summary.Temperature,
+				Seed:                h.config.GetConversation().Summary.Seed,
+				MaxCompletionTokens: h.config.GetConversation().Summary.MaxCompletionTokens,
 			}
 		}
 		if createdSession.SummaryParameters.Prompt == "" {
-			createdSession.SummaryParameters.Prompt = h.config.Conversation.Summary.Prompt
+			createdSession.SummaryParameters.Prompt = h.config.GetConversation().Summary.Prompt
 		}
 		if createdSession.SummaryParameters.ContextTemplate == "" {
-			createdSession.SummaryParameters.ContextTemplate = h.config.Conversation.Summary.ContextTemplate
+			createdSession.SummaryParameters.ContextTemplate = h.config.GetConversation().Summary.ContextTemplate
 		}
 		if createdSession.SummaryParameters.NoMatchPrefix == "" {
-			createdSession.SummaryParameters.NoMatchPrefix = h.config.Conversation.Summary.NoMatchPrefix
+			createdSession.SummaryParameters.NoMatchPrefix = h.config.GetConversation().Summary.NoMatchPrefix
 		}
 
 		logger.Debug(ctx, "Custom session strategy set")
 	} else {
 		// Use default configuration from global config
-		createdSession.MaxRounds = h.config.Conversation.MaxRounds
-		createdSession.EnableRewrite = h.config.Conversation.EnableRewrite
-		createdSession.FallbackStrategy = types.FallbackStrategy(h.config.Conversation.FallbackStrategy)
-		createdSession.FallbackResponse = h.config.Conversation.FallbackResponse
-		createdSession.EmbeddingTopK = h.config.Conversation.EmbeddingTopK
-		createdSession.KeywordThreshold = h.config.Conversation.KeywordThreshold
-		createdSession.VectorThreshold = h.config.Conversation.VectorThreshold
-		createdSession.RerankThreshold = h.config.Conversation.RerankThreshold
-		createdSession.RerankTopK = h.config.Conversation.RerankTopK
+		createdSession.MaxRounds = h.config.GetConversation().MaxRounds
+		createdSession.EnableRewrite = h.config.GetConversation().EnableRewrite
+		createdSession.FallbackStrategy = types.FallbackStrategy(h.config.GetConversation().FallbackStrategy)
+		createdSession.FallbackResponse = h.config.GetConversation().FallbackResponse
+		createdSession.EmbeddingTopK = h.config.GetConversation().EmbeddingTopK
+		createdSession.KeywordThreshold = h.config.GetConversation().KeywordThreshold
+		createdSession.VectorThreshold = h.config.GetConversation().VectorThreshold
+		createdSession.RerankThreshold = h.config.GetConversation().RerankThreshold
+		createdSession.RerankTopK = h.config.GetConversation().RerankTopK
 		createdSession.SummaryParameters = &types.SummaryConfig{
-			MaxTokens:           h.config.Conversation.Summary.MaxTokens,
-			TopP:                h.config.Conversation.Summary.TopP,
-			TopK:                h.config.Conversation.Summary.TopK,
-			FrequencyPenalty:    h.config.Conversation.Summary.FrequencyPenalty,
-			PresencePenalty:     h.config.Conversation.Summary.PresencePenalty,
-			RepeatPenalty:       h.config.Conversation.Summary.RepeatPenalty,
-			Prompt:              h.config.Conversation.Summary.Prompt,
-			ContextTemplate:     h.config.Conversation.Summary.ContextTemplate,
-			NoMatchPrefix:       h.config.Conversation.Summary.NoMatchPrefix,
-			Temperature:         h.config.Conversation.Summary.Temperature,
-			Seed:                h.config.Conversation.Summary.Seed,
-			MaxCompletionTokens: h.config.Conversation.Summary.MaxCompletionTokens,
+			MaxTokens:           h.config.GetConversation().Summary.MaxTokens,
+			TopP:                h.config.GetConversation().Summary.TopP,
+			TopK:                h.config.GetConversation().Summary.TopK,
+			FrequencyPenalty:    h.config.GetConversation().Summary.FrequencyPenalty,
+			PresencePenalty:     h.config.GetConversation().Summary.PresencePenalty,
+			RepeatPenalty:       h.config.GetConversation().Summary.RepeatPenalty,
+			Prompt:              h.config.GetConversation().Summary.Prompt,
+			ContextTemplate:     h.config.GetConversation().Summary.ContextTemplate,
+			NoMatchPrefix:       h.config.GetConversation().Summary.NoMatchPrefix,
+			Temperature:         h.config.GetConversation().Summary.Temperature,
+			Seed:                h.config.GetConversation().Summary.Seed,
+			MaxCompletionTokens: h.config.GetConversation().Summary.MaxCompletionTokens,
 		}
 
 		logger.Debug(ctx, "Using default session strategy")
 	}
 
-	kb, err := h.knowledgebaseService.GetKnowledgeBaseByID(ctx, request.KnowledgeBaseID)
+	kb, err := h.checkKnowledgeBases(ctx, createdSession)
 	if err != nil {
-		logger.Error(ctx, "Failed to get knowledge base", err)
-		c.Error(errors.NewInternalServerError(err.Error()))
+		c.Error(err)
 		return
 	}
 
@@ -328,6 +376,22 @@ func (h *SessionHandler) UpdateSession(c *gin.Context) {
 	session.ID = id
 	session.TenantID = tenantID.(uint)
 
+	// Check the knowledge bases the session is moved to
+	if session.KnowledgeBaseID != "" || len(session.KnowledgeBases) > 0 {
+		if err := session.KnowledgeBases.Validate(session.KnowledgeBaseID); err != nil {
+			logger.Errorf(ctx, "Invalid knowledge bases: %v", err)
+			c.Error(errors.NewBadRequestError(err.Error()))
+			return
+		}
+		if session.KnowledgeBaseID == "" {
+			session.KnowledgeBaseID = session.KnowledgeBases[0].KnowledgeBaseID
+		}
+		if _, err := h.checkKnowledgeBases(ctx, &session); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
 	// Call service to update session
 	if err := h.sessionService.UpdateSession(ctx, &session); err != nil {
 		if err == errors.ErrSessionNotFound {
@@ -434,8 +498,9 @@ type CreateKnowledgeQARequest struct {
 
 // SearchKnowledgeRequest defines the request structure for searching knowledge without LLM summarization
 type SearchKnowledgeRequest struct {
-	Query           string `json:"query" binding:"required"`             // Query text to search for
-	KnowledgeBaseID string `json:"knowledge_base_id" binding:"required"` // ID of the knowledge base to search
+	Query           string             `json:"query" binding:"required"`             // Query text to search for
+	KnowledgeBaseID string             `json:"knowledge_base_id" binding:"required"` // ID of the knowledge base to search
+	Audience        types.AccessLabels `json:"audience"`                             // Access labels of the audience
 }
 
 // SearchKnowledge performs knowledge base search without LLM summarization
@@ -465,6 +530,12 @@ func (h *SessionHandler) SearchKnowledge(c *gin.Context) {
 		return
 	}
 
+	if err := request.Audience.Validate(); err != nil {
+		logger.Error(ctx, "Invalid audience", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+
 	logger.Infof(
 		ctx,
 		"Knowledge search request, knowledge base ID: %s, query: %s",
@@ -473,7 +544,8 @@ func (h *SessionHandler) SearchKnowledge(c *gin.Context) {
 	)
 
 	// Directly call knowledge retrieval service without LLM summarization
-	searchResults, err := h.sessionService.SearchKnowledge(ctx, request.KnowledgeBaseID, request.Query)
+	searchResults, err := h.sessionService.SearchKnowledge(ctx,
+		request.KnowledgeBaseID, request.Query, request.Audience)
 	if err != nil {
 		logger.ErrorWithFields(ctx, err, nil)
 		c.Error(errors.NewInternalServerError(err.Error()))
@@ -793,6 +865,12 @@ func (h *SessionHandler) KnowledgeQA(c *gin.Context) {
 					logger.GetLogger(ctx).Error("Update stream content failed", "error", err)
 				}
 			}
+			if response.ResponseType == types.ResponseTypeCitation {
+				assistantMessage.Citations = append(assistantMessage.Citations, response.Citations...)
+			}
+			if response.ResponseType == types.ResponseTypeGrounding {
+				assistantMessage.Grounding = response.Grounding
+			}
 		}
 	}()
 }
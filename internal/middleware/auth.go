@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"log"
 	"net/http"
@@ -139,6 +140,25 @@ func Auth(tenantService interfaces.TenantService, userService interfaces.UserSer
 	}
 }
 
+// Operator 运维认证中间件，要求请求在X-Operator-Token请求头中携带配置的运维令牌，
+// 用于影响所有租户的操作，未配置运维令牌时拒绝所有请求
+func Operator(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.Server == nil || cfg.Server.OperatorToken == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: operator token is not configured"})
+			c.Abort()
+			return
+		}
+		token := c.GetHeader("X-Operator-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Server.OperatorToken)) != 1 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: invalid operator token"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 // GetTenantIDFromContext helper function to get tenant ID from context
 func GetTenantIDFromContext(ctx context.Context) (uint, error) {
 	tenantID, ok := ctx.Value("tenantID").(uint)
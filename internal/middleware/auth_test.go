@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestOperator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name          string
+		operatorToken string
+		header        string
+		wantStatus    int
+	}{
+		{name: "operator token not configured", header: "secret", wantStatus: http.StatusForbidden},
+		{name: "missing token", operatorToken: "secret", wantStatus: http.StatusForbidden},
+		{name: "wrong token", operatorToken: "secret", header: "guess", wantStatus: http.StatusForbidden},
+		{name: "operator token", operatorToken: "secret", header: "secret", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Server: &config.ServerConfig{OperatorToken: tt.operatorToken}}
+			r := gin.New()
+			r.POST("/rollback", Operator(cfg), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodPost, "/rollback", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Operator-Token", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package embedding
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// QueryCache keeps the embeddings of queries by model and text, it lets repeated searches of the same
+// queries, such as the trials of an evaluation sweep, skip the embedding model
+type QueryCache struct {
+	mu      sync.RWMutex
+	vectors map[string][]float32
+}
+
+// NewQueryCache creates an empty query cache
+func NewQueryCache() *QueryCache {
+	return &QueryCache{vectors: make(map[string][]float32)}
+}
+
+// Get returns the cached embedding of a text by the given model
+func (c *QueryCache) Get(modelID string, text string) ([]float32, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	vector, ok := c.vectors[modelID+"\x00"+text]
+	return vector, ok
+}
+
+// Put caches the embedding of a text by the given model
+func (c *QueryCache) Put(modelID string, text string, vector []float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.vectors[modelID+"\x00"+text] = vector
+}
+
+// EmbedQuery embeds a query text with the model. If the context carries a query cache under
+// types.QueryEmbeddingCacheContextKey, the embedding is taken from it or stored in it.
+func EmbedQuery(ctx context.Context, model Embedder, text string) ([]float32, error) {
+	cache, _ := ctx.Value(types.QueryEmbeddingCacheContextKey).(*QueryCache)
+	if cache == nil {
+		return model.Embed(ctx, text)
+	}
+	if vector, ok := cache.Get(model.GetModelID(), text); ok {
+		return vector, nil
+	}
+	vector, err := model.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	cache.Put(model.GetModelID(), text, vector)
+	return vector, nil
+}
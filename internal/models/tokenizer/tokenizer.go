@@ -0,0 +1,220 @@
+// Package tokenizer counts and truncates text in model tokens
+package tokenizer
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer types
+const (
+	// TokenizerEstimate counts CJK characters as one token each and other text as one token per four bytes
+	TokenizerEstimate = "estimate"
+	// TokenizerChar counts one token per character, a conservative choice for unknown models
+	TokenizerChar = "char"
+	// TokenizerGPT pre-tokenizes text like the BPE tokenizers of GPT style models
+	// and estimates the sub-word tokens of every piece
+	TokenizerGPT = "gpt"
+)
+
+// Tokenizer counts and truncates text in model tokens
+type Tokenizer interface {
+	// CountTokens returns the number of tokens of text
+	CountTokens(text string) int
+	// Truncate cuts text to at most maxTokens tokens
+	Truncate(text string, maxTokens int) string
+	// GetName returns the tokenizer type
+	GetName() string
+}
+
+// NewTokenizer returns the tokenizer of the given type, unknown types fall back to the estimate tokenizer
+func NewTokenizer(name string) Tokenizer {
+	switch name {
+	case TokenizerChar:
+		return &tokenizer{name: TokenizerChar, split: splitChars}
+	case TokenizerGPT:
+		return &tokenizer{name: TokenizerGPT, split: splitGPT}
+	default:
+		return &tokenizer{name: TokenizerEstimate, split: splitEstimate}
+	}
+}
+
+// tokenizer implements Tokenizer with a split function which reports the end offset of every token
+type tokenizer struct {
+	name  string
+	split func(text string, emit func(end int) bool)
+}
+
+// CountTokens returns the number of tokens of text
+func (t *tokenizer) CountTokens(text string) int {
+	count := 0
+	t.split(text, func(int) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// Truncate cuts text to at most maxTokens tokens
+func (t *tokenizer) Truncate(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	count, cut := 0, len(text)
+	t.split(text, func(end int) bool {
+		count++
+		if count > maxTokens {
+			return false
+		}
+		cut = end
+		return true
+	})
+	if count <= maxTokens {
+		return text
+	}
+	return text[:cut]
+}
+
+// GetName returns the tokenizer type
+func (t *tokenizer) GetName() string {
+	return t.name
+}
+
+// splitChars emits one token per character
+func splitChars(text string, emit func(end int) bool) {
+	for i, r := range text {
+		if !emit(i + utf8.RuneLen(r)) {
+			return
+		}
+	}
+}
+
+// splitEstimate emits one token per CJK character and one token per four bytes of other text
+func splitEstimate(text string, emit func(end int) bool) {
+	pending := 0
+	for i, r := range text {
+		size := utf8.RuneLen(r)
+		if IsCJK(r) {
+			if pending > 0 {
+				pending = 0
+				if !emit(i) {
+					return
+				}
+			}
+			if !emit(i + size) {
+				return
+			}
+			continue
+		}
+		pending += size
+		if pending >= 4 {
+			pending = 0
+			if !emit(i + size) {
+				return
+			}
+		}
+	}
+	if pending > 0 {
+		emit(len(text))
+	}
+}
+
+// gptPattern pre-tokenizes text into words, numbers, punctuation and whitespace like GPT style tokenizers
+var gptPattern = regexp.MustCompile(`'(?:s|t|re|ve|m|ll|d)| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+`)
+
+// splitGPT emits the estimated sub-word tokens of every pre-tokenized piece:
+// CJK characters and punctuation are one token each, numbers are split into groups of three digits,
+// whitespace runs are one token and words are one token per four bytes
+func splitGPT(text string, emit func(end int) bool) {
+	for _, loc := range gptPattern.FindAllStringIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		piece := text[start:end]
+		first, _ := utf8.DecodeRuneInString(piece)
+		if first == ' ' && end-start > 1 {
+			first, _ = utf8.DecodeRuneInString(piece[1:])
+		}
+		switch {
+		case isSpaces(piece):
+			if !emit(end) {
+				return
+			}
+		case unicode.IsNumber(first):
+			if !emitGroups(piece, start, 3, isNotSpace, emit) {
+				return
+			}
+		case unicode.IsLetter(first):
+			pending := 0
+			for i, r := range piece {
+				size := utf8.RuneLen(r)
+				if IsCJK(r) {
+					if pending > 0 {
+						pending = 0
+						if !emit(start + i) {
+							return
+						}
+					}
+					if !emit(start + i + size) {
+						return
+					}
+					continue
+				}
+				pending += size
+				if pending >= 4 {
+					pending = 0
+					if !emit(start + i + size) {
+						return
+					}
+				}
+			}
+			if pending > 0 && !emit(end) {
+				return
+			}
+		default:
+			if !emitGroups(piece, start, 1, isNotSpace, emit) {
+				return
+			}
+		}
+	}
+}
+
+// emitGroups emits one token per size counted runes of piece, starting at offset in the text
+func emitGroups(piece string, offset int, size int, counted func(r rune) bool, emit func(end int) bool) bool {
+	n := 0
+	for i, r := range piece {
+		if !counted(r) {
+			continue
+		}
+		n++
+		if n == size {
+			n = 0
+			if !emit(offset + i + utf8.RuneLen(r)) {
+				return false
+			}
+		}
+	}
+	if n > 0 {
+		return emit(offset + len(piece))
+	}
+	return true
+}
+
+// isSpaces returns true if s only contains whitespace
+func isSpaces(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNotSpace returns true if r is not whitespace
+func isNotSpace(r rune) bool {
+	return !unicode.IsSpace(r)
+}
+
+// IsCJK returns true for Chinese, Japanese and Korean characters, which are written without spaces between words
+func IsCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,55 @@
+package tokenizer
+
+import (
+	"testing"
+)
+
+func TestCountTokens(t *testing.T) {
+	tests := []struct {
+		tokenizer string
+		text      string
+		expected  int
+	}{
+		{tokenizer: TokenizerEstimate, text: "", expected: 0},
+		{tokenizer: TokenizerEstimate, text: "abcd", expected: 1},
+		{tokenizer: TokenizerEstimate, text: "abcde", expected: 2},
+		{tokenizer: TokenizerEstimate, text: "知识库", expected: 3},
+		{tokenizer: TokenizerEstimate, text: "CDN域名", expected: 3},
+		{tokenizer: TokenizerChar, text: "CDN域名", expected: 5},
+		{tokenizer: TokenizerGPT, text: "hello world", expected: 4},
+		{tokenizer: TokenizerGPT, text: "1234567", expected: 3},
+		{tokenizer: TokenizerGPT, text: "知识库!", expected: 4},
+		{tokenizer: "unknown", text: "abcde", expected: 2},
+	}
+	for _, tt := range tests {
+		if got := NewTokenizer(tt.tokenizer).CountTokens(tt.text); got != tt.expected {
+			t.Errorf("%s.CountTokens(%q) = %d, expected %d", tt.tokenizer, tt.text, got, tt.expected)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		tokenizer string
+		text      string
+		maxTokens int
+		expected  string
+	}{
+		{tokenizer: TokenizerEstimate, text: "这是第一段内容", maxTokens: 4, expected: "这是第一"},
+		{tokenizer: TokenizerEstimate, text: "abcdefgh", maxTokens: 1, expected: "abcd"},
+		{tokenizer: TokenizerEstimate, text: "abc", maxTokens: 5, expected: "abc"},
+		{tokenizer: TokenizerEstimate, text: "abc", maxTokens: 0, expected: ""},
+		{tokenizer: TokenizerChar, text: "CDN域名", maxTokens: 4, expected: "CDN域"},
+		{tokenizer: TokenizerGPT, text: "hello world", maxTokens: 2, expected: "hello"},
+	}
+	for _, tt := range tests {
+		tok := NewTokenizer(tt.tokenizer)
+		got := tok.Truncate(tt.text, tt.maxTokens)
+		if got != tt.expected {
+			t.Errorf("%s.Truncate(%q, %d) = %q, expected %q", tt.tokenizer, tt.text, tt.maxTokens, got, tt.expected)
+		}
+		if tokens := tok.CountTokens(got); tokens > tt.maxTokens {
+			t.Errorf("%s.Truncate(%q, %d) has %d tokens", tt.tokenizer, tt.text, tt.maxTokens, tokens)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     []float32
+		expected float64
+	}{
+		{name: "identical", a: []float32{1, 2, 3}, b: []float32{1, 2, 3}, expected: 1},
+		{name: "orthogonal", a: []float32{1, 0}, b: []float32{0, 1}, expected: 0},
+		{name: "opposite", a: []float32{1, 1}, b: []float32{-1, -1}, expected: -1},
+		{name: "dimension mismatch", a: []float32{1, 2}, b: []float32{1, 2, 3}, expected: 0},
+		{name: "zero vector", a: []float32{0, 0}, b: []float32{1, 2}, expected: 0},
+		{name: "empty", expected: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CosineSimilarity(tt.a, tt.b); math.Abs(got-tt.expected) > 1e-9 {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
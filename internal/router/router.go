@@ -35,9 +35,11 @@ type RouterParams struct {
 	MessageHandler        *handler.MessageHandler
 	ModelHandler          *handler.ModelHandler
 	EvaluationHandler     *handler.EvaluationHandler
+	DatasetHandler        *handler.DatasetHandler
 	AuthHandler           *handler.AuthHandler
 	InitializationHandler *handler.InitializationHandler
 	SystemHandler         *handler.SystemHandler
+	ConfigHandler         *handler.ConfigHandler
 }
 
 // NewRouter 创建新的路由
@@ -48,7 +50,7 @@ func NewRouter(params RouterParams) *gin.Engine {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key", "X-Request-ID"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key", "X-Request-ID", "X-Operator-Token"},
 		ExposeHeaders:    []string{"Content-Length", "Access-Control-Allow-Origin"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
@@ -82,13 +84,30 @@ func NewRouter(params RouterParams) *gin.Engine {
 		RegisterMessageRoutes(v1, params.MessageHandler)
 		RegisterModelRoutes(v1, params.ModelHandler)
 		RegisterEvaluationRoutes(v1, params.EvaluationHandler)
+		RegisterDatasetRoutes(v1, params.DatasetHandler)
 		RegisterInitializationRoutes(v1, params.InitializationHandler)
 		RegisterSystemRoutes(v1, params.SystemHandler)
+		RegisterConfigRoutes(v1, params.ConfigHandler, params.Config)
 	}
 
 	return r
 }
 
+// RegisterConfigRoutes 注册对话配置版本相关的路由，对话配置由所有租户共享，回滚仅限运维人员
+func RegisterConfigRoutes(r *gin.RouterGroup, handler *handler.ConfigHandler, cfg *config.Config) {
+	versions := r.Group("/config/versions")
+	{
+		// 获取配置版本列表
+		versions.GET("", handler.ListConfigVersions)
+		// 比较两个配置版本
+		versions.GET("/diff", handler.DiffConfigVersions)
+		// 获取配置版本详情
+		versions.GET("/:id", handler.GetConfigVersion)
+		// 回滚到指定配置版本，需要运维令牌
+		versions.POST("/:id/rollback", middleware.Operator(cfg), handler.RollbackConfigVersion)
+	}
+}
+
 // RegisterChunkRoutes 注册分块相关的路由
 func RegisterChunkRoutes(r *gin.RouterGroup, handler *handler.ChunkHandler) {
 	// 分块路由组
@@ -118,6 +137,17 @@ func RegisterKnowledgeRoutes(r *gin.RouterGroup, handler *handler.KnowledgeHandl
 		kb.POST("/passage", handler.CreateKnowledgeFromPassage)
 		// 获取知识库下的知识列表
 		kb.GET("", handler.ListKnowledge)
+		// 获取知识库下内容近似重复的知识分组
+		kb.GET("/duplicates", handler.ListDuplicateClusters)
+	}
+
+	// 知识库下的问答对路由组
+	faq := r.Group("/knowledge-bases/:id/faq")
+	{
+		// 批量导入问答对，问题相同的问答对会被替换
+		faq.POST("/import", handler.ImportFAQ)
+		// 导出知识库下的问答对
+		faq.GET("/export", handler.ExportFAQ)
 	}
 
 	// 批量导入任务路由组
@@ -133,6 +163,15 @@ func RegisterKnowledgeRoutes(r *gin.RouterGroup, handler *handler.KnowledgeHandl
 		importTasks.POST("/:task_id/cancel", handler.CancelImportTask)
 	}
 
+	// 文档解析死信任务路由组，重试用尽或无法重试的解析任务
+	deadLetters := r.Group("/knowledge-tasks/dead-letters")
+	{
+		// 获取死信任务列表
+		deadLetters.GET("", handler.ListDeadLetterTasks)
+		// 重新执行死信任务
+		deadLetters.POST("/:task_id/retry", handler.RetryDeadLetterTask)
+	}
+
 	// 兼容旧API - 知识库级别的批量导入任务
 	kbImport := r.Group("/knowledge-bases/:id/import-tasks")
 	{
@@ -145,6 +184,8 @@ func RegisterKnowledgeRoutes(r *gin.RouterGroup, handler *handler.KnowledgeHandl
 	{
 		// 批量获取知识
 		k.GET("/batch", handler.GetKnowledgeBatch)
+		// 批量删除知识
+		k.DELETE("/batch", handler.DeleteKnowledgeBatch)
 		// 获取知识详情
 		k.GET("/:id", handler.GetKnowledge)
 		// 删除知识
@@ -155,6 +196,14 @@ func RegisterKnowledgeRoutes(r *gin.RouterGroup, handler *handler.KnowledgeHandl
 		k.GET("/:id/download", handler.DownloadKnowledgeFile)
 		// 更新图像分块信息
 		k.PUT("/image/:id/:chunk_id", handler.UpdateImageInfo)
+		// 重新抓取URL知识，内容变化时重新索引
+		k.POST("/:id/refresh", handler.RefreshKnowledge)
+		// 替换文件知识的文件，仅重新嵌入变化的分块
+		k.PUT("/:id/file", handler.ReplaceKnowledgeFile)
+		// 获取URL和文件知识的历史版本
+		k.GET("/:id/versions", handler.ListKnowledgeVersions)
+		// 回滚到URL和文件知识的历史版本
+		k.POST("/:id/versions/:version/rollback", handler.RollbackKnowledgeVersion)
 	}
 }
 
@@ -257,6 +306,42 @@ func RegisterEvaluationRoutes(r *gin.RouterGroup, handler *handler.EvaluationHan
 	{
 		evaluationRoutes.POST("/", handler.Evaluation)
 		evaluationRoutes.GET("/", handler.GetEvaluationResult)
+		// 评估任务历史
+		evaluationRoutes.GET("/tasks", handler.ListEvaluations)
+		// 对比两次评估任务
+		evaluationRoutes.GET("/compare", handler.CompareEvaluations)
+		// 检索参数搜索
+		evaluationRoutes.POST("/sweeps", handler.StartSweep)
+		evaluationRoutes.GET("/sweeps", handler.ListSweeps)
+		evaluationRoutes.GET("/sweeps/:id", handler.GetSweep)
+	}
+}
+
+// RegisterDatasetRoutes 注册评估数据集相关的路由
+func RegisterDatasetRoutes(r *gin.RouterGroup, handler *handler.DatasetHandler) {
+	datasets := r.Group("/datasets")
+	{
+		// 上传数据集
+		datasets.POST("", handler.CreateDataset)
+		// 获取数据集列表
+		datasets.GET("", handler.ListDatasets)
+		// 获取数据集详情
+		datasets.GET("/:id", handler.GetDataset)
+		// 删除数据集
+		datasets.DELETE("/:id", handler.DeleteDataset)
+	}
+
+	// 数据集生成任务路由组，从知识库抽样分块由对话模型生成问题和参考答案
+	generationTasks := r.Group("/dataset-generation-tasks")
+	{
+		// 创建数据集生成任务
+		generationTasks.POST("", handler.GenerateDataset)
+		// 获取数据集生成任务列表
+		generationTasks.GET("", handler.ListGenerationTasks)
+		// 获取数据集生成任务详情及进度
+		generationTasks.GET("/:task_id", handler.GetGenerationTask)
+		// 取消数据集生成任务
+		generationTasks.POST("/:task_id/cancel", handler.CancelGenerationTask)
 	}
 }
 
@@ -1,10 +1,13 @@
 package router
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"time"
 
+	"github.com/Tencent/WeKnora/internal/config"
 	"github.com/Tencent/WeKnora/internal/types"
 	"github.com/Tencent/WeKnora/internal/types/interfaces"
 	"github.com/hibiken/asynq"
@@ -14,8 +17,11 @@ import (
 type AsynqTaskParams struct {
 	dig.In
 
-	Server    *asynq.Server
-	Extracter interfaces.Extracter
+	Server            *asynq.Server
+	Extracter         interfaces.Extracter
+	KnowledgeService  interfaces.KnowledgeService
+	ImportTaskService interfaces.ImportTaskService
+	DatasetService    interfaces.DatasetService
 }
 
 func getAsynqRedisClientOpt() *asynq.RedisClientOpt {
@@ -35,7 +41,14 @@ func NewAsyncqClient() *asynq.Client {
 	return client
 }
 
-func NewAsynqServer() *asynq.Server {
+// NewAsynqInspector creates an inspector for the tasks in the asynq queues
+func NewAsynqInspector(cleaner interfaces.ResourceCleaner) *asynq.Inspector {
+	inspector := asynq.NewInspector(getAsynqRedisClientOpt())
+	cleaner.RegisterWithName("AsynqInspector", inspector.Close)
+	return inspector
+}
+
+func NewAsynqServer(cfg *config.Config) *asynq.Server {
 	opt := getAsynqRedisClientOpt()
 	srv := asynq.NewServer(
 		opt,
@@ -45,16 +58,46 @@ func NewAsynqServer() *asynq.Server {
 				"default":  3, // Default priority queue
 				"low":      1, // Lowest priority queue
 			},
+			RetryDelayFunc: func(n int, err error, task *asynq.Task) time.Duration {
+				if task.Type() == types.TypeDocumentProcess && cfg.Ingestion != nil {
+					return documentRetryDelay(cfg.Ingestion, n, err)
+				}
+				return asynq.DefaultRetryDelayFunc(n, err, task)
+			},
+			// A task waiting for its tenant's concurrency limit has not failed and keeps its retries
+			IsFailure: func(err error) bool {
+				return !errors.Is(err, types.ErrTenantIngestionBusy)
+			},
 		},
 	)
 	return srv
 }
 
+// documentRetryDelay returns the delay before the n-th retry of a document task, doubling from RetryBaseDelay
+func documentRetryDelay(cfg *config.IngestionConfig, n int, err error) time.Duration {
+	if errors.Is(err, types.ErrTenantIngestionBusy) {
+		return cfg.TenantBusyDelay
+	}
+	delay := cfg.RetryBaseDelay
+	for i := 0; i < n && delay < cfg.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if cfg.RetryMaxDelay > 0 && delay > cfg.RetryMaxDelay {
+		delay = cfg.RetryMaxDelay
+	}
+	return delay
+}
+
 func RunAsynqServer(params AsynqTaskParams) *asynq.ServeMux {
 	// Create a new mux and register all handlers
 	mux := asynq.NewServeMux()
 
 	mux.HandleFunc(types.TypeChunkExtract, params.Extracter.Extract)
+	mux.HandleFunc(types.TypeDocumentProcess, params.KnowledgeService.ProcessDocument)
+	mux.HandleFunc(types.TypeKnowledgeSync, params.KnowledgeService.SyncKnowledge)
+	mux.HandleFunc(types.TypeImportTaskRun, params.ImportTaskService.RunTask)
+	mux.HandleFunc(types.TypeImportTaskSchedule, params.ImportTaskService.ScheduleTasks)
+	mux.HandleFunc(types.TypeDatasetGenerate, params.DatasetService.RunGenerationTask)
 
 	go func() {
 		// Start the server
@@ -64,3 +107,44 @@ func RunAsynqServer(params AsynqTaskParams) *asynq.ServeMux {
 	}()
 	return mux
 }
+
+// RunAsynqScheduler enqueues the URL knowledge re-sync scan every ingestion.sync_scan_interval
+// and the scan for due recurring import tasks every minute.
+// Every server instance runs a scheduler, a scan is unique within its interval so it runs once.
+func RunAsynqScheduler(cfg *config.Config, cleaner interfaces.ResourceCleaner) error {
+	queue := "default"
+	if cfg.Ingestion != nil && cfg.Ingestion.Queue != "" {
+		queue = cfg.Ingestion.Queue
+	}
+	scheduler := asynq.NewScheduler(getAsynqRedisClientOpt(), nil)
+	if cfg.Ingestion != nil && cfg.Ingestion.SyncScanInterval > 0 {
+		interval := cfg.Ingestion.SyncScanInterval
+		if _, err := scheduler.Register(
+			fmt.Sprintf("@every %s", interval),
+			asynq.NewTask(types.TypeKnowledgeSync, nil),
+			asynq.Queue(queue),
+			asynq.MaxRetry(0),
+			asynq.Unique(interval),
+		); err != nil {
+			return fmt.Errorf("register knowledge sync task: %w", err)
+		}
+	}
+	// Import task schedules are cron expressions, a minute is their finest resolution
+	if _, err := scheduler.Register(
+		"@every 1m",
+		asynq.NewTask(types.TypeImportTaskSchedule, nil),
+		asynq.Queue(queue),
+		asynq.MaxRetry(0),
+		asynq.Unique(time.Minute),
+	); err != nil {
+		return fmt.Errorf("register import task schedule: %w", err)
+	}
+	if err := scheduler.Start(); err != nil {
+		return fmt.Errorf("start asynq scheduler: %w", err)
+	}
+	cleaner.RegisterWithName("AsynqScheduler", func() error {
+		scheduler.Shutdown()
+		return nil
+	})
+	return nil
+}
@@ -0,0 +1,42 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/config"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+func TestDocumentRetryDelay(t *testing.T) {
+	cfg := &config.IngestionConfig{
+		RetryBaseDelay:  10 * time.Second,
+		RetryMaxDelay:   time.Minute,
+		TenantBusyDelay: 5 * time.Second,
+	}
+	tests := []struct {
+		name     string
+		retried  int
+		err      error
+		expected time.Duration
+	}{
+		{name: "first retry", retried: 0, err: errors.New("timeout"), expected: 10 * time.Second},
+		{name: "delay doubles", retried: 2, err: errors.New("timeout"), expected: 40 * time.Second},
+		{name: "delay is capped", retried: 5, err: errors.New("timeout"), expected: time.Minute},
+		{
+			name:     "busy tenant",
+			retried:  3,
+			err:      fmt.Errorf("knowledge 1: %w", types.ErrTenantIngestionBusy),
+			expected: 5 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := documentRetryDelay(cfg, tt.retried, tt.err); got != tt.expected {
+				t.Errorf("documentRetryDelay() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
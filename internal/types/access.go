@@ -0,0 +1,72 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// AccessLabels restrict who can retrieve a knowledge entry and its chunks.
+// Knowledge without labels is public, labelled knowledge is only retrieved for an audience holding one of its labels.
+type AccessLabels []string
+
+// Normalize trims the labels and drops empty and repeated ones, the result is sorted
+func (l AccessLabels) Normalize() AccessLabels {
+	if l == nil {
+		return nil
+	}
+	labels := make(AccessLabels, 0, len(l))
+	for _, label := range l {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	slices.Sort(labels)
+	return slices.Compact(labels)
+}
+
+// Validate checks that every label only uses letters, digits, underscores and hyphens
+func (l AccessLabels) Validate() error {
+	for _, label := range l {
+		if !isSafeName(label) {
+			return fmt.Errorf("invalid access label: %q", label)
+		}
+	}
+	return nil
+}
+
+// Allows returns true if the audience may retrieve content with the labels
+func (l AccessLabels) Allows(audience []string) bool {
+	if len(l) == 0 {
+		return true
+	}
+	for _, label := range audience {
+		if slices.Contains(l, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements the driver.Valuer interface, used to convert AccessLabels to database value.
+// No labels are stored as NULL, which the retrieval engines treat as public.
+func (l AccessLabels) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(l)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to AccessLabels
+func (l *AccessLabels) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, l)
+}
@@ -0,0 +1,47 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAccessLabelsNormalize(t *testing.T) {
+	labels := (AccessLabels{" legal", "hr", "", "legal"}).Normalize()
+	if expected := (AccessLabels{"hr", "legal"}); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("expected %v, got %v", expected, labels)
+	}
+	if labels := (AccessLabels{" "}).Normalize(); labels == nil || len(labels) != 0 {
+		t.Errorf("expected empty labels that clear the labels, got %#v", labels)
+	}
+	if err := (AccessLabels{"hr team"}).Validate(); err == nil {
+		t.Error("expected a label with a space to be invalid")
+	}
+}
+
+func TestAccessLabelsAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		labels   AccessLabels
+		audience []string
+		expected bool
+	}{
+		{name: "public knowledge without audience", expected: true},
+		{name: "public knowledge with audience", audience: []string{"hr"}, expected: true},
+		{name: "restricted knowledge without audience", labels: AccessLabels{"hr"}},
+		{name: "restricted knowledge with other audience", labels: AccessLabels{"hr"}, audience: []string{"legal"}},
+		{
+			name:     "restricted knowledge with matching audience",
+			labels:   AccessLabels{"hr", "legal"},
+			audience: []string{"sales", "legal"},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.labels.Allows(tt.audience); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -27,6 +27,10 @@ const (
 	ResponseTypeAnswer ResponseType = "answer"
 	// References response type
 	ResponseTypeReferences ResponseType = "references"
+	// Citation response type
+	ResponseTypeCitation ResponseType = "citation"
+	// Grounding response type
+	ResponseTypeGrounding ResponseType = "grounding"
 )
 
 // StreamResponse stream response
@@ -41,6 +45,10 @@ type StreamResponse struct {
 	Done bool `json:"done"`
 	// Knowledge references
 	KnowledgeReferences References `json:"knowledge_references"`
+	// Citations parsed from the answer
+	Citations Citations `json:"citations,omitempty"`
+	// Groundedness of the complete answer
+	Grounding *AnswerGrounding `json:"grounding,omitempty"`
 }
 
 // References references
@@ -62,3 +70,80 @@ func (c *References) Scan(value interface{}) error {
 	}
 	return json.Unmarshal(b, c)
 }
+
+// Citation maps a span of the answer to the knowledge reference cited by an [n] marker.
+// Offsets count characters (runes) of the answer content including the markers.
+type Citation struct {
+	// Number n of the [n] marker
+	Index int `json:"index"`
+	// Offset of the first character of the cited text
+	Start int `json:"start"`
+	// Offset after the last character of the cited text
+	End int `json:"end"`
+	// Offset of the [n] marker
+	MarkerOffset int `json:"marker_offset"`
+	// ID of the cited chunk
+	ChunkID string `json:"chunk_id"`
+	// ID of the knowledge the cited chunk belongs to
+	KnowledgeID string `json:"knowledge_id"`
+}
+
+// Citations citations
+type Citations []*Citation
+
+// Value implements the driver.Valuer interface, used to convert Citations to database values
+func (c Citations) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database values to Citations
+func (c *Citations) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, c)
+}
+
+// AnswerGrounding records how well an answer is supported by the retrieved chunks
+type AnswerGrounding struct {
+	// Groundedness of the answer from 0 to 1, the mean of the sentence scores
+	Score float64 `json:"score"`
+	// Scoring method: nli or embedding
+	Method string `json:"method"`
+	// Action taken because the score is below the threshold: disclaimer or fallback
+	Action string `json:"action,omitempty"`
+	// Scores of the answer sentences
+	Sentences []*SentenceGrounding `json:"sentences"`
+}
+
+// SentenceGrounding is the groundedness of one answer sentence.
+// Offsets count characters (runes) of the answer content like the citation offsets.
+type SentenceGrounding struct {
+	// Offset of the first character of the sentence
+	Start int `json:"start"`
+	// Offset after the last character of the sentence
+	End int `json:"end"`
+	// Groundedness of the sentence from 0 to 1
+	Score float64 `json:"score"`
+}
+
+// Value implements the driver.Valuer interface, used to convert AnswerGrounding to database values
+func (g AnswerGrounding) Value() (driver.Value, error) {
+	return json.Marshal(g)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database values to AnswerGrounding
+func (g *AnswerGrounding) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, g)
+}
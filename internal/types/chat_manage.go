@@ -1,5 +1,10 @@
 package types
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
 // ChatManage represents the configuration and state for a chat session
 // including query processing, search parameters, and model configurations
 type ChatManage struct {
@@ -9,11 +14,15 @@ type ChatManage struct {
 	RewriteQuery   string     `json:"rewrite_query,omitempty"`   // Query after rewriting for better retrieval
 	History        []*History `json:"history,omitempty"`         // Chat history for context
 
-	KnowledgeBaseID  string  `json:"knowledge_base_id"` // ID of the knowledge base to search against
-	VectorThreshold  float64 `json:"vector_threshold"`  // Minimum score threshold for vector search results
-	KeywordThreshold float64 `json:"keyword_threshold"` // Minimum score threshold for keyword search results
-	EmbeddingTopK    int     `json:"embedding_top_k"`   // Number of top results to retrieve from embedding search
-	VectorDatabase   string  `json:"vector_database"`   // Vector database type/name to use
+	KnowledgeBaseID  string                `json:"knowledge_base_id"` // ID of the knowledge base to search against
+	KnowledgeBases   SessionKnowledgeBases `json:"knowledge_bases"`   // Weighted knowledge bases to fan out the search to
+	VectorThreshold  float64               `json:"vector_threshold"`  // Minimum score threshold for vector search results
+	KeywordThreshold float64               `json:"keyword_threshold"` // Minimum score threshold for keyword search results
+	EmbeddingTopK    int                   `json:"embedding_top_k"`   // Number of top results to retrieve from embedding search
+	VectorDatabase   string                `json:"vector_database"`   // Vector database type/name to use
+	Fusion           *FusionConfig         `json:"fusion"`            // How vector and keyword results are fused
+	Filters          MetadataFilters       `json:"filters"`           // Metadata filters applied to retrieval
+	Audience         []string              `json:"audience"`          // Access labels of the audience, restrict the retrievable knowledge
 
 	RerankModelID   string  `json:"rerank_model_id"`  // Model ID for reranking search results
 	RerankTopK      int     `json:"rerank_top_k"`     // Number of top results after reranking
@@ -23,8 +32,14 @@ type ChatManage struct {
 	SummaryConfig    SummaryConfig    `json:"summary_config"`    // Configuration for summary generation
 	FallbackStrategy FallbackStrategy `json:"fallback_strategy"` // Strategy when no relevant results are found
 	FallbackResponse string           `json:"fallback_response"` // Default response when fallback occurs
+	Pipeline         string           `json:"pipeline"`          // Name of the pipeline the request runs through
+
+	// Per-plugin options of the resolved pipeline, keyed by event type
+	PipelineOptions map[EventType]map[string]interface{} `json:"-"`
 
 	// Internal fields for pipeline data processing
+	ExpandedQueries []string `json:"-"` // Paraphrased queries and hypothetical answers used as extra search queries
+
 	SearchResult []*SearchResult       `json:"-"` // Results from search phase
 	RerankResult []*SearchResult       `json:"-"` // Results after reranking
 	MergeResult  []*SearchResult       `json:"-"` // Final merged results after all processing
@@ -33,6 +48,48 @@ type ChatManage struct {
 	UserContent  string                `json:"-"` // Processed user content
 	ChatResponse *ChatResponse         `json:"-"` // Final response from chat model
 	ResponseChan <-chan StreamResponse `json:"-"` // Channel for streaming responses
+
+	ContextBudget *ContextBudget `json:"-"` // Token budget decisions made when building the chat message
+	// Merge results numbered in the chat message for citation, the [n] marker cites CitationReferences[n-1]
+	CitationReferences []*SearchResult  `json:"-"`
+	Grounding          *AnswerGrounding `json:"-"` // Groundedness of a non-streamed answer
+}
+
+// Value implements the driver.Valuer interface, used to store the parameters of evaluation tasks
+func (c ChatManage) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to ChatManage
+func (c *ChatManage) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, c)
+}
+
+// ContextBudget records how the retrieved context was fitted into the context window of the chat model
+type ContextBudget struct {
+	Tokenizer        string `json:"tokenizer"`         // Tokenizer used to count tokens
+	ContextLength    int    `json:"context_length"`    // Context window of the chat model, 0 means unlimited
+	CompletionTokens int    `json:"completion_tokens"` // Tokens reserved for the completion
+	HistoryTokens    int    `json:"history_tokens"`    // Tokens of the chat history sent with the message
+	PromptTokens     int    `json:"prompt_tokens"`     // Tokens of the system prompt, context template and query
+	ContextTokens    int    `json:"context_tokens"`    // Tokens available for the retrieved context
+	UsedTokens       int    `json:"used_tokens"`       // Tokens of the retrieved context actually sent
+	KeptChunks       int    `json:"kept_chunks"`       // Chunks sent in full
+	TrimmedChunks    int    `json:"trimmed_chunks"`    // Chunks cut to fit the budget
+	SummarizedChunks int    `json:"summarized_chunks"` // Chunks compressed into a summary to fit the budget
+	DroppedChunks    int    `json:"dropped_chunks"`    // Chunks left out of the message
+}
+
+// Limited returns true if the retrieved context has a token limit
+func (b *ContextBudget) Limited() bool {
+	return b.ContextLength > 0
 }
 
 // Clone creates a deep copy of the ChatManage object
@@ -43,10 +100,14 @@ func (c *ChatManage) Clone() *ChatManage {
 		RewriteQuery:     c.RewriteQuery,
 		SessionID:        c.SessionID,
 		KnowledgeBaseID:  c.KnowledgeBaseID,
+		KnowledgeBases:   append(SessionKnowledgeBases(nil), c.KnowledgeBases...),
 		VectorThreshold:  c.VectorThreshold,
 		KeywordThreshold: c.KeywordThreshold,
 		EmbeddingTopK:    c.EmbeddingTopK,
 		VectorDatabase:   c.VectorDatabase,
+		Fusion:           c.Fusion,
+		Filters:          c.Filters,
+		Audience:         c.Audience,
 		RerankModelID:    c.RerankModelID,
 		RerankTopK:       c.RerankTopK,
 		RerankThreshold:  c.RerankThreshold,
@@ -67,9 +128,17 @@ func (c *ChatManage) Clone() *ChatManage {
 		},
 		FallbackStrategy: c.FallbackStrategy,
 		FallbackResponse: c.FallbackResponse,
+		Pipeline:         c.Pipeline,
+		PipelineOptions:  c.PipelineOptions,
 	}
 }
 
+// GetKnowledgeBases returns the knowledge bases the search should fan out to,
+// falling back to the single KnowledgeBaseID when no list is configured
+func (c *ChatManage) GetKnowledgeBases() SessionKnowledgeBases {
+	return resolveKnowledgeBases(c.KnowledgeBaseID, c.KnowledgeBases)
+}
+
 // EventType represents different stages in the RAG (Retrieval Augmented Generation) pipeline
 type EventType string
 
@@ -85,25 +154,28 @@ const (
 	CHAT_COMPLETION_STREAM EventType = "chat_completion_stream" // Stream chat completion
 	STREAM_FILTER          EventType = "stream_filter"          // Filter streaming output
 	FILTER_TOP_K           EventType = "filter_top_k"           // Keep only top K results
+	ANSWER_GROUNDING       EventType = "answer_grounding"       // Check that the answer is supported by the chunks
 )
 
-// Pipline defines the sequence of events for different chat modes
+// Pipline defines the sequence of events for the built-in chat modes,
+// additional pipelines can be declared in the pipelines section of config.yaml
 var Pipline = map[string][]EventType{
-	"chat": { // Simple chat without retrieval
+	PipelineChat: { // Simple chat without retrieval
 		CHAT_COMPLETION,
 	},
-	"chat_stream": { // Streaming chat without retrieval
+	PipelineChatStream: { // Streaming chat without retrieval
 		CHAT_COMPLETION_STREAM,
 		STREAM_FILTER,
 	},
-	"rag": { // Retrieval Augmented Generation
+	PipelineRAG: { // Retrieval Augmented Generation
 		CHUNK_SEARCH,
 		CHUNK_RERANK,
 		CHUNK_MERGE,
 		INTO_CHAT_MESSAGE,
 		CHAT_COMPLETION,
+		ANSWER_GROUNDING,
 	},
-	"rag_stream": { // Streaming Retrieval Augmented Generation
+	PipelineRAGStream: { // Streaming Retrieval Augmented Generation
 		REWRITE_QUERY,
 		PREPROCESS_QUERY,
 		CHUNK_SEARCH,
@@ -114,5 +186,18 @@ var Pipline = map[string][]EventType{
 		INTO_CHAT_MESSAGE,
 		CHAT_COMPLETION_STREAM,
 		STREAM_FILTER,
+		ANSWER_GROUNDING,
+	},
+	PipelineSearch: { // Retrieval only, without LLM summarization
+		PREPROCESS_QUERY,
+		CHUNK_SEARCH,
+		CHUNK_RERANK,
+		CHUNK_MERGE,
+		FILTER_TOP_K,
+	},
+	PipelineRetrieval: { // Retrieval only, used by the parameter sweeps of evaluations
+		CHUNK_SEARCH,
+		CHUNK_RERANK,
+		CHUNK_MERGE,
 	},
 }
@@ -3,6 +3,8 @@
 package types
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -24,8 +26,20 @@ const (
 	ChunkTypeEntity ChunkType = "entity"
 	// ChunkTypeRelationship 表示关系类型的 Chunk
 	ChunkTypeRelationship ChunkType = "relationship"
+	// ChunkTypeChildText 表示父子分块模式下用于检索的子文本 Chunk，命中后返回其父 Chunk
+	ChunkTypeChildText ChunkType = "child_text"
+	// ChunkTypeFAQ 表示问答对 Chunk，包含问题和答案，只通过其问题 Chunk 检索
+	ChunkTypeFAQ ChunkType = "faq"
+	// ChunkTypeFAQQuestion 表示问答对的问题或相似问题 Chunk，命中后返回其问答对 Chunk
+	ChunkTypeFAQQuestion ChunkType = "faq_question"
 )
 
+// RetrievesParent reports whether chunks of the type are indexed in place of their parent chunk,
+// which is only retrieved through them
+func (t ChunkType) RetrievesParent() bool {
+	return t == ChunkTypeChildText || t == ChunkTypeFAQQuestion
+}
+
 // ImageInfo 表示与 Chunk 关联的图片信息
 type ImageInfo struct {
 	// 图片URL（COS）
@@ -81,6 +95,10 @@ type Chunk struct {
 	IndirectRelationChunks JSON `json:"indirect_relation_chunks" gorm:"type:json"`
 	// 图片信息，存储为 JSON
 	ImageInfo string `json:"image_info" gorm:"type:text"`
+	// Metadata recorded by the chunking strategy, e.g. the heading path of a Markdown section
+	Metadata *ChunkMetadata `json:"metadata,omitempty" gorm:"type:json"`
+	// SimHash of the content, near-duplicate chunks differ in few bits
+	SimHash int64 `json:"-"`
 	// Chunk creation time
 	CreatedAt time.Time `json:"created_at"`
 	// Chunk last update time
@@ -88,3 +106,82 @@ type Chunk struct {
 	// Soft delete marker, supports data recovery
 	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
+
+// ChunkMetadata represents the metadata the chunking strategy records for a text chunk
+type ChunkMetadata struct {
+	// Headings of the Markdown sections containing the chunk, outermost first
+	SectionPath []string `json:"section_path,omitempty"`
+	// Question-answer pair of an FAQ chunk
+	FAQ *FAQEntry `json:"faq,omitempty"`
+	// Metadata of the knowledge that retrieval can filter on, copied when the chunk is stored
+	Attributes IndexMetadata `json:"attributes,omitempty"`
+	// Access labels inherited from the knowledge
+	AccessLabels AccessLabels `json:"access_labels,omitempty"`
+	// ID of the earlier knowledge the chunk nearly duplicates, such chunks are stored but not indexed
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+// GetSectionPath returns the section path, nil for chunks without metadata
+func (m *ChunkMetadata) GetSectionPath() []string {
+	if m == nil {
+		return nil
+	}
+	return m.SectionPath
+}
+
+// GetFAQ returns the question-answer pair, nil for chunks that are not FAQ chunks
+func (m *ChunkMetadata) GetFAQ() *FAQEntry {
+	if m == nil {
+		return nil
+	}
+	return m.FAQ
+}
+
+// GetAccessLabels returns the access labels, nil for chunks without metadata
+func (m *ChunkMetadata) GetAccessLabels() AccessLabels {
+	if m == nil {
+		return nil
+	}
+	return m.AccessLabels
+}
+
+// GetDuplicateOf returns the knowledge the chunk nearly duplicates, empty for chunks without metadata
+func (m *ChunkMetadata) GetDuplicateOf() string {
+	if m == nil {
+		return ""
+	}
+	return m.DuplicateOf
+}
+
+// IndexMetadata returns the metadata indexed with the chunk: its attributes and section path
+func (m *ChunkMetadata) IndexMetadata() IndexMetadata {
+	if m == nil {
+		return nil
+	}
+	own := make(map[string]any)
+	if len(m.SectionPath) > 0 {
+		sectionPath := make([]any, len(m.SectionPath))
+		for i, heading := range m.SectionPath {
+			sectionPath[i] = heading
+		}
+		own["section_path"] = sectionPath
+	}
+	return NewIndexMetadata(m.Attributes, own)
+}
+
+// Value implements the driver.Valuer interface, used to convert ChunkMetadata to database value
+func (m ChunkMetadata) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to ChunkMetadata
+func (m *ChunkMetadata) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, m)
+}
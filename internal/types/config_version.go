@@ -0,0 +1,46 @@
+package types
+
+import "time"
+
+// ConfigVersionSource is how a config version was created
+type ConfigVersionSource string
+
+const (
+	// ConfigVersionSourceStartup is the config loaded when the server started
+	ConfigVersionSourceStartup ConfigVersionSource = "startup"
+	// ConfigVersionSourceFile is a change of the config file picked up while running
+	ConfigVersionSourceFile ConfigVersionSource = "file"
+	// ConfigVersionSourceRollback is an earlier version restored through the API
+	ConfigVersionSourceRollback ConfigVersionSource = "rollback"
+)
+
+// ConfigVersion is an applied version of the hot reloadable conversation config
+type ConfigVersion struct {
+	// Version number, increases with every applied change
+	ID uint `json:"id" gorm:"primaryKey;autoIncrement"`
+	// How the version was created
+	Source ConfigVersionSource `json:"source" gorm:"type:varchar(20)"`
+	// Version restored by a rollback
+	RestoredFrom uint `json:"restored_from,omitempty"`
+	// SHA-256 of the content, used to skip unchanged reloads
+	Checksum string `json:"checksum" gorm:"type:varchar(64)"`
+	// Conversation config of the version
+	Content JSON `json:"content,omitempty" gorm:"type:json"`
+	// Creation time of the version
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName returns the table name of ConfigVersion
+func (ConfigVersion) TableName() string {
+	return "config_versions"
+}
+
+// ConfigFieldDiff is a config field that differs between two versions
+type ConfigFieldDiff struct {
+	// YAML path of the field, e.g. conversation.summary.prompt
+	Path string `json:"path"`
+	// Value in the older version, empty if the field was added
+	Old string `json:"old"`
+	// Value in the newer version, empty if the field was removed
+	New string `json:"new"`
+}
@@ -12,6 +12,8 @@ const (
 	RequestIDContextKey ContextKey = "RequestID"
 	// LoggerContextKey is the context key for logger
 	LoggerContextKey ContextKey = "Logger"
+	// QueryEmbeddingCacheContextKey is the context key for the cache of query embeddings reused across searches
+	QueryEmbeddingCacheContextKey ContextKey = "QueryEmbeddingCache"
 )
 
 // String returns the string representation of the context key
@@ -1,5 +1,21 @@
 package types
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultDatasetID is the ID of the built-in sample dataset
+const DefaultDatasetID = "default"
+
+// DatasetFormatGenerated is the format of a dataset generated from a knowledge base
+const DatasetFormatGenerated = "generated"
+
+// TypeDatasetGenerate generates an evaluation dataset from the chunks of a knowledge base
+const TypeDatasetGenerate = "dataset:generate"
+
 // QAPair represents a complete QA example with question, related passages and answer
 type QAPair struct {
 	QID      int      // Question ID
@@ -8,4 +24,112 @@ type QAPair struct {
 	Passages []string // Passage texts
 	AID      int      // Answer ID
 	Answer   string   // Answer text
+
+	ChunkIDs     []string // Gold chunk IDs in an existing knowledge base
+	KnowledgeIDs []string // Gold knowledge IDs in an existing knowledge base
+}
+
+// Dataset is an evaluation dataset uploaded by a tenant
+type Dataset struct {
+	// Unique identifier of the dataset
+	ID string `json:"id" gorm:"type:varchar(36);primaryKey"`
+	// Tenant ID
+	TenantID uint `json:"tenant_id" gorm:"index"`
+	// Name of the dataset
+	Name string `json:"name" gorm:"type:varchar(255)"`
+	// Description of the dataset
+	Description string `json:"description"`
+	// Format of the uploaded file: parquet, jsonl or csv, generated for a dataset generated from a knowledge base
+	Format string `json:"format" gorm:"type:varchar(20)"`
+	// Name of the uploaded file
+	FileName string `json:"file_name" gorm:"type:varchar(255)"`
+	// Number of records
+	RecordCount int `json:"record_count"`
+	// Creation time
+	CreatedAt time.Time `json:"created_at"`
+	// Last update time
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BeforeCreate hook generates a UUID for new Dataset entities before they are created.
+func (d *Dataset) BeforeCreate(tx *gorm.DB) (err error) {
+	d.ID = uuid.New().String()
+	return nil
+}
+
+// DatasetRecord is a question of a dataset with its reference answer and gold references.
+// Gold passages are ingested into a fresh knowledge base for the evaluation, gold chunk and knowledge IDs
+// refer to an existing knowledge base the evaluation targets directly.
+type DatasetRecord struct {
+	ID        string `json:"-" gorm:"type:varchar(36);primaryKey"`
+	TenantID  uint   `json:"-"`
+	DatasetID string `json:"dataset_id" gorm:"type:varchar(36);index"`
+	// Question ID, unique in the dataset
+	QuestionID int `json:"question_id"`
+	// Question text
+	Question string `json:"question"`
+	// Reference answer
+	Answer string `json:"answer"`
+	// Gold passages
+	Passages StringArray `json:"passages" gorm:"type:json"`
+	// Gold chunk IDs
+	ChunkIDs StringArray `json:"chunk_ids" gorm:"type:json"`
+	// Gold knowledge IDs
+	KnowledgeIDs StringArray `json:"knowledge_ids" gorm:"type:json"`
+}
+
+// BeforeCreate hook generates a UUID for new DatasetRecord entities before they are created.
+func (r *DatasetRecord) BeforeCreate(tx *gorm.DB) (err error) {
+	r.ID = uuid.New().String()
+	return nil
+}
+
+type DatasetGenerationStatus string
+
+const (
+	DatasetGenerationStatusPending    DatasetGenerationStatus = "pending"
+	DatasetGenerationStatusProcessing DatasetGenerationStatus = "processing"
+	DatasetGenerationStatusCompleted  DatasetGenerationStatus = "completed"
+	DatasetGenerationStatusFailed     DatasetGenerationStatus = "failed"
+	DatasetGenerationStatusCancelled  DatasetGenerationStatus = "cancelled"
+)
+
+// DatasetGenerationTask generates an evaluation dataset from a knowledge base. A chat model writes a question
+// and a reference answer for every sampled chunk, the pairs passing the quality checks are stored as a dataset
+// whose gold chunk of every question is the chunk it was written for.
+type DatasetGenerationTask struct {
+	ID              string                  `json:"id" gorm:"type:varchar(36);primaryKey"`
+	TenantID        uint                    `json:"tenant_id" gorm:"index"`
+	KnowledgeBaseID string                  `json:"knowledge_base_id" gorm:"type:varchar(36)"`
+	ChatModelID     string                  `json:"chat_model_id" gorm:"type:varchar(64)"`
+	Name            string                  `json:"name" gorm:"type:varchar(255)"`
+	Description     string                  `json:"description"`
+	Status          DatasetGenerationStatus `json:"status" gorm:"type:varchar(20)"`
+	// QuestionCount is the number of questions to generate
+	QuestionCount int `json:"question_count"`
+	// ProcessedChunks is the number of chunks sent to the chat model so far
+	ProcessedChunks int `json:"processed_chunks"`
+	// AcceptedCount is the number of questions that passed the quality checks
+	AcceptedCount int `json:"accepted_count"`
+	// RejectedCount is the number of chunks for which no acceptable question was written
+	RejectedCount int `json:"rejected_count"`
+	// DatasetID is the generated dataset, set once the task completes
+	DatasetID    string     `json:"dataset_id" gorm:"type:varchar(36)"`
+	ErrorMessage string     `json:"error_message"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	CompletedAt  *time.Time `json:"completed_at"`
+}
+
+func (t *DatasetGenerationTask) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// DatasetGenerationPayload is the payload of a TypeDatasetGenerate task
+type DatasetGenerationPayload struct {
+	TenantID uint   `json:"tenant_id"`
+	TaskID   string `json:"task_id"`
 }
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// MinHashSignature is the MinHash signature of the content of a knowledge entry
+type MinHashSignature []uint32
+
+// Value implements the driver.Valuer interface, empty signatures are stored as NULL
+func (s MinHashSignature) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	return json.Marshal([]uint32(s))
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to MinHashSignature
+func (s *MinHashSignature) Scan(value interface{}) error {
+	if value == nil {
+		*s = nil
+		return nil
+	}
+	var b []byte
+	switch v := value.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return nil
+	}
+	return json.Unmarshal(b, (*[]uint32)(s))
+}
+
+// DuplicateCluster is a group of knowledge in a knowledge base whose contents nearly duplicate each other
+type DuplicateCluster struct {
+	// Knowledge in the cluster, earliest created first
+	Knowledge []*DuplicateClusterMember `json:"knowledge"`
+}
+
+// DuplicateClusterMember is a knowledge entry of a duplicate cluster
+type DuplicateClusterMember struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Type        string    `json:"type"`
+	Source      string    `json:"source"`
+	FileName    string    `json:"file_name"`
+	ParseStatus string    `json:"parse_status"`
+	DuplicateOf string    `json:"duplicate_of"`
+	CreatedAt   time.Time `json:"created_at"`
+	// Estimated similarity to the first knowledge of the cluster
+	Similarity float64 `json:"similarity"`
+}
@@ -24,11 +24,13 @@ const (
 
 // IndexInfo contains information about indexed content
 type IndexInfo struct {
-	ID              string     // Unique identifier
-	Content         string     // Content text
-	SourceID        string     // ID of the source document
-	SourceType      SourceType // Type of the source
-	ChunkID         string     // ID of the text chunk
-	KnowledgeID     string     // ID of the knowledge
-	KnowledgeBaseID string     // ID of the knowledge base
+	ID              string        // Unique identifier
+	Content         string        // Content text
+	SourceID        string        // ID of the source document
+	SourceType      SourceType    // Type of the source
+	ChunkID         string        // ID of the text chunk
+	KnowledgeID     string        // ID of the knowledge
+	KnowledgeBaseID string        // ID of the knowledge base
+	Metadata        IndexMetadata // Knowledge and chunk metadata that retrieval can filter on
+	AccessLabels    AccessLabels  // Access labels inherited from the knowledge, empty means public
 }
@@ -1,10 +1,13 @@
 package types
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/yanyiwu/gojieba"
+	"gorm.io/gorm"
 )
 
 // Jieba is a global instance of Chinese text segmentation tool
@@ -20,25 +23,86 @@ const (
 	EvaluationStatueFailed                          // Task failed
 )
 
-// EvaluationTask contains information about an evaluation task
+// EvaluationTask contains information about an evaluation task, it is stored with its parameters and metrics
 type EvaluationTask struct {
-	ID        string `json:"id"`         // Unique task ID
-	TenantID  uint   `json:"tenant_id"`  // Tenant/Organization ID
-	DatasetID string `json:"dataset_id"` // Dataset ID for evaluation
-
-	StartTime time.Time        `json:"start_time"`        // Task start time
-	Status    EvaluationStatue `json:"status"`            // Current task status
-	ErrMsg    string           `json:"err_msg,omitempty"` // Error message if failed
+	ID               string `json:"id" gorm:"type:varchar(36);primaryKey"` // Unique task ID
+	TenantID         uint   `json:"tenant_id" gorm:"index"`                // Tenant/Organization ID
+	DatasetID        string `json:"dataset_id"`                            // Dataset ID for evaluation
+	EmbeddingModelID string `json:"embedding_model_id"`                    // Embedding model of the evaluated knowledge base
+	UseExistingKB    bool   `json:"use_existing_kb"`                       // Whether the task evaluates an existing knowledge base
+	JudgeModelID     string `json:"judge_model_id,omitempty"`              // Chat model scoring the judge metrics, empty to skip them
+
+	StartTime time.Time        `json:"start_time"`         // Task start time
+	EndTime   *time.Time       `json:"end_time,omitempty"` // Time the task succeeded or failed
+	Status    EvaluationStatue `json:"status"`             // Current task status
+	ErrMsg    string           `json:"err_msg,omitempty"`  // Error message if failed
 
 	Total    int `json:"total,omitempty"`    // Total items to evaluate
 	Finished int `json:"finished,omitempty"` // Completed items count
+
+	Params *ChatManage   `json:"-" gorm:"type:json"` // Evaluation parameters, returned in EvaluationDetail
+	Metric *MetricResult `json:"-" gorm:"type:json"` // Evaluation metrics, returned in EvaluationDetail
 }
 
 // EvaluationDetail contains detailed evaluation information
 type EvaluationDetail struct {
-	Task   *EvaluationTask `json:"task"`             // Evaluation task info
-	Params *ChatManage     `json:"params"`           // Evaluation parameters
-	Metric *MetricResult   `json:"metric,omitempty"` // Evaluation metrics
+	Task      *EvaluationTask       `json:"task"`                // Evaluation task info
+	Params    *ChatManage           `json:"params"`              // Evaluation parameters
+	Metric    *MetricResult         `json:"metric,omitempty"`    // Evaluation metrics
+	Questions []*EvaluationQuestion `json:"questions,omitempty"` // Results of the evaluated questions
+}
+
+// EvaluationQuestion is the result of a question of an evaluation task
+type EvaluationQuestion struct {
+	ID                string        `json:"-" gorm:"type:varchar(36);primaryKey"`
+	TenantID          uint          `json:"-"`
+	TaskID            string        `json:"task_id" gorm:"type:varchar(36);index"` // ID of the evaluation task
+	QuestionID        int           `json:"question_id"`                           // Question ID in the dataset
+	Question          string        `json:"question"`                              // Question text
+	ExpectedAnswer    string        `json:"expected_answer"`                       // Answer of the dataset
+	Answer            string        `json:"answer"`                                // Generated answer
+	RetrievedChunkIDs StringArray   `json:"retrieved_chunk_ids" gorm:"type:json"`  // Chunks after reranking, in rank order
+	Metric            *MetricResult `json:"metric" gorm:"type:json"`               // Metrics of the question
+	Judgements        Judgements    `json:"judgements,omitempty" gorm:"type:json"` // Prompts and responses of the judge metrics
+	CreatedAt         time.Time     `json:"created_at"`
+}
+
+// BeforeCreate hook generates a UUID for new EvaluationQuestion entities before they are created.
+func (q *EvaluationQuestion) BeforeCreate(tx *gorm.DB) (err error) {
+	q.ID = uuid.New().String()
+	return nil
+}
+
+// EvaluationComparison compares a target evaluation task with a base task metric by metric and question by question
+type EvaluationComparison struct {
+	Base      *EvaluationTask           `json:"base"`
+	Target    *EvaluationTask           `json:"target"`
+	Params    []*ConfigFieldDiff        `json:"params"`    // Parameters that differ, Old is the base value
+	Metrics   []*MetricDiff             `json:"metrics"`   // Average metrics of the tasks
+	Questions []*EvaluationQuestionDiff `json:"questions"` // Questions, regressed ones first
+}
+
+// MetricDiff is the score of a metric in the base and the target task
+type MetricDiff struct {
+	Name   string  `json:"name"`
+	Base   float64 `json:"base"`
+	Target float64 `json:"target"`
+	Delta  float64 `json:"delta"` // Target minus base
+}
+
+// EvaluationQuestionDiff compares the results of a question in the base and the target task
+type EvaluationQuestionDiff struct {
+	QuestionID int                 `json:"question_id"`
+	Question   string              `json:"question"`
+	Base       *EvaluationQuestion `json:"base"`   // Result in the base task, nil if it was not evaluated
+	Target     *EvaluationQuestion `json:"target"` // Result in the target task, nil if it was not evaluated
+	Metrics    []*MetricDiff       `json:"metrics"`
+	// Chunks retrieved only in the target task
+	AddedChunkIDs []string `json:"added_chunk_ids"`
+	// Chunks retrieved only in the base task
+	RemovedChunkIDs []string `json:"removed_chunk_ids"`
+	// Whether a metric of the question is lower in the target task
+	Regressed bool `json:"regressed"`
 }
 
 // String returns JSON representation of EvaluationTask
@@ -54,6 +118,42 @@ type MetricInput struct {
 
 	GeneratedTexts string // Generated text for evaluation
 	GeneratedGT    string // Ground truth text for comparison
+
+	Question string   // Question the text was generated for, used by the judge metrics
+	Contexts []string // Contents of the retrieved chunks in rank order, used by the judge metrics
+
+	// Judgements collects the prompts and responses of the judge metrics computed on the input
+	Judgements Judgements
+}
+
+// Judgement is the prompt and the raw response of a judge metric for a question, kept for auditing
+type Judgement struct {
+	Metric   string  `json:"metric"`          // Name of the judge metric
+	Prompt   string  `json:"prompt"`          // System prompt of the judge model
+	Input    string  `json:"input"`           // User message with the question, answers and retrieved chunks
+	Response string  `json:"response"`        // Raw response of the judge model
+	Score    float64 `json:"score"`           // Score parsed from the response
+	Error    string  `json:"error,omitempty"` // Why the metric was not scored, it then scores 0
+}
+
+// Judgements are the judgements of a question
+type Judgements []*Judgement
+
+// Value implements the driver.Valuer interface, used to convert Judgements to database value
+func (j Judgements) Value() (driver.Value, error) {
+	return json.Marshal(j)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to Judgements
+func (j *Judgements) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, j)
 }
 
 // MetricResult contains evaluation metrics
@@ -62,6 +162,23 @@ type MetricResult struct {
 	GenerationMetrics GenerationMetrics `json:"generation_metrics"` // Text generation quality metrics
 }
 
+// Value implements the driver.Valuer interface, used to convert MetricResult to database value
+func (r MetricResult) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to MetricResult
+func (r *MetricResult) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, r)
+}
+
 // RetrievalMetrics contains metrics for retrieval evaluation
 type RetrievalMetrics struct {
 	Precision float64 `json:"precision"` // Precision score
@@ -82,6 +199,16 @@ type GenerationMetrics struct {
 	ROUGE1 float64 `json:"rouge1"` // ROUGE-1 score
 	ROUGE2 float64 `json:"rouge2"` // ROUGE-2 score
 	ROUGEL float64 `json:"rougel"` // ROUGE-L score
+
+	// Judge metrics, scored by the judge model of the task
+	Faithfulness     float64 `json:"faithfulness"`      // Share of the answer's statements supported by the retrieved chunks
+	AnswerRelevance  float64 `json:"answer_relevance"`  // How directly the answer addresses the question
+	ContextPrecision float64 `json:"context_precision"` // Whether the useful chunks are ranked first
+	ContextRecall    float64 `json:"context_recall"`    // Share of the reference answer's statements found in the chunks
+
+	// JudgeFailures counts the questions each judge metric failed on by metric name,
+	// a failed judgement scores 0 and is left out of the average of its metric
+	JudgeFailures map[string]int `json:"judge_failures,omitempty"`
 }
 
 // EvalState represents different stages of evaluation process
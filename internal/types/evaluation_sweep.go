@@ -0,0 +1,110 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// SweepMode is how a parameter sweep picks the settings it tries
+type SweepMode string
+
+const (
+	// SweepModeGrid tries every combination of the parameter values
+	SweepModeGrid SweepMode = "grid"
+	// SweepModeRandom tries a random sample of the combinations
+	SweepModeRandom SweepMode = "random"
+)
+
+// SweepSpace lists the values a parameter sweep tries for each retrieval parameter,
+// an empty list tries only the value of the conversation config
+type SweepSpace struct {
+	EmbeddingTopK    []int     `json:"embedding_top_k"`
+	VectorThreshold  []float64 `json:"vector_threshold"`
+	KeywordThreshold []float64 `json:"keyword_threshold"`
+	RerankTopK       []int     `json:"rerank_top_k"`
+	RerankThreshold  []float64 `json:"rerank_threshold"`
+}
+
+// Value implements the driver.Valuer interface, used to convert SweepSpace to database value
+func (s SweepSpace) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to SweepSpace
+func (s *SweepSpace) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, s)
+}
+
+// SweepSettings are the retrieval parameters of a trial of a parameter sweep
+type SweepSettings struct {
+	EmbeddingTopK    int     `json:"embedding_top_k"`
+	VectorThreshold  float64 `json:"vector_threshold"`
+	KeywordThreshold float64 `json:"keyword_threshold"`
+	RerankTopK       int     `json:"rerank_top_k"`
+	RerankThreshold  float64 `json:"rerank_threshold"`
+}
+
+// SweepTrial is the result of a setting of a parameter sweep over the questions of the dataset
+type SweepTrial struct {
+	Settings     SweepSettings    `json:"settings"`
+	Metrics      RetrievalMetrics `json:"metrics"`        // Average retrieval metrics of the questions
+	AvgLatencyMs float64          `json:"avg_latency_ms"` // Average retrieval time of a question
+	P95LatencyMs float64          `json:"p95_latency_ms"` // 95th percentile retrieval time of a question
+	// Whether no other trial has better or equal recall, MRR, NDCG@10 and latency with at least one of them better
+	Pareto bool `json:"pareto"`
+}
+
+// SweepTrials are the trials of a parameter sweep
+type SweepTrials []*SweepTrial
+
+// Value implements the driver.Valuer interface, used to convert SweepTrials to database value
+func (t SweepTrials) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to SweepTrials
+func (t *SweepTrials) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, t)
+}
+
+// EvaluationSweep is a retrieval-only evaluation of a dataset under several retrieval settings,
+// it runs the search, rerank and merge steps without generating answers
+type EvaluationSweep struct {
+	ID               string     `json:"id" gorm:"type:varchar(36);primaryKey"`
+	TenantID         uint       `json:"tenant_id" gorm:"index"`
+	DatasetID        string     `json:"dataset_id"`
+	KnowledgeBaseID  string     `json:"knowledge_base_id"`  // Knowledge base searched by the trials
+	EmbeddingModelID string     `json:"embedding_model_id"` // Embedding model of the searched knowledge base
+	RerankModelID    string     `json:"rerank_model_id"`    // Rerank model, empty to skip reranking
+	UseExistingKB    bool       `json:"use_existing_kb"`    // Whether the sweep searches an existing knowledge base
+	Mode             SweepMode  `json:"mode"`
+	Samples          int        `json:"samples,omitempty"` // Number of settings sampled in random mode
+	Seed             int64      `json:"seed,omitempty"`    // Seed of the random sample
+	Space            SweepSpace `json:"space" gorm:"type:json"`
+
+	StartTime time.Time        `json:"start_time"`
+	EndTime   *time.Time       `json:"end_time,omitempty"`
+	Status    EvaluationStatue `json:"status"`
+	ErrMsg    string           `json:"err_msg,omitempty"`
+
+	Total    int         `json:"total"`                             // Number of trials
+	Finished int         `json:"finished"`                          // Number of finished trials
+	Trials   SweepTrials `json:"trials,omitempty" gorm:"type:json"` // Finished trials in the order they ran
+	// Pareto-optimal trials of a finished sweep, best recall first
+	Recommended SweepTrials `json:"recommended,omitempty" gorm:"-"`
+}
@@ -0,0 +1,86 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FAQEntry is a question-answer pair of FAQ knowledge.
+// The question and its similar questions are indexed, a hit on any of them returns the answer.
+type FAQEntry struct {
+	// Standard question
+	Question string `json:"question"`
+	// Other wordings of the question
+	SimilarQuestions []string `json:"similar_questions,omitempty"`
+	// Answer to the question
+	Answer string `json:"answer"`
+	// Category of the entry
+	Category string `json:"category,omitempty"`
+}
+
+// Normalize trims the entry and drops empty or repeated similar questions
+func (e *FAQEntry) Normalize() {
+	e.Question = strings.TrimSpace(e.Question)
+	e.Answer = strings.TrimSpace(e.Answer)
+	e.Category = strings.TrimSpace(e.Category)
+	seen := map[string]bool{e.Question: true}
+	var similar []string
+	for _, question := range e.SimilarQuestions {
+		question = strings.TrimSpace(question)
+		if question == "" || seen[question] {
+			continue
+		}
+		seen[question] = true
+		similar = append(similar, question)
+	}
+	e.SimilarQuestions = similar
+}
+
+// Validate checks that the entry has a question and an answer
+func (e *FAQEntry) Validate() error {
+	if strings.TrimSpace(e.Question) == "" {
+		return errors.New("question is required")
+	}
+	if strings.TrimSpace(e.Answer) == "" {
+		return errors.New("answer is required")
+	}
+	return nil
+}
+
+// Questions returns the question followed by its similar questions
+func (e *FAQEntry) Questions() []string {
+	return append([]string{e.Question}, e.SimilarQuestions...)
+}
+
+// GetFAQEntry decodes the question-answer pair kept in the metadata of FAQ knowledge
+func (k *Knowledge) GetFAQEntry() (*FAQEntry, error) {
+	var entry FAQEntry
+	if err := json.Unmarshal(k.Metadata, &entry); err != nil {
+		return nil, fmt.Errorf("decode FAQ entry of knowledge %s: %w", k.ID, err)
+	}
+	return &entry, nil
+}
+
+// FAQImportResult is the outcome of a bulk import of FAQ entries
+type FAQImportResult struct {
+	// Entries imported as new knowledge
+	Created int `json:"created"`
+	// Entries that replaced the knowledge with the same question
+	Updated int `json:"updated"`
+	// Entries identical to the knowledge with the same question
+	Unchanged int `json:"unchanged"`
+	// Entries that could not be imported
+	Failed []FAQImportError `json:"failed"`
+}
+
+// FAQImportError is an entry that could not be imported
+type FAQImportError struct {
+	// Position of the entry in the request
+	Index int `json:"index"`
+	// Question of the entry
+	Question string `json:"question"`
+	// Reason of the failure
+	Error string `json:"error"`
+}
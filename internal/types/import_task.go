@@ -1,12 +1,22 @@
 package types
 
 import (
+	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
 	"gorm.io/gorm"
 )
 
+const (
+	// TypeImportTaskRun crawls the docs site of an import task and imports its new and modified pages
+	TypeImportTaskRun = "import:run"
+	// TypeImportTaskSchedule enqueues a run of the recurring import tasks that are due
+	TypeImportTaskSchedule = "import:schedule"
+)
+
 type ImportTaskStatus string
 
 const (
@@ -28,6 +38,8 @@ type ImportTask struct {
 	SuccessCount    int              `json:"success_count"`
 	FailedCount     int              `json:"failed_count"`
 	DuplicateCount  int              `json:"duplicate_count"`
+	UnchangedCount  int              `json:"unchanged_count"`
+	RemovedCount    int              `json:"removed_count"`
 	CurrentURL      string           `json:"current_url"`
 	ErrorMessage    string           `json:"error_message"`
 	Config          JSON             `json:"config" gorm:"type:json"`
@@ -35,6 +47,10 @@ type ImportTask struct {
 	CreatedAt       time.Time        `json:"created_at"`
 	UpdatedAt       time.Time        `json:"updated_at"`
 	CompletedAt     *time.Time       `json:"completed_at"`
+	// Schedule is the cron expression of a recurring crawl, empty for a one-shot import
+	Schedule  string     `json:"schedule" gorm:"type:varchar(100)"`
+	NextRunAt *time.Time `json:"next_run_at" gorm:"index"`
+	LastRunAt *time.Time `json:"last_run_at"`
 }
 
 func (t *ImportTask) BeforeCreate(tx *gorm.DB) error {
@@ -48,13 +64,97 @@ func (t *ImportTask) TableName() string {
 	return "import_tasks"
 }
 
+// NextRun returns the first scheduled run of the task after the given time, nil for a one-shot import
+func (t *ImportTask) NextRun(after time.Time) (*time.Time, error) {
+	if t.Schedule == "" {
+		return nil, nil
+	}
+	schedule, err := cron.ParseStandard(t.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", t.Schedule, err)
+	}
+	next := schedule.Next(after)
+	return &next, nil
+}
+
 type ImportTaskConfig struct {
 	MaxPages         int   `json:"max_pages"`
 	EnableMultimodel *bool `json:"enable_multimodel"`
+	// IncludePatterns are regular expressions, only pages whose URL matches one of them are imported
+	IncludePatterns []string `json:"include_patterns,omitempty"`
+	// ExcludePatterns are regular expressions, pages whose URL matches one of them are neither crawled nor imported
+	ExcludePatterns []string `json:"exclude_patterns,omitempty"`
+}
+
+// Validate checks that the URL patterns compile
+func (c *ImportTaskConfig) Validate() error {
+	if _, err := compilePatterns(c.IncludePatterns); err != nil {
+		return fmt.Errorf("invalid include pattern: %w", err)
+	}
+	if _, err := compilePatterns(c.ExcludePatterns); err != nil {
+		return fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+	return nil
+}
+
+// Include returns the compiled include patterns
+func (c *ImportTaskConfig) Include() ([]*regexp.Regexp, error) {
+	return compilePatterns(c.IncludePatterns)
+}
+
+// Exclude returns the compiled exclude patterns
+func (c *ImportTaskConfig) Exclude() ([]*regexp.Regexp, error) {
+	return compilePatterns(c.ExcludePatterns)
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// ImportTaskPage is a page imported by an import task, later runs compare against it
+// to find the new, modified and disappeared pages of the docs site
+type ImportTaskPage struct {
+	ID       string `json:"id" gorm:"type:varchar(36);primaryKey"`
+	TenantID uint   `json:"tenant_id"`
+	TaskID   string `json:"task_id" gorm:"type:varchar(36);index"`
+	URL      string `json:"url"`
+	// LastMod is the modification time of the page at its latest import,
+	// from sitemap.xml or the Last-Modified header, empty if the site does not tell
+	LastMod     string    `json:"last_mod" gorm:"type:varchar(64)"`
+	KnowledgeID string    `json:"knowledge_id" gorm:"type:varchar(36)"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (p *ImportTaskPage) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (p *ImportTaskPage) TableName() string {
+	return "import_task_pages"
+}
+
+// ImportTaskPayload is the payload of a TypeImportTaskRun task, the task ID is the import task ID
+type ImportTaskPayload struct {
+	TenantID uint   `json:"tenant_id"`
+	TaskID   string `json:"task_id"`
 }
 
 type ImportTaskResult struct {
-	URL         string `json:"url"`
+	URL string `json:"url"`
+	// Status is success for a new page, updated for a modified page, removed for a page that
+	// disappeared from the site, duplicate or failed
 	Status      string `json:"status"`
 	KnowledgeID string `json:"knowledge_id,omitempty"`
 	Error       string `json:"error,omitempty"`
@@ -0,0 +1,33 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// CachedAnswer answer stored in the semantic answer cache
+type CachedAnswer struct {
+	Query               string           `json:"query"`                // original query
+	Embedding           []float32        `json:"embedding"`            // query embedding
+	Answer              string           `json:"answer"`               // generated answer
+	KnowledgeReferences types.References `json:"knowledge_references"` // knowledge references of the answer
+	Citations           types.Citations  `json:"citations"`            // inline citations of the answer
+	CreatedAt           time.Time        `json:"created_at"`           // creation time
+}
+
+// AnswerCache semantic answer cache interface, answers are looked up by query embedding similarity
+type AnswerCache interface {
+	// Get returns the most similar cached answer in the scope, or nil if no answer is similar enough
+	Get(ctx context.Context, scope string, embedding []float32) (*CachedAnswer, error)
+
+	// Set stores an answer in the scope
+	Set(ctx context.Context, scope string, answer *CachedAnswer) error
+
+	// InvalidateKnowledge removes all cached answers referencing the given knowledge
+	InvalidateKnowledge(ctx context.Context, knowledgeIDs ...string) error
+
+	// Enabled returns whether the cache is enabled
+	Enabled() bool
+}
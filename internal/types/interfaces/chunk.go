@@ -16,6 +16,21 @@ type ChunkRepository interface {
 	ListChunksByID(ctx context.Context, tenantID uint, ids []string) ([]*types.Chunk, error)
 	// ListChunksByKnowledgeID lists chunks by knowledge id
 	ListChunksByKnowledgeID(ctx context.Context, tenantID uint, knowledgeID string) ([]*types.Chunk, error)
+	// ListChunkIDsByKnowledgeBaseID lists the IDs of the enabled chunks of the given types in a knowledge base
+	ListChunkIDsByKnowledgeBaseID(
+		ctx context.Context,
+		tenantID uint,
+		knowledgeBaseID string,
+		chunkTypes []types.ChunkType,
+	) ([]string, error)
+	// ListChunksByKnowledgeIDAndType lists chunks of the given types with all fields by knowledge id,
+	// chunks of every type are listed if chunkTypes is empty
+	ListChunksByKnowledgeIDAndType(
+		ctx context.Context,
+		tenantID uint,
+		knowledgeID string,
+		chunkTypes []types.ChunkType,
+	) ([]*types.Chunk, error)
 	// ListPagedChunksByKnowledgeID lists paged chunks by knowledge id
 	ListPagedChunksByKnowledgeID(
 		ctx context.Context,
@@ -29,6 +44,8 @@ type ChunkRepository interface {
 	UpdateChunk(ctx context.Context, chunk *types.Chunk) error
 	// DeleteChunk deletes a chunk
 	DeleteChunk(ctx context.Context, tenantID uint, id string) error
+	// DeleteChunksByIDList deletes chunks by ids
+	DeleteChunksByIDList(ctx context.Context, tenantID uint, ids []string) error
 	// DeleteChunksByKnowledgeID deletes chunks by knowledge id
 	DeleteChunksByKnowledgeID(ctx context.Context, tenantID uint, knowledgeID string) error
 	// DeleteByKnowledgeList deletes all chunks for a knowledge list
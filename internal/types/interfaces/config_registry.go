@@ -0,0 +1,35 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// ConfigRegistry keeps the hot reloadable conversation config (prompts and retrieval defaults) up to date.
+// It watches the config file, validates changes before applying them and records every applied version.
+// The newest recorded version is the active config of every replica.
+type ConfigRegistry interface {
+	// Start applies the config loaded at startup or the newest version, watches the config file
+	// and polls the versions applied by other replicas
+	Start(ctx context.Context) error
+	// Stop stops watching the config file and polling the versions
+	Stop() error
+	// ListVersions lists the applied versions without their content, newest first
+	ListVersions(ctx context.Context, pagination *types.Pagination) (*types.PageResult, error)
+	// GetVersion gets an applied version with its content
+	GetVersion(ctx context.Context, id uint) (*types.ConfigVersion, error)
+	// DiffVersions returns the fields that differ between two versions
+	DiffVersions(ctx context.Context, from, to uint) ([]*types.ConfigFieldDiff, error)
+	// Rollback applies the content of an earlier version as a new version
+	Rollback(ctx context.Context, id uint) (*types.ConfigVersion, error)
+}
+
+// ConfigVersionRepository stores the version history of the conversation config
+type ConfigVersionRepository interface {
+	Create(ctx context.Context, version *types.ConfigVersion) error
+	GetByID(ctx context.Context, id uint) (*types.ConfigVersion, error)
+	GetLatest(ctx context.Context) (*types.ConfigVersion, error)
+	GetLatestBySource(ctx context.Context, sources ...types.ConfigVersionSource) (*types.ConfigVersion, error)
+	List(ctx context.Context, pagination *types.Pagination) ([]*types.ConfigVersion, int64, error)
+}
@@ -2,16 +2,40 @@ package interfaces
 
 import (
 	"context"
+	"regexp"
 	"time"
 )
 
+// CrawlOptions restricts the pages a crawl returns
+type CrawlOptions struct {
+	MaxPages int
+	// Include patterns, only pages whose URL matches one of them are returned
+	Include []*regexp.Regexp
+	// Exclude patterns, pages whose URL matches one of them are neither visited nor returned
+	Exclude []*regexp.Regexp
+}
+
+// CrawledPage is a page discovered by a crawl
+type CrawledPage struct {
+	URL string
+	// LastMod is the lastmod of the page in sitemap.xml or its Last-Modified header, empty if unknown
+	LastMod string
+}
+
 type CrawlResult struct {
-	URLs      []string
-	Visited   int
-	Failed    []string
-	StartedAt time.Time
+	Pages   []CrawledPage
+	Visited int
+	// Failed are the pages or sitemap files that could not be fetched
+	Failed []string
+	// Truncated is set when the site has more pages than MaxPages
+	Truncated bool
+	// FromSitemap is set when the pages were discovered through sitemap.xml instead of following links
+	FromSitemap bool
+	StartedAt   time.Time
 }
 
 type CrawlerService interface {
-	CrawlWebsite(ctx context.Context, baseURL string, maxPages int) (*CrawlResult, error)
+	// CrawlWebsite discovers the pages of a site through its sitemap.xml, or by following links
+	// from baseURL if it has none. Pages disallowed by robots.txt are skipped.
+	CrawlWebsite(ctx context.Context, baseURL string, options *CrawlOptions) (*CrawlResult, error)
 }
@@ -2,18 +2,60 @@ package interfaces
 
 import (
 	"context"
+	"mime/multipart"
 
 	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/hibiken/asynq"
 )
 
 // EvaluationService defines operations for evaluation tasks
 type EvaluationService interface {
 	// Evaluation starts a new evaluation task
 	Evaluation(ctx context.Context, datasetID string, knowledgeBaseID string,
-		chatModelID string, rerankModelID string,
+		chatModelID string, rerankModelID string, useExistingKB bool, judgeModelID string,
 	) (*types.EvaluationDetail, error)
 	// EvaluationResult retrieves evaluation result by task ID
 	EvaluationResult(ctx context.Context, taskID string) (*types.EvaluationDetail, error)
+	// ListEvaluations lists the evaluation tasks of the tenant, newest first
+	ListEvaluations(ctx context.Context, pagination *types.Pagination) (*types.PageResult, error)
+	// CompareEvaluations compares a target evaluation task with a base task
+	CompareEvaluations(ctx context.Context, baseID string, targetID string) (*types.EvaluationComparison, error)
+	// RecoverOrphanedEvaluations fails the evaluations left unfinished by a restart
+	RecoverOrphanedEvaluations(ctx context.Context) error
+	// StartSweep validates a retrieval-only parameter sweep and starts it in the background
+	StartSweep(ctx context.Context, sweep *types.EvaluationSweep) (*types.EvaluationSweep, error)
+	// GetSweep gets an evaluation sweep with its trials and recommended settings
+	GetSweep(ctx context.Context, sweepID string) (*types.EvaluationSweep, error)
+	// ListSweeps lists the evaluation sweeps of the tenant, newest first
+	ListSweeps(ctx context.Context, pagination *types.Pagination) (*types.PageResult, error)
+}
+
+// EvaluationRepository defines the storage of evaluation tasks and their question results
+type EvaluationRepository interface {
+	// CreateTask stores a new evaluation task
+	CreateTask(ctx context.Context, task *types.EvaluationTask) error
+	// UpdateTask updates an evaluation task
+	UpdateTask(ctx context.Context, task *types.EvaluationTask) error
+	// GetTask gets an evaluation task of a tenant
+	GetTask(ctx context.Context, tenantID uint, id string) (*types.EvaluationTask, error)
+	// ListTasks lists the evaluation tasks of a tenant, newest first
+	ListTasks(ctx context.Context, tenantID uint, pagination *types.Pagination) ([]*types.EvaluationTask, int64, error)
+	// FailUnfinishedTasks marks all pending and running evaluation tasks as failed
+	FailUnfinishedTasks(ctx context.Context, errMsg string) (int64, error)
+	// CreateQuestion stores the result of a question
+	CreateQuestion(ctx context.Context, question *types.EvaluationQuestion) error
+	// ListQuestions lists the question results of an evaluation task
+	ListQuestions(ctx context.Context, tenantID uint, taskID string) ([]*types.EvaluationQuestion, error)
+	// CreateSweep stores a new evaluation sweep
+	CreateSweep(ctx context.Context, sweep *types.EvaluationSweep) error
+	// UpdateSweep updates an evaluation sweep
+	UpdateSweep(ctx context.Context, sweep *types.EvaluationSweep) error
+	// FailUnfinishedSweeps marks all pending and running evaluation sweeps as failed
+	FailUnfinishedSweeps(ctx context.Context, errMsg string) (int64, error)
+	// GetSweep gets an evaluation sweep of a tenant
+	GetSweep(ctx context.Context, tenantID uint, id string) (*types.EvaluationSweep, error)
+	// ListSweeps lists the evaluation sweeps of a tenant, newest first
+	ListSweeps(ctx context.Context, tenantID uint, pagination *types.Pagination) ([]*types.EvaluationSweep, int64, error)
 }
 
 // Metrics defines interface for computing evaluation metrics
@@ -32,4 +74,54 @@ type EvalHook interface {
 type DatasetService interface {
 	// GetDatasetByID retrieves QA pairs from dataset by ID
 	GetDatasetByID(ctx context.Context, datasetID string) ([]*types.QAPair, error)
+	// CreateDataset creates a dataset from an uploaded parquet, JSONL or CSV file
+	CreateDataset(ctx context.Context, name string, description string, file *multipart.FileHeader) (*types.Dataset, error)
+	// GetDataset gets a dataset without its records
+	GetDataset(ctx context.Context, datasetID string) (*types.Dataset, error)
+	// ListDatasets lists the datasets of the tenant, newest first
+	ListDatasets(ctx context.Context, pagination *types.Pagination) (*types.PageResult, error)
+	// DeleteDataset deletes a dataset
+	DeleteDataset(ctx context.Context, datasetID string) error
+	// GenerateDataset creates a task generating a dataset from a knowledge base and enqueues it
+	GenerateDataset(ctx context.Context, task *types.DatasetGenerationTask) error
+	// GetGenerationTask gets a dataset generation task with its progress
+	GetGenerationTask(ctx context.Context, taskID string) (*types.DatasetGenerationTask, error)
+	// ListGenerationTasks lists the dataset generation tasks of the tenant, newest first
+	ListGenerationTasks(ctx context.Context, pagination *types.Pagination) (*types.PageResult, error)
+	// CancelGenerationTask cancels a pending or processing dataset generation task
+	CancelGenerationTask(ctx context.Context, taskID string) error
+	// RunGenerationTask handles a TypeDatasetGenerate task
+	RunGenerationTask(ctx context.Context, t *asynq.Task) error
+}
+
+// DatasetRepository defines the storage of datasets and their records
+type DatasetRepository interface {
+	// CreateDataset stores a dataset with its records
+	CreateDataset(ctx context.Context, dataset *types.Dataset, records []*types.DatasetRecord) error
+	// GetDataset gets a dataset of a tenant
+	GetDataset(ctx context.Context, tenantID uint, id string) (*types.Dataset, error)
+	// ListDatasets lists the datasets of a tenant, newest first
+	ListDatasets(ctx context.Context, tenantID uint, pagination *types.Pagination) ([]*types.Dataset, int64, error)
+	// ListRecords lists the records of a dataset
+	ListRecords(ctx context.Context, tenantID uint, datasetID string) ([]*types.DatasetRecord, error)
+	// DeleteDataset deletes a dataset with its records
+	DeleteDataset(ctx context.Context, tenantID uint, id string) error
+	// CreateGenerationTask stores a new dataset generation task
+	CreateGenerationTask(ctx context.Context, task *types.DatasetGenerationTask) error
+	// GetGenerationTask gets a dataset generation task of a tenant
+	GetGenerationTask(ctx context.Context, tenantID uint, id string) (*types.DatasetGenerationTask, error)
+	// ListGenerationTasks lists the dataset generation tasks of a tenant, newest first
+	ListGenerationTasks(ctx context.Context,
+		tenantID uint, pagination *types.Pagination,
+	) ([]*types.DatasetGenerationTask, int64, error)
+	// UpdateGenerationStatus moves a pending or processing generation task to the given status,
+	// it reports false if the task already finished
+	UpdateGenerationStatus(ctx context.Context,
+		id string, status types.DatasetGenerationStatus, errorMsg string,
+	) (bool, error)
+	// CompleteGenerationTask marks a processing generation task as completed with the generated dataset,
+	// it reports false if the task already finished
+	CompleteGenerationTask(ctx context.Context, id string, datasetID string) (bool, error)
+	// UpdateGenerationProgress updates the counts of a generation task
+	UpdateGenerationProgress(ctx context.Context, id string, processedChunks, acceptedCount, rejectedCount int) error
 }
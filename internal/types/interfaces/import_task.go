@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/hibiken/asynq"
 )
 
 type ImportTaskService interface {
@@ -17,4 +18,8 @@ type ImportTaskService interface {
 	StartProcessing(ctx context.Context, taskID string) error
 	AddTaskResult(ctx context.Context, taskID string, result *types.ImportTaskResult) error
 	ProcessTask(ctx context.Context, task *types.ImportTask)
+	// RunTask handles a TypeImportTaskRun task
+	RunTask(ctx context.Context, t *asynq.Task) error
+	// ScheduleTasks handles a TypeImportTaskSchedule task, it enqueues the recurring import tasks that are due
+	ScheduleTasks(ctx context.Context, t *asynq.Task) error
 }
@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/Tencent/WeKnora/internal/types"
 )
@@ -14,4 +15,15 @@ type ImportTaskRepository interface {
 	UpdateStatus(ctx context.Context, taskID string, status types.ImportTaskStatus, errorMsg string) error
 	UpdateProgress(ctx context.Context, taskID string, processedURLs, successCount, failedCount, duplicateCount int, currentURL string) error
 	AddResult(ctx context.Context, taskID string, result *types.ImportTaskResult) error
+	// StartRun marks the task as processing and resets the progress and results of the previous run
+	StartRun(ctx context.Context, taskID string) error
+	UpdateRunCounts(ctx context.Context, taskID string, totalURLs, unchangedCount, removedCount int) error
+	// ListDue lists the recurring tasks of all tenants whose next run is due
+	ListDue(ctx context.Context, now time.Time) ([]*types.ImportTask, error)
+	// UpdateNextRun moves the next run of a task from nextRunAt to next,
+	// it returns false if another instance moved it first
+	UpdateNextRun(ctx context.Context, taskID string, nextRunAt *time.Time, next *time.Time) (bool, error)
+	ListPages(ctx context.Context, taskID string) ([]*types.ImportTaskPage, error)
+	SavePage(ctx context.Context, page *types.ImportTaskPage) error
+	DeletePage(ctx context.Context, pageID string) error
 }
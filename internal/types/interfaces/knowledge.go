@@ -6,6 +6,7 @@ import (
 	"mime/multipart"
 
 	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/hibiken/asynq"
 )
 
 // KnowledgeService defines the interface for knowledge services.
@@ -17,11 +18,23 @@ type KnowledgeService interface {
 		file *multipart.FileHeader,
 		metadata map[string]string,
 		enableMultimodel *bool,
+		accessLabels types.AccessLabels,
 	) (*types.Knowledge, error)
 	// CreateKnowledgeFromURL creates knowledge from a URL.
-	CreateKnowledgeFromURL(ctx context.Context, kbID string, url string, enableMultimodel *bool) (*types.Knowledge, error)
+	CreateKnowledgeFromURL(
+		ctx context.Context,
+		kbID string,
+		url string,
+		enableMultimodel *bool,
+		accessLabels types.AccessLabels,
+	) (*types.Knowledge, error)
 	// CreateKnowledgeFromPassage creates knowledge from text passages.
-	CreateKnowledgeFromPassage(ctx context.Context, kbID string, passage []string) (*types.Knowledge, error)
+	CreateKnowledgeFromPassage(
+		ctx context.Context,
+		kbID string,
+		passage []string,
+		accessLabels types.AccessLabels,
+	) (*types.Knowledge, error)
 	// GetKnowledgeByID retrieves knowledge by ID.
 	GetKnowledgeByID(ctx context.Context, id string) (*types.Knowledge, error)
 	// GetKnowledgeBatch retrieves a batch of knowledge by IDs.
@@ -36,6 +49,8 @@ type KnowledgeService interface {
 	) (*types.PageResult, error)
 	// DeleteKnowledge deletes knowledge by ID.
 	DeleteKnowledge(ctx context.Context, id string) error
+	// DeleteKnowledgeList deletes knowledge of the current tenant by IDs.
+	DeleteKnowledgeList(ctx context.Context, ids []string) error
 	// GetKnowledgeFile retrieves the file associated with the knowledge.
 	GetKnowledgeFile(ctx context.Context, id string) (io.ReadCloser, string, error)
 	// UpdateKnowledge updates knowledge information.
@@ -44,6 +59,35 @@ type KnowledgeService interface {
 	CloneKnowledgeBase(ctx context.Context, srcID, dstID string) error
 	// UpdateImageInfo updates image information for a knowledge chunk.
 	UpdateImageInfo(ctx context.Context, knowledgeID string, chunkID string, imageInfo string) error
+	// ProcessDocument handles the asynq task that parses, chunks, embeds and indexes a knowledge entry.
+	ProcessDocument(ctx context.Context, t *asynq.Task) error
+	// RecoverOrphanedKnowledge requeues or fails knowledge left pending or processing without a task.
+	RecoverOrphanedKnowledge(ctx context.Context) error
+	// ListDeadLetterTasks lists the document tasks of the current tenant that failed permanently.
+	ListDeadLetterTasks(ctx context.Context, page *types.Pagination) (*types.PageResult, error)
+	// RetryDeadLetterTask runs a document task that failed permanently again.
+	RetryDeadLetterTask(ctx context.Context, taskID string) error
+	// RefreshKnowledge enqueues a re-fetch of URL knowledge, it is reindexed only if its content changed.
+	RefreshKnowledge(ctx context.Context, id string) (*types.Knowledge, error)
+	// ReplaceKnowledgeFile enqueues a re-parse of file knowledge with a new file, only changed chunks are embedded.
+	ReplaceKnowledgeFile(
+		ctx context.Context,
+		id string,
+		file *multipart.FileHeader,
+		enableMultimodel *bool,
+	) (*types.Knowledge, error)
+	// SyncKnowledge handles the periodic asynq task that refreshes URL knowledge due for re-sync.
+	SyncKnowledge(ctx context.Context, t *asynq.Task) error
+	// ListKnowledgeVersions lists the earlier content versions of URL and file knowledge, newest first.
+	ListKnowledgeVersions(ctx context.Context, id string) ([]*types.KnowledgeVersion, error)
+	// RollbackKnowledgeVersion enqueues the restore of an earlier content version of URL and file knowledge.
+	RollbackKnowledgeVersion(ctx context.Context, id string, version int) (*types.Knowledge, error)
+	// ImportFAQ imports question-answer pairs as FAQ knowledge, replacing the entries with the same question.
+	ImportFAQ(ctx context.Context, kbID string, entries []*types.FAQEntry) (*types.FAQImportResult, error)
+	// ExportFAQ returns the question-answer pairs of the FAQ knowledge in a knowledge base.
+	ExportFAQ(ctx context.Context, kbID string) ([]*types.FAQEntry, error)
+	// ListDuplicateClusters groups the knowledge of a knowledge base whose contents nearly duplicate each other.
+	ListDuplicateClusters(ctx context.Context, kbID string, threshold float64) ([]*types.DuplicateCluster, error)
 }
 
 // KnowledgeRepository defines the interface for knowledge repositories.
@@ -71,4 +115,28 @@ type KnowledgeRepository interface {
 	// AminusB returns the difference set of A and B.
 	AminusB(ctx context.Context, Atenant uint, A string, Btenant uint, B string) ([]string, error)
 	UpdateKnowledgeColumn(ctx context.Context, id string, column string, value interface{}) error
+	// ListKnowledgeByParseStatus lists the knowledge of all tenants with one of the parse statuses
+	ListKnowledgeByParseStatus(ctx context.Context, statuses ...string) ([]*types.Knowledge, error)
+	// ListKnowledgeFingerprints lists the fingerprinted knowledge of a knowledge base, earliest created first
+	ListKnowledgeFingerprints(ctx context.Context, tenantID uint, kbID string) ([]*types.Knowledge, error)
+	// ListKnowledgeByDuplicateOf lists the knowledge found to nearly duplicate one of the knowledge entries
+	ListKnowledgeByDuplicateOf(ctx context.Context, tenantID uint, ids []string) ([]*types.Knowledge, error)
+}
+
+// KnowledgeVersionRepository defines the interface for the content versions of URL and file knowledge.
+type KnowledgeVersionRepository interface {
+	// CreateVersion stores an earlier content version
+	CreateVersion(ctx context.Context, version *types.KnowledgeVersion) error
+	// GetVersion gets a version with its chunks
+	GetVersion(ctx context.Context, tenantID uint, knowledgeID string, version int) (*types.KnowledgeVersion, error)
+	// ListVersions lists the versions of a knowledge entry without their chunks, newest first
+	ListVersions(ctx context.Context, tenantID uint, knowledgeID string) ([]*types.KnowledgeVersion, error)
+	// GetMaxVersion returns the highest version number stored for a knowledge entry, 0 if there is none
+	GetMaxVersion(ctx context.Context, tenantID uint, knowledgeID string) (int, error)
+	// DeleteVersion deletes a version
+	DeleteVersion(ctx context.Context, tenantID uint, knowledgeID string, version int) error
+	// DeleteVersions deletes versions of a knowledge entry by their numbers
+	DeleteVersions(ctx context.Context, tenantID uint, knowledgeID string, versions []int) error
+	// DeleteByKnowledgeList deletes the versions of knowledge entries
+	DeleteByKnowledgeList(ctx context.Context, tenantID uint, knowledgeIDs []string) error
 }
@@ -27,9 +27,9 @@ type SessionService interface {
 		sessionID, query string,
 	) ([]*types.SearchResult, <-chan types.StreamResponse, error)
 	// KnowledgeQAByEvent performs knowledge-based question answering by event
-	KnowledgeQAByEvent(ctx context.Context, chatManage *types.ChatManage, eventList []types.EventType) error
+	KnowledgeQAByEvent(ctx context.Context, chatManage *types.ChatManage, pipeline string) error
 	// SearchKnowledge performs knowledge-based search, without summarization
-	SearchKnowledge(ctx context.Context, knowledgeBaseID, query string) ([]*types.SearchResult, error)
+	SearchKnowledge(ctx context.Context, knowledgeBaseID, query string, audience []string) ([]*types.SearchResult, error)
 }
 
 // SessionRepository defines the session repository interface
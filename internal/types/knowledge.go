@@ -44,8 +44,24 @@ type Knowledge struct {
 	FilePath string `json:"file_path"`
 	// Storage size of the knowledge
 	StorageSize int64 `json:"storage_size"`
+	// Hash of the parsed content, a refresh of URL knowledge only reindexes it if the hash changed
+	ContentHash string `json:"content_hash"`
+	// Version of the indexed content, earlier versions of URL knowledge are kept as KnowledgeVersion
+	Version int `json:"version" gorm:"default:1"`
+	// Last time the source of URL knowledge was fetched
+	LastSyncedAt *time.Time `json:"last_synced_at"`
+	// Error of the last refresh of URL knowledge that failed before reindexing, the indexed content stays in use
+	SyncError string `json:"sync_error"`
 	// Metadata of the knowledge
 	Metadata JSON `json:"metadata" gorm:"type:json"`
+	// Access labels, labelled knowledge is only retrieved for an audience holding one of them
+	AccessLabels AccessLabels `json:"access_labels" gorm:"type:json"`
+	// MinHash signature of the parsed content, used to find near-duplicate knowledge
+	MinHash MinHashSignature `json:"-" gorm:"type:json"`
+	// ID of the earlier knowledge whose content this knowledge nearly duplicates
+	DuplicateOf string `json:"duplicate_of" gorm:"type:varchar(36);index"`
+	// Estimated similarity of the content to the knowledge it nearly duplicates
+	DuplicateSimilarity float64 `json:"duplicate_similarity"`
 	// Creation time of the knowledge
 	CreatedAt time.Time `json:"created_at"`
 	// Last updated time of the knowledge
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// TypeDocumentProcess parses, chunks, embeds and indexes a knowledge entry
+	TypeDocumentProcess = "document:process"
+	// TypeKnowledgeSync refreshes the URL knowledge whose knowledge base re-sync interval has elapsed
+	TypeKnowledgeSync = "knowledge:sync"
+)
+
+// ErrTenantIngestionBusy is returned by a document task when its tenant already runs the maximum number of tasks,
+// the task is rescheduled without counting as a failed attempt
+var ErrTenantIngestionBusy = errors.New("tenant ingestion concurrency limit reached")
+
+// DocumentProcessPayload is the payload of a TypeDocumentProcess task.
+// The task ID is the knowledge ID, so a knowledge entry is processed by at most one task.
+type DocumentProcessPayload struct {
+	TenantID    uint   `json:"tenant_id"`
+	KnowledgeID string `json:"knowledge_id"`
+	// Whether images in the document are parsed with the VLM
+	EnableMultimodel bool `json:"enable_multimodel"`
+	// Re-read knowledge that was processed before, it is only reindexed if its content changed
+	Refresh bool `json:"refresh,omitempty"`
+	// File uploaded in place of the file of file knowledge, a refresh reads it instead of the stored file
+	File *KnowledgeFile `json:"file,omitempty"`
+	// Version to roll back to, its chunks are restored in place of the indexed version
+	Rollback int `json:"rollback,omitempty"`
+	// Passages of passage knowledge, file and URL knowledge are read from the knowledge entry
+	Passages []string `json:"passages,omitempty"`
+	// Request that created the knowledge, used to correlate logs
+	RequestID string `json:"request_id"`
+}
+
+// KnowledgeFile is a stored file uploaded in place of the file of file knowledge
+type KnowledgeFile struct {
+	FileName string `json:"file_name"`
+	FileType string `json:"file_type"`
+	FileSize int64  `json:"file_size"`
+	FileHash string `json:"file_hash"`
+	FilePath string `json:"file_path"`
+}
+
+// DeadLetterTask is a document task that was archived because it failed permanently or ran out of retries
+type DeadLetterTask struct {
+	ID           string    `json:"id"`
+	KnowledgeID  string    `json:"knowledge_id"`
+	Queue        string    `json:"queue"`
+	Retried      int       `json:"retried"`
+	MaxRetry     int       `json:"max_retry"`
+	LastError    string    `json:"last_error"`
+	LastFailedAt time.Time `json:"last_failed_at"`
+}
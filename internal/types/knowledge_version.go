@@ -0,0 +1,42 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// KnowledgeVersion is an earlier content version of URL knowledge.
+// A refresh that finds changed content or a rollback keeps the replaced chunks here,
+// so the knowledge can be rolled back to them without fetching the URL again.
+type KnowledgeVersion struct {
+	// Unique identifier of the version
+	ID string `json:"id" gorm:"type:varchar(36);primaryKey"`
+	// Tenant ID
+	TenantID uint `json:"tenant_id"`
+	// ID of the knowledge
+	KnowledgeID string `json:"knowledge_id" gorm:"type:varchar(36);index"`
+	// Version number, the active version number is Knowledge.Version
+	Version int `json:"version"`
+	// Hash of the parsed content
+	ContentHash string `json:"content_hash"`
+	// Description of the knowledge at this version
+	Description string `json:"description"`
+	// Storage size of the index of this version
+	StorageSize int64 `json:"storage_size"`
+	// Number of chunks kept
+	ChunkCount int `json:"chunk_count"`
+	// Chunks of this version, omitted when versions are listed
+	Chunks JSON `json:"chunks,omitempty" gorm:"type:json"`
+	// Time the version was processed
+	ProcessedAt *time.Time `json:"processed_at"`
+	// Time the version was replaced
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BeforeCreate hook generates a UUID for new KnowledgeVersion entities before they are created.
+func (v *KnowledgeVersion) BeforeCreate(tx *gorm.DB) (err error) {
+	v.ID = uuid.New().String()
+	return nil
+}
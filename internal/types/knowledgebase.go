@@ -3,6 +3,7 @@ package types
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -40,6 +41,12 @@ type KnowledgeBase struct {
 	StorageConfig StorageConfig `yaml:"cos_config" json:"cos_config" gorm:"column:cos_config;type:json"`
 	// Extract config
 	ExtractConfig *ExtractConfig `yaml:"extract_config" json:"extract_config" gorm:"column:extract_config;type:json"`
+	// Chat pipeline used by sessions of this knowledge base, either a name or an inline definition
+	Pipeline *PipelineConfig `yaml:"pipeline" json:"pipeline" gorm:"type:json"`
+	// Scheduled re-sync of the URL knowledge in the knowledge base
+	URLSyncConfig *URLSyncConfig `yaml:"url_sync_config" json:"url_sync_config" gorm:"type:json"`
+	// Handling of knowledge whose content nearly duplicates knowledge in the knowledge base
+	DedupConfig *DedupConfig `yaml:"dedup_config" json:"dedup_config" gorm:"type:json"`
 	// Creation time of the knowledge base
 	CreatedAt time.Time `yaml:"created_at" json:"created_at"`
 	// Last updated time of the knowledge base
@@ -54,6 +61,12 @@ type KnowledgeBaseConfig struct {
 	ChunkingConfig ChunkingConfig `yaml:"chunking_config" json:"chunking_config"`
 	// Image processing configuration
 	ImageProcessingConfig ImageProcessingConfig `yaml:"image_processing_config" json:"image_processing_config"`
+	// Chat pipeline configuration
+	Pipeline *PipelineConfig `yaml:"pipeline" json:"pipeline"`
+	// URL knowledge re-sync configuration
+	URLSyncConfig *URLSyncConfig `yaml:"url_sync_config" json:"url_sync_config"`
+	// Near-duplicate handling configuration
+	DedupConfig *DedupConfig `yaml:"dedup_config" json:"dedup_config"`
 }
 
 // ChunkingConfig represents the document splitting configuration
@@ -66,6 +79,63 @@ type ChunkingConfig struct {
 	Separators []string `yaml:"separators" json:"separators"`
 	// Enable multimodal
 	EnableMultimodal bool `yaml:"enable_multimodal" json:"enable_multimodal"`
+	// Child chunk size, text chunks longer than this are split into child chunks which are indexed
+	// instead of the text chunk; a child chunk hit returns its whole parent chunk. 0 disables it
+	ChildChunkSize int `yaml:"child_chunk_size" json:"child_chunk_size"`
+	// Child chunk overlap
+	ChildChunkOverlap int `yaml:"child_chunk_overlap" json:"child_chunk_overlap"`
+	// Strategy that splits the parsed text into chunks, defaults to recursive
+	Strategy ChunkingStrategy `yaml:"strategy" json:"strategy,omitempty"`
+	// Percentile of the distances between neighbouring sentences above which the semantic strategy
+	// starts a new chunk, defaults to 95
+	BreakpointPercentile int `yaml:"breakpoint_percentile" json:"breakpoint_percentile,omitempty"`
+}
+
+// ChunkingStrategy represents how the parsed text of a document is split into chunks
+type ChunkingStrategy string
+
+const (
+	// ChunkingStrategyRecursive keeps the chunks of docreader, which splits recursively by the separators
+	ChunkingStrategyRecursive ChunkingStrategy = "recursive"
+	// ChunkingStrategyMarkdown splits at Markdown headings and keeps the heading path of each chunk
+	ChunkingStrategyMarkdown ChunkingStrategy = "markdown"
+	// ChunkingStrategySentenceWindow packs whole sentences into chunks, neighbouring chunks share sentences
+	ChunkingStrategySentenceWindow ChunkingStrategy = "sentence_window"
+	// ChunkingStrategySemantic starts a new chunk where the embeddings of neighbouring sentences diverge
+	ChunkingStrategySemantic ChunkingStrategy = "semantic"
+)
+
+// DefaultBreakpointPercentile is the default breakpoint percentile of the semantic strategy
+const DefaultBreakpointPercentile = 95
+
+// GetStrategy returns the chunking strategy, recursive if none is set
+func (c *ChunkingConfig) GetStrategy() ChunkingStrategy {
+	if c.Strategy == "" {
+		return ChunkingStrategyRecursive
+	}
+	return c.Strategy
+}
+
+// GetBreakpointPercentile returns the breakpoint percentile of the semantic strategy
+func (c *ChunkingConfig) GetBreakpointPercentile() int {
+	if c.BreakpointPercentile <= 0 {
+		return DefaultBreakpointPercentile
+	}
+	return c.BreakpointPercentile
+}
+
+// Validate checks the chunking strategy and its parameters
+func (c *ChunkingConfig) Validate() error {
+	switch c.GetStrategy() {
+	case ChunkingStrategyRecursive, ChunkingStrategyMarkdown,
+		ChunkingStrategySentenceWindow, ChunkingStrategySemantic:
+	default:
+		return fmt.Errorf("unknown chunking strategy: %s", c.Strategy)
+	}
+	if c.BreakpointPercentile < 0 || c.BreakpointPercentile >= 100 {
+		return fmt.Errorf("breakpoint_percentile must be between 0 and 99")
+	}
+	return nil
 }
 
 // COSConfig represents the COS configuration
@@ -170,6 +240,125 @@ func (c *VLMConfig) Scan(value interface{}) error {
 	return json.Unmarshal(b, c)
 }
 
+// URLSyncConfig represents the scheduled re-sync of URL knowledge
+type URLSyncConfig struct {
+	// Whether URL knowledge is refreshed on schedule
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Minutes between two refreshes of the same URL knowledge
+	IntervalMinutes int `yaml:"interval_minutes" json:"interval_minutes"`
+}
+
+// Due reports whether knowledge last synced at lastSynced should be refreshed at now
+func (c *URLSyncConfig) Due(lastSynced time.Time, now time.Time) bool {
+	if c == nil || !c.Enabled || c.IntervalMinutes <= 0 {
+		return false
+	}
+	return now.Sub(lastSynced) >= time.Duration(c.IntervalMinutes)*time.Minute
+}
+
+// Validate checks that an enabled re-sync has an interval
+func (c *URLSyncConfig) Validate() error {
+	if c != nil && c.Enabled && c.IntervalMinutes <= 0 {
+		return fmt.Errorf("url sync interval_minutes must be positive")
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface, used to convert URLSyncConfig to database value
+func (c URLSyncConfig) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to URLSyncConfig
+func (c *URLSyncConfig) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, c)
+}
+
+// DedupPolicy represents what happens to knowledge found to nearly duplicate earlier knowledge
+type DedupPolicy string
+
+const (
+	// DedupPolicyFlag indexes the knowledge and records the knowledge it duplicates
+	DedupPolicyFlag DedupPolicy = "flag"
+	// DedupPolicySkip keeps the chunks of the knowledge without indexing them and disables it
+	DedupPolicySkip DedupPolicy = "skip"
+	// DedupPolicyMerge only indexes the chunks that are not near-duplicates of chunks of the earlier knowledge
+	DedupPolicyMerge DedupPolicy = "merge"
+)
+
+const (
+	// DefaultDuplicateThreshold is the default similarity above which knowledge is a near-duplicate
+	DefaultDuplicateThreshold = 0.9
+	// MinDuplicateThreshold is the lowest similarity threshold, candidates below it are likely missed
+	MinDuplicateThreshold = 0.5
+)
+
+// DedupConfig represents the handling of near-duplicate knowledge at ingestion
+type DedupConfig struct {
+	// Policy applied to near-duplicate knowledge, empty only fingerprints the knowledge
+	Policy DedupPolicy `yaml:"policy" json:"policy"`
+	// Estimated similarity of the content from which knowledge is a near-duplicate, defaults to 0.9
+	Threshold float64 `yaml:"threshold" json:"threshold"`
+}
+
+// Enabled reports whether a policy is applied to near-duplicate knowledge
+func (c *DedupConfig) Enabled() bool {
+	return c != nil && c.Policy != ""
+}
+
+// GetThreshold returns the similarity threshold
+func (c *DedupConfig) GetThreshold() float64 {
+	if c == nil || c.Threshold == 0 {
+		return DefaultDuplicateThreshold
+	}
+	return c.Threshold
+}
+
+// Validate checks the policy and the threshold
+func (c *DedupConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	switch c.Policy {
+	case "", DedupPolicyFlag, DedupPolicySkip, DedupPolicyMerge:
+	default:
+		return fmt.Errorf("unsupported dedup policy: %s", c.Policy)
+	}
+	return ValidateDuplicateThreshold(c.Threshold)
+}
+
+// ValidateDuplicateThreshold checks a similarity threshold, 0 stands for the default
+func ValidateDuplicateThreshold(threshold float64) error {
+	if threshold != 0 && (threshold < MinDuplicateThreshold || threshold > 1) {
+		return fmt.Errorf("dedup threshold must be between %v and 1", MinDuplicateThreshold)
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface, used to convert DedupConfig to database value
+func (c DedupConfig) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to DedupConfig
+func (c *DedupConfig) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, c)
+}
+
 type ExtractConfig struct {
 	Text      string           `yaml:"text" json:"text"`
 	Tags      []string         `yaml:"tags" json:"tags"`
@@ -0,0 +1,27 @@
+package types
+
+import "testing"
+
+func TestDedupConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *DedupConfig
+		wantErr bool
+	}{
+		{name: "nil config", config: nil},
+		{name: "fingerprints only", config: &DedupConfig{}},
+		{name: "merge with default threshold", config: &DedupConfig{Policy: DedupPolicyMerge}},
+		{name: "skip with threshold", config: &DedupConfig{Policy: DedupPolicySkip, Threshold: 0.8}},
+		{name: "unknown policy", config: &DedupConfig{Policy: "drop"}, wantErr: true},
+		{name: "threshold too low", config: &DedupConfig{Policy: DedupPolicyFlag, Threshold: 0.2}, wantErr: true},
+		{name: "threshold above 1", config: &DedupConfig{Policy: DedupPolicyFlag, Threshold: 1.5}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -34,6 +34,10 @@ type Message struct {
 	Role string `json:"role"`
 	// References to knowledge chunks used in the response
 	KnowledgeReferences References `json:"knowledge_references" gorm:"type:json,column:knowledge_references"`
+	// Citations of the knowledge references marked in the content
+	Citations Citations `json:"citations" gorm:"type:json,column:citations"`
+	// Groundedness of the response in the knowledge references, nil if it was not checked
+	Grounding *AnswerGrounding `json:"grounding" gorm:"type:json,column:grounding"`
 	// Whether message generation is complete
 	IsCompleted bool `json:"is_completed"`
 	// Message creation timestamp
@@ -45,7 +49,7 @@ type Message struct {
 }
 
 // BeforeCreate is a GORM hook that runs before creating a new message record
-// Automatically generates a UUID for new messages and initializes knowledge references
+// Automatically generates a UUID for new messages and initializes knowledge references and citations
 // Parameters:
 //   - tx: GORM database transaction
 //
@@ -56,5 +60,8 @@ func (m *Message) BeforeCreate(tx *gorm.DB) (err error) {
 	if m.KnowledgeReferences == nil {
 		m.KnowledgeReferences = make(References, 0)
 	}
+	if m.Citations == nil {
+		m.Citations = make(Citations, 0)
+	}
 	return nil
 }
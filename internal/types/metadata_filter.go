@@ -0,0 +1,240 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode"
+)
+
+// MetadataFilterOp represents the operator of a metadata filter
+type MetadataFilterOp string
+
+const (
+	// MetadataFilterOpEq matches a field equal to the value, or a list field containing it
+	MetadataFilterOpEq MetadataFilterOp = "eq"
+	// MetadataFilterOpIn matches a field equal to one of the values
+	MetadataFilterOpIn MetadataFilterOp = "in"
+	// MetadataFilterOpRange matches a field within the bounds
+	MetadataFilterOpRange MetadataFilterOp = "range"
+	// MetadataFilterOpExists matches a field that is set
+	MetadataFilterOpExists MetadataFilterOp = "exists"
+)
+
+// MetadataFilter restricts retrieval to chunks whose index metadata matches the condition
+type MetadataFilter struct {
+	// Metadata field, e.g. category
+	Field string `json:"field"`
+	// Operator
+	Op MetadataFilterOp `json:"op"`
+	// Value of eq
+	Value any `json:"value,omitempty"`
+	// Values of in
+	Values []any `json:"values,omitempty"`
+	// Bounds of range
+	Range *MetadataRange `json:"range,omitempty"`
+}
+
+// MetadataRange holds the bounds of a range filter, either all numbers or all strings.
+// Strings are compared byte-wise, so dates have to be written like 2006-01-02.
+type MetadataRange struct {
+	Gt  any `json:"gt,omitempty"`
+	Gte any `json:"gte,omitempty"`
+	Lt  any `json:"lt,omitempty"`
+	Lte any `json:"lte,omitempty"`
+}
+
+// MetadataFilters is a list of filters that all have to match
+type MetadataFilters []*MetadataFilter
+
+// Validate checks every filter
+func (f MetadataFilters) Validate() error {
+	for _, filter := range f {
+		if filter == nil {
+			return errors.New("metadata filter cannot be empty")
+		}
+		if err := filter.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface, used to convert MetadataFilters to database value
+func (f MetadataFilters) Value() (driver.Value, error) {
+	if f == nil {
+		return nil, nil
+	}
+	return json.Marshal(f)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to MetadataFilters
+func (f *MetadataFilters) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, f)
+}
+
+// Validate checks the field, the operator and its values
+func (f *MetadataFilter) Validate() error {
+	if !IsMetadataField(f.Field) {
+		return fmt.Errorf("invalid metadata filter field: %q", f.Field)
+	}
+	switch f.Op {
+	case MetadataFilterOpEq:
+		if !isMetadataScalar(f.Value) {
+			return fmt.Errorf("metadata filter on %s: eq needs a string, number or boolean value", f.Field)
+		}
+	case MetadataFilterOpIn:
+		if len(f.Values) == 0 {
+			return fmt.Errorf("metadata filter on %s: in needs at least one value", f.Field)
+		}
+		for _, value := range f.Values {
+			if !isMetadataScalar(value) {
+				return fmt.Errorf("metadata filter on %s: in values must be strings, numbers or booleans", f.Field)
+			}
+		}
+	case MetadataFilterOpRange:
+		if err := f.Range.validate(); err != nil {
+			return fmt.Errorf("metadata filter on %s: %w", f.Field, err)
+		}
+	case MetadataFilterOpExists:
+	default:
+		return fmt.Errorf("unsupported metadata filter operator: %s", f.Op)
+	}
+	return nil
+}
+
+// Bounds returns the set bounds keyed by gt, gte, lt and lte
+func (r *MetadataRange) Bounds() map[string]any {
+	bounds := make(map[string]any, 4)
+	for name, value := range map[string]any{"gt": r.Gt, "gte": r.Gte, "lt": r.Lt, "lte": r.Lte} {
+		if value != nil {
+			bounds[name] = value
+		}
+	}
+	return bounds
+}
+
+// Numeric returns true if the bounds are numbers
+func (r *MetadataRange) Numeric() bool {
+	for _, value := range r.Bounds() {
+		_, isString := value.(string)
+		return !isString
+	}
+	return false
+}
+
+// validate checks that there is at least one bound and that the bounds share one type
+func (r *MetadataRange) validate() error {
+	if r == nil || len(r.Bounds()) == 0 {
+		return errors.New("range needs at least one bound")
+	}
+	numeric := r.Numeric()
+	for _, value := range r.Bounds() {
+		_, isString := value.(string)
+		if isString == numeric || (!isString && !isMetadataNumber(value)) {
+			return errors.New("range bounds must be all numbers or all strings")
+		}
+	}
+	return nil
+}
+
+// IsMetadataField returns true if the name can be used as a metadata field:
+// letters, digits, underscores and hyphens only, so it is safe in every engine's field path
+func IsMetadataField(name string) bool {
+	return isSafeName(name)
+}
+
+// isSafeName returns true for short names of letters, digits, underscores and hyphens
+func isSafeName(name string) bool {
+	if name == "" || len(name) > 64 {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// isMetadataScalar returns true for the value types a filter can compare
+func isMetadataScalar(value any) bool {
+	switch value.(type) {
+	case string, bool:
+		return true
+	}
+	return isMetadataNumber(value)
+}
+
+// isMetadataNumber returns true for numbers, as decoded from JSON or set in code
+func isMetadataNumber(value any) bool {
+	switch value.(type) {
+	case float64, float32, int, int32, int64, uint, uint32, uint64, json.Number:
+		return true
+	}
+	return false
+}
+
+// IndexMetadata is the flat metadata of an index entry that filters run against.
+// Values are strings, numbers, booleans or lists of them.
+type IndexMetadata map[string]any
+
+// NewIndexMetadata keeps the fields of the source maps that can be filtered on, later maps win.
+// Nested objects are dropped, lists keep their scalar items.
+func NewIndexMetadata(sources ...map[string]any) IndexMetadata {
+	metadata := make(IndexMetadata)
+	for _, source := range sources {
+		for field, value := range source {
+			if !IsMetadataField(field) {
+				continue
+			}
+			if list, ok := value.([]any); ok {
+				items := make([]any, 0, len(list))
+				for _, item := range list {
+					if isMetadataScalar(item) {
+						items = append(items, item)
+					}
+				}
+				if len(items) > 0 {
+					metadata[field] = items
+				}
+				continue
+			}
+			if isMetadataScalar(value) {
+				metadata[field] = value
+			}
+		}
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
+
+// Value implements the driver.Valuer interface, used to convert IndexMetadata to database value
+func (m IndexMetadata) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to IndexMetadata
+func (m *IndexMetadata) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, m)
+}
@@ -0,0 +1,97 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMetadataFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  MetadataFilter
+		wantErr bool
+	}{
+		{
+			name:   "eq",
+			filter: MetadataFilter{Field: "category", Op: MetadataFilterOpEq, Value: "对象存储"},
+		},
+		{
+			name:   "in",
+			filter: MetadataFilter{Field: "category", Op: MetadataFilterOpIn, Values: []any{"对象存储", "CDN"}},
+		},
+		{
+			name:   "numeric range",
+			filter: MetadataFilter{Field: "version", Op: MetadataFilterOpRange, Range: &MetadataRange{Gte: 2, Lt: 3.5}},
+		},
+		{
+			name: "string range",
+			filter: MetadataFilter{Field: "import_date", Op: MetadataFilterOpRange,
+				Range: &MetadataRange{Gte: "2024-01-01"}},
+		},
+		{
+			name:   "exists",
+			filter: MetadataFilter{Field: "source", Op: MetadataFilterOpExists},
+		},
+		{
+			name:    "field with a path",
+			filter:  MetadataFilter{Field: "a.b", Op: MetadataFilterOpExists},
+			wantErr: true,
+		},
+		{
+			name:    "unknown operator",
+			filter:  MetadataFilter{Field: "category", Op: "like", Value: "CDN"},
+			wantErr: true,
+		},
+		{
+			name:    "eq without value",
+			filter:  MetadataFilter{Field: "category", Op: MetadataFilterOpEq},
+			wantErr: true,
+		},
+		{
+			name:    "in with a list value",
+			filter:  MetadataFilter{Field: "category", Op: MetadataFilterOpIn, Values: []any{[]any{"CDN"}}},
+			wantErr: true,
+		},
+		{
+			name:    "range without bounds",
+			filter:  MetadataFilter{Field: "version", Op: MetadataFilterOpRange, Range: &MetadataRange{}},
+			wantErr: true,
+		},
+		{
+			name:    "range with mixed bounds",
+			filter:  MetadataFilter{Field: "version", Op: MetadataFilterOpRange, Range: &MetadataRange{Gte: 1, Lt: "2"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChunkMetadataIndexMetadata(t *testing.T) {
+	metadata := &ChunkMetadata{
+		SectionPath: []string{"安装", "配置"},
+		Attributes: NewIndexMetadata(map[string]any{
+			"category":     "CDN",
+			"tags":         []any{"a", map[string]any{"b": 1}, 2.0},
+			"nested":       map[string]any{"a": 1},
+			"invalid key":  "x",
+			"section_path": "overridden",
+		}),
+	}
+	want := IndexMetadata{
+		"category":     "CDN",
+		"tags":         []any{"a", 2.0},
+		"section_path": []any{"安装", "配置"},
+	}
+	if got := metadata.IndexMetadata(); !reflect.DeepEqual(got, want) {
+		t.Errorf("IndexMetadata() = %v, want %v", got, want)
+	}
+	if got := (*ChunkMetadata)(nil).IndexMetadata(); got != nil {
+		t.Errorf("IndexMetadata() of nil metadata = %v, want nil", got)
+	}
+}
@@ -45,6 +45,10 @@ type ModelParameters struct {
 	BaseURL             string              `yaml:"base_url" json:"base_url"`
 	APIKey              string              `yaml:"api_key" json:"api_key"`
 	EmbeddingParameters EmbeddingParameters `yaml:"embedding_parameters" json:"embedding_parameters"`
+	// Context window of chat models in tokens, 0 uses the configured default
+	ContextLength int `yaml:"context_length" json:"context_length"`
+	// Tokenizer used to count tokens for the model: estimate, char or gpt
+	Tokenizer string `yaml:"tokenizer" json:"tokenizer"`
 }
 
 // Model represents the AI model
@@ -0,0 +1,176 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// Default pipeline names
+const (
+	PipelineChat       = "chat"
+	PipelineChatStream = "chat_stream"
+	PipelineRAG        = "rag"
+	PipelineRAGStream  = "rag_stream"
+	PipelineSearch     = "search"
+	PipelineRetrieval  = "retrieval"
+)
+
+// PipelineStep is a single stage of a chat pipeline
+type PipelineStep struct {
+	// Event triggered by this step
+	Event EventType `yaml:"event" json:"event"`
+	// Options passed to the plugins handling the event, e.g. top_k for filter_top_k
+	Options map[string]interface{} `yaml:"options" json:"options,omitempty"`
+}
+
+// PipelineConfig describes an ordered chat pipeline.
+// A config with only a name references a pipeline registered in the EventManager.
+type PipelineConfig struct {
+	// Pipeline name
+	Name string `yaml:"name" json:"name"`
+	// Ordered steps of the pipeline
+	Steps []PipelineStep `yaml:"steps" json:"steps,omitempty"`
+}
+
+// NewPipelineConfig creates a pipeline config from an ordered event list
+func NewPipelineConfig(name string, events []EventType) *PipelineConfig {
+	steps := make([]PipelineStep, 0, len(events))
+	for _, event := range events {
+		steps = append(steps, PipelineStep{Event: event})
+	}
+	return &PipelineConfig{Name: name, Steps: steps}
+}
+
+// IsReference returns true if the config only references a pipeline by name
+func (p *PipelineConfig) IsReference() bool {
+	return p != nil && len(p.Steps) == 0
+}
+
+// Events returns the ordered event list of the pipeline
+func (p *PipelineConfig) Events() []EventType {
+	events := make([]EventType, 0, len(p.Steps))
+	for _, step := range p.Steps {
+		events = append(events, step.Event)
+	}
+	return events
+}
+
+// StepOptions returns the options of every step keyed by event type
+func (p *PipelineConfig) StepOptions() map[EventType]map[string]interface{} {
+	options := make(map[EventType]map[string]interface{})
+	for _, step := range p.Steps {
+		if len(step.Options) > 0 {
+			options[step.Event] = step.Options
+		}
+	}
+	return options
+}
+
+// pipelineEvents lists all known event types
+var pipelineEvents = []EventType{
+	PREPROCESS_QUERY,
+	REWRITE_QUERY,
+	CHUNK_SEARCH,
+	ENTITY_SEARCH,
+	CHUNK_RERANK,
+	CHUNK_MERGE,
+	INTO_CHAT_MESSAGE,
+	CHAT_COMPLETION,
+	CHAT_COMPLETION_STREAM,
+	STREAM_FILTER,
+	FILTER_TOP_K,
+	ANSWER_GROUNDING,
+}
+
+// pipelineRequires lists the events that must appear earlier in the pipeline for an event to work
+var pipelineRequires = map[EventType][]EventType{
+	ENTITY_SEARCH:     {CHUNK_SEARCH},
+	CHUNK_RERANK:      {CHUNK_SEARCH},
+	CHUNK_MERGE:       {CHUNK_SEARCH},
+	FILTER_TOP_K:      {CHUNK_SEARCH},
+	INTO_CHAT_MESSAGE: {CHUNK_SEARCH},
+	STREAM_FILTER:     {CHAT_COMPLETION_STREAM},
+	ANSWER_GROUNDING:  {INTO_CHAT_MESSAGE},
+}
+
+// pipelinePrecedes lists the events that must come after an event when both are present
+var pipelinePrecedes = map[EventType][]EventType{
+	REWRITE_QUERY:          {CHUNK_SEARCH},
+	PREPROCESS_QUERY:       {CHUNK_SEARCH},
+	INTO_CHAT_MESSAGE:      {CHAT_COMPLETION, CHAT_COMPLETION_STREAM},
+	CHAT_COMPLETION:        {ANSWER_GROUNDING},
+	CHAT_COMPLETION_STREAM: {ANSWER_GROUNDING},
+	STREAM_FILTER:          {ANSWER_GROUNDING},
+}
+
+// Validate checks that the pipeline only contains known events in a consistent order
+func (p *PipelineConfig) Validate() error {
+	if p == nil {
+		return fmt.Errorf("pipeline is empty")
+	}
+	if p.Name == "" {
+		return fmt.Errorf("pipeline name is empty")
+	}
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("pipeline %s has no steps", p.Name)
+	}
+
+	position := make(map[EventType]int, len(p.Steps))
+	for i, step := range p.Steps {
+		if !slices.Contains(pipelineEvents, step.Event) {
+			return fmt.Errorf("pipeline %s: unknown event %q", p.Name, step.Event)
+		}
+		if _, ok := position[step.Event]; ok {
+			return fmt.Errorf("pipeline %s: duplicate event %q", p.Name, step.Event)
+		}
+		position[step.Event] = i
+	}
+
+	if _, ok := position[CHAT_COMPLETION]; ok {
+		if _, ok := position[CHAT_COMPLETION_STREAM]; ok {
+			return fmt.Errorf("pipeline %s: %q and %q cannot be used together",
+				p.Name, CHAT_COMPLETION, CHAT_COMPLETION_STREAM)
+		}
+	}
+	if _, ok := position[ANSWER_GROUNDING]; ok {
+		_, chat := position[CHAT_COMPLETION]
+		_, stream := position[CHAT_COMPLETION_STREAM]
+		if !chat && !stream {
+			return fmt.Errorf("pipeline %s: %q must come after %q or %q",
+				p.Name, ANSWER_GROUNDING, CHAT_COMPLETION, CHAT_COMPLETION_STREAM)
+		}
+	}
+	for i, step := range p.Steps {
+		event := step.Event
+		for _, required := range pipelineRequires[event] {
+			if j, ok := position[required]; !ok || j > i {
+				return fmt.Errorf("pipeline %s: %q must come after %q", p.Name, event, required)
+			}
+		}
+		for _, later := range pipelinePrecedes[event] {
+			if j, ok := position[later]; ok && j < i {
+				return fmt.Errorf("pipeline %s: %q must come before %q", p.Name, event, later)
+			}
+		}
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface, used to convert PipelineConfig to database value
+func (p PipelineConfig) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to PipelineConfig
+func (p *PipelineConfig) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, p)
+}
@@ -37,6 +37,10 @@ type RetrieveParams struct {
 	TopK int
 	// Similarity threshold
 	Threshold float64
+	// Metadata filters, all of them have to match
+	Filters MetadataFilters
+	// Access labels of the audience, only public content and content labelled with one of them is retrieved
+	Audience []string
 	// Additional parameters, different retrievers may require different parameters
 	AdditionalParams map[string]interface{}
 	// Retriever type
@@ -3,6 +3,7 @@ package types
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 )
 
 // SearchResult represents the search result
@@ -13,6 +14,9 @@ type SearchResult struct {
 	Content string `gorm:"column:content" json:"content"`
 	// Knowledge ID
 	KnowledgeID string `gorm:"column:knowledge_id" json:"knowledge_id"`
+	// Knowledge base ID
+	// The knowledge base the chunk was retrieved from, used to keep citations correct across knowledge bases
+	KnowledgeBaseID string `gorm:"column:knowledge_base_id" json:"knowledge_base_id"`
 	// Chunk index
 	ChunkIndex int `gorm:"column:chunk_index" json:"chunk_index"`
 	// Knowledge title
@@ -38,6 +42,10 @@ type SearchResult struct {
 	ParentChunkID string `json:"parent_chunk_id"`
 	// 图片信息 (JSON 格式)
 	ImageInfo string `json:"image_info"`
+	// Headings of the Markdown sections containing the chunk
+	SectionPath []string `json:"section_path,omitempty"`
+	// Question-answer pair of an FAQ hit
+	FAQ *FAQEntry `json:"faq,omitempty"`
 
 	// Knowledge file name
 	// Used for file type knowledge, contains the original file name
@@ -50,10 +58,105 @@ type SearchResult struct {
 
 // SearchParams represents the search parameters
 type SearchParams struct {
-	QueryText        string  `json:"query_text"`
-	VectorThreshold  float64 `json:"vector_threshold"`
-	KeywordThreshold float64 `json:"keyword_threshold"`
-	MatchCount       int     `json:"match_count"`
+	QueryText        string        `json:"query_text"`
+	VectorThreshold  float64       `json:"vector_threshold"`
+	KeywordThreshold float64       `json:"keyword_threshold"`
+	MatchCount       int           `json:"match_count"`
+	Fusion           *FusionConfig `json:"fusion,omitempty"`
+	// Metadata filters, all of them have to match
+	Filters MetadataFilters `json:"filters,omitempty"`
+	// Access labels of the audience, labelled knowledge is only searched for an audience holding one of its labels
+	Audience []string `json:"audience,omitempty"`
+}
+
+// FusionMethod represents how vector and keyword results are combined
+type FusionMethod string
+
+const (
+	// FusionMethodNone keeps the raw retriever scores, the first hit of a chunk wins
+	FusionMethodNone FusionMethod = "none"
+	// FusionMethodRRF uses reciprocal rank fusion
+	FusionMethodRRF FusionMethod = "rrf"
+	// FusionMethodWeighted uses the weighted sum of min-max normalised scores
+	FusionMethodWeighted FusionMethod = "weighted"
+)
+
+// DefaultRRFK is the default rank constant of reciprocal rank fusion
+const DefaultRRFK = 60
+
+// FusionConfig represents the fusion stage configuration of hybrid search
+type FusionConfig struct {
+	// Fusion method, empty means none
+	Method FusionMethod `json:"method"`
+	// Rank constant k for RRF, defaults to 60
+	RRFK int `json:"rrf_k,omitempty"`
+	// Weight of vector results, defaults to 1
+	VectorWeight float64 `json:"vector_weight,omitempty"`
+	// Weight of keyword results, defaults to 1
+	KeywordWeight float64 `json:"keyword_weight,omitempty"`
+}
+
+// GetMethod returns the fusion method, nil config means none
+func (f *FusionConfig) GetMethod() FusionMethod {
+	if f == nil || f.Method == "" {
+		return FusionMethodNone
+	}
+	return f.Method
+}
+
+// GetRRFK returns the RRF rank constant
+func (f *FusionConfig) GetRRFK() int {
+	if f == nil || f.RRFK <= 0 {
+		return DefaultRRFK
+	}
+	return f.RRFK
+}
+
+// GetWeight returns the weight of the given retriever type
+func (f *FusionConfig) GetWeight(retrieverType RetrieverType) float64 {
+	weight := 0.0
+	if f != nil {
+		switch retrieverType {
+		case VectorRetrieverType:
+			weight = f.VectorWeight
+		case KeywordsRetrieverType:
+			weight = f.KeywordWeight
+		}
+	}
+	if weight <= 0 {
+		return 1.0
+	}
+	return weight
+}
+
+// Validate checks the fusion configuration
+func (f *FusionConfig) Validate() error {
+	switch f.GetMethod() {
+	case FusionMethodNone, FusionMethodRRF, FusionMethodWeighted:
+	default:
+		return fmt.Errorf("unsupported fusion method: %s", f.Method)
+	}
+	if f != nil && (f.RRFK < 0 || f.VectorWeight < 0 || f.KeywordWeight < 0) {
+		return fmt.Errorf("fusion rrf_k and weights cannot be negative")
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface, used to convert FusionConfig to database value
+func (f FusionConfig) Value() (driver.Value, error) {
+	return json.Marshal(f)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to FusionConfig
+func (f *FusionConfig) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, f)
 }
 
 // Value implements the driver.Valuer interface, used to convert SearchResult to database value
@@ -3,6 +3,7 @@ package types
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -56,19 +57,24 @@ type Session struct {
 	TenantID uint `json:"tenant_id" gorm:"index"`
 
 	// Strategy configuration
-	KnowledgeBaseID   string           `json:"knowledge_base_id"`                   // 关联的知识库ID
-	MaxRounds         int              `json:"max_rounds"`                          // 多轮保持轮数
-	EnableRewrite     bool             `json:"enable_rewrite"`                      // 多轮改写开关
-	FallbackStrategy  FallbackStrategy `json:"fallback_strategy"`                   // 兜底策略
-	FallbackResponse  string           `json:"fallback_response"`                   // 固定回复内容
-	EmbeddingTopK     int              `json:"embedding_top_k"`                     // 向量召回TopK
-	KeywordThreshold  float64          `json:"keyword_threshold"`                   // 关键词召回阈值
-	VectorThreshold   float64          `json:"vector_threshold"`                    // 向量召回阈值
-	RerankModelID     string           `json:"rerank_model_id"`                     // 排序模型ID
-	RerankTopK        int              `json:"rerank_top_k"`                        // 排序TopK
-	RerankThreshold   float64          `json:"rerank_threshold"`                    // 排序阈值
-	SummaryModelID    string           `json:"summary_model_id"`                    // 总结模型ID
-	SummaryParameters *SummaryConfig   `json:"summary_parameters" gorm:"type:json"` // 总结模型参数
+	KnowledgeBaseID   string                `json:"knowledge_base_id"`                   // 关联的知识库ID
+	KnowledgeBases    SessionKnowledgeBases `json:"knowledge_bases" gorm:"type:json"`    // 关联的多个知识库及权重
+	MaxRounds         int                   `json:"max_rounds"`                          // 多轮保持轮数
+	EnableRewrite     bool                  `json:"enable_rewrite"`                      // 多轮改写开关
+	FallbackStrategy  FallbackStrategy      `json:"fallback_strategy"`                   // 兜底策略
+	FallbackResponse  string                `json:"fallback_response"`                   // 固定回复内容
+	EmbeddingTopK     int                   `json:"embedding_top_k"`                     // 向量召回TopK
+	KeywordThreshold  float64               `json:"keyword_threshold"`                   // 关键词召回阈值
+	VectorThreshold   float64               `json:"vector_threshold"`                    // 向量召回阈值
+	RerankModelID     string                `json:"rerank_model_id"`                     // 排序模型ID
+	RerankTopK        int                   `json:"rerank_top_k"`                        // 排序TopK
+	RerankThreshold   float64               `json:"rerank_threshold"`                    // 排序阈值
+	SummaryModelID    string                `json:"summary_model_id"`                    // 总结模型ID
+	SummaryParameters *SummaryConfig        `json:"summary_parameters" gorm:"type:json"` // 总结模型参数
+	Pipeline          *PipelineConfig       `json:"pipeline" gorm:"type:json"`           // 对话流水线，为空时使用知识库或默认流水线
+	Fusion            *FusionConfig         `json:"fusion" gorm:"type:json"`             // 混合检索结果融合方式
+	Filters           MetadataFilters       `json:"filters" gorm:"type:json"`            // 默认的元数据过滤条件
+	Audience          AccessLabels          `json:"audience" gorm:"type:json"`           // 受众的访问标签，决定可检索的知识
 
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -83,6 +89,80 @@ func (s *Session) BeforeCreate(tx *gorm.DB) (err error) {
 	return nil
 }
 
+// GetKnowledgeBases returns the knowledge bases referenced by the session,
+// falling back to the legacy single KnowledgeBaseID when no list is configured
+func (s *Session) GetKnowledgeBases() SessionKnowledgeBases {
+	return resolveKnowledgeBases(s.KnowledgeBaseID, s.KnowledgeBases)
+}
+
+// SessionKnowledgeBase describes one knowledge base referenced by a session.
+// Thresholds and TopK are optional, when unset the session level values are used.
+type SessionKnowledgeBase struct {
+	// Knowledge base ID
+	KnowledgeBaseID string `json:"knowledge_base_id"`
+	// Weight applied to the normalised scores of this knowledge base, defaults to 1
+	Weight float64 `json:"weight,omitempty"`
+	// Vector threshold override
+	VectorThreshold *float64 `json:"vector_threshold,omitempty"`
+	// Keyword threshold override
+	KeywordThreshold *float64 `json:"keyword_threshold,omitempty"`
+	// Embedding TopK override
+	EmbeddingTopK int `json:"embedding_top_k,omitempty"`
+}
+
+// GetWeight returns the effective weight of the knowledge base
+func (k SessionKnowledgeBase) GetWeight() float64 {
+	if k.Weight <= 0 {
+		return 1.0
+	}
+	return k.Weight
+}
+
+// SessionKnowledgeBases is the list of knowledge bases referenced by a session
+type SessionKnowledgeBases []SessionKnowledgeBase
+
+// IDs returns the knowledge base IDs in order
+func (k SessionKnowledgeBases) IDs() []string {
+	ids := make([]string, 0, len(k))
+	for _, kb := range k {
+		ids = append(ids, kb.KnowledgeBaseID)
+	}
+	return ids
+}
+
+// Validate checks that every knowledge base is listed once with a valid weight and that the primary
+// knowledge base of the session, if set, is one of them
+func (k SessionKnowledgeBases) Validate(knowledgeBaseID string) error {
+	seen := make(map[string]bool, len(k))
+	for _, kb := range k {
+		if kb.KnowledgeBaseID == "" {
+			return fmt.Errorf("knowledge base ID cannot be empty")
+		}
+		if seen[kb.KnowledgeBaseID] {
+			return fmt.Errorf("duplicate knowledge base: %s", kb.KnowledgeBaseID)
+		}
+		seen[kb.KnowledgeBaseID] = true
+		if kb.Weight < 0 {
+			return fmt.Errorf("knowledge base weight cannot be negative: %s", kb.KnowledgeBaseID)
+		}
+	}
+	if knowledgeBaseID != "" && len(k) > 0 && !seen[knowledgeBaseID] {
+		return fmt.Errorf("knowledge base %s is not in knowledge_bases", knowledgeBaseID)
+	}
+	return nil
+}
+
+// resolveKnowledgeBases merges the legacy single knowledge base ID with the configured list
+func resolveKnowledgeBases(knowledgeBaseID string, knowledgeBases SessionKnowledgeBases) SessionKnowledgeBases {
+	if len(knowledgeBases) > 0 {
+		return knowledgeBases
+	}
+	if knowledgeBaseID == "" {
+		return nil
+	}
+	return SessionKnowledgeBases{{KnowledgeBaseID: knowledgeBaseID, Weight: 1.0}}
+}
+
 type StringArray []string
 
 // Value implements the driver.Valuer interface, used to convert StringArray to database value
@@ -118,3 +198,20 @@ func (c *SummaryConfig) Scan(value interface{}) error {
 	}
 	return json.Unmarshal(b, c)
 }
+
+// Value implements the driver.Valuer interface, used to convert SessionKnowledgeBases to database value
+func (c SessionKnowledgeBases) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to SessionKnowledgeBases
+func (c *SessionKnowledgeBases) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, c)
+}
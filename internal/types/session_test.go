@@ -0,0 +1,45 @@
+package types
+
+import "testing"
+
+func TestSessionKnowledgeBasesValidate(t *testing.T) {
+	tests := []struct {
+		name            string
+		knowledgeBaseID string
+		knowledgeBases  SessionKnowledgeBases
+		wantErr         bool
+	}{
+		{name: "single knowledge base", knowledgeBaseID: "kb1"},
+		{
+			name:            "primary in list",
+			knowledgeBaseID: "kb2",
+			knowledgeBases:  SessionKnowledgeBases{{KnowledgeBaseID: "kb1"}, {KnowledgeBaseID: "kb2", Weight: 0.5}},
+		},
+		{name: "list without primary", knowledgeBases: SessionKnowledgeBases{{KnowledgeBaseID: "kb1"}}},
+		{
+			name:            "primary missing from list",
+			knowledgeBaseID: "kb3",
+			knowledgeBases:  SessionKnowledgeBases{{KnowledgeBaseID: "kb1"}, {KnowledgeBaseID: "kb2"}},
+			wantErr:         true,
+		},
+		{
+			name:           "duplicate knowledge base",
+			knowledgeBases: SessionKnowledgeBases{{KnowledgeBaseID: "kb1"}, {KnowledgeBaseID: "kb1", Weight: 2}},
+			wantErr:        true,
+		},
+		{name: "empty ID", knowledgeBases: SessionKnowledgeBases{{KnowledgeBaseID: ""}}, wantErr: true},
+		{
+			name:           "negative weight",
+			knowledgeBases: SessionKnowledgeBases{{KnowledgeBaseID: "kb1", Weight: -1}},
+			wantErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.knowledgeBases.Validate(tt.knowledgeBaseID); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
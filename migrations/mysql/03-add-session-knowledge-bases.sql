@@ -0,0 +1,3 @@
+-- Allow a session to reference multiple knowledge bases with per knowledge base weight and thresholds
+ALTER TABLE sessions ADD COLUMN knowledge_bases JSON NULL
+    COMMENT 'Knowledge bases referenced by the session, each with optional weight, vector_threshold, keyword_threshold and embedding_top_k';
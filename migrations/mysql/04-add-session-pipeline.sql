@@ -0,0 +1,3 @@
+-- Allow a session to select a named chat pipeline or define one inline
+ALTER TABLE sessions ADD COLUMN pipeline JSON NULL
+    COMMENT 'Chat pipeline of the session: {"name": ...} references a configured pipeline, steps define one inline';
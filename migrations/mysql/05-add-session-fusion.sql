@@ -0,0 +1,3 @@
+-- Allow a session to choose how vector and keyword results are fused
+ALTER TABLE sessions ADD COLUMN fusion JSON NULL
+    COMMENT 'Hybrid search fusion config: method (none, rrf, weighted), rrf_k, vector_weight, keyword_weight';
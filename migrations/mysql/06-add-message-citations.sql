@@ -0,0 +1,3 @@
+-- Store the inline citations of assistant answers
+ALTER TABLE messages ADD COLUMN citations JSON NULL
+    COMMENT 'Inline citations: index, start, end, marker_offset, chunk_id, knowledge_id';
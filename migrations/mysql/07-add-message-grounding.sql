@@ -0,0 +1,3 @@
+-- Store the groundedness of assistant answers
+ALTER TABLE messages ADD COLUMN grounding JSON NULL
+    COMMENT 'Answer grounding: score, method, action and sentence scores';
@@ -0,0 +1,10 @@
+-- Create config_versions table for the version history of the hot reloadable conversation config
+CREATE TABLE IF NOT EXISTS config_versions (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    source VARCHAR(20) NOT NULL,
+    restored_from INT UNSIGNED NOT NULL DEFAULT 0,
+    checksum VARCHAR(64) NOT NULL,
+    content JSON NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_config_versions_created_at (created_at DESC)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Conversation config version history';
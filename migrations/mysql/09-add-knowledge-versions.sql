@@ -0,0 +1,26 @@
+-- Track the content of URL knowledge so a refresh only reindexes changed pages
+ALTER TABLE knowledges ADD COLUMN content_hash VARCHAR(64) NOT NULL DEFAULT ''
+    COMMENT 'Hash of the parsed content, a refresh reindexes the knowledge only if it changed';
+ALTER TABLE knowledges ADD COLUMN version INT NOT NULL DEFAULT 1
+    COMMENT 'Version of the indexed content';
+ALTER TABLE knowledges ADD COLUMN last_synced_at TIMESTAMP NULL
+    COMMENT 'Last time the source of URL knowledge was fetched';
+ALTER TABLE knowledges ADD COLUMN sync_error TEXT
+    COMMENT 'Error of the last refresh that failed before reindexing, the indexed content stays in use';
+
+-- Create knowledge_versions table for the earlier content versions of URL knowledge
+CREATE TABLE IF NOT EXISTS knowledge_versions (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    knowledge_id VARCHAR(36) NOT NULL,
+    version INT NOT NULL,
+    content_hash VARCHAR(64) NOT NULL DEFAULT '',
+    description TEXT,
+    storage_size BIGINT NOT NULL DEFAULT 0,
+    chunk_count INT NOT NULL DEFAULT 0,
+    chunks JSON NOT NULL,
+    processed_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_knowledge_versions_knowledge_id (knowledge_id),
+    UNIQUE INDEX idx_knowledge_versions_version (tenant_id, knowledge_id, version)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Earlier content versions of URL knowledge';
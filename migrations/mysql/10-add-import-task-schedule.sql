@@ -0,0 +1,25 @@
+-- Run import tasks on a cron schedule and track the pages they imported
+ALTER TABLE import_tasks ADD COLUMN schedule VARCHAR(100) NOT NULL DEFAULT ''
+    COMMENT 'Cron expression of a recurring crawl, empty for a one-shot import';
+ALTER TABLE import_tasks ADD COLUMN next_run_at TIMESTAMP NULL
+    COMMENT 'Next scheduled run of a recurring crawl';
+ALTER TABLE import_tasks ADD COLUMN last_run_at TIMESTAMP NULL
+    COMMENT 'Start of the latest run';
+ALTER TABLE import_tasks ADD COLUMN unchanged_count INT NOT NULL DEFAULT 0
+    COMMENT 'Pages of the latest run that were not modified since the previous run';
+ALTER TABLE import_tasks ADD COLUMN removed_count INT NOT NULL DEFAULT 0
+    COMMENT 'Pages of the previous run whose knowledge was removed by the latest run';
+CREATE INDEX idx_import_tasks_next_run_at ON import_tasks(next_run_at);
+
+-- Create import_task_pages table for the pages imported by an import task
+CREATE TABLE IF NOT EXISTS import_task_pages (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    task_id VARCHAR(36) NOT NULL,
+    url TEXT NOT NULL,
+    last_mod VARCHAR(64) NOT NULL DEFAULT '',
+    knowledge_id VARCHAR(36) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_import_task_pages_task_id (task_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Pages imported by import tasks';
@@ -0,0 +1,3 @@
+-- Keep the metadata recorded by the chunking strategy, e.g. the heading path of a Markdown section
+ALTER TABLE chunks ADD COLUMN metadata JSON NULL
+    COMMENT 'Metadata recorded by the chunking strategy';
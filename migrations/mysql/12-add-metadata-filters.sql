@@ -0,0 +1,3 @@
+-- Allow a session to define default metadata filters of retrieval
+ALTER TABLE sessions ADD COLUMN filters JSON NULL
+    COMMENT 'Default metadata filters of retrieval: list of field, op (eq, in, range, exists) and values';
@@ -0,0 +1,7 @@
+-- Restrict knowledge to an audience, its chunks inherit the labels
+ALTER TABLE knowledges ADD COLUMN access_labels JSON NULL
+    COMMENT 'Access labels, NULL means public; otherwise only an audience holding one of the labels can retrieve the knowledge';
+
+-- Allow a session to define the access labels of its audience
+ALTER TABLE sessions ADD COLUMN audience JSON NULL
+    COMMENT 'Access labels of the session audience';
@@ -0,0 +1,11 @@
+-- Fingerprint knowledge and chunks to find near-duplicate content
+ALTER TABLE knowledges ADD COLUMN min_hash JSON NULL
+    COMMENT 'MinHash signature of the parsed content';
+ALTER TABLE knowledges ADD COLUMN duplicate_of VARCHAR(36) NOT NULL DEFAULT ''
+    COMMENT 'ID of the earlier knowledge whose content this knowledge nearly duplicates';
+ALTER TABLE knowledges ADD COLUMN duplicate_similarity DOUBLE NOT NULL DEFAULT 0
+    COMMENT 'Estimated similarity to the knowledge it nearly duplicates';
+CREATE INDEX idx_knowledges_duplicate_of ON knowledges (duplicate_of);
+
+ALTER TABLE chunks ADD COLUMN sim_hash BIGINT NOT NULL DEFAULT 0
+    COMMENT 'SimHash of the chunk content';
@@ -0,0 +1,31 @@
+-- Create evaluation_tasks table so evaluation runs survive restarts and can be compared
+CREATE TABLE IF NOT EXISTS evaluation_tasks (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    dataset_id VARCHAR(255) NOT NULL DEFAULT '',
+    embedding_model_id VARCHAR(64) NOT NULL DEFAULT '',
+    start_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    end_time TIMESTAMP NULL,
+    status INT NOT NULL DEFAULT 0 COMMENT 'Task status: 0 pending, 1 running, 2 success, 3 failed',
+    err_msg TEXT,
+    total INT NOT NULL DEFAULT 0,
+    finished INT NOT NULL DEFAULT 0,
+    params JSON,
+    metric JSON,
+    INDEX idx_evaluation_tasks_tenant_id (tenant_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Evaluation runs with their parameters and average metrics';
+
+-- Create evaluation_questions table for the result of each question of an evaluation run
+CREATE TABLE IF NOT EXISTS evaluation_questions (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    task_id VARCHAR(36) NOT NULL,
+    question_id INT NOT NULL,
+    question TEXT,
+    expected_answer TEXT,
+    answer TEXT,
+    retrieved_chunk_ids JSON COMMENT 'Chunks after reranking, in rank order',
+    metric JSON,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_evaluation_questions_task_id (task_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Results of the questions of evaluation runs';
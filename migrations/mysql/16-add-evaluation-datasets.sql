@@ -0,0 +1,31 @@
+-- Create datasets table for the evaluation datasets uploaded by tenants
+CREATE TABLE IF NOT EXISTS datasets (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    description TEXT,
+    format VARCHAR(20) NOT NULL DEFAULT '',
+    file_name VARCHAR(255) NOT NULL DEFAULT '',
+    record_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_datasets_tenant_id (tenant_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Evaluation datasets uploaded by tenants';
+
+-- Create dataset_records table for the questions of the datasets
+CREATE TABLE IF NOT EXISTS dataset_records (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    dataset_id VARCHAR(36) NOT NULL,
+    question_id INT NOT NULL,
+    question TEXT NOT NULL,
+    answer TEXT,
+    passages JSON COMMENT 'Gold passages, ingested into a fresh knowledge base for the evaluation',
+    chunk_ids JSON COMMENT 'Gold chunk IDs in an existing knowledge base',
+    knowledge_ids JSON COMMENT 'Gold knowledge IDs in an existing knowledge base',
+    INDEX idx_dataset_records_dataset_id (dataset_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Questions of evaluation datasets with their reference answers and gold references';
+
+-- Evaluate an existing knowledge base instead of ingesting the dataset passages into a fresh one
+ALTER TABLE evaluation_tasks ADD COLUMN use_existing_kb BOOLEAN NOT NULL DEFAULT FALSE
+    COMMENT 'Whether the task evaluates an existing knowledge base';
@@ -0,0 +1,20 @@
+-- Create dataset_generation_tasks table for the evaluation datasets generated from knowledge bases
+CREATE TABLE IF NOT EXISTS dataset_generation_tasks (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    knowledge_base_id VARCHAR(36) NOT NULL DEFAULT '',
+    chat_model_id VARCHAR(64) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' COMMENT 'Task status: pending, processing, completed, failed or cancelled',
+    question_count INT NOT NULL DEFAULT 0,
+    processed_chunks INT NOT NULL DEFAULT 0 COMMENT 'Chunks sent to the chat model so far',
+    accepted_count INT NOT NULL DEFAULT 0,
+    rejected_count INT NOT NULL DEFAULT 0 COMMENT 'Chunks for which no question passed the quality checks',
+    dataset_id VARCHAR(36) NOT NULL DEFAULT '',
+    error_message TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP NULL,
+    INDEX idx_dataset_generation_tasks_tenant_id (tenant_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Tasks generating evaluation datasets from knowledge base chunks';
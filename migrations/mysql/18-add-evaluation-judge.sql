@@ -0,0 +1,5 @@
+-- Score the generated answers with a judge chat model
+ALTER TABLE evaluation_tasks ADD COLUMN judge_model_id VARCHAR(64) NOT NULL DEFAULT ''
+    COMMENT 'Chat model scoring the judge metrics, empty if the task was not judged';
+ALTER TABLE evaluation_questions ADD COLUMN judgements JSON
+    COMMENT 'Prompts and raw responses of the judge metrics';
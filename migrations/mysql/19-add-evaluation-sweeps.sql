@@ -0,0 +1,22 @@
+-- Create evaluation_sweeps table for retrieval-only parameter sweeps over a dataset
+CREATE TABLE IF NOT EXISTS evaluation_sweeps (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    dataset_id VARCHAR(255) NOT NULL DEFAULT '',
+    knowledge_base_id VARCHAR(36) NOT NULL DEFAULT '',
+    embedding_model_id VARCHAR(64) NOT NULL DEFAULT '',
+    rerank_model_id VARCHAR(64) NOT NULL DEFAULT '',
+    use_existing_kb BOOLEAN NOT NULL DEFAULT FALSE,
+    mode VARCHAR(32) NOT NULL DEFAULT 'grid' COMMENT 'How the settings are picked: grid or random',
+    samples INT NOT NULL DEFAULT 0,
+    seed BIGINT NOT NULL DEFAULT 0,
+    space JSON COMMENT 'Values tried for each retrieval parameter',
+    start_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    end_time TIMESTAMP NULL,
+    status INT NOT NULL DEFAULT 0 COMMENT 'Sweep status: 0 pending, 1 running, 2 success, 3 failed',
+    err_msg TEXT,
+    total INT NOT NULL DEFAULT 0,
+    finished INT NOT NULL DEFAULT 0,
+    trials JSON COMMENT 'Settings tried with their retrieval metrics and latency',
+    INDEX idx_evaluation_sweeps_tenant_id (tenant_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='Retrieval-only parameter sweeps with the metrics and latency of each setting';
@@ -0,0 +1,4 @@
+-- Allow a session to reference multiple knowledge bases with per knowledge base weight and thresholds
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS knowledge_bases JSONB NOT NULL DEFAULT '[]';
+
+COMMENT ON COLUMN sessions.knowledge_bases IS 'Knowledge bases referenced by the session, each with optional weight, vector_threshold, keyword_threshold and embedding_top_k';
@@ -0,0 +1,4 @@
+-- Allow a session to select a named chat pipeline or define one inline
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS pipeline JSONB;
+
+COMMENT ON COLUMN sessions.pipeline IS 'Chat pipeline of the session: {"name": ...} references a configured pipeline, steps define one inline';
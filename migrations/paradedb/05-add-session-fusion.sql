@@ -0,0 +1,4 @@
+-- Allow a session to choose how vector and keyword results are fused
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS fusion JSONB;
+
+COMMENT ON COLUMN sessions.fusion IS 'Hybrid search fusion config: method (none, rrf, weighted), rrf_k, vector_weight, keyword_weight';
@@ -0,0 +1,4 @@
+-- Store the inline citations of assistant answers
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS citations JSONB NOT NULL DEFAULT '[]';
+
+COMMENT ON COLUMN messages.citations IS 'Inline citations: index, start, end, marker_offset, chunk_id, knowledge_id';
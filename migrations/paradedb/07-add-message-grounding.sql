@@ -0,0 +1,4 @@
+-- Store the groundedness of assistant answers
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS grounding JSONB;
+
+COMMENT ON COLUMN messages.grounding IS 'Answer grounding: score, method, action and sentence scores';
@@ -0,0 +1,16 @@
+-- Create config_versions table for the version history of the hot reloadable conversation config
+CREATE TABLE IF NOT EXISTS config_versions (
+    id SERIAL PRIMARY KEY,
+    source VARCHAR(20) NOT NULL,
+    restored_from INTEGER NOT NULL DEFAULT 0,
+    checksum VARCHAR(64) NOT NULL,
+    content JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_config_versions_created_at ON config_versions(created_at DESC);
+
+-- Add comment
+COMMENT ON TABLE config_versions IS 'Conversation config version history';
+COMMENT ON COLUMN config_versions.source IS 'Version source: startup, file, rollback';
+COMMENT ON COLUMN config_versions.restored_from IS 'Version restored by a rollback';
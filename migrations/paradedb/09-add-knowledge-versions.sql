@@ -0,0 +1,32 @@
+-- Track the content of URL knowledge so a refresh only reindexes changed pages
+ALTER TABLE knowledges ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE knowledges ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE knowledges ADD COLUMN IF NOT EXISTS last_synced_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE knowledges ADD COLUMN IF NOT EXISTS sync_error TEXT;
+
+COMMENT ON COLUMN knowledges.content_hash IS 'Hash of the parsed content, a refresh reindexes the knowledge only if it changed';
+COMMENT ON COLUMN knowledges.version IS 'Version of the indexed content';
+COMMENT ON COLUMN knowledges.last_synced_at IS 'Last time the source of URL knowledge was fetched';
+COMMENT ON COLUMN knowledges.sync_error IS 'Error of the last refresh that failed before reindexing, the indexed content stays in use';
+
+-- Create knowledge_versions table for the earlier content versions of URL knowledge
+CREATE TABLE IF NOT EXISTS knowledge_versions (
+    id VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id INTEGER NOT NULL,
+    knowledge_id VARCHAR(36) NOT NULL,
+    version INTEGER NOT NULL,
+    content_hash VARCHAR(64) NOT NULL DEFAULT '',
+    description TEXT,
+    storage_size BIGINT NOT NULL DEFAULT 0,
+    chunk_count INTEGER NOT NULL DEFAULT 0,
+    chunks JSONB NOT NULL DEFAULT '[]',
+    processed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_knowledge_versions_knowledge_id ON knowledge_versions(knowledge_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_knowledge_versions_version ON knowledge_versions(tenant_id, knowledge_id, version);
+
+-- Add comment
+COMMENT ON TABLE knowledge_versions IS 'Earlier content versions of URL knowledge';
+COMMENT ON COLUMN knowledge_versions.chunks IS 'Chunks of the version, restored with new IDs by a rollback';
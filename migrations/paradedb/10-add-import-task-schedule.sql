@@ -0,0 +1,31 @@
+-- Run import tasks on a cron schedule and track the pages they imported
+ALTER TABLE import_tasks ADD COLUMN IF NOT EXISTS schedule VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE import_tasks ADD COLUMN IF NOT EXISTS next_run_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE import_tasks ADD COLUMN IF NOT EXISTS last_run_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE import_tasks ADD COLUMN IF NOT EXISTS unchanged_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE import_tasks ADD COLUMN IF NOT EXISTS removed_count INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_import_tasks_next_run_at ON import_tasks(next_run_at);
+
+COMMENT ON COLUMN import_tasks.schedule IS 'Cron expression of a recurring crawl, empty for a one-shot import';
+COMMENT ON COLUMN import_tasks.next_run_at IS 'Next scheduled run of a recurring crawl';
+COMMENT ON COLUMN import_tasks.last_run_at IS 'Start of the latest run';
+COMMENT ON COLUMN import_tasks.unchanged_count IS 'Pages of the latest run that were not modified since the previous run';
+COMMENT ON COLUMN import_tasks.removed_count IS 'Pages of the previous run whose knowledge was removed by the latest run';
+
+-- Create import_task_pages table for the pages imported by an import task
+CREATE TABLE IF NOT EXISTS import_task_pages (
+    id VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id INTEGER NOT NULL,
+    task_id VARCHAR(36) NOT NULL,
+    url TEXT NOT NULL,
+    last_mod VARCHAR(64) NOT NULL DEFAULT '',
+    knowledge_id VARCHAR(36) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_task_pages_task_id ON import_task_pages(task_id);
+
+-- Add comment
+COMMENT ON TABLE import_task_pages IS 'Pages imported by import tasks';
+COMMENT ON COLUMN import_task_pages.last_mod IS 'Modification time of the page at its latest import, from sitemap.xml or the Last-Modified header';
@@ -0,0 +1,2 @@
+-- Keep the metadata recorded by the chunking strategy, e.g. the heading path of a Markdown section
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS metadata JSONB;
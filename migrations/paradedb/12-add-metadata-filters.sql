@@ -0,0 +1,8 @@
+-- Index the metadata of knowledge and chunks, so retrieval can filter on it
+ALTER TABLE embeddings ADD COLUMN IF NOT EXISTS metadata JSONB;
+CREATE INDEX IF NOT EXISTS embeddings_metadata_idx ON embeddings USING gin (metadata);
+
+-- Allow a session to define default metadata filters of retrieval
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS filters JSONB;
+
+COMMENT ON COLUMN sessions.filters IS 'Default metadata filters of retrieval: list of field, op (eq, in, range, exists) and values';
@@ -0,0 +1,11 @@
+-- Restrict knowledge to an audience, its chunks inherit the labels and are indexed with them
+ALTER TABLE knowledges ADD COLUMN IF NOT EXISTS access_labels JSONB;
+ALTER TABLE embeddings ADD COLUMN IF NOT EXISTS access_labels JSONB;
+CREATE INDEX IF NOT EXISTS embeddings_access_labels_idx ON embeddings USING gin (access_labels);
+
+-- Allow a session to define the access labels of its audience
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS audience JSONB;
+
+COMMENT ON COLUMN knowledges.access_labels IS 'Access labels, NULL means public; otherwise only an audience holding one of the labels can retrieve the knowledge';
+COMMENT ON COLUMN embeddings.access_labels IS 'Access labels inherited from the knowledge, NULL means public';
+COMMENT ON COLUMN sessions.audience IS 'Access labels of the session audience';
@@ -0,0 +1,12 @@
+-- Fingerprint knowledge and chunks to find near-duplicate content
+ALTER TABLE knowledges ADD COLUMN IF NOT EXISTS min_hash JSONB;
+ALTER TABLE knowledges ADD COLUMN IF NOT EXISTS duplicate_of VARCHAR(36) NOT NULL DEFAULT '';
+ALTER TABLE knowledges ADD COLUMN IF NOT EXISTS duplicate_similarity DOUBLE PRECISION NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_knowledges_duplicate_of ON knowledges (duplicate_of);
+
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS sim_hash BIGINT NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN knowledges.min_hash IS 'MinHash signature of the parsed content';
+COMMENT ON COLUMN knowledges.duplicate_of IS 'ID of the earlier knowledge whose content this knowledge nearly duplicates';
+COMMENT ON COLUMN knowledges.duplicate_similarity IS 'Estimated similarity to the knowledge it nearly duplicates';
+COMMENT ON COLUMN chunks.sim_hash IS 'SimHash of the chunk content';
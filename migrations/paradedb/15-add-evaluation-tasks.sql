@@ -0,0 +1,39 @@
+-- Create evaluation_tasks table so evaluation runs survive restarts and can be compared
+CREATE TABLE IF NOT EXISTS evaluation_tasks (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    dataset_id VARCHAR(255) NOT NULL DEFAULT '',
+    embedding_model_id VARCHAR(64) NOT NULL DEFAULT '',
+    start_time TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    end_time TIMESTAMP WITH TIME ZONE,
+    status INTEGER NOT NULL DEFAULT 0,
+    err_msg TEXT,
+    total INTEGER NOT NULL DEFAULT 0,
+    finished INTEGER NOT NULL DEFAULT 0,
+    params JSONB,
+    metric JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_evaluation_tasks_tenant_id ON evaluation_tasks(tenant_id);
+
+-- Create evaluation_questions table for the result of each question of an evaluation run
+CREATE TABLE IF NOT EXISTS evaluation_questions (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    task_id VARCHAR(36) NOT NULL,
+    question_id INTEGER NOT NULL,
+    question TEXT,
+    expected_answer TEXT,
+    answer TEXT,
+    retrieved_chunk_ids JSONB,
+    metric JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_evaluation_questions_task_id ON evaluation_questions(task_id);
+
+-- Add comment
+COMMENT ON TABLE evaluation_tasks IS 'Evaluation runs with their parameters and average metrics';
+COMMENT ON COLUMN evaluation_tasks.status IS 'Task status: 0 pending, 1 running, 2 success, 3 failed';
+COMMENT ON TABLE evaluation_questions IS 'Results of the questions of evaluation runs';
+COMMENT ON COLUMN evaluation_questions.retrieved_chunk_ids IS 'Chunks after reranking, in rank order';
@@ -0,0 +1,40 @@
+-- Create datasets table for the evaluation datasets uploaded by tenants
+CREATE TABLE IF NOT EXISTS datasets (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    description TEXT,
+    format VARCHAR(20) NOT NULL DEFAULT '',
+    file_name VARCHAR(255) NOT NULL DEFAULT '',
+    record_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_datasets_tenant_id ON datasets(tenant_id);
+
+-- Create dataset_records table for the questions of the datasets
+CREATE TABLE IF NOT EXISTS dataset_records (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    dataset_id VARCHAR(36) NOT NULL,
+    question_id INTEGER NOT NULL,
+    question TEXT NOT NULL,
+    answer TEXT,
+    passages JSONB,
+    chunk_ids JSONB,
+    knowledge_ids JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_dataset_records_dataset_id ON dataset_records(dataset_id);
+
+-- Evaluate an existing knowledge base instead of ingesting the dataset passages into a fresh one
+ALTER TABLE evaluation_tasks ADD COLUMN IF NOT EXISTS use_existing_kb BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Add comment
+COMMENT ON TABLE datasets IS 'Evaluation datasets uploaded by tenants';
+COMMENT ON TABLE dataset_records IS 'Questions of evaluation datasets with their reference answers and gold references';
+COMMENT ON COLUMN dataset_records.passages IS 'Gold passages, ingested into a fresh knowledge base for the evaluation';
+COMMENT ON COLUMN dataset_records.chunk_ids IS 'Gold chunk IDs in an existing knowledge base';
+COMMENT ON COLUMN dataset_records.knowledge_ids IS 'Gold knowledge IDs in an existing knowledge base';
+COMMENT ON COLUMN evaluation_tasks.use_existing_kb IS 'Whether the task evaluates an existing knowledge base';
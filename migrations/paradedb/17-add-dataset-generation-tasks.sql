@@ -0,0 +1,27 @@
+-- Create dataset_generation_tasks table for the evaluation datasets generated from knowledge bases
+CREATE TABLE IF NOT EXISTS dataset_generation_tasks (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    knowledge_base_id VARCHAR(36) NOT NULL DEFAULT '',
+    chat_model_id VARCHAR(64) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    question_count INTEGER NOT NULL DEFAULT 0,
+    processed_chunks INTEGER NOT NULL DEFAULT 0,
+    accepted_count INTEGER NOT NULL DEFAULT 0,
+    rejected_count INTEGER NOT NULL DEFAULT 0,
+    dataset_id VARCHAR(36) NOT NULL DEFAULT '',
+    error_message TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_dataset_generation_tasks_tenant_id ON dataset_generation_tasks(tenant_id);
+
+-- Add comment
+COMMENT ON TABLE dataset_generation_tasks IS 'Tasks generating evaluation datasets from knowledge base chunks';
+COMMENT ON COLUMN dataset_generation_tasks.status IS 'Task status: pending, processing, completed, failed or cancelled';
+COMMENT ON COLUMN dataset_generation_tasks.processed_chunks IS 'Chunks sent to the chat model so far';
+COMMENT ON COLUMN dataset_generation_tasks.rejected_count IS 'Chunks for which no question passed the quality checks';
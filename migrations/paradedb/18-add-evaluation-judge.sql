@@ -0,0 +1,7 @@
+-- Score the generated answers with a judge chat model
+ALTER TABLE evaluation_tasks ADD COLUMN IF NOT EXISTS judge_model_id VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE evaluation_questions ADD COLUMN IF NOT EXISTS judgements JSONB;
+
+-- Add comment
+COMMENT ON COLUMN evaluation_tasks.judge_model_id IS 'Chat model scoring the judge metrics, empty if the task was not judged';
+COMMENT ON COLUMN evaluation_questions.judgements IS 'Prompts and raw responses of the judge metrics';
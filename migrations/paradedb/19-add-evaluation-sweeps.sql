@@ -0,0 +1,30 @@
+-- Create evaluation_sweeps table for retrieval-only parameter sweeps over a dataset
+CREATE TABLE IF NOT EXISTS evaluation_sweeps (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    dataset_id VARCHAR(255) NOT NULL DEFAULT '',
+    knowledge_base_id VARCHAR(36) NOT NULL DEFAULT '',
+    embedding_model_id VARCHAR(64) NOT NULL DEFAULT '',
+    rerank_model_id VARCHAR(64) NOT NULL DEFAULT '',
+    use_existing_kb BOOLEAN NOT NULL DEFAULT FALSE,
+    mode VARCHAR(32) NOT NULL DEFAULT 'grid',
+    samples INTEGER NOT NULL DEFAULT 0,
+    seed BIGINT NOT NULL DEFAULT 0,
+    space JSONB,
+    start_time TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    end_time TIMESTAMP WITH TIME ZONE,
+    status INTEGER NOT NULL DEFAULT 0,
+    err_msg TEXT,
+    total INTEGER NOT NULL DEFAULT 0,
+    finished INTEGER NOT NULL DEFAULT 0,
+    trials JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_evaluation_sweeps_tenant_id ON evaluation_sweeps(tenant_id);
+
+-- Add comment
+COMMENT ON TABLE evaluation_sweeps IS 'Retrieval-only parameter sweeps with the metrics and latency of each setting';
+COMMENT ON COLUMN evaluation_sweeps.mode IS 'How the settings are picked: grid or random';
+COMMENT ON COLUMN evaluation_sweeps.space IS 'Values tried for each retrieval parameter';
+COMMENT ON COLUMN evaluation_sweeps.status IS 'Sweep status: 0 pending, 1 running, 2 success, 3 failed';
+COMMENT ON COLUMN evaluation_sweeps.trials IS 'Settings tried with their retrieval metrics and latency';
@@ -0,0 +1,82 @@
+// Package htmltext extracts the text of HTML documents and fragments, it is shared by the document parser
+// and the tools preparing QA data
+package htmltext
+
+import (
+	stdhtml "html"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	// tagPattern matches the tags of HTML that could not be parsed
+	tagPattern = regexp.MustCompile(`<[^>]+>`)
+	// spacePattern matches runs of whitespace within a line
+	spacePattern = regexp.MustCompile(`[^\S\n]+`)
+	// blankLinesPattern matches more than one empty line
+	blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// skippedElements are the elements whose text is not content
+var skippedElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "head": true,
+}
+
+// elementBreaks are the breaks written around elements that are not inline: block elements are paragraphs,
+// line breaks and table rows end a line and table cells are separated by a space
+var elementBreaks = map[string]string{
+	"address": "\n\n", "article": "\n\n", "aside": "\n\n", "blockquote": "\n\n", "dd": "\n\n",
+	"div": "\n\n", "dl": "\n\n", "dt": "\n\n", "figcaption": "\n\n", "figure": "\n\n", "footer": "\n\n",
+	"h1": "\n\n", "h2": "\n\n", "h3": "\n\n", "h4": "\n\n", "h5": "\n\n", "h6": "\n\n", "header": "\n\n",
+	"hr": "\n\n", "li": "\n\n", "main": "\n\n", "nav": "\n\n", "ol": "\n\n", "p": "\n\n", "pre": "\n\n",
+	"section": "\n\n", "table": "\n\n", "ul": "\n\n",
+	"br": "\n", "tr": "\n",
+	"td": " ", "th": " ",
+}
+
+// Text extracts the text of HTML, leaving out scripts and styles. Block elements are kept in paragraphs
+// of their own and HTML that cannot be parsed has its tags stripped.
+func Text(text string) string {
+	doc, err := html.Parse(strings.NewReader(text))
+	if err != nil {
+		return clean(stdhtml.UnescapeString(tagPattern.ReplaceAllString(text, " ")))
+	}
+
+	var b strings.Builder
+	var extract func(*html.Node)
+	extract = func(n *html.Node) {
+		if n.Type == html.ElementNode && skippedElements[n.Data] {
+			return
+		}
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		var separator string
+		if n.Type == html.ElementNode {
+			separator = elementBreaks[n.Data]
+		}
+		b.WriteString(separator)
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			extract(c)
+		}
+		b.WriteString(separator)
+	}
+	extract(doc)
+	return clean(b.String())
+}
+
+// Inline extracts the text of HTML like Text, on a single line with all whitespace collapsed to one space
+func Inline(text string) string {
+	return strings.Join(strings.Fields(Text(text)), " ")
+}
+
+// clean collapses the whitespace within lines, drops empty lines beyond a paragraph break and trims the text
+func clean(text string) string {
+	lines := strings.Split(spacePattern.ReplaceAllString(text, " "), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
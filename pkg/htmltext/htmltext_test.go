@@ -0,0 +1,42 @@
+package htmltext
+
+import "testing"
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name       string
+		html       string
+		wantText   string
+		wantInline string
+	}{
+		{
+			name:       "empty",
+			html:       "",
+			wantText:   "",
+			wantInline: "",
+		},
+		{
+			name:       "fragment with entities",
+			html:       "您好，<b>请</b> 重启&lt;服务&gt; &amp;  检查",
+			wantText:   "您好，请 重启<服务> & 检查",
+			wantInline: "您好，请 重启<服务> & 检查",
+		},
+		{
+			name: "document drops scripts and keeps blocks",
+			html: "<html><head><title>t</title></head><body><h1>Title</h1><script>x()</script>" +
+				"<p>Hello   <b>world</b></p><ul><li>a</li><li>b</li></ul><style>p{}</style></body></html>",
+			wantText:   "Title\n\nHello world\n\na\n\nb",
+			wantInline: "Title Hello world a b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.html); got != tt.wantText {
+				t.Errorf("Text() = %q, want %q", got, tt.wantText)
+			}
+			if got := Inline(tt.html); got != tt.wantInline {
+				t.Errorf("Inline() = %q, want %q", got, tt.wantInline)
+			}
+		})
+	}
+}
@@ -2,19 +2,15 @@ package main
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"os"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/gocolly/colly/v2"
 )
 
 type URLRequest struct {
@@ -28,6 +24,8 @@ type ImportStats struct {
 	Failed    int
 	Duplicate int
 	Skipped   int
+	Unchanged int
+	Removed   int
 }
 
 type FailedRecord struct {
@@ -252,8 +250,12 @@ func (imp *DocsiteImporter) PrintStats(elapsed time.Duration) {
 	fmt.Printf("  失败: %d 个\n", imp.stats.Failed)
 	fmt.Printf("  重复: %d 个\n", imp.stats.Duplicate)
 	fmt.Printf("  跳过: %d 个 (断点续传)\n", imp.stats.Skipped)
-	if imp.stats.Total > 0 {
-		successRate := float64(imp.stats.Success) / float64(imp.stats.Total-imp.stats.Skipped) * 100
+	if imp.stats.Unchanged > 0 || imp.stats.Removed > 0 {
+		fmt.Printf("  未变化: %d 个\n", imp.stats.Unchanged)
+		fmt.Printf("  已移除: %d 个 (页面已从文档站消失)\n", imp.stats.Removed)
+	}
+	if submitted := imp.stats.Total - imp.stats.Skipped - imp.stats.Unchanged; submitted > 0 {
+		successRate := float64(imp.stats.Success) / float64(submitted) * 100
 		fmt.Printf("  成功率: %.2f%%\n", successRate)
 	}
 	fmt.Printf("\n  总耗时: %.2f 秒\n", elapsed.Seconds())
@@ -299,120 +301,136 @@ func truncateString(s string, maxLen int) string {
 	return s[:maxLen] + "..."
 }
 
-func CrawlDocsite(ctx context.Context, baseURL string, maxPages int) ([]string, error) {
-	fmt.Printf("\n开始爬取文档站: %s\n", baseURL)
-	fmt.Printf("最大页面数: %d\n\n", maxPages)
+// ImportTaskRequest 服务端批量导入任务的创建参数
+type ImportTaskRequest struct {
+	BaseURL          string   `json:"base_url"`
+	MaxPages         int      `json:"max_pages"`
+	EnableMultimodel *bool    `json:"enable_multimodel,omitempty"`
+	Schedule         string   `json:"schedule,omitempty"`
+	IncludePatterns  []string `json:"include_patterns,omitempty"`
+	ExcludePatterns  []string `json:"exclude_patterns,omitempty"`
+}
 
-	parsedBase, err := url.Parse(baseURL)
+// ImportTask 服务端批量导入任务
+type ImportTask struct {
+	ID             string     `json:"id"`
+	Status         string     `json:"status"`
+	TotalURLs      int        `json:"total_urls"`
+	ProcessedURLs  int        `json:"processed_urls"`
+	SuccessCount   int        `json:"success_count"`
+	FailedCount    int        `json:"failed_count"`
+	DuplicateCount int        `json:"duplicate_count"`
+	UnchangedCount int        `json:"unchanged_count"`
+	RemovedCount   int        `json:"removed_count"`
+	CurrentURL     string     `json:"current_url"`
+	ErrorMessage   string     `json:"error_message"`
+	Schedule       string     `json:"schedule"`
+	NextRunAt      *time.Time `json:"next_run_at"`
+	Results        []struct {
+		URL    string `json:"url"`
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	} `json:"results"`
+}
+
+// CreateImportTask 在服务端创建批量导入任务，由服务端爬取文档站 (sitemap.xml、robots.txt) 并导入
+func (imp *DocsiteImporter) CreateImportTask(payload *ImportTaskRequest) (*ImportTask, error) {
+	apiURL := fmt.Sprintf("%s/api/v1/knowledge-bases/%s/import-tasks", imp.apiURL, imp.knowledgeBaseID)
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return nil, fmt.Errorf("无效的 URL: %w", err)
+		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
+	req.Header.Set("Content-Type", "application/json")
+	return imp.doImportTaskRequest(req, http.StatusCreated)
+}
 
-	c := colly.NewCollector(
-		colly.AllowedDomains(parsedBase.Host),
-		colly.MaxDepth(5),
-		colly.Async(true),
-	)
+// GetImportTask 查询批量导入任务的状态和进度
+func (imp *DocsiteImporter) GetImportTask(taskID string) (*ImportTask, error) {
+	apiURL := fmt.Sprintf("%s/api/v1/import-tasks/%s", imp.apiURL, taskID)
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	return imp.doImportTaskRequest(req, http.StatusOK)
+}
 
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*",
-		Parallelism: 5,
-		Delay:       300 * time.Millisecond,
-	})
+func (imp *DocsiteImporter) doImportTaskRequest(req *http.Request, expectedStatus int) (*ImportTask, error) {
+	req.Header.Set("X-API-Key", imp.token)
 
-	urls := make([]string, 0)
-	visited := make(map[string]bool)
-	var mu sync.Mutex
+	client := &http.Client{
+		Timeout: 60 * time.Second,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
 
-	c.OnHTML("a[href]", func(e *colly.HTMLElement) {
-		link := e.Attr("href")
-		absoluteURL := e.Request.AbsoluteURL(link)
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != expectedStatus {
+		return nil, fmt.Errorf("API 错误 %d: %s", resp.StatusCode, string(body))
+	}
 
-		if absoluteURL == "" {
-			return
-		}
+	var result struct {
+		Data *ImportTask `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || result.Data == nil {
+		return nil, fmt.Errorf("解析响应失败: %s", string(body))
+	}
+	return result.Data, nil
+}
 
-		parsedURL, err := url.Parse(absoluteURL)
+// WaitImportTask 等待导入任务本次运行结束，定时任务只等待当前这一次
+func (imp *DocsiteImporter) WaitImportTask(taskID string) (*ImportTask, error) {
+	lastProcessed := -1
+	for {
+		task, err := imp.GetImportTask(taskID)
 		if err != nil {
-			return
+			return nil, err
 		}
-
-		if parsedURL.Host != parsedBase.Host {
-			return
+		switch task.Status {
+		case "completed", "failed", "cancelled":
+			return task, nil
 		}
-
-		parsedURL.Fragment = ""
-		cleanURL := parsedURL.String()
-
-		if shouldSkipURL(cleanURL) {
-			return
+		if task.ProcessedURLs != lastProcessed && task.TotalURLs > 0 {
+			lastProcessed = task.ProcessedURLs
+			fmt.Printf("[%d/%d] %s\n", task.ProcessedURLs, task.TotalURLs, truncateString(task.CurrentURL, 80))
 		}
-
-		mu.Lock()
-		alreadyVisited := visited[cleanURL]
-		urlCount := len(urls)
-		if !alreadyVisited && urlCount < maxPages {
-			visited[cleanURL] = true
-			urls = append(urls, cleanURL)
-			fmt.Printf("  发现: [%d/%d] %s\n", len(urls), maxPages, cleanURL)
-		}
-		mu.Unlock()
-
-		if alreadyVisited || urlCount >= maxPages {
-			return
-		}
-
-		if err := e.Request.Visit(cleanURL); err != nil {
-			if err.Error() != "URL already visited" {
-			}
-		}
-	})
-
-	c.OnRequest(func(r *colly.Request) {
-	})
-
-	c.OnError(func(r *colly.Response, err error) {
-		fmt.Printf("  ⚠️  爬取失败: %s - %v\n", r.Request.URL, err)
-	})
-
-	if err := c.Visit(baseURL); err != nil {
-		return nil, fmt.Errorf("无法访问起始 URL: %w", err)
+		time.Sleep(3 * time.Second)
 	}
-
-	c.Wait()
-
-	fmt.Printf("\n✅ 爬取完成，共发现 %d 个 URL\n", len(urls))
-	return urls, nil
 }
 
-func shouldSkipURL(urlStr string) bool {
-	lowerURL := strings.ToLower(urlStr)
-
-	skipExtensions := []string{
-		".jpg", ".jpeg", ".png", ".gif", ".svg", ".ico",
-		".pdf", ".zip", ".tar", ".gz", ".rar",
-		".mp4", ".avi", ".mov", ".mp3", ".wav",
-		".css", ".js", ".woff", ".woff2", ".ttf", ".eot",
-	}
-
-	for _, ext := range skipExtensions {
-		if strings.HasSuffix(lowerURL, ext) {
-			return true
+// ApplyImportTask 汇总服务端导入任务的结果
+func (imp *DocsiteImporter) ApplyImportTask(task *ImportTask) {
+	imp.stats = ImportStats{
+		Total:     task.TotalURLs,
+		Success:   task.SuccessCount,
+		Failed:    task.FailedCount,
+		Duplicate: task.DuplicateCount,
+		Unchanged: task.UnchangedCount,
+		Removed:   task.RemovedCount,
+	}
+	for _, result := range task.Results {
+		if result.Status == "failed" {
+			imp.failedRecords = append(imp.failedRecords, FailedRecord{URL: result.URL, Error: result.Error})
 		}
 	}
+}
 
-	skipPatterns := []string{
-		"/api/", "/assets/", "/static/",
-		"mailto:", "tel:", "javascript:",
-	}
+// patternList 可重复指定的 URL 正则参数
+type patternList []string
 
-	for _, pattern := range skipPatterns {
-		if strings.Contains(lowerURL, pattern) {
-			return true
-		}
-	}
+func (p *patternList) String() string {
+	return strings.Join(*p, ",")
+}
 
-	return false
+func (p *patternList) Set(value string) error {
+	*p = append(*p, value)
+	return nil
 }
 
 func ReadURLsFromFile(filename string) ([]string, error) {
@@ -447,6 +465,9 @@ func main() {
 		enableMultimodel bool
 		progressFile     string
 		failedLog        string
+		schedule         string
+		includePatterns  patternList
+		excludePatterns  patternList
 		showHelp         bool
 	)
 
@@ -460,6 +481,9 @@ func main() {
 	flag.BoolVar(&enableMultimodel, "enable-multimodel", false, "启用多模态处理")
 	flag.StringVar(&progressFile, "progress-file", ".docsite-importer-progress.json", "断点续传进度文件")
 	flag.StringVar(&failedLog, "failed-log", "failed_imports.json", "失败记录保存文件")
+	flag.StringVar(&schedule, "schedule", "", "定时重新爬取的 cron 表达式 (仅 --base-url)")
+	flag.Var(&includePatterns, "include", "只导入匹配该正则的 URL，可重复指定 (仅 --base-url)")
+	flag.Var(&excludePatterns, "exclude", "不爬取匹配该正则的 URL，可重复指定 (仅 --base-url)")
 	flag.BoolVar(&showHelp, "help", false, "显示帮助信息")
 
 	flag.Parse()
@@ -503,31 +527,52 @@ func main() {
 
 	importer := NewDocsiteImporter(apiURL, token, kbID, concurrent, enableMultimodelPtr, progressFile)
 
-	var urls []string
-	var err error
-
 	if baseURL != "" {
-		ctx := context.Background()
-		urls, err = CrawlDocsite(ctx, baseURL, maxPages)
+		// 爬取由服务端导入任务完成，再次运行时只提交新增和修改的页面
+		startTime := time.Now()
+		task, err := importer.CreateImportTask(&ImportTaskRequest{
+			BaseURL:          baseURL,
+			MaxPages:         maxPages,
+			EnableMultimodel: enableMultimodelPtr,
+			Schedule:         schedule,
+			IncludePatterns:  includePatterns,
+			ExcludePatterns:  excludePatterns,
+		})
+		if err != nil {
+			fmt.Printf("❌ 创建导入任务失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\n✅ 已创建导入任务: %s\n", task.ID)
+		if task.Schedule != "" && task.NextRunAt != nil {
+			fmt.Printf("定时爬取: %s，下次运行: %s\n", task.Schedule, task.NextRunAt.Local().Format(time.DateTime))
+		}
+
+		task, err = importer.WaitImportTask(task.ID)
 		if err != nil {
-			fmt.Printf("❌ 爬取失败: %v\n", err)
+			fmt.Printf("❌ 查询导入任务失败: %v\n", err)
 			os.Exit(1)
 		}
+		if task.Status != "completed" {
+			fmt.Printf("❌ 导入任务%s: %s\n", task.Status, task.ErrorMessage)
+			os.Exit(1)
+		}
+		importer.ApplyImportTask(task)
+		importer.PrintStats(time.Since(startTime))
 	} else {
-		urls, err = ReadURLsFromFile(urlFile)
+		urls, err := ReadURLsFromFile(urlFile)
 		if err != nil {
 			fmt.Printf("❌ 读取文件失败: %v\n", err)
 			os.Exit(1)
 		}
 		fmt.Printf("\n✅ 从文件读取了 %d 个 URL\n", len(urls))
-	}
 
-	if len(urls) == 0 {
-		fmt.Println("❌ 没有发现任何 URL")
-		os.Exit(1)
-	}
+		if len(urls) == 0 {
+			fmt.Println("❌ 没有发现任何 URL")
+			os.Exit(1)
+		}
 
-	importer.ImportURLs(urls)
+		importer.ImportURLs(urls)
+	}
 	importer.SaveFailedRecords(failedLog)
 
 	if importer.stats.Failed > 0 {
@@ -552,10 +597,13 @@ func printHelp() {
 	fmt.Println("  --url-file        URL 列表文件 (每行一个 URL)")
 	fmt.Println("\n可选参数:")
 	fmt.Println("  --max-pages       最大爬取页面数 (默认: 200)")
-	fmt.Println("  --concurrent      并发导入数 (默认: 3)")
+	fmt.Println("  --concurrent      并发导入数 (默认: 3, 仅 --url-file)")
 	fmt.Println("  --enable-multimodel  启用多模态处理 (默认: false)")
-	fmt.Println("  --progress-file   断点续传进度文件 (默认: .docsite-importer-progress.json)")
+	fmt.Println("  --progress-file   断点续传进度文件 (默认: .docsite-importer-progress.json, 仅 --url-file)")
 	fmt.Println("  --failed-log      失败记录保存文件 (默认: failed_imports.json)")
+	fmt.Println("  --schedule        定时重新爬取的 cron 表达式, 例如 \"0 3 * * *\" (仅 --base-url)")
+	fmt.Println("  --include         只导入匹配该正则的 URL, 可重复指定 (仅 --base-url)")
+	fmt.Println("  --exclude         不爬取匹配该正则的 URL, 可重复指定 (仅 --base-url)")
 	fmt.Println("\n示例:")
 	fmt.Println("\n  1. 自动爬取并导入:")
 	fmt.Println("     docsite-importer \\")
@@ -564,7 +612,7 @@ func printHelp() {
 	fmt.Println("       --kb-id kb-xxxxx \\")
 	fmt.Println("       --base-url https://docs.example.com \\")
 	fmt.Println("       --max-pages 200 \\")
-	fmt.Println("       --concurrent 3")
+	fmt.Println("       --schedule \"0 3 * * *\"")
 	fmt.Println("\n  2. 从文件导入:")
 	fmt.Println("     docsite-importer \\")
 	fmt.Println("       --api-url http://localhost:8080 \\")
@@ -580,7 +628,9 @@ func printHelp() {
 	fmt.Println("       --base-url https://docs.example.com \\")
 	fmt.Println("       --enable-multimodel")
 	fmt.Println("\n特性:")
-	fmt.Println("  - 自动爬取或从文件读取 URL")
+	fmt.Println("  - 由服务端爬取文档站, 优先使用 sitemap.xml 并遵守 robots.txt")
+	fmt.Println("  - 定时增量爬取, 只提交新增和修改的页面, 移除已消失页面的知识")
+	fmt.Println("  - 从文件读取 URL")
 	fmt.Println("  - 并发导入，可配置并发数")
 	fmt.Println("  - 断点续传，失败后可继续导入")
 	fmt.Println("  - 详细的进度显示和统计")
@@ -1,7 +1,12 @@
 module github.com/Wintercom/c-cube/tools
 
-go 1.21
+go 1.24.0
 
-require golang.org/x/net v0.19.0
+require (
+	github.com/Tencent/WeKnora v0.0.0-00010101000000-000000000000
+	golang.org/x/net v0.43.0 // indirect
+)
 
-require github.com/lib/pq v1.10.9 // indirect
+require github.com/lib/pq v1.10.9
+
+replace github.com/Tencent/WeKnora => ../
@@ -3,14 +3,13 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	stdhtml "html"
 	"os"
 	"regexp"
 	"sort"
 	"strings"
 	"unicode"
 
-	"golang.org/x/net/html"
+	"github.com/Tencent/WeKnora/pkg/htmltext"
 )
 
 type HistoricalQA struct {
@@ -61,39 +60,7 @@ func NewKeywordExtractor() *KeywordExtractor {
 }
 
 func (e *KeywordExtractor) CleanHTMLContent(htmlText string) string {
-	if htmlText == "" {
-		return ""
-	}
-
-	doc, err := html.Parse(strings.NewReader(htmlText))
-	if err != nil {
-		return e.stripHTMLSimple(htmlText)
-	}
-
-	var text strings.Builder
-	var extractText func(*html.Node)
-	extractText = func(n *html.Node) {
-		if n.Type == html.TextNode {
-			text.WriteString(n.Data)
-		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			extractText(c)
-		}
-	}
-	extractText(doc)
-
-	result := stdhtml.UnescapeString(text.String())
-	result = regexp.MustCompile(`\s+`).ReplaceAllString(result, " ")
-
-	return strings.TrimSpace(result)
-}
-
-func (e *KeywordExtractor) stripHTMLSimple(htmlText string) string {
-	re := regexp.MustCompile(`<[^>]+>`)
-	text := re.ReplaceAllString(htmlText, " ")
-	text = stdhtml.UnescapeString(text)
-	text = regexp.MustCompile(`\s+`).ReplaceAllString(text, " ")
-	return strings.TrimSpace(text)
+	return htmltext.Inline(htmlText)
 }
 
 func (e *KeywordExtractor) IsTechnicalKeyword(word string) bool {
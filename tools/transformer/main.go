@@ -3,14 +3,12 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	stdhtml "html"
 	"os"
-	"regexp"
 	"strings"
 	"time"
 
+	"github.com/Tencent/WeKnora/pkg/htmltext"
 	"github.com/Wintercom/c-cube/tools/common"
-	"golang.org/x/net/html"
 )
 
 type HistoricalQA struct {
@@ -73,40 +71,7 @@ func loadAdditionalKeywords(filePath string) map[string]bool {
 }
 
 func (t *QADataTransformer) CleanHTMLContent(htmlText string) string {
-	if htmlText == "" {
-		return ""
-	}
-
-	doc, err := html.Parse(strings.NewReader(htmlText))
-	if err != nil {
-		return t.stripHTMLSimple(htmlText)
-	}
-
-	var text strings.Builder
-	var extractText func(*html.Node)
-	extractText = func(n *html.Node) {
-		if n.Type == html.TextNode {
-			text.WriteString(n.Data)
-		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			extractText(c)
-		}
-	}
-	extractText(doc)
-
-	result := stdhtml.UnescapeString(text.String())
-	result = regexp.MustCompile(`\s+`).ReplaceAllString(result, " ")
-	result = regexp.MustCompile(`\n{3,}`).ReplaceAllString(result, "\n\n")
-
-	return strings.TrimSpace(result)
-}
-
-func (t *QADataTransformer) stripHTMLSimple(htmlText string) string {
-	re := regexp.MustCompile(`<[^>]+>`)
-	text := re.ReplaceAllString(htmlText, " ")
-	text = stdhtml.UnescapeString(text)
-	text = regexp.MustCompile(`\s+`).ReplaceAllString(text, " ")
-	return strings.TrimSpace(text)
+	return htmltext.Inline(htmlText)
 }
 
 func (t *QADataTransformer) BuildConversationalPassage(qa HistoricalQA) string {